@@ -9,6 +9,7 @@ const (
 	CodeInternal     = "INTERNAL_ERROR"
 	CodeUnauthorized = "UNAUTHORIZED"
 	CodeExists       = "ALREADY_EXISTS"
+	CodeTooLarge     = "PAYLOAD_TOO_LARGE"
 )
 
 // NewError creates a standardized GraphQL error
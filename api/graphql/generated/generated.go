@@ -97,11 +97,13 @@ type ComplexityRoot struct {
 	}
 
 	ImapConfig struct {
-		ImapPassword func(childComplexity int) int
-		ImapPort     func(childComplexity int) int
-		ImapSecurity func(childComplexity int) int
-		ImapServer   func(childComplexity int) int
-		ImapUsername func(childComplexity int) int
+		ImapCaCert             func(childComplexity int) int
+		ImapInsecureSkipVerify func(childComplexity int) int
+		ImapPassword           func(childComplexity int) int
+		ImapPort               func(childComplexity int) int
+		ImapSecurity           func(childComplexity int) int
+		ImapServer             func(childComplexity int) int
+		ImapUsername           func(childComplexity int) int
 	}
 
 	Mailbox struct {
@@ -118,9 +120,10 @@ type ComplexityRoot struct {
 	}
 
 	Mutation struct {
-		AddMailbox    func(childComplexity int, input graphql_model.MailboxInput) int
-		SendEmail     func(childComplexity int, input graphql_model.EmailInput) int
-		UpdateMailbox func(childComplexity int, id string, input graphql_model.MailboxInput) int
+		AddMailbox           func(childComplexity int, input graphql_model.MailboxInput) int
+		CancelScheduledEmail func(childComplexity int, emailID string) int
+		SendEmail            func(childComplexity int, input graphql_model.EmailInput) int
+		UpdateMailbox        func(childComplexity int, id string, input graphql_model.MailboxInput) int
 	}
 
 	PageInfo struct {
@@ -155,6 +158,7 @@ type ComplexityRoot struct {
 
 type MutationResolver interface {
 	SendEmail(ctx context.Context, input graphql_model.EmailInput) (*graphql_model.EmailResult, error)
+	CancelScheduledEmail(ctx context.Context, emailID string) (*graphql_model.EmailResult, error)
 	AddMailbox(ctx context.Context, input graphql_model.MailboxInput) (*graphql_model.Mailbox, error)
 	UpdateMailbox(ctx context.Context, id string, input graphql_model.MailboxInput) (*graphql_model.Mailbox, error)
 }
@@ -414,6 +418,20 @@ func (e *executableSchema) Complexity(typeName, field string, childComplexity in
 
 		return e.complexity.EmailThreadConnection.TotalCount(childComplexity), true
 
+	case "ImapConfig.imapCaCert":
+		if e.complexity.ImapConfig.ImapCaCert == nil {
+			break
+		}
+
+		return e.complexity.ImapConfig.ImapCaCert(childComplexity), true
+
+	case "ImapConfig.imapInsecureSkipVerify":
+		if e.complexity.ImapConfig.ImapInsecureSkipVerify == nil {
+			break
+		}
+
+		return e.complexity.ImapConfig.ImapInsecureSkipVerify(childComplexity), true
+
 	case "ImapConfig.imapPassword":
 		if e.complexity.ImapConfig.ImapPassword == nil {
 			break
@@ -531,6 +549,18 @@ func (e *executableSchema) Complexity(typeName, field string, childComplexity in
 
 		return e.complexity.Mutation.AddMailbox(childComplexity, args["input"].(graphql_model.MailboxInput)), true
 
+	case "Mutation.cancelScheduledEmail":
+		if e.complexity.Mutation.CancelScheduledEmail == nil {
+			break
+		}
+
+		args, err := ec.field_Mutation_cancelScheduledEmail_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Mutation.CancelScheduledEmail(childComplexity, args["emailId"].(string)), true
+
 	case "Mutation.sendEmail":
 		if e.complexity.Mutation.SendEmail == nil {
 			break
@@ -698,6 +728,7 @@ func (e *executableSchema) Exec(ctx context.Context) graphql.ResponseHandler {
 	ec := executionContext{opCtx, e, 0, 0, make(chan graphql.DeferredResult)}
 	inputUnmarshalMap := graphql.BuildUnmarshalerMap(
 		ec.unmarshalInputEmailBody,
+		ec.unmarshalInputEmailHeaderInput,
 		ec.unmarshalInputEmailInput,
 		ec.unmarshalInputImapConfigInput,
 		ec.unmarshalInputMailboxInput,
@@ -831,6 +862,7 @@ interface Connection {
 
 enum EmailStatus {
   received
+  draft
   queued
   scheduled
   sent
@@ -851,6 +883,9 @@ input EmailInput {
   attachmentIds: [String!]
   scheduleFor: Time
   trackClicks: Boolean
+  unsubscribeUrl: String
+  unsubscribeMailto: String
+  headers: [EmailHeaderInput!]
 }
 
 input EmailBody {
@@ -858,6 +893,11 @@ input EmailBody {
   html: String
 }
 
+input EmailHeaderInput {
+  name: String!
+  value: String!
+}
+
 type EmailResult {
   emailId: String!
   status: EmailStatus!
@@ -902,6 +942,7 @@ extend type Query {
 
 extend type Mutation {
   sendEmail(input: EmailInput!): EmailResult!
+  cancelScheduledEmail(emailId: String!): EmailResult!
 }
 `, BuiltIn: false},
 	{Name: "../schemas/mailboxes.graphqls", Input: `enum MailboxProvider {
@@ -915,6 +956,7 @@ enum EmailSecurity {
   none
   ssl
   tls
+  startTLS
 }
 
 enum MailboxConnectionStatus {
@@ -934,6 +976,11 @@ input MailboxInput {
   smtpConfig: SmtpConfigInput
   replyToAddress: String
   syncFolders: [String]
+  syncBatchSize: Int
+  syncMaxMessages: Int
+  syncPollIntervalSeconds: Int
+  sentFolder: String
+  messageIdDomain: String
 }
 
 input ImapConfigInput {
@@ -942,6 +989,8 @@ input ImapConfigInput {
   imapUsername: String
   imapPassword: String
   imapSecurity: EmailSecurity
+  imapInsecureSkipVerify: Boolean
+  imapCaCert: String
 }
 
 input SmtpConfigInput {
@@ -972,6 +1021,8 @@ type ImapConfig {
   imapUsername: String
   imapPassword: String
   imapSecurity: EmailSecurity
+  imapInsecureSkipVerify: Boolean
+  imapCaCert: String
 }
 
 type SmtpConfig {
@@ -1048,6 +1099,34 @@ func (ec *executionContext) field_Mutation_addMailbox_argsInput(
 	return zeroVal, nil
 }
 
+func (ec *executionContext) field_Mutation_cancelScheduledEmail_args(ctx context.Context, rawArgs map[string]any) (map[string]any, error) {
+	var err error
+	args := map[string]any{}
+	arg0, err := ec.field_Mutation_cancelScheduledEmail_argsEmailID(ctx, rawArgs)
+	if err != nil {
+		return nil, err
+	}
+	args["emailId"] = arg0
+	return args, nil
+}
+func (ec *executionContext) field_Mutation_cancelScheduledEmail_argsEmailID(
+	ctx context.Context,
+	rawArgs map[string]any,
+) (string, error) {
+	if _, ok := rawArgs["emailId"]; !ok {
+		var zeroVal string
+		return zeroVal, nil
+	}
+
+	ctx = graphql.WithPathContext(ctx, graphql.NewPathWithField("emailId"))
+	if tmp, ok := rawArgs["emailId"]; ok {
+		return ec.unmarshalNString2string(ctx, tmp)
+	}
+
+	var zeroVal string
+	return zeroVal, nil
+}
+
 func (ec *executionContext) field_Mutation_sendEmail_args(ctx context.Context, rawArgs map[string]any) (map[string]any, error) {
 	var err error
 	args := map[string]any{}
@@ -3059,6 +3138,88 @@ func (ec *executionContext) fieldContext_ImapConfig_imapSecurity(_ context.Conte
 	return fc, nil
 }
 
+func (ec *executionContext) _ImapConfig_imapInsecureSkipVerify(ctx context.Context, field graphql.CollectedField, obj *graphql_model.ImapConfig) (ret graphql.Marshaler) {
+	fc, err := ec.fieldContext_ImapConfig_imapInsecureSkipVerify(ctx, field)
+	if err != nil {
+		return graphql.Null
+	}
+	ctx = graphql.WithFieldContext(ctx, fc)
+	defer func() {
+		if r := recover(); r != nil {
+			ec.Error(ctx, ec.Recover(ctx, r))
+			ret = graphql.Null
+		}
+	}()
+	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (any, error) {
+		ctx = rctx // use context from middleware stack in children
+		return obj.ImapInsecureSkipVerify, nil
+	})
+	if err != nil {
+		ec.Error(ctx, err)
+		return graphql.Null
+	}
+	if resTmp == nil {
+		return graphql.Null
+	}
+	res := resTmp.(*bool)
+	fc.Result = res
+	return ec.marshalOBoolean2ᚖbool(ctx, field.Selections, res)
+}
+
+func (ec *executionContext) fieldContext_ImapConfig_imapInsecureSkipVerify(_ context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
+	fc = &graphql.FieldContext{
+		Object:     "ImapConfig",
+		Field:      field,
+		IsMethod:   false,
+		IsResolver: false,
+		Child: func(ctx context.Context, field graphql.CollectedField) (*graphql.FieldContext, error) {
+			return nil, errors.New("field of type Boolean does not have child fields")
+		},
+	}
+	return fc, nil
+}
+
+func (ec *executionContext) _ImapConfig_imapCaCert(ctx context.Context, field graphql.CollectedField, obj *graphql_model.ImapConfig) (ret graphql.Marshaler) {
+	fc, err := ec.fieldContext_ImapConfig_imapCaCert(ctx, field)
+	if err != nil {
+		return graphql.Null
+	}
+	ctx = graphql.WithFieldContext(ctx, fc)
+	defer func() {
+		if r := recover(); r != nil {
+			ec.Error(ctx, ec.Recover(ctx, r))
+			ret = graphql.Null
+		}
+	}()
+	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (any, error) {
+		ctx = rctx // use context from middleware stack in children
+		return obj.ImapCaCert, nil
+	})
+	if err != nil {
+		ec.Error(ctx, err)
+		return graphql.Null
+	}
+	if resTmp == nil {
+		return graphql.Null
+	}
+	res := resTmp.(*string)
+	fc.Result = res
+	return ec.marshalOString2ᚖstring(ctx, field.Selections, res)
+}
+
+func (ec *executionContext) fieldContext_ImapConfig_imapCaCert(_ context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
+	fc = &graphql.FieldContext{
+		Object:     "ImapConfig",
+		Field:      field,
+		IsMethod:   false,
+		IsResolver: false,
+		Child: func(ctx context.Context, field graphql.CollectedField) (*graphql.FieldContext, error) {
+			return nil, errors.New("field of type String does not have child fields")
+		},
+	}
+	return fc, nil
+}
+
 func (ec *executionContext) _Mailbox_id(ctx context.Context, field graphql.CollectedField, obj *graphql_model.Mailbox) (ret graphql.Marshaler) {
 	fc, err := ec.fieldContext_Mailbox_id(ctx, field)
 	if err != nil {
@@ -3553,6 +3714,69 @@ func (ec *executionContext) fieldContext_Mutation_sendEmail(ctx context.Context,
 	return fc, nil
 }
 
+func (ec *executionContext) _Mutation_cancelScheduledEmail(ctx context.Context, field graphql.CollectedField) (ret graphql.Marshaler) {
+	fc, err := ec.fieldContext_Mutation_cancelScheduledEmail(ctx, field)
+	if err != nil {
+		return graphql.Null
+	}
+	ctx = graphql.WithFieldContext(ctx, fc)
+	defer func() {
+		if r := recover(); r != nil {
+			ec.Error(ctx, ec.Recover(ctx, r))
+			ret = graphql.Null
+		}
+	}()
+	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (any, error) {
+		ctx = rctx // use context from middleware stack in children
+		return ec.resolvers.Mutation().CancelScheduledEmail(rctx, fc.Args["emailId"].(string))
+	})
+	if err != nil {
+		ec.Error(ctx, err)
+		return graphql.Null
+	}
+	if resTmp == nil {
+		if !graphql.HasFieldError(ctx, fc) {
+			ec.Errorf(ctx, "must not be null")
+		}
+		return graphql.Null
+	}
+	res := resTmp.(*graphql_model.EmailResult)
+	fc.Result = res
+	return ec.marshalNEmailResult2ᚖgithubᚗcomᚋcustomerosᚋmailstackᚋapiᚋgraphqlᚋgraphql_modelᚐEmailResult(ctx, field.Selections, res)
+}
+
+func (ec *executionContext) fieldContext_Mutation_cancelScheduledEmail(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
+	fc = &graphql.FieldContext{
+		Object:     "Mutation",
+		Field:      field,
+		IsMethod:   true,
+		IsResolver: true,
+		Child: func(ctx context.Context, field graphql.CollectedField) (*graphql.FieldContext, error) {
+			switch field.Name {
+			case "emailId":
+				return ec.fieldContext_EmailResult_emailId(ctx, field)
+			case "status":
+				return ec.fieldContext_EmailResult_status(ctx, field)
+			case "error":
+				return ec.fieldContext_EmailResult_error(ctx, field)
+			}
+			return nil, fmt.Errorf("no field named %q was found under type EmailResult", field.Name)
+		},
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = ec.Recover(ctx, r)
+			ec.Error(ctx, err)
+		}
+	}()
+	ctx = graphql.WithFieldContext(ctx, fc)
+	if fc.Args, err = ec.field_Mutation_cancelScheduledEmail_args(ctx, field.ArgumentMap(ec.Variables)); err != nil {
+		ec.Error(ctx, err)
+		return fc, err
+	}
+	return fc, nil
+}
+
 func (ec *executionContext) _Mutation_addMailbox(ctx context.Context, field graphql.CollectedField) (ret graphql.Marshaler) {
 	fc, err := ec.fieldContext_Mutation_addMailbox(ctx, field)
 	if err != nil {
@@ -6638,6 +6862,40 @@ func (ec *executionContext) unmarshalInputEmailBody(ctx context.Context, obj any
 	return it, nil
 }
 
+func (ec *executionContext) unmarshalInputEmailHeaderInput(ctx context.Context, obj any) (graphql_model.EmailHeaderInput, error) {
+	var it graphql_model.EmailHeaderInput
+	asMap := map[string]any{}
+	for k, v := range obj.(map[string]any) {
+		asMap[k] = v
+	}
+
+	fieldsInOrder := [...]string{"name", "value"}
+	for _, k := range fieldsInOrder {
+		v, ok := asMap[k]
+		if !ok {
+			continue
+		}
+		switch k {
+		case "name":
+			ctx := graphql.WithPathContext(ctx, graphql.NewPathWithField("name"))
+			data, err := ec.unmarshalNString2string(ctx, v)
+			if err != nil {
+				return it, err
+			}
+			it.Name = data
+		case "value":
+			ctx := graphql.WithPathContext(ctx, graphql.NewPathWithField("value"))
+			data, err := ec.unmarshalNString2string(ctx, v)
+			if err != nil {
+				return it, err
+			}
+			it.Value = data
+		}
+	}
+
+	return it, nil
+}
+
 func (ec *executionContext) unmarshalInputEmailInput(ctx context.Context, obj any) (graphql_model.EmailInput, error) {
 	var it graphql_model.EmailInput
 	asMap := map[string]any{}
@@ -6645,7 +6903,7 @@ func (ec *executionContext) unmarshalInputEmailInput(ctx context.Context, obj an
 		asMap[k] = v
 	}
 
-	fieldsInOrder := [...]string{"mailboxId", "fromAddress", "fromName", "toAddresses", "ccAddresses", "bccAddresses", "replyTo", "subject", "body", "attachmentIds", "scheduleFor", "trackClicks"}
+	fieldsInOrder := [...]string{"mailboxId", "fromAddress", "fromName", "toAddresses", "ccAddresses", "bccAddresses", "replyTo", "subject", "body", "attachmentIds", "scheduleFor", "trackClicks", "unsubscribeUrl", "unsubscribeMailto", "headers"}
 	for _, k := range fieldsInOrder {
 		v, ok := asMap[k]
 		if !ok {
@@ -6736,6 +6994,27 @@ func (ec *executionContext) unmarshalInputEmailInput(ctx context.Context, obj an
 				return it, err
 			}
 			it.TrackClicks = data
+		case "unsubscribeUrl":
+			ctx := graphql.WithPathContext(ctx, graphql.NewPathWithField("unsubscribeUrl"))
+			data, err := ec.unmarshalOString2ᚖstring(ctx, v)
+			if err != nil {
+				return it, err
+			}
+			it.UnsubscribeURL = data
+		case "unsubscribeMailto":
+			ctx := graphql.WithPathContext(ctx, graphql.NewPathWithField("unsubscribeMailto"))
+			data, err := ec.unmarshalOString2ᚖstring(ctx, v)
+			if err != nil {
+				return it, err
+			}
+			it.UnsubscribeMailto = data
+		case "headers":
+			ctx := graphql.WithPathContext(ctx, graphql.NewPathWithField("headers"))
+			data, err := ec.unmarshalOEmailHeaderInput2ᚕᚖgithubᚗcomᚋcustomerosᚋmailstackᚋapiᚋgraphqlᚋgraphql_modelᚐEmailHeaderInputᚄ(ctx, v)
+			if err != nil {
+				return it, err
+			}
+			it.Headers = data
 		}
 	}
 
@@ -6749,7 +7028,7 @@ func (ec *executionContext) unmarshalInputImapConfigInput(ctx context.Context, o
 		asMap[k] = v
 	}
 
-	fieldsInOrder := [...]string{"imapServer", "imapPort", "imapUsername", "imapPassword", "imapSecurity"}
+	fieldsInOrder := [...]string{"imapServer", "imapPort", "imapUsername", "imapPassword", "imapSecurity", "imapInsecureSkipVerify", "imapCaCert"}
 	for _, k := range fieldsInOrder {
 		v, ok := asMap[k]
 		if !ok {
@@ -6791,6 +7070,20 @@ func (ec *executionContext) unmarshalInputImapConfigInput(ctx context.Context, o
 				return it, err
 			}
 			it.ImapSecurity = data
+		case "imapInsecureSkipVerify":
+			ctx := graphql.WithPathContext(ctx, graphql.NewPathWithField("imapInsecureSkipVerify"))
+			data, err := ec.unmarshalOBoolean2ᚖbool(ctx, v)
+			if err != nil {
+				return it, err
+			}
+			it.ImapInsecureSkipVerify = data
+		case "imapCaCert":
+			ctx := graphql.WithPathContext(ctx, graphql.NewPathWithField("imapCaCert"))
+			data, err := ec.unmarshalOString2ᚖstring(ctx, v)
+			if err != nil {
+				return it, err
+			}
+			it.ImapCaCert = data
 		}
 	}
 
@@ -6804,7 +7097,7 @@ func (ec *executionContext) unmarshalInputMailboxInput(ctx context.Context, obj
 		asMap[k] = v
 	}
 
-	fieldsInOrder := [...]string{"id", "provider", "emailAddress", "senderId", "inboundEnabled", "outboundEnabled", "imapConfig", "smtpConfig", "replyToAddress", "syncFolders"}
+	fieldsInOrder := [...]string{"id", "provider", "emailAddress", "senderId", "inboundEnabled", "outboundEnabled", "imapConfig", "smtpConfig", "replyToAddress", "syncFolders", "syncBatchSize", "syncMaxMessages", "syncPollIntervalSeconds", "sentFolder", "messageIdDomain"}
 	for _, k := range fieldsInOrder {
 		v, ok := asMap[k]
 		if !ok {
@@ -6881,6 +7174,41 @@ func (ec *executionContext) unmarshalInputMailboxInput(ctx context.Context, obj
 				return it, err
 			}
 			it.SyncFolders = data
+		case "syncBatchSize":
+			ctx := graphql.WithPathContext(ctx, graphql.NewPathWithField("syncBatchSize"))
+			data, err := ec.unmarshalOInt2ᚖint(ctx, v)
+			if err != nil {
+				return it, err
+			}
+			it.SyncBatchSize = data
+		case "syncMaxMessages":
+			ctx := graphql.WithPathContext(ctx, graphql.NewPathWithField("syncMaxMessages"))
+			data, err := ec.unmarshalOInt2ᚖint(ctx, v)
+			if err != nil {
+				return it, err
+			}
+			it.SyncMaxMessages = data
+		case "syncPollIntervalSeconds":
+			ctx := graphql.WithPathContext(ctx, graphql.NewPathWithField("syncPollIntervalSeconds"))
+			data, err := ec.unmarshalOInt2ᚖint(ctx, v)
+			if err != nil {
+				return it, err
+			}
+			it.SyncPollIntervalSeconds = data
+		case "sentFolder":
+			ctx := graphql.WithPathContext(ctx, graphql.NewPathWithField("sentFolder"))
+			data, err := ec.unmarshalOString2ᚖstring(ctx, v)
+			if err != nil {
+				return it, err
+			}
+			it.SentFolder = data
+		case "messageIdDomain":
+			ctx := graphql.WithPathContext(ctx, graphql.NewPathWithField("messageIdDomain"))
+			data, err := ec.unmarshalOString2ᚖstring(ctx, v)
+			if err != nil {
+				return it, err
+			}
+			it.MessageIDDomain = data
 		}
 	}
 
@@ -7344,6 +7672,10 @@ func (ec *executionContext) _ImapConfig(ctx context.Context, sel ast.SelectionSe
 			out.Values[i] = ec._ImapConfig_imapPassword(ctx, field, obj)
 		case "imapSecurity":
 			out.Values[i] = ec._ImapConfig_imapSecurity(ctx, field, obj)
+		case "imapInsecureSkipVerify":
+			out.Values[i] = ec._ImapConfig_imapInsecureSkipVerify(ctx, field, obj)
+		case "imapCaCert":
+			out.Values[i] = ec._ImapConfig_imapCaCert(ctx, field, obj)
 		default:
 			panic("unknown field " + strconv.Quote(field.Name))
 		}
@@ -7468,6 +7800,13 @@ func (ec *executionContext) _Mutation(ctx context.Context, sel ast.SelectionSet)
 			if out.Values[i] == graphql.Null {
 				out.Invalids++
 			}
+		case "cancelScheduledEmail":
+			out.Values[i] = ec.OperationContext.RootResolverMiddleware(innerCtx, func(ctx context.Context) (res graphql.Marshaler) {
+				return ec._Mutation_cancelScheduledEmail(ctx, field)
+			})
+			if out.Values[i] == graphql.Null {
+				out.Invalids++
+			}
 		case "addMailbox":
 			out.Values[i] = ec.OperationContext.RootResolverMiddleware(innerCtx, func(ctx context.Context) (res graphql.Marshaler) {
 				return ec._Mutation_addMailbox(ctx, field)
@@ -8124,6 +8463,11 @@ func (ec *executionContext) unmarshalNEmailBody2ᚖgithubᚗcomᚋcustomerosᚋm
 	return &res, graphql.ErrorOnPath(ctx, err)
 }
 
+func (ec *executionContext) unmarshalNEmailHeaderInput2ᚖgithubᚗcomᚋcustomerosᚋmailstackᚋapiᚋgraphqlᚋgraphql_modelᚐEmailHeaderInput(ctx context.Context, v any) (*graphql_model.EmailHeaderInput, error) {
+	res, err := ec.unmarshalInputEmailHeaderInput(ctx, v)
+	return &res, graphql.ErrorOnPath(ctx, err)
+}
+
 func (ec *executionContext) unmarshalNEmailDirection2githubᚗcomᚋcustomerosᚋmailstackᚋinternalᚋenumᚐEmailDirection(ctx context.Context, v any) (enum.EmailDirection, error) {
 	tmp, err := graphql.UnmarshalString(v)
 	res := enum.EmailDirection(tmp)
@@ -8772,6 +9116,24 @@ func (ec *executionContext) marshalOBoolean2ᚖbool(ctx context.Context, sel ast
 	return res
 }
 
+func (ec *executionContext) unmarshalOEmailHeaderInput2ᚕᚖgithubᚗcomᚋcustomerosᚋmailstackᚋapiᚋgraphqlᚋgraphql_modelᚐEmailHeaderInputᚄ(ctx context.Context, v any) ([]*graphql_model.EmailHeaderInput, error) {
+	if v == nil {
+		return nil, nil
+	}
+	var vSlice []any
+	vSlice = graphql.CoerceList(v)
+	var err error
+	res := make([]*graphql_model.EmailHeaderInput, len(vSlice))
+	for i := range vSlice {
+		ctx := graphql.WithPathContext(ctx, graphql.NewPathWithIndex(i))
+		res[i], err = ec.unmarshalNEmailHeaderInput2ᚖgithubᚗcomᚋcustomerosᚋmailstackᚋapiᚋgraphqlᚋgraphql_modelᚐEmailHeaderInput(ctx, vSlice[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
 func (ec *executionContext) unmarshalOEmailSecurity2ᚖgithubᚗcomᚋcustomerosᚋmailstackᚋinternalᚋenumᚐEmailSecurity(ctx context.Context, v any) (*enum.EmailSecurity, error) {
 	if v == nil {
 		return nil, nil
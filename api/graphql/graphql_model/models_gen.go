@@ -26,19 +26,27 @@ type EmailBody struct {
 	HTML *string `json:"html,omitempty"`
 }
 
+type EmailHeaderInput struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
 type EmailInput struct {
-	MailboxID     *string    `json:"mailboxId,omitempty"`
-	FromAddress   string     `json:"fromAddress"`
-	FromName      *string    `json:"fromName,omitempty"`
-	ToAddresses   []string   `json:"toAddresses"`
-	CcAddresses   []string   `json:"ccAddresses,omitempty"`
-	BccAddresses  []string   `json:"bccAddresses,omitempty"`
-	ReplyTo       *string    `json:"replyTo,omitempty"`
-	Subject       string     `json:"subject"`
-	Body          *EmailBody `json:"body"`
-	AttachmentIds []string   `json:"attachmentIds,omitempty"`
-	ScheduleFor   *time.Time `json:"scheduleFor,omitempty"`
-	TrackClicks   *bool      `json:"trackClicks,omitempty"`
+	MailboxID         *string             `json:"mailboxId,omitempty"`
+	FromAddress       string              `json:"fromAddress"`
+	FromName          *string             `json:"fromName,omitempty"`
+	ToAddresses       []string            `json:"toAddresses"`
+	CcAddresses       []string            `json:"ccAddresses,omitempty"`
+	BccAddresses      []string            `json:"bccAddresses,omitempty"`
+	ReplyTo           *string             `json:"replyTo,omitempty"`
+	Subject           string              `json:"subject"`
+	Body              *EmailBody          `json:"body"`
+	AttachmentIds     []string            `json:"attachmentIds,omitempty"`
+	ScheduleFor       *time.Time          `json:"scheduleFor,omitempty"`
+	TrackClicks       *bool               `json:"trackClicks,omitempty"`
+	UnsubscribeURL    *string             `json:"unsubscribeUrl,omitempty"`
+	UnsubscribeMailto *string             `json:"unsubscribeMailto,omitempty"`
+	Headers           []*EmailHeaderInput `json:"headers,omitempty"`
 }
 
 type EmailMessage struct {
@@ -87,19 +95,23 @@ func (this EmailThreadConnection) GetPageInfo() *PageInfo { return this.PageInfo
 func (this EmailThreadConnection) GetTotalCount() int     { return this.TotalCount }
 
 type ImapConfig struct {
-	ImapServer   *string             `json:"imapServer,omitempty"`
-	ImapPort     *int                `json:"imapPort,omitempty"`
-	ImapUsername *string             `json:"imapUsername,omitempty"`
-	ImapPassword *string             `json:"imapPassword,omitempty"`
-	ImapSecurity *enum.EmailSecurity `json:"imapSecurity,omitempty"`
+	ImapServer             *string             `json:"imapServer,omitempty"`
+	ImapPort               *int                `json:"imapPort,omitempty"`
+	ImapUsername           *string             `json:"imapUsername,omitempty"`
+	ImapPassword           *string             `json:"imapPassword,omitempty"`
+	ImapSecurity           *enum.EmailSecurity `json:"imapSecurity,omitempty"`
+	ImapInsecureSkipVerify *bool               `json:"imapInsecureSkipVerify,omitempty"`
+	ImapCaCert             *string             `json:"imapCaCert,omitempty"`
 }
 
 type ImapConfigInput struct {
-	ImapServer   *string             `json:"imapServer,omitempty"`
-	ImapPort     *int                `json:"imapPort,omitempty"`
-	ImapUsername *string             `json:"imapUsername,omitempty"`
-	ImapPassword *string             `json:"imapPassword,omitempty"`
-	ImapSecurity *enum.EmailSecurity `json:"imapSecurity,omitempty"`
+	ImapServer             *string             `json:"imapServer,omitempty"`
+	ImapPort               *int                `json:"imapPort,omitempty"`
+	ImapUsername           *string             `json:"imapUsername,omitempty"`
+	ImapPassword           *string             `json:"imapPassword,omitempty"`
+	ImapSecurity           *enum.EmailSecurity `json:"imapSecurity,omitempty"`
+	ImapInsecureSkipVerify *bool               `json:"imapInsecureSkipVerify,omitempty"`
+	ImapCaCert             *string             `json:"imapCaCert,omitempty"`
 }
 
 type Mailbox struct {
@@ -116,16 +128,21 @@ type Mailbox struct {
 }
 
 type MailboxInput struct {
-	ID              *string            `json:"id,omitempty"`
-	Provider        enum.EmailProvider `json:"provider"`
-	EmailAddress    string             `json:"emailAddress"`
-	SenderID        *string            `json:"senderId,omitempty"`
-	InboundEnabled  *bool              `json:"inboundEnabled,omitempty"`
-	OutboundEnabled *bool              `json:"outboundEnabled,omitempty"`
-	ImapConfig      *ImapConfigInput   `json:"imapConfig,omitempty"`
-	SMTPConfig      *SMTPConfigInput   `json:"smtpConfig,omitempty"`
-	ReplyToAddress  *string            `json:"replyToAddress,omitempty"`
-	SyncFolders     []*string          `json:"syncFolders,omitempty"`
+	ID                      *string            `json:"id,omitempty"`
+	Provider                enum.EmailProvider `json:"provider"`
+	EmailAddress            string             `json:"emailAddress"`
+	SenderID                *string            `json:"senderId,omitempty"`
+	InboundEnabled          *bool              `json:"inboundEnabled,omitempty"`
+	OutboundEnabled         *bool              `json:"outboundEnabled,omitempty"`
+	ImapConfig              *ImapConfigInput   `json:"imapConfig,omitempty"`
+	SMTPConfig              *SMTPConfigInput   `json:"smtpConfig,omitempty"`
+	ReplyToAddress          *string            `json:"replyToAddress,omitempty"`
+	SyncFolders             []*string          `json:"syncFolders,omitempty"`
+	SyncBatchSize           *int               `json:"syncBatchSize,omitempty"`
+	SyncMaxMessages         *int               `json:"syncMaxMessages,omitempty"`
+	SyncPollIntervalSeconds *int               `json:"syncPollIntervalSeconds,omitempty"`
+	SentFolder              *string            `json:"sentFolder,omitempty"`
+	MessageIDDomain         *string            `json:"messageIdDomain,omitempty"`
 }
 
 type Mutation struct {
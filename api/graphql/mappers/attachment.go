@@ -10,6 +10,6 @@ func MapGormAttachmentToGraph(attachment *models.EmailAttachment) *graphql_model
 		ID:          attachment.ID,
 		Filename:    attachment.Filename,
 		ContentType: attachment.ContentType,
-		URL:         "https://files.cust.cx/" + attachment.StorageKey,
+		URL:         attachment.PublicURL(),
 	}
 }
@@ -24,7 +24,7 @@ func MapGormEmailToGraph(email *models.Email) *graphql_model.EmailMessage {
 }
 
 func MapGraphEmailInputToGorm(email *graphql_model.EmailInput) *models.Email {
-	return &models.Email{
+	gormEmail := &models.Email{
 		MailboxID:    utils.GetOrDefault(email.MailboxID, ""),
 		Direction:    enum.EmailDirectionOutbound,
 		FromAddress:  email.FromAddress,
@@ -38,5 +38,16 @@ func MapGraphEmailInputToGorm(email *graphql_model.EmailInput) *models.Email {
 		BodyHTML:     utils.GetOrDefault(email.Body.HTML, ""),
 		ScheduledFor: email.ScheduleFor,
 		TrackClicks:  utils.GetOrDefault(email.TrackClicks, false),
+
+		UnsubscribeURL:    utils.GetOrDefault(email.UnsubscribeURL, ""),
+		UnsubscribeMailto: utils.GetOrDefault(email.UnsubscribeMailto, ""),
+	}
+
+	headers := make(map[string]string, len(email.Headers))
+	for _, header := range email.Headers {
+		headers[header.Name] = header.Value
 	}
+	gormEmail.SetCustomHeaders(headers)
+
+	return gormEmail
 }
@@ -69,6 +69,12 @@ func MapGraphMailboxInputToGorm(input *graphql_model.MailboxInput) *models.Mailb
 		if input.ImapConfig.ImapSecurity != nil {
 			gormMailbox.ImapSecurity = *input.ImapConfig.ImapSecurity
 		}
+		if input.ImapConfig.ImapInsecureSkipVerify != nil {
+			gormMailbox.ImapInsecureSkipVerify = *input.ImapConfig.ImapInsecureSkipVerify
+		}
+		if input.ImapConfig.ImapCaCert != nil {
+			gormMailbox.ImapCACert = *input.ImapConfig.ImapCaCert
+		}
 	}
 
 	// Map SMTP configuration if provided
@@ -101,6 +107,21 @@ func MapGraphMailboxInputToGorm(input *graphql_model.MailboxInput) *models.Mailb
 		}
 		gormMailbox.SyncFolders = syncFolders
 	}
+	if input.SyncBatchSize != nil {
+		gormMailbox.SyncBatchSize = *input.SyncBatchSize
+	}
+	if input.SyncMaxMessages != nil {
+		gormMailbox.SyncMaxMessages = *input.SyncMaxMessages
+	}
+	if input.SyncPollIntervalSeconds != nil {
+		gormMailbox.SyncPollIntervalSeconds = *input.SyncPollIntervalSeconds
+	}
+	if input.SentFolder != nil {
+		gormMailbox.SentFolder = *input.SentFolder
+	}
+	if input.MessageIDDomain != nil {
+		gormMailbox.MessageIDDomain = *input.MessageIDDomain
+	}
 
 	return gormMailbox
 }
@@ -7,13 +7,16 @@ package resolver
 import (
 	"context"
 	"errors"
+	"net/http"
 
 	api_errors "github.com/customeros/mailstack/api/errors"
 	"github.com/customeros/mailstack/api/graphql/graphql_model"
 	"github.com/customeros/mailstack/api/graphql/mappers"
 	"github.com/customeros/mailstack/internal/enum"
+	mailstack_errors "github.com/customeros/mailstack/internal/errors"
 	"github.com/customeros/mailstack/internal/tracing"
 	"github.com/customeros/mailstack/internal/utils"
+	"github.com/customeros/mailstack/services/email"
 	opentracing "github.com/opentracing/opentracing-go"
 )
 
@@ -41,6 +44,12 @@ func (r *mutationResolver) SendEmail(ctx context.Context, input graphql_model.Em
 		errStr := err.Error()
 		result.Status = enum.EmailStatusFailed
 		result.Error = &errStr
+		if errors.Is(err, mailstack_errors.ErrMessageTooLarge) {
+			return &result, api_errors.NewError(errStr, api_errors.CodeTooLarge, map[string]interface{}{"status": http.StatusRequestEntityTooLarge})
+		}
+		if problems, ok := mailstack_errors.AsValidationErrors(err); ok {
+			return &result, api_errors.NewError(errStr, api_errors.CodeBadInput, map[string]interface{}{"validationErrors": problems})
+		}
 		return &result, err
 	}
 
@@ -50,6 +59,38 @@ func (r *mutationResolver) SendEmail(ctx context.Context, input graphql_model.Em
 	return &result, nil
 }
 
+// CancelScheduledEmail is the resolver for the cancelScheduledEmail field.
+func (r *mutationResolver) CancelScheduledEmail(ctx context.Context, emailID string) (*graphql_model.EmailResult, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "mutationResolver.CancelScheduledEmail")
+	defer span.Finish()
+	tracing.SetDefaultGraphqlSpanTags(ctx, span)
+	span.SetTag("email.id", emailID)
+
+	tenant := utils.GetTenantFromContext(ctx)
+	if tenant == "" {
+		tracing.TraceErr(span, errors.New("tenant not set"))
+		return nil, api_errors.NewError("tenant not set", api_errors.CodeBadInput, nil)
+	}
+
+	err := r.services.EmailService.CancelScheduledSend(ctx, emailID)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		switch {
+		case errors.Is(err, email.ErrEmailNotFound):
+			return nil, api_errors.NewError("email not found", api_errors.CodeNotFound, nil)
+		case errors.Is(err, email.ErrEmailNotScheduled):
+			return nil, api_errors.NewError("email is not scheduled", api_errors.CodeBadInput, nil)
+		default:
+			return nil, api_errors.NewError("error cancelling scheduled email", api_errors.CodeInternal, nil)
+		}
+	}
+
+	return &graphql_model.EmailResult{
+		EmailID: emailID,
+		Status:  enum.EmailStatusDraft,
+	}, nil
+}
+
 // GetAllEmailsInThread is the resolver for the getAllEmailsInThread field.
 func (r *queryResolver) GetAllEmailsInThread(ctx context.Context, threadID string) ([]*graphql_model.EmailMessage, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "queryResolver.GetEmailsByThread")
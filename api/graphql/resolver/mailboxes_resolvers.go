@@ -12,9 +12,9 @@ import (
 	api_errors "github.com/customeros/mailstack/api/errors"
 	"github.com/customeros/mailstack/api/graphql/graphql_model"
 	"github.com/customeros/mailstack/api/graphql/mappers"
+	er "github.com/customeros/mailstack/internal/errors"
 	"github.com/customeros/mailstack/internal/tracing"
 	"github.com/customeros/mailstack/internal/utils"
-	mbox "github.com/customeros/mailstack/services/mailbox"
 	opentracing "github.com/opentracing/opentracing-go"
 )
 
@@ -40,7 +40,7 @@ func (r *mutationResolver) AddMailbox(ctx context.Context, input graphql_model.M
 		tracing.TraceErr(span, err)
 
 		switch err {
-		case mbox.ErrMailboxExists:
+		case er.ErrMailboxExists:
 			return nil, api_errors.NewError("unable to add mailbox", api_errors.CodeExists, nil)
 		default:
 			return nil, api_errors.NewError("unable to add mailbox", api_errors.CodeInternal, nil)
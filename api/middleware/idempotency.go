@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/opentracing/opentracing-go"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed" // set to true on stored responses
+	maxIdempotencyKeyLength  = 255
+	// completeAttempts and completeBackoff bound the retries of storing a response
+	completeAttempts = 3
+	completeBackoff  = 200 * time.Millisecond
+)
+
+// IdempotencyMiddleware makes a request carrying an Idempotency-Key header run once per tenant and
+// key. The response is stored and returned to retries with the same key until it expires after
+// ttl, retries arriving while the first request is still handled get 409. Reusing a key for another
+// endpoint or body is refused with 422. Requests without the header are handled as usual.
+func IdempotencyMiddleware(repo interfaces.IdempotencyKeyRepository, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := strings.TrimSpace(c.GetHeader(IdempotencyKeyHeader))
+		if key == "" {
+			c.Next()
+			return
+		}
+		ctx := c.Request.Context()
+		span := opentracing.SpanFromContext(ctx)
+
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "idempotency key is too long"})
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		hash := sha256.Sum256(body)
+
+		reserved := &models.IdempotencyKey{
+			Tenant:      utils.GetTenantFromContext(ctx),
+			Key:         key,
+			Endpoint:    c.Request.Method + " " + c.FullPath(),
+			RequestHash: hex.EncodeToString(hash[:]),
+			ExpiresAt:   utils.Now().Add(ttl),
+		}
+		existing, err := repo.Reserve(ctx, reserved)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to check idempotency key"})
+			return
+		}
+		if existing != nil {
+			replayIdempotentResponse(c, existing, reserved)
+			return
+		}
+
+		// the outcome is stored even when the client went away, that is when it retries
+		storeCtx := context.WithoutCancel(ctx)
+		writer := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = writer
+		completed := false
+		defer func() {
+			if !completed {
+				// the handler panicked, nothing to replay so a retry runs it again
+				tracing.TraceErr(span, repo.Release(storeCtx, reserved.ID))
+			}
+		}()
+
+		c.Next()
+
+		completed = true
+		if err = completeIdempotencyKey(storeCtx, repo, reserved.ID, writer.Status(), writer.body.Bytes()); err != nil {
+			// without a stored response the key would answer 409 until it expires, a retry runs
+			// the request again instead
+			tracing.TraceErr(span, err)
+			tracing.TraceErr(span, repo.Release(storeCtx, reserved.ID))
+		}
+	}
+}
+
+// completeIdempotencyKey stores the response of the key, retrying a failed attempt
+func completeIdempotencyKey(ctx context.Context, repo interfaces.IdempotencyKeyRepository, id string, statusCode int, body []byte) error {
+	var err error
+	for attempt := 1; attempt <= completeAttempts; attempt++ {
+		if err = repo.Complete(ctx, id, statusCode, body); err == nil {
+			return nil
+		}
+		if attempt < completeAttempts {
+			time.Sleep(time.Duration(attempt) * completeBackoff)
+		}
+	}
+	return err
+}
+
+func replayIdempotentResponse(c *gin.Context, existing, request *models.IdempotencyKey) {
+	if existing.Endpoint != request.Endpoint || existing.RequestHash != request.RequestHash {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "idempotency key was already used for a different request"})
+		return
+	}
+	if !existing.Completed() {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "a request with this idempotency key is in progress"})
+		return
+	}
+	// the guarded handlers all respond with json
+	c.Header(IdempotentReplayedHeader, "true")
+	c.Data(existing.StatusCode, "application/json; charset=utf-8", existing.ResponseBody)
+	c.Abort()
+}
+
+// responseRecorder keeps a copy of the response body written to the client
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
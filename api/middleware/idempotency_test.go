@@ -0,0 +1,170 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+// fakeIdempotencyKeyRepository reserves keys atomically like the upsert of the repository, whose
+// statement TestIdempotencyKeyReserveQuery checks
+type fakeIdempotencyKeyRepository struct {
+	interfaces.IdempotencyKeyRepository
+	mu            sync.Mutex
+	keys          map[string]*models.IdempotencyKey
+	completeError error
+}
+
+func newFakeIdempotencyKeyRepository() *fakeIdempotencyKeyRepository {
+	return &fakeIdempotencyKeyRepository{keys: make(map[string]*models.IdempotencyKey)}
+}
+
+func (r *fakeIdempotencyKeyRepository) Reserve(_ context.Context, key *models.IdempotencyKey) (*models.IdempotencyKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := key.Tenant + "/" + key.Key
+	if existing, ok := r.keys[id]; ok && existing.ExpiresAt.After(utils.Now()) {
+		stored := *existing
+		return &stored, nil
+	}
+	key.ID = id
+	stored := *key
+	r.keys[id] = &stored
+	return nil, nil
+}
+
+func (r *fakeIdempotencyKeyRepository) Complete(_ context.Context, id string, statusCode int, body []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.completeError != nil {
+		return r.completeError
+	}
+	r.keys[id].StatusCode = statusCode
+	r.keys[id].ResponseBody = body
+	return nil
+}
+
+func (r *fakeIdempotencyKeyRepository) Release(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.keys, id)
+	return nil
+}
+
+func newIdempotentRouter(repo interfaces.IdempotencyKeyRepository, handler gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(gin.Recovery(), TenantValidationMiddleware(), CustomContextMiddleware())
+	r.POST("/domains/purchase", IdempotencyMiddleware(repo, time.Hour), handler)
+	return r
+}
+
+func purchase(r http.Handler, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/domains/purchase", strings.NewReader(body))
+	req.Header.Set("X-Tenant", "acme")
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyMiddlewareConcurrentDuplicates(t *testing.T) {
+	var executions atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	r := newIdempotentRouter(newFakeIdempotencyKeyRepository(), func(c *gin.Context) {
+		executions.Add(1)
+		close(started)
+		<-release
+		c.JSON(http.StatusCreated, gin.H{"domain": "acme.com"})
+	})
+
+	const requests = 10
+	responses := make(chan *httptest.ResponseRecorder, requests)
+	for i := 0; i < requests; i++ {
+		go func() {
+			responses <- purchase(r, "purchase-1", `{"domain":"acme.com"}`)
+		}()
+	}
+
+	// the request that won the key is held in the handler, all the others are turned away
+	<-started
+	for i := 0; i < requests-1; i++ {
+		w := <-responses
+		assert.Equal(t, http.StatusConflict, w.Code)
+	}
+	close(release)
+	first := <-responses
+	require.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get(IdempotentReplayedHeader))
+
+	replay := purchase(r, "purchase-1", `{"domain":"acme.com"}`)
+	assert.Equal(t, http.StatusCreated, replay.Code)
+	assert.Equal(t, first.Body.String(), replay.Body.String())
+	assert.Equal(t, "true", replay.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, int32(1), executions.Load())
+}
+
+func TestIdempotencyMiddlewareKeyReuse(t *testing.T) {
+	var executions atomic.Int32
+	r := newIdempotentRouter(newFakeIdempotencyKeyRepository(), func(c *gin.Context) {
+		executions.Add(1)
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+
+	assert.Equal(t, http.StatusOK, purchase(r, "purchase-1", `{"domain":"acme.com"}`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, purchase(r, "purchase-1", `{"domain":"other.com"}`).Code)
+	assert.Equal(t, http.StatusOK, purchase(r, "purchase-2", `{"domain":"other.com"}`).Code)
+
+	// without a key every request runs
+	assert.Equal(t, http.StatusOK, purchase(r, "", `{"domain":"acme.com"}`).Code)
+	assert.Equal(t, http.StatusOK, purchase(r, "", `{"domain":"acme.com"}`).Code)
+	assert.Equal(t, int32(4), executions.Load())
+}
+
+func TestIdempotencyMiddlewareReleasesKeyOnPanic(t *testing.T) {
+	var executions atomic.Int32
+	r := newIdempotentRouter(newFakeIdempotencyKeyRepository(), func(c *gin.Context) {
+		if executions.Add(1) == 1 {
+			panic("registrar client crashed")
+		}
+		c.JSON(http.StatusCreated, gin.H{"domain": "acme.com"})
+	})
+
+	assert.Equal(t, http.StatusInternalServerError, purchase(r, "purchase-1", `{"domain":"acme.com"}`).Code)
+	assert.Equal(t, http.StatusCreated, purchase(r, "purchase-1", `{"domain":"acme.com"}`).Code)
+	assert.Equal(t, int32(2), executions.Load())
+}
+
+func TestIdempotencyMiddlewareReleasesKeyWhenResponseNotStored(t *testing.T) {
+	repo := newFakeIdempotencyKeyRepository()
+	repo.completeError = errors.New("connection reset")
+	var executions atomic.Int32
+	r := newIdempotentRouter(repo, func(c *gin.Context) {
+		executions.Add(1)
+		c.JSON(http.StatusCreated, gin.H{"domain": "acme.com"})
+	})
+
+	assert.Equal(t, http.StatusCreated, purchase(r, "purchase-1", `{"domain":"acme.com"}`).Code)
+	assert.Empty(t, repo.keys)
+
+	// the retry runs again rather than waiting for the key to expire
+	repo.completeError = nil
+	assert.Equal(t, http.StatusCreated, purchase(r, "purchase-1", `{"domain":"acme.com"}`).Code)
+	assert.Equal(t, int32(2), executions.Load())
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/opentracing/opentracing-go"
+
+	"github.com/customeros/mailstack/interfaces"
+	mailstack_errors "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/services"
+	"github.com/customeros/mailstack/services/events"
+)
+
+type AdminHandler struct {
+	events       *events.EventsService
+	emailService interfaces.EmailService
+}
+
+func NewAdminHandler(s *services.Services) *AdminHandler {
+	return &AdminHandler{
+		events:       s.EventsService,
+		emailService: s.EmailService,
+	}
+}
+
+type MergeThreadsRequest struct {
+	SourceThreadIDs []string `json:"sourceThreadIds" binding:"required,min=1"`
+}
+
+type SplitThreadRequest struct {
+	EmailIDs []string `json:"emailIds" binding:"required,min=1"`
+}
+
+// ReplayDLQ re-publishes the messages of a dead letter queue to their original destination.
+// Query params: limit (default all) and dryRun to only count the pending messages.
+func (h *AdminHandler) ReplayDLQ() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "AdminHandler.ReplayDLQ")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		limit := 0
+		if value := c.Query("limit"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a non-negative integer"})
+				return
+			}
+			limit = parsed
+		}
+		dryRun := c.Query("dryRun") == "true"
+
+		result, err := h.events.Publisher.ReplayDLQ(ctx, c.Param("queue"), limit, dryRun)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "result": result})
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// MergeThreads moves the emails of the source threads into the thread of the path and deletes
+// the sources, correcting conversations automatic threading split
+func (h *AdminHandler) MergeThreads() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "AdminHandler.MergeThreads")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		var req MergeThreadsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		thread, err := h.emailService.MergeThreads(ctx, req.SourceThreadIDs, c.Param("id"))
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(threadRegroupErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, thread)
+	}
+}
+
+// SplitThread moves the given emails of the thread of the path into a new thread, correcting
+// conversations automatic threading merged, and returns the new thread
+func (h *AdminHandler) SplitThread() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "AdminHandler.SplitThread")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		var req SplitThreadRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		thread, err := h.emailService.SplitThread(ctx, c.Param("id"), req.EmailIDs)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(threadRegroupErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, thread)
+	}
+}
+
+// RecomputeThreadMetadata rebuilds the metadata of the thread of the path from its emails and
+// returns the fields that were corrected
+func (h *AdminHandler) RecomputeThreadMetadata() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "AdminHandler.RecomputeThreadMetadata")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		correction, err := h.emailService.RecomputeThreadMetadata(ctx, c.Param("id"))
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(threadRegroupErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, correction)
+	}
+}
+
+func threadRegroupErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, mailstack_errors.ErrThreadNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, mailstack_errors.ErrThreadMailboxMismatch),
+		errors.Is(err, mailstack_errors.ErrEmailNotInThread),
+		errors.Is(err, mailstack_errors.ErrInvalidThreadMerge),
+		errors.Is(err, mailstack_errors.ErrInvalidThreadSplit):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
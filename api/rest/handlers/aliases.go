@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+
+	"github.com/customeros/mailstack/internal/enum"
+	er "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+type SetupAliasRequest struct {
+	Alias     string `json:"alias"`
+	ForwardTo string `json:"forwardTo"`
+}
+
+type SetCatchAllRequest struct {
+	Destination string `json:"destination"` // empty turns the catch-all off
+}
+
+type AliasRecord struct {
+	Alias     string `json:"alias"`
+	ForwardTo string `json:"forwardTo"`
+	CatchAll  bool   `json:"catchAll"`
+}
+
+type AliasesResponse struct {
+	Aliases []AliasRecord `json:"aliases"`
+}
+
+// GetAliases lists the aliases and the catch-all configured for a domain
+func (h *DomainHandler) GetAliases() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "DomainHandler.GetAliases")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		tenant := utils.GetTenantFromContext(ctx)
+		domain := c.Param("domain")
+
+		domainBelongsToTenant, err := h.repos.DomainRepository.CheckDomainOwnership(ctx, tenant, domain)
+		if err != nil {
+			tracing.TraceErr(span, errors.Wrap(err, "Error checking domain"))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking domain"})
+			return
+		}
+		if !domainBelongsToTenant {
+			c.JSON(http.StatusNotFound, gin.H{"error": er.ErrDomainNotFound.Error()})
+			return
+		}
+
+		aliases, err := h.repos.MailboxAliasRepository.ListByDomain(ctx, tenant, domain)
+		if err != nil {
+			tracing.TraceErr(span, errors.Wrap(err, "Error getting aliases"))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting aliases"})
+			return
+		}
+
+		response := AliasesResponse{Aliases: make([]AliasRecord, 0, len(aliases))}
+		for _, alias := range aliases {
+			response.Aliases = append(response.Aliases, AliasRecord{
+				Alias:     alias.Alias,
+				ForwardTo: alias.ForwardTo,
+				CatchAll:  alias.CatchAll,
+			})
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// SetupAlias creates or updates an alias of the domain
+func (h *DomainHandler) SetupAlias() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "DomainHandler.SetupAlias")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		tenant := utils.GetTenantFromContext(ctx)
+		domain := c.Param("domain")
+
+		var req SetupAliasRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !strings.EqualFold(utils.ExtractDomainFromEmail(req.Alias), domain) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "alias must belong to the domain"})
+			return
+		}
+
+		err := h.svc.OpenSrsService.SetupAlias(ctx, tenant, req.Alias, req.ForwardTo)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			respondAliasError(c, err, "Error setting up alias")
+			return
+		}
+		h.audit(ctx, enum.AuditAliasCreated, domain, nil, map[string]interface{}{"alias": req.Alias, "forwardTo": req.ForwardTo})
+
+		c.JSON(http.StatusOK, AliasRecord{Alias: req.Alias, ForwardTo: req.ForwardTo})
+	}
+}
+
+// RemoveAlias deletes an alias of the domain
+func (h *DomainHandler) RemoveAlias() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "DomainHandler.RemoveAlias")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		tenant := utils.GetTenantFromContext(ctx)
+		domain := c.Param("domain")
+		alias := c.Param("alias")
+
+		if !strings.EqualFold(utils.ExtractDomainFromEmail(alias), domain) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "alias not found"})
+			return
+		}
+
+		err := h.svc.OpenSrsService.RemoveAlias(ctx, tenant, alias)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			if errors.Is(err, er.ErrInvalidAlias) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "alias not found"})
+				return
+			}
+			respondAliasError(c, err, "Error removing alias")
+			return
+		}
+		h.audit(ctx, enum.AuditAliasRemoved, domain, map[string]interface{}{"alias": alias}, nil)
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// SetCatchAll sets or clears the catch-all destination of the domain
+func (h *DomainHandler) SetCatchAll() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "DomainHandler.SetCatchAll")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		tenant := utils.GetTenantFromContext(ctx)
+		domain := c.Param("domain")
+
+		var req SetCatchAllRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		err := h.svc.OpenSrsService.SetDomainCatchAll(ctx, tenant, domain, req.Destination)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			respondAliasError(c, err, "Error setting catch-all")
+			return
+		}
+		h.audit(ctx, enum.AuditCatchAllChanged, domain, nil, map[string]interface{}{"destination": req.Destination})
+
+		c.JSON(http.StatusOK, gin.H{"domain": domain, "destination": req.Destination})
+	}
+}
+
+func respondAliasError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, er.ErrDomainNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": er.ErrDomainNotFound.Error()})
+	case errors.Is(err, er.ErrInvalidAlias):
+		c.JSON(http.StatusBadRequest, gin.H{"error": er.ErrInvalidAlias.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
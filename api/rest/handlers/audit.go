@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/opentracing/opentracing-go"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/repository"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+type AuditLogHandler struct {
+	repos *repository.Repositories
+}
+
+func NewAuditLogHandler(repos *repository.Repositories) *AuditLogHandler {
+	return &AuditLogHandler{
+		repos: repos,
+	}
+}
+
+type AuditLogResponse struct {
+	Entries    []*models.AuditLog `json:"entries"`
+	NextCursor string             `json:"nextCursor,omitempty"`
+}
+
+// ListAuditLog returns the audit log of the tenant, newest first. The optional from and to
+// query parameters (RFC3339) limit it to entries created in [from, to).
+func (h *AuditLogHandler) ListAuditLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "AuditLogHandler.ListAuditLog")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		page, err := parsePagination(c)
+		if err == nil && page.offset > 0 {
+			// entries keep being added on top, only cursors page through them reliably
+			err = errInvalidQueryParam("offset")
+		}
+		if err == nil && page.after != nil && !page.after.Descending {
+			err = errInvalidQueryParam("cursor")
+		}
+		filter := interfaces.AuditLogFilter{
+			Tenant: utils.GetTenantFromContext(ctx),
+			After:  page.after,
+		}
+		if err == nil {
+			filter.From, err = parseTimeQueryParam(c, "from")
+		}
+		if err == nil {
+			filter.To, err = parseTimeQueryParam(c, "to")
+		}
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		entries, err := h.repos.AuditLogRepository.List(ctx, filter, page.limit+1)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get audit log"})
+			return
+		}
+
+		response := AuditLogResponse{Entries: entries}
+		if len(entries) > page.limit {
+			response.Entries = entries[:page.limit]
+			last := response.Entries[page.limit-1]
+			response.NextCursor = utils.EncodeCursor(utils.Cursor{At: &last.CreatedAt, ID: last.ID, Descending: true})
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// parseTimeQueryParam reads an optional RFC3339 time from the query, nil when not given
+func parseTimeQueryParam(c *gin.Context, name string) (*time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, errInvalidQueryParam(name)
+	}
+	return &t, nil
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/opentracing/opentracing-go"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/services"
+)
+
+// maxDMARCUploadSize caps uploaded aggregate reports, compressed reports are a few KB
+const maxDMARCUploadSize = 10 << 20
+
+type DMARCHandler struct {
+	domainService interfaces.DomainService
+}
+
+func NewDMARCHandler(s *services.Services) *DMARCHandler {
+	return &DMARCHandler{
+		domainService: s.DomainService,
+	}
+}
+
+// UploadAggregateReport ingests a DMARC aggregate report posted as the raw request body,
+// either plain XML or a gzip/zip archive as received from the reporting ISP
+func (h *DMARCHandler) UploadAggregateReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "DMARCHandler.UploadAggregateReport")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxDMARCUploadSize+1))
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+			return
+		}
+		if len(data) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "empty report"})
+			return
+		}
+		if len(data) > maxDMARCUploadSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "report too large"})
+			return
+		}
+
+		stored, err := h.domainService.ProcessDMARCReport(ctx, data, c.Query("provider"))
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"stored": stored})
+	}
+}
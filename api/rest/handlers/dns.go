@@ -6,6 +6,8 @@ import (
 	"strings"
 
 	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/enum"
+	er "github.com/customeros/mailstack/internal/errors"
 	"github.com/customeros/mailstack/internal/tracing"
 	"github.com/customeros/mailstack/internal/utils"
 	"github.com/customeros/mailstack/services"
@@ -17,12 +19,14 @@ import (
 type DNSHandler struct {
 	domainService     interfaces.DomainService
 	cloudflareService interfaces.CloudflareService
+	auditService      interfaces.AuditService
 }
 
 func NewDNSHandler(s *services.Services) *DNSHandler {
 	return &DNSHandler{
 		domainService:     s.DomainService,
 		cloudflareService: s.CloudflareService,
+		auditService:      s.AuditService,
 	}
 }
 
@@ -92,6 +96,12 @@ func (h *DNSHandler) AddDNSRecord() gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		h.auditService.Record(ctx, interfaces.AuditEntry{
+			Action:     enum.AuditDNSRecordAdded,
+			TargetType: enum.AuditTargetDomain,
+			TargetID:   domain,
+			After:      map[string]interface{}{"type": record.Type, "name": record.Name, "content": record.Content},
+		})
 
 		c.JSON(http.StatusOK, DNSRecordResponse{
 			Record: record,
@@ -160,6 +170,12 @@ func (h *DNSHandler) DeleteDNSRecord() gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		h.auditService.Record(ctx, interfaces.AuditEntry{
+			Action:     enum.AuditDNSRecordDeleted,
+			TargetType: enum.AuditTargetDomain,
+			TargetID:   domain,
+			Before:     map[string]interface{}{"id": dnsRecordId},
+		})
 
 		c.JSON(http.StatusOK, gin.H{"message": "DNS record deleted"})
 	}
@@ -218,3 +234,44 @@ func (h *DNSHandler) GetDNSRecords() gin.HandlerFunc {
 
 	}
 }
+
+type DNSVerificationResponse struct {
+	Domain   string                             `json:"domain"`
+	Verified bool                               `json:"verified"`
+	Records  []interfaces.DNSRecordVerification `json:"records"`
+}
+
+// VerifyDNS reports whether the MX, SPF, DKIM and DMARC records of a domain resolve to the configured values
+func (h *DNSHandler) VerifyDNS() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "DNSHandler.VerifyDNS")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		domain := strings.ToLower(strings.TrimSpace(c.Param("domain")))
+
+		records, err := h.domainService.VerifyDNS(ctx, domain)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			if errors.Is(err, er.ErrDomainNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "domain not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		verified := true
+		for _, record := range records {
+			if record.Status != enum.DNSRecordPresent {
+				verified = false
+			}
+		}
+
+		c.JSON(http.StatusOK, DNSVerificationResponse{
+			Domain:   domain,
+			Verified: verified,
+			Records:  records,
+		})
+	}
+}
@@ -9,8 +9,11 @@ import (
 	"github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
 
+	"github.com/customeros/mailstack/interfaces"
 	"github.com/customeros/mailstack/internal/config"
+	"github.com/customeros/mailstack/internal/enum"
 	er "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/models"
 	"github.com/customeros/mailstack/internal/repository"
 	"github.com/customeros/mailstack/internal/tracing"
 	"github.com/customeros/mailstack/internal/utils"
@@ -31,6 +34,14 @@ type ConfigureDomainRequest struct {
 	Website string `json:"website"`
 }
 
+type SetAutoRenewRequest struct {
+	AutoRenew bool `json:"autoRenew"`
+}
+
+type TenantCurrencyRequest struct {
+	Currency string `json:"currency" binding:"required,len=3,alpha"`
+}
+
 type DomainResponse struct {
 	Domain DomainRecord `json:"domain"`
 }
@@ -51,6 +62,14 @@ type DomainAvailabilityResponse struct {
 	IsPremium   bool `json:"isPremium"`
 }
 
+type CheckDomainsAvailabilityRequest struct {
+	Domains []string `json:"domains"`
+}
+
+type DomainsAvailabilityResponse struct {
+	Domains []interfaces.NamecheapDomainAvailability `json:"domains"`
+}
+
 type DomainHandler struct {
 	repos *repository.Repositories
 	cfg   *config.Config
@@ -81,8 +100,6 @@ func (h *DomainHandler) RegisterNewDomain() gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		domain := req.Domain
-		website := req.Website
 
 		// Check for missing domain
 		if req.Domain == "" {
@@ -97,9 +114,30 @@ func (h *DomainHandler) RegisterNewDomain() gin.HandlerFunc {
 			return
 		}
 
+		// the normalized domain is used from here on, for availability, purchase and storage
+		domain, err := utils.NormalizeDomain(req.Domain)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		website, err := utils.ValidateWebsiteURL(req.Website)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		span.LogKV("domain", domain, "website", website)
+
 		// check if domain tld is supported
-		// Extract the TLD from the domain (e.g., "com" from "example.com")
-		tld := strings.Split(domain, ".")[1]
+		// Extract the TLD from the domain (e.g., "co.uk" from "example.co.uk")
+		_, tld, err := utils.SplitDomain(domain)
+		if err != nil {
+			message := "Invalid domain"
+			tracing.TraceErr(span, errors.Wrap(err, message))
+			c.JSON(http.StatusBadRequest, gin.H{"error": message})
+			return
+		}
 		if !utils.IsStringInSlice(tld, h.cfg.DomainConfig.SupportedTlds) {
 			message := "Domain TLD not supported"
 			tracing.TraceErr(span, errors.New(message))
@@ -126,20 +164,20 @@ func (h *DomainHandler) RegisterNewDomain() gin.HandlerFunc {
 			return
 		}
 		// check if domain price is exceeded
-		domainPrice, err := h.svc.NamecheapService.GetDomainPrice(ctx, domain)
+		priceExceeded, err := h.svc.NamecheapService.PriceExceedsMax(ctx, domain)
 		if err != nil {
 			tracing.TraceErr(span, err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		if domainPrice > h.cfg.NamecheapConfig.MaxPrice {
+		if priceExceeded {
 			message := "Domain price is exceeded"
 			tracing.TraceErr(span, errors.New(message))
 			c.JSON(http.StatusNotAcceptable, gin.H{"error": message})
 			return
 		}
 		// register domain
-		err = h.svc.NamecheapService.PurchaseDomain(ctx, tenant, domain)
+		err = h.purchaseDomain(ctx, tenant, domain)
 		if err != nil {
 			tracing.TraceErr(span, err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -169,6 +207,44 @@ func (h *DomainHandler) RegisterNewDomain() gin.HandlerFunc {
 	}
 }
 
+// purchaseDomain buys the domain and, when the registration could not be stored, reconciles
+// it right away. If that fails too the periodic reconciliation picks it up.
+func (h *DomainHandler) purchaseDomain(ctx context.Context, tenant, domain string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "DomainHandler.purchaseDomain")
+	defer span.Finish()
+
+	err := h.svc.NamecheapService.PurchaseDomain(ctx, tenant, domain)
+	// the domain is bought even when storing it failed
+	if err == nil || errors.Is(err, er.ErrDomainNotStored) {
+		h.audit(ctx, enum.AuditDomainPurchased, domain, nil, nil)
+	}
+	if !errors.Is(err, er.ErrDomainNotStored) {
+		return err
+	}
+	tracing.TraceErr(span, err)
+
+	if _, reconcileErr := h.svc.DomainService.ReconcilePurchasedDomains(ctx); reconcileErr != nil {
+		tracing.TraceErr(span, reconcileErr)
+		return err
+	}
+	stored, checkErr := h.repos.DomainRepository.CheckDomainOwnership(ctx, tenant, domain)
+	if checkErr != nil || !stored {
+		return err
+	}
+	return nil
+}
+
+// audit records a mutation of a domain of the tenant
+func (h *DomainHandler) audit(ctx context.Context, action enum.AuditAction, domain string, before, after map[string]interface{}) {
+	h.svc.AuditService.Record(ctx, interfaces.AuditEntry{
+		Action:     action,
+		TargetType: enum.AuditTargetDomain,
+		TargetID:   domain,
+		Before:     before,
+		After:      after,
+	})
+}
+
 func (h *DomainHandler) configureDomain(ctx context.Context, domain, website string) (DomainRecord, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "DomainHandler.configureDomain")
 	defer span.Finish()
@@ -195,6 +271,7 @@ func (h *DomainHandler) configureDomain(ctx context.Context, domain, website str
 		tracing.TraceErr(span, errors.Wrap(err, "Error configuring domain"))
 		return domainResponse, er.ErrDomainConfigurationFailed
 	}
+	h.audit(ctx, enum.AuditDomainConfigured, domain, nil, map[string]interface{}{"website": website})
 
 	// get domain details
 	domainInfo, err := h.svc.NamecheapService.GetDomainInfo(ctx, tenant, domain)
@@ -217,6 +294,7 @@ func (h *DomainHandler) GetDomains() gin.HandlerFunc {
 		tracing.SetDefaultRestSpanTags(ctx, span)
 
 		tenant := utils.GetTenantFromContext(ctx)
+		forceRefresh := c.Query("refresh") == "true"
 
 		// get all active domains from postgres
 		activeDomainRecords, err := h.repos.DomainRepository.GetActiveDomains(ctx, tenant)
@@ -231,7 +309,8 @@ func (h *DomainHandler) GetDomains() gin.HandlerFunc {
 		}
 
 		for _, domainRecord := range activeDomainRecords {
-			domain, err := h.svc.NamecheapService.GetDomainInfo(ctx, tenant, domainRecord.Domain)
+			// served from cache, stale entries are refreshed in the background
+			domain, err := h.svc.NamecheapService.GetCachedDomainInfo(ctx, tenant, domainRecord.Domain, forceRefresh)
 			if err != nil {
 				message := "Unable to retreive domain info"
 				tracing.TraceErr(span, err)
@@ -250,6 +329,51 @@ func (h *DomainHandler) GetDomains() gin.HandlerFunc {
 	}
 }
 
+// SetAutoRenew turns automatic renewal on or off for a domain of the tenant
+func (h *DomainHandler) SetAutoRenew() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "DomainHandler.SetAutoRenew")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		tenant := utils.GetTenantFromContext(ctx)
+		domain := c.Param("domain")
+
+		var req SetAutoRenewRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		domainBelongsToTenant, err := h.repos.DomainRepository.CheckDomainOwnership(ctx, tenant, domain)
+		if err != nil {
+			tracing.TraceErr(span, errors.Wrap(err, "Error checking domain"))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking domain"})
+			return
+		}
+		if !domainBelongsToTenant {
+			c.JSON(http.StatusNotFound, gin.H{"error": er.ErrDomainNotFound.Error()})
+			return
+		}
+		// only for the audit log, the update does not depend on it
+		var before map[string]interface{}
+		if current, err := h.repos.DomainRepository.GetDomain(ctx, tenant, domain); err == nil && current != nil {
+			before = map[string]interface{}{"autoRenew": current.AutoRenew}
+		}
+
+		err = h.repos.DomainRepository.SetAutoRenew(ctx, tenant, domain, req.AutoRenew)
+		if err != nil {
+			tracing.TraceErr(span, errors.Wrap(err, "Error updating auto renew"))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating auto renew"})
+			return
+		}
+		h.audit(ctx, enum.AuditDomainAutoRenewChanged, domain, before, map[string]interface{}{"autoRenew": req.AutoRenew})
+
+		c.JSON(http.StatusOK, gin.H{"domain": domain, "autoRenew": req.AutoRenew})
+	}
+}
+
 func (h *DomainHandler) GetRecommendations() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "DomainHandler.GetRecommendations")
@@ -268,6 +392,17 @@ func (h *DomainHandler) GetRecommendations() gin.HandlerFunc {
 		// get domain recommendations
 		recommendations := h.svc.MailboxServiceOld.RecommendOutboundDomains(ctx, baseName, 500)
 
+		// the suggestions only pass DNS and whois checks, registrable ones are confirmed with Namecheap
+		if c.Query("onlyAvailable") == "true" {
+			available, err := h.availableDomains(ctx, recommendations)
+			if err != nil {
+				tracing.TraceErr(span, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			recommendations = available
+		}
+
 		var response struct {
 			Recommendations []string `json:"recommendations"`
 		}
@@ -350,6 +485,136 @@ func (h *DomainHandler) CheckAvailability() gin.HandlerFunc {
 	}
 }
 
+// GetDomainPrice quotes the registration of a domain, in the currency query param when set,
+// else in the tenant's currency
+func (h *DomainHandler) GetDomainPrice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "DomainHandler.GetDomainPrice")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		domain := c.Param("domain")
+		if domain == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "domain parameter is required"})
+			return
+		}
+
+		price, err := h.svc.NamecheapService.GetDomainPrice(ctx, utils.GetTenantFromContext(ctx), domain, c.Query("currency"))
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, price)
+	}
+}
+
+// GetTenantCurrency returns the currency domain prices are quoted in for the tenant
+func (h *DomainHandler) GetTenantCurrency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "DomainHandler.GetTenantCurrency")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		currency, err := h.repos.TenantCurrencyRepository.GetByTenant(ctx, utils.GetTenantFromContext(ctx))
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get tenant currency"})
+			return
+		}
+		if currency == nil {
+			c.JSON(http.StatusOK, gin.H{"currency": strings.ToUpper(h.cfg.NamecheapConfig.PriceCurrency)})
+			return
+		}
+
+		c.JSON(http.StatusOK, currency)
+	}
+}
+
+// SetTenantCurrency sets the currency domain prices are quoted in for the tenant
+func (h *DomainHandler) SetTenantCurrency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "DomainHandler.SetTenantCurrency")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		var req TenantCurrencyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		span.LogKV("currency", req.Currency)
+
+		currency := &models.TenantCurrency{
+			Tenant:   utils.GetTenantFromContext(ctx),
+			Currency: strings.ToUpper(req.Currency),
+		}
+		if err := h.repos.TenantCurrencyRepository.Save(ctx, currency); err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save tenant currency"})
+			return
+		}
+
+		c.JSON(http.StatusOK, currency)
+	}
+}
+
+// CheckDomainsAvailability checks many domains in as few Namecheap calls as possible
+func (h *DomainHandler) CheckDomainsAvailability() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "DomainHandler.CheckDomainsAvailability")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		var req CheckDomainsAvailabilityRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if len(req.Domains) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "domains are required"})
+			return
+		}
+
+		availability, err := h.svc.NamecheapService.CheckDomainsAvailability(ctx, req.Domains)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, DomainsAvailabilityResponse{Domains: availability})
+	}
+}
+
+// availableDomains keeps the domains that can be registered at the regular price, in their order
+func (h *DomainHandler) availableDomains(ctx context.Context, domains []string) ([]string, error) {
+	if len(domains) == 0 {
+		return domains, nil
+	}
+
+	availability, err := h.svc.NamecheapService.CheckDomainsAvailability(ctx, domains)
+	if err != nil {
+		return nil, err
+	}
+
+	registrable := make(map[string]bool, len(availability))
+	for _, domain := range availability {
+		registrable[domain.Domain] = domain.Available && !domain.Premium
+	}
+
+	available := []string{}
+	for _, domain := range domains {
+		if registrable[strings.ToLower(domain)] {
+			available = append(available, domain)
+		}
+	}
+	return available, nil
+}
+
 func (h *DomainHandler) PurchaseDomain() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "DomainHandler.PurchaseDomain")
@@ -375,7 +640,7 @@ func (h *DomainHandler) PurchaseDomain() gin.HandlerFunc {
 		}
 
 		// purchase domain
-		err := h.svc.NamecheapService.PurchaseDomain(ctx, tenant, req.Domain)
+		err := h.purchaseDomain(ctx, tenant, req.Domain)
 		if err != nil {
 			tracing.TraceErr(span, err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
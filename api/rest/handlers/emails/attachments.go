@@ -0,0 +1,98 @@
+package emails
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/opentracing/opentracing-go"
+	tracingLog "github.com/opentracing/opentracing-go/log"
+
+	"github.com/customeros/mailstack/internal/enum"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+// DownloadAttachment streams an attachment of an email owned by the requesting tenant.
+// Range requests are handled by http.ServeContent.
+func (h *EmailsHandler) DownloadAttachment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "EmailsHandler.DownloadAttachment")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		emailID := c.Param("id")
+		attachmentID := c.Param("attachmentId")
+		span.LogFields(tracingLog.String("emailId", emailID), tracingLog.String("attachmentId", attachmentID))
+
+		email, err := h.repositories.EmailRepository.GetByID(ctx, emailID)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get email"})
+			return
+		}
+		if email == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "email not found"})
+			return
+		}
+
+		// emails of other tenants are reported as not found
+		mailbox, err := h.repositories.MailboxRepository.GetMailbox(ctx, email.MailboxID)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get mailbox"})
+			return
+		}
+		if mailbox == nil || mailbox.Tenant != utils.GetTenantFromContext(ctx) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "email not found"})
+			return
+		}
+
+		attachment, err := h.repositories.EmailAttachmentRepository.GetByID(ctx, attachmentID)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get attachment"})
+			return
+		}
+		if attachment == nil || !slices.Contains(attachment.Emails, email.ID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "attachment not found"})
+			return
+		}
+		if attachment.ScanStatus == enum.AttachmentInfected {
+			c.JSON(http.StatusForbidden, gin.H{"error": "attachment is infected"})
+			return
+		}
+		if attachment.StorageKey == "" {
+			// only metadata was stored, e.g. attachments over the inbound size cap
+			c.JSON(http.StatusNotFound, gin.H{"error": "attachment content not available"})
+			return
+		}
+
+		data, err := h.repositories.EmailAttachmentRepository.DownloadAttachment(ctx, attachment.ID)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to download attachment"})
+			return
+		}
+
+		contentType := attachment.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		filename := attachment.Filename
+		if filename == "" {
+			filename = attachment.ID
+		}
+
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+		c.Header("Content-Length", strconv.Itoa(len(data)))
+		c.Header("X-Content-Type-Options", "nosniff")
+
+		// ServeContent overrides Content-Length and sets Content-Range for partial requests
+		http.ServeContent(c.Writer, c.Request, filename, attachment.UpdatedAt, bytes.NewReader(data))
+	}
+}
@@ -0,0 +1,150 @@
+package emails
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/enum"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/repository"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+type fakeEmailRepository struct {
+	interfaces.EmailRepository
+	emails map[string]*models.Email
+}
+
+func (r *fakeEmailRepository) GetByID(_ context.Context, id string) (*models.Email, error) {
+	return r.emails[id], nil
+}
+
+type fakeMailboxRepository struct {
+	interfaces.MailboxRepository
+	mailboxes map[string]*models.Mailbox
+}
+
+func (r *fakeMailboxRepository) GetMailbox(_ context.Context, id string) (*models.Mailbox, error) {
+	return r.mailboxes[id], nil
+}
+
+type fakeAttachmentRepository struct {
+	interfaces.EmailAttachmentRepository
+	attachments map[string]*models.EmailAttachment
+	content     map[string][]byte
+}
+
+func (r *fakeAttachmentRepository) GetByID(_ context.Context, id string) (*models.EmailAttachment, error) {
+	return r.attachments[id], nil
+}
+
+func (r *fakeAttachmentRepository) DownloadAttachment(_ context.Context, id string) ([]byte, error) {
+	return r.content[id], nil
+}
+
+func newAttachmentRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	updatedAt := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+
+	handler := NewEmailsHandler(&repository.Repositories{
+		EmailRepository: &fakeEmailRepository{emails: map[string]*models.Email{
+			"email_acme":  {ID: "email_acme", MailboxID: "mbox_acme"},
+			"email_other": {ID: "email_other", MailboxID: "mbox_other"},
+		}},
+		MailboxRepository: &fakeMailboxRepository{mailboxes: map[string]*models.Mailbox{
+			"mbox_acme":  {ID: "mbox_acme", Tenant: "acme"},
+			"mbox_other": {ID: "mbox_other", Tenant: "other"},
+		}},
+		EmailAttachmentRepository: &fakeAttachmentRepository{
+			attachments: map[string]*models.EmailAttachment{
+				"att_pdf":      {ID: "att_pdf", Emails: pq.StringArray{"email_acme"}, Filename: "offer.pdf", ContentType: "application/pdf", StorageKey: "att/offer.pdf", UpdatedAt: updatedAt},
+				"att_other":    {ID: "att_other", Emails: pq.StringArray{"email_other"}, Filename: "secret.pdf", StorageKey: "att/secret.pdf", UpdatedAt: updatedAt},
+				"att_infected": {ID: "att_infected", Emails: pq.StringArray{"email_acme"}, Filename: "invoice.exe", StorageKey: "att/invoice.exe", ScanStatus: enum.AttachmentInfected},
+				"att_metadata": {ID: "att_metadata", Emails: pq.StringArray{"email_acme"}, Filename: "huge.zip"},
+			},
+			content: map[string][]byte{
+				"att_pdf":   []byte("%PDF-1.7 offer"),
+				"att_other": []byte("%PDF-1.7 secret"),
+			},
+		},
+	}, nil)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(utils.SetTenantInContext(c.Request.Context(), "acme"))
+	})
+	router.GET("/emails/:id/attachments/:attachmentId", handler.DownloadAttachment())
+	return router
+}
+
+func TestDownloadAttachment(t *testing.T) {
+	router := newAttachmentRouter()
+	get := func(path string, header map[string]string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, path, nil)
+		for k, v := range header {
+			request.Header.Set(k, v)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	t.Run("full download", func(t *testing.T) {
+		response := get("/emails/email_acme/attachments/att_pdf", nil)
+		assert.Equal(t, http.StatusOK, response.Code)
+		assert.Equal(t, "%PDF-1.7 offer", response.Body.String())
+		assert.Equal(t, "application/pdf", response.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename=offer.pdf`, response.Header().Get("Content-Disposition"))
+		assert.Equal(t, "14", response.Header().Get("Content-Length"))
+		assert.Equal(t, "nosniff", response.Header().Get("X-Content-Type-Options"))
+	})
+
+	t.Run("range request", func(t *testing.T) {
+		response := get("/emails/email_acme/attachments/att_pdf", map[string]string{"Range": "bytes=9-13"})
+		assert.Equal(t, http.StatusPartialContent, response.Code)
+		assert.Equal(t, "offer", response.Body.String())
+		assert.Equal(t, "bytes 9-13/14", response.Header().Get("Content-Range"))
+		assert.Equal(t, "5", response.Header().Get("Content-Length"))
+	})
+
+	t.Run("unsatisfiable range", func(t *testing.T) {
+		response := get("/emails/email_acme/attachments/att_pdf", map[string]string{"Range": "bytes=100-200"})
+		assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, response.Code)
+	})
+
+	t.Run("not modified", func(t *testing.T) {
+		response := get("/emails/email_acme/attachments/att_pdf", map[string]string{"If-Modified-Since": "Thu, 01 Oct 2026 09:00:00 GMT"})
+		assert.Equal(t, http.StatusNotModified, response.Code)
+	})
+
+	notFound := []struct {
+		name string
+		path string
+	}{
+		{"unknown email", "/emails/email_missing/attachments/att_pdf"},
+		{"email of another tenant", "/emails/email_other/attachments/att_other"},
+		{"unknown attachment", "/emails/email_acme/attachments/att_missing"},
+		{"attachment of another email", "/emails/email_acme/attachments/att_other"},
+		{"content not stored", "/emails/email_acme/attachments/att_metadata"},
+	}
+	for _, tt := range notFound {
+		t.Run(tt.name, func(t *testing.T) {
+			response := get(tt.path, nil)
+			assert.Equal(t, http.StatusNotFound, response.Code)
+			assert.NotContains(t, response.Body.String(), "PDF")
+		})
+	}
+
+	t.Run("infected attachment", func(t *testing.T) {
+		response := get("/emails/email_acme/attachments/att_infected", nil)
+		assert.Equal(t, http.StatusForbidden, response.Code)
+	})
+}
@@ -1,13 +1,18 @@
 package emails
 
-import "github.com/customeros/mailstack/internal/repository"
+import (
+	"github.com/customeros/mailstack/internal/repository"
+	"github.com/customeros/mailstack/services"
+)
 
 type EmailsHandler struct {
 	repositories *repository.Repositories
+	services     *services.Services
 }
 
-func NewEmailsHandler(repos *repository.Repositories) *EmailsHandler {
+func NewEmailsHandler(repos *repository.Repositories, s *services.Services) *EmailsHandler {
 	return &EmailsHandler{
 		repositories: repos,
+		services:     s,
 	}
 }
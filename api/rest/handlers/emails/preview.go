@@ -0,0 +1,49 @@
+package emails
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/opentracing/opentracing-go"
+	tracingLog "github.com/opentracing/opentracing-go/log"
+
+	mailstack_errors "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/services/email"
+)
+
+// Preview returns an outbound email rendered as it would go out: headers, envelope, MIME body
+// structure and the raw message. Nothing is sent or stored.
+func (h *EmailsHandler) Preview() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "EmailsHandler.Preview")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		emailID := c.Param("id")
+		span.LogFields(tracingLog.String("emailId", emailID))
+
+		preview, err := h.services.EmailService.PreviewEmail(ctx, emailID)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(previewErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, preview)
+	}
+}
+
+func previewErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, email.ErrEmailNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, email.ErrEmailNotOutbound):
+		return http.StatusBadRequest
+	case errors.Is(err, mailstack_errors.ErrMessageTooLarge):
+		return http.StatusRequestEntityTooLarge
+	default:
+		return http.StatusInternalServerError
+	}
+}
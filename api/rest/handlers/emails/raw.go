@@ -0,0 +1,50 @@
+package emails
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/opentracing/opentracing-go"
+	tracingLog "github.com/opentracing/opentracing-go/log"
+
+	mailstack_errors "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/services/email"
+)
+
+// Raw returns the original RFC 822 source of an email as message/rfc822, e.g. to forward it as
+// an attachment or to debug its headers
+func (h *EmailsHandler) Raw() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "EmailsHandler.Raw")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		emailID := c.Param("id")
+		span.LogFields(tracingLog.String("emailId", emailID))
+
+		raw, err := h.services.EmailService.GetRawEmail(ctx, emailID)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(rawErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": emailID + ".eml"}))
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.Data(http.StatusOK, "message/rfc822", raw)
+	}
+}
+
+func rawErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, email.ErrEmailNotFound), errors.Is(err, mailstack_errors.ErrMessageNotOnServer):
+		return http.StatusNotFound
+	case errors.Is(err, mailstack_errors.ErrIMAPAuthFailed):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
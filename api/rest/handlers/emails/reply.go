@@ -1,211 +1,116 @@
 package emails
 
-//
-// import (
-// 	"context"
-// 	"fmt"
-// 	"net/http"
-// 	"time"
-//
-// 	"github.com/gin-gonic/gin"
-// 	"github.com/opentracing/opentracing-go"
-// 	"github.com/pkg/errors"
-//
-// 	custom_err "github.com/customeros/mailstack/api/errors"
-// 	"github.com/customeros/mailstack/internal/enum"
-// 	"github.com/customeros/mailstack/internal/models"
-// 	"github.com/customeros/mailstack/internal/tracing"
-// 	"github.com/customeros/mailstack/internal/utils"
-// )
-//
-// type ReplyEmailRequest struct {
-// 	ReplyTo     string        `json:"replyTo"`
-// 	Body        EmailBody     `json:"body"`
-// 	Attachments []Attachments `json:"attachments"`
-// 	ScheduleFor time.Time     `json:"scheduleFor"`
-// }
-//
-// // reply to an existing email thread
-// func (h *EmailsHandler) Reply() gin.HandlerFunc {
-// 	return func(c *gin.Context) {
-// 		ctx, span := tracing.StartHttpServerTracerSpanWithHeader(c.Request.Context(), "EmailsHandler.Reply", c.Request.Header)
-// 		defer span.Finish()
-// 		tracing.TagComponentRest(span)
-// 		tracing.TagTenant(span, utils.GetTenantFromContext(ctx))
-//
-// 		// Parse the request
-// 		var request ReplyEmailRequest
-// 		if err := c.ShouldBindJSON(&request); err != nil {
-// 			h.respondWithError(c, span, http.StatusBadRequest, "Invalid request format", err)
-// 			return
-// 		}
-//
-// 		// validate email exists
-// 		replyToEmailID := c.Param("id")
-// 		if replyToEmailID == "" {
-// 			h.respondWithError(c, span, http.StatusBadRequest, "emailID cannot be empty", nil)
-// 			return
-// 		}
-// 		replyToEmail, err := h.repositories.EmailRepository.GetByID(ctx, replyToEmailID)
-// 		if err != nil {
-// 			h.respondWithError(c, span, http.StatusInternalServerError, "error retrieving email", err)
-// 			return
-// 		}
-// 		if replyToEmail == nil {
-// 			h.respondWithError(c, span, http.StatusNotFound, fmt.Sprintf("cannot find email with id %s", replyToEmailID), nil)
-// 			return
-// 		}
-//
-// 		// Validate request
-// 		errs := h.ValidateReplyRequest(ctx, &request)
-// 		if errs.HasErrors() {
-// 			tracing.TraceErr(span, errs)
-// 			c.JSON(http.StatusBadRequest, errs)
-// 		}
-//
-// 		// Build email container
-// 		emailContainer, err := h.buildReplyEmailContainer(ctx, &request, replyToEmail)
-// 		if err != nil {
-// 			h.respondWithError(c, span, http.StatusInternalServerError, "error retrieving email", err)
-// 			return
-// 		}
-// 		if emailContainer == nil {
-// 			h.respondWithError(c, span, http.StatusNotFound, fmt.Sprintf("cannot find email thread with id %s", replyToEmailID), nil)
-// 			return
-// 		}
-//
-// 		// Send reply
-// 		err = h.sendEmail(ctx, emailContainer)
-// 		if err != nil {
-// 			h.respondWithError(c, span, http.StatusInternalServerError, "Failed to send email", err)
-// 			return
-// 		}
-//
-// 		c.JSON(http.StatusOK, gin.H{
-// 			"messageId": "",
-// 		})
-// 	}
-// }
-//
-// func (h *EmailsHandler) ValidateReplyRequest(ctx context.Context, request *ReplyEmailRequest) *custom_err.MultiErrors {
-// 	span, ctx := opentracing.StartSpanFromContext(ctx, "EmailsHandler.validateRequest")
-// 	defer span.Finish()
-// 	tracing.TagComponentRest(span)
-//
-// 	var errs custom_err.MultiErrors
-// 	if request.Body.HTML == "" && request.Body.Text == "" {
-// 		errs.Add("body", "please provide a valid html or text body (or both)", errors.New("body is empty"))
-// 	}
-// 	return &errs
-// }
-//
-// func (h *EmailsHandler) buildReplyEmailContainer(ctx context.Context, request *ReplyEmailRequest, replyToEmail *models.Email) (*EmailContainer, error) {
-// 	span, ctx := opentracing.StartSpanFromContext(ctx, "EmailsHandler.buildReplyEmailContainer")
-// 	defer span.Finish()
-// 	tracing.TagComponentRest(span)
-//
-// 	// Create container
-// 	container := &EmailContainer{}
-//
-// 	// Get mailbox
-// 	mailbox, err := h.repositories.MailboxRepository.GetMailbox(ctx, replyToEmail.MailboxID)
-// 	if err != nil {
-// 		tracing.TraceErr(span, err)
-// 		return nil, err
-// 	}
-// 	container.Mailbox = mailbox
-//
-// 	// Validate outbound capability
-// 	if !mailbox.OutboundEnabled {
-// 		tracing.TraceErr(span, err)
-// 		return nil, errors.New("mailbox not enabled for outbound email")
-// 	}
-//
-// 	// Validate reply-to
-// 	replyTo := request.ReplyTo
-// 	if replyTo != "" {
-// 		cleanReplyTo, err := h.validateEmailSyntax(ctx, replyTo)
-// 		if err != nil {
-// 			tracing.TraceErr(span, err)
-// 			return nil, fmt.Errorf("invalid replyTo address: %w", err)
-// 		}
-// 		replyTo = cleanReplyTo
-// 	}
-//
-// 	// Validate attachments
-// 	attachments, err := h.resolveAttachments(ctx, request.Attachments)
-// 	if err != nil {
-// 		tracing.TraceErr(span, err)
-// 		return nil, err
-// 	}
-// 	container.Attachments = attachments
-//
-// 	// get sender profile
-// 	senderProfile, err := h.repositories.SenderRepository.GetByID(ctx, mailbox.SenderID)
-// 	if err != nil {
-// 		tracing.TraceErr(span, err)
-// 		return nil, err
-// 	}
-// 	if senderProfile == nil {
-// 		err := errors.New("No sender attached to mailbox")
-// 		tracing.TraceErr(span, err)
-// 		return nil, err
-// 	}
-// 	if senderProfile.DisplayName == "" {
-// 		senderProfile.DisplayName = mailbox.EmailAddress
-// 	}
-//
-// 	// build email model
-// 	email := &models.Email{
-// 		MailboxID:    mailbox.ID,
-// 		Direction:    enum.EmailDirectionOutbound,
-// 		MessageID:    utils.GenerateMessageID(mailbox.MailboxDomain, ""),
-// 		ThreadID:     replyToEmail.ThreadID,
-// 		InReplyTo:    replyToEmail.MessageID,
-// 		Subject:      fmt.Sprintf("RE: %s", replyToEmail.CleanSubject),
-// 		FromAddress:  mailbox.EmailAddress,
-// 		FromName:     senderProfile.DisplayName,
-// 		FromUser:     mailbox.MailboxUser,
-// 		FromDomain:   mailbox.MailboxDomain,
-// 		ReplyTo:      replyTo,
-// 		ToAddresses:  []string{replyToEmail.FromAddress},
-// 		CcAddresses:  []string{},
-// 		BccAddresses: []string{},
-// 		BodyText:     request.Body.Text,
-// 		BodyHTML:     request.Body.HTML,
-// 		Status:       enum.EmailStatusQueued,
-// 		SendAttempts: 1,
-// 	}
-//
-// 	// if reply-to header set on replyToEmail, use reply-to address as to
-// 	if replyToEmail.ReplyTo != "" {
-// 		replyTo := []string{replyToEmail.ReplyTo}
-// 		email.ToAddresses = replyTo
-// 	}
-//
-// 	if len(request.Attachments) > 0 {
-// 		email.HasAttachment = true
-// 	}
-//
-// 	// build references from all previous emails in thread
-// 	threadRecord, err := h.repositories.EmailRepository.ListByThread(ctx, replyToEmail.ThreadID)
-// 	if err != nil {
-// 		tracing.TraceErr(span, err)
-// 		return nil, err
-// 	}
-// 	if threadRecord == nil {
-// 		err := errors.New("unable to identify email thread")
-// 		tracing.TraceErr(span, err)
-// 		return nil, err
-// 	}
-//
-// 	for _, e := range threadRecord {
-// 		messageID := fmt.Sprintf("<%s>", e.MessageID)
-// 		if !utils.IsStringInSlice(messageID, email.References) {
-// 			email.References = append(email.References, messageID)
-// 		}
-// 	}
-//
-// 	container.Email = email
-// 	return container, nil
-// }
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/opentracing/opentracing-go"
+	tracingLog "github.com/opentracing/opentracing-go/log"
+
+	"github.com/customeros/mailstack/internal/enum"
+	mailstack_errors "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/services/email"
+)
+
+type ReplyEmailRequest struct {
+	FromName      string            `json:"fromName"`
+	ToAddresses   []string          `json:"toAddresses"`
+	CcAddresses   []string          `json:"ccAddresses"`
+	BccAddresses  []string          `json:"bccAddresses"`
+	ReplyTo       string            `json:"replyTo"`
+	Headers       map[string]string `json:"headers"`
+	Subject       string            `json:"subject"`
+	Body          EmailBody         `json:"body"`
+	AttachmentIDs []string          `json:"attachmentIds"`
+	ScheduleFor   *time.Time        `json:"scheduleFor"`
+}
+
+type EmailBody struct {
+	Text string `json:"text"`
+	HTML string `json:"html"`
+}
+
+type ReplyEmailResponse struct {
+	EmailID string           `json:"emailId"`
+	Status  enum.EmailStatus `json:"status"`
+}
+
+// Reply answers, answers all or forwards an email in its thread. Recipients and subject are
+// inherited from the original unless set in the request, forwards need explicit recipients.
+func (h *EmailsHandler) Reply(mode enum.ReplyMode) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "EmailsHandler.Reply")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		emailID := c.Param("id")
+		span.LogFields(tracingLog.String("emailId", emailID), tracingLog.String("mode", mode.String()))
+
+		var request ReplyEmailRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if mode == enum.ReplyModeForward && len(request.ToAddresses) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "toAddresses is required to forward an email"})
+			return
+		}
+
+		reply := &models.Email{
+			FromName:     request.FromName,
+			ToAddresses:  request.ToAddresses,
+			CcAddresses:  request.CcAddresses,
+			BccAddresses: request.BccAddresses,
+			ReplyTo:      request.ReplyTo,
+			Subject:      request.Subject,
+			BodyText:     request.Body.Text,
+			BodyHTML:     request.Body.HTML,
+			ScheduledFor: request.ScheduleFor,
+		}
+		reply.SetCustomHeaders(request.Headers)
+
+		emailID, status, err := h.services.EmailService.ScheduleReply(ctx, emailID, mode, reply, request.AttachmentIDs)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			if problems, ok := mailstack_errors.AsValidationErrors(err); ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "validationErrors": problems})
+				return
+			}
+			c.JSON(replyErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, ReplyEmailResponse{
+			EmailID: emailID,
+			Status:  status,
+		})
+	}
+}
+
+func replyErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, email.ErrEmailNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, email.ErrUnauthorizedSender), errors.Is(err, email.ErrOutboundNotEnabled):
+		return http.StatusForbidden
+	case errors.Is(err, mailstack_errors.ErrMessageTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, email.ErrRecipientsMissing),
+		errors.Is(err, email.ErrInvalidEmail),
+		errors.Is(err, email.ErrUnknownSender),
+		errors.Is(err, email.ErrEmptySubject),
+		errors.Is(err, email.ErrEmptyEmailBody),
+		errors.Is(err, email.ErrAttachmentDoesNotExist),
+		errors.Is(err, email.ErrScheduledSendNotValid),
+		errors.Is(err, email.ErrInvalidUnsubscribe),
+		errors.Is(err, email.ErrInvalidHeader):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,30 @@
+package emails
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	mailstack_errors "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/services/email"
+)
+
+func TestErrorStatusMessageTooLarge(t *testing.T) {
+	// as returned by the estimate before queueing and by rendering the message
+	for _, err := range []error{
+		errors.Wrapf(mailstack_errors.ErrMessageTooLarge, "estimated %d bytes, limit %d", 9000, 8999),
+		fmt.Errorf("render: %w", errors.Wrapf(mailstack_errors.ErrMessageTooLarge, "%d bytes, limit %d", 9000, 8999)),
+	} {
+		assert.Equal(t, http.StatusRequestEntityTooLarge, replyErrorStatus(err), err.Error())
+		assert.Equal(t, http.StatusRequestEntityTooLarge, previewErrorStatus(err), err.Error())
+	}
+
+	assert.Equal(t, http.StatusNotFound, replyErrorStatus(email.ErrEmailNotFound))
+	assert.Equal(t, http.StatusBadRequest, replyErrorStatus(email.ErrEmptyEmailBody))
+	assert.Equal(t, http.StatusBadRequest, previewErrorStatus(email.ErrEmailNotOutbound))
+	assert.Equal(t, http.StatusInternalServerError, replyErrorStatus(errors.New("connection reset")))
+	assert.Equal(t, http.StatusInternalServerError, previewErrorStatus(errors.New("connection reset")))
+}
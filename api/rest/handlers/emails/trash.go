@@ -0,0 +1,67 @@
+package emails
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/opentracing/opentracing-go"
+	tracingLog "github.com/opentracing/opentracing-go/log"
+
+	mailstack_errors "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/services/email"
+)
+
+// Delete moves an email to the trash, it is purged after the retention period. Its thread is
+// deleted along when it was the last email.
+func (h *EmailsHandler) Delete() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "EmailsHandler.Delete")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		emailID := c.Param("id")
+		span.LogFields(tracingLog.String("emailId", emailID))
+
+		if err := h.services.EmailService.DeleteEmail(ctx, emailID); err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(trashErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// Restore takes an email out of the trash, and its thread when that was deleted
+func (h *EmailsHandler) Restore() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "EmailsHandler.Restore")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		emailID := c.Param("id")
+		span.LogFields(tracingLog.String("emailId", emailID))
+
+		restored, err := h.services.EmailService.RestoreEmail(ctx, emailID)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(trashErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, restored)
+	}
+}
+
+func trashErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, email.ErrEmailNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, mailstack_errors.ErrEmailNotDeleted):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
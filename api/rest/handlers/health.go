@@ -2,6 +2,9 @@ package handlers
 
 import (
 	"net/http"
+	"sort"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
@@ -22,3 +25,98 @@ func Status(imapService interfaces.IMAPService) gin.HandlerFunc {
 		c.JSON(http.StatusOK, status)
 	}
 }
+
+// defaultMaxDisconnected is how long a mailbox may be disconnected before the service is not ready
+const defaultMaxDisconnected = 10 * time.Minute
+
+type MailboxesHealthResponse struct {
+	Ready     bool            `json:"ready"`
+	Mailboxes []MailboxHealth `json:"mailboxes"`
+}
+
+type MailboxHealth struct {
+	MailboxID         string         `json:"mailboxId"`
+	Connected         bool           `json:"connected"`
+	LastError         string         `json:"lastError,omitempty"`
+	LastChecked       time.Time      `json:"lastChecked"`
+	DisconnectedSince *time.Time     `json:"disconnectedSince,omitempty"`
+	WaitingForSlot    bool           `json:"waitingForSlot,omitempty"`
+	AuthFailed        bool           `json:"authFailed,omitempty"`
+	Folders           []FolderHealth `json:"folders"`
+}
+
+type FolderHealth struct {
+	Name     string    `json:"name"`
+	Total    uint32    `json:"total"`
+	Unseen   uint32    `json:"unseen"`
+	LastSync time.Time `json:"lastSync"`
+}
+
+// MailboxesHealth reports the IMAP connection and folder sync state of every monitored mailbox.
+// The service is ready unless a mailbox has been disconnected for longer than maxDisconnectedSeconds
+// (10 minutes by default), in which case it answers 503.
+func MailboxesHealth(imapService interfaces.IMAPService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		maxDisconnected := defaultMaxDisconnected
+		if value := c.Query("maxDisconnectedSeconds"); value != "" {
+			seconds, err := strconv.Atoi(value)
+			if err != nil || seconds < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "maxDisconnectedSeconds must be a positive number"})
+				return
+			}
+			maxDisconnected = time.Duration(seconds) * time.Second
+		}
+
+		response := mailboxesHealth(imapService.Status(), time.Now(), maxDisconnected)
+		if !response.Ready {
+			c.JSON(http.StatusServiceUnavailable, response)
+			return
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+func mailboxesHealth(statuses map[string]interfaces.MailboxStatus, now time.Time, maxDisconnected time.Duration) MailboxesHealthResponse {
+	response := MailboxesHealthResponse{
+		Ready:     true,
+		Mailboxes: make([]MailboxHealth, 0, len(statuses)),
+	}
+
+	for mailboxID, status := range statuses {
+		mailbox := MailboxHealth{
+			MailboxID:      mailboxID,
+			Connected:      status.Connected,
+			LastError:      status.LastError,
+			LastChecked:    status.LastChecked,
+			WaitingForSlot: status.WaitingForSlot,
+			AuthFailed:     status.AuthFailed,
+			Folders:        make([]FolderHealth, 0, len(status.Folders)),
+		}
+		// mailboxes queued behind the connection limit wait by design and rejected credentials
+		// need the user to update them, neither fails readiness
+		if !status.Connected && !status.WaitingForSlot && !status.AuthFailed {
+			if !status.DisconnectedSince.IsZero() {
+				disconnectedSince := status.DisconnectedSince
+				mailbox.DisconnectedSince = &disconnectedSince
+			}
+			if status.DisconnectedSince.IsZero() || now.Sub(status.DisconnectedSince) > maxDisconnected {
+				response.Ready = false
+			}
+		}
+
+		for name, folder := range status.Folders {
+			mailbox.Folders = append(mailbox.Folders, FolderHealth{
+				Name:     name,
+				Total:    folder.Total,
+				Unseen:   folder.Unseen,
+				LastSync: folder.LastSync,
+			})
+		}
+		sort.Slice(mailbox.Folders, func(i, j int) bool { return mailbox.Folders[i].Name < mailbox.Folders[j].Name })
+
+		response.Mailboxes = append(response.Mailboxes, mailbox)
+	}
+	sort.Slice(response.Mailboxes, func(i, j int) bool { return response.Mailboxes[i].MailboxID < response.Mailboxes[j].MailboxID })
+
+	return response
+}
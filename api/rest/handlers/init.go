@@ -8,19 +8,29 @@ import (
 )
 
 type APIHandlers struct {
-	Emails   *emails.EmailsHandler
-	Domains  *DomainHandler
-	DNS      *DNSHandler
-	Mailbox  *MailboxHandler
-	Postmark *PostmarkHandler
+	Emails    *emails.EmailsHandler
+	Domains   *DomainHandler
+	DNS       *DNSHandler
+	Mailbox   *MailboxHandler
+	Postmark  *PostmarkHandler
+	DMARC     *DMARCHandler
+	Admin     *AdminHandler
+	Threads   *ThreadsHandler
+	Retention *RetentionHandler
+	AuditLog  *AuditLogHandler
 }
 
 func InitHandlers(r *repository.Repositories, cfg *config.Config, s *services.Services) *APIHandlers {
 	return &APIHandlers{
-		Emails:   emails.NewEmailsHandler(r),
-		Domains:  NewDomainHandler(r, cfg, s),
-		DNS:      NewDNSHandler(s),
-		Mailbox:  NewMailboxHandler(r, cfg, s),
-		Postmark: NewPostmarkHandler(r, s),
+		Emails:    emails.NewEmailsHandler(r, s),
+		Domains:   NewDomainHandler(r, cfg, s),
+		DNS:       NewDNSHandler(s),
+		Mailbox:   NewMailboxHandler(r, cfg, s),
+		Postmark:  NewPostmarkHandler(r, s),
+		DMARC:     NewDMARCHandler(s),
+		Admin:     NewAdminHandler(s),
+		Threads:   NewThreadsHandler(r, s),
+		Retention: NewRetentionHandler(r),
+		AuditLog:  NewAuditLogHandler(r),
 	}
 }
@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/mail"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/opentracing/opentracing-go"
+	tracingLog "github.com/opentracing/opentracing-go/log"
+	"github.com/pkg/errors"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/enum"
+	"github.com/customeros/mailstack/internal/tracing"
+)
+
+const (
+	// maxImportBytes caps the upload of an import, and separately the messages read from it. The
+	// messages are held in memory until they are appended, larger migrations are split by the client.
+	maxImportBytes    = 25 << 20
+	maxImportMessages = 2000
+)
+
+var errImportTooLarge = fmt.Errorf("import is larger than %d bytes", maxImportBytes)
+
+type ImportMessagesResponse struct {
+	Folder   string                    `json:"folder"`
+	Imported int                       `json:"imported"`
+	Failed   int                       `json:"failed"`
+	Messages []interfaces.ImportResult `json:"messages"`
+}
+
+// ImportMessages appends uploaded messages to a folder of the mailbox, e.g. when migrating from
+// another provider. It takes a multipart form with one or more "files", each a single .eml
+// message, an mbox or a zip of those, and the fields "folder", "flags" (comma separated, e.g.
+// \Seen), "internalDate" (RFC3339, defaults to the Date header of each message) and "process" to
+// queue the messages for processing. The outcome is reported per message.
+func (h *MailboxHandler) ImportMessages() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "MailboxHandler.ImportMessages")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		mailboxID := c.Param("id")
+		span.LogFields(tracingLog.String("mailboxId", mailboxID))
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+		form, err := c.MultipartForm()
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("expected a multipart form of at most %dMB", maxImportBytes>>20)})
+			return
+		}
+
+		folder := c.PostForm("folder")
+		flags, err := parseImportFlags(c.PostForm("flags"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		var internalDate time.Time
+		if value := c.PostForm("internalDate"); value != "" {
+			if internalDate, err = time.Parse(time.RFC3339, value); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid internalDate, expected RFC3339"})
+				return
+			}
+		}
+		process, _ := strconv.ParseBool(c.DefaultPostForm("process", "false"))
+
+		messages, err := readImportFiles(form.File["files"])
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		for i := range messages {
+			messages[i].Flags = flags
+			messages[i].Date = internalDate
+			if internalDate.IsZero() {
+				messages[i].Date = messageDate(messages[i].Raw)
+			}
+		}
+		span.LogFields(tracingLog.Int("messages", len(messages)))
+
+		mailbox := h.tenantMailbox(c, span, mailboxID)
+		if mailbox == nil {
+			return
+		}
+
+		results, err := h.services.IMAPService.ImportMessages(ctx, mailbox, folder, messages, process)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(folderErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		response := ImportMessagesResponse{Folder: folder, Messages: results}
+		for _, result := range results {
+			if result.Imported {
+				response.Imported++
+			} else {
+				response.Failed++
+			}
+		}
+		h.audit(ctx, enum.AuditMailboxMessagesImported, mailbox.EmailAddress, nil, map[string]interface{}{
+			"folder":   folder,
+			"imported": response.Imported,
+			"failed":   response.Failed,
+		})
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// parseImportFlags splits the comma separated flags, refusing characters that would break the
+// APPEND command
+func parseImportFlags(value string) ([]string, error) {
+	var flags []string
+	for _, flag := range strings.Split(value, ",") {
+		flag = strings.TrimSpace(flag)
+		if flag == "" {
+			continue
+		}
+		if strings.ContainsAny(flag, " ()\"{}%*]\r\n") || strings.Contains(flag[1:], "\\") {
+			return nil, fmt.Errorf("invalid flag %q", flag)
+		}
+		flags = append(flags, flag)
+	}
+	return flags, nil
+}
+
+// readImportFiles turns the uploaded files into messages. Zip files are unpacked, files starting
+// with an mbox "From " line are split, anything else is taken as a single message.
+func readImportFiles(files []*multipart.FileHeader) ([]interfaces.ImportMessage, error) {
+	if len(files) == 0 {
+		return nil, errors.New("no files uploaded")
+	}
+
+	var messages []interfaces.ImportMessage
+	unpacked := int64(0)
+	for _, header := range files {
+		found, err := readImportFile(header, maxImportBytes-unpacked)
+		if err != nil {
+			return nil, err
+		}
+		for _, message := range found {
+			unpacked += int64(len(message.Raw))
+		}
+		messages = append(messages, found...)
+	}
+
+	if len(messages) == 0 {
+		return nil, errors.New("no messages found in the uploaded files")
+	}
+	if len(messages) > maxImportMessages {
+		return nil, fmt.Errorf("at most %d messages can be imported at once", maxImportMessages)
+	}
+	return messages, nil
+}
+
+// readImportFile reads the messages of an uploaded file. Zip files are read from the stored upload
+// entry by entry instead of being loaded whole, failing once they unpack to more than limit bytes.
+func readImportFile(header *multipart.FileHeader, limit int64) ([]interfaces.ImportMessage, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", header.Filename)
+	}
+	defer file.Close()
+
+	magic := make([]byte, 4)
+	n, _ := file.ReadAt(magic, 0)
+	if !isZip(magic[:n]) {
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s", header.Filename)
+		}
+		return splitMessages(header.Filename, data), nil
+	}
+
+	archive, err := zip.NewReader(file, header.Size)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid zip file %s", header.Filename)
+	}
+	var messages []interfaces.ImportMessage
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() || strings.HasPrefix(path.Base(entry.Name), ".") {
+			continue
+		}
+		content, err := readZipEntry(entry, limit)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to unpack %s from %s", entry.Name, header.Filename)
+		}
+		limit -= int64(len(content))
+		messages = append(messages, splitMessages(header.Filename+"/"+entry.Name, content)...)
+	}
+	return messages, nil
+}
+
+func isZip(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("PK\x03\x04"))
+}
+
+// readZipEntry unpacks an entry, failing once it unpacks to more than limit bytes
+func readZipEntry(entry *zip.File, limit int64) ([]byte, error) {
+	reader, err := entry.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > limit {
+		return nil, errImportTooLarge
+	}
+	return content, nil
+}
+
+// splitMessages splits an mbox into its messages, naming them after their position in it. Lines
+// quoted by the mbox format (">From ") are restored. Other content is a single message.
+func splitMessages(name string, data []byte) []interfaces.ImportMessage {
+	if !bytes.HasPrefix(data, []byte("From ")) {
+		return []interfaces.ImportMessage{{Name: name, Raw: toCRLF(data)}}
+	}
+
+	var messages []interfaces.ImportMessage
+	var current bytes.Buffer
+	flush := func() {
+		raw := bytes.TrimRight(current.Bytes(), "\r\n")
+		if len(raw) > 0 {
+			messages = append(messages, interfaces.ImportMessage{
+				Name: fmt.Sprintf("%s#%d", name, len(messages)+1),
+				Raw:  append(toCRLF(raw), '\r', '\n'),
+			})
+		}
+		current.Reset()
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), len(data)+1)
+	previousBlank := true
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if previousBlank && bytes.HasPrefix(line, []byte("From ")) {
+			flush()
+			previousBlank = false
+			continue
+		}
+		previousBlank = len(bytes.TrimRight(line, "\r")) == 0
+		if unquoted := bytes.TrimLeft(line, ">"); len(unquoted) < len(line) && bytes.HasPrefix(unquoted, []byte("From ")) {
+			line = line[1:]
+		}
+		current.Write(line)
+		current.WriteByte('\n')
+	}
+	flush()
+	return messages
+}
+
+// toCRLF normalizes line endings to the CRLF IMAP servers expect, in a single copy of the data
+// with room left for a final line ending
+func toCRLF(data []byte) []byte {
+	normalized := make([]byte, 0, len(data)+bytes.Count(data, []byte("\n"))+2)
+	for i, b := range data {
+		if b == '\n' && (i == 0 || data[i-1] != '\r') {
+			normalized = append(normalized, '\r')
+		}
+		normalized = append(normalized, b)
+	}
+	return normalized
+}
+
+// messageDate is the Date header of a message, zero when missing or unparsable so the server
+// uses the time of the import
+func messageDate(raw []byte) time.Time {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return time.Time{}
+	}
+	date, err := msg.Header.Date()
+	if err != nil {
+		return time.Time{}
+	}
+	return date
+}
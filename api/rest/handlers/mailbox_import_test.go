@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"mime/multipart"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const importedEml = "From: jane@acme.com\nTo: joe@beta.com\nDate: Mon, 12 Oct 2026 09:30:00 +0000\nSubject: Hello\n\nHi Joe\n"
+
+func TestSplitMessages(t *testing.T) {
+	mbox := "From jane@acme.com Mon Oct 12 09:30:00 2026\n" + importedEml +
+		"\nFrom joe@beta.com Tue Oct 13 10:00:00 2026\nFrom: joe@beta.com\nSubject: Re: Hello\n\n>From the team\n>>From quoted\n"
+
+	messages := splitMessages("archive.mbox", []byte(mbox))
+	require.Len(t, messages, 2)
+	assert.Equal(t, "archive.mbox#1", messages[0].Name)
+	assert.Equal(t, "From: jane@acme.com\r\nTo: joe@beta.com\r\nDate: Mon, 12 Oct 2026 09:30:00 +0000\r\nSubject: Hello\r\n\r\nHi Joe\r\n", string(messages[0].Raw))
+	assert.Equal(t, "archive.mbox#2", messages[1].Name)
+	assert.Equal(t, "From: joe@beta.com\r\nSubject: Re: Hello\r\n\r\nFrom the team\r\n>From quoted\r\n", string(messages[1].Raw))
+
+	single := splitMessages("hello.eml", []byte(importedEml))
+	require.Len(t, single, 1)
+	assert.Equal(t, "hello.eml", single[0].Name)
+	assert.Equal(t, time.Date(2026, 10, 12, 9, 30, 0, 0, time.UTC), messageDate(single[0].Raw).UTC())
+	assert.True(t, messageDate([]byte("not a message")).IsZero())
+}
+
+func TestReadImportFiles(t *testing.T) {
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for name, content := range map[string]string{
+		"inbox/1.eml":  importedEml,
+		"inbox/2.eml":  importedEml,
+		"sent.mbox":    "From jane@acme.com Mon Oct 12 09:30:00 2026\n" + importedEml,
+		"__MACOSX/":    "",
+		"inbox/.DS_St": "junk",
+	} {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+
+	files := uploadFiles(t, map[string][]byte{"export.zip": archive.Bytes(), "hello.eml": []byte(importedEml)})
+	messages, err := readImportFiles(files)
+	require.NoError(t, err)
+
+	var names []string
+	for _, message := range messages {
+		names = append(names, message.Name)
+	}
+	assert.ElementsMatch(t, []string{"export.zip/inbox/1.eml", "export.zip/inbox/2.eml", "export.zip/sent.mbox#1", "hello.eml"}, names)
+
+	_, err = readImportFiles(nil)
+	assert.Error(t, err)
+}
+
+func TestParseImportFlags(t *testing.T) {
+	flags, err := parseImportFlags(`\Seen, \Flagged,,$Imported`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{`\Seen`, `\Flagged`, `$Imported`}, flags)
+
+	for _, value := range []string{`\Seen)`, `two words`, `\Se\en`, "\\Se\ren"} {
+		_, err = parseImportFlags(value)
+		assert.Error(t, err, value)
+	}
+}
+
+// uploadFiles builds the file headers of a multipart form with the files under "files"
+func uploadFiles(t *testing.T, files map[string][]byte) []*multipart.FileHeader {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for name, content := range files {
+		w, err := mw.CreateFormFile("files", name)
+		require.NoError(t, err)
+		_, err = w.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, mw.Close())
+
+	form, err := multipart.NewReader(&body, mw.Boundary()).ReadForm(1 << 20)
+	require.NoError(t, err)
+	return form.File["files"]
+}
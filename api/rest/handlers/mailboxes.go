@@ -1,24 +1,29 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/opentracing/opentracing-go"
 	tracingLog "github.com/opentracing/opentracing-go/log"
 	"github.com/pkg/errors"
+	"gorm.io/gorm"
 
 	"github.com/customeros/mailstack/interfaces"
 	"github.com/customeros/mailstack/internal/config"
+	"github.com/customeros/mailstack/internal/enum"
 	er "github.com/customeros/mailstack/internal/errors"
 	"github.com/customeros/mailstack/internal/models"
 	"github.com/customeros/mailstack/internal/repository"
 	"github.com/customeros/mailstack/internal/tracing"
 	"github.com/customeros/mailstack/internal/utils"
 	"github.com/customeros/mailstack/services"
+	"github.com/customeros/mailstack/services/mailbox"
 )
 
 type MailboxHandler struct {
@@ -37,16 +42,6 @@ func NewMailboxHandler(repos *repository.Repositories, cfg *config.Config, s *se
 	}
 }
 
-type NewMailboxRequest struct {
-	Username              string   `json:"username"`
-	Password              string   `json:"password"`
-	Domain                string   `json:"domain"`
-	ForwardingTo          []string `json:"forwardingTo"`
-	WebmailEnabled        bool     `json:"webmailEnabled"`
-	UserId                string   `json:"userId"`
-	IgnoreDomainOwnership bool     `json:"ignoreDomainOwnership"`
-}
-
 type MailboxesResponse struct {
 	Mailboxes []MailboxRecord `json:"mailboxes,omitempty"`
 }
@@ -113,47 +108,55 @@ func (h *MailboxHandler) GetMailboxes() gin.HandlerFunc {
 	}
 }
 
-func (h *MailboxHandler) RegisterNewMailbox() gin.HandlerFunc {
+type ProvisionMailboxRequest struct {
+	Username       string   `json:"username"`
+	Password       string   `json:"password"`
+	Domain         string   `json:"domain"`
+	ForwardingTo   []string `json:"forwardingTo"`
+	WebmailEnabled bool     `json:"webmailEnabled"`
+	UserId         string   `json:"userId"`
+	SenderId       string   `json:"senderId"`
+}
+
+type ProvisionMailboxResponse struct {
+	ID           string   `json:"id"`
+	Email        string   `json:"email"`
+	Password     string   `json:"password,omitempty"`
+	ImapServer   string   `json:"imapServer"`
+	ImapPort     int      `json:"imapPort"`
+	SmtpServer   string   `json:"smtpServer"`
+	SmtpPort     int      `json:"smtpPort"`
+	SyncFolders  []string `json:"syncFolders"`
+	ForwardingTo []string `json:"forwardingTo"`
+}
+
+// ProvisionMailbox sets up a hosted mailbox at OpenSRS, stores it and starts syncing it in
+// one request. Nothing is left behind when a step fails.
+func (h *MailboxHandler) ProvisionMailbox() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "MailboxHandler.RegisterNewMailbox")
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "MailboxHandler.ProvisionMailbox")
 		defer span.Finish()
 		tracing.SetDefaultRestSpanTags(ctx, span)
 
 		tenant := utils.GetTenantFromContext(ctx)
 
-		// Parse and validate request body
-		var request NewMailboxRequest
+		var request ProvisionMailboxRequest
 		if err := c.ShouldBindJSON(&request); err != nil {
 			tracing.TraceErr(span, errors.Wrap(err, "Invalid request body"))
-			// log body
-			body, _ := c.GetRawData()
-			span.LogFields(tracingLog.String("request.body", string(body)))
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		tracing.LogObjectAsJson(span, "request", request)
 
-		// validate domain
-		if request.Domain == "" {
+		domain := strings.TrimSpace(request.Domain)
+		if domain == "" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Domain is required"})
 			return
 		}
-		domain := strings.TrimSpace(request.Domain)
-
 		username := strings.TrimSpace(request.Username)
 		if username == "" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Username is required"})
 			return
 		}
-
-		password := strings.TrimSpace(request.Password)
-		passwordGenerated := false
-		if password == "" {
-			passwordGenerated = true
-			password = utils.GenerateLowerAlpha(1) + utils.GenerateKey(11, false)
-		}
-
-		// validate username format
 		if err := validateMailboxUsername(username); err != nil {
 			message := "username has wrong format"
 			tracing.TraceErr(span, errors.Wrap(err, message))
@@ -161,60 +164,56 @@ func (h *MailboxHandler) RegisterNewMailbox() gin.HandlerFunc {
 			return
 		}
 
-		// add bcc forwarding address
-		forwardingTo := request.ForwardingTo
-		additionalForwardingTo := fmt.Sprintf("bcc@%s.customeros.ai", strings.ToLower(tenant))
-		forwardingTo = append(forwardingTo, additionalForwardingTo)
+		// spaces are valid in passwords, the password is used as given
+		password := request.Password
+		passwordGenerated := false
+		if password == "" {
+			passwordGenerated = true
+			password = utils.GenerateLowerAlpha(1) + utils.GenerateKey(11, false)
+		}
+
+		forwardingTo := append(request.ForwardingTo, fmt.Sprintf("bcc@%s.customeros.ai", strings.ToLower(tenant)))
 
-		err := h.services.MailboxServiceOld.CreateMailbox(ctx, nil, interfaces.CreateMailboxRequest{
-			Domain:                domain,
-			Username:              username,
-			Password:              password,
-			UserId:                request.UserId,
-			WebmailEnabled:        request.WebmailEnabled,
-			ForwardingTo:          forwardingTo,
-			IgnoreDomainOwnership: request.IgnoreDomainOwnership,
+		provisioned, err := h.mailboxService.ProvisionMailbox(ctx, interfaces.ProvisionMailboxRequest{
+			Username:       username,
+			Domain:         domain,
+			Password:       password,
+			UserID:         request.UserId,
+			SenderID:       request.SenderId,
+			ForwardingTo:   forwardingTo,
+			WebmailEnabled: request.WebmailEnabled,
 		})
 		if err != nil {
-			if errors.Is(err, er.ErrDomainNotFound) {
-				message := "domain not found"
-				tracing.TraceErr(span, errors.Wrap(err, message))
-				c.JSON(http.StatusNotFound, gin.H{"error": message})
-				return
-			} else if errors.Is(err, er.ErrMailboxExists) {
-				message := "username already exists"
-				tracing.TraceErr(span, errors.Wrap(err, message))
-				c.JSON(http.StatusConflict, gin.H{"error": message})
-				return
-			} else {
-				message := "Mailbox setup failed"
-				tracing.TraceErr(span, errors.Wrap(err, message))
-				c.JSON(http.StatusInternalServerError, gin.H{"error": message})
-				return
+			tracing.TraceErr(span, err)
+			switch {
+			case errors.Is(err, er.ErrDomainNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": "domain not found"})
+			case errors.Is(err, er.ErrMailboxExists):
+				c.JSON(http.StatusConflict, gin.H{"error": "username already exists"})
+			case errors.Is(err, mailbox.ErrMailboxValidation):
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Mailbox setup failed"})
 			}
-		}
-
-		mailbox, err := h.services.MailboxServiceOld.GetByMailbox(ctx, username, domain)
-		if err != nil {
-			tracing.TraceErr(span, errors.Wrap(err, "Error retrieving mailbox"))
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		if mailbox == nil {
-			tracing.TraceErr(span, errors.New("mailbox not created"))
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "mailbox not created"})
 			return
 		}
 
-		response := MailboxRecord{
-			ID:                mailbox.ID,
-			Email:             username + "@" + domain,
-			WebmailEnabled:    request.WebmailEnabled,
-			ForwardingEnabled: true,
-			ForwardingTo:      forwardingTo,
-			Provisioned:       mailbox.Status == models.MailboxStatusProvisioned,
-		}
+		h.audit(ctx, enum.AuditMailboxCreated, provisioned.EmailAddress, nil, map[string]interface{}{
+			"forwardingTo":   forwardingTo,
+			"webmailEnabled": request.WebmailEnabled,
+			"syncFolders":    []string(provisioned.SyncFolders),
+		})
 
+		response := ProvisionMailboxResponse{
+			ID:           provisioned.ID,
+			Email:        provisioned.EmailAddress,
+			ImapServer:   provisioned.ImapServer,
+			ImapPort:     provisioned.ImapPort,
+			SmtpServer:   provisioned.SmtpServer,
+			SmtpPort:     provisioned.SmtpPort,
+			SyncFolders:  provisioned.SyncFolders,
+			ForwardingTo: forwardingTo,
+		}
 		if passwordGenerated {
 			response.Password = password
 		}
@@ -259,6 +258,14 @@ func (h *MailboxHandler) ConfigureMailbox() gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to configure mailbox"})
 			return
 		}
+		// mailboxes are audited by their address, the id only when the mailbox can not be loaded
+		target := mailboxID
+		if mailbox, err := h.repos.TenantSettingsMailboxRepository.GetById(ctx, mailboxID); err != nil {
+			tracing.TraceErr(span, err)
+		} else if mailbox != nil && mailbox.MailboxUsername != "" {
+			target = mailbox.MailboxUsername
+		}
+		h.audit(ctx, enum.AuditMailboxConfigured, target, nil, nil)
 
 		c.JSON(http.StatusOK, gin.H{})
 	}
@@ -319,3 +326,484 @@ func (h *MailboxHandler) GetMailboxByEmail() gin.HandlerFunc {
 		c.JSON(http.StatusOK, response)
 	}
 }
+
+type ChangeMailboxPasswordRequest struct {
+	Password string `json:"password"`
+}
+
+// DeleteMailbox deletes a hosted mailbox of the tenant
+func (h *MailboxHandler) DeleteMailbox() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "MailboxHandler.DeleteMailbox")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		tenant := utils.GetTenantFromContext(ctx)
+		email := c.Param("email")
+
+		err := h.services.OpenSrsService.DeleteMailbox(ctx, tenant, email)
+		if err != nil {
+			if errors.Is(err, er.ErrMailboxNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "mailbox not found"})
+				return
+			}
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete mailbox"})
+			return
+		}
+		h.audit(ctx, enum.AuditMailboxDeleted, email, nil, nil)
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// ChangeMailboxPassword sets a new password for a hosted mailbox. A password is
+// generated when none is provided and returned in the response.
+func (h *MailboxHandler) ChangeMailboxPassword() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "MailboxHandler.ChangeMailboxPassword")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		tenant := utils.GetTenantFromContext(ctx)
+		email := c.Param("email")
+
+		var request ChangeMailboxPasswordRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// spaces are valid in passwords, the password is used as given
+		password := request.Password
+		passwordGenerated := false
+		if password == "" {
+			passwordGenerated = true
+			password = utils.GenerateLowerAlpha(1) + utils.GenerateKey(11, false)
+		}
+
+		err := h.services.OpenSrsService.ChangeMailboxPassword(ctx, tenant, email, password)
+		if err != nil {
+			if errors.Is(err, er.ErrMailboxNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "mailbox not found"})
+				return
+			}
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to change mailbox password"})
+			return
+		}
+		h.audit(ctx, enum.AuditMailboxPasswordChanged, email, nil, map[string]interface{}{"passwordGenerated": passwordGenerated})
+
+		response := gin.H{"email": email}
+		if passwordGenerated {
+			response["password"] = password
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// GetMailboxUsage returns the storage used by a hosted mailbox of the tenant
+func (h *MailboxHandler) GetMailboxUsage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "MailboxHandler.GetMailboxUsage")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		email := c.Param("email")
+		parts := strings.Split(email, "@")
+		if len(parts) != 2 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid email format"})
+			return
+		}
+
+		// tenant scoped lookup, mailboxes of other tenants are reported as not found
+		mailbox, err := h.services.MailboxServiceOld.GetByMailbox(ctx, parts[0], parts[1])
+		if err != nil {
+			tracing.TraceErr(span, errors.Wrap(err, "Error retrieving mailbox"))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if mailbox == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "mailbox not found"})
+			return
+		}
+
+		usage, err := h.services.OpenSrsService.GetMailboxUsage(ctx, email)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "failed to get mailbox usage"})
+			return
+		}
+
+		c.JSON(http.StatusOK, usage)
+	}
+}
+
+type MailboxStatusResponse struct {
+	MailboxID           string                `json:"mailboxId"`
+	ConnectionStatus    enum.ConnectionStatus `json:"connectionStatus"`
+	LastError           string                `json:"lastError,omitempty"`
+	LastConnectionCheck *time.Time            `json:"lastConnectionCheck"`
+	DisconnectedSince   *time.Time            `json:"disconnectedSince,omitempty"`
+	Folders             []FolderHealth        `json:"folders"`
+}
+
+// GetMailboxStatus returns the connection state and folder stats of a mailbox as stored by the
+// pod monitoring it, so the answer is the same on every pod. A connection status of auth_failed
+// means the server rejected the credentials and the mailbox waits for them to be updated.
+func (h *MailboxHandler) GetMailboxStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "MailboxHandler.GetMailboxStatus")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		mailboxID := c.Param("id")
+		span.LogFields(tracingLog.String("mailboxId", mailboxID))
+
+		mailbox := h.tenantMailbox(c, span, mailboxID)
+		if mailbox == nil {
+			return
+		}
+
+		folderStats, err := h.repos.MailboxFolderStatsRepository.GetByMailbox(ctx, mailboxID)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get mailbox folder stats"})
+			return
+		}
+
+		response := MailboxStatusResponse{
+			MailboxID:           mailbox.ID,
+			ConnectionStatus:    mailbox.ConnectionStatus,
+			LastError:           mailbox.ErrorMessage,
+			LastConnectionCheck: mailbox.LastConnectionCheck,
+			DisconnectedSince:   mailbox.DisconnectedSince,
+			Folders:             make([]FolderHealth, 0, len(folderStats)),
+		}
+		for _, folder := range folderStats {
+			response.Folders = append(response.Folders, FolderHealth{
+				Name:     folder.FolderName,
+				Total:    folder.Total,
+				Unseen:   folder.Unseen,
+				LastSync: folder.LastSync,
+			})
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// GetMailboxQuota returns the daily send limit of a mailbox, including its warm-up, and how
+// many sends are left today
+func (h *MailboxHandler) GetMailboxQuota() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "MailboxHandler.GetMailboxQuota")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		mailboxID := c.Param("id")
+		span.LogFields(tracingLog.String("mailboxId", mailboxID))
+
+		mailbox := h.tenantMailbox(c, span, mailboxID)
+		if mailbox == nil {
+			return
+		}
+
+		quota, err := h.services.EmailService.GetSendQuota(ctx, mailbox)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get mailbox quota"})
+			return
+		}
+
+		c.JSON(http.StatusOK, quota)
+	}
+}
+
+// CheckMailAuthAlignment reports whether mail sent from a mailbox passes SPF, DKIM and DMARC
+// alignment with its From domain, with warnings for what needs fixing before a campaign
+func (h *MailboxHandler) CheckMailAuthAlignment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "MailboxHandler.CheckMailAuthAlignment")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		mailboxID := c.Param("id")
+		span.LogFields(tracingLog.String("mailboxId", mailboxID))
+
+		mailbox := h.tenantMailbox(c, span, mailboxID)
+		if mailbox == nil {
+			return
+		}
+
+		report, err := h.services.DomainService.CheckMailAuthAlignment(ctx, mailbox)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+type ResyncMailboxResponse struct {
+	JobID string `json:"jobId"`
+}
+
+// ResyncMailbox resets the sync state of a mailbox, or of a single folder given with the folder
+// query param, and runs the initial sync again in the background
+func (h *MailboxHandler) ResyncMailbox() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "MailboxHandler.ResyncMailbox")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		mailboxID := c.Param("id")
+		folder := c.Query("folder")
+		span.LogFields(tracingLog.String("mailboxId", mailboxID), tracingLog.String("folder", folder))
+
+		if mailbox := h.tenantMailbox(c, span, mailboxID); mailbox == nil {
+			return
+		}
+
+		jobID, err := h.services.IMAPService.Resync(ctx, mailboxID, folder)
+		if err != nil {
+			switch {
+			case errors.Is(err, er.ErrMailboxNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": "mailbox is not synced"})
+			case errors.Is(err, er.ErrSyncFolderNotFound):
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			case errors.Is(err, er.ErrResyncInProgress):
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			default:
+				tracing.TraceErr(span, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start resync"})
+			}
+			return
+		}
+
+		c.JSON(http.StatusAccepted, ResyncMailboxResponse{JobID: jobID})
+	}
+}
+
+type CreateMailboxFolderRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// GetMailboxFolders returns the folder tree of the mailbox server with message and unseen counts
+func (h *MailboxHandler) GetMailboxFolders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "MailboxHandler.GetMailboxFolders")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		mailboxID := c.Param("id")
+		span.LogFields(tracingLog.String("mailboxId", mailboxID))
+
+		mailbox := h.tenantMailbox(c, span, mailboxID)
+		if mailbox == nil {
+			return
+		}
+
+		folders, err := h.services.IMAPService.ListFolders(ctx, mailbox)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(folderErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"folders": folders})
+	}
+}
+
+// CreateMailboxFolder creates a folder on the mailbox server
+func (h *MailboxHandler) CreateMailboxFolder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "MailboxHandler.CreateMailboxFolder")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		mailboxID := c.Param("id")
+		span.LogFields(tracingLog.String("mailboxId", mailboxID))
+
+		var request CreateMailboxFolderRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		mailbox := h.tenantMailbox(c, span, mailboxID)
+		if mailbox == nil {
+			return
+		}
+
+		if err := h.services.IMAPService.CreateFolder(ctx, mailbox, request.Name); err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(folderErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		h.audit(ctx, enum.AuditMailboxFolderCreated, mailbox.EmailAddress, nil, map[string]interface{}{"folder": request.Name})
+
+		c.JSON(http.StatusCreated, gin.H{"name": request.Name})
+	}
+}
+
+// DeleteMailboxFolder deletes the folder given with the name query param from the mailbox server.
+// A synced folder is also removed from the sync folders of the mailbox.
+func (h *MailboxHandler) DeleteMailboxFolder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "MailboxHandler.DeleteMailboxFolder")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		mailboxID := c.Param("id")
+		name := c.Query("name")
+		span.LogFields(tracingLog.String("mailboxId", mailboxID), tracingLog.String("folder", name))
+
+		mailbox := h.tenantMailbox(c, span, mailboxID)
+		if mailbox == nil {
+			return
+		}
+
+		if err := h.services.IMAPService.DeleteFolder(ctx, mailbox, name); err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(folderErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		h.audit(ctx, enum.AuditMailboxFolderDeleted, mailbox.EmailAddress, map[string]interface{}{"folder": name}, nil)
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+type UpdateMailboxRequest struct {
+	SyncFolders             []string `json:"syncFolders"`
+	SyncPollIntervalSeconds *int     `json:"syncPollIntervalSeconds"`
+	ImapUsername            *string  `json:"imapUsername"`
+	ImapPassword            *string  `json:"imapPassword"`
+}
+
+type MailboxSettingsResponse struct {
+	ID                      string   `json:"id"`
+	EmailAddress            string   `json:"emailAddress"`
+	SyncFolders             []string `json:"syncFolders"`
+	SyncPollIntervalSeconds int      `json:"syncPollIntervalSeconds"`
+	ImapUsername            string   `json:"imapUsername"`
+}
+
+// UpdateMailbox changes the sync folders, poll interval and IMAP credentials of a mailbox while
+// it syncs. Omitted fields are left as they are. Folders still synced keep their sync progress.
+func (h *MailboxHandler) UpdateMailbox() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "MailboxHandler.UpdateMailbox")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		mailboxID := c.Param("id")
+		span.LogFields(tracingLog.String("mailboxId", mailboxID))
+
+		var request UpdateMailboxRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		span.LogFields(
+			tracingLog.Object("request.syncFolders", request.SyncFolders),
+			tracingLog.Bool("request.credentials", request.ImapUsername != nil || request.ImapPassword != nil),
+		)
+
+		mailbox := h.tenantMailbox(c, span, mailboxID)
+		if mailbox == nil {
+			return
+		}
+
+		before := mailboxSettingsSummary(mailbox)
+		mailbox, err := h.services.IMAPService.UpdateMailboxSettings(ctx, mailbox, interfaces.MailboxSettingsUpdate{
+			SyncFolders:             request.SyncFolders,
+			SyncPollIntervalSeconds: request.SyncPollIntervalSeconds,
+			ImapUsername:            request.ImapUsername,
+			ImapPassword:            request.ImapPassword,
+		})
+		if err != nil {
+			tracing.TraceErr(span, err)
+			if errors.Is(err, er.ErrInvalidSyncSettings) || errors.Is(err, er.ErrInvalidFolderName) ||
+				errors.Is(err, er.ErrIMAPNotConfigured) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update mailbox"})
+			return
+		}
+		after := mailboxSettingsSummary(mailbox)
+		after["imapPasswordChanged"] = request.ImapPassword != nil
+		h.audit(ctx, enum.AuditMailboxSettingsUpdated, mailbox.EmailAddress, before, after)
+
+		c.JSON(http.StatusOK, MailboxSettingsResponse{
+			ID:                      mailbox.ID,
+			EmailAddress:            mailbox.EmailAddress,
+			SyncFolders:             mailbox.SyncFolders,
+			SyncPollIntervalSeconds: mailbox.SyncPollIntervalSeconds,
+			ImapUsername:            mailbox.ImapUsername,
+		})
+	}
+}
+
+// mailboxSettingsSummary is the part of the mailbox settings kept in the audit log, without secrets
+func mailboxSettingsSummary(mailbox *models.Mailbox) map[string]interface{} {
+	return map[string]interface{}{
+		"syncFolders":             []string(mailbox.SyncFolders),
+		"syncPollIntervalSeconds": mailbox.SyncPollIntervalSeconds,
+		"imapUsername":            mailbox.ImapUsername,
+	}
+}
+
+// audit records a mutation of a mailbox of the tenant, given by its address or id
+func (h *MailboxHandler) audit(ctx context.Context, action enum.AuditAction, mailbox string, before, after map[string]interface{}) {
+	h.services.AuditService.Record(ctx, interfaces.AuditEntry{
+		Action:     action,
+		TargetType: enum.AuditTargetMailbox,
+		TargetID:   mailbox,
+		Before:     before,
+		After:      after,
+	})
+}
+
+// tenantMailbox loads a mailbox of the tenant, or responds with the error and returns nil
+func (h *MailboxHandler) tenantMailbox(c *gin.Context, span opentracing.Span, mailboxID string) *models.Mailbox {
+	ctx := c.Request.Context()
+	mailbox, err := h.repos.MailboxRepository.GetMailbox(ctx, mailboxID)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && mailbox == nil) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "mailbox not found"})
+		return nil
+	}
+	if err != nil {
+		tracing.TraceErr(span, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get mailbox"})
+		return nil
+	}
+	if mailbox.Tenant != utils.GetTenantFromContext(ctx) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "mailbox does not belong to tenant"})
+		return nil
+	}
+	return mailbox
+}
+
+func folderErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, er.ErrFolderNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, er.ErrFolderExists):
+		return http.StatusConflict
+	case errors.Is(err, er.ErrInvalidFolderName),
+		errors.Is(err, er.ErrFolderProtected),
+		errors.Is(err, er.ErrIMAPNotConfigured):
+		return http.StatusBadRequest
+	case errors.Is(err, er.ErrIMAPAuthFailed):
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/interfaces"
+	er "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/repository"
+	"github.com/customeros/mailstack/internal/utils"
+	"github.com/customeros/mailstack/services"
+)
+
+type fakeResyncIMAPService struct {
+	interfaces.IMAPService
+	err     error
+	folders []string
+}
+
+func (s *fakeResyncIMAPService) Resync(_ context.Context, mailboxID, folderName string) (string, error) {
+	if s.err != nil {
+		return "", s.err
+	}
+	s.folders = append(s.folders, folderName)
+	return "resync_" + mailboxID, nil
+}
+
+func TestResyncMailbox(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name     string
+		path     string
+		err      error
+		status   int
+		folders  []string
+		response string
+	}{
+		{name: "all folders", path: "/mailboxes/mbox_1/resync", status: http.StatusAccepted, folders: []string{""}, response: "resync_mbox_1"},
+		{name: "single folder", path: "/mailboxes/mbox_1/resync?folder=INBOX", status: http.StatusAccepted, folders: []string{"INBOX"}, response: "resync_mbox_1"},
+		{name: "unknown mailbox", path: "/mailboxes/mbox_unknown/resync", status: http.StatusNotFound},
+		{name: "mailbox of another tenant", path: "/mailboxes/mbox_other/resync", status: http.StatusForbidden},
+		{name: "mailbox not synced", path: "/mailboxes/mbox_1/resync", err: er.ErrMailboxNotFound, status: http.StatusNotFound},
+		{name: "unknown folder", path: "/mailboxes/mbox_1/resync?folder=Archive", err: er.ErrSyncFolderNotFound, status: http.StatusBadRequest},
+		{name: "resync in progress", path: "/mailboxes/mbox_1/resync", err: er.ErrResyncInProgress, status: http.StatusConflict},
+		{name: "reset failed", path: "/mailboxes/mbox_1/resync", err: errors.New("connection reset"), status: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imapService := &fakeResyncIMAPService{err: tt.err}
+			handler := &MailboxHandler{
+				repos: &repository.Repositories{
+					MailboxRepository: &fakeMailboxRepository{mailboxes: []*models.Mailbox{
+						{ID: "mbox_1", Tenant: "acme"},
+						{ID: "mbox_other", Tenant: "other"},
+					}},
+				},
+				services: &services.Services{IMAPService: imapService},
+			}
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				c.Request = c.Request.WithContext(utils.SetTenantInContext(c.Request.Context(), "acme"))
+			})
+			router.POST("/mailboxes/:id/resync", handler.ResyncMailbox())
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, tt.path, nil))
+
+			assert.Equal(t, tt.status, recorder.Code)
+			assert.Equal(t, tt.folders, imapService.folders)
+			if tt.response != "" {
+				var response ResyncMailboxResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+				assert.Equal(t, tt.response, response.JobID)
+			}
+		})
+	}
+}
+
+type fakeProvisioningService struct {
+	interfaces.MailboxService
+	requests []interfaces.ProvisionMailboxRequest
+}
+
+func (s *fakeProvisioningService) ProvisionMailbox(_ context.Context, request interfaces.ProvisionMailboxRequest) (*models.Mailbox, error) {
+	s.requests = append(s.requests, request)
+	return &models.Mailbox{ID: "mbox_1", EmailAddress: request.Username + "@" + request.Domain}, nil
+}
+
+type fakeAuditService struct {
+	entries []interfaces.AuditEntry
+}
+
+func (s *fakeAuditService) Record(_ context.Context, entry interfaces.AuditEntry) {
+	s.entries = append(s.entries, entry)
+}
+
+func TestProvisionMailboxKeepsPassword(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mailboxService := &fakeProvisioningService{}
+	audit := &fakeAuditService{}
+	handler := &MailboxHandler{
+		mailboxService: mailboxService,
+		services:       &services.Services{AuditService: audit},
+	}
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(utils.SetTenantInContext(c.Request.Context(), "acme"))
+	})
+	router.POST("/mailboxes", handler.ProvisionMailbox())
+
+	recorder := httptest.NewRecorder()
+	body := `{"username":" jane ","domain":"acme.io","password":" pass word "}`
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/mailboxes", strings.NewReader(body)))
+
+	require.Equal(t, http.StatusCreated, recorder.Code, recorder.Body.String())
+	require.Len(t, mailboxService.requests, 1)
+	assert.Equal(t, "jane", mailboxService.requests[0].Username)
+	assert.Equal(t, " pass word ", mailboxService.requests[0].Password)
+	assert.NotContains(t, recorder.Body.String(), "pass word", "a given password is not echoed")
+	require.Len(t, audit.entries, 1)
+}
@@ -1,23 +1,17 @@
 package handlers
 
 import (
-	"archive/zip"
-	"bytes"
-	"compress/gzip"
 	"context"
 	"encoding/base64"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"runtime/debug"
 	"strings"
 
 	"github.com/customeros/mailsherpa/mailvalidate"
-	"github.com/customeros/mailstack/internal/models"
 	"github.com/customeros/mailstack/internal/repository"
 	"github.com/customeros/mailstack/internal/tracing"
 	"github.com/customeros/mailstack/services"
-	dmarcstats "github.com/customeros/mailwatcher/dmarkstats"
 	"github.com/gin-gonic/gin"
 	"github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
@@ -65,7 +59,7 @@ func (h *PostmarkHandler) PostmarkDMARCMonitor() gin.HandlerFunc {
 
 			var err error
 			if emailData.IsMonitorEmail() {
-				err = h.processDmarcMonitoringReport(c, &emailData)
+				err = h.processDmarcMonitoringReport(context.WithoutCancel(ctx), &emailData)
 				if err != nil {
 					tracing.TraceErr(span, errors.Wrap(err, "failed to process DMARC report"))
 				}
@@ -89,127 +83,38 @@ func (h *PostmarkHandler) processDmarcMonitoringReport(ctx context.Context, emai
 	defer span.Finish()
 	tracing.SetDefaultRestSpanTags(ctx, span)
 
-	// Get attachment, unzip, feed file to dmark analyzer service
-	attachment := emailData.Attachments[0]
-	if attachment.ContentType != "application/zip" && attachment.ContentType != "application/gzip" {
-		return fmt.Errorf("attachment %s is not a zip file", attachment.Name)
+	if len(emailData.Attachments) == 0 {
+		return errors.New("dmarc report email has no attachments")
 	}
 
 	provider := emailData.DMARCReportProvider()
 
-	reports, err := decodeAndReadDMARCReportFile(attachment.Content, attachment.ContentType)
-	if err != nil {
-		return fmt.Errorf("cannot parse dmarc report %s from attachment: %v", attachment.Name, err)
-	}
-	for _, report := range reports {
-		dbReport := h.buildDMARCReport(ctx, report, provider)
-		// todo - if tenant is empty, don't send report to database
-		// leaving this in for now to verify everything is working as expected
-		h.repos.DomainRepository.CreateDMARCReport(ctx, dbReport.Tenant, &dbReport)
-	}
-	return nil
-}
-
-func (h *PostmarkHandler) buildDMARCReport(ctx context.Context, report dmarcstats.Report, provider string) models.DMARCMonitoring {
-	span, ctx := tracing.StartTracerSpan(ctx, "PostmarkHandler.buildDMARCReport")
-	defer span.Finish()
-	tracing.SetDefaultRestSpanTags(ctx, span)
-
-	tenant, err := h.svc.DomainService.GetTenantForMailstackDomain(ctx, report.Domain)
-	if err != nil {
-		tracing.TraceErr(span, fmt.Errorf("unable to get tenant for domain %s: %v", report.Domain, err))
-	}
-
-	jsonReport, _ := json.Marshal(report)
-	return models.DMARCMonitoring{
-		Tenant:        tenant,
-		EmailProvider: provider,
-		Domain:        report.Domain,
-		ReportStart:   report.ReportPeriod.Start,
-		ReportEnd:     report.ReportPeriod.End,
-		MessageCount:  report.TotalMessages,
-		SPFPass:       report.AuthResults.SPFPassCount,
-		DKIMPass:      report.AuthResults.DKIMPassCount,
-		DMARCPass:     report.AuthResults.DMARCPassCount,
-		Data:          string(jsonReport),
-	}
-}
-
-func decodeAndReadDMARCReportFile(attachment, contentType string) ([]dmarcstats.Report, error) {
-	var reports []dmarcstats.Report
-
-	// Decode base64 string to bytes
-	decoded, err := base64.StdEncoding.DecodeString(attachment)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode base64: %w", err)
-	}
-
-	switch contentType {
-	case "application/zip":
-		reports, err = handleZipFile(decoded)
-	case "application/gzip":
-		reports, err = handleGzipFile(decoded)
-	default:
-		return nil, fmt.Errorf("unsupported content type: %s", contentType)
-	}
-
-	if err != nil {
-		return nil, err
-	}
-
-	return reports, nil
-}
-
-func handleZipFile(decoded []byte) ([]dmarcstats.Report, error) {
-	var reports []dmarcstats.Report
-
-	zipReader, err := zip.NewReader(bytes.NewReader(decoded), int64(len(decoded)))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create zip reader: %w", err)
-	}
+	for _, attachment := range emailData.Attachments {
+		if !isDMARCReportContentType(attachment.ContentType) {
+			span.LogKV("skipped.attachment", attachment.Name, "contentType", attachment.ContentType)
+			continue
+		}
 
-	for _, file := range zipReader.File {
-		report, err := processZipFile(file)
+		decoded, err := base64.StdEncoding.DecodeString(attachment.Content)
 		if err != nil {
-			return nil, err
+			return fmt.Errorf("failed to decode dmarc report %s: %w", attachment.Name, err)
 		}
-		reports = append(reports, *report)
-	}
-
-	return reports, nil
-}
-
-func handleGzipFile(decoded []byte) ([]dmarcstats.Report, error) {
-	var reports []dmarcstats.Report
-
-	gzReader, err := gzip.NewReader(bytes.NewReader(decoded))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
-	}
-	defer gzReader.Close()
 
-	report, err := dmarcstats.AnalyzeDMARCReport(gzReader)
-	if err != nil {
-		return nil, fmt.Errorf("failed to analyze DMARC report from gzip: %w", err)
+		_, err = h.svc.DomainService.ProcessDMARCReport(ctx, decoded, provider)
+		if err != nil {
+			return fmt.Errorf("cannot process dmarc report %s from attachment: %w", attachment.Name, err)
+		}
 	}
-
-	reports = append(reports, *report)
-	return reports, nil
+	return nil
 }
 
-func processZipFile(file *zip.File) (*dmarcstats.Report, error) {
-	rc, err := file.Open()
-	if err != nil {
-		return nil, fmt.Errorf("failed to open zip file %s: %w", file.Name, err)
-	}
-	defer rc.Close()
-
-	report, err := dmarcstats.AnalyzeDMARCReport(rc)
-	if err != nil {
-		return nil, fmt.Errorf("failed to analyze DMARC report for file %s: %w", file.Name, err)
+func isDMARCReportContentType(contentType string) bool {
+	switch strings.ToLower(contentType) {
+	case "application/zip", "application/x-zip-compressed", "application/gzip", "application/x-gzip",
+		"application/xml", "text/xml", "application/octet-stream":
+		return true
 	}
-
-	return report, nil
+	return false
 }
 
 type postmarkInboundEmailData struct {
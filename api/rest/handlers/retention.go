@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/opentracing/opentracing-go"
+	tracingLog "github.com/opentracing/opentracing-go/log"
+
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/repository"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+type RetentionHandler struct {
+	repos *repository.Repositories
+}
+
+func NewRetentionHandler(repos *repository.Repositories) *RetentionHandler {
+	return &RetentionHandler{
+		repos: repos,
+	}
+}
+
+type RetentionPolicyRequest struct {
+	RetentionDays int `json:"retentionDays" binding:"required,min=1"`
+}
+
+// GetRetentionPolicy returns the retention policy of the tenant, 404 when its mail is kept forever
+func (h *RetentionHandler) GetRetentionPolicy() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "RetentionHandler.GetRetentionPolicy")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		policy, err := h.repos.TenantRetentionPolicyRepository.GetByTenant(ctx, utils.GetTenantFromContext(ctx))
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get retention policy"})
+			return
+		}
+		if policy == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no retention policy, mail is kept"})
+			return
+		}
+
+		c.JSON(http.StatusOK, policy)
+	}
+}
+
+// SetRetentionPolicy sets after how many days the mail of the tenant is purged, with its
+// attachment files. The next retention run removes what is already past the window.
+func (h *RetentionHandler) SetRetentionPolicy() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "RetentionHandler.SetRetentionPolicy")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		var req RetentionPolicyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		span.LogFields(tracingLog.Int("retentionDays", req.RetentionDays))
+
+		policy := &models.TenantRetentionPolicy{
+			Tenant:        utils.GetTenantFromContext(ctx),
+			RetentionDays: req.RetentionDays,
+		}
+		if err := h.repos.TenantRetentionPolicyRepository.Save(ctx, policy); err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save retention policy"})
+			return
+		}
+
+		c.JSON(http.StatusOK, policy)
+	}
+}
+
+// DeleteRetentionPolicy removes the retention policy of the tenant, its mail is kept from then on
+func (h *RetentionHandler) DeleteRetentionPolicy() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "RetentionHandler.DeleteRetentionPolicy")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		if err := h.repos.TenantRetentionPolicyRepository.Delete(ctx, utils.GetTenantFromContext(ctx)); err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete retention policy"})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,774 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/opentracing/opentracing-go"
+	tracingLog "github.com/opentracing/opentracing-go/log"
+	"gorm.io/gorm"
+
+	"github.com/customeros/mailstack/dto"
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/enum"
+	mailstack_errors "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/repository"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+	"github.com/customeros/mailstack/services"
+)
+
+const (
+	defaultThreadPageSize = 50
+	maxThreadPageSize     = 200
+	threadPreviewLength   = 200
+	maxBulkThreadIDs      = 500
+)
+
+// threadNotifier publishes the notifications of thread state changes
+type threadNotifier interface {
+	PublishNotificationBulk(ctx context.Context, tenant string, entityIds []string, entityType enum.EntityType, details *utils.EventCompletedDetails)
+}
+
+type ThreadsHandler struct {
+	repos        *repository.Repositories
+	notifier     threadNotifier
+	emailService interfaces.EmailService
+}
+
+func NewThreadsHandler(repos *repository.Repositories, s *services.Services) *ThreadsHandler {
+	h := &ThreadsHandler{
+		repos:        repos,
+		emailService: s.EmailService,
+	}
+	if s.EventsService != nil && s.EventsService.Publisher != nil {
+		h.notifier = s.EventsService.Publisher
+	}
+	return h
+}
+
+type ThreadsResponse struct {
+	Threads    []ThreadRecord `json:"threads"`
+	TotalCount int64          `json:"totalCount"`
+	Limit      int            `json:"limit"`
+	Offset     int            `json:"offset"` // deprecated, page with nextCursor
+	HasMore    bool           `json:"hasMore"`
+	NextCursor string         `json:"nextCursor,omitempty"`
+}
+
+type ThreadRecord struct {
+	ID             string                `json:"id"`
+	MailboxID      string                `json:"mailboxId"`
+	Subject        string                `json:"subject"`
+	Summary        string                `json:"summary,omitempty"`
+	Participants   []string              `json:"participants"`
+	HasAttachments bool                  `json:"hasAttachments"`
+	IsDone         bool                  `json:"isDone"`
+	IsViewed       bool                  `json:"isViewed"`
+	LastMessageAt  *time.Time            `json:"lastMessageAt,omitempty"`
+	LastMessage    *ThreadMessagePreview `json:"lastMessage,omitempty"`
+}
+
+type ThreadMessagePreview struct {
+	ID          string     `json:"id"`
+	FromName    string     `json:"fromName,omitempty"`
+	FromAddress string     `json:"fromAddress"`
+	Preview     string     `json:"preview"`
+	SentAt      *time.Time `json:"sentAt,omitempty"`
+}
+
+// GetThreads lists the threads of the tenant mailboxes, newest first unless order=asc.
+// Query params: mailboxIds (comma separated), isDone, isViewed, limit, cursor, order and the
+// deprecated offset. The next page is asked with the nextCursor of the response.
+func (h *ThreadsHandler) GetThreads() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "ThreadsHandler.GetThreads")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		tenant := utils.GetTenantFromContext(ctx)
+
+		filter, err := parseThreadFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		page, err := parsePagination(c)
+		if err == nil && page.after != nil && page.after.Descending == filter.OldestFirst {
+			// a cursor of the other order would continue from the wrong end
+			err = errInvalidQueryParam("cursor")
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		filter.After = page.after
+
+		// requested mailboxes are narrowed to the tenant ones, none requested means all of them
+		mailboxes, err := h.repos.MailboxRepository.GetMailboxesByTenant(ctx, tenant)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get mailboxes"})
+			return
+		}
+		var mailboxIDs []string
+		for _, mailbox := range mailboxes {
+			if len(filter.MailboxIDs) == 0 || slices.Contains(filter.MailboxIDs, mailbox.ID) {
+				mailboxIDs = append(mailboxIDs, mailbox.ID)
+			}
+		}
+
+		response := ThreadsResponse{
+			Threads: []ThreadRecord{},
+			Limit:   page.limit,
+			Offset:  page.offset,
+		}
+		if len(mailboxIDs) == 0 {
+			c.JSON(http.StatusOK, response)
+			return
+		}
+		filter.MailboxIDs = mailboxIDs
+
+		// one more than the page tells whether another page follows
+		threads, total, err := h.repos.EmailThreadRepository.ListByFilter(ctx, filter, page.limit+1, page.offset)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get threads"})
+			return
+		}
+		if len(threads) > page.limit {
+			threads = threads[:page.limit]
+			last := threads[len(threads)-1]
+			response.HasMore = true
+			response.NextCursor = utils.EncodeCursor(utils.Cursor{At: last.LastMessageAt, ID: last.ID, Descending: !filter.OldestFirst})
+		}
+
+		for _, thread := range threads {
+			response.Threads = append(response.Threads, h.threadRecord(ctx, thread))
+		}
+
+		response.TotalCount = total
+
+		span.LogFields(tracingLog.Int("result.count", len(threads)), tracingLog.Int64("result.total", total))
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+type ThreadDetail struct {
+	ThreadRecord
+	ParticipantRoles []ThreadParticipant `json:"participantRoles"`
+}
+
+// ThreadParticipant counts the emails of the thread an address sent, received or was copied on
+type ThreadParticipant struct {
+	Address string `json:"address"`
+	From    int    `json:"from"`
+	To      int    `json:"to"`
+	Cc      int    `json:"cc"`
+}
+
+// GetThread returns a thread with the roles its participants had, most active first
+func (h *ThreadsHandler) GetThread() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "ThreadsHandler.GetThread")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		threadID := c.Param("id")
+		span.LogFields(tracingLog.String("threadId", threadID))
+
+		thread, err := h.getTenantThread(ctx, utils.GetTenantFromContext(ctx), threadID)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get thread"})
+			return
+		}
+		if thread == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "thread not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, ThreadDetail{
+			ThreadRecord:     h.threadRecord(ctx, thread),
+			ParticipantRoles: threadParticipants(thread.ParticipantRoles),
+		})
+	}
+}
+
+type ThreadMessagesResponse struct {
+	Messages   []ThreadMessage `json:"messages"`
+	TotalCount int64           `json:"totalCount"`
+	Limit      int             `json:"limit"`
+	Offset     int             `json:"offset"` // deprecated, page with nextCursor
+	HasMore    bool            `json:"hasMore"`
+	NextCursor string          `json:"nextCursor,omitempty"`
+}
+
+type ThreadMessage struct {
+	ID                   string             `json:"id"`
+	MessageID            string             `json:"messageId"`
+	Direction            string             `json:"direction"`
+	Status               string             `json:"status"`
+	FromName             string             `json:"fromName,omitempty"`
+	FromAddress          string             `json:"fromAddress"`
+	ToAddresses          []string           `json:"toAddresses"`
+	CcAddresses          []string           `json:"ccAddresses"`
+	Subject              string             `json:"subject"`
+	BodyText             string             `json:"bodyText"`
+	BodyHTML             string             `json:"bodyHtml"`
+	BodyMarkdown         string             `json:"bodyMarkdown"`
+	Classification       string             `json:"classification,omitempty"`
+	ClassificationReason string             `json:"classificationReason,omitempty"`
+	SentAt               *time.Time         `json:"sentAt,omitempty"`
+	ReceivedAt           *time.Time         `json:"receivedAt,omitempty"`
+	Attachments          []ThreadAttachment `json:"attachments"`
+}
+
+type ThreadAttachment struct {
+	ID          string `json:"id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"contentType"`
+	Size        int    `json:"size"`
+	IsInline    bool   `json:"isInline"`
+	ContentID   string `json:"contentId,omitempty"`
+	ScanStatus  string `json:"scanStatus"`
+}
+
+// GetThreadMessages returns a page of the thread emails, oldest first. The next page is asked
+// with the nextCursor of the response as cursor query param, offset is deprecated. The thread is
+// marked viewed when markViewed=true.
+func (h *ThreadsHandler) GetThreadMessages() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "ThreadsHandler.GetThreadMessages")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		threadID := c.Param("id")
+		span.LogFields(tracingLog.String("threadId", threadID))
+
+		page, err := parsePagination(c)
+		if err == nil && page.after != nil && page.after.Descending {
+			err = errInvalidQueryParam("cursor")
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		markViewed, err := parseOptionalBool(c, "markViewed")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		thread, err := h.getTenantThread(ctx, utils.GetTenantFromContext(ctx), threadID)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get thread"})
+			return
+		}
+		if thread == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "thread not found"})
+			return
+		}
+
+		// one more than the page tells whether another page follows
+		var emails []*models.Email
+		var total int64
+		if page.offset > 0 {
+			emails, total, err = h.repos.EmailRepository.ListByThreadPaginated(ctx, thread.ID, page.limit+1, page.offset)
+		} else {
+			emails, total, err = h.repos.EmailRepository.ListByThreadAfter(ctx, thread.ID, page.after, page.limit+1)
+		}
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get messages"})
+			return
+		}
+
+		attachments, err := h.repos.EmailAttachmentRepository.ListByThread(ctx, thread.ID)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get attachments"})
+			return
+		}
+		attachmentsByEmail := make(map[string][]ThreadAttachment)
+		for _, attachment := range attachments {
+			for _, emailID := range attachment.Emails {
+				attachmentsByEmail[emailID] = append(attachmentsByEmail[emailID], ThreadAttachment{
+					ID:          attachment.ID,
+					Filename:    attachment.Filename,
+					ContentType: attachment.ContentType,
+					Size:        attachment.Size,
+					IsInline:    attachment.IsInline,
+					ContentID:   attachment.ContentID,
+					ScanStatus:  string(attachment.ScanStatus),
+				})
+			}
+		}
+
+		response := ThreadMessagesResponse{
+			Messages:   make([]ThreadMessage, 0, len(emails)),
+			TotalCount: total,
+			Limit:      page.limit,
+			Offset:     page.offset,
+		}
+		if len(emails) > page.limit {
+			emails = emails[:page.limit]
+			response.HasMore = true
+			response.NextCursor = utils.EncodeCursor(emailCursor(emails[len(emails)-1]))
+		}
+		for _, email := range emails {
+			message := ThreadMessage{
+				ID:                   email.ID,
+				MessageID:            email.MessageID,
+				Direction:            string(email.Direction),
+				Status:               string(email.Status),
+				FromName:             email.FromName,
+				FromAddress:          email.FromAddress,
+				ToAddresses:          nonNilStrings(email.ToAddresses),
+				CcAddresses:          nonNilStrings(email.CcAddresses),
+				Subject:              email.Subject,
+				BodyText:             email.BodyText,
+				BodyHTML:             email.BodyHTML,
+				BodyMarkdown:         email.BodyMarkdown,
+				Classification:       string(email.Classification),
+				ClassificationReason: email.ClassificationReason,
+				SentAt:               email.SentAt,
+				ReceivedAt:           email.ReceivedAt,
+				Attachments:          attachmentsByEmail[email.ID],
+			}
+			if message.Attachments == nil {
+				message.Attachments = []ThreadAttachment{}
+			}
+			response.Messages = append(response.Messages, message)
+		}
+
+		if markViewed != nil && *markViewed && !thread.IsViewed {
+			if err = h.repos.EmailThreadRepository.MarkThreadAsViewed(ctx, thread.ID); err != nil {
+				tracing.TraceErr(span, err)
+			} else {
+				viewed := true
+				h.publishThreadStateChanged(ctx, []*models.EmailThread{thread}, dto.ThreadStateChanged{IsViewed: &viewed})
+			}
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+type BulkMarkThreadsRequest struct {
+	ThreadIDs []string `json:"threadIds" binding:"required"`
+	// IsDone defaults to true, only used when marking threads as done
+	IsDone *bool `json:"isDone"`
+}
+
+type BulkMarkThreadsResponse struct {
+	Updated int64 `json:"updated"`
+}
+
+// MarkThreadsAsDone sets the done status of several threads at once
+func (h *ThreadsHandler) MarkThreadsAsDone() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "ThreadsHandler.MarkThreadsAsDone")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		threads, req, ok := h.bindBulkMarkRequest(ctx, c)
+		if !ok {
+			return
+		}
+		isDone := req.IsDone == nil || *req.IsDone
+		span.LogFields(tracingLog.Int("threadCount", len(threads)), tracingLog.Bool("isDone", isDone))
+
+		var changed []*models.EmailThread
+		for _, thread := range threads {
+			if thread.IsDone != isDone {
+				changed = append(changed, thread)
+			}
+		}
+
+		updated, err := h.repos.EmailThreadRepository.MarkThreadsAsDone(ctx, threadIDsOf(changed), isDone)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update threads"})
+			return
+		}
+		h.publishThreadStateChanged(ctx, changed, dto.ThreadStateChanged{IsDone: &isDone})
+
+		c.JSON(http.StatusOK, BulkMarkThreadsResponse{Updated: updated})
+	}
+}
+
+// MarkThreadsAsViewed marks several threads as viewed at once
+func (h *ThreadsHandler) MarkThreadsAsViewed() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "ThreadsHandler.MarkThreadsAsViewed")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		threads, _, ok := h.bindBulkMarkRequest(ctx, c)
+		if !ok {
+			return
+		}
+		span.LogFields(tracingLog.Int("threadCount", len(threads)))
+
+		var changed []*models.EmailThread
+		for _, thread := range threads {
+			if !thread.IsViewed {
+				changed = append(changed, thread)
+			}
+		}
+
+		updated, err := h.repos.EmailThreadRepository.MarkThreadsAsViewed(ctx, threadIDsOf(changed))
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update threads"})
+			return
+		}
+		viewed := true
+		h.publishThreadStateChanged(ctx, changed, dto.ThreadStateChanged{IsViewed: &viewed})
+
+		c.JSON(http.StatusOK, BulkMarkThreadsResponse{Updated: updated})
+	}
+}
+
+// DeleteThread moves a thread and its emails to the trash, they are purged after the retention period
+func (h *ThreadsHandler) DeleteThread() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "ThreadsHandler.DeleteThread")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		threadID := c.Param("id")
+		span.LogFields(tracingLog.String("threadId", threadID))
+
+		if _, err := h.emailService.DeleteThread(ctx, threadID); err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(trashErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// RestoreThread takes a thread out of the trash together with the emails deleted with it
+func (h *ThreadsHandler) RestoreThread() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "ThreadsHandler.RestoreThread")
+		defer span.Finish()
+		tracing.SetDefaultRestSpanTags(ctx, span)
+
+		threadID := c.Param("id")
+		span.LogFields(tracingLog.String("threadId", threadID))
+
+		thread, err := h.emailService.RestoreThread(ctx, threadID)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			c.JSON(trashErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, h.threadRecord(ctx, thread))
+	}
+}
+
+// bindBulkMarkRequest reads a bulk request and loads its threads, checking every one belongs to
+// a mailbox of the tenant. The error response is written when it returns false.
+func (h *ThreadsHandler) bindBulkMarkRequest(ctx context.Context, c *gin.Context) ([]*models.EmailThread, BulkMarkThreadsRequest, bool) {
+	span := opentracing.SpanFromContext(ctx)
+
+	var req BulkMarkThreadsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		tracing.TraceErr(span, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, req, false
+	}
+
+	var threadIDs []string
+	for _, id := range req.ThreadIDs {
+		if id = strings.TrimSpace(id); id != "" && !slices.Contains(threadIDs, id) {
+			threadIDs = append(threadIDs, id)
+		}
+	}
+	if len(threadIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "threadIds is required"})
+		return nil, req, false
+	}
+	if len(threadIDs) > maxBulkThreadIDs {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d threads can be updated at once", maxBulkThreadIDs)})
+		return nil, req, false
+	}
+
+	threads, foreign, err := h.getTenantThreads(ctx, utils.GetTenantFromContext(ctx), threadIDs)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get threads"})
+		return nil, req, false
+	}
+	if len(foreign) > 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "threads not found", "threadIds": foreign})
+		return nil, req, false
+	}
+
+	return threads, req, true
+}
+
+// getTenantThreads returns the threads of the tenant mailboxes and the IDs that do not exist
+// or belong to another tenant mailboxes
+func (h *ThreadsHandler) getTenantThreads(ctx context.Context, tenant string, threadIDs []string) ([]*models.EmailThread, []string, error) {
+	mailboxes, err := h.repos.MailboxRepository.GetMailboxesByTenant(ctx, tenant)
+	if err != nil {
+		return nil, nil, err
+	}
+	tenantMailboxes := make(map[string]bool, len(mailboxes))
+	for _, mailbox := range mailboxes {
+		tenantMailboxes[mailbox.ID] = true
+	}
+
+	threads, err := h.repos.EmailThreadRepository.GetByIDs(ctx, threadIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	owned := make(map[string]*models.EmailThread, len(threads))
+	for _, thread := range threads {
+		if tenantMailboxes[thread.MailboxID] {
+			owned[thread.ID] = thread
+		}
+	}
+
+	tenantThreads := make([]*models.EmailThread, 0, len(threadIDs))
+	foreign := []string{}
+	for _, id := range threadIDs {
+		if thread, ok := owned[id]; ok {
+			tenantThreads = append(tenantThreads, thread)
+		} else {
+			foreign = append(foreign, id)
+		}
+	}
+	return tenantThreads, foreign, nil
+}
+
+// publishThreadStateChanged notifies about threads whose state changed, one notification per mailbox
+func (h *ThreadsHandler) publishThreadStateChanged(ctx context.Context, threads []*models.EmailThread, change dto.ThreadStateChanged) {
+	if h.notifier == nil || len(threads) == 0 {
+		return
+	}
+	tenant := utils.GetTenantFromContext(ctx)
+
+	byMailbox := make(map[string][]string)
+	var mailboxIDs []string
+	for _, thread := range threads {
+		if _, ok := byMailbox[thread.MailboxID]; !ok {
+			mailboxIDs = append(mailboxIDs, thread.MailboxID)
+		}
+		byMailbox[thread.MailboxID] = append(byMailbox[thread.MailboxID], thread.ID)
+	}
+
+	for _, mailboxID := range mailboxIDs {
+		data := change
+		data.MailboxID = mailboxID
+		h.notifier.PublishNotificationBulk(ctx, tenant, byMailbox[mailboxID], enum.THREAD, utils.NewEventCompletedDetails().WithUpdate().WithData(data))
+	}
+}
+
+func threadIDsOf(threads []*models.EmailThread) []string {
+	ids := make([]string, 0, len(threads))
+	for _, thread := range threads {
+		ids = append(ids, thread.ID)
+	}
+	return ids
+}
+
+// threadRecord maps a thread for the API, with a preview of its last message
+func (h *ThreadsHandler) threadRecord(ctx context.Context, thread *models.EmailThread) ThreadRecord {
+	record := ThreadRecord{
+		ID:             thread.ID,
+		MailboxID:      thread.MailboxID,
+		Subject:        thread.Subject,
+		Summary:        thread.Summary,
+		Participants:   nonNilStrings(thread.Participants),
+		HasAttachments: thread.HasAttachments,
+		IsDone:         thread.IsDone,
+		IsViewed:       thread.IsViewed,
+		LastMessageAt:  thread.LastMessageAt,
+	}
+
+	if thread.LastMessageID != "" {
+		lastMessage, err := h.repos.EmailRepository.GetByMessageID(ctx, thread.LastMessageID)
+		if err != nil {
+			tracing.TraceErr(opentracing.SpanFromContext(ctx), err)
+		} else if lastMessage != nil {
+			record.LastMessage = threadMessagePreview(lastMessage)
+		}
+	}
+
+	return record
+}
+
+// threadParticipants orders the participants by the number of emails they appeared on
+func threadParticipants(roles models.ThreadParticipants) []ThreadParticipant {
+	participants := make([]ThreadParticipant, 0, len(roles))
+	for address, counts := range roles {
+		participants = append(participants, ThreadParticipant{Address: address, From: counts.From, To: counts.To, Cc: counts.Cc})
+	}
+	slices.SortFunc(participants, func(a, b ThreadParticipant) int {
+		if total := (b.From + b.To + b.Cc) - (a.From + a.To + a.Cc); total != 0 {
+			return total
+		}
+		return strings.Compare(a.Address, b.Address)
+	})
+	return participants
+}
+
+// getTenantThread returns the thread if it belongs to a mailbox of the tenant, nil otherwise
+func (h *ThreadsHandler) getTenantThread(ctx context.Context, tenant, threadID string) (*models.EmailThread, error) {
+	thread, err := h.repos.EmailThreadRepository.GetByID(ctx, threadID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	mailbox, err := h.repos.MailboxRepository.GetMailbox(ctx, thread.MailboxID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if mailbox == nil || mailbox.Tenant != tenant {
+		return nil, nil
+	}
+	return thread, nil
+}
+
+func trashErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, mailstack_errors.ErrThreadNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, mailstack_errors.ErrThreadNotDeleted):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+func parseThreadFilter(c *gin.Context) (interfaces.EmailThreadFilter, error) {
+	var filter interfaces.EmailThreadFilter
+
+	for _, id := range strings.Split(c.Query("mailboxIds"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			filter.MailboxIDs = append(filter.MailboxIDs, id)
+		}
+	}
+
+	var err error
+	if filter.IsDone, err = parseOptionalBool(c, "isDone"); err != nil {
+		return filter, err
+	}
+	if filter.IsViewed, err = parseOptionalBool(c, "isViewed"); err != nil {
+		return filter, err
+	}
+
+	switch strings.ToLower(c.DefaultQuery("order", "desc")) {
+	case "desc":
+	case "asc":
+		filter.OldestFirst = true
+	default:
+		return filter, errInvalidQueryParam("order")
+	}
+
+	return filter, nil
+}
+
+func parseOptionalBool(c *gin.Context, name string) (*bool, error) {
+	value, ok := c.GetQuery(name)
+	if !ok || value == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, errInvalidQueryParam(name)
+	}
+	return &parsed, nil
+}
+
+// pageRequest is a page of a listing, it continues after the cursor or, deprecated, skips offset rows
+type pageRequest struct {
+	limit  int
+	offset int
+	after  *utils.Cursor
+}
+
+func parsePagination(c *gin.Context) (pageRequest, error) {
+	var page pageRequest
+	var err error
+
+	page.limit, err = strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultThreadPageSize)))
+	if err != nil || page.limit < 1 || page.limit > maxThreadPageSize {
+		return page, errInvalidQueryParam("limit")
+	}
+	page.offset, err = strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || page.offset < 0 {
+		return page, errInvalidQueryParam("offset")
+	}
+	if token := c.Query("cursor"); token != "" {
+		if page.offset > 0 {
+			return page, errors.New("offset can not be combined with cursor")
+		}
+		if page.after, err = utils.DecodeCursor(token); err != nil {
+			return page, errInvalidQueryParam("cursor")
+		}
+	}
+	return page, nil
+}
+
+// emailCursor points after an email in the listing of a thread, ordered by when it was sent
+func emailCursor(email *models.Email) utils.Cursor {
+	at := email.SentAt
+	if at == nil {
+		at = email.ReceivedAt
+	}
+	return utils.Cursor{At: at, ID: email.ID}
+}
+
+func errInvalidQueryParam(name string) error {
+	return fmt.Errorf("invalid query parameter %s", name)
+}
+
+// threadMessagePreview shows the start of the new content of a message on a single line
+func threadMessagePreview(email *models.Email) *ThreadMessagePreview {
+	text := email.VisibleText
+	if strings.TrimSpace(text) == "" {
+		text = email.BodyText
+	}
+
+	return &ThreadMessagePreview{
+		ID:          email.ID,
+		FromName:    email.FromName,
+		FromAddress: email.FromAddress,
+		Preview:     truncatePreview(strings.Join(strings.Fields(text), " "), threadPreviewLength),
+		SentAt:      email.SentAt,
+	}
+}
+
+func truncatePreview(text string, length int) string {
+	if utf8.RuneCountInString(text) <= length {
+		return text
+	}
+	return strings.TrimSpace(string([]rune(text)[:length])) + "…"
+}
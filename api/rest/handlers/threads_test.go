@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/customeros/mailstack/dto"
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/enum"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/repository"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+type fakeMailboxRepository struct {
+	interfaces.MailboxRepository
+	mailboxes []*models.Mailbox
+}
+
+func (r *fakeMailboxRepository) GetMailboxesByTenant(_ context.Context, tenant string) ([]*models.Mailbox, error) {
+	var mailboxes []*models.Mailbox
+	for _, mailbox := range r.mailboxes {
+		if mailbox.Tenant == tenant {
+			mailboxes = append(mailboxes, mailbox)
+		}
+	}
+	return mailboxes, nil
+}
+
+func (r *fakeMailboxRepository) GetMailbox(_ context.Context, id string) (*models.Mailbox, error) {
+	for _, mailbox := range r.mailboxes {
+		if mailbox.ID == id {
+			return mailbox, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// fakeThreadRepository pages newest first like the keyset and offset queries of the repository
+type fakeThreadRepository struct {
+	interfaces.EmailThreadRepository
+	threads []*models.EmailThread
+	marked  [][]string
+}
+
+func (r *fakeThreadRepository) ListByFilter(_ context.Context, filter interfaces.EmailThreadFilter, limit, offset int) ([]*models.EmailThread, int64, error) {
+	threads := slices.Clone(r.threads)
+	newerFirst := func(a, b *models.EmailThread) int {
+		if c := b.LastMessageAt.Compare(*a.LastMessageAt); c != 0 {
+			return c
+		}
+		return strings.Compare(b.ID, a.ID)
+	}
+	slices.SortFunc(threads, newerFirst)
+
+	var page []*models.EmailThread
+	for i, thread := range threads {
+		if filter.After != nil {
+			if newerFirst(thread, &models.EmailThread{ID: filter.After.ID, LastMessageAt: filter.After.At}) <= 0 {
+				continue
+			}
+		} else if i < offset {
+			continue
+		}
+		if len(page) < limit {
+			page = append(page, thread)
+		}
+	}
+	return page, int64(len(threads)), nil
+}
+
+func (r *fakeThreadRepository) GetByIDs(_ context.Context, ids []string) ([]*models.EmailThread, error) {
+	var threads []*models.EmailThread
+	for _, thread := range r.threads {
+		if slices.Contains(ids, thread.ID) {
+			threads = append(threads, thread)
+		}
+	}
+	return threads, nil
+}
+
+func (r *fakeThreadRepository) MarkThreadsAsDone(_ context.Context, ids []string, isDone bool) (int64, error) {
+	r.marked = append(r.marked, ids)
+	for _, thread := range r.threads {
+		if slices.Contains(ids, thread.ID) {
+			thread.IsDone = isDone
+		}
+	}
+	return int64(len(ids)), nil
+}
+
+func (r *fakeThreadRepository) MarkThreadsAsViewed(_ context.Context, ids []string) (int64, error) {
+	r.marked = append(r.marked, ids)
+	for _, thread := range r.threads {
+		if slices.Contains(ids, thread.ID) {
+			thread.IsViewed = true
+		}
+	}
+	return int64(len(ids)), nil
+}
+
+type publishedNotification struct {
+	tenant string
+	ids    []string
+	data   dto.ThreadStateChanged
+}
+
+type fakeThreadNotifier struct {
+	published []publishedNotification
+}
+
+func (n *fakeThreadNotifier) PublishNotificationBulk(_ context.Context, tenant string, entityIds []string, _ enum.EntityType, details *utils.EventCompletedDetails) {
+	n.published = append(n.published, publishedNotification{tenant: tenant, ids: entityIds, data: details.Data.(dto.ThreadStateChanged)})
+}
+
+func TestGetThreadsPaging(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	start := time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)
+	threads := &fakeThreadRepository{}
+	addThread := func(id string, minutes int) {
+		at := start.Add(time.Duration(minutes) * time.Minute)
+		threads.threads = append(threads.threads, &models.EmailThread{ID: id, MailboxID: "mbox_1", LastMessageAt: &at})
+	}
+	for i, id := range []string{"thrd_a", "thrd_b", "thrd_c", "thrd_d", "thrd_e"} {
+		addThread(id, -i)
+	}
+	addThread("thrd_f", -4) // same time as thrd_e
+
+	handler := &ThreadsHandler{repos: &repository.Repositories{
+		EmailThreadRepository: threads,
+		MailboxRepository:     &fakeMailboxRepository{mailboxes: []*models.Mailbox{{ID: "mbox_1", Tenant: "acme"}}},
+	}}
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(utils.SetTenantInContext(c.Request.Context(), "acme"))
+	})
+	router.GET("/threads", handler.GetThreads())
+
+	get := func(query string) (int, ThreadsResponse) {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/threads?limit=2"+query, nil))
+		var response ThreadsResponse
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+		return recorder.Code, response
+	}
+	ids := func(response ThreadsResponse) []string {
+		var ids []string
+		for _, thread := range response.Threads {
+			ids = append(ids, thread.ID)
+		}
+		return ids
+	}
+
+	t.Run("new mail between pages neither shifts nor repeats threads", func(t *testing.T) {
+		code, first := get("")
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, []string{"thrd_a", "thrd_b"}, ids(first))
+		assert.True(t, first.HasMore)
+
+		addThread("thrd_new1", 5)
+		addThread("thrd_new2", 6)
+
+		_, second := get("&cursor=" + first.NextCursor)
+		assert.Equal(t, []string{"thrd_c", "thrd_d"}, ids(second))
+		_, third := get("&cursor=" + second.NextCursor)
+		assert.Equal(t, []string{"thrd_f", "thrd_e"}, ids(third))
+		assert.False(t, third.HasMore)
+		assert.Empty(t, third.NextCursor)
+		assert.Equal(t, int64(8), third.TotalCount)
+
+		// the deprecated offset repeats the threads pushed down by the new ones
+		_, byOffset := get("&offset=2")
+		assert.Equal(t, []string{"thrd_a", "thrd_b"}, ids(byOffset))
+	})
+
+	t.Run("invalid cursors", func(t *testing.T) {
+		_, first := get("")
+		for _, query := range []string{"&cursor=garbage", "&order=asc&cursor=" + first.NextCursor, "&offset=2&cursor=" + first.NextCursor} {
+			code, _ := get(query)
+			assert.Equal(t, http.StatusBadRequest, code, query)
+		}
+	})
+}
+
+func TestBulkMarkThreads(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	isTrue, isFalse := true, false
+
+	setup := func() (*gin.Engine, *fakeThreadRepository, *fakeThreadNotifier) {
+		threads := &fakeThreadRepository{threads: []*models.EmailThread{
+			{ID: "thrd_a", MailboxID: "mbox_1"},
+			{ID: "thrd_b", MailboxID: "mbox_1", IsDone: true, IsViewed: true},
+			{ID: "thrd_c", MailboxID: "mbox_2"},
+			{ID: "thrd_other", MailboxID: "mbox_other"},
+		}}
+		notifier := &fakeThreadNotifier{}
+		handler := &ThreadsHandler{
+			repos: &repository.Repositories{
+				EmailThreadRepository: threads,
+				MailboxRepository: &fakeMailboxRepository{mailboxes: []*models.Mailbox{
+					{ID: "mbox_1", Tenant: "acme"},
+					{ID: "mbox_2", Tenant: "acme"},
+					{ID: "mbox_other", Tenant: "other"},
+				}},
+			},
+			notifier: notifier,
+		}
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Request = c.Request.WithContext(utils.SetTenantInContext(c.Request.Context(), "acme"))
+		})
+		router.POST("/threads/done", handler.MarkThreadsAsDone())
+		router.POST("/threads/viewed", handler.MarkThreadsAsViewed())
+		return router, threads, notifier
+	}
+	post := func(router *gin.Engine, path, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return recorder
+	}
+
+	t.Run("only changed threads are updated and published", func(t *testing.T) {
+		router, threads, notifier := setup()
+
+		response := post(router, "/threads/done", `{"threadIds":["thrd_a","thrd_b"," thrd_c ","thrd_a"]}`)
+		require.Equal(t, http.StatusOK, response.Code)
+		assert.JSONEq(t, `{"updated":2}`, response.Body.String())
+		assert.Equal(t, [][]string{{"thrd_a", "thrd_c"}}, threads.marked)
+		assert.Equal(t, []publishedNotification{
+			{tenant: "acme", ids: []string{"thrd_a"}, data: dto.ThreadStateChanged{MailboxID: "mbox_1", IsDone: &isTrue}},
+			{tenant: "acme", ids: []string{"thrd_c"}, data: dto.ThreadStateChanged{MailboxID: "mbox_2", IsDone: &isTrue}},
+		}, notifier.published)
+
+		notifier.published = nil
+		response = post(router, "/threads/done", `{"threadIds":["thrd_a","thrd_b","thrd_c"],"isDone":false}`)
+		require.Equal(t, http.StatusOK, response.Code)
+		assert.JSONEq(t, `{"updated":3}`, response.Body.String())
+		assert.Len(t, notifier.published, 2)
+		assert.Equal(t, &isFalse, notifier.published[0].data.IsDone)
+		assert.Equal(t, []string{"thrd_a", "thrd_b"}, notifier.published[0].ids)
+
+		notifier.published = nil
+		response = post(router, "/threads/viewed", `{"threadIds":["thrd_a","thrd_b"]}`)
+		require.Equal(t, http.StatusOK, response.Code)
+		assert.JSONEq(t, `{"updated":1}`, response.Body.String())
+		assert.Equal(t, []publishedNotification{
+			{tenant: "acme", ids: []string{"thrd_a"}, data: dto.ThreadStateChanged{MailboxID: "mbox_1", IsViewed: &isTrue}},
+		}, notifier.published)
+	})
+
+	t.Run("unchanged threads publish nothing", func(t *testing.T) {
+		router, _, notifier := setup()
+
+		response := post(router, "/threads/viewed", `{"threadIds":["thrd_b"]}`)
+		require.Equal(t, http.StatusOK, response.Code)
+		assert.JSONEq(t, `{"updated":0}`, response.Body.String())
+		assert.Empty(t, notifier.published)
+	})
+
+	t.Run("foreign or missing threads are not found", func(t *testing.T) {
+		for _, path := range []string{"/threads/done", "/threads/viewed"} {
+			router, threads, notifier := setup()
+
+			response := post(router, path, `{"threadIds":["thrd_a","thrd_other","thrd_missing"]}`)
+			require.Equal(t, http.StatusNotFound, response.Code, path)
+			assert.JSONEq(t, `{"error":"threads not found","threadIds":["thrd_other","thrd_missing"]}`, response.Body.String())
+			assert.Empty(t, threads.marked, path)
+			assert.Empty(t, notifier.published, path)
+			assert.False(t, threads.threads[0].IsDone || threads.threads[0].IsViewed, path)
+		}
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		router, threads, _ := setup()
+		tooMany := make([]string, maxBulkThreadIDs+1)
+		for i := range tooMany {
+			tooMany[i] = fmt.Sprintf("thrd_%d", i)
+		}
+		body, err := json.Marshal(BulkMarkThreadsRequest{ThreadIDs: tooMany})
+		require.NoError(t, err)
+
+		for _, body := range []string{`not json`, `{}`, `{"threadIds":[]}`, `{"threadIds":[" ",""]}`, string(body)} {
+			response := post(router, "/threads/done", body)
+			assert.Equal(t, http.StatusBadRequest, response.Code, body)
+		}
+		assert.Empty(t, threads.marked)
+	})
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"time"
 
 	"github.com/99designs/gqlgen/graphql/handler"
 	"github.com/99designs/gqlgen/graphql/handler/extension"
@@ -10,6 +11,8 @@ import (
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/gin-gonic/gin"
 	"github.com/opentracing/opentracing-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/vektah/gqlparser/v2/ast"
 
 	"github.com/customeros/mailstack/api/graphql/generated"
@@ -17,9 +20,11 @@ import (
 	"github.com/customeros/mailstack/api/middleware"
 	"github.com/customeros/mailstack/api/rest/handlers"
 	"github.com/customeros/mailstack/internal/config"
+	"github.com/customeros/mailstack/internal/enum"
 	"github.com/customeros/mailstack/internal/repository"
 	"github.com/customeros/mailstack/internal/tracing"
 	"github.com/customeros/mailstack/services"
+	"github.com/customeros/mailstack/services/events"
 )
 
 // RegisterRoutes sets up all API endpoints
@@ -38,10 +43,21 @@ func RegisterRoutes(ctx context.Context, r *gin.Engine, s *services.Services, re
 	// setup handlers
 	apiHandlers := handlers.InitHandlers(repos, cfg, s)
 
+	// Replays the stored response to retried purchases and mailbox setups sent with an Idempotency-Key
+	idempotent := middleware.IdempotencyMiddleware(repos.IdempotencyKeyRepository,
+		time.Duration(cfg.IdempotencyConfig.KeyTTLHours)*time.Hour)
+
 	// Health check and status endpoints (no custom context needed)
 	r.GET("/health", handlers.HealthCheck)
+	r.GET("/health/mailboxes", handlers.MailboxesHealth(s.IMAPService))
 	r.GET("/status", handlers.Status(s.IMAPService))
 
+	// Prometheus metrics, the dead letter queue depths are read from RabbitMQ on every scrape
+	if s.EventsService != nil && s.EventsService.Publisher != nil {
+		prometheus.MustRegister(events.NewDLQCollector(s.EventsService.Publisher))
+	}
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
 	apiKeyMiddleware := middleware.APIKeyMiddleware(middleware.APIKeyConfig{
 		HeaderName:  "X-CUSTOMER-OS-API-KEY",
 		ValidAPIKey: cfg.AppConfig.APIKey,
@@ -77,17 +93,29 @@ func RegisterRoutes(ctx context.Context, r *gin.Engine, s *services.Services, re
 		{
 			// Domain discovery and acquisition
 			domains.GET("/check-availability/:domain", apiHandlers.Domains.CheckAvailability())
+			domains.POST("/check-availability", apiHandlers.Domains.CheckDomainsAvailability())
 			domains.GET("/recommendations", apiHandlers.Domains.GetRecommendations())
+			domains.GET("/price/:domain", apiHandlers.Domains.GetDomainPrice())
+			domains.GET("/currency", apiHandlers.Domains.GetTenantCurrency())
+			domains.PUT("/currency", apiHandlers.Domains.SetTenantCurrency())
 
 			// Domain registration and configuration
-			domains.POST("/purchase", apiHandlers.Domains.PurchaseDomain())
+			domains.POST("/purchase", idempotent, apiHandlers.Domains.PurchaseDomain())
 			domains.POST("/configure", apiHandlers.Domains.ConfigureDomain())
-			domains.POST("", apiHandlers.Domains.RegisterNewDomain()) // Combined purchase + configure
+			domains.POST("", idempotent, apiHandlers.Domains.RegisterNewDomain()) // Combined purchase + configure
+			domains.PUT("/:domain/auto-renew", apiHandlers.Domains.SetAutoRenew())
+
+			// Aliases and catch-all
+			domains.GET("/:domain/aliases", apiHandlers.Domains.GetAliases())
+			domains.POST("/:domain/aliases", apiHandlers.Domains.SetupAlias())
+			domains.DELETE("/:domain/aliases/:alias", apiHandlers.Domains.RemoveAlias())
+			domains.PUT("/:domain/catch-all", apiHandlers.Domains.SetCatchAll())
 
 			// DNS management
 			domains.POST("/:domain/dns", apiHandlers.DNS.AddDNSRecord())
 			domains.GET("/:domain/dns", apiHandlers.DNS.GetDNSRecords())
 			domains.DELETE("/:domain/dns/:id", apiHandlers.DNS.DeleteDNSRecord())
+			domains.GET("/:domain/dns/verify", apiHandlers.DNS.VerifyDNS())
 
 			// Domain listing
 			domains.GET("", apiHandlers.Domains.GetDomains())
@@ -100,23 +128,80 @@ func RegisterRoutes(ctx context.Context, r *gin.Engine, s *services.Services, re
 		mailboxes.Use(middleware.TracingMiddleware(ctx))    // Add tracing with parent context
 		{
 			mailboxes.GET("", apiHandlers.Mailbox.GetMailboxes())
-			mailboxes.POST("", apiHandlers.Mailbox.RegisterNewMailbox())
+			mailboxes.POST("", idempotent, apiHandlers.Mailbox.ProvisionMailbox()) // OpenSRS setup + mailbox + IMAP sync
+			mailboxes.PATCH("/:id", apiHandlers.Mailbox.UpdateMailbox())           // sync folders, poll interval and imap credentials
 			mailboxes.POST("/:id/configure", apiHandlers.Mailbox.ConfigureMailbox())
+			mailboxes.POST("/:id/resync", apiHandlers.Mailbox.ResyncMailbox())
+			mailboxes.GET("/:id/status", apiHandlers.Mailbox.GetMailboxStatus())
+			mailboxes.GET("/:id/quota", apiHandlers.Mailbox.GetMailboxQuota())
+			mailboxes.GET("/:id/mail-auth", apiHandlers.Mailbox.CheckMailAuthAlignment())
+			mailboxes.GET("/:id/folders", apiHandlers.Mailbox.GetMailboxFolders())
+			mailboxes.POST("/:id/folders", apiHandlers.Mailbox.CreateMailboxFolder())
+			mailboxes.DELETE("/:id/folders", apiHandlers.Mailbox.DeleteMailboxFolder()) // folder given with the name query param
+			mailboxes.POST("/:id/import", apiHandlers.Mailbox.ImportMessages())         // multipart upload of eml, mbox or zip files
 			mailboxes.GET("/by-email/:email", apiHandlers.Mailbox.GetMailboxByEmail())
+			mailboxes.GET("/by-email/:email/usage", apiHandlers.Mailbox.GetMailboxUsage())
+			mailboxes.DELETE("/by-email/:email", apiHandlers.Mailbox.DeleteMailbox())
+			mailboxes.PUT("/by-email/:email/password", apiHandlers.Mailbox.ChangeMailboxPassword())
 		}
 
 		// Dmarc endpoints
 		dmarc := api.Group("/dmarc")
 		{
 			dmarc.POST("", apiHandlers.Postmark.PostmarkDMARCMonitor())
+			dmarc.POST("/reports", apiHandlers.DMARC.UploadAggregateReport())
 		}
 
 		// Email endpoints
 		emails := api.Group("/emails")
+		emails.Use(middleware.TenantValidationMiddleware())
+		emails.Use(middleware.CustomContextMiddleware()) // Add custom context
+		emails.Use(middleware.TracingMiddleware(ctx))    // Add tracing with parent context
+		{
+			emails.GET("/:id", nil)                                                               // get specific email
+			emails.POST("/:id/reply", apiHandlers.Emails.Reply(enum.ReplyModeReply))              // reply to an email
+			emails.POST("/:id/replyall", apiHandlers.Emails.Reply(enum.ReplyModeReplyAll))        // reply-all to an email
+			emails.POST("/:id/forward", apiHandlers.Emails.Reply(enum.ReplyModeForward))          // forward an email
+			emails.GET("/:id/preview", apiHandlers.Emails.Preview())                              // render without sending
+			emails.GET("/:id/raw", apiHandlers.Emails.Raw())                                      // original RFC 822 source
+			emails.GET("/:id/attachments/:attachmentId", apiHandlers.Emails.DownloadAttachment()) // download an attachment
+			emails.DELETE("/:id", apiHandlers.Emails.Delete())                                    // move to the trash
+			emails.POST("/:id/restore", apiHandlers.Emails.Restore())                             // take out of the trash
+		}
+
+		// Thread endpoints
+		threads := api.Group("/threads")
+		threads.Use(middleware.TenantValidationMiddleware())
+		threads.Use(middleware.CustomContextMiddleware()) // Add custom context
+		threads.Use(middleware.TracingMiddleware(ctx))    // Add tracing with parent context
+		{
+			threads.GET("", apiHandlers.Threads.GetThreads())
+			threads.GET("/:id", apiHandlers.Threads.GetThread())
+			threads.GET("/:id/messages", apiHandlers.Threads.GetThreadMessages())
+			threads.POST("/done", apiHandlers.Threads.MarkThreadsAsDone())
+			threads.POST("/viewed", apiHandlers.Threads.MarkThreadsAsViewed())
+			threads.DELETE("/:id", apiHandlers.Threads.DeleteThread())        // move to the trash with its emails
+			threads.POST("/:id/restore", apiHandlers.Threads.RestoreThread()) // take out of the trash with its emails
+		}
+
+		// Retention policy endpoints
+		retention := api.Group("/retention-policy")
+		retention.Use(middleware.TenantValidationMiddleware())
+		retention.Use(middleware.CustomContextMiddleware()) // Add custom context
+		retention.Use(middleware.TracingMiddleware(ctx))    // Add tracing with parent context
+		{
+			retention.GET("", apiHandlers.Retention.GetRetentionPolicy())
+			retention.PUT("", apiHandlers.Retention.SetRetentionPolicy())
+			retention.DELETE("", apiHandlers.Retention.DeleteRetentionPolicy()) // keep mail forever
+		}
+
+		// Audit log of domain and mailbox changes
+		auditLog := api.Group("/audit-log")
+		auditLog.Use(middleware.TenantValidationMiddleware())
+		auditLog.Use(middleware.CustomContextMiddleware()) // Add custom context
+		auditLog.Use(middleware.TracingMiddleware(ctx))    // Add tracing with parent context
 		{
-			emails.GET("/:id", nil)           // get specific email
-			emails.POST("/:id/replyall", nil) // reply-all to an email
-			emails.POST("/:id/forward", nil)  // forward an email
+			auditLog.GET("", apiHandlers.AuditLog.ListAuditLog()) // ?from=&to=&limit=&cursor=
 		}
 
 		attachments := api.Group("/attachments")
@@ -125,6 +210,16 @@ func RegisterRoutes(ctx context.Context, r *gin.Engine, s *services.Services, re
 			attachments.GET("/:id", nil) // get attachment
 		}
 
+		// Admin endpoints
+		admin := api.Group("/admin")
+		admin.Use(middleware.TracingMiddleware(ctx))
+		{
+			admin.POST("/dlq/:queue/replay", apiHandlers.Admin.ReplayDLQ())                   // replay a dead letter queue
+			admin.POST("/threads/:id/merge", apiHandlers.Admin.MergeThreads())                // merge threads into this one
+			admin.POST("/threads/:id/split", apiHandlers.Admin.SplitThread())                 // split emails off into a new thread
+			admin.POST("/threads/:id/recompute", apiHandlers.Admin.RecomputeThreadMetadata()) // correct drifted thread metadata
+		}
+
 		drafts := api.Group("/drafts")
 		{
 			drafts.POST("", nil)          // create a new draft
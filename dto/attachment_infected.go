@@ -0,0 +1,8 @@
+package dto
+
+type AttachmentInfected struct {
+	AttachmentID string
+	EmailID      string
+	Filename     string
+	Signature    string
+}
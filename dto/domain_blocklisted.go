@@ -0,0 +1,7 @@
+package dto
+
+type DomainBlocklisted struct {
+	Domain      string
+	NewListings []string // "<zone>:<ip or domain>"
+	AllListings []string
+}
@@ -0,0 +1,11 @@
+package dto
+
+import "time"
+
+type DomainExpiring struct {
+	Domain    string
+	ExpiresAt time.Time
+	DaysLeft  int
+	Threshold int // notification threshold in days that was crossed
+	AutoRenew bool
+}
@@ -0,0 +1,9 @@
+package dto
+
+import "time"
+
+type DomainRenewed struct {
+	Domain    string
+	Years     int
+	ExpiresAt *time.Time // new expiration date, nil if the registrar did not return one
+}
@@ -0,0 +1,16 @@
+package dto
+
+import "time"
+
+type EmailAutoResponded struct {
+	MailboxID         string
+	MessageID         string
+	FromAddress       string
+	FromName          string
+	Subject           string
+	Reason            string
+	OriginalEmailID   string // outbound email the auto-reply answers, empty when unknown
+	OriginalRecipient string
+	OutOfOfficeFrom   *time.Time
+	OutOfOfficeUntil  *time.Time // last day away or return date, as stated in the reply
+}
@@ -0,0 +1,10 @@
+package dto
+
+type EmailBounced struct {
+	EmailID          string
+	MessageID        string
+	MailboxID        string
+	FailedRecipients []string
+	Status           string
+	DiagnosticCode   string
+}
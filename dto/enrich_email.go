@@ -0,0 +1,6 @@
+package dto
+
+// EnrichEmail asks for the AI structured body of a stored inbound email
+type EnrichEmail struct {
+	EmailID string
+}
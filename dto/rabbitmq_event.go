@@ -31,4 +31,5 @@ type EventCompleted struct {
 	Create     bool            `json:"create"`
 	Update     bool            `json:"update"`
 	Delete     bool            `json:"delete"`
+	Data       interface{}     `json:"data,omitempty"`
 }
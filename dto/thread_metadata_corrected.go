@@ -0,0 +1,9 @@
+package dto
+
+// ThreadMetadataCorrected is the data of the update notification sent when the metadata of a
+// thread is recomputed from its emails and differed from the stored one
+type ThreadMetadataCorrected struct {
+	MailboxID string   `json:"mailboxId"`
+	ThreadID  string   `json:"threadId"`
+	Fields    []string `json:"fields"`
+}
@@ -0,0 +1,18 @@
+package dto
+
+// ThreadsMerged is the data of the notifications sent when threads are merged: an update of the
+// target thread and a delete of the source threads, whose emails it now holds
+type ThreadsMerged struct {
+	MailboxID       string   `json:"mailboxId"`
+	ThreadID        string   `json:"threadId"`
+	SourceThreadIDs []string `json:"sourceThreadIds"`
+}
+
+// ThreadSplit is the data of the notifications sent when emails are split off a thread: an update
+// of the thread and a create of the new thread holding the moved emails
+type ThreadSplit struct {
+	MailboxID   string   `json:"mailboxId"`
+	ThreadID    string   `json:"threadId"`
+	NewThreadID string   `json:"newThreadId"`
+	EmailIDs    []string `json:"emailIds"`
+}
@@ -0,0 +1,9 @@
+package dto
+
+// ThreadStateChanged is the data of the notification sent when threads of a mailbox are marked
+// viewed or done, the thread IDs are the entity IDs of the notification. Only the changed state is set.
+type ThreadStateChanged struct {
+	MailboxID string `json:"mailboxId"`
+	IsViewed  *bool  `json:"isViewed,omitempty"`
+	IsDone    *bool  `json:"isDone,omitempty"`
+}
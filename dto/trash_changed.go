@@ -0,0 +1,10 @@
+package dto
+
+// TrashChanged is the data of the notifications sent when a thread or an email is deleted or
+// restored. EmailID is empty when the whole thread moved.
+type TrashChanged struct {
+	MailboxID string `json:"mailboxId"`
+	ThreadID  string `json:"threadId"`
+	EmailID   string `json:"emailId,omitempty"`
+	Deleted   bool   `json:"deleted"`
+}
@@ -0,0 +1,13 @@
+package interfaces
+
+import "context"
+
+type AttachmentScanner interface {
+	Scan(ctx context.Context, data []byte) (*ScanResult, error)
+}
+
+type ScanResult struct {
+	Skipped   bool // no scanner configured
+	Infected  bool
+	Signature string
+}
@@ -0,0 +1,21 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/customeros/mailstack/internal/enum"
+)
+
+type AuditService interface {
+	Record(ctx context.Context, entry AuditEntry)
+}
+
+// AuditEntry is a mutation of a domain or mailbox. Before and After summarize the changed
+// settings of the target, they must not hold passwords or other secrets.
+type AuditEntry struct {
+	Action     enum.AuditAction
+	TargetType enum.AuditTarget
+	TargetID   string
+	Before     map[string]interface{}
+	After      map[string]interface{}
+}
@@ -0,0 +1,23 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+type AuditLogRepository interface {
+	Create(ctx context.Context, entry *models.AuditLog) error
+	List(ctx context.Context, filter AuditLogFilter, limit int) ([]*models.AuditLog, error)
+}
+
+// AuditLogFilter selects the audit log of a tenant, newest first. From and To bound the creation
+// time, From inclusive and To exclusive, nil leaves the side open.
+type AuditLogFilter struct {
+	Tenant string
+	From   *time.Time
+	To     *time.Time
+	After  *utils.Cursor
+}
@@ -11,9 +11,10 @@ type CloudflareService interface {
 }
 
 type DNSRecord struct {
-	ID      string `json:"id"`
-	ZoneID  string `json:"zone_id"`
-	Name    string `json:"zone_name"`
-	Type    string `json:"type"`
-	Content string `json:"content"`
+	ID         string `json:"id"`
+	ZoneID     string `json:"zone_id"`
+	Name       string `json:"zone_name"`
+	RecordName string `json:"name"` // fully qualified record name, e.g. _dmarc.example.com
+	Type       string `json:"type"`
+	Content    string `json:"content"`
 }
@@ -3,6 +3,7 @@ package interfaces
 import (
 	"context"
 
+	"github.com/customeros/mailstack/internal/enum"
 	"github.com/customeros/mailstack/internal/models"
 )
 
@@ -10,5 +11,53 @@ type DomainService interface {
 	ConfigureDomain(ctx context.Context, domain, redirectWebsite string) error
 	GetDomain(ctx context.Context, domain string) (*models.MailStackDomain, error)
 	CheckMailstackDomainReputations(ctx context.Context) error
+	RenewExpiringDomains(ctx context.Context) error
+	NotifyExpiringDomains(ctx context.Context) error
+	ReconcilePurchasedDomains(ctx context.Context) (int, error)
+	ReconcileDomainOwnership(ctx context.Context) (DomainOwnershipReport, error)
 	GetTenantForMailstackDomain(ctx context.Context, domain string) (string, error)
+	VerifyDNS(ctx context.Context, domain string) ([]DNSRecordVerification, error)
+	ProcessDMARCReport(ctx context.Context, data []byte, provider string) (int, error)
+	CheckMailAuthAlignment(ctx context.Context, mailbox *models.Mailbox) (*MailAuthAlignmentReport, error)
+}
+
+// DNSRecordVerification compares what public DNS serves for a record with what we configured
+type DNSRecordVerification struct {
+	Type     string               `json:"type"`
+	Name     string               `json:"name"`
+	Expected string               `json:"expected"`
+	Found    []string             `json:"found"`
+	Status   enum.DNSRecordStatus `json:"status"`
+}
+
+// MailAuthAlignmentReport tells whether mail sent from a mailbox authenticates and aligns with
+// its From domain, so it passes DMARC at the receiver
+type MailAuthAlignmentReport struct {
+	MailboxID    string        `json:"mailboxId"`
+	HeaderFrom   string        `json:"headerFrom"`
+	EnvelopeFrom string        `json:"envelopeFrom"`
+	SendingHost  string        `json:"sendingHost"`
+	SPF          MailAuthCheck `json:"spf"`
+	DKIM         MailAuthCheck `json:"dkim"`
+	DMARC        MailAuthCheck `json:"dmarc"`
+	PassesDMARC  bool          `json:"passesDmarc"`
+	Warnings     []string      `json:"warnings"`
+}
+
+// MailAuthCheck is the result of one mechanism. Aligned is set when the domain it authenticated
+// matches the From domain the way the DMARC policy requires.
+type MailAuthCheck struct {
+	Status  enum.MailAuthStatus `json:"status"`
+	Record  string              `json:"record,omitempty"`
+	Domain  string              `json:"domain,omitempty"`
+	Aligned bool                `json:"aligned"`
+	Detail  string              `json:"detail,omitempty"`
+}
+
+// DomainOwnershipReport lists the differences between the registrar and the stored domains
+type DomainOwnershipReport struct {
+	Created     []string `json:"created"`     // owned at the registrar, row created for the purchasing tenant
+	Deactivated []string `json:"deactivated"` // stored as active, no longer owned
+	Unassigned  []string `json:"unassigned"`  // owned at the registrar, no tenant known
+	Failed      []string `json:"failed"`
 }
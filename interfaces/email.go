@@ -2,6 +2,7 @@ package interfaces
 
 import (
 	"context"
+	"time"
 
 	"github.com/customeros/mailstack/internal/enum"
 	"github.com/customeros/mailstack/internal/models"
@@ -9,7 +10,86 @@ import (
 
 type EmailService interface {
 	ScheduleSend(ctx context.Context, email *models.Email, attachmentIDs []string) (string, enum.EmailStatus, error)
+	ScheduleReply(ctx context.Context, originalEmailID string, mode enum.ReplyMode, email *models.Email, attachmentIDs []string) (string, enum.EmailStatus, error)
+	CancelScheduledSend(ctx context.Context, emailID string) error
+
+	GetSendQuota(ctx context.Context, mailbox *models.Mailbox) (*SendQuota, error)
+	PreviewEmail(ctx context.Context, emailID string) (*EmailPreview, error)
+	GetRawEmail(ctx context.Context, emailID string) ([]byte, error)
+
+	// corrections of automatic threading
+	MergeThreads(ctx context.Context, sourceIDs []string, targetID string) (*models.EmailThread, error)
+	SplitThread(ctx context.Context, threadID string, emailIDs []string) (*models.EmailThread, error)
+	RecomputeThreadMetadata(ctx context.Context, threadID string) (*ThreadMetadataCorrection, error)
+
+	// trash, deleted threads and emails are kept until purged
+	DeleteThread(ctx context.Context, threadID string) (*models.EmailThread, error)
+	RestoreThread(ctx context.Context, threadID string) (*models.EmailThread, error)
+	DeleteEmail(ctx context.Context, emailID string) error
+	RestoreEmail(ctx context.Context, emailID string) (*models.Email, error)
+
+	// used only by cron
+	DispatchScheduled(ctx context.Context) error
+	RecomputeThreadsMetadata(ctx context.Context) (ThreadMetadataReport, error)
+	PurgeDeleted(ctx context.Context, before time.Time) (PurgeReport, error)
+	EnforceRetention(ctx context.Context) (RetentionReport, error)
 
 	// used only by events
 	SendWithSMTP(ctx context.Context, mailbox *models.Mailbox, email *models.Email, attachments []*models.EmailAttachment) error
+
+	// closes the pooled SMTP sessions on shutdown
+	Close()
+}
+
+// SendQuota is the daily send limit of a mailbox and how much of it is used on the current
+// UTC day. A DailyLimit of 0 means the mailbox is not limited.
+type SendQuota struct {
+	Date       time.Time `json:"date"`
+	DailyLimit int       `json:"dailyLimit"`
+	Sent       int       `json:"sent"`
+	Remaining  int       `json:"remaining"`
+	WarmingUp  bool      `json:"warmingUp"`
+	ResetsAt   time.Time `json:"resetsAt"`
+}
+
+// EmailPreview is an outbound email rendered exactly as it would be sent, headers, envelope and
+// MIME body structure included
+type EmailPreview struct {
+	EmailID       string            `json:"emailId"`
+	Headers       map[string]string `json:"headers"`
+	Envelope      models.JSONMap    `json:"envelope"`
+	BodyStructure models.JSONMap    `json:"bodyStructure"`
+	Recipients    []string          `json:"recipients"`
+	Raw           string            `json:"raw"`
+	SizeBytes     int               `json:"sizeBytes"`
+}
+
+// ThreadMetadataCorrection lists the metadata fields of a thread that had drifted from its emails
+// and were corrected, none when the thread was consistent
+type ThreadMetadataCorrection struct {
+	ThreadID string   `json:"threadId"`
+	Fields   []string `json:"fields"`
+}
+
+// ThreadMetadataReport is the outcome of recomputing the metadata of all threads
+type ThreadMetadataReport struct {
+	Checked   int                        `json:"checked"`
+	Corrected []ThreadMetadataCorrection `json:"corrected"`
+	Failed    []string                   `json:"failed"`
+}
+
+// PurgeReport counts the rows and storage objects permanently removed by a purge. The reclaimed
+// bytes are those of the attachment files and of the stored sources whose size was recorded.
+type PurgeReport struct {
+	Emails         int   `json:"emails"`
+	Threads        int64 `json:"threads"`
+	Attachments    int   `json:"attachments"`
+	StorageObjects int   `json:"storageObjects"`
+	BytesReclaimed int64 `json:"bytesReclaimed"`
+}
+
+// RetentionReport is the outcome of enforcing the retention policies, per tenant
+type RetentionReport struct {
+	Tenants map[string]PurgeReport `json:"tenants"`
+	Failed  []string               `json:"failed"`
 }
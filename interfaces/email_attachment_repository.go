@@ -11,6 +11,9 @@ type EmailAttachmentRepository interface {
 	ListByEmail(ctx context.Context, emailID string) ([]*models.EmailAttachment, error)
 	ListByThread(ctx context.Context, threadID string) ([]*models.EmailAttachment, error)
 	Store(ctx context.Context, attachment *models.EmailAttachment, threadID, emailID string, data []byte) error
+	StoreMetadata(ctx context.Context, attachment *models.EmailAttachment, threadID, emailID string) error
+	StorePreview(ctx context.Context, attachment *models.EmailAttachment, preview []byte) error
 	DownloadAttachment(ctx context.Context, id string) ([]byte, error)
 	Delete(ctx context.Context, id string) error
+	DeleteFiles(ctx context.Context, attachment *models.EmailAttachment) error
 }
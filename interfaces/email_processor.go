@@ -13,7 +13,12 @@ type EmailProcessor interface {
 	NewAttachmentFile(attachmentID string, data []byte) *AttachmentFile
 
 	ProcessEmail(ctx context.Context, email *models.Email, attachments []*models.EmailAttachment, files []*AttachmentFile) error
-	EmailFilter(ctx context.Context, email *models.Email) error
+	EmailFilter(ctx context.Context, email *models.Email, rawMessage []byte) error
+	ProcessBounce(ctx context.Context, email *models.Email, rawMessage []byte) error
+	ProcessAutoResponder(ctx context.Context, email *models.Email) error
+	MatchSentCopy(ctx context.Context, email *models.Email) (bool, error)
+	EnrichEmail(ctx context.Context, emailID string) error
+	StoreRawMessage(ctx context.Context, emailID string, rawMessage []byte) error
 }
 
 type IMAPProcessor interface {
@@ -0,0 +1,11 @@
+package interfaces
+
+import "context"
+
+// EmailRawRepository stores the original RFC 822 source of emails in attachment storage
+type EmailRawRepository interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Save stores the source of an email and returns its key, saving again replaces it
+	Save(ctx context.Context, emailID string, raw []byte) (string, error)
+	Delete(ctx context.Context, key string) error
+}
@@ -2,8 +2,11 @@ package interfaces
 
 import (
 	"context"
+	"time"
 
+	"github.com/customeros/mailstack/internal/enum"
 	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/utils"
 )
 
 type EmailRepository interface {
@@ -11,10 +14,34 @@ type EmailRepository interface {
 	GetByID(ctx context.Context, id string) (*models.Email, error)
 	GetByUID(ctx context.Context, mailboxID, folder string, uid uint32) (*models.Email, error)
 	GetByMessageID(ctx context.Context, messageID string) (*models.Email, error)
+	GetOutboundByMessageID(ctx context.Context, mailboxID, messageID string) (*models.Email, error)
 	ListByMailbox(ctx context.Context, mailboxID string, limit, offset int) ([]*models.Email, int64, error)
 	ListByFolder(ctx context.Context, mailboxID, folder string, limit, offset int) ([]*models.Email, int64, error)
 	ListByThread(ctx context.Context, threadID string) ([]*models.Email, error)
+	// Deprecated: offsets skip or repeat emails when mail arrives while paging, use ListByThreadAfter
+	ListByThreadPaginated(ctx context.Context, threadID string, limit, offset int) ([]*models.Email, int64, error)
+	ListByThreadAfter(ctx context.Context, threadID string, after *utils.Cursor, limit int) ([]*models.Email, int64, error)
 	Search(ctx context.Context, query string, limit, offset int) ([]*models.Email, int64, error)
+	ListDueScheduled(ctx context.Context, before time.Time, limit int) ([]*models.Email, error)
+	ListSentAtSince(ctx context.Context, mailboxID string, since time.Time) ([]time.Time, error)
+	TransitionStatus(ctx context.Context, emailID string, from, to enum.EmailStatus) (bool, error)
 	Update(ctx context.Context, email *models.Email) error
 	SetEmailRawData(ctx context.Context, emailID string, headers, envelope, bodyStructure models.JSONMap) error
+	SetStructuredBody(ctx context.Context, emailID string, hasSignature bool, bodyMarkdown string) error
+	SetRawKey(ctx context.Context, emailID, rawKey string) error
+	GetByIDIncludingDeleted(ctx context.Context, id string) (*models.Email, error)
+	// SoftDelete and Restore report whether the thread of the email was deleted or restored along
+	SoftDelete(ctx context.Context, emailID string) (bool, error)
+	Restore(ctx context.Context, emailID string) (bool, error)
+	PurgeDeleted(ctx context.Context, before time.Time, limit int) (*PurgedEmails, error)
+	PurgeExpired(ctx context.Context, tenant string, before time.Time, limit int) (*PurgedEmails, error)
+}
+
+// PurgedEmails are the rows removed by a purge: the emails, the threads left without emails and
+// the attachments no longer referenced. The stored sources and attachment files are deleted by
+// the caller, only once the rows are gone.
+type PurgedEmails struct {
+	Emails      []*models.Email
+	Threads     int64
+	Attachments []*models.EmailAttachment
 }
@@ -2,18 +2,43 @@ package interfaces
 
 import (
 	"context"
+	"time"
 
 	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/utils"
 )
 
+// EmailThreadFilter selects threads of the given mailboxes, nil flags match any value. After
+// continues the listing after the last thread of the previous page.
+type EmailThreadFilter struct {
+	MailboxIDs  []string
+	IsDone      *bool
+	IsViewed    *bool
+	OldestFirst bool
+	After       *utils.Cursor
+}
+
 type EmailThreadRepository interface {
 	Create(ctx context.Context, thread *models.EmailThread) (string, error)
 	GetByID(ctx context.Context, id string) (*models.EmailThread, error)
+	GetByIDs(ctx context.Context, ids []string) ([]*models.EmailThread, error)
 	GetByMailboxIDs(ctx context.Context, mailboxIDs []string, limit int, offset int) ([]*models.EmailThread, error)
 	CountByMailboxIDs(ctx context.Context, mailboxIDs []string) (int64, error)
+	ListByFilter(ctx context.Context, filter EmailThreadFilter, limit, offset int) ([]*models.EmailThread, int64, error)
 	Update(ctx context.Context, thread *models.EmailThread) error
 	GetParticipantsForThread(ctx context.Context, threadID string) ([]string, error)
 	FindBySubjectAndMailbox(ctx context.Context, subject string, mailboxID string) ([]*models.EmailThread, error)
 	MarkThreadAsViewed(ctx context.Context, threadID string) error
 	MarkThreadAsDone(ctx context.Context, threadID string, isDone bool) error
+	MarkThreadsAsViewed(ctx context.Context, threadIDs []string) (int64, error)
+	MarkThreadsAsDone(ctx context.Context, threadIDs []string, isDone bool) (int64, error)
+	Delete(ctx context.Context, threadID string) error
+	MergeThreads(ctx context.Context, sourceIDs []string, targetID string) (*models.EmailThread, error)
+	SplitThread(ctx context.Context, threadID string, emailIDs []string) (*models.EmailThread, *models.EmailThread, error)
+	RecomputeMetadata(ctx context.Context, threadID string) (*models.EmailThread, []string, error)
+	ListIDsAfter(ctx context.Context, afterID string, limit int) ([]string, error)
+	GetByIDIncludingDeleted(ctx context.Context, id string) (*models.EmailThread, error)
+	SoftDelete(ctx context.Context, threadID string) (*models.EmailThread, error)
+	Restore(ctx context.Context, threadID string) (*models.EmailThread, error)
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
 }
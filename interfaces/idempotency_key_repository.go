@@ -0,0 +1,17 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/customeros/mailstack/internal/models"
+)
+
+type IdempotencyKeyRepository interface {
+	// Reserve stores the key unless the tenant already has it unexpired, in which case that one
+	// is returned instead. A nil result means the caller holds the key and handles the request.
+	Reserve(ctx context.Context, key *models.IdempotencyKey) (*models.IdempotencyKey, error)
+	Complete(ctx context.Context, id string, statusCode int, body []byte) error
+	Release(ctx context.Context, id string) error
+	DeleteExpired(ctx context.Context, before time.Time, limit int) (int64, error)
+}
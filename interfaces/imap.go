@@ -14,22 +14,85 @@ type IMAPService interface {
 	Stop() error
 	AddMailbox(ctx context.Context, mailbox *models.Mailbox) error
 	RemoveMailbox(ctx context.Context, mailboxID string) error
+	ReloadMailbox(ctx context.Context, mailbox *models.Mailbox) error
 	GetMessageByUID(ctx context.Context, mailboxID, folderName string, uid uint32) (*imap.Message, error)
+	FetchRaw(ctx context.Context, mailboxID, folderName string, uid uint32, messageID string) ([]byte, error)
 	Status() map[string]MailboxStatus
+	Resync(ctx context.Context, mailboxID, folderName string) (string, error)
+	AppendToSent(ctx context.Context, mailbox *models.Mailbox, message []byte, date time.Time) error
+	ListFolders(ctx context.Context, mailbox *models.Mailbox) ([]*IMAPFolder, error)
+	CreateFolder(ctx context.Context, mailbox *models.Mailbox, name string) error
+	DeleteFolder(ctx context.Context, mailbox *models.Mailbox, name string) error
+	UpdateMailboxSettings(ctx context.Context, mailbox *models.Mailbox, update MailboxSettingsUpdate) (*models.Mailbox, error)
+	ImportMessages(ctx context.Context, mailbox *models.Mailbox, folder string, messages []ImportMessage, process bool) ([]ImportResult, error)
+}
+
+// ImportMessage is a raw RFC 822 message to append to a mailbox folder
+type ImportMessage struct {
+	Name  string // where the message came from, e.g. the file name and its position in an mbox
+	Raw   []byte
+	Flags []string
+	Date  time.Time // internal date, zero lets the server set it
+}
+
+// ImportResult reports how the import of a message went
+type ImportResult struct {
+	Name     string `json:"name"`
+	Imported bool   `json:"imported"`
+	UID      uint32 `json:"uid,omitempty"`    // known when the server supports UIDPLUS
+	Queued   bool   `json:"queued,omitempty"` // queued for processing
+	Error    string `json:"error,omitempty"`
+}
+
+// MailboxSettingsUpdate holds the sync settings and IMAP credentials to change, nil fields are
+// left as they are
+type MailboxSettingsUpdate struct {
+	SyncFolders             []string
+	SyncPollIntervalSeconds *int
+	ImapUsername            *string
+	ImapPassword            *string
 }
 
 type MailboxStatus struct {
-	Connected   bool
-	LastError   string
-	Folders     map[string]FolderStats
-	LastChecked time.Time
+	Connected         bool
+	LastError         string
+	Folders           map[string]FolderStats
+	LastChecked       time.Time
+	DisconnectedSince time.Time // zero while connected
+	WaitingForSlot    bool      `json:"waiting_for_slot,omitempty"` // queued behind the connection limit
+	// AuthFailed is set when the server rejected the credentials, reconnecting stops until they change
+	AuthFailed bool `json:"auth_failed,omitempty"`
+
+	// InitialSyncComplete is set once every synced folder finished its initial sync
+	InitialSyncComplete bool `json:"initial_sync_complete"`
+	// ResyncJobID is the last manual resync started for the mailbox
+	ResyncJobID string `json:"resync_job_id,omitempty"`
 }
 
 type FolderStats struct {
-	Total    uint32
-	Unseen   uint32
-	LastSeen uint32
-	LastSync time.Time
+	Total       uint32
+	Unseen      uint32
+	LastSeen    uint32
+	LastSync    time.Time
+	InitialSync InitialSyncProgress `json:"initial_sync"`
+}
+
+type InitialSyncProgress struct {
+	Processed int  `json:"processed"`
+	Total     int  `json:"total"`
+	Complete  bool `json:"initial_sync_complete"`
+}
+
+// IMAPFolder is a folder on the server of a mailbox with its subfolders. Folders that can not
+// hold messages, marked \Noselect, have no counts.
+type IMAPFolder struct {
+	Name       string        `json:"name"` // full name, as used in IMAP commands
+	Delimiter  string        `json:"delimiter,omitempty"`
+	Attributes []string      `json:"attributes"`
+	Messages   uint32        `json:"messages"`
+	Unseen     uint32        `json:"unseen"`
+	Synced     bool          `json:"synced"`
+	Children   []*IMAPFolder `json:"children"`
 }
 
 type MailEvent struct {
@@ -0,0 +1,12 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/customeros/mailstack/internal/models"
+)
+
+// MailAuthVerifier verifies the DKIM signatures, SPF and DMARC of an inbound message
+type MailAuthVerifier interface {
+	Verify(ctx context.Context, rawMessage []byte) (*models.MailAuthResults, error)
+}
@@ -8,4 +8,16 @@ import (
 
 type MailboxService interface {
 	EnrollMailbox(ctx context.Context, mailbox *models.Mailbox) (*models.Mailbox, error)
+	ProvisionMailbox(ctx context.Context, request ProvisionMailboxRequest) (*models.Mailbox, error)
+}
+
+// ProvisionMailboxRequest describes a mailbox hosted on the mailstack platform
+type ProvisionMailboxRequest struct {
+	Username       string
+	Domain         string
+	Password       string
+	UserID         string
+	SenderID       string
+	ForwardingTo   []string
+	WebmailEnabled bool
 }
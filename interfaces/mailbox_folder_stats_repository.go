@@ -0,0 +1,14 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/customeros/mailstack/internal/models"
+)
+
+type MailboxFolderStatsRepository interface {
+	Save(ctx context.Context, stats *models.MailboxFolderStats) error
+	GetByMailbox(ctx context.Context, mailboxID string) ([]models.MailboxFolderStats, error)
+	DeleteByMailbox(ctx context.Context, mailboxID string) error
+	DeleteByFolder(ctx context.Context, mailboxID, folderName string) error
+}
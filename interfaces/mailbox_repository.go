@@ -10,9 +10,11 @@ import (
 type MailboxRepository interface {
 	GetMailboxes(ctx context.Context) ([]*models.Mailbox, error)
 	GetMailboxesByUserID(ctx context.Context, userID string) ([]*models.Mailbox, error)
+	GetMailboxesByTenant(ctx context.Context, tenant string) ([]*models.Mailbox, error)
 	GetMailbox(ctx context.Context, id string) (*models.Mailbox, error)
 	GetMailboxByEmailAddress(ctx context.Context, emailAddress string) (*models.Mailbox, error)
 	SaveMailbox(ctx context.Context, mailbox models.Mailbox) (string, error)
 	DeleteMailbox(ctx context.Context, id string) error
 	UpdateConnectionStatus(ctx context.Context, mailboxID string, status enum.ConnectionStatus, errorMessage string) error
+	UpdateSyncFolders(ctx context.Context, mailboxID string, folders []string) error
 }
@@ -0,0 +1,12 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+)
+
+type MailboxSendCountRepository interface {
+	Reserve(ctx context.Context, mailboxID string, day time.Time, limit int) (bool, error)
+	Release(ctx context.Context, mailboxID string, day time.Time) error
+	Get(ctx context.Context, mailboxID string, day time.Time) (int, error)
+}
@@ -1,19 +1,70 @@
 package interfaces
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type NamecheapService interface {
 	CheckDomainAvailability(ctx context.Context, domain string) (bool, bool, error)
+	CheckDomainsAvailability(ctx context.Context, domains []string) ([]NamecheapDomainAvailability, error)
 	PurchaseDomain(ctx context.Context, tenant, domain string) error
-	GetDomainPrice(ctx context.Context, domain string) (float64, error)
+	GetDomainPrice(ctx context.Context, tenant, domain, currency string) (NamecheapDomainPrice, error)
+	PriceExceedsMax(ctx context.Context, domain string) (bool, error)
 	GetDomainInfo(ctx context.Context, tenant, domain string) (NamecheapDomainInfo, error)
+	GetCachedDomainInfo(ctx context.Context, tenant, domain string, forceRefresh bool) (NamecheapDomainInfo, error)
 	UpdateNameservers(ctx context.Context, tenant, domain string, nameservers []string) error
+	GetHostRecords(ctx context.Context, tenant, domain string) (NamecheapHostRecords, error)
+	SetHostRecords(ctx context.Context, tenant, domain string, records NamecheapHostRecords) error
+	RenewDomain(ctx context.Context, tenant, domain string, years int) error
+	ListDomains(ctx context.Context) ([]NamecheapListedDomain, error)
 }
 
 type NamecheapDomainInfo struct {
-	DomainName  string   `json:"domainName"`
-	CreatedDate string   `json:"createdDate"`
-	ExpiredDate string   `json:"expiredDate"`
-	Nameservers []string `json:"nameservers"`
-	WhoisGuard  bool     `json:"whoisGuard"`
+	DomainName  string     `json:"domainName"`
+	CreatedDate string     `json:"createdDate"`
+	ExpiredDate string     `json:"expiredDate"`
+	ExpiresAt   *time.Time `json:"expiresAt"` // ExpiredDate parsed, nil if the format is unknown
+	Nameservers []string   `json:"nameservers"`
+	WhoisGuard  bool       `json:"whoisGuard"`
+}
+
+type NamecheapHostRecords struct {
+	EmailType         string                `json:"emailType"`
+	UsingNamecheapDNS bool                  `json:"usingNamecheapDNS"` // false once the nameservers point elsewhere
+	Hosts             []NamecheapHostRecord `json:"hosts"`
+}
+
+type NamecheapHostRecord struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"` // relative to the domain, @ for the apex
+	Type    string `json:"type"`
+	Address string `json:"address"`
+	MXPref  int    `json:"mxPref"`
+	TTL     int    `json:"ttl"`
+}
+
+// ExchangeRateSource returns how many units of to one unit of from is worth
+type ExchangeRateSource interface {
+	Rate(ctx context.Context, from, to string) (float64, error)
+}
+
+type NamecheapDomainPrice struct {
+	Domain   string  `json:"domain"`
+	Currency string  `json:"currency"`
+	Cost     float64 `json:"cost"`  // charged by Namecheap
+	Price    float64 `json:"price"` // cost with the markup applied
+}
+
+type NamecheapDomainAvailability struct {
+	Domain    string `json:"domain"`
+	Available bool   `json:"isAvailable"`
+	Premium   bool   `json:"isPremium"`
+}
+
+type NamecheapListedDomain struct {
+	DomainName string     `json:"domainName"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	IsExpired  bool       `json:"isExpired"`
+	AutoRenew  bool       `json:"autoRenew"`
 }
@@ -7,10 +7,16 @@ import (
 )
 
 type OpenSrsService interface {
-	SendEmail(ctx context.Context, request *models.EmailMessage) error
+	SendEmail(ctx context.Context, request *models.EmailMessage, attachments []*models.EmailAttachment) error
 	SetupDomain(ctx context.Context, tenant, domain string) error
 	SetupMailbox(ctx context.Context, tenant, username, password string, forwardingTo []string, webmailEnabled bool) error
 	GetMailboxDetails(ctx context.Context, email string) (MailboxDetails, error)
+	GetMailboxUsage(ctx context.Context, email string) (MailboxUsage, error)
+	DeleteMailbox(ctx context.Context, tenant, email string) error
+	ChangeMailboxPassword(ctx context.Context, tenant, email, newPassword string) error
+	SetupAlias(ctx context.Context, tenant, alias, forwardTo string) error
+	RemoveAlias(ctx context.Context, tenant, alias string) error
+	SetDomainCatchAll(ctx context.Context, tenant, domain, destination string) error
 }
 
 type MailboxDetails struct {
@@ -19,3 +25,12 @@ type MailboxDetails struct {
 	ForwardingTo      []string `json:"forwardingTo"`
 	WebmailEnabled    bool     `json:"webmailEnabled"`
 }
+
+// MailboxUsage is the storage used by a hosted mailbox. QuotaBytes is 0 when the quota is unlimited.
+type MailboxUsage struct {
+	Email       string  `json:"email"`
+	UsedBytes   int64   `json:"usedBytes"`
+	QuotaBytes  int64   `json:"quotaBytes"`
+	Unlimited   bool    `json:"unlimited"`
+	UsedPercent float64 `json:"usedPercent"`
+}
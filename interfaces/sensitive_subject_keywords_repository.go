@@ -0,0 +1,12 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/customeros/mailstack/internal/models"
+)
+
+type SensitiveSubjectKeywordsRepository interface {
+	GetByTenant(ctx context.Context, tenant string) ([]models.SensitiveSubjectKeywords, error)
+	Save(ctx context.Context, keywords *models.SensitiveSubjectKeywords) error
+}
@@ -0,0 +1,14 @@
+package interfaces
+
+import "context"
+
+type SpamScorer interface {
+	Score(ctx context.Context, rawMessage []byte) (*SpamScore, error)
+}
+
+type SpamScore struct {
+	Skipped   bool // no scorer configured
+	Score     float64
+	Threshold float64
+	Spam      bool // Score exceeds Threshold
+}
@@ -8,3 +8,10 @@ type StorageService interface {
 	Delete(ctx context.Context, key string) error
 	GetPublicURL(key string) string
 }
+
+// AttachmentStorage resolves the storage of an object from the backend and bucket it was stored in
+type AttachmentStorage interface {
+	// Default returns the backend and bucket new objects are uploaded to
+	Default() (backend, bucket string)
+	Get(backend, bucket string) (StorageService, error)
+}
@@ -0,0 +1,12 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/customeros/mailstack/internal/models"
+)
+
+type StructuredBodyCacheRepository interface {
+	Get(ctx context.Context, bodyHash string) (*models.StructuredBodyCache, error)
+	Save(ctx context.Context, entry *models.StructuredBodyCache) error
+}
@@ -0,0 +1,12 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/customeros/mailstack/internal/models"
+)
+
+type TenantCurrencyRepository interface {
+	GetByTenant(ctx context.Context, tenant string) (*models.TenantCurrency, error)
+	Save(ctx context.Context, currency *models.TenantCurrency) error
+}
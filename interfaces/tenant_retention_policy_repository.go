@@ -0,0 +1,14 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/customeros/mailstack/internal/models"
+)
+
+type TenantRetentionPolicyRepository interface {
+	GetByTenant(ctx context.Context, tenant string) (*models.TenantRetentionPolicy, error)
+	List(ctx context.Context) ([]models.TenantRetentionPolicy, error)
+	Save(ctx context.Context, policy *models.TenantRetentionPolicy) error
+	Delete(ctx context.Context, tenant string) error
+}
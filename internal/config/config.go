@@ -5,6 +5,9 @@ type AppConfig struct {
 	APIKey            string `env:"API_KEY,required"`
 	RabbitMQURL       string `env:"RABBITMQ_URL"`
 	TrackingPublicUrl string `env:"TRACKING_PUBLIC_URL" envDefault:"https://custosmetrics.com"`
+
+	RabbitMQPrefetchCount   int `env:"RABBITMQ_PREFETCH_COUNT" envDefault:"10"`
+	RabbitMQConsumerWorkers int `env:"RABBITMQ_CONSUMER_WORKERS" envDefault:"4"`
 }
 
 type MailstackDatabaseConfig struct {
@@ -33,15 +36,151 @@ type OpenlineDatabaseConfig struct {
 	SSLMode         string `env:"OPENLINE_POSTGRES_SSL_MODE" envDefault:"require"`
 }
 
+// R2 credentials are only required when attachments are stored in R2, now or in the past
 type R2StorageConfig struct {
-	AccountID             string `env:"CLOUDFLARE_R2_ACCOUNT_ID,required"`
-	AccessKeyID           string `env:"CLOUDFLARE_R2_ACCESS_KEY_ID,required"`
-	AccessKeySecret       string `env:"CLOUDFLARE_R2_ACCESS_KEY_SECRET,required"`
-	EmailAttachmentBucket string `env:"BUCKET_NAME_EMAIL_ATTACHMENT" envDefault:"attachments"`
+	AccountID       string `env:"CLOUDFLARE_R2_ACCOUNT_ID"`
+	AccessKeyID     string `env:"CLOUDFLARE_R2_ACCESS_KEY_ID"`
+	AccessKeySecret string `env:"CLOUDFLARE_R2_ACCESS_KEY_SECRET"`
+}
+
+// AttachmentStorageConfig selects where new attachments are uploaded, R2, S3 or MINIO.
+// Endpoint, region and keys configure the S3 and MinIO backends, R2 uses R2StorageConfig.
+type AttachmentStorageConfig struct {
+	Backend         string `env:"ATTACHMENT_STORAGE_BACKEND" envDefault:"R2"`
+	Bucket          string `env:"BUCKET_NAME_EMAIL_ATTACHMENT" envDefault:"attachments"`
+	Endpoint        string `env:"ATTACHMENT_STORAGE_ENDPOINT"`
+	Region          string `env:"ATTACHMENT_STORAGE_REGION" envDefault:"us-east-1"`
+	AccessKeyID     string `env:"ATTACHMENT_STORAGE_ACCESS_KEY_ID"`
+	AccessKeySecret string `env:"ATTACHMENT_STORAGE_ACCESS_KEY_SECRET"`
+}
+
+type SMTPConfig struct {
+	MaxSendAttempts       int `env:"SMTP_MAX_SEND_ATTEMPTS" envDefault:"5"`
+	RetryBaseDelaySeconds int `env:"SMTP_RETRY_BASE_DELAY_SECONDS" envDefault:"60"`
+	RetryMaxDelaySeconds  int `env:"SMTP_RETRY_MAX_DELAY_SECONDS" envDefault:"3600"`
+	MaxMessageSizeBytes   int `env:"SMTP_MAX_MESSAGE_SIZE_BYTES" envDefault:"26214400"`
+
+	// Authenticated connections kept per mailbox and reused across sends, 0 disables pooling
+	PoolMaxConnectionsPerMailbox int `env:"SMTP_POOL_MAX_CONNECTIONS_PER_MAILBOX" envDefault:"2"`
+	PoolMaxIdleSeconds           int `env:"SMTP_POOL_MAX_IDLE_SECONDS" envDefault:"60"`
+
+	// "skip" sends to the valid recipients when some are invalid, "reject" fails the send
+	InvalidRecipientPolicy string `env:"SMTP_INVALID_RECIPIENT_POLICY" envDefault:"skip"`
+
+	// Recipients of domains without MX or address records are skipped before SMTP. Off by default,
+	// it adds a DNS lookup per uncached recipient domain to every send.
+	MXPrecheckEnabled      bool `env:"SMTP_MX_PRECHECK_ENABLED" envDefault:"false"`
+	MXPrecheckCacheSeconds int  `env:"SMTP_MX_PRECHECK_CACHE_SECONDS" envDefault:"3600"`
+
+	// Sent messages are appended to the IMAP sent folder of the mailbox
+	AppendToSentFolder bool `env:"SMTP_APPEND_TO_SENT_FOLDER" envDefault:"true"`
+}
+
+// CredentialsEncryptionConfig holds the keys mailbox passwords are encrypted with, as comma separated
+// "id:base64 key" pairs of 32 byte keys. New values use the active key, the others are kept to decrypt
+// values written before a rotation. No keys stores passwords in plaintext.
+type CredentialsEncryptionConfig struct {
+	Keys        string `env:"CREDENTIALS_ENCRYPTION_KEYS"`
+	ActiveKeyID string `env:"CREDENTIALS_ENCRYPTION_ACTIVE_KEY_ID"`
+}
+
+// IMAPConfig caps the mailboxes connected at the same time, 0 connects all of them at once.
+// A connected mailbox keeps its slot while it polls for new mail, so mailboxes beyond the cap
+// are not synced until another one disconnects: set it above the number of synced mailboxes
+// times FolderConcurrency.
+// FolderConcurrency is how many folders of a mailbox sync at the same time, each over its own
+// connection, these extra connections count against MaxConcurrentConnections too.
+type IMAPConfig struct {
+	MaxConcurrentConnections int `env:"IMAP_MAX_CONCURRENT_CONNECTIONS" envDefault:"500"`
+	FolderConcurrency        int `env:"IMAP_FOLDER_CONCURRENCY" envDefault:"1"`
+}
+
+type InboundConfig struct {
+	MaxAttachmentSizeBytes int `env:"INBOUND_MAX_ATTACHMENT_SIZE_BYTES" envDefault:"26214400"`
+	// Stores the original source of inbound emails in attachment storage so they can be parsed
+	// again later, off by default as it roughly doubles the storage used per email
+	StoreRawMessages bool `env:"INBOUND_STORE_RAW_MESSAGES" envDefault:"false"`
+	// Verifies DKIM signatures, SPF and DMARC of inbound emails from their raw source, which
+	// costs a few DNS lookups per email
+	VerifyMailAuth bool `env:"INBOUND_VERIFY_MAIL_AUTH" envDefault:"false"`
+	// CIDR ranges of the provider relays whose Received hops are skipped when looking for the
+	// host SPF is evaluated for, needed when the provider relays between public addresses
+	TrustedRelayNetworks []string `env:"INBOUND_TRUSTED_RELAY_NETWORKS" envSeparator:","`
+}
+
+// ThreadingConfig limits the subject based fallback used when an inbound email has no usable
+// In-Reply-To or References headers
+type ThreadingConfig struct {
+	SubjectMatchWindowDays        int `env:"THREADING_SUBJECT_MATCH_WINDOW_DAYS" envDefault:"30"`
+	SubjectMatchMinSharedContacts int `env:"THREADING_SUBJECT_MATCH_MIN_SHARED_CONTACTS" envDefault:"2"`
+}
+
+// RetentionConfig sets how long deleted threads and emails stay in the trash before they are purged
+type RetentionConfig struct {
+	DeletedRetentionDays int `env:"DELETED_RETENTION_DAYS" envDefault:"30"`
+}
+
+// IdempotencyConfig sets how long the response to a request made with an Idempotency-Key header
+// is replayed to retries of it
+type IdempotencyConfig struct {
+	KeyTTLHours int `env:"IDEMPOTENCY_KEY_TTL_HOURS" envDefault:"24"`
+}
+
+// HTMLSanitizerConfig overrides the default allowlists used to clean inbound HTML bodies
+type HTMLSanitizerConfig struct {
+	AllowedTags       []string `env:"HTML_SANITIZER_ALLOWED_TAGS" envSeparator:","`
+	AllowedAttributes []string `env:"HTML_SANITIZER_ALLOWED_ATTRIBUTES" envSeparator:","`
+}
+
+// SpoofingConfig tunes the heuristics that flag inbound email impersonating a brand or domain
+type SpoofingConfig struct {
+	// Rules to turn off by name: brand_display_name, reply_to_dmarc_fail, lookalike_domain
+	DisabledRules []string `env:"SPOOFING_DISABLED_RULES" envSeparator:","`
+	// Replaces the default brands, each "name=domain|domain", e.g. "paypal=paypal.com|paypal.me"
+	Brands []string `env:"SPOOFING_BRANDS" envSeparator:","`
+}
+
+// AttachmentPreviewConfig controls the thumbnails generated for image and PDF attachments
+type AttachmentPreviewConfig struct {
+	Enabled   bool `env:"ATTACHMENT_PREVIEWS_ENABLED" envDefault:"true"`
+	MaxSizePx int  `env:"ATTACHMENT_PREVIEW_MAX_SIZE_PX" envDefault:"320"`
+}
+
+// AttachmentScannerConfig enables ClamAV scanning of inbound attachments when an address is set
+type AttachmentScannerConfig struct {
+	ClamAVAddress  string `env:"CLAMAV_ADDRESS"`
+	TimeoutSeconds int    `env:"CLAMAV_TIMEOUT_SECONDS" envDefault:"30"`
+}
+
+// SpamScorerConfig enables spam scoring of inbound mail. Provider is rspamd, with Address the
+// controller URL, or spamassassin, with Address the spamd host:port.
+type SpamScorerConfig struct {
+	Provider       string  `env:"SPAM_SCORER_PROVIDER"`
+	Address        string  `env:"SPAM_SCORER_ADDRESS"`
+	Password       string  `env:"SPAM_SCORER_PASSWORD"`
+	Threshold      float64 `env:"SPAM_SCORER_THRESHOLD" envDefault:"6"`
+	TimeoutSeconds int     `env:"SPAM_SCORER_TIMEOUT_SECONDS" envDefault:"10"`
 }
 
 type DomainConfig struct {
-	SupportedTlds []string `env:"MAILSTACK_SUPPORTED_TLD" envDefault:"com"`
+	SupportedTlds      []string `env:"MAILSTACK_SUPPORTED_TLD" envDefault:"com"`
+	SPFInclude         string   `env:"MAILSTACK_SPF_INCLUDE" envDefault:"_spf.hostedemail.com"`
+	DMARCPolicy        string   `env:"MAILSTACK_DMARC_POLICY" envDefault:"reject"`
+	DMARCReportEmail   string   `env:"MAILSTACK_DMARC_RUA_EMAIL" envDefault:"monitor@customerosmail.com"`
+	DMARCForensicEmail string   `env:"MAILSTACK_DMARC_RUF_EMAIL" envDefault:"dmarc@customerosmail.com"`
+
+	// DNS blocklists queried by the reputation monitor
+	SendingIPs          []string `env:"MAILSTACK_SENDING_IPS" envSeparator:","`
+	IPBlocklists        []string `env:"MAILSTACK_IP_DNSBLS" envSeparator:"," envDefault:"zen.spamhaus.org,b.barracudacentral.org,bl.spamcop.net"`
+	DomainBlocklists    []string `env:"MAILSTACK_DOMAIN_DNSBLS" envSeparator:"," envDefault:"dbl.spamhaus.org,multi.surbl.org"`
+	DNSBLTimeoutSeconds int      `env:"MAILSTACK_DNSBL_TIMEOUT_SECONDS" envDefault:"5"`
+
+	// Automatic renewal of domains that expire within RenewBeforeDays
+	RenewBeforeDays int `env:"MAILSTACK_RENEW_BEFORE_DAYS" envDefault:"30"`
+	RenewYears      int `env:"MAILSTACK_RENEW_YEARS" envDefault:"1"`
+
+	// Days before expiry at which an expiry notification is published
+	ExpiryNotifyDays []int `env:"MAILSTACK_EXPIRY_NOTIFY_DAYS" envSeparator:"," envDefault:"30,14,7,1"`
 }
 
 type NamecheapConfig struct {
@@ -63,6 +202,19 @@ type NamecheapConfig struct {
 	RegistrantCountry     string  `env:"NAMECHEAP_REGISTRANT_COUNTRY" `
 	RegistrantPhoneNumber string  `env:"NAMECHEAP_REGISTRANT_PHONE_NUMBER" `
 	RegistrantEmail       string  `env:"NAMECHEAP_REGISTRANT_EMAIL" `
+
+	// Domain info is served from cache for the TTL, stale entries up to the max age are
+	// returned while they refresh in the background
+	DomainInfoCacheTTLSeconds    int `env:"NAMECHEAP_DOMAIN_INFO_CACHE_TTL_SECONDS" envDefault:"300"`
+	DomainInfoCacheMaxAgeSeconds int `env:"NAMECHEAP_DOMAIN_INFO_CACHE_MAX_AGE_SECONDS" envDefault:"86400"`
+
+	// TLD prices are cached for the TTL. Quotes add the markup percent and are converted with the
+	// exchange rates, given as units per USD like EUR:0.92,GBP:0.79. MaxPrice is in MaxPriceCurrency.
+	PriceCacheTTLSeconds int     `env:"NAMECHEAP_PRICE_CACHE_TTL_SECONDS" envDefault:"3600"`
+	PriceMarkupPercent   float64 `env:"NAMECHEAP_PRICE_MARKUP_PERCENT" envDefault:"0"`
+	PriceCurrency        string  `env:"NAMECHEAP_PRICE_CURRENCY" envDefault:"USD"`
+	MaxPriceCurrency     string  `env:"NAMECHEAP_MAX_PRICE_CURRENCY" envDefault:"USD"`
+	ExchangeRates        string  `env:"NAMECHEAP_EXCHANGE_RATES"`
 }
 
 type CloudflareConfig struct {
@@ -75,6 +227,8 @@ type OpenSRSConfig struct {
 	Url      string `env:"OPENSRS_URL" envDefault:"https://admin.a.hostedemail.com"`
 	ApiKey   string `env:"OPENSRS_API_KEY"`
 	Username string `env:"OPENSRS_API_USERNAME"`
+
+	UsageCacheTTLSeconds int `env:"OPENSRS_USAGE_CACHE_TTL_SECONDS" envDefault:"300"`
 }
 
 type CustomerOSAPIConfig struct {
@@ -18,6 +18,19 @@ type Config struct {
 	OpenlineDatabaseConfig  *OpenlineDatabaseConfig
 	CustomerOSAPIConfig     *CustomerOSAPIConfig
 	R2StorageConfig         *R2StorageConfig
+	AttachmentStorageConfig *AttachmentStorageConfig
+	CredentialsEncryption   *CredentialsEncryptionConfig
+	SMTPConfig              *SMTPConfig
+	IMAPConfig              *IMAPConfig
+	InboundConfig           *InboundConfig
+	ThreadingConfig         *ThreadingConfig
+	RetentionConfig         *RetentionConfig
+	IdempotencyConfig       *IdempotencyConfig
+	HTMLSanitizerConfig     *HTMLSanitizerConfig
+	SpoofingConfig          *SpoofingConfig
+	AttachmentScannerConfig *AttachmentScannerConfig
+	AttachmentPreviewConfig *AttachmentPreviewConfig
+	SpamScorerConfig        *SpamScorerConfig
 	DomainConfig            *DomainConfig
 	NamecheapConfig         *NamecheapConfig
 	CloudflareConfig        *CloudflareConfig
@@ -33,6 +46,19 @@ func InitConfig() (*Config, error) {
 		OpenlineDatabaseConfig:  &OpenlineDatabaseConfig{},
 		CustomerOSAPIConfig:     &CustomerOSAPIConfig{},
 		R2StorageConfig:         &R2StorageConfig{},
+		AttachmentStorageConfig: &AttachmentStorageConfig{},
+		CredentialsEncryption:   &CredentialsEncryptionConfig{},
+		SMTPConfig:              &SMTPConfig{},
+		IMAPConfig:              &IMAPConfig{},
+		InboundConfig:           &InboundConfig{},
+		ThreadingConfig:         &ThreadingConfig{},
+		RetentionConfig:         &RetentionConfig{},
+		IdempotencyConfig:       &IdempotencyConfig{},
+		HTMLSanitizerConfig:     &HTMLSanitizerConfig{},
+		SpoofingConfig:          &SpoofingConfig{},
+		AttachmentScannerConfig: &AttachmentScannerConfig{},
+		AttachmentPreviewConfig: &AttachmentPreviewConfig{},
+		SpamScorerConfig:        &SpamScorerConfig{},
 		DomainConfig:            &DomainConfig{},
 		NamecheapConfig:         &NamecheapConfig{},
 		CloudflareConfig:        &CloudflareConfig{},
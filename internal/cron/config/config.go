@@ -5,8 +5,26 @@ type Config struct {
 	CronScheduleHeartbeat string `env:"CRON_SCHEDULE_HEARTBEAT" envDefault:"0 * * * * *"`
 	// Mailstack Reputation Monitoring, daily at midnight
 	CronScheduleMailstackReputation string `env:"CRON_SCHEDULE_MAILSTACK_REPUTATION" envDefault:"0 0 0 * * *"`
+	// Renew Expiring Domains, daily at 02:00
+	CronScheduleRenewDomains string `env:"CRON_SCHEDULE_RENEW_DOMAINS" envDefault:"0 0 2 * * *"`
+	// Domain Expiry Notifications, daily at 01:00
+	CronScheduleDomainExpiryNotifications string `env:"CRON_SCHEDULE_DOMAIN_EXPIRY_NOTIFICATIONS" envDefault:"0 0 1 * * *"`
+	// Reconcile Domain Purchases, every 15 minutes
+	CronScheduleReconcileDomainPurchases string `env:"CRON_SCHEDULE_RECONCILE_DOMAIN_PURCHASES" envDefault:"0 */15 * * * *"`
+	// Reconcile Domain Ownership with Namecheap, daily at 03:00
+	CronScheduleReconcileDomainOwnership string `env:"CRON_SCHEDULE_RECONCILE_DOMAIN_OWNERSHIP" envDefault:"0 0 3 * * *"`
 	// Mailbox Ramp Up, every minute
 	CronScheduleRampUpMailboxes string `env:"CRON_SCHEDULE_RAMP_UP_MAILBOXES" envDefault:"0 * * * * *"`
 	// Configure Pending Mailboxes, every hour
 	CronScheduleConfigureMailboxes string `env:"CRON_SCHEDULE_CONFIGURE_MAILBOXES" envDefault:"0 0 * * * *"`
+	// Dispatch Due Scheduled Emails, every 30 seconds
+	CronScheduleSendScheduledEmails string `env:"CRON_SCHEDULE_SEND_SCHEDULED_EMAILS" envDefault:"*/30 * * * * *"`
+	// Recompute Thread Metadata from their emails, daily at 04:00
+	CronScheduleRecomputeThreads string `env:"CRON_SCHEDULE_RECOMPUTE_THREADS" envDefault:"0 0 4 * * *"`
+	// Purge Threads and Emails deleted longer than the retention period, daily at 05:00
+	CronSchedulePurgeDeleted string `env:"CRON_SCHEDULE_PURGE_DELETED" envDefault:"0 0 5 * * *"`
+	// Purge Mail past the Retention Policy of its tenant, daily at 05:30
+	CronScheduleEnforceRetention string `env:"CRON_SCHEDULE_ENFORCE_RETENTION" envDefault:"0 30 5 * * *"`
+	// Purge Expired Idempotency Keys, every hour
+	CronSchedulePurgeIdempotencyKeys string `env:"CRON_SCHEDULE_PURGE_IDEMPOTENCY_KEYS" envDefault:"0 15 * * * *"`
 }
@@ -31,6 +31,13 @@ const (
 	// GroupMailstackMailbox is the group for mailstack mailbox related jobs
 	GroupMailstackMailbox = "mailstack_mailbox"
 
+	// GroupMailstackEmail is the group for mailstack email related jobs
+	GroupMailstackEmail = "mailstack_email"
+
+	// GroupMailstackThread is the group for mailstack thread maintenance jobs, kept apart so a
+	// long sweep does not hold up sending
+	GroupMailstackThread = "mailstack_thread"
+
 	// LeaseDuration is how long a lease lasts before needing renewal
 	LeaseDuration = 15 * time.Second
 	// RenewDeadline is how long a leader has to renew its lease
@@ -47,6 +54,8 @@ var jobLocks = struct {
 	locks: map[string]*sync.Mutex{
 		GroupMailstackDomain:  new(sync.Mutex),
 		GroupMailstackMailbox: new(sync.Mutex),
+		GroupMailstackEmail:   new(sync.Mutex),
+		GroupMailstackThread:  new(sync.Mutex),
 	},
 }
 
@@ -59,10 +68,11 @@ type CronManager struct {
 	jobIDs   map[string]cronv3.EntryID
 	domain   interfaces.DomainService
 	mailbox  interfaces.MailboxServiceOld
+	email    interfaces.EmailService
 	postgres *repository.Repositories
 }
 
-func NewCronManager(cfg *config.Config, log logger.Logger, k8s kubernetes.Interface, domain interfaces.DomainService, mailbox interfaces.MailboxServiceOld, postgres *repository.Repositories) *CronManager {
+func NewCronManager(cfg *config.Config, log logger.Logger, k8s kubernetes.Interface, domain interfaces.DomainService, mailbox interfaces.MailboxServiceOld, email interfaces.EmailService, postgres *repository.Repositories) *CronManager {
 	return &CronManager{
 		cfg:      cfg,
 		log:      log,
@@ -71,6 +81,7 @@ func NewCronManager(cfg *config.Config, log logger.Logger, k8s kubernetes.Interf
 		jobIDs:   make(map[string]cronv3.EntryID),
 		domain:   domain,
 		mailbox:  mailbox,
+		email:    email,
 		postgres: postgres,
 	}
 }
@@ -195,6 +206,66 @@ func (cm *CronManager) registerJobs(c *cronv3.Cron) {
 		cm.log.Infof("Registered mailstack reputation job with schedule: %s", cronConfig.CronScheduleMailstackReputation)
 	}
 
+	// Add domain purchase reconciliation job
+	if cronConfig.CronScheduleReconcileDomainPurchases != "" {
+		id, err := c.AddFunc(cronConfig.CronScheduleReconcileDomainPurchases, func() {
+			defer tracing.RecoverAndLogToJaeger(cm.log)
+			jobLocks.locks[GroupMailstackDomain].Lock()
+			defer jobLocks.locks[GroupMailstackDomain].Unlock()
+			cm.reconcileDomainPurchases()
+		})
+		if err != nil {
+			cm.log.Fatalf("Could not add domain purchase reconciliation cron job: %v", err)
+		}
+		cm.jobIDs["reconcile_domain_purchases"] = id
+		cm.log.Infof("Registered domain purchase reconciliation job with schedule: %s", cronConfig.CronScheduleReconcileDomainPurchases)
+	}
+
+	// Add domain ownership reconciliation job
+	if cronConfig.CronScheduleReconcileDomainOwnership != "" {
+		id, err := c.AddFunc(cronConfig.CronScheduleReconcileDomainOwnership, func() {
+			defer tracing.RecoverAndLogToJaeger(cm.log)
+			jobLocks.locks[GroupMailstackDomain].Lock()
+			defer jobLocks.locks[GroupMailstackDomain].Unlock()
+			cm.reconcileDomainOwnership()
+		})
+		if err != nil {
+			cm.log.Fatalf("Could not add domain ownership reconciliation cron job: %v", err)
+		}
+		cm.jobIDs["reconcile_domain_ownership"] = id
+		cm.log.Infof("Registered domain ownership reconciliation job with schedule: %s", cronConfig.CronScheduleReconcileDomainOwnership)
+	}
+
+	// Add domain renewal job
+	if cronConfig.CronScheduleRenewDomains != "" {
+		id, err := c.AddFunc(cronConfig.CronScheduleRenewDomains, func() {
+			defer tracing.RecoverAndLogToJaeger(cm.log)
+			jobLocks.locks[GroupMailstackDomain].Lock()
+			defer jobLocks.locks[GroupMailstackDomain].Unlock()
+			cm.renewExpiringDomains()
+		})
+		if err != nil {
+			cm.log.Fatalf("Could not add domain renewal cron job: %v", err)
+		}
+		cm.jobIDs["renew_domains"] = id
+		cm.log.Infof("Registered domain renewal job with schedule: %s", cronConfig.CronScheduleRenewDomains)
+	}
+
+	// Add domain expiry notification job
+	if cronConfig.CronScheduleDomainExpiryNotifications != "" {
+		id, err := c.AddFunc(cronConfig.CronScheduleDomainExpiryNotifications, func() {
+			defer tracing.RecoverAndLogToJaeger(cm.log)
+			jobLocks.locks[GroupMailstackDomain].Lock()
+			defer jobLocks.locks[GroupMailstackDomain].Unlock()
+			cm.notifyExpiringDomains()
+		})
+		if err != nil {
+			cm.log.Fatalf("Could not add domain expiry notification cron job: %v", err)
+		}
+		cm.jobIDs["domain_expiry_notifications"] = id
+		cm.log.Infof("Registered domain expiry notification job with schedule: %s", cronConfig.CronScheduleDomainExpiryNotifications)
+	}
+
 	// Add mailbox ramp up job
 	if cronConfig.CronScheduleRampUpMailboxes != "" {
 		id, err := c.AddFunc(cronConfig.CronScheduleRampUpMailboxes, func() {
@@ -224,6 +295,81 @@ func (cm *CronManager) registerJobs(c *cronv3.Cron) {
 		cm.jobIDs["configure_mailboxes"] = id
 		cm.log.Infof("Registered configure mailboxes job with schedule: %s", cronConfig.CronScheduleConfigureMailboxes)
 	}
+
+	// Add scheduled emails dispatch job
+	if cronConfig.CronScheduleSendScheduledEmails != "" {
+		id, err := c.AddFunc(cronConfig.CronScheduleSendScheduledEmails, func() {
+			defer tracing.RecoverAndLogToJaeger(cm.log)
+			jobLocks.locks[GroupMailstackEmail].Lock()
+			defer jobLocks.locks[GroupMailstackEmail].Unlock()
+			cm.sendScheduledEmails()
+		})
+		if err != nil {
+			cm.log.Fatalf("Could not add send scheduled emails cron job: %v", err)
+		}
+		cm.jobIDs["send_scheduled_emails"] = id
+		cm.log.Infof("Registered send scheduled emails job with schedule: %s", cronConfig.CronScheduleSendScheduledEmails)
+	}
+
+	// Add thread metadata recompute job
+	if cronConfig.CronScheduleRecomputeThreads != "" {
+		id, err := c.AddFunc(cronConfig.CronScheduleRecomputeThreads, func() {
+			defer tracing.RecoverAndLogToJaeger(cm.log)
+			jobLocks.locks[GroupMailstackThread].Lock()
+			defer jobLocks.locks[GroupMailstackThread].Unlock()
+			cm.recomputeThreads()
+		})
+		if err != nil {
+			cm.log.Fatalf("Could not add recompute threads cron job: %v", err)
+		}
+		cm.jobIDs["recompute_threads"] = id
+		cm.log.Infof("Registered recompute threads job with schedule: %s", cronConfig.CronScheduleRecomputeThreads)
+	}
+
+	// Add purge of deleted threads and emails job
+	if cronConfig.CronSchedulePurgeDeleted != "" {
+		id, err := c.AddFunc(cronConfig.CronSchedulePurgeDeleted, func() {
+			defer tracing.RecoverAndLogToJaeger(cm.log)
+			jobLocks.locks[GroupMailstackThread].Lock()
+			defer jobLocks.locks[GroupMailstackThread].Unlock()
+			cm.purgeDeleted()
+		})
+		if err != nil {
+			cm.log.Fatalf("Could not add purge deleted cron job: %v", err)
+		}
+		cm.jobIDs["purge_deleted"] = id
+		cm.log.Infof("Registered purge deleted job with schedule: %s", cronConfig.CronSchedulePurgeDeleted)
+	}
+
+	// Add retention policy enforcement job
+	if cronConfig.CronScheduleEnforceRetention != "" {
+		id, err := c.AddFunc(cronConfig.CronScheduleEnforceRetention, func() {
+			defer tracing.RecoverAndLogToJaeger(cm.log)
+			jobLocks.locks[GroupMailstackThread].Lock()
+			defer jobLocks.locks[GroupMailstackThread].Unlock()
+			cm.enforceRetention()
+		})
+		if err != nil {
+			cm.log.Fatalf("Could not add enforce retention cron job: %v", err)
+		}
+		cm.jobIDs["enforce_retention"] = id
+		cm.log.Infof("Registered enforce retention job with schedule: %s", cronConfig.CronScheduleEnforceRetention)
+	}
+
+	// Add expired idempotency keys cleanup job
+	if cronConfig.CronSchedulePurgeIdempotencyKeys != "" {
+		id, err := c.AddFunc(cronConfig.CronSchedulePurgeIdempotencyKeys, func() {
+			defer tracing.RecoverAndLogToJaeger(cm.log)
+			jobLocks.locks[GroupMailstackDomain].Lock()
+			defer jobLocks.locks[GroupMailstackDomain].Unlock()
+			cm.purgeExpiredIdempotencyKeys()
+		})
+		if err != nil {
+			cm.log.Fatalf("Could not add purge idempotency keys cron job: %v", err)
+		}
+		cm.jobIDs["purge_idempotency_keys"] = id
+		cm.log.Infof("Registered purge idempotency keys job with schedule: %s", cronConfig.CronSchedulePurgeIdempotencyKeys)
+	}
 }
 
 // StartCron initializes and starts the cron scheduler
@@ -263,6 +409,88 @@ func (cm *CronManager) checkMailstackDomainReputation() {
 	cm.log.Info("Successfully completed domain reputation check")
 }
 
+func (cm *CronManager) renewExpiringDomains() {
+	cm.log.Info("Running domain renewal check")
+
+	// Create a background context for the operation
+	ctx := context.Background()
+
+	span, ctx := tracing.StartTracerSpan(ctx, "CronManager.renewExpiringDomains")
+	defer span.Finish()
+	tracing.TagComponentCronJob(span)
+
+	if err := cm.domain.RenewExpiringDomains(ctx); err != nil {
+		tracing.TraceErr(span, err)
+		cm.log.Errorf("Failed to renew expiring domains: %v", err)
+		return
+	}
+
+	cm.log.Info("Successfully completed domain renewal check")
+}
+
+func (cm *CronManager) reconcileDomainPurchases() {
+	cm.log.Info("Running domain purchase reconciliation")
+
+	// Create a background context for the operation
+	ctx := context.Background()
+
+	span, ctx := tracing.StartTracerSpan(ctx, "CronManager.reconcileDomainPurchases")
+	defer span.Finish()
+	tracing.TagComponentCronJob(span)
+
+	backfilled, err := cm.domain.ReconcilePurchasedDomains(ctx)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		cm.log.Errorf("Failed to reconcile domain purchases: %v", err)
+		return
+	}
+
+	cm.log.Infof("Successfully completed domain purchase reconciliation, %d domains backfilled", backfilled)
+}
+
+func (cm *CronManager) reconcileDomainOwnership() {
+	cm.log.Info("Running domain ownership reconciliation")
+
+	// Create a background context for the operation
+	ctx := context.Background()
+
+	span, ctx := tracing.StartTracerSpan(ctx, "CronManager.reconcileDomainOwnership")
+	defer span.Finish()
+	tracing.TagComponentCronJob(span)
+
+	report, err := cm.domain.ReconcileDomainOwnership(ctx)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		cm.log.Errorf("Failed to reconcile domain ownership: %v", err)
+		return
+	}
+
+	if len(report.Created)+len(report.Deactivated)+len(report.Unassigned)+len(report.Failed) > 0 {
+		cm.log.Warnf("Domain ownership discrepancies: created %v, deactivated %v, unassigned %v, failed %v",
+			report.Created, report.Deactivated, report.Unassigned, report.Failed)
+	}
+	cm.log.Info("Successfully completed domain ownership reconciliation")
+}
+
+func (cm *CronManager) notifyExpiringDomains() {
+	cm.log.Info("Running domain expiry notification check")
+
+	// Create a background context for the operation
+	ctx := context.Background()
+
+	span, ctx := tracing.StartTracerSpan(ctx, "CronManager.notifyExpiringDomains")
+	defer span.Finish()
+	tracing.TagComponentCronJob(span)
+
+	if err := cm.domain.NotifyExpiringDomains(ctx); err != nil {
+		tracing.TraceErr(span, err)
+		cm.log.Errorf("Failed to notify expiring domains: %v", err)
+		return
+	}
+
+	cm.log.Info("Successfully completed domain expiry notification check")
+}
+
 func (cm *CronManager) rampUpMailboxes() {
 	cm.log.Info("Running mailbox ramp up check")
 
@@ -317,3 +545,132 @@ func (cm *CronManager) configureMailboxes() {
 
 	cm.log.Info("Successfully completed configure mailboxes check")
 }
+
+func (cm *CronManager) sendScheduledEmails() {
+	// Create a background context for the operation
+	ctx := context.Background()
+
+	span, ctx := tracing.StartTracerSpan(ctx, "CronManager.sendScheduledEmails")
+	defer span.Finish()
+	tracing.TagComponentCronJob(span)
+
+	if err := cm.email.DispatchScheduled(ctx); err != nil {
+		tracing.TraceErr(span, err)
+		cm.log.Errorf("Failed to dispatch scheduled emails: %v", err)
+		return
+	}
+}
+
+func (cm *CronManager) recomputeThreads() {
+	cm.log.Info("Running thread metadata recompute")
+
+	// Create a background context for the operation
+	ctx := context.Background()
+
+	span, ctx := tracing.StartTracerSpan(ctx, "CronManager.recomputeThreads")
+	defer span.Finish()
+	tracing.TagComponentCronJob(span)
+
+	report, err := cm.email.RecomputeThreadsMetadata(ctx)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		cm.log.Errorf("Failed to recompute thread metadata: %v", err)
+		return
+	}
+
+	for _, correction := range report.Corrected {
+		cm.log.Warnf("Corrected drifted metadata of thread %s: %v", correction.ThreadID, correction.Fields)
+	}
+	if len(report.Failed) > 0 {
+		cm.log.Warnf("Failed to recompute metadata of threads %v", report.Failed)
+	}
+	cm.log.Infof("Successfully completed thread metadata recompute, %d threads checked, %d corrected", report.Checked, len(report.Corrected))
+}
+
+func (cm *CronManager) purgeDeleted() {
+	cm.log.Info("Running purge of deleted threads and emails")
+
+	// Create a background context for the operation
+	ctx := context.Background()
+
+	span, ctx := tracing.StartTracerSpan(ctx, "CronManager.purgeDeleted")
+	defer span.Finish()
+	tracing.TagComponentCronJob(span)
+
+	retentionDays := cm.cfg.RetentionConfig.DeletedRetentionDays
+	if retentionDays <= 0 {
+		cm.log.Warnf("Skipping purge of deleted threads and emails, retention of %d days is not positive", retentionDays)
+		return
+	}
+	before := utils.Now().AddDate(0, 0, -retentionDays)
+	span.LogFields(log.Object("before", before))
+
+	report, err := cm.email.PurgeDeleted(ctx, before)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		cm.log.Errorf("Failed to purge deleted threads and emails: %v", err)
+		return
+	}
+
+	cm.log.Infof("Successfully completed purge of threads and emails deleted before %s: %d emails, %d threads, %d attachments, %d storage objects, %d bytes reclaimed",
+		before.Format(time.RFC3339), report.Emails, report.Threads, report.Attachments, report.StorageObjects, report.BytesReclaimed)
+}
+
+func (cm *CronManager) enforceRetention() {
+	cm.log.Info("Running retention policy enforcement")
+
+	// Create a background context for the operation
+	ctx := context.Background()
+
+	span, ctx := tracing.StartTracerSpan(ctx, "CronManager.enforceRetention")
+	defer span.Finish()
+	tracing.TagComponentCronJob(span)
+
+	report, err := cm.email.EnforceRetention(ctx)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		cm.log.Errorf("Failed to enforce retention policies: %v", err)
+		return
+	}
+
+	for tenant, purged := range report.Tenants {
+		cm.log.Infof("Retention purged for tenant %s: %d emails, %d threads, %d attachments, %d storage objects, %d bytes reclaimed",
+			tenant, purged.Emails, purged.Threads, purged.Attachments, purged.StorageObjects, purged.BytesReclaimed)
+	}
+	if len(report.Failed) > 0 {
+		cm.log.Warnf("Failed to enforce retention of tenants %v", report.Failed)
+	}
+	cm.log.Infof("Successfully completed retention policy enforcement for %d tenants", len(report.Tenants))
+}
+
+// purgeExpiredIdempotencyKeys removes keys past their replay window. Requests reusing an expired
+// key take it over anyway, this keeps the table from growing.
+func (cm *CronManager) purgeExpiredIdempotencyKeys() {
+	cm.log.Info("Running purge of expired idempotency keys")
+
+	// Create a background context for the operation
+	ctx := context.Background()
+
+	span, ctx := tracing.StartTracerSpan(ctx, "CronManager.purgeExpiredIdempotencyKeys")
+	defer span.Finish()
+	tracing.TagComponentCronJob(span)
+
+	const batchSize = 1000
+	now := utils.Now()
+	var total int64
+	for {
+		deleted, err := cm.postgres.IdempotencyKeyRepository.DeleteExpired(ctx, now, batchSize)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			cm.log.Errorf("Failed to purge expired idempotency keys: %v", err)
+			return
+		}
+		total += deleted
+		if deleted < batchSize {
+			break
+		}
+	}
+
+	span.LogFields(log.Int64("deleted", total))
+	cm.log.Infof("Successfully purged %d expired idempotency keys", total)
+}
@@ -29,17 +29,13 @@ func getLogger() logger.Logger {
 func TestNewCronManager(t *testing.T) {
 	// Arrange
 	cfg := &config.Config{
-		AppConfig: &config.AppConfig{
-			Logger: &logger.Config{
-				LogLevel: "info",
-			},
-		},
+		AppConfig: &config.AppConfig{},
 	}
 	log := getLogger()
 	k8s := &mockKubernetesInterface{}
 
 	// Act
-	cm := NewCronManager(cfg, log, k8s, nil, nil, nil)
+	cm := NewCronManager(cfg, log, k8s, nil, nil, nil, nil)
 
 	// Assert
 	assert.NotNil(t, cm)
@@ -51,7 +47,7 @@ func TestNewCronManager(t *testing.T) {
 
 func TestCronManager_StartCron(t *testing.T) {
 	// Set environment variable for testing
-	os.Setenv("CRON_SCHEDULE_MAILSTACK_REPUTATION", "0 0 * * *")
+	os.Setenv("CRON_SCHEDULE_MAILSTACK_REPUTATION", "0 0 0 * * *")
 	os.Setenv("CRON_SCHEDULE_RAMP_UP_MAILBOXES", "0 * * * * *")
 	os.Setenv("CRON_SCHEDULE_CONFIGURE_MAILBOXES", "0 0 * * * *")
 	defer os.Unsetenv("CRON_SCHEDULE_MAILSTACK_REPUTATION")
@@ -60,22 +56,18 @@ func TestCronManager_StartCron(t *testing.T) {
 
 	// Arrange
 	cfg := &config.Config{
-		AppConfig: &config.AppConfig{
-			Logger: &logger.Config{
-				LogLevel: "info",
-			},
-		},
+		AppConfig: &config.AppConfig{},
 	}
 	log := getLogger()
 	k8s := &mockKubernetesInterface{}
-	cm := NewCronManager(cfg, log, k8s, nil, nil, nil)
+	cm := NewCronManager(cfg, log, k8s, nil, nil, nil, nil)
 
 	// Create a mock cron for testing
-	mockCron := cronv3.New()
+	mockCron := cronv3.New(cronv3.WithSeconds())
 
 	// Register jobs directly
 	var cronConfig cron_config.Config
-	cronConfig.CronScheduleMailstackReputation = "0 0 * * *"
+	cronConfig.CronScheduleMailstackReputation = "0 0 0 * * *"
 	cronConfig.CronScheduleRampUpMailboxes = "0 * * * * *"
 	cronConfig.CronScheduleConfigureMailboxes = "0 0 * * * *"
 
@@ -103,23 +95,19 @@ func TestCronManager_StartCron(t *testing.T) {
 
 func TestCronManager_Stop(t *testing.T) {
 	// Set environment variable for testing
-	os.Setenv("CRON_SCHEDULE_MAILSTACK_REPUTATION", "0 0 * * *")
+	os.Setenv("CRON_SCHEDULE_MAILSTACK_REPUTATION", "0 0 0 * * *")
 	defer os.Unsetenv("CRON_SCHEDULE_MAILSTACK_REPUTATION")
 
 	// Arrange
 	cfg := &config.Config{
-		AppConfig: &config.AppConfig{
-			Logger: &logger.Config{
-				LogLevel: "info",
-			},
-		},
+		AppConfig: &config.AppConfig{},
 	}
 	log := getLogger()
 	k8s := &mockKubernetesInterface{}
-	cm := NewCronManager(cfg, log, k8s, nil, nil, nil)
+	cm := NewCronManager(cfg, log, k8s, nil, nil, nil, nil)
 
 	// Create a mock cron for testing
-	mockCron := cronv3.New()
+	mockCron := cronv3.New(cronv3.WithSeconds())
 	mockCron.Start()
 	cm.cron = mockCron
 
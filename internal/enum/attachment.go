@@ -0,0 +1,14 @@
+package enum
+
+type AttachmentScanStatus string
+
+const (
+	AttachmentNotScanned AttachmentScanStatus = "not_scanned"
+	AttachmentClean      AttachmentScanStatus = "clean"
+	AttachmentInfected   AttachmentScanStatus = "infected"
+	AttachmentScanFailed AttachmentScanStatus = "scan_failed"
+)
+
+func (t AttachmentScanStatus) String() string {
+	return string(t)
+}
@@ -0,0 +1,37 @@
+package enum
+
+type AuditAction string
+
+const (
+	AuditDomainPurchased         AuditAction = "domain_purchased"
+	AuditDomainConfigured        AuditAction = "domain_configured"
+	AuditDomainAutoRenewChanged  AuditAction = "domain_auto_renew_changed"
+	AuditDNSRecordAdded          AuditAction = "dns_record_added"
+	AuditDNSRecordDeleted        AuditAction = "dns_record_deleted"
+	AuditAliasCreated            AuditAction = "alias_created"
+	AuditAliasRemoved            AuditAction = "alias_removed"
+	AuditCatchAllChanged         AuditAction = "catch_all_changed"
+	AuditMailboxCreated          AuditAction = "mailbox_created"
+	AuditMailboxConfigured       AuditAction = "mailbox_configured"
+	AuditMailboxDeleted          AuditAction = "mailbox_deleted"
+	AuditMailboxPasswordChanged  AuditAction = "mailbox_password_changed"
+	AuditMailboxSettingsUpdated  AuditAction = "mailbox_settings_updated"
+	AuditMailboxFolderCreated    AuditAction = "mailbox_folder_created"
+	AuditMailboxFolderDeleted    AuditAction = "mailbox_folder_deleted"
+	AuditMailboxMessagesImported AuditAction = "mailbox_messages_imported"
+)
+
+func (a AuditAction) String() string {
+	return string(a)
+}
+
+type AuditTarget string
+
+const (
+	AuditTargetDomain  AuditTarget = "domain"
+	AuditTargetMailbox AuditTarget = "mailbox"
+)
+
+func (t AuditTarget) String() string {
+	return string(t)
+}
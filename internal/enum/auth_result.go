@@ -0,0 +1,15 @@
+package enum
+
+// AuthResult is the outcome of verifying the SPF, DKIM or DMARC of an inbound message, named
+// like the results of an Authentication-Results header (RFC 8601)
+type AuthResult string
+
+const (
+	AuthResultPass      AuthResult = "pass"
+	AuthResultFail      AuthResult = "fail"
+	AuthResultSoftFail  AuthResult = "softfail" // SPF only
+	AuthResultNeutral   AuthResult = "neutral"  // SPF only
+	AuthResultNone      AuthResult = "none"     // nothing published or signed
+	AuthResultTempError AuthResult = "temperror"
+	AuthResultPermError AuthResult = "permerror"
+)
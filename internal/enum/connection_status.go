@@ -5,4 +5,7 @@ type ConnectionStatus string
 const (
 	ConnectionActive    ConnectionStatus = "active"
 	ConnectionNotActive ConnectionStatus = "not_active"
+	// ConnectionAuthFailed is set when the server rejected the credentials, the mailbox is not
+	// reconnected until they are updated
+	ConnectionAuthFailed ConnectionStatus = "auth_failed"
 )
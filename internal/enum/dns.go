@@ -0,0 +1,19 @@
+package enum
+
+type DNSRecordStatus string
+
+const (
+	DNSRecordPresent  DNSRecordStatus = "present"
+	DNSRecordMissing  DNSRecordStatus = "missing"
+	DNSRecordMismatch DNSRecordStatus = "mismatch"
+)
+
+// MailAuthStatus is the outcome of one check of the mail authentication self-check
+type MailAuthStatus string
+
+const (
+	MailAuthPass       MailAuthStatus = "pass"
+	MailAuthFail       MailAuthStatus = "fail"
+	MailAuthMissing    MailAuthStatus = "missing"
+	MailAuthUnverified MailAuthStatus = "unverified" // nothing stored to compare against
+)
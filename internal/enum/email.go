@@ -19,10 +19,12 @@ const (
 	EmailAutoResponder      EmailClassification = "auto_responder"
 	EmailBounceNotification EmailClassification = "bounce_notification"
 	EmailBulk               EmailClassification = "bulk_email"
+	EmailCalendarInvite     EmailClassification = "calendar_invite"
 	EmailInternal           EmailClassification = "internal"
 	EmailOK                 EmailClassification = "ok"
 	EmailSensitive          EmailClassification = "sensitive"
 	EmailSpam               EmailClassification = "spam"
+	EmailSuspicious         EmailClassification = "suspicious" // likely spoofing or phishing
 	EmailWarmer             EmailClassification = "email_warmer"
 )
 
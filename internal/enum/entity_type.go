@@ -5,6 +5,8 @@ type EntityType string
 const (
 	EMAIL_SIGNATURE EntityType = "EMAIL_SIGNATURE"
 	EMAIL           EntityType = "EMAIL"
+	DOMAIN          EntityType = "DOMAIN"
+	THREAD          EntityType = "THREAD"
 )
 
 func (entityType EntityType) String() string {
@@ -0,0 +1,13 @@
+package enum
+
+type ReplyMode string
+
+const (
+	ReplyModeReply    ReplyMode = "reply"
+	ReplyModeReplyAll ReplyMode = "reply_all"
+	ReplyModeForward  ReplyMode = "forward"
+)
+
+func (m ReplyMode) String() string {
+	return string(m)
+}
@@ -6,13 +6,47 @@ var (
 	// common errors
 	ErrTenantMissing     = errors.New("tenant is missing")
 	ErrConnectionTimeout = errors.New("connection timeout")
+	ErrInvalidCursor     = errors.New("invalid pagination cursor")
+
+	// email errors
+	ErrMessageTooLarge = errors.New("message exceeds maximum size")
+	ErrEmailNotDeleted = errors.New("email is not deleted")
 
 	// domain errors
 	ErrDomainNotFound            = errors.New("domain not found")
 	ErrDomainConfigurationFailed = errors.New("domain configuration failed")
+	ErrDNSRecordsNotPublished    = errors.New("dns records not published")
+	ErrDomainNotStored           = errors.New("domain purchased but not stored")
 
 	// mailbox errors
 	ErrMailboxExists           = errors.New("mailbox already exists")
 	ErrMailboxNotFound         = errors.New("mailbox not found")
 	ErrMailboxNotOwnedByTenant = errors.New("mailbox does not belong to tenant")
+	ErrInvalidAlias            = errors.New("invalid alias")
+	ErrSyncFolderNotFound      = errors.New("folder is not synced for mailbox")
+	ErrResyncInProgress        = errors.New("resync already in progress")
+	ErrSentFolderNotFound      = errors.New("sent folder not found")
+	ErrIMAPAuthFailed          = errors.New("imap authentication failed")
+	ErrMessageNotOnServer      = errors.New("message not found on server")
+	ErrIMAPNotConfigured       = errors.New("mailbox has no imap access")
+	ErrInvalidFolderName       = errors.New("invalid folder name")
+	ErrFolderNotFound          = errors.New("folder not found on server")
+	ErrFolderExists            = errors.New("folder already exists on server")
+	ErrFolderProtected         = errors.New("folder can not be deleted")
+	ErrInvalidSyncSettings     = errors.New("invalid sync settings")
+
+	// OpenSRS errors
+	ErrOpenSRSAuthFailed       = errors.New("opensrs authentication failed")
+	ErrOpenSRSPermissionDenied = errors.New("opensrs permission denied")
+	ErrOpenSRSInvalidRequest   = errors.New("opensrs rejected the request data")
+	ErrOpenSRSObjectNotFound   = errors.New("opensrs object not found")
+	ErrOpenSRSUnavailable      = errors.New("opensrs is unavailable")
+
+	// thread errors
+	ErrThreadNotFound        = errors.New("thread not found")
+	ErrThreadMailboxMismatch = errors.New("threads belong to different mailboxes")
+	ErrEmailNotInThread      = errors.New("email is not in the thread")
+	ErrInvalidThreadMerge    = errors.New("invalid thread merge")
+	ErrInvalidThreadSplit    = errors.New("invalid thread split")
+	ErrThreadNotDeleted      = errors.New("thread is not deleted")
 )
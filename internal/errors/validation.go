@@ -0,0 +1,92 @@
+package mailstack_errors
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ValidationCode identifies a problem with an email to send, so clients can react to it
+type ValidationCode string
+
+const (
+	ValidationMissingFrom        ValidationCode = "MISSING_FROM"
+	ValidationInvalidFrom        ValidationCode = "INVALID_FROM"
+	ValidationDomainMismatch     ValidationCode = "DOMAIN_MISMATCH"
+	ValidationNoRecipients       ValidationCode = "NO_RECIPIENTS"
+	ValidationInvalidRecipient   ValidationCode = "INVALID_RECIPIENT"
+	ValidationUndeliverable      ValidationCode = "UNDELIVERABLE_RECIPIENT"
+	ValidationEmptyBody          ValidationCode = "EMPTY_BODY"
+	ValidationNoSubject          ValidationCode = "NO_SUBJECT"
+	ValidationInvalidUnsubscribe ValidationCode = "INVALID_UNSUBSCRIBE"
+	ValidationInvalidSchedule    ValidationCode = "INVALID_SCHEDULE"
+	ValidationInvalidHeader      ValidationCode = "INVALID_HEADER"
+)
+
+// ValidationError is one problem of an email. Err is the sentinel error of the problem, if
+// there is one, so errors.Is keeps matching it.
+type ValidationError struct {
+	Code    ValidationCode `json:"code"`
+	Field   string         `json:"field,omitempty"`
+	Message string         `json:"message"`
+	Err     error          `json:"-"`
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationErrors collects every problem of an email, instead of stopping at the first one
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Message)
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+func (e ValidationErrors) Unwrap() []error {
+	errs := make([]error, 0, len(e))
+	for _, err := range e {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// Add records a problem, err is the sentinel it matches and may be nil
+func (e *ValidationErrors) Add(code ValidationCode, field, message string, err error) {
+	*e = append(*e, &ValidationError{Code: code, Field: field, Message: message, Err: err})
+}
+
+// HasCode tells whether a problem with the code was found
+func (e ValidationErrors) HasCode(code ValidationCode) bool {
+	for _, err := range e {
+		if err.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns the collected problems as an error, nil when there are none
+func (e ValidationErrors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// AsValidationErrors returns the problems carried by err, if it is a validation error
+func AsValidationErrors(err error) (ValidationErrors, bool) {
+	var validationErrors ValidationErrors
+	if errors.As(err, &validationErrors) {
+		return validationErrors, true
+	}
+	return nil, false
+}
@@ -0,0 +1,50 @@
+package listeners
+
+import (
+	"context"
+
+	"github.com/opentracing/opentracing-go"
+
+	"github.com/customeros/mailstack/dto"
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/logger"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/services/events"
+)
+
+type EnrichEmailListener struct {
+	events.BaseEventListener
+	emailProcessor interfaces.EmailProcessor
+}
+
+func NewEnrichEmailListener(logger logger.Logger, emailProcessor interfaces.EmailProcessor) interfaces.EventListener {
+	return &EnrichEmailListener{
+		BaseEventListener: events.NewBaseEventListener(
+			logger,
+			events.GetEventType[dto.EnrichEmail](), // subscribed event
+			events.QueueEnrichEmail,                // listening on Direct queue
+		),
+		emailProcessor: emailProcessor,
+	}
+}
+
+func (l *EnrichEmailListener) Handle(ctx context.Context, baseEvent any) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "EnrichEmailListener.Handle")
+	defer span.Finish()
+	tracing.SetDefaultListenerSpanTags(ctx, span)
+	tracing.LogObjectAsJson(span, "event", baseEvent)
+
+	validatedEvent, err := l.ValidateBaseEvent(ctx, baseEvent)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	enrichEmail, err := events.DecodeEventData[dto.EnrichEmail](ctx, validatedEvent)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	return l.emailProcessor.EnrichEmail(ctx, enrichEmail.EmailID)
+}
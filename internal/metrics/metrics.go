@@ -0,0 +1,74 @@
+// Package metrics holds the Prometheus metrics of the service, exposed on /metrics
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "mailstack"
+
+// Operations an email can fail in
+const (
+	OperationIngest = "ingest"
+	OperationSend   = "send"
+)
+
+var (
+	// EmailsIngested counts messages fetched from a mailbox and queued for processing
+	EmailsIngested = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "emails_ingested_total",
+		Help:      "Messages fetched from mailboxes and queued for processing.",
+	}, []string{"source"})
+
+	// EmailsSent counts emails accepted by the SMTP server
+	EmailsSent = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "emails_sent_total",
+		Help:      "Emails accepted by the SMTP server.",
+	})
+
+	// EmailsFailed counts emails that could not be ingested or sent
+	EmailsFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "emails_failed_total",
+		Help:      "Emails that failed to be ingested or sent, by operation.",
+	}, []string{"operation"})
+
+	// SendDuration observes the SMTP conversation of a send, from connecting to the reply to the data
+	SendDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "smtp_send_duration_seconds",
+		Help:      "Duration of sending an email to the SMTP server.",
+		Buckets:   prometheus.ExponentialBuckets(0.1, 2, 10),
+	}, []string{"result"})
+
+	// IMAPConnections is the number of mailboxes connected to their IMAP server
+	IMAPConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "imap_active_connections",
+		Help:      "Mailboxes connected to their IMAP server.",
+	})
+
+	// IMAPReconnects counts connection attempts after the first one of a mailbox
+	IMAPReconnects = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "imap_reconnects_total",
+		Help:      "IMAP reconnection attempts.",
+	})
+
+	// QueuePublishFailures counts failed publish attempts, retries included
+	QueuePublishFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "queue_publish_failures_total",
+		Help:      "Failed attempts to publish a message to RabbitMQ, by exchange.",
+	}, []string{"exchange"})
+
+	// DLQDepthDesc describes the messages waiting in a dead letter queue, read from RabbitMQ when scraped
+	DLQDepthDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "dlq_depth"),
+		"Messages waiting in a dead letter queue.",
+		[]string{"queue"}, nil,
+	)
+)
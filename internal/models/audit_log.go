@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/customeros/mailstack/internal/enum"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+// AuditLog records a mutation of a domain or mailbox of a tenant: who made it, what was changed
+// and a summary of the target before and after. Summaries never hold passwords or other secrets.
+type AuditLog struct {
+	ID         string           `gorm:"column:id;type:varchar(50);primaryKey" json:"id"`
+	Tenant     string           `gorm:"column:tenant;type:varchar(255);not null;index:idx_audit_logs_tenant_created_at,priority:1" json:"tenant"`
+	ActorID    string           `gorm:"column:actor_id;type:varchar(255)" json:"actorId"`       // user id, empty for API key calls without one
+	ActorEmail string           `gorm:"column:actor_email;type:varchar(255)" json:"actorEmail"` // empty when unknown
+	RequestID  string           `gorm:"column:request_id;type:varchar(255)" json:"requestId,omitempty"`
+	Action     enum.AuditAction `gorm:"column:action;type:varchar(50);not null" json:"action"`
+	TargetType enum.AuditTarget `gorm:"column:target_type;type:varchar(50);not null" json:"targetType"`
+	TargetID   string           `gorm:"column:target_id;type:varchar(255);not null" json:"targetId"` // domain name, mailbox address or mailbox id
+	Before     JSONMap          `gorm:"column:before;type:jsonb" json:"before,omitempty"`
+	After      JSONMap          `gorm:"column:after;type:jsonb" json:"after,omitempty"`
+	CreatedAt  time.Time        `gorm:"column:created_at;type:timestamp;default:current_timestamp;index:idx_audit_logs_tenant_created_at,priority:2" json:"createdAt"`
+}
+
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
+
+func (m *AuditLog) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == "" {
+		m.ID = utils.GenerateNanoIDWithPrefix("adlg", 16)
+	}
+	return nil
+}
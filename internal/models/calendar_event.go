@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// CalendarEvent is the event of a calendar invite (RFC 5545 VEVENT) carried by an email
+type CalendarEvent struct {
+	Method         string     `json:"method,omitempty"` // REQUEST, CANCEL, REPLY...
+	UID            string     `json:"uid,omitempty"`
+	Status         string     `json:"status,omitempty"`
+	Summary        string     `json:"summary,omitempty"`
+	Location       string     `json:"location,omitempty"`
+	Start          *time.Time `json:"start,omitempty"`
+	End            *time.Time `json:"end,omitempty"`
+	AllDay         bool       `json:"allDay"`
+	OrganizerName  string     `json:"organizerName,omitempty"`
+	OrganizerEmail string     `json:"organizerEmail,omitempty"`
+	Attendees      []string   `json:"attendees,omitempty"`
+}
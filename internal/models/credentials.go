@@ -0,0 +1,86 @@
+package models
+
+import (
+	"log"
+
+	"gorm.io/gorm"
+
+	"github.com/customeros/mailstack/internal/secrets"
+)
+
+// Credentials are encrypted right before they are written and decrypted once loaded, so the
+// rest of the code only ever sees plaintext. The id of the key a value is encrypted with is
+// stored in its own column, an empty key id marks a plaintext value. Updates with column maps
+// skip the hooks and have to encrypt the value and set its key id themselves.
+
+// credential is a password field and the key id column stored with it
+type credential struct {
+	value *string
+	keyID *string
+}
+
+func encryptCredentials(credentials ...credential) error {
+	for _, c := range credentials {
+		if *c.keyID != "" {
+			continue // still encrypted, a failed save is not followed by AfterSave
+		}
+		encrypted, keyID, err := secrets.Encrypt(*c.value)
+		if err != nil {
+			return err
+		}
+		*c.value, *c.keyID = encrypted, keyID
+	}
+	return nil
+}
+
+// decryptCredentials never fails, one unreadable value must not fail a find of many rows. A value
+// that can not be decrypted is cleared, the mailbox fails to log in until its password is set again.
+func decryptCredentials(table string, credentials ...credential) {
+	for _, c := range credentials {
+		decrypted, err := secrets.Decrypt(*c.value, *c.keyID)
+		if err != nil {
+			log.Printf("Failed to decrypt credentials in %s with key %s: %v", table, *c.keyID, err)
+			decrypted = ""
+		}
+		*c.value, *c.keyID = decrypted, ""
+	}
+}
+
+func (m *Mailbox) credentials() []credential {
+	return []credential{
+		{value: &m.ImapPassword, keyID: &m.ImapPasswordKeyID},
+		{value: &m.SmtpPassword, keyID: &m.SmtpPasswordKeyID},
+	}
+}
+
+func (m *Mailbox) BeforeSave(tx *gorm.DB) error {
+	return encryptCredentials(m.credentials()...)
+}
+
+func (m *Mailbox) AfterSave(tx *gorm.DB) error {
+	decryptCredentials(m.TableName(), m.credentials()...)
+	return nil
+}
+
+func (m *Mailbox) AfterFind(tx *gorm.DB) error {
+	decryptCredentials(m.TableName(), m.credentials()...)
+	return nil
+}
+
+func (m *TenantSettingsMailbox) credentials() []credential {
+	return []credential{{value: &m.MailboxPassword, keyID: &m.MailboxPasswordKeyID}}
+}
+
+func (m *TenantSettingsMailbox) BeforeSave(tx *gorm.DB) error {
+	return encryptCredentials(m.credentials()...)
+}
+
+func (m *TenantSettingsMailbox) AfterSave(tx *gorm.DB) error {
+	decryptCredentials(m.TableName(), m.credentials()...)
+	return nil
+}
+
+func (m *TenantSettingsMailbox) AfterFind(tx *gorm.DB) error {
+	decryptCredentials(m.TableName(), m.credentials()...)
+	return nil
+}
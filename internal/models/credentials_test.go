@@ -0,0 +1,57 @@
+package models
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/internal/secrets"
+)
+
+func TestMailboxCredentialHooks(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("a", 32)))
+	keyring, err := secrets.NewKeyring("v1:"+key, "")
+	require.NoError(t, err)
+
+	t.Run("password that looks like an encrypted value", func(t *testing.T) {
+		secrets.SetDefault(nil)
+		t.Cleanup(func() { secrets.SetDefault(nil) })
+
+		// stored in plaintext without a keyring and read back as it is
+		mailbox := &Mailbox{ImapPassword: "enc:x:y", SmtpPassword: "enc:v1:abc"}
+		require.NoError(t, mailbox.BeforeSave(nil))
+		assert.Equal(t, "enc:x:y", mailbox.ImapPassword)
+		assert.Empty(t, mailbox.ImapPasswordKeyID)
+		require.NoError(t, mailbox.AfterFind(nil))
+		assert.Equal(t, "enc:x:y", mailbox.ImapPassword)
+		assert.Equal(t, "enc:v1:abc", mailbox.SmtpPassword)
+
+		// encrypted once a keyring is set
+		secrets.SetDefault(keyring)
+		require.NoError(t, mailbox.BeforeSave(nil))
+		assert.Equal(t, "v1", mailbox.ImapPasswordKeyID)
+		assert.NotEqual(t, "enc:x:y", mailbox.ImapPassword)
+		require.NoError(t, mailbox.AfterFind(nil))
+		assert.Equal(t, "enc:x:y", mailbox.ImapPassword)
+		assert.Equal(t, "enc:v1:abc", mailbox.SmtpPassword)
+		assert.Empty(t, mailbox.ImapPasswordKeyID)
+	})
+
+	t.Run("unreadable value does not fail the find", func(t *testing.T) {
+		secrets.SetDefault(nil)
+		t.Cleanup(func() { secrets.SetDefault(nil) })
+
+		mailbox := &Mailbox{ImapPassword: "sealed", ImapPasswordKeyID: "v1", SmtpPassword: "plain"}
+		require.NoError(t, mailbox.AfterFind(nil))
+		assert.Empty(t, mailbox.ImapPassword)
+		assert.Equal(t, "plain", mailbox.SmtpPassword)
+
+		tenantMailbox := &TenantSettingsMailbox{MailboxPassword: "not base64", MailboxPasswordKeyID: "v1"}
+		secrets.SetDefault(keyring)
+		require.NoError(t, tenantMailbox.AfterFind(nil))
+		assert.Empty(t, tenantMailbox.MailboxPassword)
+	})
+}
@@ -7,6 +7,8 @@ type DMARCMonitoring struct {
 	Tenant        string    `gorm:"column:tenant;type:varchar(255);NOT NULL" json:"tenant"`
 	CreatedAt     time.Time `gorm:"column:created_at;type:timestamp;DEFAULT:current_timestamp" json:"createdAt"`
 	EmailProvider string    `gorm:"column:email_provider;type:varchar(255)" json:"emailProvider"`
+	ReportingOrg  string    `gorm:"column:reporting_org;type:varchar(255);index:idx_dmarc_report,priority:1" json:"reportingOrg"`
+	ReportID      string    `gorm:"column:report_id;type:varchar(255);index:idx_dmarc_report,priority:2" json:"reportId"`
 	Domain        string    `gorm:"column:domain;type:varchar(255)" json:"domain"`
 	ReportStart   time.Time `gorm:"column:report_start;type:timestamp" json:"reportStart"`
 	ReportEnd     time.Time `gorm:"column:report_end;type:timestamp" json:"reportEnd"`
@@ -2,8 +2,10 @@ package models
 
 import (
 	"fmt"
+	"mime"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/lib/pq"
 	"gorm.io/gorm"
@@ -39,16 +41,34 @@ type Email struct {
 	TrackClicks  bool           `gorm:"column:track_clicks;default:false" json:"trackClicks"`
 	IsViewed     bool           `gorm:"column:isViewed;default:false" json:"isViewed"`
 
+	// One-click unsubscribe (RFC 2369 / RFC 8058), set per campaign for bulk sends
+	UnsubscribeURL    string `gorm:"column:unsubscribe_url;type:varchar(2000)" json:"unsubscribeUrl"`
+	UnsubscribeMailto string `gorm:"column:unsubscribe_mailto;type:varchar(255)" json:"unsubscribeMailto"`
+
 	// Content
 	BodyText      string `gorm:"column:body_text;type:text" json:"bodyText"`
 	BodyHTML      string `gorm:"column:body_html;type:text" json:"bodyHtml"`
 	BodyMarkdown  string `gorm:"column:body_markdown;type:text" json:"bodyMarkdown"`
+	VisibleText   string `gorm:"column:visible_text;type:text" json:"visibleText"` // Latest reply only
+	QuotedText    string `gorm:"column:quoted_text;type:text" json:"quotedText"`   // Quoted history and signature
 	HasAttachment bool   `gorm:"column:has_attachment;default:false" json:"hasAttachment"`
 	HasSignature  bool   `gorm:"column:has_signature;default:false" json:"hasSignature"`
+	Language      string `gorm:"column:language;type:varchar(10)" json:"language"` // ISO 639-1, empty when undetected
+
+	// Set when the email carries a calendar invite
+	CalendarEvent *CalendarEvent `gorm:"column:calendar_event;type:jsonb;serializer:json" json:"calendarEvent,omitempty"`
 
 	// Send Details
 	StatusDetail string `gorm:"column:status_detail;type:text" json:"statusDetail"` // Error message or delivery info
 	SendAttempts int    `gorm:"column:send_attempts;default:0" json:"sendAttempts"` // Number of send attempts
+	// Recipients left out of the send because their address is invalid
+	SkippedRecipients pq.StringArray `gorm:"column:skipped_recipients;type:text[]" json:"skippedRecipients,omitempty"`
+
+	// Metrics of the last send attempt
+	MessageSizeBytes int    `gorm:"column:message_size_bytes;default:0" json:"messageSizeBytes"`
+	RecipientCount   int    `gorm:"column:recipient_count;default:0" json:"recipientCount"`
+	SendDurationMs   int64  `gorm:"column:send_duration_ms;default:0" json:"sendDurationMs"`
+	SmtpResponse     string `gorm:"column:smtp_response;type:text" json:"smtpResponse"` // Server reply to the message data, or the error reply
 
 	// Time information
 	SentAt        *time.Time `gorm:"column:sent_at;type:timestamp;index" json:"sentAt"`
@@ -64,14 +84,19 @@ type Email struct {
 	RawHeaders    JSONMap `gorm:"column:raw_headers;type:jsonb" json:"rawHeaders"`
 	Envelope      JSONMap `gorm:"column:envelope;type:jsonb" json:"envelope"`
 	BodyStructure JSONMap `gorm:"column:body_structure;type:jsonb" json:"bodyStructure"`
+	// RawKey is the attachment storage key of the original RFC 822 source, empty when not stored
+	RawKey string `gorm:"column:raw_key;type:varchar(255)" json:"rawKey,omitempty"`
 
 	// Classification
 	Classification       enum.EmailClassification `gorm:"column:classification;type:varchar(50);index" json:"classification"`
 	ClassificationReason string                   `gorm:"column:classification_reason;type:text" json:"classificationReason"`
+	// SPF, DKIM and DMARC of inbound emails, nil when not verified
+	AuthResults *MailAuthResults `gorm:"column:auth_results;type:jsonb;serializer:json" json:"authResults,omitempty"`
 
 	// Standard timestamps
-	CreatedAt time.Time `gorm:"column:created_at;type:timestamp;default:current_timestamp" json:"createdAt"`
-	UpdatedAt time.Time `gorm:"column:updated_at;type:timestamp;default:current_timestamp" json:"updatedAt"`
+	CreatedAt time.Time      `gorm:"column:created_at;type:timestamp;default:current_timestamp" json:"createdAt"`
+	UpdatedAt time.Time      `gorm:"column:updated_at;type:timestamp;default:current_timestamp" json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `gorm:"column:deleted_at;index" json:"deletedAt"` // Set while in the trash
 }
 
 func (Email) TableName() string {
@@ -205,7 +230,9 @@ func (e *Email) Headers() (*EmailHeaders, error) {
 	return headers, nil
 }
 
-// BuildHeaders creates a map of headers for an outgoing email
+// BuildHeaders creates a map of headers for an outgoing email. It never contains a Bcc header,
+// blind copy recipients only receive the message through the SMTP envelope. Custom headers of the
+// sender, e.g. X-Campaign-ID, are taken from RawHeaders without replacing the derived ones.
 func (e *Email) BuildHeaders() map[string]string {
 	header := make(map[string]string)
 
@@ -265,18 +292,34 @@ func (e *Email) BuildHeaders() map[string]string {
 	// X-Mailer helps identify your system
 	header["X-Mailer"] = "CustomerOS Mailstack"
 
+	// List-Unsubscribe, one-click only applies to an https url
+	if listUnsubscribe := e.listUnsubscribeHeader(); listUnsubscribe != "" {
+		header["List-Unsubscribe"] = listUnsubscribe
+		if strings.HasPrefix(strings.ToLower(e.UnsubscribeURL), "https://") {
+			header["List-Unsubscribe-Post"] = "List-Unsubscribe=One-Click"
+		}
+	}
+
 	// Add custom headers from RawHeaders if any
 	if e.RawHeaders != nil {
+		set := make(map[string]bool, len(header))
+		for k := range header {
+			set[strings.ToLower(k)] = true
+		}
 		for k, v := range e.RawHeaders {
-			// Skip headers we've already set
-			if _, exists := header[k]; !exists {
+			// Bcc recipients only go in the SMTP envelope, a header would show them to everyone
+			if isBccHeader(k) {
+				continue
+			}
+			// Skip headers we've already set, in whatever case they are spelled
+			if !set[strings.ToLower(k)] {
 				// Handle different value types (string or []string)
 				switch value := v.(type) {
 				case string:
-					header[k] = value
+					header[k] = encodeHeaderValue(value)
 				case []string:
 					if len(value) > 0 {
-						header[k] = strings.Join(value, ", ")
+						header[k] = encodeHeaderValue(strings.Join(value, ", "))
 					}
 				}
 			}
@@ -286,6 +329,64 @@ func (e *Email) BuildHeaders() map[string]string {
 	return header
 }
 
+// SetCustomHeaders stores headers of the sender to be added to the message by BuildHeaders
+func (e *Email) SetCustomHeaders(headers map[string]string) {
+	if len(headers) == 0 {
+		return
+	}
+	if e.RawHeaders == nil {
+		e.RawHeaders = make(JSONMap, len(headers))
+	}
+	for name, value := range headers {
+		e.RawHeaders[name] = value
+	}
+}
+
+// reservedHeaders are set from the fields of the email and can not be given as custom headers
+var reservedHeaders = []string{
+	"From", "Sender", "To", "Cc", "Bcc", "Resent-Bcc", "Reply-To", "Return-Path", "Subject", "Date",
+	"Message-ID", "In-Reply-To", "References", "MIME-Version", "Content-Type",
+	"Content-Transfer-Encoding", "List-Unsubscribe", "List-Unsubscribe-Post",
+}
+
+// IsReservedHeader tells whether a header name, in any case, is one BuildHeaders derives itself
+func IsReservedHeader(name string) bool {
+	for _, reserved := range reservedHeaders {
+		if strings.EqualFold(name, reserved) {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeHeaderValue keeps ASCII values as they are and encodes others as RFC 2047 words
+func encodeHeaderValue(value string) string {
+	for _, r := range value {
+		if r >= utf8.RuneSelf {
+			return mime.QEncoding.Encode("utf-8", value)
+		}
+	}
+	return value
+}
+
+// isBccHeader matches the headers that would disclose blind copy recipients, in any case
+func isBccHeader(name string) bool {
+	return strings.EqualFold(name, "Bcc") || strings.EqualFold(name, "Resent-Bcc")
+}
+
+func (e *Email) listUnsubscribeHeader() string {
+	var values []string
+	if e.UnsubscribeMailto != "" {
+		values = append(values, fmt.Sprintf("<mailto:%s?subject=unsubscribe>", e.UnsubscribeMailto))
+	}
+	if e.UnsubscribeURL != "" {
+		values = append(values, fmt.Sprintf("<%s>", e.UnsubscribeURL))
+	}
+	return strings.Join(values, ", ")
+}
+
+// AllRecipients returns the To, Cc and Bcc recipients, the SMTP envelope of an outgoing email.
+// Bcc recipients are never put in the headers, see BuildHeaders.
 func (e *Email) AllRecipients() []string {
 	// Pre-allocate slice with enough capacity
 	recipients := make([]string, 0, len(e.ToAddresses)+len(e.CcAddresses)+len(e.BccAddresses))
@@ -6,9 +6,13 @@ import (
 	"github.com/lib/pq"
 	"gorm.io/gorm"
 
+	"github.com/customeros/mailstack/internal/enum"
 	"github.com/customeros/mailstack/internal/utils"
 )
 
+// AttachmentPublicBaseURL is where stored attachments are served from
+const AttachmentPublicBaseURL = "https://files.cust.cx/"
+
 // EmailAttachment represents an attachment to an email
 type EmailAttachment struct {
 	ID          string         `gorm:"type:varchar(50);primaryKey"`
@@ -21,12 +25,16 @@ type EmailAttachment struct {
 	IsInline    bool           `gorm:"default:false"`
 
 	// Storage options
-	StorageService string `gorm:"type:varchar(50)"`   // "s3", "azure", "local", etc.
+	StorageService string `gorm:"type:varchar(50)"`   // R2, S3 or MINIO
 	StorageBucket  string `gorm:"type:varchar(255)"`  // For cloud storage
 	StorageKey     string `gorm:"type:varchar(1000)"` // If stored in S3/blob storage
+	PreviewKey     string `gorm:"type:varchar(1000)"` // JPEG thumbnail of images and PDFs, stored next to the original
 
 	// Security and verification
-	ContentHash string `gorm:"type:varchar(64);index"` // SHA-256 hash of content
+	ContentHash   string                    `gorm:"type:varchar(64);index"` // SHA-256 hash of content
+	ScanStatus    enum.AttachmentScanStatus `gorm:"type:varchar(20);default:not_scanned"`
+	ScanSignature string                    `gorm:"type:varchar(255)"` // Malware signature when infected
+	ScannedAt     *time.Time                `gorm:"type:timestamp"`
 
 	// Standard timestamps
 	CreatedAt time.Time `gorm:"column:created_at;type:timestamp;default:current_timestamp" json:"createdAt"`
@@ -38,6 +46,11 @@ func (EmailAttachment) TableName() string {
 	return "email_attachments"
 }
 
+// PublicURL returns the URL of the stored attachment
+func (e *EmailAttachment) PublicURL() string {
+	return AttachmentPublicBaseURL + e.StorageKey
+}
+
 func (e *EmailAttachment) BeforeCreate(tx *gorm.DB) error {
 	if e.ID == "" {
 		e.ID = utils.GenerateNanoIDWithPrefix("file", 12)
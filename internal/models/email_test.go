@@ -0,0 +1,33 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildHeadersCustomHeaders(t *testing.T) {
+	email := &Email{
+		FromAddress: "jane@acme.io",
+		ToAddresses: pq.StringArray{"bob@corp.io"},
+		ReplyTo:     "sales@acme.io",
+		Subject:     "Launch",
+		MessageID:   "<abc@acme.io>",
+	}
+	email.SetCustomHeaders(map[string]string{
+		"X-Campaign-ID": "q4-launch",
+		"List-ID":       "<news.acme.io>",
+		"X-Greeting":    "Grüße",
+		"message-id":    "<forged@acme.io>",
+	})
+
+	headers := email.BuildHeaders()
+	assert.Equal(t, "q4-launch", headers["X-Campaign-ID"])
+	assert.Equal(t, "<news.acme.io>", headers["List-ID"])
+	assert.Equal(t, "=?utf-8?q?Gr=C3=BC=C3=9Fe?=", headers["X-Greeting"])
+	assert.Equal(t, "sales@acme.io", headers["Reply-To"])
+	// the derived header wins, whatever the case of the custom one
+	assert.Equal(t, "<abc@acme.io>", headers["Message-ID"])
+	assert.NotContains(t, headers, "message-id")
+}
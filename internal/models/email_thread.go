@@ -1,6 +1,11 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/lib/pq"
@@ -10,18 +15,23 @@ import (
 )
 
 type EmailThread struct {
-	ID             string         `gorm:"column:id;type:varchar(50);primaryKey" json:"id"`
-	MailboxID      string         `gorm:"column:mailbox_id;type:varchar(50);index" json:"mailboxId"`
-	Subject        string         `gorm:"column:subject;type:varchar(1000)" json:"subject"`
-	Summary        string         `gorm:"column:summary;type:varchar(1000)" json:"summary"`
-	Participants   pq.StringArray `gorm:"column:participants;type:text[]" json:"participants"`
-	LastMessageID  string         `gorm:"column:last_message_id;type:varchar(255)" json:"lastMessageId"`
-	HasAttachments bool           `gorm:"column:has_attachments;default:false" json:"hasAttachments"`
-	IsDone         bool           `gorm:"column:isDone;default:false" json:"isDone"`
-	LastMessageAt  *time.Time     `gorm:"column:last_message_at;type:timestamp" json:"lastMessageAt"`
-	FirstMessageAt *time.Time     `gorm:"column:first_message_at;type:timestamp" json:"firstMessageAt"`
-	CreatedAt      time.Time      `gorm:"column:created_at;type:timestamp;default:current_timestamp" json:"createdAt"`
-	UpdatedAt      time.Time      `gorm:"column:updated_at;type:timestamp;default:current_timestamp" json:"updatedAt"`
+	ID           string         `gorm:"column:id;type:varchar(50);primaryKey" json:"id"`
+	MailboxID    string         `gorm:"column:mailbox_id;type:varchar(50);index" json:"mailboxId"`
+	Subject      string         `gorm:"column:subject;type:varchar(1000)" json:"subject"`
+	Summary      string         `gorm:"column:summary;type:varchar(1000)" json:"summary"`
+	Participants pq.StringArray `gorm:"column:participants;type:text[]" json:"participants"`
+	// ParticipantRoles counts per address how often it appeared as sender and recipient
+	ParticipantRoles ThreadParticipants `gorm:"column:participant_roles;type:jsonb" json:"participantRoles"`
+	LastMessageID    string             `gorm:"column:last_message_id;type:varchar(255)" json:"lastMessageId"`
+	HasAttachments   bool               `gorm:"column:has_attachments;default:false" json:"hasAttachments"`
+	IsDone           bool               `gorm:"column:isDone;default:false" json:"isDone"`
+	IsViewed         bool               `gorm:"column:is_viewed;default:false" json:"isViewed"`
+	LastMessageAt    *time.Time         `gorm:"column:last_message_at;type:timestamp" json:"lastMessageAt"`
+	FirstMessageAt   *time.Time         `gorm:"column:first_message_at;type:timestamp" json:"firstMessageAt"`
+	CreatedAt        time.Time          `gorm:"column:created_at;type:timestamp;default:current_timestamp" json:"createdAt"`
+	UpdatedAt        time.Time          `gorm:"column:updated_at;type:timestamp;default:current_timestamp" json:"updatedAt"`
+	// DeletedAt is set while the thread is in the trash, its emails are deleted with it
+	DeletedAt gorm.DeletedAt `gorm:"column:deleted_at;index" json:"deletedAt"`
 }
 
 func (EmailThread) TableName() string {
@@ -35,3 +45,125 @@ func (e *EmailThread) BeforeCreate(tx *gorm.DB) error {
 	e.CreatedAt = utils.Now()
 	return nil
 }
+
+// Recompute rebuilds the participants, attachment flag and message times of the thread from its
+// emails, used when emails move between threads
+func (e *EmailThread) Recompute(emails []*Email) {
+	e.Participants = pq.StringArray{}
+	e.ParticipantRoles = ThreadParticipants{}
+	e.HasAttachments = false
+	e.FirstMessageAt, e.LastMessageAt, e.LastMessageID = nil, nil, ""
+
+	for _, email := range emails {
+		for _, participant := range email.AllParticipants() {
+			if !utils.IsStringInSlice(participant, e.Participants) {
+				e.Participants = append(e.Participants, participant)
+			}
+		}
+		e.ParticipantRoles.Record(email)
+		e.HasAttachments = e.HasAttachments || email.HasAttachment
+
+		messageAt := email.SentAt
+		if messageAt == nil {
+			messageAt = email.ReceivedAt
+		}
+		if messageAt == nil {
+			continue
+		}
+		if e.FirstMessageAt == nil || messageAt.Before(*e.FirstMessageAt) {
+			e.FirstMessageAt = messageAt
+		}
+		if e.LastMessageAt == nil || messageAt.After(*e.LastMessageAt) {
+			e.LastMessageAt = messageAt
+			e.LastMessageID = strings.Trim(email.MessageID, "<>")
+		}
+	}
+}
+
+// MetadataDiff returns the json names of the metadata fields Recompute rebuilds that differ
+// between the thread and other. Participants are compared regardless of their order.
+func (e *EmailThread) MetadataDiff(other *EmailThread) []string {
+	var fields []string
+	if !sameParticipants(e.Participants, other.Participants) {
+		fields = append(fields, "participants")
+	}
+	if !reflect.DeepEqual(e.ParticipantRoles, other.ParticipantRoles) {
+		fields = append(fields, "participantRoles")
+	}
+	if e.HasAttachments != other.HasAttachments {
+		fields = append(fields, "hasAttachments")
+	}
+	if !sameTime(e.FirstMessageAt, other.FirstMessageAt) {
+		fields = append(fields, "firstMessageAt")
+	}
+	if !sameTime(e.LastMessageAt, other.LastMessageAt) {
+		fields = append(fields, "lastMessageAt")
+	}
+	if e.LastMessageID != other.LastMessageID {
+		fields = append(fields, "lastMessageId")
+	}
+	return fields
+}
+
+func sameParticipants(a, b []string) bool {
+	return slices.Equal(slices.Sorted(slices.Values(a)), slices.Sorted(slices.Values(b)))
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// ParticipantRoleCounts is how many emails of a thread had the participant in each role
+type ParticipantRoleCounts struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+	Cc   int `json:"cc"`
+}
+
+// ThreadParticipants maps lowercased addresses to their role counts in the thread
+type ThreadParticipants map[string]*ParticipantRoleCounts
+
+// Record counts the sender and the to and cc recipients of an email. An address listed twice
+// in the same role of one email is counted once. Bcc recipients are not counted, the other
+// participants never saw them.
+func (p ThreadParticipants) Record(email *Email) {
+	count := func(addresses []string, role func(*ParticipantRoleCounts)) {
+		seen := make(map[string]bool, len(addresses))
+		for _, address := range addresses {
+			address = strings.ToLower(strings.TrimSpace(address))
+			if address == "" || seen[address] {
+				continue
+			}
+			seen[address] = true
+			if p[address] == nil {
+				p[address] = &ParticipantRoleCounts{}
+			}
+			role(p[address])
+		}
+	}
+
+	count([]string{email.FromAddress}, func(c *ParticipantRoleCounts) { c.From++ })
+	count(email.ToAddresses, func(c *ParticipantRoleCounts) { c.To++ })
+	count(email.CcAddresses, func(c *ParticipantRoleCounts) { c.Cc++ })
+}
+
+// Value implements the driver.Valuer interface for ThreadParticipants
+func (p ThreadParticipants) Value() (driver.Value, error) {
+	if p == nil {
+		return json.Marshal(map[string]*ParticipantRoleCounts{})
+	}
+	return json.Marshal(map[string]*ParticipantRoleCounts(p))
+}
+
+// Scan implements the sql.Scanner interface for ThreadParticipants
+func (p *ThreadParticipants) Scan(value interface{}) error {
+	*p = make(ThreadParticipants)
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, p)
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThreadParticipantsRecord(t *testing.T) {
+	participants := ThreadParticipants{}
+	participants.Record(&Email{
+		FromAddress: "Jane@Acme.io",
+		ToAddresses: pq.StringArray{"bob@corp.io", "BOB@corp.io"},
+		CcAddresses: pq.StringArray{"carol@corp.io"},
+	})
+	participants.Record(&Email{
+		FromAddress:  "bob@corp.io",
+		ToAddresses:  pq.StringArray{"jane@acme.io"},
+		BccAddresses: pq.StringArray{"audit@corp.io"},
+	})
+
+	assert.Equal(t, &ParticipantRoleCounts{From: 1, To: 1}, participants["jane@acme.io"])
+	assert.Equal(t, &ParticipantRoleCounts{From: 1, To: 1}, participants["bob@corp.io"])
+	assert.Equal(t, &ParticipantRoleCounts{Cc: 1}, participants["carol@corp.io"])
+	assert.NotContains(t, participants, "audit@corp.io")
+
+	value, err := participants.Value()
+	require.NoError(t, err)
+	var scanned ThreadParticipants
+	require.NoError(t, scanned.Scan(value))
+	assert.Equal(t, participants, scanned)
+}
+
+func TestEmailThreadRecompute(t *testing.T) {
+	first := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	last := first.Add(2 * time.Hour)
+	thread := &EmailThread{
+		Participants:   pq.StringArray{"gone@corp.io"},
+		HasAttachments: true,
+		LastMessageID:  "gone@corp.io",
+	}
+
+	thread.Recompute([]*Email{
+		{MessageID: "<b@acme.io>", FromAddress: "bob@corp.io", ToAddresses: pq.StringArray{"jane@acme.io"}, ReceivedAt: &last},
+		{MessageID: "<a@acme.io>", FromAddress: "jane@acme.io", ToAddresses: pq.StringArray{"bob@corp.io"}, SentAt: &first},
+	})
+
+	assert.ElementsMatch(t, []string{"jane@acme.io", "bob@corp.io"}, thread.Participants)
+	assert.Equal(t, &ParticipantRoleCounts{From: 1, To: 1}, thread.ParticipantRoles["bob@corp.io"])
+	assert.False(t, thread.HasAttachments)
+	assert.Equal(t, first, *thread.FirstMessageAt)
+	assert.Equal(t, last, *thread.LastMessageAt)
+	assert.Equal(t, "b@acme.io", thread.LastMessageID)
+}
+
+func TestEmailThreadMetadataDiff(t *testing.T) {
+	sentAt := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	emails := []*Email{
+		{MessageID: "<a@acme.io>", FromAddress: "jane@acme.io", ToAddresses: pq.StringArray{"bob@corp.io"}, SentAt: &sentAt},
+	}
+	recomputed := &EmailThread{}
+	recomputed.Recompute(emails)
+
+	stored := &EmailThread{}
+	stored.Recompute(emails)
+	stored.Participants = pq.StringArray{"bob@corp.io", "jane@acme.io"}
+	storedAt := sentAt.In(time.FixedZone("CET", 3600))
+	stored.FirstMessageAt = &storedAt
+	assert.Empty(t, stored.MetadataDiff(recomputed))
+
+	stored.HasAttachments = true
+	stored.LastMessageAt = nil
+	stored.ParticipantRoles["jane@acme.io"].From = 2
+	assert.Equal(t, []string{"participantRoles", "hasAttachments", "lastMessageAt"}, stored.MetadataDiff(recomputed))
+}
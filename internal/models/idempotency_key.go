@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+// IdempotencyKey records a request made with an Idempotency-Key header and, once it completed,
+// its response, which is returned to retries of the request until the key expires. A zero
+// StatusCode means the request is still being handled.
+type IdempotencyKey struct {
+	ID           string    `gorm:"column:id;type:varchar(50);primaryKey" json:"id"`
+	Tenant       string    `gorm:"column:tenant;type:varchar(255);not null;uniqueIndex:idx_idempotency_keys_tenant_key,priority:1" json:"tenant"`
+	Key          string    `gorm:"column:key;type:varchar(255);not null;uniqueIndex:idx_idempotency_keys_tenant_key,priority:2" json:"key"`
+	Endpoint     string    `gorm:"column:endpoint;type:varchar(255);not null" json:"endpoint"`       // method and route
+	RequestHash  string    `gorm:"column:request_hash;type:varchar(64);not null" json:"requestHash"` // sha256 of the body
+	StatusCode   int       `gorm:"column:status_code;not null;default:0" json:"statusCode"`
+	ResponseBody []byte    `gorm:"column:response_body;type:bytea" json:"-"`
+	CreatedAt    time.Time `gorm:"column:created_at;type:timestamp;default:current_timestamp" json:"createdAt"`
+	ExpiresAt    time.Time `gorm:"column:expires_at;type:timestamp;not null;index" json:"expiresAt"`
+}
+
+func (IdempotencyKey) TableName() string {
+	return "idempotency_keys"
+}
+
+func (m *IdempotencyKey) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == "" {
+		m.ID = utils.GenerateNanoIDWithPrefix("idem", 16)
+	}
+	return nil
+}
+
+// Completed reports whether the response of the request is stored
+func (m *IdempotencyKey) Completed() bool {
+	return m.StatusCode != 0
+}
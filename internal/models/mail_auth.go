@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/customeros/mailstack/internal/enum"
+)
+
+// MailAuthResults are the SPF, DKIM and DMARC results of an inbound email, verified by us from
+// its raw source rather than taken from the headers of the receiving server
+type MailAuthResults struct {
+	SPF        SPFResult    `json:"spf"`
+	DKIM       []DKIMResult `json:"dkim"` // one per signature, empty when unsigned
+	DMARC      DMARCResult  `json:"dmarc"`
+	VerifiedAt time.Time    `json:"verifiedAt"`
+}
+
+type SPFResult struct {
+	Result   enum.AuthResult `json:"result"`
+	Domain   string          `json:"domain,omitempty"`   // MAIL FROM domain, taken from Return-Path
+	ClientIP string          `json:"clientIp,omitempty"` // host that handed the message to our provider
+	Detail   string          `json:"detail,omitempty"`
+}
+
+type DKIMResult struct {
+	Result   enum.AuthResult `json:"result"`
+	Domain   string          `json:"domain,omitempty"` // d= of the signature
+	Selector string          `json:"selector,omitempty"`
+	Detail   string          `json:"detail,omitempty"`
+}
+
+type DMARCResult struct {
+	Result      enum.AuthResult `json:"result"`
+	Domain      string          `json:"domain,omitempty"` // From domain
+	Policy      string          `json:"policy,omitempty"` // none, quarantine or reject
+	SPFAligned  bool            `json:"spfAligned"`
+	DKIMAligned bool            `json:"dkimAligned"`
+	Detail      string          `json:"detail,omitempty"`
+}
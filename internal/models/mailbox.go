@@ -30,15 +30,22 @@ type Mailbox struct {
 	ImapServer   string             `gorm:"column:imap_server;type:varchar(255)" json:"imapServer"`
 	ImapPort     int                `gorm:"column:imap_port" json:"imapPort"`
 	ImapUsername string             `gorm:"column:imap_username;type:varchar(255)" json:"imapUsername"`
-	ImapPassword string             `gorm:"column:imap_password;type:varchar(255)" json:"imapPassword"`
+	ImapPassword string             `gorm:"column:imap_password;type:text" json:"imapPassword"`
 	ImapSecurity enum.EmailSecurity `gorm:"column:imap_security;type:varchar(50)" json:"imapSecurity"`
+	// certificate verification is strict unless skipped, ImapCACert holds PEM encoded roots for private CAs
+	ImapInsecureSkipVerify bool   `gorm:"column:imap_insecure_skip_verify;default:false" json:"imapInsecureSkipVerify"`
+	ImapCACert             string `gorm:"column:imap_ca_cert;type:text" json:"imapCaCert"`
 
 	SmtpServer   string             `gorm:"column:smtp_server;type:varchar(255)" json:"smtpServer"`
 	SmtpPort     int                `gorm:"column:smtp_port" json:"smtpPort"`
 	SmtpUsername string             `gorm:"column:smtp_username;type:varchar(255)" json:"smtpUsername"`
-	SmtpPassword string             `gorm:"column:smtp_password;type:varchar(255)" json:"smtpPassword"`
+	SmtpPassword string             `gorm:"column:smtp_password;type:text" json:"smtpPassword"`
 	SmtpSecurity enum.EmailSecurity `gorm:"column:smtp_security;type:varchar(50)" json:"smtpSecurity"`
 
+	// keys the stored passwords are encrypted with, empty for plaintext
+	ImapPasswordKeyID string `gorm:"column:imap_password_key_id;type:varchar(50);not null;default:''" json:"-"`
+	SmtpPasswordKeyID string `gorm:"column:smtp_password_key_id;type:varchar(50);not null;default:''" json:"-"`
+
 	// OAuth specific fields (for Google, Microsoft, etc.)
 	OAuthClientID     string     `gorm:"column:oauth_client_id;type:varchar(255)" json:"oauthClientId"`
 	OAuthClientSecret string     `gorm:"column:oauth_client_secret;type:varchar(255)" json:"oauthClientSecret"`
@@ -49,19 +56,42 @@ type Mailbox struct {
 
 	// Email sending configuration
 	ReplyToAddress string `gorm:"column:reply_to_address;type:varchar(255)" json:"replyToAddress"`
+	// Host of the Message-IDs of sent emails, e.g. a dedicated bounce or tracking domain. Empty uses
+	// the domain of the sender.
+	MessageIDDomain string `gorm:"column:message_id_domain;type:varchar(255)" json:"messageIdDomain"`
 
 	// Sync configuration
 	SyncFolders pq.StringArray `gorm:"column:sync_folders;type:text[]" json:"syncFolders"`
+	// Initial sync fetches full messages SyncBatchSize at a time, so memory grows with the batch
+	// size times the message size. SyncMaxMessages caps the messages imported per folder, 0 uses the defaults.
+	SyncBatchSize           int `gorm:"column:sync_batch_size;default:20" json:"syncBatchSize"`
+	SyncMaxMessages         int `gorm:"column:sync_max_messages;default:50000" json:"syncMaxMessages"`
+	SyncPollIntervalSeconds int `gorm:"column:sync_poll_interval_seconds;default:30" json:"syncPollIntervalSeconds"`
+	// Reconnects after a lost IMAP connection wait a backoff growing from the initial to the max
+	// delay, with jitter so mailboxes disconnected together do not reconnect together
+	ReconnectInitialBackoffSeconds int `gorm:"column:reconnect_initial_backoff_seconds;default:1" json:"reconnectInitialBackoffSeconds"`
+	ReconnectMaxBackoffSeconds     int `gorm:"column:reconnect_max_backoff_seconds;default:120" json:"reconnectMaxBackoffSeconds"`
+	// IMAP folder messages sent over SMTP are appended to, empty detects the sent folder of the server
+	SentFolder string `gorm:"column:sent_folder;type:varchar(255)" json:"sentFolder"`
 
 	// Status tracking
 	ConnectionStatus    enum.ConnectionStatus `gorm:"column:connection_status;type:varchar(50)" json:"connectionStatus"`
 	LastConnectionCheck *time.Time            `gorm:"column:last_connection_check;type:timestamp" json:"lastConnectionCheck"`
 	ErrorMessage        string                `gorm:"column:error_message;type:text" json:"errorMessage"`
+	DisconnectedSince   *time.Time            `gorm:"column:disconnected_since;type:timestamp" json:"disconnectedSince"` // nil while connected
+
+	// Send rate limits, a DailySendQuota of 0 disables the daily limit. New mailboxes warm up:
+	// their daily limit starts at WarmupStartLimit and grows by WarmupDailyIncrease every day
+	// until it reaches DailySendQuota. A WarmupStartLimit of 0 disables the warm-up, mailboxes
+	// created before the warm-up existed have it disabled. Sent emails are counted per day in
+	// MailboxDailySendCount.
+	DailySendQuota      int `gorm:"column:daily_quota;default:2000" json:"dailyQuota"`
+	WarmupStartLimit    int `gorm:"column:warmup_start_limit;default:20" json:"warmupStartLimit"`
+	WarmupDailyIncrease int `gorm:"column:warmup_daily_increase;default:10" json:"warmupDailyIncrease"`
 
-	// Send rate limits
-	DailySendQuota int        `gorm:"column:daily_quota;default:2000" json:"dailyQuota"`
-	DailySendCount int        `gorm:"column:daily_send_count;default:0" json:"dailySendCount"`
-	QuotaResetAt   *time.Time `gorm:"column:quota_reset_at;type:timestamp" json:"quotaResetAt"`
+	// Send throttling, 0 disables the respective limit
+	HourlySendLimit        int `gorm:"column:hourly_send_limit;default:30" json:"hourlySendLimit"`
+	MinSendIntervalSeconds int `gorm:"column:min_send_interval_seconds;default:60" json:"minSendIntervalSeconds"`
 
 	// Standard timestamps
 	CreatedAt time.Time      `gorm:"column:created_at;type:timestamp;default:current_timestamp" json:"createdAt"`
@@ -69,6 +99,33 @@ type Mailbox struct {
 	DeletedAt gorm.DeletedAt `gorm:"column:deleted_at;index" json:"-"`
 }
 
+// Sync setting defaults and accepted ranges
+const (
+	DefaultSyncBatchSize           = 20
+	MaxSyncBatchSize               = 200
+	DefaultSyncMaxMessages         = 50000
+	MaxSyncMaxMessages             = 500000
+	DefaultSyncPollIntervalSeconds = 30
+	MinSyncPollIntervalSeconds     = 10
+	MaxSyncPollIntervalSeconds     = 180
+)
+
+// Reconnect backoff defaults and accepted range
+const (
+	DefaultReconnectInitialBackoffSeconds = 1
+	DefaultReconnectMaxBackoffSeconds     = 120
+	MaxReconnectBackoffSeconds            = 3600
+)
+
+// MessageIDHost is the host of the Message-IDs the mailbox sends, senderDomain unless a
+// MessageIDDomain is configured
+func (m *Mailbox) MessageIDHost(senderDomain string) string {
+	if m.MessageIDDomain != "" {
+		return m.MessageIDDomain
+	}
+	return senderDomain
+}
+
 // TableName sets the table name for the Mailbox model
 func (Mailbox) TableName() string {
 	return "mailboxes"
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// MailboxAlias is an address that forwards to another mailbox. A catch-all is stored
+// with alias "*@<domain>".
+type MailboxAlias struct {
+	ID        string    `gorm:"primary_key;type:uuid;default:gen_random_uuid()" json:"id"`
+	Tenant    string    `gorm:"column:tenant;type:varchar(255);NOT NULL" json:"tenant"`
+	Domain    string    `gorm:"column:domain;type:varchar(255);NOT NULL;index" json:"domain"`
+	Alias     string    `gorm:"column:alias;type:varchar(255);NOT NULL;uniqueIndex" json:"alias"`
+	ForwardTo string    `gorm:"column:forward_to;type:varchar(255);NOT NULL" json:"forwardTo"`
+	CatchAll  bool      `gorm:"column:catch_all;type:boolean;NOT NULL;DEFAULT:false" json:"catchAll"`
+	CreatedAt time.Time `gorm:"column:created_at;type:timestamp;DEFAULT:current_timestamp" json:"createdAt"`
+	UpdatedAt time.Time `gorm:"column:updated_at;type:timestamp" json:"updatedAt"`
+}
+
+func (MailboxAlias) TableName() string {
+	return "mailbox_alias"
+}
+
+// CatchAllAlias is the alias a catch-all of the domain is stored under
+func CatchAllAlias(domain string) string {
+	return "*@" + domain
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+// MailboxDailySendCount counts the emails a mailbox sent on a UTC day, the daily send limit
+// is enforced against it
+type MailboxDailySendCount struct {
+	ID        string    `gorm:"column:id;type:varchar(50);primaryKey" json:"id"`
+	MailboxID string    `gorm:"column:mailbox_id;type:varchar(50);not null;uniqueIndex:idx_mailbox_daily_send_counts_day" json:"mailboxId"`
+	Date      time.Time `gorm:"column:date;type:date;not null;uniqueIndex:idx_mailbox_daily_send_counts_day" json:"date"`
+	Count     int       `gorm:"column:count;not null;default:0" json:"count"`
+	CreatedAt time.Time `gorm:"column:created_at;type:timestamp;default:current_timestamp" json:"createdAt"`
+	UpdatedAt time.Time `gorm:"column:updated_at;type:timestamp;default:current_timestamp" json:"updatedAt"`
+}
+
+func (MailboxDailySendCount) TableName() string {
+	return "mailbox_daily_send_counts"
+}
+
+func (m *MailboxDailySendCount) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == "" {
+		m.ID = utils.GenerateNanoIDWithPrefix("mdsc", 16)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+// MailboxFolderStats are the counts of a synced folder as last seen by the pod monitoring the
+// mailbox, stored so every pod and the API read the same view
+type MailboxFolderStats struct {
+	ID          string    `gorm:"column:id;type:varchar(50);primaryKey" json:"id"`
+	MailboxID   string    `gorm:"column:mailbox_id;type:varchar(50);not null;uniqueIndex:idx_mailbox_folder_stats_folder" json:"mailboxId"`
+	FolderName  string    `gorm:"column:folder_name;type:varchar(100);not null;uniqueIndex:idx_mailbox_folder_stats_folder" json:"folderName"`
+	Total       uint32    `gorm:"column:total;not null;default:0" json:"total"`
+	Unseen      uint32    `gorm:"column:unseen;not null;default:0" json:"unseen"`
+	LastSeenUID uint32    `gorm:"column:last_seen_uid;not null;default:0" json:"lastSeenUid"`
+	LastSync    time.Time `gorm:"column:last_sync;type:timestamp" json:"lastSync"`
+	CreatedAt   time.Time `gorm:"column:created_at;type:timestamp;default:current_timestamp" json:"createdAt"`
+	UpdatedAt   time.Time `gorm:"column:updated_at;type:timestamp;default:current_timestamp" json:"updatedAt"`
+}
+
+func (MailboxFolderStats) TableName() string {
+	return "mailbox_folder_stats"
+}
+
+func (m *MailboxFolderStats) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == "" {
+		m.ID = utils.GenerateNanoIDWithPrefix("mfst", 16)
+	}
+	return nil
+}
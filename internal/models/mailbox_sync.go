@@ -17,6 +17,11 @@ type MailboxSyncState struct {
 	LastSync   time.Time `gorm:"column:last_sync;type:timestamp"`
 	CreatedAt  time.Time `gorm:"column:created_at;type:timestamp;default:current_timestamp"`
 	UpdatedAt  time.Time `gorm:"column:updated_at;type:timestamp;default:current_timestamp"`
+
+	// Initial sync progress, processed and total count messages across resumed runs
+	InitialSyncProcessed int  `gorm:"column:initial_sync_processed;not null;default:0"`
+	InitialSyncTotal     int  `gorm:"column:initial_sync_total;not null;default:0"`
+	InitialSyncComplete  bool `gorm:"column:initial_sync_complete;not null;default:false"`
 }
 
 func (MailboxSyncState) TableName() string {
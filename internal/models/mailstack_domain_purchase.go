@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// MailStackDomainPurchase records a domain purchase so a registration that could not be
+// stored as MailStackDomain can be reconciled later
+type MailStackDomainPurchase struct {
+	ID        uint64               `gorm:"primary_key;autoIncrement" json:"id"`
+	Tenant    string               `gorm:"column:tenant;type:varchar(255);NOT NULL" json:"tenant"`
+	Domain    string               `gorm:"column:domain;type:varchar(255);NOT NULL;index" json:"domain"`
+	Status    DomainPurchaseStatus `gorm:"column:status;type:varchar(50);NOT NULL;index" json:"status"`
+	OrderID   string               `gorm:"column:order_id;type:varchar(255)" json:"orderId"`
+	CreatedAt time.Time            `gorm:"column:created_at;type:timestamp;DEFAULT:current_timestamp" json:"createdAt"`
+	UpdatedAt time.Time            `gorm:"column:updated_at;type:timestamp" json:"updatedAt"`
+}
+
+func (MailStackDomainPurchase) TableName() string {
+	return "mailstack_domain_purchase"
+}
+
+type DomainPurchaseStatus string
+
+const (
+	DomainPurchaseStatusPending    DomainPurchaseStatus = "PENDING"    // registrar call in progress or interrupted
+	DomainPurchaseStatusRegistered DomainPurchaseStatus = "REGISTERED" // registered, not stored as MailStackDomain yet
+	DomainPurchaseStatusStored     DomainPurchaseStatus = "STORED"
+	DomainPurchaseStatusFailed     DomainPurchaseStatus = "FAILED"
+)
@@ -8,12 +8,14 @@ import (
 	"github.com/customeros/mailstack/internal/utils"
 )
 
+// OrphanEmail is a parent Message-ID that replies in a mailbox referenced before it arrived.
+// The same Message-ID can be missing in several mailboxes, records are unique per mailbox.
 type OrphanEmail struct {
 	ID           string    `gorm:"column:id;type:varchar(50);primaryKey"`
-	MessageID    string    `gorm:"column:message_id;type:varchar(255);uniqueIndex"`
+	MessageID    string    `gorm:"column:message_id;type:varchar(255);uniqueIndex:idx_orphan_emails_mailbox_message,priority:2"`
 	ReferencedBy string    `gorm:"column:referenced_by;type:varchar(255)"` // Email ID that referenced this
 	ThreadID     string    `gorm:"column:thread_id;type:varchar(50);index"`
-	MailboxID    string    `gorm:"column:mailbox_id;type:varchar(50);index"`
+	MailboxID    string    `gorm:"column:mailbox_id;type:varchar(50);index;uniqueIndex:idx_orphan_emails_mailbox_message,priority:1"`
 	CreatedAt    time.Time `gorm:"column:created_at;type:timestamp;default:current_timestamp"`
 }
 
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+// Categories of SensitiveSubjectKeywords that are matched as markers or classification levels
+// instead of keyword groups
+const (
+	SensitiveCategoryConfidentialityMarkers = "confidentiality_markers"
+	SensitiveCategoryClassificationLevels   = "classification_levels"
+)
+
+// SensitiveSubjectKeywords overrides one category of the sensitive subject check for a tenant.
+// The keywords replace the default list of the category, Disabled turns the category off and
+// unknown categories are added as new keyword groups.
+type SensitiveSubjectKeywords struct {
+	ID        string         `gorm:"column:id;type:varchar(50);primaryKey" json:"id"`
+	Tenant    string         `gorm:"column:tenant;type:varchar(255);not null;uniqueIndex:idx_sensitive_subject_tenant_category" json:"tenant"`
+	Category  string         `gorm:"column:category;type:varchar(100);not null;uniqueIndex:idx_sensitive_subject_tenant_category" json:"category"`
+	Keywords  pq.StringArray `gorm:"column:keywords;type:text[]" json:"keywords"`
+	Disabled  bool           `gorm:"column:disabled;type:boolean;not null;default:false" json:"disabled"`
+	CreatedAt time.Time      `gorm:"column:created_at;type:timestamp;default:current_timestamp" json:"createdAt"`
+	UpdatedAt time.Time      `gorm:"column:updated_at;type:timestamp;default:current_timestamp" json:"updatedAt"`
+}
+
+func (SensitiveSubjectKeywords) TableName() string {
+	return "sensitive_subject_keywords"
+}
+
+func (m *SensitiveSubjectKeywords) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == "" {
+		m.ID = utils.GenerateNanoIDWithPrefix("sskw", 16)
+	}
+	return nil
+}
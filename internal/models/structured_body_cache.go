@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// StructuredBodyCache keeps the AI structured body of an email by the hash of its tenant, sender,
+// recipient and normalized body, so duplicate deliveries are not sent to the AI again
+type StructuredBodyCache struct {
+	BodyHash  string    `gorm:"column:body_hash;type:varchar(64);primaryKey" json:"bodyHash"`
+	EmailData JSONMap   `gorm:"column:email_data;type:jsonb" json:"emailData"`
+	CreatedAt time.Time `gorm:"column:created_at;type:timestamp;default:current_timestamp" json:"createdAt"`
+}
+
+func (StructuredBodyCache) TableName() string {
+	return "structured_body_cache"
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+// TenantCurrency is the currency domain prices are quoted in for a tenant. Tenants without one
+// get the configured price currency.
+type TenantCurrency struct {
+	ID        string    `gorm:"column:id;type:varchar(50);primaryKey" json:"id"`
+	Tenant    string    `gorm:"column:tenant;type:varchar(255);not null;uniqueIndex" json:"tenant"`
+	Currency  string    `gorm:"column:currency;type:varchar(3);not null" json:"currency"`
+	CreatedAt time.Time `gorm:"column:created_at;type:timestamp;default:current_timestamp" json:"createdAt"`
+	UpdatedAt time.Time `gorm:"column:updated_at;type:timestamp;default:current_timestamp" json:"updatedAt"`
+}
+
+func (TenantCurrency) TableName() string {
+	return "tenant_currencies"
+}
+
+func (m *TenantCurrency) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == "" {
+		m.ID = utils.GenerateNanoIDWithPrefix("tcur", 16)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+// TenantRetentionPolicy sets after how many days the mail of a tenant, its threads and attachment
+// files are purged, counted from when each email was sent or received. Tenants without a policy
+// keep their mail.
+type TenantRetentionPolicy struct {
+	ID            string    `gorm:"column:id;type:varchar(50);primaryKey" json:"id"`
+	Tenant        string    `gorm:"column:tenant;type:varchar(255);not null;uniqueIndex" json:"tenant"`
+	RetentionDays int       `gorm:"column:retention_days;not null" json:"retentionDays"`
+	CreatedAt     time.Time `gorm:"column:created_at;type:timestamp;default:current_timestamp" json:"createdAt"`
+	UpdatedAt     time.Time `gorm:"column:updated_at;type:timestamp;default:current_timestamp" json:"updatedAt"`
+}
+
+func (TenantRetentionPolicy) TableName() string {
+	return "tenant_retention_policies"
+}
+
+func (m *TenantRetentionPolicy) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == "" {
+		m.ID = utils.GenerateNanoIDWithPrefix("rtnp", 16)
+	}
+	return nil
+}
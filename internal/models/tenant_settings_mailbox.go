@@ -9,7 +9,9 @@ type TenantSettingsMailbox struct {
 	UpdatedAt time.Time `gorm:"column:updated_at;type:timestamp" json:"updatedAt"`
 
 	MailboxUsername string `gorm:"column:mailbox_username;type:varchar(255)" json:"mailboxUsername"`
-	MailboxPassword string `gorm:"column:mailbox_password;type:varchar(255)" json:"mailboxPassword"`
+	MailboxPassword string `gorm:"column:mailbox_password;type:text"         json:"mailboxPassword"`
+	// key the stored password is encrypted with, empty for plaintext
+	MailboxPasswordKeyID string `gorm:"column:mailbox_password_key_id;type:varchar(50);not null;default:''" json:"-"`
 
 	Domain   string `gorm:"column:domain;type:varchar(255)" json:"domain"`
 	Username string `gorm:"column:user_name;type:varchar(255)" json:"userName"`
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/opentracing/opentracing-go"
+	"gorm.io/gorm"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+)
+
+type auditLogRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditLogRepository(db *gorm.DB) interfaces.AuditLogRepository {
+	return &auditLogRepository{db: db}
+}
+
+// Create stores an audit log entry
+func (r *auditLogRepository) Create(ctx context.Context, entry *models.AuditLog) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "auditLogRepository.Create")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("action", entry.Action)
+
+	if err := r.db.WithContext(ctx).Create(entry).Error; err != nil {
+		tracing.TraceErr(span, err)
+		return fmt.Errorf("failed to create audit log: %w", err)
+	}
+
+	return nil
+}
+
+// List returns up to limit entries of the audit log of a tenant, newest first
+func (r *auditLogRepository) List(ctx context.Context, filter interfaces.AuditLogFilter, limit int) ([]*models.AuditLog, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "auditLogRepository.List")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("tenant", filter.Tenant)
+	span.SetTag("limit", limit)
+
+	query := r.db.WithContext(ctx).Model(&models.AuditLog{}).Where("tenant = ?", filter.Tenant)
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+
+	var entries []*models.AuditLog
+	if err := keysetPage(query, "created_at", filter.After, true).Limit(limit).Find(&entries).Error; err != nil {
+		tracing.TraceErr(span, err)
+		return nil, fmt.Errorf("failed to list audit log: %w", err)
+	}
+
+	return entries, nil
+}
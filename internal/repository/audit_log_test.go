@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+func TestAuditLogListQuery(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: noConnPool{}}), &gorm.Config{DryRun: true})
+	require.NoError(t, err)
+	from := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)
+	at := time.Date(2026, 10, 10, 9, 0, 0, 0, time.UTC)
+
+	var stmt *gorm.Statement
+	db.Callback().Query().After("gorm:query").Register("test:capture", func(tx *gorm.DB) { stmt = tx.Statement })
+
+	_, err = NewAuditLogRepository(db).List(context.Background(), interfaces.AuditLogFilter{
+		Tenant: "acme",
+		From:   &from,
+		To:     &to,
+		After:  &utils.Cursor{At: &at, ID: "adlg_5", Descending: true},
+	}, 20)
+	require.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM "audit_logs" WHERE tenant = $1 AND created_at >= $2 AND created_at < $3 AND `+
+		`(created_at < $4 OR (created_at = $5 AND id < $6) OR created_at IS NULL) `+
+		`ORDER BY created_at DESC NULLS LAST,id DESC LIMIT $7`, stmt.SQL.String())
+	assert.Equal(t, []interface{}{"acme", from, to, at, at, "adlg_5", 20}, stmt.Vars)
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/customeros/mailstack/internal/secrets"
+)
+
+// encryptCredentials encrypts the plaintext values of the credential columns and re-encrypts
+// values of older keys with the active key, so running the migration after a key rotation
+// moves every row to the new key. The key id of each column is stored in <column>_key_id. Rows
+// are read without the model hooks to see the stored values.
+func encryptCredentials(db *gorm.DB, table string, columns ...string) error {
+	keyring := secrets.Default()
+	if keyring == nil {
+		return nil
+	}
+
+	selected := []string{"id"}
+	conditions := make([]string, 0, len(columns))
+	args := make([]interface{}, 0, len(columns))
+	for _, column := range columns {
+		selected = append(selected, column, keyIDColumn(column))
+		conditions = append(conditions, fmt.Sprintf("(%s <> '' AND %s <> ?)", column, keyIDColumn(column)))
+		args = append(args, keyring.ActiveKeyID())
+	}
+
+	var rows []map[string]interface{}
+	err := db.Table(table).
+		Select(selected).
+		Where(strings.Join(conditions, " OR "), args...).
+		Find(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	encryptedRows := 0
+	for _, row := range rows {
+		updates := make(map[string]interface{})
+		for _, column := range columns {
+			value, _ := row[column].(string)
+			keyID, _ := row[keyIDColumn(column)].(string)
+			if keyring.Current(value, keyID) {
+				continue
+			}
+			plain, err := keyring.Decrypt(value, keyID)
+			if err != nil {
+				// left for a later run once the key is configured again
+				log.Printf("Skipped credentials of %s %v %s: %v", table, row["id"], column, err)
+				continue
+			}
+			encrypted, newKeyID, err := keyring.Encrypt(plain)
+			if err != nil {
+				return err
+			}
+			updates[column] = encrypted
+			updates[keyIDColumn(column)] = newKeyID
+		}
+		if len(updates) == 0 {
+			continue
+		}
+		err = db.Table(table).Where("id = ?", row["id"]).UpdateColumns(updates).Error
+		if err != nil {
+			return err
+		}
+		encryptedRows++
+	}
+
+	if encryptedRows > 0 {
+		log.Printf("Encrypted credentials of %d rows in %s", encryptedRows, table)
+	}
+	return nil
+}
+
+func keyIDColumn(column string) string {
+	return column + "_key_id"
+}
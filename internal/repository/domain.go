@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/customeros/mailstack/internal/tracing"
 	"github.com/customeros/mailstack/internal/utils"
@@ -21,10 +22,21 @@ type DomainRepository interface {
 	GetActiveDomains(ctx context.Context, tenant string) ([]models.MailStackDomain, error)
 	MarkConfigured(ctx context.Context, tenant, domain string) error
 	SetDkimKeys(ctx context.Context, tenant, domain, dkimPublic, dkimPrivate string) error
+	SetAutoRenew(ctx context.Context, tenant, domain string, autoRenew bool) error
+	RecordRenewal(ctx context.Context, tenant, domain string, expiresAt *time.Time) error
+	SetExpiryNotified(ctx context.Context, tenant, domain string, expiresAt *time.Time, days *int) error
 	CreateDMARCReport(ctx context.Context, tenant string, report *models.DMARCMonitoring) error
+	DMARCReportExists(ctx context.Context, reportingOrg, reportID string) (bool, error)
 	CreateMailstackReputationScore(ctx context.Context, tenant string, score *models.MailstackReputation) error
+	GetLatestMailstackReputation(ctx context.Context, tenant, domain string) (*models.MailstackReputation, error)
 	GetDomainCrossTenant(ctx context.Context, domain string) (*models.MailStackDomain, error)
 	GetAllActiveDomainsCrossTenant(ctx context.Context) ([]models.MailStackDomain, error)
+	CreatePurchase(ctx context.Context, tenant, domain string) (*models.MailStackDomainPurchase, error)
+	UpdatePurchaseStatus(ctx context.Context, id uint64, status models.DomainPurchaseStatus, orderID string) error
+	GetUnreconciledPurchases(ctx context.Context) ([]*models.MailStackDomainPurchase, error)
+	GetLatestPurchase(ctx context.Context, domain string) (*models.MailStackDomainPurchase, error)
+	GetAllDomainsCrossTenant(ctx context.Context) ([]models.MailStackDomain, error)
+	SetActive(ctx context.Context, tenant, domain string, active bool) error
 }
 
 type domainRepository struct {
@@ -51,6 +63,29 @@ func (r *domainRepository) CreateMailstackReputationScore(ctx context.Context, t
 	return nil
 }
 
+func (r *domainRepository) GetLatestMailstackReputation(ctx context.Context, tenant, domain string) (*models.MailstackReputation, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "DomainRepository.GetLatestMailstackReputation")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	tracing.TagTenant(span, tenant)
+	span.LogKV("domain", domain)
+
+	var reputation models.MailstackReputation
+	err := r.db.WithContext(ctx).
+		Where("tenant = ? AND domain = ?", tenant, domain).
+		Order("created_at DESC").
+		First(&reputation).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		tracing.TraceErr(span, errors.Wrap(err, "db error"))
+		return nil, err
+	}
+
+	return &reputation, nil
+}
+
 func (r *domainRepository) CreateDMARCReport(ctx context.Context, tenant string, report *models.DMARCMonitoring) error {
 	span, _ := opentracing.StartSpanFromContext(ctx, "DomainRepository.CreateDMARCReport")
 	defer span.Finish()
@@ -68,6 +103,31 @@ func (r *domainRepository) CreateDMARCReport(ctx context.Context, tenant string,
 	return nil
 }
 
+// DMARCReportExists checks whether an aggregate report was already stored, reporters resend them
+func (r *domainRepository) DMARCReportExists(ctx context.Context, reportingOrg, reportID string) (bool, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "DomainRepository.DMARCReportExists")
+	defer span.Finish()
+	tracing.SetDefaultPostgresRepositorySpanTags(ctx, span)
+	span.LogKV("reportingOrg", reportingOrg, "reportId", reportID)
+
+	if reportID == "" {
+		return false, nil
+	}
+
+	var exists bool
+	err := r.db.WithContext(ctx).
+		Model(&models.DMARCMonitoring{}).
+		Select("count(*) > 0").
+		Where("reporting_org = ? AND report_id = ?", reportingOrg, reportID).
+		Find(&exists).Error
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "db error"))
+		return false, err
+	}
+
+	return exists, nil
+}
+
 func (r *domainRepository) RegisterDomain(ctx context.Context, tenant, domain string) (*models.MailStackDomain, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "DomainRepository.RegisterDomain")
 	defer span.Finish()
@@ -94,6 +154,7 @@ func (r *domainRepository) RegisterDomain(ctx context.Context, tenant, domain st
 		CreatedAt: now,
 		UpdatedAt: now,
 		Active:    true,
+		AutoRenew: true,
 	}
 
 	err = r.db.WithContext(ctx).Create(&mailStackDomain).Error
@@ -191,6 +252,86 @@ func (r *domainRepository) SetDkimKeys(ctx context.Context, tenant, domain, dkim
 	return nil
 }
 
+func (r *domainRepository) SetAutoRenew(ctx context.Context, tenant, domain string, autoRenew bool) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "DomainRepository.SetAutoRenew")
+	defer span.Finish()
+	tracing.SetDefaultPostgresRepositorySpanTags(ctx, span)
+	span.LogKV("domain", domain, "autoRenew", autoRenew)
+
+	err := r.db.WithContext(ctx).
+		Model(&models.MailStackDomain{}).
+		Where("tenant = ? AND domain = ?", tenant, domain).
+		UpdateColumns(map[string]interface{}{
+			"auto_renew": autoRenew,
+			"updated_at": utils.Now(),
+		}).
+		Error
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "db error"))
+		return err
+	}
+
+	return nil
+}
+
+// RecordRenewal stores the renewal time and, when known, the new expiration date
+func (r *domainRepository) RecordRenewal(ctx context.Context, tenant, domain string, expiresAt *time.Time) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "DomainRepository.RecordRenewal")
+	defer span.Finish()
+	tracing.SetDefaultPostgresRepositorySpanTags(ctx, span)
+	span.LogKV("domain", domain)
+
+	now := utils.Now()
+	columns := map[string]interface{}{
+		"last_renewed_at":      now,
+		"expiry_notified_days": nil,
+		"updated_at":           now,
+	}
+	if expiresAt != nil {
+		columns["expires_at"] = *expiresAt
+	}
+
+	err := r.db.WithContext(ctx).
+		Model(&models.MailStackDomain{}).
+		Where("tenant = ? AND domain = ?", tenant, domain).
+		UpdateColumns(columns).
+		Error
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "db error"))
+		return err
+	}
+
+	return nil
+}
+
+// SetExpiryNotified stores the last expiry notification threshold, nil resets it
+func (r *domainRepository) SetExpiryNotified(ctx context.Context, tenant, domain string, expiresAt *time.Time, days *int) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "DomainRepository.SetExpiryNotified")
+	defer span.Finish()
+	tracing.SetDefaultPostgresRepositorySpanTags(ctx, span)
+	span.LogKV("domain", domain)
+
+	columns := map[string]interface{}{
+		"expiry_notified_days": days,
+		"updated_at":           utils.Now(),
+	}
+	if expiresAt != nil {
+		columns["expires_at"] = *expiresAt
+	}
+
+	err := r.db.WithContext(ctx).
+		Model(&models.MailStackDomain{}).
+		Where("tenant = ? AND domain = ?", tenant, domain).
+		UpdateColumns(columns).
+		Error
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "db error"))
+		return err
+	}
+
+	return nil
+}
+
 func (r *domainRepository) GetDomain(ctx context.Context, tenant, domain string) (*models.MailStackDomain, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "DomainRepository.GetDomain")
 	defer span.Finish()
@@ -253,3 +394,139 @@ func (r *domainRepository) GetAllActiveDomainsCrossTenant(ctx context.Context) (
 	span.LogFields(tracingLog.Int("result.count", len(mailStackDomains)))
 	return mailStackDomains, nil
 }
+
+func (r *domainRepository) CreatePurchase(ctx context.Context, tenant, domain string) (*models.MailStackDomainPurchase, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "DomainRepository.CreatePurchase")
+	defer span.Finish()
+	tracing.SetDefaultPostgresRepositorySpanTags(ctx, span)
+	tracing.TagTenant(span, tenant)
+	span.LogKV("domain", domain)
+
+	now := utils.Now()
+	purchase := models.MailStackDomainPurchase{
+		Tenant:    tenant,
+		Domain:    domain,
+		Status:    models.DomainPurchaseStatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	err := r.db.WithContext(ctx).Create(&purchase).Error
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "db error"))
+		return nil, err
+	}
+
+	return &purchase, nil
+}
+
+// UpdatePurchaseStatus sets the status of a purchase, an empty orderID keeps the stored one
+func (r *domainRepository) UpdatePurchaseStatus(ctx context.Context, id uint64, status models.DomainPurchaseStatus, orderID string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "DomainRepository.UpdatePurchaseStatus")
+	defer span.Finish()
+	tracing.SetDefaultPostgresRepositorySpanTags(ctx, span)
+	span.LogKV("id", id, "status", status)
+
+	columns := map[string]interface{}{
+		"status":     status,
+		"updated_at": utils.Now(),
+	}
+	if orderID != "" {
+		columns["order_id"] = orderID
+	}
+
+	err := r.db.WithContext(ctx).
+		Model(&models.MailStackDomainPurchase{}).
+		Where("id = ?", id).
+		UpdateColumns(columns).Error
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "db error"))
+		return err
+	}
+
+	return nil
+}
+
+// GetUnreconciledPurchases returns purchases that did not end up stored or failed
+func (r *domainRepository) GetUnreconciledPurchases(ctx context.Context) ([]*models.MailStackDomainPurchase, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "DomainRepository.GetUnreconciledPurchases")
+	defer span.Finish()
+	tracing.SetDefaultPostgresRepositorySpanTags(ctx, span)
+
+	var purchases []*models.MailStackDomainPurchase
+	err := r.db.WithContext(ctx).
+		Where("status IN ?", []models.DomainPurchaseStatus{models.DomainPurchaseStatusPending, models.DomainPurchaseStatusRegistered}).
+		Order("created_at").
+		Find(&purchases).Error
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "db error"))
+		return nil, err
+	}
+
+	span.LogFields(tracingLog.Int("result.count", len(purchases)))
+	return purchases, nil
+}
+
+// GetLatestPurchase returns the most recent purchase of the domain, nil if it was never purchased through us
+func (r *domainRepository) GetLatestPurchase(ctx context.Context, domain string) (*models.MailStackDomainPurchase, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "DomainRepository.GetLatestPurchase")
+	defer span.Finish()
+	tracing.SetDefaultPostgresRepositorySpanTags(ctx, span)
+	span.LogKV("domain", domain)
+
+	var purchase models.MailStackDomainPurchase
+	err := r.db.WithContext(ctx).
+		Where("domain = ? AND status <> ?", domain, models.DomainPurchaseStatusFailed).
+		Order("created_at DESC").
+		First(&purchase).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			span.LogFields(tracingLog.Bool("response.found", false))
+			return nil, nil
+		}
+		tracing.TraceErr(span, errors.Wrap(err, "db error"))
+		return nil, err
+	}
+
+	return &purchase, nil
+}
+
+// GetAllDomainsCrossTenant returns every stored domain, active or not
+func (r *domainRepository) GetAllDomainsCrossTenant(ctx context.Context) ([]models.MailStackDomain, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "DomainRepository.GetAllDomainsCrossTenant")
+	defer span.Finish()
+	tracing.SetDefaultPostgresRepositorySpanTags(ctx, span)
+
+	var mailStackDomains []models.MailStackDomain
+	err := r.db.WithContext(ctx).
+		Find(&mailStackDomains).Error
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "db error"))
+		return nil, err
+	}
+
+	span.LogFields(tracingLog.Int("result.count", len(mailStackDomains)))
+	return mailStackDomains, nil
+}
+
+func (r *domainRepository) SetActive(ctx context.Context, tenant, domain string, active bool) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "DomainRepository.SetActive")
+	defer span.Finish()
+	tracing.SetDefaultPostgresRepositorySpanTags(ctx, span)
+	tracing.TagTenant(span, tenant)
+	span.LogKV("domain", domain, "active", active)
+
+	err := r.db.WithContext(ctx).
+		Model(&models.MailStackDomain{}).
+		Where("tenant = ? AND domain = ?", tenant, domain).
+		UpdateColumns(map[string]interface{}{
+			"active":     active,
+			"updated_at": utils.Now(),
+		}).Error
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "db error"))
+		return err
+	}
+
+	return nil
+}
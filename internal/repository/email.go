@@ -7,10 +7,13 @@ import (
 	"strings"
 	"time"
 
+	"github.com/lib/pq"
 	"github.com/opentracing/opentracing-go"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/enum"
 	"github.com/customeros/mailstack/internal/models"
 	"github.com/customeros/mailstack/internal/tracing"
 	"github.com/customeros/mailstack/internal/utils"
@@ -43,9 +46,10 @@ func (r *emailRepository) Create(ctx context.Context, email *models.Email) (stri
 		email.CleanSubject = utils.NormalizeSubject(email.Subject)
 	}
 
-	// Check if email already exists before creating
+	// Check if email already exists before creating, a deleted one is not synced back
 	existingEmail := &models.Email{}
 	err := r.db.WithContext(ctx).
+		Unscoped().
 		Where("message_id = ?", email.MessageID).
 		First(existingEmail).Error
 
@@ -126,6 +130,29 @@ func (r *emailRepository) GetByMessageID(ctx context.Context, messageID string)
 	return &email, nil
 }
 
+// GetOutboundByMessageID retrieves an email sent from the mailbox by its Message-ID header. Sent
+// emails store the ID in angle brackets as generated, so both forms are matched.
+func (r *emailRepository) GetOutboundByMessageID(ctx context.Context, mailboxID, messageID string) (*models.Email, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailRepository.GetOutboundByMessageID")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+
+	messageID = strings.Trim(messageID, "<>")
+
+	var email models.Email
+	err := r.db.WithContext(ctx).
+		Where("mailbox_id = ? AND message_id IN ? AND direction = ?", mailboxID, []string{messageID, utils.FormatMessageID(messageID)}, enum.EmailDirectionOutbound).
+		First(&email).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+	return &email, nil
+}
+
 // ListByMailbox retrieves emails for a specific mailbox with pagination
 func (r *emailRepository) ListByMailbox(ctx context.Context, mailboxID string, limit, offset int) ([]*models.Email, int64, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "emailRepository.ListByMailbox")
@@ -207,6 +234,68 @@ func (r *emailRepository) ListByThread(ctx context.Context, threadID string) ([]
 	return emails, nil
 }
 
+// ListByThreadPaginated retrieves a page of the emails of a thread, oldest first by sent time,
+// or received time for emails without one
+func (r *emailRepository) ListByThreadPaginated(ctx context.Context, threadID string, limit, offset int) ([]*models.Email, int64, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailRepository.ListByThreadPaginated")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("thread_id", threadID)
+
+	var emails []*models.Email
+	var count int64
+
+	if err := r.db.WithContext(ctx).Model(&models.Email{}).
+		Where("thread_id = ?", threadID).
+		Count(&count).Error; err != nil {
+		tracing.TraceErr(span, err)
+		return nil, 0, err
+	}
+
+	if err := r.db.WithContext(ctx).
+		Where("thread_id = ?", threadID).
+		Order("COALESCE(sent_at, received_at) ASC NULLS LAST").
+		Order("id").
+		Limit(limit).
+		Offset(offset).
+		Find(&emails).Error; err != nil {
+		tracing.TraceErr(span, err)
+		return nil, 0, err
+	}
+
+	return emails, count, nil
+}
+
+// ListByThreadAfter retrieves a page of the emails of a thread, oldest first, continuing after
+// the cursor when set, together with the total number of emails of the thread
+func (r *emailRepository) ListByThreadAfter(ctx context.Context, threadID string, after *utils.Cursor, limit int) ([]*models.Email, int64, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailRepository.ListByThreadAfter")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("thread_id", threadID)
+	span.SetTag("limit", limit)
+
+	var emails []*models.Email
+	var count int64
+
+	if err := r.db.WithContext(ctx).Model(&models.Email{}).
+		Where("thread_id = ?", threadID).
+		Count(&count).Error; err != nil {
+		tracing.TraceErr(span, err)
+		return nil, 0, err
+	}
+
+	query := r.db.WithContext(ctx).Where("thread_id = ?", threadID)
+	if err := keysetPage(query, "COALESCE(sent_at, received_at)", after, false).
+		Limit(limit).
+		Find(&emails).Error; err != nil {
+		tracing.TraceErr(span, err)
+		return nil, 0, err
+	}
+
+	return emails, count, nil
+}
+
 // Search searches emails by query string
 func (r *emailRepository) Search(ctx context.Context, query string, limit, offset int) ([]*models.Email, int64, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "emailRepository.Search")
@@ -242,6 +331,70 @@ func (r *emailRepository) Search(ctx context.Context, query string, limit, offse
 	return emails, count, nil
 }
 
+// ListDueScheduled retrieves scheduled emails whose send time is at or before the given time
+func (r *emailRepository) ListDueScheduled(ctx context.Context, before time.Time, limit int) ([]*models.Email, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailRepository.ListDueScheduled")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+
+	var emails []*models.Email
+
+	if err := r.db.WithContext(ctx).
+		Where("status = ? AND scheduled_for <= ?", enum.EmailStatusScheduled, before).
+		Order("scheduled_for ASC").
+		Limit(limit).
+		Find(&emails).Error; err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	return emails, nil
+}
+
+// ListSentAtSince returns the send times of outbound emails sent from a mailbox since the given time, oldest first
+func (r *emailRepository) ListSentAtSince(ctx context.Context, mailboxID string, since time.Time) ([]time.Time, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailRepository.ListSentAtSince")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("mailbox.id", mailboxID)
+
+	var sentAt []time.Time
+
+	if err := r.db.WithContext(ctx).
+		Model(&models.Email{}).
+		Where("mailbox_id = ? AND direction = ? AND sent_at >= ?", mailboxID, enum.EmailDirectionOutbound, since).
+		Order("sent_at ASC").
+		Pluck("sent_at", &sentAt).Error; err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	return sentAt, nil
+}
+
+// TransitionStatus moves an email from one status to another, returning false if the
+// email was not in the expected status (e.g. it was cancelled or already dispatched)
+func (r *emailRepository) TransitionStatus(ctx context.Context, emailID string, from, to enum.EmailStatus) (bool, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailRepository.TransitionStatus")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("email.id", emailID)
+
+	result := r.db.WithContext(ctx).
+		Model(&models.Email{}).
+		Where("id = ? AND status = ?", emailID, from).
+		Updates(map[string]interface{}{
+			"status":     to,
+			"updated_at": time.Now(),
+		})
+	if result.Error != nil {
+		tracing.TraceErr(span, result.Error)
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
 // Update updates an email record
 func (r *emailRepository) Update(ctx context.Context, email *models.Email) error {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "emailRepository.Update")
@@ -302,6 +455,11 @@ func (r *emailRepository) Update(ctx context.Context, email *models.Email) error
 			"scheduled_for":   email.ScheduledFor,
 			"updated_at":      email.UpdatedAt,
 			"send_attempts":   email.SendAttempts,
+
+			"message_size_bytes": email.MessageSizeBytes,
+			"recipient_count":    email.RecipientCount,
+			"send_duration_ms":   email.SendDurationMs,
+			"smtp_response":      email.SmtpResponse,
 		})
 
 	if result.Error != nil {
@@ -371,3 +529,311 @@ func (r *emailRepository) SetEmailRawData(ctx context.Context, emailID string, h
 
 	return nil
 }
+
+// SetStructuredBody stores the AI structured body of an email, without touching other columns
+func (r *emailRepository) SetStructuredBody(ctx context.Context, emailID string, hasSignature bool, bodyMarkdown string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailRepository.SetStructuredBody")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("email_id", emailID)
+
+	if emailID == "" {
+		return ErrInvalidInput
+	}
+
+	result := r.db.WithContext(ctx).
+		Model(&models.Email{}).
+		Where("id = ?", emailID).
+		Updates(map[string]interface{}{
+			"has_signature": hasSignature,
+			"body_markdown": bodyMarkdown,
+			"updated_at":    time.Now(),
+		})
+	if result.Error != nil {
+		tracing.TraceErr(span, result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrEmailNotFound
+	}
+
+	return nil
+}
+
+// SetRawKey stores where the original source of an email is kept, without touching other columns
+func (r *emailRepository) SetRawKey(ctx context.Context, emailID, rawKey string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailRepository.SetRawKey")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("email_id", emailID)
+
+	if emailID == "" {
+		return ErrInvalidInput
+	}
+
+	result := r.db.WithContext(ctx).
+		Model(&models.Email{}).
+		Where("id = ?", emailID).
+		Updates(map[string]interface{}{
+			"raw_key":    rawKey,
+			"updated_at": time.Now(),
+		})
+	if result.Error != nil {
+		tracing.TraceErr(span, result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrEmailNotFound
+	}
+
+	return nil
+}
+
+// GetByIDIncludingDeleted retrieves an email by its ID, also when it is soft deleted
+func (r *emailRepository) GetByIDIncludingDeleted(ctx context.Context, id string) (*models.Email, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailRepository.GetByIDIncludingDeleted")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+
+	var email models.Email
+	if err := r.db.WithContext(ctx).Unscoped().Where("id = ?", id).First(&email).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+	return &email, nil
+}
+
+// SoftDelete moves an email to the trash. When it was the last email of its thread the thread
+// is deleted too, at the same time, so restoring the thread brings the email back.
+func (r *emailRepository) SoftDelete(ctx context.Context, emailID string) (bool, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailRepository.SoftDelete")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("email_id", emailID)
+
+	threadDeleted := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var email models.Email
+		if err := tx.Where("id = ?", emailID).First(&email).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrEmailNotFound
+			}
+			return err
+		}
+
+		deletedAt := utils.Now()
+		if err := tx.Model(&models.Email{}).Where("id = ?", emailID).Update("deleted_at", deletedAt).Error; err != nil {
+			return err
+		}
+		if email.ThreadID == "" {
+			return nil
+		}
+
+		var remaining int64
+		if err := tx.Model(&models.Email{}).Where("thread_id = ?", email.ThreadID).Count(&remaining).Error; err != nil {
+			return err
+		}
+		if remaining > 0 {
+			return nil
+		}
+		result := tx.Model(&models.EmailThread{}).Where("id = ?", email.ThreadID).Update("deleted_at", deletedAt)
+		threadDeleted = result.RowsAffected > 0
+		return result.Error
+	})
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return false, err
+	}
+
+	span.SetTag("thread_deleted", threadDeleted)
+	return threadDeleted, nil
+}
+
+// Restore takes an email out of the trash, and its thread when that was deleted, without the
+// other emails deleted with the thread
+func (r *emailRepository) Restore(ctx context.Context, emailID string) (bool, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailRepository.Restore")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("email_id", emailID)
+
+	threadRestored := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var email models.Email
+		if err := tx.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", emailID).First(&email).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrEmailNotFound
+			}
+			return err
+		}
+
+		if err := tx.Unscoped().Model(&models.Email{}).Where("id = ?", emailID).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		if email.ThreadID == "" {
+			return nil
+		}
+
+		result := tx.Unscoped().
+			Model(&models.EmailThread{}).
+			Where("id = ? AND deleted_at IS NOT NULL", email.ThreadID).
+			Update("deleted_at", nil)
+		threadRestored = result.RowsAffected > 0
+		return result.Error
+	})
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return false, err
+	}
+
+	span.SetTag("thread_restored", threadRestored)
+	return threadRestored, nil
+}
+
+// PurgeDeleted permanently removes up to limit emails deleted before the given time
+func (r *emailRepository) PurgeDeleted(ctx context.Context, before time.Time, limit int) (*interfaces.PurgedEmails, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailRepository.PurgeDeleted")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.LogKV("before", before, "limit", limit)
+
+	var purged *interfaces.PurgedEmails
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		batch := tx.Unscoped().
+			Model(&models.Email{}).
+			Select("id").
+			Where("deleted_at < ?", before).
+			Limit(limit)
+
+		var err error
+		purged, err = purgeEmails(tx, batch)
+		return err
+	})
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	span.SetTag("purged_count", len(purged.Emails))
+	return purged, nil
+}
+
+// PurgeExpired permanently removes up to limit emails of the mailboxes of a tenant, deleted
+// mailboxes included, sent or received before the given time. Emails never sent, like drafts and
+// scheduled ones, are kept.
+func (r *emailRepository) PurgeExpired(ctx context.Context, tenant string, before time.Time, limit int) (*interfaces.PurgedEmails, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailRepository.PurgeExpired")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("tenant", tenant)
+	span.LogKV("before", before, "limit", limit)
+
+	if tenant == "" {
+		return nil, ErrInvalidInput
+	}
+
+	var purged *interfaces.PurgedEmails
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		mailboxes := tx.Unscoped().
+			Model(&models.Mailbox{}).
+			Select("id").
+			Where("tenant = ?", tenant)
+		batch := tx.Unscoped().
+			Model(&models.Email{}).
+			Select("id").
+			Where("mailbox_id IN (?) AND COALESCE(sent_at, received_at) < ?", mailboxes, before).
+			Limit(limit)
+
+		var err error
+		purged, err = purgeEmails(tx, batch)
+		return err
+	})
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	span.SetTag("purged_count", len(purged.Emails))
+	return purged, nil
+}
+
+// purgeEmails deletes the emails selected by the ids query, the threads left without emails and
+// the attachment records no other email references. The storage objects of the returned emails
+// and attachments are left to the caller, to delete once tx is committed.
+func purgeEmails(tx *gorm.DB, ids *gorm.DB) (*interfaces.PurgedEmails, error) {
+	purged := &interfaces.PurgedEmails{}
+	err := tx.Unscoped().
+		Clauses(clause.Returning{Columns: []clause.Column{
+			{Name: "id"}, {Name: "mailbox_id"}, {Name: "thread_id"}, {Name: "raw_key"}, {Name: "message_size_bytes"},
+		}}).
+		Where("id IN (?)", ids).
+		Delete(&purged.Emails).Error
+	if err != nil || len(purged.Emails) == 0 {
+		return purged, err
+	}
+
+	emailIDs := make(pq.StringArray, 0, len(purged.Emails))
+	threadIDs := make([]string, 0, len(purged.Emails))
+	for _, email := range purged.Emails {
+		emailIDs = append(emailIDs, email.ID)
+		if email.ThreadID != "" && !utils.IsStringInSlice(email.ThreadID, threadIDs) {
+			threadIDs = append(threadIDs, email.ThreadID)
+		}
+	}
+
+	// attachments are deduplicated by content, the file stays while another email uses it
+	var attachmentIDs []string
+	err = tx.Model(&models.EmailAttachment{}).
+		Where("emails && ?", emailIDs).
+		Pluck("id", &attachmentIDs).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(attachmentIDs) > 0 {
+		err = tx.Model(&models.EmailAttachment{}).
+			Where("id IN ?", attachmentIDs).
+			Updates(map[string]interface{}{
+				"emails":     gorm.Expr("ARRAY(SELECT e FROM unnest(emails) AS e WHERE e <> ALL(?))", emailIDs),
+				"updated_at": utils.Now(),
+			}).Error
+		if err != nil {
+			return nil, err
+		}
+		err = tx.Clauses(clause.Returning{}).
+			Where("id IN ? AND cardinality(emails) = 0", attachmentIDs).
+			Delete(&purged.Attachments).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(threadIDs) > 0 {
+		remaining := tx.Unscoped().
+			Model(&models.Email{}).
+			Select("thread_id").
+			Where("thread_id IN ?", threadIDs)
+		var emptyThreadIDs []string
+		err = tx.Unscoped().
+			Model(&models.EmailThread{}).
+			Where("id IN ? AND id NOT IN (?)", threadIDs, remaining).
+			Pluck("id", &emptyThreadIDs).Error
+		if err != nil {
+			return nil, err
+		}
+		if len(emptyThreadIDs) > 0 {
+			if err = tx.Where("thread_id IN ?", emptyThreadIDs).Delete(&models.OrphanEmail{}).Error; err != nil {
+				return nil, err
+			}
+			result := tx.Unscoped().Where("id IN ?", emptyThreadIDs).Delete(&models.EmailThread{})
+			if result.Error != nil {
+				return nil, result.Error
+			}
+			purged.Threads = result.RowsAffected
+		}
+	}
+
+	return purged, nil
+}
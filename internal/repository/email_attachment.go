@@ -19,13 +19,13 @@ import (
 
 type emailAttachmentRepository struct {
 	db      *gorm.DB
-	storage interfaces.StorageService
+	storage interfaces.AttachmentStorage
 }
 
-func NewEmailAttachmentRepository(db *gorm.DB, storageService interfaces.StorageService) interfaces.EmailAttachmentRepository {
+func NewEmailAttachmentRepository(db *gorm.DB, storage interfaces.AttachmentStorage) interfaces.EmailAttachmentRepository {
 	return &emailAttachmentRepository{
 		db:      db,
-		storage: storageService,
+		storage: storage,
 	}
 }
 
@@ -120,16 +120,19 @@ func (r *emailAttachmentRepository) Store(ctx context.Context, attachment *model
 
 	// If file exists, update email and thread references
 	if existingAttachment != nil {
-		if !utils.IsStringInSlice(emailID, existingAttachment.Emails) {
-			existingAttachment.Emails = append(existingAttachment.Emails, emailID)
-		}
-		if !utils.IsStringInSlice(threadID, existingAttachment.Threads) {
-			existingAttachment.Threads = append(existingAttachment.Threads, threadID)
-		}
-		if attachment.Filename != "" {
-			existingAttachment.Filename = attachment.Filename
+		if err = r.addReferences(ctx, existingAttachment.ID, threadID, emailID, attachment.Filename); err != nil {
+			tracing.TraceErr(span, err)
+			return err
 		}
-		return r.db.WithContext(ctx).Save(existingAttachment).Error
+
+		// point the caller's copy at the stored file
+		attachment.ID = existingAttachment.ID
+		attachment.StorageService = existingAttachment.StorageService
+		attachment.StorageBucket = existingAttachment.StorageBucket
+		attachment.StorageKey = existingAttachment.StorageKey
+		attachment.PreviewKey = existingAttachment.PreviewKey
+		attachment.ContentHash = existingAttachment.ContentHash
+		return nil
 	}
 
 	// This is a new file, proceed with upload
@@ -149,9 +152,16 @@ func (r *emailAttachmentRepository) Store(ctx context.Context, attachment *model
 	}
 
 	attachment.StorageKey = fmt.Sprintf("%s/%s", fileExt, filename)
+	attachment.StorageService, attachment.StorageBucket = r.storage.Default()
+
+	storage, err := r.storage.Get(attachment.StorageService, attachment.StorageBucket)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
 
 	// Store the file in the storage service
-	if err := r.storage.Upload(ctx, attachment.StorageKey, data, attachment.ContentType); err != nil {
+	if err := storage.Upload(ctx, attachment.StorageKey, data, attachment.ContentType); err != nil {
 		tracing.TraceErr(span, err)
 		tracing.LogObjectAsJson(span, "attachment", attachment)
 		return fmt.Errorf("failed to upload attachment: %w", err)
@@ -160,6 +170,88 @@ func (r *emailAttachmentRepository) Store(ctx context.Context, attachment *model
 	return r.db.WithContext(ctx).Save(attachment).Error
 }
 
+// addReferences adds the email and thread to a stored attachment in one statement, so references
+// added concurrently are kept. An attachment purged in the meantime is not brought back, its file
+// is gone, and ErrAttachmentNotFound is returned instead.
+func (r *emailAttachmentRepository) addReferences(ctx context.Context, id, threadID, emailID, filename string) error {
+	updates := map[string]interface{}{
+		"emails":     gorm.Expr("CASE WHEN ? = ANY(emails) THEN emails ELSE array_append(emails, ?) END", emailID, emailID),
+		"threads":    gorm.Expr("CASE WHEN ? = ANY(threads) THEN threads ELSE array_append(threads, ?) END", threadID, threadID),
+		"updated_at": utils.Now(),
+	}
+	if filename != "" {
+		updates["filename"] = filename
+	}
+
+	result := r.db.WithContext(ctx).Model(&models.EmailAttachment{}).Where("id = ?", id).UpdateColumns(updates)
+	if result.Error != nil {
+		return fmt.Errorf("failed to add attachment references: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: %s", ErrAttachmentNotFound, id)
+	}
+	return nil
+}
+
+// StoreMetadata saves the attachment record without uploading any content
+func (r *emailAttachmentRepository) StoreMetadata(ctx context.Context, attachment *models.EmailAttachment, threadID, emailID string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailAttachmentRepository.StoreMetadata")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+
+	if attachment == nil {
+		err := errors.New("Nil attachment")
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	attachment.UpdatedAt = time.Now()
+	attachment.Emails = []string{emailID}
+	attachment.Threads = []string{threadID}
+	attachment.StorageKey = ""
+
+	err := r.db.WithContext(ctx).Save(attachment).Error
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+	return nil
+}
+
+// StorePreview uploads the JPEG preview next to the attachment and records its key
+func (r *emailAttachmentRepository) StorePreview(ctx context.Context, attachment *models.EmailAttachment, preview []byte) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailAttachmentRepository.StorePreview")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("attachment.id", attachment.ID)
+
+	storage, err := r.storage.Get(attachment.StorageService, attachment.StorageBucket)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	previewKey := fmt.Sprintf("previews/%s.jpg", attachment.ID)
+	if err = storage.Upload(ctx, previewKey, preview, "image/jpeg"); err != nil {
+		tracing.TraceErr(span, err)
+		return fmt.Errorf("failed to upload attachment preview: %w", err)
+	}
+
+	err = r.db.WithContext(ctx).Model(&models.EmailAttachment{}).
+		Where("id = ?", attachment.ID).
+		Updates(map[string]interface{}{
+			"preview_key": previewKey,
+			"updated_at":  time.Now(),
+		}).Error
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	attachment.PreviewKey = previewKey
+	return nil
+}
+
 // GetAttachment retrieves the attachment data from storage
 func (r *emailAttachmentRepository) DownloadAttachment(ctx context.Context, id string) ([]byte, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "emailAttachmentRepository.GetData")
@@ -177,8 +269,13 @@ func (r *emailAttachmentRepository) DownloadAttachment(ctx context.Context, id s
 		return nil, errors.New("attachment not found")
 	}
 
-	// Retrieve the file from storage
-	data, err := r.storage.Download(ctx, attachment.StorageKey)
+	// Retrieve the file from the storage it was uploaded to
+	storage, err := r.storage.Get(attachment.StorageService, attachment.StorageBucket)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+	data, err := storage.Download(ctx, attachment.StorageKey)
 	if err != nil {
 		tracing.TraceErr(span, err)
 		return nil, fmt.Errorf("failed to download attachment: %w", err)
@@ -203,9 +300,16 @@ func (r *emailAttachmentRepository) Delete(ctx context.Context, id string) error
 		return nil // Already deleted
 	}
 
-	// Delete from storage
-	if attachment.StorageKey != "" {
-		if err := r.storage.Delete(ctx, attachment.StorageKey); err != nil {
+	// Delete from storage, together with the preview
+	for _, key := range []string{attachment.StorageKey, attachment.PreviewKey} {
+		if key == "" {
+			continue
+		}
+		storage, err := r.storage.Get(attachment.StorageService, attachment.StorageBucket)
+		if err == nil {
+			err = storage.Delete(ctx, key)
+		}
+		if err != nil {
 			// Log the error but continue with DB deletion
 			fmt.Printf("Failed to delete attachment from storage: %v", err)
 		}
@@ -214,3 +318,30 @@ func (r *emailAttachmentRepository) Delete(ctx context.Context, id string) error
 	// Delete from database
 	return r.db.WithContext(ctx).Delete(&models.EmailAttachment{}, "id = ?", id).Error
 }
+
+// DeleteFiles removes the stored file and preview of an attachment whose record is already gone
+func (r *emailAttachmentRepository) DeleteFiles(ctx context.Context, attachment *models.EmailAttachment) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailAttachmentRepository.DeleteFiles")
+	defer span.Finish()
+	span.SetTag("attachment.id", attachment.ID)
+
+	if attachment.StorageKey == "" && attachment.PreviewKey == "" {
+		return nil
+	}
+	storage, err := r.storage.Get(attachment.StorageService, attachment.StorageBucket)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	for _, key := range []string{attachment.StorageKey, attachment.PreviewKey} {
+		if key == "" {
+			continue
+		}
+		if err = storage.Delete(ctx, key); err != nil {
+			tracing.TraceErr(span, err)
+			return fmt.Errorf("failed to delete attachment file %s: %w", key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestAddAttachmentReferencesQuery(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: noConnPool{}}), &gorm.Config{DryRun: true})
+	require.NoError(t, err)
+
+	var statement *gorm.Statement
+	require.NoError(t, db.Callback().Update().After("gorm:update").Register("test:capture", func(tx *gorm.DB) {
+		statement = tx.Statement
+	}))
+
+	// the dry run updates no row, like an attachment purged since it was looked up
+	repository := &emailAttachmentRepository{db: db}
+	err = repository.addReferences(context.Background(), "file_1", "thread_1", "email_1", "report.pdf")
+	assert.ErrorIs(t, err, ErrAttachmentNotFound)
+
+	require.NotNil(t, statement)
+	assert.Equal(t, `UPDATE "email_attachments" SET `+
+		`"emails"=CASE WHEN $1 = ANY(emails) THEN emails ELSE array_append(emails, $2) END,"filename"=$3,`+
+		`"threads"=CASE WHEN $4 = ANY(threads) THEN threads ELSE array_append(threads, $5) END,"updated_at"=$6 WHERE id = $7`,
+		statement.SQL.String())
+	assert.Equal(t, []interface{}{"email_1", "email_1", "report.pdf", "thread_1", "thread_1"}, statement.Vars[:5])
+	assert.Equal(t, "file_1", statement.Vars[6])
+}
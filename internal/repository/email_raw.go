@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/opentracing/opentracing-go"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/tracing"
+)
+
+type emailRawRepository struct {
+	storage interfaces.AttachmentStorage
+}
+
+func NewEmailRawRepository(storage interfaces.AttachmentStorage) interfaces.EmailRawRepository {
+	return &emailRawRepository{
+		storage: storage,
+	}
+}
+
+func rawKey(emailID string) string {
+	return fmt.Sprintf("raw/%s.eml", emailID)
+}
+
+// Get downloads a stored source from the default storage
+func (r *emailRawRepository) Get(ctx context.Context, key string) ([]byte, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailRawRepository.Get")
+	defer span.Finish()
+	span.SetTag("key", key)
+
+	storage, err := r.storage.Get(r.storage.Default())
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	data, err := storage.Download(ctx, key)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, fmt.Errorf("failed to download raw email: %w", err)
+	}
+	return data, nil
+}
+
+// Save uploads the source to the default storage
+func (r *emailRawRepository) Save(ctx context.Context, emailID string, raw []byte) (string, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailRawRepository.Save")
+	defer span.Finish()
+	span.SetTag("email.id", emailID)
+	span.LogKV("size", len(raw))
+
+	storage, err := r.storage.Get(r.storage.Default())
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return "", err
+	}
+
+	key := rawKey(emailID)
+	if err = storage.Upload(ctx, key, raw, "message/rfc822"); err != nil {
+		tracing.TraceErr(span, err)
+		return "", fmt.Errorf("failed to upload raw email: %w", err)
+	}
+	return key, nil
+}
+
+// Delete removes a stored source from the default storage
+func (r *emailRawRepository) Delete(ctx context.Context, key string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailRawRepository.Delete")
+	defer span.Finish()
+	span.SetTag("key", key)
+
+	storage, err := r.storage.Get(r.storage.Default())
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	if err = storage.Delete(ctx, key); err != nil {
+		tracing.TraceErr(span, err)
+		return fmt.Errorf("failed to delete raw email: %w", err)
+	}
+	return nil
+}
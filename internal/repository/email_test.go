@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"github.com/customeros/mailstack/internal/enum"
+)
+
+func TestPurgeEmails(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: noConnPool{}}), &gorm.Config{DryRun: true})
+	require.NoError(t, err)
+	before := time.Date(2026, 9, 15, 0, 0, 0, 0, time.UTC)
+
+	// the emails are deleted first, the dry run returns none so nothing else follows
+	var statements []*gorm.Statement
+	require.NoError(t, db.Callback().Delete().After("gorm:delete").Register("test:capture", func(tx *gorm.DB) {
+		statements = append(statements, tx.Statement)
+	}))
+	repository := NewEmailRepository(db)
+	returning := `RETURNING "id","mailbox_id","thread_id","raw_key","message_size_bytes"`
+
+	purged, err := repository.PurgeDeleted(context.Background(), before, 100)
+	require.NoError(t, err)
+	assert.Empty(t, purged.Emails)
+	require.Len(t, statements, 1)
+	assert.Equal(t, `DELETE FROM "emails" WHERE id IN (SELECT "id" FROM "emails" WHERE deleted_at < $1 LIMIT $2) `+returning,
+		statements[0].SQL.String())
+	assert.Equal(t, []interface{}{before, 100}, statements[0].Vars)
+
+	_, err = repository.PurgeExpired(context.Background(), "acme", before, 100)
+	require.NoError(t, err)
+	require.Len(t, statements, 2)
+	assert.Equal(t, `DELETE FROM "emails" WHERE id IN (SELECT "id" FROM "emails" WHERE `+
+		`mailbox_id IN (SELECT "id" FROM "mailboxes" WHERE tenant = $1) AND COALESCE(sent_at, received_at) < $2 LIMIT $3) `+returning,
+		statements[1].SQL.String())
+	assert.Equal(t, []interface{}{"acme", before, 100}, statements[1].Vars)
+}
+
+func TestGetOutboundByMessageIDQuery(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: noConnPool{}}), &gorm.Config{DryRun: true})
+	require.NoError(t, err)
+
+	var statement *gorm.Statement
+	require.NoError(t, db.Callback().Query().After("gorm:query").Register("test:capture", func(tx *gorm.DB) {
+		statement = tx.Statement
+	}))
+
+	_, err = NewEmailRepository(db).GetOutboundByMessageID(context.Background(), "mbox_1", "abc@acme.com")
+	require.NoError(t, err)
+	require.NotNil(t, statement)
+	assert.Contains(t, statement.SQL.String(), `mailbox_id = $1 AND message_id IN ($2,$3) AND direction = $4`)
+	assert.Equal(t, []interface{}{"mbox_1", "abc@acme.com", "<abc@acme.com>", enum.EmailDirectionOutbound}, statement.Vars[:4])
+}
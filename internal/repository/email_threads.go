@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
@@ -12,6 +13,7 @@ import (
 	"gorm.io/gorm/clause"
 
 	"github.com/customeros/mailstack/interfaces"
+	mailstack_errors "github.com/customeros/mailstack/internal/errors"
 	"github.com/customeros/mailstack/internal/models"
 	"github.com/customeros/mailstack/internal/tracing"
 	"github.com/customeros/mailstack/internal/utils"
@@ -95,7 +97,7 @@ func (r *emailThreadRepository) GetByID(ctx context.Context, id string) (*models
 	err := r.db.WithContext(ctx).Where("id = ?", id).First(&thread).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			notFoundErr := fmt.Errorf("thread with ID %s not found", id)
+			notFoundErr := fmt.Errorf("thread with ID %s not found: %w", id, err)
 			tracing.TraceErr(span, notFoundErr)
 			return nil, notFoundErr
 		}
@@ -106,7 +108,31 @@ func (r *emailThreadRepository) GetByID(ctx context.Context, id string) (*models
 	return &thread, nil
 }
 
-// GetByMailboxIDsPaginated retrieves threads for an array of mailboxes with pagination
+// GetByIDs retrieves the threads with the given IDs, missing ones are skipped
+func (r *emailThreadRepository) GetByIDs(ctx context.Context, ids []string) ([]*models.EmailThread, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailThreadRepository.GetByIDs")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("thread_count", len(ids))
+
+	if len(ids) == 0 {
+		return []*models.EmailThread{}, nil
+	}
+
+	var threads []*models.EmailThread
+	err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&threads).Error
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	return threads, nil
+}
+
+// GetByMailboxIDs retrieves threads for an array of mailboxes with pagination
+//
+// Deprecated: offsets skip or repeat threads when mail arrives while paging, use ListByFilter
+// with a cursor.
 func (r *emailThreadRepository) GetByMailboxIDs(ctx context.Context, mailboxIDs []string, limit int, offset int) ([]*models.EmailThread, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "emailThreadRepository.GetByMailboxIDsPaginated")
 	defer span.Finish()
@@ -138,6 +164,54 @@ func (r *emailThreadRepository) GetByMailboxIDs(ctx context.Context, mailboxIDs
 	return threads, nil
 }
 
+// ListByFilter retrieves the threads of the filtered mailboxes ordered by last message time,
+// together with the total number of matching threads. Pages continue after filter.After when
+// set, the offset is the deprecated way of paging and only applies without a cursor.
+func (r *emailThreadRepository) ListByFilter(ctx context.Context, filter interfaces.EmailThreadFilter, limit, offset int) ([]*models.EmailThread, int64, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailThreadRepository.ListByFilter")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("mailbox_ids", strings.Join(filter.MailboxIDs, ","))
+	span.SetTag("limit", limit)
+	span.SetTag("offset", offset)
+
+	if len(filter.MailboxIDs) == 0 {
+		err := errors.New("mailbox IDs list cannot be empty")
+		tracing.TraceErr(span, err)
+		return nil, 0, err
+	}
+
+	query := r.db.WithContext(ctx).Model(&models.EmailThread{}).Where("mailbox_id IN ?", filter.MailboxIDs)
+	if filter.IsDone != nil {
+		query = query.Where(`"isDone" = ?`, *filter.IsDone)
+	}
+	if filter.IsViewed != nil {
+		query = query.Where("is_viewed = ?", *filter.IsViewed)
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		tracing.TraceErr(span, err)
+		return nil, 0, err
+	}
+
+	page := keysetPage(query, "last_message_at", filter.After, !filter.OldestFirst)
+	if filter.After == nil {
+		page = page.Offset(offset)
+	}
+
+	var threads []*models.EmailThread
+	err := page.
+		Limit(limit).
+		Find(&threads).Error
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, 0, err
+	}
+
+	return threads, count, nil
+}
+
 // CountByMailboxIDs counts total number of threads across all specified mailboxes
 func (r *emailThreadRepository) CountByMailboxIDs(ctx context.Context, mailboxIDs []string) (int64, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "emailThreadRepository.CountByMailboxIDs")
@@ -225,6 +299,9 @@ func (r *emailThreadRepository) Update(ctx context.Context, thread *models.Email
 	if len(thread.Participants) > 0 {
 		updates["participants"] = thread.Participants
 	}
+	if len(thread.ParticipantRoles) > 0 {
+		updates["participant_roles"] = thread.ParticipantRoles
+	}
 	if thread.LastMessageID != "" {
 		updates["last_message_id"] = strings.Trim(thread.LastMessageID, "<>")
 	}
@@ -494,7 +571,7 @@ func (r *emailThreadRepository) MarkThreadAsDone(ctx context.Context, threadID s
 
 	// Update the thread's done status
 	updates := map[string]interface{}{
-		"is_done":    isDone,
+		"isDone":     isDone,
 		"updated_at": utils.Now(),
 	}
 
@@ -516,3 +593,487 @@ func (r *emailThreadRepository) MarkThreadAsDone(ctx context.Context, threadID s
 
 	return nil
 }
+
+// MarkThreadsAsViewed marks the threads as viewed in a single update, returns the number of threads changed
+func (r *emailThreadRepository) MarkThreadsAsViewed(ctx context.Context, threadIDs []string) (int64, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailThreadRepository.MarkThreadsAsViewed")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("thread_count", len(threadIDs))
+
+	if len(threadIDs) == 0 {
+		return 0, nil
+	}
+
+	result := r.db.WithContext(ctx).Model(&models.EmailThread{}).
+		Where("id IN ? AND is_viewed = ?", threadIDs, false).
+		Updates(map[string]interface{}{
+			"is_viewed":  true,
+			"updated_at": utils.Now(),
+		})
+	if result.Error != nil {
+		tracing.TraceErr(span, result.Error)
+		return 0, result.Error
+	}
+
+	span.SetTag("updated_count", result.RowsAffected)
+	return result.RowsAffected, nil
+}
+
+// MarkThreadsAsDone sets the done status of the threads in a single update, returns the number of threads changed
+func (r *emailThreadRepository) MarkThreadsAsDone(ctx context.Context, threadIDs []string, isDone bool) (int64, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailThreadRepository.MarkThreadsAsDone")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("thread_count", len(threadIDs))
+	span.SetTag("is_done", isDone)
+
+	if len(threadIDs) == 0 {
+		return 0, nil
+	}
+
+	result := r.db.WithContext(ctx).Model(&models.EmailThread{}).
+		Where(`id IN ? AND "isDone" = ?`, threadIDs, !isDone).
+		Updates(map[string]interface{}{
+			"isDone":     isDone,
+			"updated_at": utils.Now(),
+		})
+	if result.Error != nil {
+		tracing.TraceErr(span, result.Error)
+		return 0, result.Error
+	}
+
+	span.SetTag("updated_count", result.RowsAffected)
+	return result.RowsAffected, nil
+}
+
+// Delete removes an email thread permanently, unlike SoftDelete
+func (r *emailThreadRepository) Delete(ctx context.Context, threadID string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailThreadRepository.Delete")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("thread_id", threadID)
+
+	if threadID == "" {
+		err := errors.New("thread ID cannot be empty")
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	err := r.db.WithContext(ctx).Unscoped().Delete(&models.EmailThread{}, "id = ?", threadID).Error
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	return nil
+}
+
+// MergeThreads moves the emails and orphan records of the source threads into the target
+// thread, recomputes its metadata from the emails and deletes the sources. All threads are
+// locked for the transaction, so concurrent merges and splits of them apply one after another.
+func (r *emailThreadRepository) MergeThreads(ctx context.Context, sourceIDs []string, targetID string) (*models.EmailThread, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailThreadRepository.MergeThreads")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("thread_id", targetID)
+	span.LogKV("source_ids", strings.Join(sourceIDs, ","))
+
+	sourceIDs = slices.Compact(slices.Sorted(slices.Values(sourceIDs)))
+	if targetID == "" || len(sourceIDs) == 0 || slices.Contains(sourceIDs, targetID) || slices.Contains(sourceIDs, "") {
+		err := errors.Wrap(mailstack_errors.ErrInvalidThreadMerge, "source threads and a different target thread are required")
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	var target *models.EmailThread
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		threads, err := lockThreads(tx, append([]string{targetID}, sourceIDs...))
+		if err != nil {
+			return err
+		}
+		target = threads[targetID]
+		for _, sourceID := range sourceIDs {
+			if threads[sourceID].MailboxID != target.MailboxID {
+				return mailstack_errors.ErrThreadMailboxMismatch
+			}
+			// the merged thread needs attention when any of its parts does
+			target.IsViewed = target.IsViewed && threads[sourceID].IsViewed
+			target.IsDone = target.IsDone && threads[sourceID].IsDone
+		}
+
+		// deleted emails move too, restoring them must not point them at a removed thread
+		if err = tx.Unscoped().Model(&models.Email{}).Where("thread_id IN ?", sourceIDs).Update("thread_id", targetID).Error; err != nil {
+			return err
+		}
+		if err = tx.Model(&models.OrphanEmail{}).Where("thread_id IN ?", sourceIDs).Update("thread_id", targetID).Error; err != nil {
+			return err
+		}
+		if err = recomputeThread(tx, target); err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&models.EmailThread{}, "id IN ?", sourceIDs).Error
+	})
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	return target, nil
+}
+
+// SplitThread moves the given emails of a thread into a new thread and recomputes the metadata
+// of both. The thread keeps at least one email, the new one takes over its done and viewed state.
+func (r *emailThreadRepository) SplitThread(ctx context.Context, threadID string, emailIDs []string) (*models.EmailThread, *models.EmailThread, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailThreadRepository.SplitThread")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("thread_id", threadID)
+	span.LogKV("email_ids", strings.Join(emailIDs, ","))
+
+	emailIDs = slices.Compact(slices.Sorted(slices.Values(emailIDs)))
+	if threadID == "" || len(emailIDs) == 0 {
+		err := errors.Wrap(mailstack_errors.ErrInvalidThreadSplit, "thread and emails to split off are required")
+		tracing.TraceErr(span, err)
+		return nil, nil, err
+	}
+
+	var original, split *models.EmailThread
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		threads, err := lockThreads(tx, []string{threadID})
+		if err != nil {
+			return err
+		}
+		original = threads[threadID]
+
+		var emails []*models.Email
+		if err = tx.Where("thread_id = ?", threadID).Find(&emails).Error; err != nil {
+			return err
+		}
+		var moving, remaining []*models.Email
+		var movingMessageIDs []string
+		for _, email := range emails {
+			if slices.Contains(emailIDs, email.ID) {
+				moving = append(moving, email)
+				movingMessageIDs = append(movingMessageIDs, strings.Trim(email.MessageID, "<>"))
+			} else {
+				remaining = append(remaining, email)
+			}
+		}
+		if len(moving) != len(emailIDs) {
+			return mailstack_errors.ErrEmailNotInThread
+		}
+		if len(remaining) == 0 {
+			return errors.Wrap(mailstack_errors.ErrInvalidThreadSplit, "a thread can not be split off entirely")
+		}
+
+		split = &models.EmailThread{
+			MailboxID: original.MailboxID,
+			IsDone:    original.IsDone,
+			IsViewed:  original.IsViewed,
+		}
+		split.Recompute(moving)
+		split.Subject = utils.NormalizeSubject(earliestEmail(moving).Subject)
+		split.UpdatedAt = utils.Now()
+		if err = tx.Create(split).Error; err != nil {
+			return err
+		}
+
+		if err = tx.Model(&models.Email{}).Where("id IN ?", emailIDs).Update("thread_id", split.ID).Error; err != nil {
+			return err
+		}
+		// parents still missing for the moved emails are awaited in the new thread
+		err = tx.Model(&models.OrphanEmail{}).
+			Where("thread_id = ? AND referenced_by IN ?", threadID, movingMessageIDs).
+			Update("thread_id", split.ID).Error
+		if err != nil {
+			return err
+		}
+
+		original.Recompute(remaining)
+		return saveThread(tx, original)
+	})
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, nil, err
+	}
+
+	return original, split, nil
+}
+
+// RecomputeMetadata rebuilds the metadata of a thread from its emails and stores it when it
+// drifted from the stored row. It returns the thread as stored afterwards and the json names of
+// the corrected fields, none when the thread was consistent.
+func (r *emailThreadRepository) RecomputeMetadata(ctx context.Context, threadID string) (*models.EmailThread, []string, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailThreadRepository.RecomputeMetadata")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("thread_id", threadID)
+
+	var thread *models.EmailThread
+	var corrected []string
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		threads, err := lockThreads(tx, []string{threadID})
+		if err != nil {
+			return err
+		}
+		stored := threads[threadID]
+
+		emails, err := threadEmails(tx, threadID)
+		if err != nil {
+			return err
+		}
+		recomputed := *stored
+		recomputed.Recompute(emails)
+
+		thread = stored
+		if corrected = stored.MetadataDiff(&recomputed); len(corrected) == 0 {
+			return nil
+		}
+		thread = &recomputed
+		return saveThread(tx, thread)
+	})
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, nil, err
+	}
+
+	span.LogKV("corrected_fields", strings.Join(corrected, ","))
+	return thread, corrected, nil
+}
+
+// ListIDsAfter returns the ids of up to limit threads ordered by id, starting after afterID, to
+// walk all threads in batches
+func (r *emailThreadRepository) ListIDsAfter(ctx context.Context, afterID string, limit int) ([]string, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailThreadRepository.ListIDsAfter")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.LogKV("after_id", afterID, "limit", limit)
+
+	var ids []string
+	err := r.db.WithContext(ctx).
+		Model(&models.EmailThread{}).
+		Where("id > ?", afterID).
+		Order("id").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// GetByIDIncludingDeleted retrieves a thread by its ID, also when it is soft deleted
+func (r *emailThreadRepository) GetByIDIncludingDeleted(ctx context.Context, id string) (*models.EmailThread, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailThreadRepository.GetByIDIncludingDeleted")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("thread_id", id)
+
+	var thread models.EmailThread
+	err := r.db.WithContext(ctx).Unscoped().Where("id = ?", id).First(&thread).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = errors.Wrapf(mailstack_errors.ErrThreadNotFound, "thread %s", id)
+		}
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	return &thread, nil
+}
+
+// SoftDelete moves a thread and its emails to the trash. The emails get the deletion time of
+// the thread, so restoring it brings back exactly the emails deleted with it.
+func (r *emailThreadRepository) SoftDelete(ctx context.Context, threadID string) (*models.EmailThread, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailThreadRepository.SoftDelete")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("thread_id", threadID)
+
+	var thread *models.EmailThread
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		threads, err := lockThreads(tx, []string{threadID})
+		if err != nil {
+			return err
+		}
+		thread = threads[threadID]
+
+		deletedAt := utils.Now()
+		result := tx.Model(&models.Email{}).Where("thread_id = ?", threadID).Update("deleted_at", deletedAt)
+		if result.Error != nil {
+			return result.Error
+		}
+		span.SetTag("email_count", result.RowsAffected)
+
+		thread.DeletedAt = gorm.DeletedAt{Time: deletedAt, Valid: true}
+		return tx.Model(&models.EmailThread{}).Where("id = ?", threadID).Update("deleted_at", deletedAt).Error
+	})
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	return thread, nil
+}
+
+// Restore takes a thread out of the trash together with the emails deleted with it, emails
+// deleted on their own before stay deleted. The metadata is recomputed from the restored emails.
+func (r *emailThreadRepository) Restore(ctx context.Context, threadID string) (*models.EmailThread, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailThreadRepository.Restore")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("thread_id", threadID)
+
+	var thread models.EmailThread
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Unscoped().
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", threadID).
+			First(&thread).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.Wrapf(mailstack_errors.ErrThreadNotFound, "thread %s", threadID)
+		}
+		if err != nil {
+			return err
+		}
+		if !thread.DeletedAt.Valid {
+			return mailstack_errors.ErrThreadNotDeleted
+		}
+
+		result := tx.Unscoped().
+			Model(&models.Email{}).
+			Where("thread_id = ? AND deleted_at = ?", threadID, thread.DeletedAt.Time).
+			Update("deleted_at", nil)
+		if result.Error != nil {
+			return result.Error
+		}
+		span.SetTag("email_count", result.RowsAffected)
+
+		if err = tx.Unscoped().Model(&models.EmailThread{}).Where("id = ?", threadID).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		thread.DeletedAt = gorm.DeletedAt{}
+		return recomputeThread(tx, &thread)
+	})
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	return &thread, nil
+}
+
+// PurgeDeleted permanently removes the threads deleted before the given time and the orphan
+// records still awaited in them. Their emails are purged by the email repository.
+func (r *emailThreadRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailThreadRepository.PurgeDeleted")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.LogKV("before", before)
+
+	var purged int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		deleted := tx.Unscoped().
+			Model(&models.EmailThread{}).
+			Select("id").
+			Where("deleted_at < ?", before)
+		if err := tx.Where("thread_id IN (?)", deleted).Delete(&models.OrphanEmail{}).Error; err != nil {
+			return err
+		}
+
+		result := tx.Unscoped().Where("deleted_at < ?", before).Delete(&models.EmailThread{})
+		purged = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return 0, err
+	}
+
+	span.SetTag("purged_count", purged)
+	return purged, nil
+}
+
+// lockThreads loads and locks the threads in a stable order, so transactions locking the same
+// threads can not deadlock
+func lockThreads(tx *gorm.DB, ids []string) (map[string]*models.EmailThread, error) {
+	var threads []*models.EmailThread
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", ids).
+		Order("id").
+		Find(&threads).Error
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]*models.EmailThread, len(threads))
+	for _, thread := range threads {
+		byID[thread.ID] = thread
+	}
+	for _, id := range ids {
+		if byID[id] == nil {
+			return nil, errors.Wrapf(mailstack_errors.ErrThreadNotFound, "thread %s", id)
+		}
+	}
+	return byID, nil
+}
+
+// recomputeThread rebuilds the metadata of a thread from the emails it holds inside tx
+func recomputeThread(tx *gorm.DB, thread *models.EmailThread) error {
+	emails, err := threadEmails(tx, thread.ID)
+	if err != nil {
+		return err
+	}
+	thread.Recompute(emails)
+	return saveThread(tx, thread)
+}
+
+// threadEmails loads the emails of a thread in a stable order, so recomputing the same emails
+// always picks the same last message among simultaneous ones
+func threadEmails(tx *gorm.DB, threadID string) ([]*models.Email, error) {
+	var emails []*models.Email
+	err := tx.Where("thread_id = ?", threadID).Order("created_at, id").Find(&emails).Error
+	return emails, err
+}
+
+// saveThread writes the recomputed fields, unlike Update it clears the ones that became empty
+func saveThread(tx *gorm.DB, thread *models.EmailThread) error {
+	thread.UpdatedAt = utils.Now()
+	return tx.Model(&models.EmailThread{}).
+		Where("id = ?", thread.ID).
+		Updates(map[string]interface{}{
+			"participants":      thread.Participants,
+			"participant_roles": thread.ParticipantRoles,
+			"has_attachments":   thread.HasAttachments,
+			"first_message_at":  thread.FirstMessageAt,
+			"last_message_at":   thread.LastMessageAt,
+			"last_message_id":   thread.LastMessageID,
+			"isDone":            thread.IsDone,
+			"is_viewed":         thread.IsViewed,
+			"updated_at":        thread.UpdatedAt,
+		}).Error
+}
+
+// earliestEmail returns the email sent or received first
+func earliestEmail(emails []*models.Email) *models.Email {
+	messageAt := func(email *models.Email) time.Time {
+		if email.SentAt != nil {
+			return *email.SentAt
+		}
+		if email.ReceivedAt != nil {
+			return *email.ReceivedAt
+		}
+		return email.CreatedAt
+	}
+
+	earliest := emails[0]
+	for _, email := range emails[1:] {
+		if messageAt(email).Before(messageAt(earliest)) {
+			earliest = email
+		}
+	}
+	return earliest
+}
@@ -7,4 +7,5 @@ var (
 	ErrEmailNotFound       = errors.New("email not found")
 	ErrSenderAlreadyExists = errors.New("sender already exists")
 	ErrInvalidInput        = errors.New("invalid input parameters")
+	ErrAttachmentNotFound  = errors.New("attachment not found")
 )
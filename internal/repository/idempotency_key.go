@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+type idempotencyKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyKeyRepository(db *gorm.DB) interfaces.IdempotencyKeyRepository {
+	return &idempotencyKeyRepository{db: db}
+}
+
+// Reserve inserts the key, taking over an expired one of the tenant in the same statement so two
+// requests racing on a key can not both win. When the tenant holds the key unexpired the stored
+// key is returned and nothing is written.
+func (r *idempotencyKeyRepository) Reserve(ctx context.Context, key *models.IdempotencyKey) (*models.IdempotencyKey, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "idempotencyKeyRepository.Reserve")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("tenant", key.Tenant)
+
+	if key.Tenant == "" || key.Key == "" {
+		return nil, ErrInvalidInput
+	}
+
+	result := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "tenant"}, {Name: "key"}},
+			Where: clause.Where{Exprs: []clause.Expression{
+				clause.Expr{SQL: `"idempotency_keys"."expires_at" < ?`, Vars: []interface{}{utils.Now()}},
+			}},
+			DoUpdates: clause.AssignmentColumns([]string{
+				"id", "endpoint", "request_hash", "status_code", "response_body", "created_at", "expires_at",
+			}),
+		}).
+		Create(key)
+	if result.Error != nil {
+		tracing.TraceErr(span, result.Error)
+		return nil, fmt.Errorf("failed to reserve idempotency key: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return nil, nil
+	}
+
+	var existing models.IdempotencyKey
+	err := r.db.WithContext(ctx).
+		Where("tenant = ? AND key = ?", key.Tenant, key.Key).
+		First(&existing).Error
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+
+	span.SetTag("replay", true)
+	return &existing, nil
+}
+
+// Complete stores the response of the request made with the key
+func (r *idempotencyKeyRepository) Complete(ctx context.Context, id string, statusCode int, body []byte) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "idempotencyKeyRepository.Complete")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("status_code", statusCode)
+
+	err := r.db.WithContext(ctx).
+		Model(&models.IdempotencyKey{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status_code":   statusCode,
+			"response_body": body,
+		}).Error
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return fmt.Errorf("failed to complete idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+// Release removes a key whose request ended without a response to replay, so it can be retried
+func (r *idempotencyKeyRepository) Release(ctx context.Context, id string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "idempotencyKeyRepository.Release")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+
+	if err := r.db.WithContext(ctx).Where("id = ?", id).Delete(&models.IdempotencyKey{}).Error; err != nil {
+		tracing.TraceErr(span, err)
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteExpired removes up to limit keys that expired before the given time
+func (r *idempotencyKeyRepository) DeleteExpired(ctx context.Context, before time.Time, limit int) (int64, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "idempotencyKeyRepository.DeleteExpired")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.LogKV("before", before, "limit", limit)
+
+	batch := r.db.Model(&models.IdempotencyKey{}).
+		Select("id").
+		Where("expires_at < ?", before).
+		Limit(limit)
+	result := r.db.WithContext(ctx).Where("id IN (?)", batch).Delete(&models.IdempotencyKey{})
+	if result.Error != nil {
+		tracing.TraceErr(span, result.Error)
+		return 0, fmt.Errorf("failed to delete expired idempotency keys: %w", result.Error)
+	}
+
+	span.SetTag("deleted_count", result.RowsAffected)
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"github.com/customeros/mailstack/internal/models"
+)
+
+func TestIdempotencyKeyReserveQuery(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: noConnPool{}}), &gorm.Config{DryRun: true})
+	require.NoError(t, err)
+
+	var insert string
+	var vars []interface{}
+	db.Callback().Create().After("gorm:create").Register("test:capture", func(tx *gorm.DB) {
+		insert, vars = tx.Statement.SQL.String(), tx.Statement.Vars
+	})
+
+	_, err = NewIdempotencyKeyRepository(db).Reserve(context.Background(), &models.IdempotencyKey{
+		Tenant:      "acme",
+		Key:         "purchase-1",
+		Endpoint:    "POST /v1/domains/purchase",
+		RequestHash: "abc",
+		ExpiresAt:   time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	// a key is only taken over once it expired, so concurrent requests can not both reserve it
+	assert.Contains(t, insert, `ON CONFLICT ("tenant","key") DO UPDATE SET "id"="excluded"."id"`)
+	assert.Contains(t, insert, `WHERE "idempotency_keys"."expires_at" < $`)
+	// the expiry is compared in UTC like the expires_at the middleware stores
+	now, ok := vars[len(vars)-1].(time.Time)
+	require.True(t, ok)
+	assert.Equal(t, time.UTC, now.Location())
+
+	// the conflict target is the unique index, otherwise Postgres rejects the statement
+	require.NoError(t, db.Statement.Parse(&models.IdempotencyKey{}))
+	index := db.Statement.Schema.LookIndex("idx_idempotency_keys_tenant_key")
+	require.NotNil(t, index)
+	assert.Equal(t, "UNIQUE", index.Class)
+	columns := make([]string, 0, len(index.Fields))
+	for _, field := range index.Fields {
+		columns = append(columns, field.DBName)
+	}
+	assert.Equal(t, []string{"tenant", "key"}, columns)
+
+	_, err = NewIdempotencyKeyRepository(db).Reserve(context.Background(), &models.IdempotencyKey{Tenant: "acme"})
+	assert.ErrorIs(t, err, ErrInvalidInput)
+}
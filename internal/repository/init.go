@@ -12,39 +12,56 @@ import (
 )
 
 type Repositories struct {
-	DomainRepository                DomainRepository
-	EmailRepository                 interfaces.EmailRepository
-	EmailAttachmentRepository       interfaces.EmailAttachmentRepository
-	EmailThreadRepository           interfaces.EmailThreadRepository
-	MailboxRepository               interfaces.MailboxRepository
-	MailboxSyncRepository           interfaces.MailboxSyncRepository
-	OrphanEmailRepository           interfaces.OrphanEmailRepository
-	SenderRepository                interfaces.SenderRepository
-	TenantSettingsMailboxRepository TenantSettingsMailboxRepository
+	AuditLogRepository                 interfaces.AuditLogRepository
+	DomainRepository                   DomainRepository
+	EmailRepository                    interfaces.EmailRepository
+	EmailAttachmentRepository          interfaces.EmailAttachmentRepository
+	EmailRawRepository                 interfaces.EmailRawRepository
+	EmailThreadRepository              interfaces.EmailThreadRepository
+	IdempotencyKeyRepository           interfaces.IdempotencyKeyRepository
+	MailboxAliasRepository             MailboxAliasRepository
+	MailboxRepository                  interfaces.MailboxRepository
+	MailboxFolderStatsRepository       interfaces.MailboxFolderStatsRepository
+	MailboxSendCountRepository         interfaces.MailboxSendCountRepository
+	MailboxSyncRepository              interfaces.MailboxSyncRepository
+	OrphanEmailRepository              interfaces.OrphanEmailRepository
+	SenderRepository                   interfaces.SenderRepository
+	SensitiveSubjectKeywordsRepository interfaces.SensitiveSubjectKeywordsRepository
+	StructuredBodyCacheRepository      interfaces.StructuredBodyCacheRepository
+	TenantCurrencyRepository           interfaces.TenantCurrencyRepository
+	TenantRetentionPolicyRepository    interfaces.TenantRetentionPolicyRepository
+	TenantSettingsMailboxRepository    TenantSettingsMailboxRepository
 }
 
-func InitRepositories(mailstackDB *gorm.DB, openlineDB *gorm.DB, r2Config *config.R2StorageConfig) *Repositories {
-	emailAttachmentStorage := storage.NewR2StorageService(
-		r2Config.AccountID,
-		r2Config.AccessKeyID,
-		r2Config.AccessKeySecret,
-		r2Config.EmailAttachmentBucket,
-		false, // private access
-	)
+func InitRepositories(mailstackDB *gorm.DB, openlineDB *gorm.DB, storageConfig *config.AttachmentStorageConfig, r2Config *config.R2StorageConfig) (*Repositories, error) {
+	emailAttachmentStorage, err := storage.NewAttachmentStorage(storageConfig, r2Config)
+	if err != nil {
+		return nil, err
+	}
 
 	return &Repositories{
 		// Openline
 		DomainRepository:                NewDomainRepository(openlineDB),
 		TenantSettingsMailboxRepository: NewTenantSettingsMailboxRepository(openlineDB),
+		MailboxAliasRepository:          NewMailboxAliasRepository(openlineDB),
 		// Mailstack
-		EmailRepository:           NewEmailRepository(mailstackDB),
-		EmailAttachmentRepository: NewEmailAttachmentRepository(mailstackDB, emailAttachmentStorage),
-		EmailThreadRepository:     NewEmailThreadRepository(mailstackDB),
-		MailboxRepository:         NewMailboxRepository(mailstackDB),
-		MailboxSyncRepository:     NewMailboxSyncRepository(mailstackDB),
-		OrphanEmailRepository:     NewOrphanEmailRepository(mailstackDB),
-		SenderRepository:          NewSenderRepository(mailstackDB),
-	}
+		AuditLogRepository:                 NewAuditLogRepository(mailstackDB),
+		EmailRepository:                    NewEmailRepository(mailstackDB),
+		EmailAttachmentRepository:          NewEmailAttachmentRepository(mailstackDB, emailAttachmentStorage),
+		EmailRawRepository:                 NewEmailRawRepository(emailAttachmentStorage),
+		EmailThreadRepository:              NewEmailThreadRepository(mailstackDB),
+		IdempotencyKeyRepository:           NewIdempotencyKeyRepository(mailstackDB),
+		MailboxRepository:                  NewMailboxRepository(mailstackDB),
+		MailboxFolderStatsRepository:       NewMailboxFolderStatsRepository(mailstackDB),
+		MailboxSendCountRepository:         NewMailboxSendCountRepository(mailstackDB),
+		MailboxSyncRepository:              NewMailboxSyncRepository(mailstackDB),
+		OrphanEmailRepository:              NewOrphanEmailRepository(mailstackDB),
+		SenderRepository:                   NewSenderRepository(mailstackDB),
+		SensitiveSubjectKeywordsRepository: NewSensitiveSubjectKeywordsRepository(mailstackDB),
+		StructuredBodyCacheRepository:      NewStructuredBodyCacheRepository(mailstackDB),
+		TenantCurrencyRepository:           NewTenantCurrencyRepository(mailstackDB),
+		TenantRetentionPolicyRepository:    NewTenantRetentionPolicyRepository(mailstackDB),
+	}, nil
 }
 
 func MigrateMailstackDB(dbConfig *config.MailstackDatabaseConfig, mailstackDB *gorm.DB) error {
@@ -55,15 +72,38 @@ func MigrateMailstackDB(dbConfig *config.MailstackDatabaseConfig, mailstackDB *g
 
 	db.SetMaxOpenConns(5)
 
+	// mailboxes existing when the warm-up columns are added are not warmed up again
+	addsWarmup := !mailstackDB.Migrator().HasColumn(&models.Mailbox{}, "warmup_start_limit")
+
 	err = mailstackDB.AutoMigrate(
+		&models.AuditLog{},
 		&models.Email{},
 		&models.EmailAttachment{},
 		&models.EmailThread{},
+		&models.IdempotencyKey{},
 		&models.Mailbox{},
+		&models.MailboxDailySendCount{},
+		&models.MailboxFolderStats{},
 		&models.MailboxSyncState{},
 		&models.OrphanEmail{},
 		&models.Sender{},
+		&models.SensitiveSubjectKeywords{},
+		&models.StructuredBodyCache{},
+		&models.TenantCurrency{},
+		&models.TenantRetentionPolicy{},
 	)
+	if err == nil {
+		// orphan emails were unique by message id alone, they are unique per mailbox now
+		if mailstackDB.Migrator().HasIndex(&models.OrphanEmail{}, "idx_orphan_emails_message_id") {
+			err = mailstackDB.Migrator().DropIndex(&models.OrphanEmail{}, "idx_orphan_emails_message_id")
+		}
+	}
+	if err == nil {
+		err = migrateMailboxSendLimits(mailstackDB, addsWarmup)
+	}
+	if err == nil {
+		err = encryptCredentials(mailstackDB, models.Mailbox{}.TableName(), "imap_password", "smtp_password")
+	}
 
 	db.SetMaxIdleConns(dbConfig.MaxIdleConn)
 	db.SetMaxOpenConns(dbConfig.MaxConn)
@@ -72,6 +112,27 @@ func MigrateMailstackDB(dbConfig *config.MailstackDatabaseConfig, mailstackDB *g
 	return err
 }
 
+// migrateMailboxSendLimits disables the warm-up of the mailboxes that existed before it, and drops
+// the send count columns replaced by the mailbox_daily_send_counts table
+func migrateMailboxSendLimits(db *gorm.DB, addsWarmup bool) error {
+	if addsWarmup {
+		err := db.Model(&models.Mailbox{}).
+			Where("warmup_start_limit <> 0 OR warmup_daily_increase <> 0").
+			UpdateColumns(map[string]interface{}{"warmup_start_limit": 0, "warmup_daily_increase": 0}).Error
+		if err != nil {
+			return err
+		}
+	}
+	for _, column := range []string{"daily_send_count", "quota_reset_at"} {
+		if db.Migrator().HasColumn(&models.Mailbox{}, column) {
+			if err := db.Migrator().DropColumn(&models.Mailbox{}, column); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func MigrateOpenlineDB(dbConfig *config.OpenlineDatabaseConfig, openlineDB *gorm.DB) error {
 	db, err := openlineDB.DB()
 	if err != nil {
@@ -85,7 +146,12 @@ func MigrateOpenlineDB(dbConfig *config.OpenlineDatabaseConfig, openlineDB *gorm
 		&models.MailStackDomain{},
 		&models.TenantSettingsMailbox{},
 		&models.MailstackReputation{},
+		&models.MailboxAlias{},
+		&models.MailStackDomainPurchase{},
 	)
+	if err == nil {
+		err = encryptCredentials(openlineDB, models.TenantSettingsMailbox{}.TableName(), "mailbox_password")
+	}
 
 	db.SetMaxIdleConns(dbConfig.MaxIdleConn)
 	db.SetMaxOpenConns(dbConfig.MaxConn)
@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+// keysetPage orders query by the time expression, rows without a time last, then by id, and
+// keeps only the rows after the cursor. Unlike an offset, the cursor still points at the same
+// row when rows are inserted or removed before it between two pages.
+func keysetPage(query *gorm.DB, timeExpr string, after *utils.Cursor, descending bool) *gorm.DB {
+	direction, beyond := "ASC", ">"
+	if descending {
+		direction, beyond = "DESC", "<"
+	}
+
+	if after != nil {
+		if after.At == nil {
+			// only rows without a time remain, they are ordered by id alone
+			query = query.Where(fmt.Sprintf("%s IS NULL AND id %s ?", timeExpr, beyond), after.ID)
+		} else {
+			query = query.Where(
+				fmt.Sprintf("%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?) OR %[1]s IS NULL", timeExpr, beyond),
+				*after.At, *after.At, after.ID,
+			)
+		}
+	}
+
+	return query.
+		Order(fmt.Sprintf("%s %s NULLS LAST", timeExpr, direction)).
+		Order("id " + direction)
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+// noConnPool lets gorm build statements in dry run mode without a database
+type noConnPool struct{}
+
+func (noConnPool) PrepareContext(context.Context, string) (*sql.Stmt, error) { return nil, nil }
+func (noConnPool) ExecContext(context.Context, string, ...interface{}) (sql.Result, error) {
+	return nil, nil
+}
+func (noConnPool) QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error) {
+	return nil, nil
+}
+func (noConnPool) QueryRowContext(context.Context, string, ...interface{}) *sql.Row { return nil }
+func (noConnPool) BeginTx(context.Context, *sql.TxOptions) (gorm.ConnPool, error) {
+	return &noConnPool{}, nil
+}
+func (noConnPool) Commit() error   { return nil }
+func (noConnPool) Rollback() error { return nil }
+
+func TestKeysetPage(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: noConnPool{}}), &gorm.Config{DryRun: true})
+	require.NoError(t, err)
+	at := time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)
+
+	build := func(after *utils.Cursor, descending bool) (string, []interface{}) {
+		var threads []*models.EmailThread
+		stmt := keysetPage(db.Where("mailbox_id = ?", "mbox_1"), "last_message_at", after, descending).
+			Limit(3).Find(&threads).Statement
+		return stmt.SQL.String(), stmt.Vars
+	}
+
+	query, vars := build(nil, true)
+	assert.Equal(t, `SELECT * FROM "email_threads" WHERE mailbox_id = $1 AND "email_threads"."deleted_at" IS NULL ORDER BY last_message_at DESC NULLS LAST,id DESC LIMIT $2`, query)
+	assert.Equal(t, []interface{}{"mbox_1", 3}, vars)
+
+	query, vars = build(&utils.Cursor{At: &at, ID: "thrd_5"}, true)
+	assert.Equal(t, `SELECT * FROM "email_threads" WHERE mailbox_id = $1 AND `+
+		`(last_message_at < $2 OR (last_message_at = $3 AND id < $4) OR last_message_at IS NULL) `+
+		`AND "email_threads"."deleted_at" IS NULL ORDER BY last_message_at DESC NULLS LAST,id DESC LIMIT $5`, query)
+	assert.Equal(t, []interface{}{"mbox_1", at, at, "thrd_5", 3}, vars)
+
+	query, _ = build(&utils.Cursor{At: &at, ID: "thrd_5"}, false)
+	assert.Contains(t, query, "(last_message_at > $2 OR (last_message_at = $3 AND id > $4) OR last_message_at IS NULL)")
+	assert.Contains(t, query, "ORDER BY last_message_at ASC NULLS LAST,id ASC")
+
+	query, vars = build(&utils.Cursor{ID: "thrd_5"}, true)
+	assert.Contains(t, query, "WHERE mailbox_id = $1 AND (last_message_at IS NULL AND id < $2) AND")
+	assert.Equal(t, []interface{}{"mbox_1", "thrd_5", 3}, vars)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/lib/pq"
 	"github.com/opentracing/opentracing-go"
 	"gorm.io/gorm"
 
@@ -54,6 +55,22 @@ func (r *mailboxRepository) GetMailboxesByUserID(ctx context.Context, userID str
 	return mailboxes, nil
 }
 
+func (r *mailboxRepository) GetMailboxesByTenant(ctx context.Context, tenant string) ([]*models.Mailbox, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "mailboxRepository.GetMailboxesByTenant")
+	defer span.Finish()
+	tracing.SetDefaultPostgresRepositorySpanTags(ctx, span)
+	tracing.TagTenant(span, tenant)
+
+	var mailboxes []*models.Mailbox
+	result := r.db.WithContext(ctx).Where("tenant = ?", tenant).Find(&mailboxes)
+	if result.Error != nil {
+		tracing.TraceErr(span, result.Error)
+		return nil, result.Error
+	}
+
+	return mailboxes, nil
+}
+
 func (r *mailboxRepository) GetMailbox(ctx context.Context, id string) (*models.Mailbox, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "mailboxRepository.GetMailbox")
 	defer span.Finish()
@@ -117,14 +134,21 @@ func (r *mailboxRepository) UpdateConnectionStatus(ctx context.Context, mailboxI
 	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	// Update the connection status, error message, and last connection check time
+	// Update the connection status, error message, and last connection check time. The time the
+	// mailbox first lost its connection is kept across failed reconnects.
+	now := time.Now()
+	disconnectedSince := gorm.Expr("COALESCE(disconnected_since, ?)", now)
+	if status == enum.ConnectionActive {
+		disconnectedSince = gorm.Expr("NULL")
+	}
 	result := r.db.WithContext(timeoutCtx).Model(&models.Mailbox{}).
 		Where("id = ?", mailboxID).
 		Updates(map[string]interface{}{
 			"connection_status":     status,
 			"error_message":         errorMessage,
-			"last_connection_check": time.Now(),
-			"updated_at":            time.Now(),
+			"last_connection_check": now,
+			"disconnected_since":    disconnectedSince,
+			"updated_at":            now,
 		})
 
 	if result.Error != nil {
@@ -141,3 +165,24 @@ func (r *mailboxRepository) UpdateConnectionStatus(ctx context.Context, mailboxI
 	span.LogKV("affectedRows", result.RowsAffected)
 	return nil
 }
+
+// UpdateSyncFolders replaces the folders synced for a mailbox
+func (r *mailboxRepository) UpdateSyncFolders(ctx context.Context, mailboxID string, folders []string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "mailboxRepository.UpdateSyncFolders")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("mailbox.id", mailboxID)
+
+	err := r.db.WithContext(ctx).Model(&models.Mailbox{}).
+		Where("id = ?", mailboxID).
+		Updates(map[string]interface{}{
+			"sync_folders": pq.StringArray(folders),
+			"updated_at":   time.Now(),
+		}).Error
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return fmt.Errorf("failed to update mailbox sync folders: %w", err)
+	}
+
+	return nil
+}
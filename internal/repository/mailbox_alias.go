@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/opentracing/opentracing-go"
+	tracingLog "github.com/opentracing/opentracing-go/log"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+type MailboxAliasRepository interface {
+	Upsert(ctx context.Context, alias *models.MailboxAlias) error
+	GetByAlias(ctx context.Context, tenant, alias string) (*models.MailboxAlias, error)
+	ListByDomain(ctx context.Context, tenant, domain string) ([]*models.MailboxAlias, error)
+	Delete(ctx context.Context, tenant, alias string) error
+}
+
+type mailboxAliasRepository struct {
+	db *gorm.DB
+}
+
+func NewMailboxAliasRepository(db *gorm.DB) MailboxAliasRepository {
+	return &mailboxAliasRepository{db: db}
+}
+
+// Upsert creates the alias or updates its destination
+func (r *mailboxAliasRepository) Upsert(ctx context.Context, alias *models.MailboxAlias) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "MailboxAliasRepository.Upsert")
+	defer span.Finish()
+	tracing.SetDefaultPostgresRepositorySpanTags(ctx, span)
+	span.LogFields(tracingLog.String("alias", alias.Alias), tracingLog.String("forwardTo", alias.ForwardTo))
+
+	now := utils.Now()
+	alias.CreatedAt = now
+	alias.UpdatedAt = now
+
+	err := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "alias"}},
+			DoUpdates: clause.AssignmentColumns([]string{"forward_to", "catch_all", "updated_at"}),
+		}).
+		Create(alias).Error
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "db error"))
+		return err
+	}
+
+	return nil
+}
+
+func (r *mailboxAliasRepository) GetByAlias(ctx context.Context, tenant, alias string) (*models.MailboxAlias, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "MailboxAliasRepository.GetByAlias")
+	defer span.Finish()
+	tracing.SetDefaultPostgresRepositorySpanTags(ctx, span)
+	span.LogFields(tracingLog.String("alias", alias))
+
+	var result models.MailboxAlias
+	err := r.db.WithContext(ctx).
+		Where("tenant = ? AND alias = ?", tenant, alias).
+		First(&result).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			span.LogFields(tracingLog.Bool("result.found", false))
+			return nil, nil
+		}
+		tracing.TraceErr(span, errors.Wrap(err, "db error"))
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (r *mailboxAliasRepository) ListByDomain(ctx context.Context, tenant, domain string) ([]*models.MailboxAlias, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "MailboxAliasRepository.ListByDomain")
+	defer span.Finish()
+	tracing.SetDefaultPostgresRepositorySpanTags(ctx, span)
+	span.LogFields(tracingLog.String("domain", domain))
+
+	var result []*models.MailboxAlias
+	err := r.db.WithContext(ctx).
+		Where("tenant = ? AND domain = ?", tenant, domain).
+		Order("alias").
+		Find(&result).Error
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "db error"))
+		return nil, err
+	}
+
+	span.LogFields(tracingLog.Int("result.count", len(result)))
+	return result, nil
+}
+
+func (r *mailboxAliasRepository) Delete(ctx context.Context, tenant, alias string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "MailboxAliasRepository.Delete")
+	defer span.Finish()
+	tracing.SetDefaultPostgresRepositorySpanTags(ctx, span)
+	span.LogFields(tracingLog.String("alias", alias))
+
+	err := r.db.WithContext(ctx).
+		Where("tenant = ? AND alias = ?", tenant, alias).
+		Delete(&models.MailboxAlias{}).Error
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "db error"))
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+)
+
+type mailboxFolderStatsRepository struct {
+	db *gorm.DB
+}
+
+func NewMailboxFolderStatsRepository(db *gorm.DB) interfaces.MailboxFolderStatsRepository {
+	return &mailboxFolderStatsRepository{db: db}
+}
+
+// Save creates or replaces the stats of a mailbox folder
+func (r *mailboxFolderStatsRepository) Save(ctx context.Context, stats *models.MailboxFolderStats) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "mailboxFolderStatsRepository.Save")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("mailbox.id", stats.MailboxID)
+
+	stats.UpdatedAt = time.Now()
+	err := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "mailbox_id"}, {Name: "folder_name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"total", "unseen", "last_seen_uid", "last_sync", "updated_at"}),
+		}).
+		Create(stats).Error
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return fmt.Errorf("failed to save mailbox folder stats: %w", err)
+	}
+
+	return nil
+}
+
+// GetByMailbox returns the stats of every synced folder of a mailbox
+func (r *mailboxFolderStatsRepository) GetByMailbox(ctx context.Context, mailboxID string) ([]models.MailboxFolderStats, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "mailboxFolderStatsRepository.GetByMailbox")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("mailbox.id", mailboxID)
+
+	var stats []models.MailboxFolderStats
+	err := r.db.WithContext(ctx).
+		Where("mailbox_id = ?", mailboxID).
+		Order("folder_name").
+		Find(&stats).Error
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, fmt.Errorf("failed to get mailbox folder stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+func (r *mailboxFolderStatsRepository) DeleteByMailbox(ctx context.Context, mailboxID string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "mailboxFolderStatsRepository.DeleteByMailbox")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("mailbox.id", mailboxID)
+
+	err := r.db.WithContext(ctx).
+		Where("mailbox_id = ?", mailboxID).
+		Delete(&models.MailboxFolderStats{}).Error
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return fmt.Errorf("failed to delete mailbox folder stats: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteByFolder removes the stats of one folder of a mailbox, once it is no longer synced
+func (r *mailboxFolderStatsRepository) DeleteByFolder(ctx context.Context, mailboxID, folderName string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "mailboxFolderStatsRepository.DeleteByFolder")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("mailbox.id", mailboxID)
+	span.SetTag("folder", folderName)
+
+	err := r.db.WithContext(ctx).
+		Where("mailbox_id = ? AND folder_name = ?", mailboxID, folderName).
+		Delete(&models.MailboxFolderStats{}).Error
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return fmt.Errorf("failed to delete mailbox folder stats: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+type mailboxSendCountRepository struct {
+	db *gorm.DB
+}
+
+func NewMailboxSendCountRepository(db *gorm.DB) interfaces.MailboxSendCountRepository {
+	return &mailboxSendCountRepository{db: db}
+}
+
+// Reserve counts a send of the mailbox on the day, unless the count already reached limit.
+// The check and the increment are a single statement, so concurrent senders cannot go over
+// the limit. A limit of 0 or less always reserves. Returns false when the limit is reached.
+func (r *mailboxSendCountRepository) Reserve(ctx context.Context, mailboxID string, day time.Time, limit int) (bool, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "mailboxSendCountRepository.Reserve")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("mailbox.id", mailboxID)
+	span.SetTag("limit", limit)
+
+	table := models.MailboxDailySendCount{}.TableName()
+	onConflict := clause.OnConflict{
+		Columns: []clause.Column{{Name: "mailbox_id"}, {Name: "date"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"count":      gorm.Expr(table + ".count + 1"),
+			"updated_at": utils.Now(),
+		}),
+	}
+	if limit > 0 {
+		onConflict.Where = clause.Where{Exprs: []clause.Expression{
+			clause.Expr{SQL: table + ".count < ?", Vars: []interface{}{limit}},
+		}}
+	}
+
+	result := r.db.WithContext(ctx).
+		Clauses(onConflict).
+		Create(&models.MailboxDailySendCount{
+			MailboxID: mailboxID,
+			Date:      utils.StartOfDayInUTC(day.UTC()),
+			Count:     1,
+		})
+	if result.Error != nil {
+		tracing.TraceErr(span, result.Error)
+		return false, fmt.Errorf("failed to reserve daily send: %w", result.Error)
+	}
+
+	// the conflict update is skipped when the limit is reached, leaving no row affected
+	return result.RowsAffected > 0, nil
+}
+
+// Release gives back a reserved send that did not go out
+func (r *mailboxSendCountRepository) Release(ctx context.Context, mailboxID string, day time.Time) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "mailboxSendCountRepository.Release")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("mailbox.id", mailboxID)
+
+	err := r.db.WithContext(ctx).
+		Model(&models.MailboxDailySendCount{}).
+		Where("mailbox_id = ? AND date = ? AND count > 0", mailboxID, utils.StartOfDayInUTC(day.UTC())).
+		Updates(map[string]interface{}{
+			"count":      gorm.Expr("count - 1"),
+			"updated_at": utils.Now(),
+		}).Error
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return fmt.Errorf("failed to release daily send: %w", err)
+	}
+
+	return nil
+}
+
+// Get returns the sends of the mailbox counted on the day, 0 when it sent nothing
+func (r *mailboxSendCountRepository) Get(ctx context.Context, mailboxID string, day time.Time) (int, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "mailboxSendCountRepository.Get")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("mailbox.id", mailboxID)
+
+	var count models.MailboxDailySendCount
+	err := r.db.WithContext(ctx).
+		Where("mailbox_id = ? AND date = ?", mailboxID, utils.StartOfDayInUTC(day.UTC())).
+		First(&count).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return 0, fmt.Errorf("failed to get daily send count: %w", err)
+	}
+
+	return count.Count, nil
+}
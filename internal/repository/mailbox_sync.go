@@ -75,6 +75,29 @@ func (r *mailboxSyncRepository) SaveSyncState(ctx context.Context, state *models
 	return nil
 }
 
+// SaveInitialSyncProgress saves the initial sync counts and completion of a mailbox folder
+func (r *mailboxSyncRepository) SaveInitialSyncProgress(ctx context.Context, state *models.MailboxSyncState) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "mailboxSyncRepository.SaveInitialSyncProgress")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+
+	err := r.db.WithContext(ctx).
+		Model(&models.MailboxSyncState{}).
+		Where("mailbox_id = ? AND folder_name = ?", state.MailboxID, state.FolderName).
+		Updates(map[string]interface{}{
+			"initial_sync_processed": state.InitialSyncProcessed,
+			"initial_sync_total":     state.InitialSyncTotal,
+			"initial_sync_complete":  state.InitialSyncComplete,
+			"updated_at":             time.Now(),
+		}).Error
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return fmt.Errorf("failed to save initial sync progress: %w", err)
+	}
+
+	return nil
+}
+
 // DeleteSyncState deletes the sync state for a mailbox folder
 func (r *mailboxSyncRepository) DeleteSyncState(ctx context.Context, mailboxID, folderName string) error {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "mailboxSyncRepository.DeleteSyncState")
@@ -55,10 +55,10 @@ func (r *orphanEmailRepository) Create(ctx context.Context, orphan *models.Orpha
 		return "", tx.Error
 	}
 
-	// Check if an entry with this message ID already exists
+	// Check if an entry with this message ID already exists in the mailbox
 	var count int64
 	if err := tx.Model(&models.OrphanEmail{}).
-		Where("message_id = ?", orphan.MessageID).
+		Where("mailbox_id = ? AND message_id = ?", orphan.MailboxID, orphan.MessageID).
 		Count(&count).Error; err != nil {
 		tx.Rollback()
 		tracing.TraceErr(span, err)
@@ -114,11 +114,12 @@ func (r *orphanEmailRepository) GetByID(ctx context.Context, id string) (*models
 	return &orphan, nil
 }
 
-// GetByMessageID retrieves orphan emails by message ID
-func (r *orphanEmailRepository) GetByMessageID(ctx context.Context, messageID string) (*models.OrphanEmail, error) {
+// GetByMessageID retrieves the orphan email of a message ID in a mailbox
+func (r *orphanEmailRepository) GetByMessageID(ctx context.Context, mailboxID, messageID string) (*models.OrphanEmail, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "orphanEmailRepository.GetByMessageID")
 	defer span.Finish()
 	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("mailbox_id", mailboxID)
 	span.SetTag("message_id", messageID)
 
 	if messageID == "" {
@@ -128,7 +129,7 @@ func (r *orphanEmailRepository) GetByMessageID(ctx context.Context, messageID st
 	}
 
 	var orphans *models.OrphanEmail
-	err := r.db.WithContext(ctx).Where("message_id = ?", messageID).First(&orphans).Error
+	err := r.db.WithContext(ctx).Where("mailbox_id = ? AND message_id = ?", mailboxID, messageID).First(&orphans).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
@@ -222,6 +223,32 @@ func (r *orphanEmailRepository) DeleteByThreadID(ctx context.Context, threadID s
 	return nil
 }
 
+// MoveToThread points the orphan records of one thread at another thread
+func (r *orphanEmailRepository) MoveToThread(ctx context.Context, fromThreadID, toThreadID string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "orphanEmailRepository.MoveToThread")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("from_thread_id", fromThreadID)
+	span.SetTag("to_thread_id", toThreadID)
+
+	if fromThreadID == "" || toThreadID == "" {
+		err := errors.New("thread ID cannot be empty")
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	err := r.db.WithContext(ctx).
+		Model(&models.OrphanEmail{}).
+		Where("thread_id = ?", fromThreadID).
+		Update("thread_id", toThreadID).Error
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	return nil
+}
+
 // ListByThreadID retrieves orphan emails by thread ID
 func (r *orphanEmailRepository) ListByThreadID(ctx context.Context, threadID string) ([]*models.OrphanEmail, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "orphanEmailRepository.ListByThreadID")
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+)
+
+type sensitiveSubjectKeywordsRepository struct {
+	db *gorm.DB
+}
+
+func NewSensitiveSubjectKeywordsRepository(db *gorm.DB) interfaces.SensitiveSubjectKeywordsRepository {
+	return &sensitiveSubjectKeywordsRepository{db: db}
+}
+
+// GetByTenant returns the sensitive subject overrides of a tenant, one per category
+func (r *sensitiveSubjectKeywordsRepository) GetByTenant(ctx context.Context, tenant string) ([]models.SensitiveSubjectKeywords, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "sensitiveSubjectKeywordsRepository.GetByTenant")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+
+	var keywords []models.SensitiveSubjectKeywords
+	err := r.db.WithContext(ctx).
+		Where("tenant = ?", tenant).
+		Order("category").
+		Find(&keywords).Error
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, fmt.Errorf("failed to get sensitive subject keywords: %w", err)
+	}
+
+	return keywords, nil
+}
+
+// Save creates or replaces the override of a tenant category
+func (r *sensitiveSubjectKeywordsRepository) Save(ctx context.Context, keywords *models.SensitiveSubjectKeywords) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "sensitiveSubjectKeywordsRepository.Save")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+
+	keywords.UpdatedAt = time.Now()
+	err := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "tenant"}, {Name: "category"}},
+			DoUpdates: clause.AssignmentColumns([]string{"keywords", "disabled", "updated_at"}),
+		}).
+		Create(keywords).Error
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return fmt.Errorf("failed to save sensitive subject keywords: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/opentracing/opentracing-go"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+)
+
+type structuredBodyCacheRepository struct {
+	db *gorm.DB
+}
+
+func NewStructuredBodyCacheRepository(db *gorm.DB) interfaces.StructuredBodyCacheRepository {
+	return &structuredBodyCacheRepository{db: db}
+}
+
+// Get returns the cached structured body of a body hash, nil when it is not cached
+func (r *structuredBodyCacheRepository) Get(ctx context.Context, bodyHash string) (*models.StructuredBodyCache, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "structuredBodyCacheRepository.Get")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("body_hash", bodyHash)
+
+	var entry models.StructuredBodyCache
+	err := r.db.WithContext(ctx).Where("body_hash = ?", bodyHash).First(&entry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, fmt.Errorf("failed to get structured body cache: %w", err)
+	}
+
+	return &entry, nil
+}
+
+// Save caches a structured body, an entry cached concurrently for the same hash is kept
+func (r *structuredBodyCacheRepository) Save(ctx context.Context, entry *models.StructuredBodyCache) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "structuredBodyCacheRepository.Save")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+	span.SetTag("body_hash", entry.BodyHash)
+
+	err := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(entry).Error
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return fmt.Errorf("failed to save structured body cache: %w", err)
+	}
+
+	return nil
+}
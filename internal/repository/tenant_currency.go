@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+)
+
+type tenantCurrencyRepository struct {
+	db *gorm.DB
+}
+
+func NewTenantCurrencyRepository(db *gorm.DB) interfaces.TenantCurrencyRepository {
+	return &tenantCurrencyRepository{db: db}
+}
+
+// GetByTenant returns the currency of a tenant, nil when it has none
+func (r *tenantCurrencyRepository) GetByTenant(ctx context.Context, tenant string) (*models.TenantCurrency, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "tenantCurrencyRepository.GetByTenant")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+
+	var currency models.TenantCurrency
+	err := r.db.WithContext(ctx).Where("tenant = ?", tenant).First(&currency).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, fmt.Errorf("failed to get tenant currency: %w", err)
+	}
+
+	return &currency, nil
+}
+
+// Save creates or replaces the currency of a tenant
+func (r *tenantCurrencyRepository) Save(ctx context.Context, currency *models.TenantCurrency) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "tenantCurrencyRepository.Save")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+
+	currency.UpdatedAt = time.Now()
+	err := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "tenant"}},
+			DoUpdates: clause.AssignmentColumns([]string{"currency", "updated_at"}),
+		}).
+		Create(currency).Error
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return fmt.Errorf("failed to save tenant currency: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+)
+
+type tenantRetentionPolicyRepository struct {
+	db *gorm.DB
+}
+
+func NewTenantRetentionPolicyRepository(db *gorm.DB) interfaces.TenantRetentionPolicyRepository {
+	return &tenantRetentionPolicyRepository{db: db}
+}
+
+// GetByTenant returns the retention policy of a tenant, nil when it has none
+func (r *tenantRetentionPolicyRepository) GetByTenant(ctx context.Context, tenant string) (*models.TenantRetentionPolicy, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "tenantRetentionPolicyRepository.GetByTenant")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+
+	var policy models.TenantRetentionPolicy
+	err := r.db.WithContext(ctx).Where("tenant = ?", tenant).First(&policy).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, fmt.Errorf("failed to get retention policy: %w", err)
+	}
+
+	return &policy, nil
+}
+
+// List returns the retention policies of all tenants
+func (r *tenantRetentionPolicyRepository) List(ctx context.Context) ([]models.TenantRetentionPolicy, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "tenantRetentionPolicyRepository.List")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+
+	var policies []models.TenantRetentionPolicy
+	if err := r.db.WithContext(ctx).Order("tenant").Find(&policies).Error; err != nil {
+		tracing.TraceErr(span, err)
+		return nil, fmt.Errorf("failed to list retention policies: %w", err)
+	}
+
+	return policies, nil
+}
+
+// Save creates or replaces the retention policy of a tenant
+func (r *tenantRetentionPolicyRepository) Save(ctx context.Context, policy *models.TenantRetentionPolicy) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "tenantRetentionPolicyRepository.Save")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+
+	policy.UpdatedAt = time.Now()
+	err := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "tenant"}},
+			DoUpdates: clause.AssignmentColumns([]string{"retention_days", "updated_at"}),
+		}).
+		Create(policy).Error
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return fmt.Errorf("failed to save retention policy: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes the retention policy of a tenant, its mail is kept from then on
+func (r *tenantRetentionPolicyRepository) Delete(ctx context.Context, tenant string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "tenantRetentionPolicyRepository.Delete")
+	defer span.Finish()
+	tracing.TagComponentPostgresRepository(span)
+
+	if err := r.db.WithContext(ctx).Where("tenant = ?", tenant).Delete(&models.TenantRetentionPolicy{}).Error; err != nil {
+		tracing.TraceErr(span, err)
+		return fmt.Errorf("failed to delete retention policy: %w", err)
+	}
+
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/secrets"
 	"github.com/customeros/mailstack/internal/tracing"
 	"github.com/customeros/mailstack/internal/utils"
 	"github.com/opentracing/opentracing-go"
@@ -28,6 +29,8 @@ type TenantSettingsMailboxRepository interface {
 	Update(ctx context.Context, tx *gorm.DB, mailbox *models.TenantSettingsMailbox) error
 	UpdateStatus(ctx context.Context, id string, status models.MailboxStatus) error
 	UpdateRampUpFields(ctx context.Context, mailbox *models.TenantSettingsMailbox) error
+	UpdatePassword(ctx context.Context, id, password string) error
+	Delete(ctx context.Context, id string) error
 }
 
 func NewTenantSettingsMailboxRepository(db *gorm.DB) TenantSettingsMailboxRepository {
@@ -225,6 +228,7 @@ func (r *tenantSettingsMailboxRepository) Create(ctx context.Context, tx *gorm.D
 		tracing.TraceErr(span, err)
 		return err
 	}
+	input.ID = mailbox.ID
 
 	return nil
 }
@@ -316,3 +320,53 @@ func (r *tenantSettingsMailboxRepository) UpdateRampUpFields(ctx context.Context
 
 	return nil
 }
+
+func (r *tenantSettingsMailboxRepository) UpdatePassword(ctx context.Context, id, password string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "TenantSettingsMailboxRepository.UpdatePassword")
+	defer span.Finish()
+	tracing.SetDefaultPostgresRepositorySpanTags(ctx, span)
+	tracing.TagEntity(span, id)
+
+	tenant := utils.GetTenantFromContext(ctx)
+
+	// column updates skip the model hooks
+	encrypted, keyID, err := secrets.Encrypt(password)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	err = r.gormDb.WithContext(ctx).
+		Model(&models.TenantSettingsMailbox{}).
+		Where("tenant = ? AND id = ?", tenant, id).
+		UpdateColumns(map[string]interface{}{
+			"mailbox_password":        encrypted,
+			"mailbox_password_key_id": keyID,
+			"updated_at":              utils.Now(),
+		}).Error
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "db error"))
+		return err
+	}
+
+	return nil
+}
+
+func (r *tenantSettingsMailboxRepository) Delete(ctx context.Context, id string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "TenantSettingsMailboxRepository.Delete")
+	defer span.Finish()
+	tracing.SetDefaultPostgresRepositorySpanTags(ctx, span)
+	tracing.TagEntity(span, id)
+
+	tenant := utils.GetTenantFromContext(ctx)
+
+	err := r.gormDb.WithContext(ctx).
+		Where("tenant = ? AND id = ?", tenant, id).
+		Delete(&models.TenantSettingsMailbox{}).Error
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "db error"))
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+// Package secrets encrypts credentials stored in the database with AES-256-GCM.
+//
+// An encrypted value is stored as base64 nonce and ciphertext, with the id of the key it was
+// encrypted with in a column next to it. Values without a key id are plaintext written before
+// encryption was enabled and are returned as they are, whatever they look like. New values use
+// the active key, values of older keys are still decrypted as long as the key is configured,
+// which lets keys be rotated without downtime.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// maxKeyIDLength is the size of the key id columns
+const maxKeyIDLength = 50
+
+var (
+	ErrUnknownKey = errors.New("encryption key not configured")
+	ErrMalformed  = errors.New("malformed encrypted value")
+)
+
+// Keyring holds the configured keys by id, new values are encrypted with the active one
+type Keyring struct {
+	keys   map[string]cipher.AEAD
+	active string
+}
+
+// NewKeyring parses keys given as comma separated "id:base64 key" pairs, each key 32 bytes long.
+// Returns nil when no keys are configured, values are then stored in plaintext.
+func NewKeyring(keys, activeKeyID string) (*Keyring, error) {
+	if strings.TrimSpace(keys) == "" {
+		return nil, nil
+	}
+
+	k := &Keyring{keys: make(map[string]cipher.AEAD), active: strings.TrimSpace(activeKeyID)}
+	for _, pair := range strings.Split(keys, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("invalid key %q, expected id:base64 key", pair)
+		}
+		if len(id) > maxKeyIDLength {
+			return nil, fmt.Errorf("key id %s is longer than %d characters", id, maxKeyIDLength)
+		}
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, errors.Wrapf(err, "key %s is not base64", id)
+		}
+		if len(raw) != 32 {
+			return nil, fmt.Errorf("key %s must be 32 bytes, got %d", id, len(raw))
+		}
+		block, err := aes.NewCipher(raw)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[id] = aead
+	}
+
+	if k.active == "" && len(k.keys) == 1 {
+		for id := range k.keys {
+			k.active = id
+		}
+	}
+	if _, ok := k.keys[k.active]; !ok {
+		return nil, fmt.Errorf("active key %q is not configured", k.active)
+	}
+	return k, nil
+}
+
+// Encrypt encrypts value with the active key and returns the key id to store with it. Empty values
+// and a nil keyring keep the value in plaintext with an empty key id.
+func (k *Keyring) Encrypt(value string) (string, string, error) {
+	if k == nil || value == "" {
+		return value, "", nil
+	}
+
+	aead := k.keys[k.active]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(value), []byte(k.active))
+	return base64.StdEncoding.EncodeToString(sealed), k.active, nil
+}
+
+// Decrypt returns the plaintext of a value encrypted with the key keyID, values without a key id
+// are plaintext and returned unchanged
+func (k *Keyring) Decrypt(value, keyID string) (string, error) {
+	if keyID == "" {
+		return value, nil
+	}
+	if k == nil {
+		return "", errors.Wrap(ErrUnknownKey, keyID)
+	}
+	aead, ok := k.keys[keyID]
+	if !ok {
+		return "", errors.Wrap(ErrUnknownKey, keyID)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", ErrMalformed
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(keyID))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to decrypt value")
+	}
+	return string(plain), nil
+}
+
+// Current tells whether a stored value is encrypted with the active key, values that are not
+// need to be re-encrypted. Empty values are always current.
+func (k *Keyring) Current(value, keyID string) bool {
+	if value == "" || k == nil {
+		return true
+	}
+	return keyID == k.active
+}
+
+// ActiveKeyID is the id of the key new values are encrypted with
+func (k *Keyring) ActiveKeyID() string {
+	return k.active
+}
+
+var (
+	defaultKeyring *Keyring
+	mu             sync.RWMutex
+)
+
+// SetDefault sets the keyring used by the model hooks, a nil keyring disables encryption
+func SetDefault(k *Keyring) {
+	mu.Lock()
+	defer mu.Unlock()
+	defaultKeyring = k
+}
+
+// Default returns the keyring set at startup, nil when encryption is disabled
+func Default() *Keyring {
+	mu.RLock()
+	defer mu.RUnlock()
+	return defaultKeyring
+}
+
+// Encrypt encrypts value with the default keyring
+func Encrypt(value string) (string, string, error) {
+	return Default().Encrypt(value)
+}
+
+// Decrypt decrypts value with the default keyring
+func Decrypt(value, keyID string) (string, error) {
+	return Default().Decrypt(value, keyID)
+}
@@ -0,0 +1,105 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), 32)))
+}
+
+func TestKeyring(t *testing.T) {
+	k1, err := NewKeyring("v1:"+testKey('a'), "")
+	require.NoError(t, err)
+
+	encrypted, keyID, err := k1.Encrypt("hunter2")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", keyID)
+	assert.NotContains(t, encrypted, "hunter2")
+	assert.True(t, k1.Current(encrypted, keyID))
+
+	plain, err := k1.Decrypt(encrypted, keyID)
+	require.NoError(t, err)
+	assert.Equal(t, "hunter2", plain)
+
+	t.Run("plaintext passes through", func(t *testing.T) {
+		plain, err := k1.Decrypt("legacy", "")
+		require.NoError(t, err)
+		assert.Equal(t, "legacy", plain)
+		assert.False(t, k1.Current("legacy", ""))
+		assert.True(t, k1.Current("", ""))
+	})
+
+	t.Run("plaintext that looks encrypted", func(t *testing.T) {
+		for _, password := range []string{"enc:x:y", "enc:v1:" + encrypted} {
+			plain, err := k1.Decrypt(password, "")
+			require.NoError(t, err)
+			assert.Equal(t, password, plain)
+
+			stored, keyID, err := k1.Encrypt(password)
+			require.NoError(t, err)
+			assert.Equal(t, "v1", keyID)
+			assert.NotEqual(t, password, stored, "the password is encrypted like any other")
+			plain, err = k1.Decrypt(stored, keyID)
+			require.NoError(t, err)
+			assert.Equal(t, password, plain)
+		}
+	})
+
+	t.Run("rotation keeps old values readable", func(t *testing.T) {
+		k2, err := NewKeyring("v1:"+testKey('a')+",v2:"+testKey('b'), "v2")
+		require.NoError(t, err)
+
+		plain, err := k2.Decrypt(encrypted, keyID)
+		require.NoError(t, err)
+		assert.Equal(t, "hunter2", plain)
+		assert.False(t, k2.Current(encrypted, keyID))
+
+		_, newKeyID, err := k2.Encrypt(plain)
+		require.NoError(t, err)
+		assert.Equal(t, "v2", newKeyID)
+	})
+
+	t.Run("removed key", func(t *testing.T) {
+		k3, err := NewKeyring("v3:"+testKey('c'), "v3")
+		require.NoError(t, err)
+		_, err = k3.Decrypt(encrypted, keyID)
+		assert.ErrorIs(t, err, ErrUnknownKey)
+	})
+
+	t.Run("tampered value", func(t *testing.T) {
+		_, err := k1.Decrypt(encrypted[:len(encrypted)-4]+"AAAA", keyID)
+		assert.Error(t, err)
+	})
+
+	t.Run("no keyring", func(t *testing.T) {
+		var none *Keyring
+		stored, keyID, err := none.Encrypt("enc:x:y")
+		require.NoError(t, err)
+		assert.Equal(t, "enc:x:y", stored)
+		assert.Empty(t, keyID)
+		plain, err := none.Decrypt(stored, keyID)
+		require.NoError(t, err)
+		assert.Equal(t, "enc:x:y", plain)
+	})
+}
+
+func TestNewKeyring(t *testing.T) {
+	k, err := NewKeyring("", "")
+	require.NoError(t, err)
+	assert.Nil(t, k)
+
+	_, err = NewKeyring("v1:"+base64.StdEncoding.EncodeToString([]byte("short")), "")
+	assert.Error(t, err)
+
+	_, err = NewKeyring("v1:"+testKey('a')+",v2:"+testKey('b'), "")
+	assert.Error(t, err, "active key must be set with several keys")
+
+	_, err = NewKeyring("v1:"+testKey('a'), "v2")
+	assert.Error(t, err)
+}
@@ -51,7 +51,10 @@ func NewServer(cfg *config.Config, mailstackDB *gorm.DB, openlineDB *gorm.DB) (*
 	opentracing.SetGlobalTracer(tracer)
 
 	// Initialize repositories
-	repos := repository.InitRepositories(mailstackDB, openlineDB, cfg.R2StorageConfig)
+	repos, err := repository.InitRepositories(mailstackDB, openlineDB, cfg.AttachmentStorageConfig, cfg.R2StorageConfig)
+	if err != nil {
+		return nil, err
+	}
 
 	// Initialize services
 	svcs, err := services.InitServices(cfg.AppConfig.RabbitMQURL, logger, repos, cfg)
@@ -62,6 +65,7 @@ func NewServer(cfg *config.Config, mailstackDB *gorm.DB, openlineDB *gorm.DB) (*
 	// Initialize listeners
 	svcs.EventsService.Subscriber.RegisterListener(listeners.NewSendEmailListener(logger, repos, svcs.EmailService))
 	svcs.EventsService.Subscriber.RegisterListener(listeners.NewReceiveEmailListener(logger, repos, svcs.IMAPProcessor))
+	svcs.EventsService.Subscriber.RegisterListener(listeners.NewEnrichEmailListener(logger, svcs.EmailProcessor))
 
 	// Start Listening on rabbit queues
 	err = svcs.EventsService.Subscriber.ListenQueue(events.QueueSendEmail)
@@ -72,6 +76,10 @@ func NewServer(cfg *config.Config, mailstackDB *gorm.DB, openlineDB *gorm.DB) (*
 	if err != nil {
 		logger.Errorf("Failed to start listening on receive email queue: %v", err)
 	}
+	err = svcs.EventsService.Subscriber.ListenQueue(events.QueueEnrichEmail)
+	if err != nil {
+		logger.Errorf("Failed to start listening on enrich email queue: %v", err)
+	}
 
 	// Initialize Gin
 	gin.SetMode(gin.ReleaseMode)
@@ -213,6 +221,21 @@ func (s *Server) waitForShutdown() error {
 		log.Println("⚠️ IMAP service stop timed out, forcing exit")
 	}
 
+	// Stop consuming and wait for in-flight event handlers
+	log.Println("Shutting down events service...")
+	eventsCtx, eventsCancel := context.WithTimeout(context.Background(), events.DefaultShutdownTimeout)
+	defer eventsCancel()
+	if err := s.services.EventsService.Shutdown(eventsCtx); err != nil {
+		log.Printf("❌ Events service shutdown error: %v", err)
+	} else {
+		log.Println("✅ Events service shut down successfully")
+	}
+
+	// Close pooled SMTP sessions after the event handlers that send have drained
+	log.Println("Closing SMTP sessions...")
+	s.services.EmailService.Close()
+	log.Println("✅ SMTP sessions closed")
+
 	return nil
 }
 
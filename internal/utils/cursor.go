@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	er "github.com/customeros/mailstack/internal/errors"
+)
+
+// Cursor marks the last row of a page in a listing ordered by a time, then by id. The next page
+// starts right after it, so rows inserted while paging neither shift nor repeat rows.
+type Cursor struct {
+	At         *time.Time `json:"t,omitempty"` // nil for rows without a time, which are listed last
+	ID         string     `json:"i"`
+	Descending bool       `json:"d,omitempty"` // order of the listing the cursor was made for
+}
+
+// EncodeCursor returns the opaque token handed to clients for the next page
+func EncodeCursor(cursor Cursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor reads a token made by EncodeCursor, it returns ErrInvalidCursor for anything else
+func DecodeCursor(token string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, er.ErrInvalidCursor
+	}
+	var cursor Cursor
+	if err = json.Unmarshal(data, &cursor); err != nil || cursor.ID == "" {
+		return nil, er.ErrInvalidCursor
+	}
+	return &cursor, nil
+}
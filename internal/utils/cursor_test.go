@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	er "github.com/customeros/mailstack/internal/errors"
+)
+
+func TestCursor(t *testing.T) {
+	at := time.Date(2026, 10, 15, 9, 30, 0, 123456000, time.UTC)
+	for _, cursor := range []Cursor{
+		{At: &at, ID: "thrd_1", Descending: true},
+		{ID: "email_1"},
+	} {
+		decoded, err := DecodeCursor(EncodeCursor(cursor))
+		require.NoError(t, err)
+		assert.Equal(t, cursor, *decoded)
+	}
+
+	for _, token := range []string{"", "not base64!", EncodeCursor(Cursor{}), "bm90IGpzb24"} {
+		_, err := DecodeCursor(token)
+		assert.ErrorIs(t, err, er.ErrInvalidCursor, token)
+	}
+}
@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
+)
+
+// SplitDomain splits a domain into its second-level label and public suffix using the
+// public suffix list, e.g. "example.co.uk" returns "example" and "co.uk".
+// Subdomains are dropped: "mail.example.com" returns "example" and "com".
+func SplitDomain(domain string) (sld string, tld string, err error) {
+	domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+	if domain == "" || !strings.Contains(domain, ".") {
+		return "", "", fmt.Errorf("invalid domain: %q", domain)
+	}
+
+	registrable, err := publicsuffix.EffectiveTLDPlusOne(domain)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid domain %q: %w", domain, err)
+	}
+
+	sld, tld, _ = strings.Cut(registrable, ".")
+	if sld == "" || tld == "" {
+		return "", "", fmt.Errorf("invalid domain: %q", domain)
+	}
+	return sld, tld, nil
+}
+
+// NormalizeDomain lowercases and trims a domain to register and converts internationalized names
+// to punycode, e.g. " Bücher.Example " returns "xn--bcher-kva.example". Only registrable domains
+// are accepted, subdomains are rejected.
+func NormalizeDomain(domain string) (string, error) {
+	trimmed := strings.TrimSuffix(strings.TrimSpace(domain), ".")
+	if trimmed == "" {
+		return "", errors.New("domain is empty")
+	}
+
+	ascii, err := idna.Registration.ToASCII(strings.ToLower(trimmed))
+	if err != nil {
+		return "", fmt.Errorf("invalid domain %q: %w", domain, err)
+	}
+
+	sld, tld, err := SplitDomain(ascii)
+	if err != nil {
+		return "", err
+	}
+	if ascii != sld+"."+tld {
+		return "", fmt.Errorf("invalid domain %q: subdomains can not be registered, use %s", domain, sld+"."+tld)
+	}
+	return ascii, nil
+}
+
+// NormalizeHostname lowercases and trims a host name, e.g. of a Message-ID, and converts
+// internationalized names to punycode. Unlike NormalizeDomain it accepts subdomains, the host has
+// to end in a public suffix.
+func NormalizeHostname(host string) (string, error) {
+	trimmed := strings.TrimSuffix(strings.TrimSpace(host), ".")
+	if trimmed == "" {
+		return "", errors.New("host is empty")
+	}
+
+	ascii, err := idna.Registration.ToASCII(strings.ToLower(trimmed))
+	if err != nil {
+		return "", fmt.Errorf("invalid host %q: %w", host, err)
+	}
+	if _, _, err = SplitDomain(ascii); err != nil {
+		return "", err
+	}
+	return ascii, nil
+}
+
+// ValidateWebsiteURL checks the website is an absolute http(s) URL with a host and returns it trimmed
+func ValidateWebsiteURL(website string) (string, error) {
+	website = strings.TrimSpace(website)
+	if website == "" {
+		return "", errors.New("website is empty")
+	}
+
+	parsed, err := url.Parse(website)
+	if err != nil {
+		return "", fmt.Errorf("invalid website %q: %w", website, err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return "", fmt.Errorf("invalid website %q: must start with http:// or https://", website)
+	}
+	if parsed.User != nil {
+		return "", fmt.Errorf("invalid website %q: credentials are not allowed", website)
+	}
+
+	host := parsed.Hostname()
+	if host == "" {
+		return "", fmt.Errorf("invalid website %q: host is missing", website)
+	}
+	if _, err = idna.Lookup.ToASCII(host); err != nil || !strings.Contains(strings.Trim(host, "."), ".") {
+		return "", fmt.Errorf("invalid website %q: host %q is not a valid domain", website, host)
+	}
+	return website, nil
+}
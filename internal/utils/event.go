@@ -4,6 +4,7 @@ type EventCompletedDetails struct {
 	Create bool
 	Update bool
 	Delete bool
+	Data   interface{}
 }
 
 func NewEventCompletedDetails() *EventCompletedDetails {
@@ -24,3 +25,8 @@ func (ecd *EventCompletedDetails) WithDelete() *EventCompletedDetails {
 	ecd.Delete = true
 	return ecd
 }
+
+func (ecd *EventCompletedDetails) WithData(data interface{}) *EventCompletedDetails {
+	ecd.Data = data
+	return ecd
+}
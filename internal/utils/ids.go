@@ -10,7 +10,8 @@ import (
 
 const alphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
 
-// generateMessageID creates a unique message ID for the email
+// GenerateMessageID creates a unique message ID for the email on domain, the host part of the id.
+// Every send channel uses it so replies thread the same way whichever channel sent the original.
 func GenerateMessageID(domain, metadata string) string {
 	id, err := gonanoid.Generate(alphabet, 12)
 	if err != nil {
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"regexp"
+	"testing"
+)
+
+var messageIDFormat = regexp.MustCompile(`^<\d{16}\.[a-z0-9]{12}(\.[0-9a-f]{8})?@([a-z0-9.-]+)>$`)
+
+func TestGenerateMessageID(t *testing.T) {
+	first := GenerateMessageID("bounce.acme.io", "")
+	match := messageIDFormat.FindStringSubmatch(first)
+	if match == nil {
+		t.Fatalf("GenerateMessageID = %q; not an RFC 5322 msg-id", first)
+	}
+	if match[1] != "" || match[2] != "bounce.acme.io" {
+		t.Errorf("GenerateMessageID = %q; expected no metadata hash on host bounce.acme.io", first)
+	}
+
+	if second := GenerateMessageID("bounce.acme.io", ""); second == first {
+		t.Errorf("GenerateMessageID returned %q twice", first)
+	}
+
+	withMetadata := GenerateMessageID("acme.io", "campaign-42")
+	match = messageIDFormat.FindStringSubmatch(withMetadata)
+	if match == nil || match[1] != ".fb21c4f1" || match[2] != "acme.io" {
+		t.Errorf("GenerateMessageID = %q; expected the metadata hash .fb21c4f1 on host acme.io", withMetadata)
+	}
+}
+
+func TestFormatMessageID(t *testing.T) {
+	tests := map[string]string{
+		"abc@acme.io":     "<abc@acme.io>",
+		" <abc@acme.io> ": "<abc@acme.io>",
+		"":                "",
+		"<>":              "",
+	}
+	for input, expected := range tests {
+		if formatted := FormatMessageID(input); formatted != expected {
+			t.Errorf("FormatMessageID(%q) = %q; expected %q", input, formatted, expected)
+		}
+	}
+
+	// generated ids go in headers as they are and survive being stored without brackets
+	generated := GenerateMessageID("acme.io", "")
+	if formatted := FormatMessageID(NormalizeMessageID(generated)); formatted != generated {
+		t.Errorf("FormatMessageID(NormalizeMessageID(%q)) = %q", generated, formatted)
+	}
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime/quotedprintable"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// DecodeMIMEBody decodes a body part according to its Content-Transfer-Encoding and charset and
+// returns it as UTF-8. Steps that fail leave the content as it was, so nothing is dropped.
+func DecodeMIMEBody(data []byte, transferEncoding, charset string) string {
+	switch strings.ToLower(strings.TrimSpace(transferEncoding)) {
+	case "quoted-printable":
+		if decoded, err := io.ReadAll(quotedprintable.NewReader(bytes.NewReader(data))); err == nil {
+			data = decoded
+		}
+	case "base64":
+		cleaned := strings.Join(strings.Fields(string(data)), "")
+		if decoded, err := base64.StdEncoding.DecodeString(cleaned); err == nil {
+			data = decoded
+		}
+	}
+
+	return DecodeCharset(data, charset)
+}
+
+// DecodeCharset converts text in the given charset to UTF-8. Names are resolved like browsers do,
+// so iso-8859-1 is read as its windows-1252 superset.
+func DecodeCharset(data []byte, charset string) string {
+	charset = strings.ToLower(strings.Trim(strings.TrimSpace(charset), `"`))
+	if charset == "" || charset == "utf-8" || charset == "utf8" || charset == "us-ascii" {
+		return string(data)
+	}
+
+	encoding, err := htmlindex.Get(charset)
+	if err != nil {
+		return string(data)
+	}
+	decoded, err := encoding.NewDecoder().Bytes(data)
+	if err != nil || !utf8.Valid(decoded) {
+		return string(data)
+	}
+	return string(decoded)
+}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeMIMEBody(t *testing.T) {
+	tests := []struct {
+		name             string
+		data             string
+		transferEncoding string
+		charset          string
+		expected         string
+	}{
+		{
+			name:             "quoted-printable latin-1",
+			data:             "Caf=E9 cr=E8me, =E0 bient=F4t=\r\n!",
+			transferEncoding: "quoted-printable",
+			charset:          "ISO-8859-1",
+			expected:         "Café crème, à bientôt!",
+		},
+		{
+			name:             "base64 windows-1252 with line breaks",
+			data:             "k1F1b3RllIAgY2Fm\r\n6Q==",
+			transferEncoding: "base64",
+			charset:          "windows-1252",
+			expected:         "“Quote”€ café",
+		},
+		{
+			name:             "quoted charset and uppercase encoding",
+			data:             "na=EFve",
+			transferEncoding: "Quoted-Printable",
+			charset:          `"iso-8859-1"`,
+			expected:         "naïve",
+		},
+		{
+			name:             "utf-8 passes through",
+			data:             "Grüße",
+			transferEncoding: "8bit",
+			charset:          "utf-8",
+			expected:         "Grüße",
+		},
+		{
+			name:             "unknown charset keeps content",
+			data:             "plain",
+			transferEncoding: "7bit",
+			charset:          "x-unknown",
+			expected:         "plain",
+		},
+		{
+			name:             "invalid base64 keeps content",
+			data:             "not base64!",
+			transferEncoding: "base64",
+			expected:         "not base64!",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, DecodeMIMEBody([]byte(tt.data), tt.transferEncoding, tt.charset))
+		})
+	}
+}
@@ -1,8 +1,10 @@
 package utils
 
 import (
+	"bytes"
 	"crypto/rand"
 	"math/big"
+	"net/mail"
 	"regexp"
 	"strings"
 )
@@ -32,6 +34,29 @@ func NormalizeMessageID(messageID string) string {
 	return messageID
 }
 
+// FormatMessageID wraps a message ID in angle brackets as it goes in Message-ID, In-Reply-To and
+// References headers, inbound IDs are stored without them
+func FormatMessageID(messageID string) string {
+	messageID = strings.Trim(strings.TrimSpace(messageID), "<>")
+	if messageID == "" {
+		return ""
+	}
+	return "<" + messageID + ">"
+}
+
+// RawHasMessageID reports whether the Message-ID header of an RFC 822 source is messageID. An
+// empty messageID matches any source, a source without readable headers matches none.
+func RawHasMessageID(raw []byte, messageID string) bool {
+	if messageID == "" {
+		return true
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return false
+	}
+	return NormalizeMessageID(msg.Header.Get("Message-ID")) == NormalizeMessageID(messageID)
+}
+
 func GenerateLowerAlpha(length int) string {
 	if length < 1 {
 		return ""
@@ -12,6 +12,7 @@ import (
 	"github.com/customeros/mailstack/internal/cron"
 	"github.com/customeros/mailstack/internal/database"
 	"github.com/customeros/mailstack/internal/repository"
+	"github.com/customeros/mailstack/internal/secrets"
 	"github.com/customeros/mailstack/internal/server"
 )
 
@@ -32,6 +33,15 @@ func main() {
 		log.Fatalf("config is empty")
 	}
 
+	keyring, err := secrets.NewKeyring(cfg.CredentialsEncryption.Keys, cfg.CredentialsEncryption.ActiveKeyID)
+	if err != nil {
+		log.Fatalf("Credentials encryption setup failed: %v", err)
+	}
+	if keyring == nil {
+		log.Println("CREDENTIALS_ENCRYPTION_KEYS not set, mailbox passwords are stored in plaintext")
+	}
+	secrets.SetDefault(keyring)
+
 	// Setup the databases
 	openlineDB, err := database.InitOpenlineDatabase(&database.DatabaseConfig{
 		DBName:          cfg.OpenlineDatabaseConfig.DBName,
@@ -105,6 +115,7 @@ func main() {
 			k8sClient,
 			srv.Services().DomainService,
 			srv.Services().MailboxServiceOld,
+			srv.Services().EmailService,
 			srv.Repositories(),
 		)
 
@@ -0,0 +1,53 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/opentracing/opentracing-go"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/logger"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/repository"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+type auditService struct {
+	log          logger.Logger
+	repositories *repository.Repositories
+}
+
+func NewAuditService(log logger.Logger, repos *repository.Repositories) interfaces.AuditService {
+	return &auditService{
+		log:          log,
+		repositories: repos,
+	}
+}
+
+// Record stores an audit log entry with the tenant, actor and request of the context. The
+// mutation is done already when it is recorded, so a failure is logged rather than returned.
+func (s *auditService) Record(ctx context.Context, entry interfaces.AuditEntry) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "auditService.Record")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.SetTag("action", entry.Action)
+	span.SetTag("target", entry.TargetID)
+
+	customContext := utils.GetContext(ctx)
+	err := s.repositories.AuditLogRepository.Create(ctx, &models.AuditLog{
+		Tenant:     customContext.Tenant,
+		ActorID:    customContext.UserId,
+		ActorEmail: customContext.UserEmail,
+		RequestID:  customContext.RequestID,
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		TargetID:   entry.TargetID,
+		Before:     entry.Before,
+		After:      entry.After,
+	})
+	if err != nil {
+		tracing.TraceErr(span, err)
+		s.log.Errorf("Failed to record audit log %s of %s %s: %v", entry.Action, entry.TargetType, entry.TargetID, err)
+	}
+}
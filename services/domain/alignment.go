@@ -0,0 +1,259 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/mail"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/opentracing/opentracing-go"
+	tracingLog "github.com/opentracing/opentracing-go/log"
+	"github.com/pkg/errors"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/enum"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+	"github.com/customeros/mailstack/services/mailauth"
+)
+
+// CheckMailAuthAlignment composes a message from the mailbox, without sending it, and checks
+// its envelope and From domains against public DNS: SPF must authorize the sending host, the
+// DKIM selector must publish the key we sign with, and both must align with the From domain
+// the way its DMARC policy requires
+func (s *domainService) CheckMailAuthAlignment(ctx context.Context, mailbox *models.Mailbox) (*interfaces.MailAuthAlignmentReport, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "DomainService.CheckMailAuthAlignment")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.SetTag("mailbox_id", mailbox.ID)
+
+	fromDomain := strings.ToLower(utils.ExtractDomainFromEmail(mailbox.EmailAddress))
+	domainRecord, err := s.postgres.DomainRepository.GetDomain(ctx, mailbox.Tenant, fromDomain)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	report, err := s.mailAuthAlignment(ctx, mailbox, domainRecord)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	span.LogFields(
+		tracingLog.String("spf", string(report.SPF.Status)),
+		tracingLog.String("dkim", string(report.DKIM.Status)),
+		tracingLog.String("dmarc", string(report.DMARC.Status)),
+		tracingLog.Bool("passesDmarc", report.PassesDMARC),
+	)
+	return report, nil
+}
+
+// mailAuthAlignment builds the report. domainRecord is the stored domain for domains we host,
+// nil for mailboxes of other providers, whose DKIM key we do not know.
+func (s *domainService) mailAuthAlignment(ctx context.Context, mailbox *models.Mailbox, domainRecord *models.MailStackDomain) (*interfaces.MailAuthAlignmentReport, error) {
+	headers := alignmentProbe(mailbox).BuildHeaders()
+	from, err := mail.ParseAddress(headers["From"])
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid mailbox address")
+	}
+
+	report := &interfaces.MailAuthAlignmentReport{
+		MailboxID:    mailbox.ID,
+		HeaderFrom:   from.Address,
+		EnvelopeFrom: strings.Trim(headers["Return-Path"], "<>"),
+		SendingHost:  mailbox.SmtpServer,
+		Warnings:     []string{},
+	}
+	fromDomain := strings.ToLower(utils.ExtractDomainFromEmail(report.HeaderFrom))
+	envelopeDomain := strings.ToLower(utils.ExtractDomainFromEmail(report.EnvelopeFrom))
+
+	dmarc, strictSPF, strictDKIM, err := s.checkDMARC(ctx, fromDomain)
+	if err != nil {
+		return nil, err
+	}
+	report.DMARC = dmarc
+
+	report.SPF, err = s.checkSPF(ctx, envelopeDomain, report.EnvelopeFrom, mailbox.SmtpServer, domainRecord)
+	if err != nil {
+		return nil, err
+	}
+	report.SPF.Aligned = report.SPF.Status == enum.MailAuthPass && mailauth.DomainsAligned(envelopeDomain, fromDomain, strictSPF)
+
+	report.DKIM, err = s.checkDKIM(ctx, fromDomain, domainRecord)
+	if err != nil {
+		return nil, err
+	}
+	report.DKIM.Aligned = report.DKIM.Status == enum.MailAuthPass && mailauth.DomainsAligned(report.DKIM.Domain, fromDomain, strictDKIM)
+
+	report.PassesDMARC = report.SPF.Aligned || report.DKIM.Aligned
+	if report.DMARC.Status != enum.MailAuthMissing {
+		report.DMARC.Status = enum.MailAuthFail
+		if report.PassesDMARC {
+			report.DMARC.Status = enum.MailAuthPass
+		}
+	}
+
+	report.Warnings = alignmentWarnings(report)
+	return report, nil
+}
+
+// alignmentProbe is the message the check reasons about, composed like a send from the mailbox
+func alignmentProbe(mailbox *models.Mailbox) *models.Email {
+	return &models.Email{
+		MailboxID:   mailbox.ID,
+		FromAddress: mailbox.EmailAddress,
+		ToAddresses: pq.StringArray{mailbox.EmailAddress},
+		Subject:     "Mail authentication check",
+	}
+}
+
+// checkDMARC reads the DMARC record of the From domain and the alignment modes it asks for,
+// relaxed unless aspf or adkim say otherwise
+func (s *domainService) checkDMARC(ctx context.Context, domain string) (interfaces.MailAuthCheck, bool, bool, error) {
+	check := interfaces.MailAuthCheck{Status: enum.MailAuthMissing, Domain: domain}
+
+	record, err := s.lookupTaggedTXT(ctx, "_dmarc."+domain, "v=dmarc1")
+	if err != nil || record == "" {
+		return check, false, false, err
+	}
+	check.Status = enum.MailAuthPass
+	check.Record = record
+
+	tags := mailauth.ParseTagList(record)
+	if policy := tags["p"]; policy != "" {
+		check.Detail = "policy " + policy
+	}
+	return check, tags["aspf"] == "s", tags["adkim"] == "s", nil
+}
+
+// checkSPF evaluates the SPF record of the envelope domain for the addresses of the sending
+// host. For domains we host, reaching the include of our mail provider counts as authorized too,
+// the provider relays from other hosts than the one clients submit to.
+func (s *domainService) checkSPF(ctx context.Context, domain, sender, sendingHost string, domainRecord *models.MailStackDomain) (interfaces.MailAuthCheck, error) {
+	check := interfaces.MailAuthCheck{Status: enum.MailAuthMissing, Domain: domain}
+
+	record, err := s.lookupTaggedTXT(ctx, domain, "v=spf1")
+	if err != nil || record == "" {
+		return check, err
+	}
+	check.Record = record
+
+	var ips []net.IP
+	if sendingHost != "" {
+		addrs, err := s.resolver.LookupHost(ctx, sendingHost)
+		if err != nil && !mailauth.IsNotFound(err) {
+			return check, fmt.Errorf("host lookup for %s failed: %w", sendingHost, err)
+		}
+		for _, addr := range addrs {
+			if ip := net.ParseIP(addr); ip != nil {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	if len(ips) == 0 {
+		// without an address only the provider include or +all can authorize
+		ips = []net.IP{nil}
+	}
+
+	providerInclude := ""
+	if domainRecord != nil {
+		providerInclude = mailAuthSPFInclude(s.cfg)
+	}
+
+	// authorized when one of the addresses of the sending host passes
+	var result enum.AuthResult
+	var detail string
+	for _, ip := range ips {
+		result, detail = mailauth.EvaluateSPF(ctx, s.resolver, ip, sender, domain, providerInclude)
+		if result == enum.AuthResultPass || result == enum.AuthResultTempError {
+			break
+		}
+	}
+
+	switch result {
+	case enum.AuthResultPass:
+		check.Status = enum.MailAuthPass
+	case enum.AuthResultTempError:
+		return check, fmt.Errorf("spf evaluation of %s failed: %s", domain, detail)
+	case enum.AuthResultPermError:
+		check.Status = enum.MailAuthFail
+		check.Detail = detail
+	default:
+		check.Status = enum.MailAuthFail
+		check.Detail = fmt.Sprintf("%s is not authorized by the spf record", sendingHost)
+	}
+	return check, nil
+}
+
+// checkDKIM compares the key published under our selector with the stored one. Mailboxes of
+// other providers are signed with selectors we do not know, those stay unverified.
+func (s *domainService) checkDKIM(ctx context.Context, domain string, domainRecord *models.MailStackDomain) (interfaces.MailAuthCheck, error) {
+	check := interfaces.MailAuthCheck{Status: enum.MailAuthUnverified, Domain: domain}
+	if domainRecord == nil || domainRecord.DkimPublic == "" {
+		check.Detail = "dkim is signed by the mailbox provider, its key is not known"
+		return check, nil
+	}
+
+	name := fmt.Sprintf("%s._domainkey.%s", dkimSelector, domain)
+	record, err := s.lookupTaggedTXT(ctx, name, "v=dkim1")
+	if err != nil {
+		return check, err
+	}
+	if record == "" {
+		check.Status = enum.MailAuthMissing
+		check.Detail = "selector " + name + " does not resolve"
+		return check, nil
+	}
+	check.Record = record
+
+	published := strings.Join(strings.Fields(mailauth.ParseTagList(record)["p"]), "")
+	stored := strings.Join(strings.Fields(mailauth.ParseTagList(normalizeTXT(domainRecord.DkimPublic))["p"]), "")
+	check.Status = enum.MailAuthFail
+	if published != "" && published == stored {
+		check.Status = enum.MailAuthPass
+	} else {
+		check.Detail = "published key does not match the signing key"
+	}
+	return check, nil
+}
+
+// lookupTaggedTXT returns the TXT record of name with the version tag, empty when there is none
+func (s *domainService) lookupTaggedTXT(ctx context.Context, name, tag string) (string, error) {
+	values, err := s.resolver.LookupTXT(ctx, name)
+	if err != nil && !mailauth.IsNotFound(err) {
+		return "", fmt.Errorf("txt lookup for %s failed: %w", name, err)
+	}
+	for _, value := range values {
+		if txtVersionTag(value) == tag {
+			return normalizeTXT(value), nil
+		}
+	}
+	return "", nil
+}
+
+func alignmentWarnings(report *interfaces.MailAuthAlignmentReport) []string {
+	warnings := []string{}
+	switch report.SPF.Status {
+	case enum.MailAuthMissing:
+		warnings = append(warnings, fmt.Sprintf("%s publishes no SPF record", report.SPF.Domain))
+	case enum.MailAuthFail:
+		warnings = append(warnings, "SPF fails: "+report.SPF.Detail)
+	}
+	switch report.DKIM.Status {
+	case enum.MailAuthMissing, enum.MailAuthFail:
+		warnings = append(warnings, "DKIM fails: "+report.DKIM.Detail)
+	}
+	if report.DMARC.Status == enum.MailAuthMissing {
+		warnings = append(warnings, fmt.Sprintf("%s publishes no DMARC record, receivers apply their own policy", report.DMARC.Domain))
+	} else if report.DMARC.Detail == "policy none" {
+		warnings = append(warnings, "DMARC policy is none, failing mail is still delivered")
+	}
+	if !report.PassesDMARC {
+		warnings = append(warnings, "neither SPF nor DKIM passes aligned with the From domain, mail will fail DMARC")
+	}
+	return warnings
+}
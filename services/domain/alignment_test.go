@@ -0,0 +1,90 @@
+package domain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/internal/enum"
+	"github.com/customeros/mailstack/internal/models"
+)
+
+func TestMailAuthAlignment(t *testing.T) {
+	mailbox := &models.Mailbox{ID: "mb1", EmailAddress: "jane@acme.io", SmtpServer: "smtp.acme.io"}
+
+	t.Run("hosted domain passes through the provider include and dkim", func(t *testing.T) {
+		s := &domainService{resolver: &fakeResolver{
+			txt: map[string][]string{
+				"acme.io":                 {"v=spf1 include:_spf.hostedemail.com -all"},
+				"dkim._domainkey.acme.io": {`"v=DKIM1; k=rsa; " "p=KEY"`},
+				"_dmarc.acme.io":          {"v=DMARC1; p=reject; aspf=s; adkim=s"},
+			},
+		}}
+
+		report, err := s.mailAuthAlignment(context.Background(), mailbox, &models.MailStackDomain{DkimPublic: "v=DKIM1; k=rsa; p=KEY"})
+		require.NoError(t, err)
+		assert.Equal(t, "jane@acme.io", report.EnvelopeFrom)
+		assert.Equal(t, enum.MailAuthPass, report.SPF.Status)
+		assert.True(t, report.SPF.Aligned)
+		assert.Equal(t, enum.MailAuthPass, report.DKIM.Status)
+		assert.True(t, report.DKIM.Aligned)
+		assert.Equal(t, enum.MailAuthPass, report.DMARC.Status)
+		assert.True(t, report.PassesDMARC)
+		assert.Empty(t, report.Warnings)
+	})
+
+	t.Run("external domain evaluated against the sending host", func(t *testing.T) {
+		s := &domainService{resolver: &fakeResolver{
+			txt: map[string][]string{
+				"acme.io":      {"v=spf1 ip4:192.0.2.0/24 include:spf.other.io ~all"},
+				"spf.other.io": {"v=spf1 a:relay.other.io -all"},
+			},
+			hosts: map[string][]string{
+				"smtp.acme.io":   {"198.51.100.7"},
+				"relay.other.io": {"198.51.100.7"},
+			},
+		}}
+
+		report, err := s.mailAuthAlignment(context.Background(), mailbox, nil)
+		require.NoError(t, err)
+		assert.Equal(t, enum.MailAuthPass, report.SPF.Status)
+		assert.Equal(t, enum.MailAuthUnverified, report.DKIM.Status)
+		assert.Equal(t, enum.MailAuthMissing, report.DMARC.Status)
+		assert.True(t, report.PassesDMARC)
+		assert.Len(t, report.Warnings, 1)
+	})
+
+	t.Run("sending host not authorized and dkim key rotated", func(t *testing.T) {
+		s := &domainService{resolver: &fakeResolver{
+			txt: map[string][]string{
+				"acme.io":                 {"v=spf1 ip4:192.0.2.10 -all"},
+				"dkim._domainkey.acme.io": {"v=DKIM1; k=rsa; p=OLD"},
+				"_dmarc.acme.io":          {"v=DMARC1; p=none"},
+			},
+			hosts: map[string][]string{"smtp.acme.io": {"198.51.100.7"}},
+		}}
+
+		report, err := s.mailAuthAlignment(context.Background(), mailbox, &models.MailStackDomain{DkimPublic: "v=DKIM1; k=rsa; p=NEW"})
+		require.NoError(t, err)
+		assert.Equal(t, enum.MailAuthFail, report.SPF.Status)
+		assert.Equal(t, enum.MailAuthFail, report.DKIM.Status)
+		assert.Equal(t, enum.MailAuthFail, report.DMARC.Status)
+		assert.False(t, report.PassesDMARC)
+		assert.Len(t, report.Warnings, 4)
+	})
+}
+
+func TestSPFLookupLimit(t *testing.T) {
+	txt := map[string][]string{}
+	for _, name := range []string{"a.io", "b.io"} {
+		txt[name] = []string{"v=spf1 include:a.io include:b.io -all"}
+	}
+	s := &domainService{resolver: &fakeResolver{txt: txt, hosts: map[string][]string{"smtp.a.io": {"192.0.2.1"}}}}
+
+	check, err := s.checkSPF(context.Background(), "a.io", "jane@a.io", "smtp.a.io", nil)
+	require.NoError(t, err)
+	assert.Equal(t, enum.MailAuthFail, check.Status)
+	assert.Contains(t, check.Detail, "exceeds 10 dns lookups")
+}
@@ -0,0 +1,155 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	tracingLog "github.com/opentracing/opentracing-go/log"
+
+	"github.com/customeros/mailstack/dto"
+	"github.com/customeros/mailstack/internal/enum"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+	"github.com/customeros/mailstack/services/mailauth"
+)
+
+const (
+	defaultDNSBLTimeout  = 5 * time.Second
+	blocklistListingPct  = 25
+	maxConcurrentDNSBLQs = 16
+)
+
+// checkBlocklists queries the configured DNSBLs for the domain and the sending IPs
+// concurrently. Returns the sorted listings as "<zone>:<ip or domain>".
+func (s *domainService) checkBlocklists(ctx context.Context, domain string) []string {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "DomainService.checkBlocklists")
+	defer span.Finish()
+	span.LogKV("domain", domain)
+
+	if s.cfg == nil {
+		return nil
+	}
+
+	timeout := defaultDNSBLTimeout
+	if s.cfg.DNSBLTimeoutSeconds > 0 {
+		timeout = time.Duration(s.cfg.DNSBLTimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type query struct {
+		name    string
+		listing string
+	}
+	var queries []query
+	for _, zone := range s.cfg.DomainBlocklists {
+		zone = strings.Trim(strings.TrimSpace(zone), ".")
+		if zone != "" {
+			queries = append(queries, query{name: domain + "." + zone, listing: zone + ":" + domain})
+		}
+	}
+	for _, ip := range s.cfg.SendingIPs {
+		reversed := reverseIPv4(ip)
+		if reversed == "" {
+			continue
+		}
+		for _, zone := range s.cfg.IPBlocklists {
+			zone = strings.Trim(strings.TrimSpace(zone), ".")
+			if zone != "" {
+				queries = append(queries, query{name: reversed + "." + zone, listing: zone + ":" + strings.TrimSpace(ip)})
+			}
+		}
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		listings []string
+		sem      = make(chan struct{}, maxConcurrentDNSBLQs)
+	)
+	for _, q := range queries {
+		wg.Add(1)
+		go func(q query) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			addresses, err := s.resolver.LookupHost(ctx, q.name)
+			if err != nil {
+				// NXDOMAIN means not listed, timeouts are treated the same way
+				if !mailauth.IsNotFound(err) {
+					span.LogFields(tracingLog.String("dnsbl.error", fmt.Sprintf("%s: %v", q.name, err)))
+				}
+				return
+			}
+			if isDNSBLListing(addresses) {
+				mu.Lock()
+				listings = append(listings, q.listing)
+				mu.Unlock()
+			}
+		}(q)
+	}
+	wg.Wait()
+
+	sort.Strings(listings)
+	span.LogFields(tracingLog.Int("queries", len(queries)), tracingLog.Object("listings", listings))
+	return listings
+}
+
+// notifyNewListings publishes an event when listings appear that the previous check did not report
+func (s *domainService) notifyNewListings(ctx context.Context, tenant, domain string, listings, previous []string) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "DomainService.notifyNewListings")
+	defer span.Finish()
+	tracing.TagTenant(span, tenant)
+
+	var newListings []string
+	for _, listing := range listings {
+		if !utils.IsStringInSlice(listing, previous) {
+			newListings = append(newListings, listing)
+		}
+	}
+	if len(newListings) == 0 || s.events == nil {
+		return
+	}
+
+	ctx = utils.WithTenantContext(ctx, tenant)
+	err := s.events.Publisher.PublishFanoutEvent(ctx, domain, enum.DOMAIN, dto.DomainBlocklisted{
+		Domain:      domain,
+		NewListings: newListings,
+		AllListings: listings,
+	})
+	if err != nil {
+		tracing.TraceErr(span, err)
+	}
+}
+
+// isDNSBLListing reports whether a DNSBL answer is a listing. Answers in 127.0.0.0/8 are
+// listings, except 127.255.255.0/24 which Spamhaus uses for refused or rate limited queries.
+func isDNSBLListing(addresses []string) bool {
+	for _, address := range addresses {
+		ip := net.ParseIP(address).To4()
+		if ip == nil || ip[0] != 127 {
+			continue
+		}
+		if ip[1] == 255 && ip[2] == 255 {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// reverseIPv4 returns the octets of an IPv4 address in reverse order, as DNSBLs expect
+func reverseIPv4(value string) string {
+	ip := net.ParseIP(strings.TrimSpace(value)).To4()
+	if ip == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d.%d.%d.%d", ip[3], ip[2], ip[1], ip[0])
+}
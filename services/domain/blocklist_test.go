@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/customeros/mailstack/internal/config"
+)
+
+func TestCheckBlocklists(t *testing.T) {
+	s := &domainService{
+		cfg: &config.DomainConfig{
+			SendingIPs:       []string{"192.0.2.10", "not-an-ip"},
+			IPBlocklists:     []string{"zen.spamhaus.org", "b.barracudacentral.org"},
+			DomainBlocklists: []string{"dbl.spamhaus.org"},
+		},
+		resolver: &fakeResolver{hosts: map[string][]string{
+			"10.2.0.192.b.barracudacentral.org": {"127.0.0.2"},
+			// spamhaus refusing queries from public resolvers is not a listing
+			"10.2.0.192.zen.spamhaus.org":  {"127.255.255.254"},
+			"example.com.dbl.spamhaus.org": {"127.0.1.2"},
+		}},
+	}
+
+	listings := s.checkBlocklists(context.Background(), "example.com")
+
+	assert.Equal(t, []string{"b.barracudacentral.org:192.0.2.10", "dbl.spamhaus.org:example.com"}, listings)
+}
+
+func TestReverseIPv4(t *testing.T) {
+	assert.Equal(t, "4.3.2.1", reverseIPv4("1.2.3.4"))
+	assert.Equal(t, "", reverseIPv4("2001:db8::1"))
+	assert.Equal(t, "", reverseIPv4("example.com"))
+}
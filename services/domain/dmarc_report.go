@@ -0,0 +1,325 @@
+package domain
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	tracingLog "github.com/opentracing/opentracing-go/log"
+	"github.com/pkg/errors"
+
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+)
+
+// limits applied while decompressing, aggregate reports are small XML documents
+const (
+	maxDMARCReportSize  = 50 << 20
+	maxDMARCReportFiles = 20
+)
+
+// dmarcFeedback is the RFC 7489 aggregate report XML
+type dmarcFeedback struct {
+	XMLName        xml.Name `xml:"feedback"`
+	ReportMetadata struct {
+		OrgName   string `xml:"org_name"`
+		Email     string `xml:"email"`
+		ReportID  string `xml:"report_id"`
+		DateRange struct {
+			Begin int64 `xml:"begin"`
+			End   int64 `xml:"end"`
+		} `xml:"date_range"`
+	} `xml:"report_metadata"`
+	PolicyPublished dmarcPolicy `xml:"policy_published"`
+	Records         []struct {
+		Row struct {
+			SourceIP        string `xml:"source_ip"`
+			Count           int    `xml:"count"`
+			PolicyEvaluated struct {
+				Disposition string `xml:"disposition"`
+				DKIM        string `xml:"dkim"`
+				SPF         string `xml:"spf"`
+			} `xml:"policy_evaluated"`
+		} `xml:"row"`
+		Identifiers struct {
+			HeaderFrom string `xml:"header_from"`
+		} `xml:"identifiers"`
+	} `xml:"record"`
+}
+
+type dmarcPolicy struct {
+	Domain string `xml:"domain" json:"domain"`
+	ADKIM  string `xml:"adkim" json:"adkim"`
+	ASPF   string `xml:"aspf" json:"aspf"`
+	P      string `xml:"p" json:"p"`
+	SP     string `xml:"sp" json:"sp"`
+	PCT    string `xml:"pct" json:"pct"`
+}
+
+// dmarcAggregateReport is the parsed content of one aggregate report
+type dmarcAggregateReport struct {
+	ReportingOrg string              `json:"reportingOrg"`
+	ReportID     string              `json:"reportId"`
+	Domain       string              `json:"domain"`
+	Start        time.Time           `json:"start"`
+	End          time.Time           `json:"end"`
+	Policy       dmarcPolicy         `json:"policy"`
+	MessageCount int                 `json:"messageCount"`
+	SPFAligned   int                 `json:"spfAligned"`
+	DKIMAligned  int                 `json:"dkimAligned"`
+	DMARCPass    int                 `json:"dmarcPass"`
+	Sources      []*dmarcSourceStats `json:"sources"`
+}
+
+// dmarcSourceStats holds the results of one sending IP. A message passes DMARC when
+// either SPF or DKIM passed with alignment.
+type dmarcSourceStats struct {
+	SourceIP     string         `json:"sourceIp"`
+	HeaderFrom   string         `json:"headerFrom"`
+	MessageCount int            `json:"messageCount"`
+	SPFAligned   int            `json:"spfAligned"`
+	DKIMAligned  int            `json:"dkimAligned"`
+	DMARCPass    int            `json:"dmarcPass"`
+	DMARCFail    int            `json:"dmarcFail"`
+	Dispositions map[string]int `json:"dispositions"`
+}
+
+// ProcessDMARCReport parses an aggregate report attachment (xml, gzip or zip) and stores one
+// DMARCMonitoring row per report. Reports for unknown domains and reports already stored
+// are skipped. Returns the number of rows stored.
+func (s *domainService) ProcessDMARCReport(ctx context.Context, data []byte, provider string) (int, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "DomainService.ProcessDMARCReport")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.LogKV("provider", provider, "size", len(data))
+
+	reports, err := parseDMARCReports(data)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return 0, err
+	}
+
+	stored := 0
+	for _, report := range reports {
+		// reports of a zip can come from different organizations
+		reportProvider := provider
+		if reportProvider == "" {
+			reportProvider = report.ReportingOrg
+		}
+
+		tenant, err := s.GetTenantForMailstackDomain(ctx, report.Domain)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return stored, err
+		}
+		if tenant == "" {
+			span.LogFields(tracingLog.String("skipped.domain", report.Domain))
+			continue
+		}
+
+		exists, err := s.postgres.DomainRepository.DMARCReportExists(ctx, report.ReportingOrg, report.ReportID)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return stored, err
+		}
+		if exists {
+			span.LogFields(tracingLog.String("skipped.duplicate", report.ReportID))
+			continue
+		}
+
+		dbReport, err := report.toModel(tenant, reportProvider)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return stored, err
+		}
+		err = s.postgres.DomainRepository.CreateDMARCReport(ctx, tenant, dbReport)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return stored, err
+		}
+		stored++
+	}
+
+	span.LogFields(tracingLog.Int("result.stored", stored))
+	return stored, nil
+}
+
+func (r *dmarcAggregateReport) toModel(tenant, provider string) (*models.DMARCMonitoring, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal dmarc report")
+	}
+
+	return &models.DMARCMonitoring{
+		Tenant:        tenant,
+		EmailProvider: provider,
+		ReportingOrg:  r.ReportingOrg,
+		ReportID:      r.ReportID,
+		Domain:        r.Domain,
+		ReportStart:   r.Start,
+		ReportEnd:     r.End,
+		MessageCount:  r.MessageCount,
+		SPFPass:       r.SPFAligned,
+		DKIMPass:      r.DKIMAligned,
+		DMARCPass:     r.DMARCPass,
+		Data:          string(data),
+	}, nil
+}
+
+// parseDMARCReports detects the container format and parses every report inside it
+func parseDMARCReports(data []byte) ([]*dmarcAggregateReport, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		gzReader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open gzip report: %w", err)
+		}
+		defer gzReader.Close()
+
+		content, err := readLimited(gzReader)
+		if err != nil {
+			return nil, err
+		}
+		report, err := parseDMARCFeedback(content)
+		if err != nil {
+			return nil, err
+		}
+		return []*dmarcAggregateReport{report}, nil
+
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		zipReader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open zip report: %w", err)
+		}
+		if len(zipReader.File) > maxDMARCReportFiles {
+			return nil, fmt.Errorf("zip report contains %d files", len(zipReader.File))
+		}
+
+		var reports []*dmarcAggregateReport
+		for _, file := range zipReader.File {
+			if file.FileInfo().IsDir() {
+				continue
+			}
+			rc, err := file.Open()
+			if err != nil {
+				return nil, fmt.Errorf("failed to open %s: %w", file.Name, err)
+			}
+			content, err := readLimited(rc)
+			rc.Close()
+			if err != nil {
+				return nil, err
+			}
+			report, err := parseDMARCFeedback(content)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", file.Name, err)
+			}
+			reports = append(reports, report)
+		}
+		return reports, nil
+
+	default:
+		report, err := parseDMARCFeedback(data)
+		if err != nil {
+			return nil, err
+		}
+		return []*dmarcAggregateReport{report}, nil
+	}
+}
+
+// parseDMARCFeedback parses the report XML and aggregates the records per source IP
+func parseDMARCFeedback(content []byte) (*dmarcAggregateReport, error) {
+	var feedback dmarcFeedback
+	if err := xml.Unmarshal(content, &feedback); err != nil {
+		return nil, fmt.Errorf("failed to decode dmarc report: %w", err)
+	}
+
+	domain := strings.ToLower(strings.TrimSpace(feedback.PolicyPublished.Domain))
+	if domain == "" {
+		return nil, errors.New("dmarc report has no policy domain")
+	}
+
+	report := &dmarcAggregateReport{
+		ReportingOrg: strings.TrimSpace(feedback.ReportMetadata.OrgName),
+		ReportID:     strings.TrimSpace(feedback.ReportMetadata.ReportID),
+		Domain:       domain,
+		Start:        time.Unix(feedback.ReportMetadata.DateRange.Begin, 0).UTC(),
+		End:          time.Unix(feedback.ReportMetadata.DateRange.End, 0).UTC(),
+		Policy:       feedback.PolicyPublished,
+	}
+
+	sources := map[string]*dmarcSourceStats{}
+	for _, record := range feedback.Records {
+		row := record.Row
+		if row.Count <= 0 {
+			continue
+		}
+
+		source, ok := sources[row.SourceIP]
+		if !ok {
+			source = &dmarcSourceStats{
+				SourceIP:     row.SourceIP,
+				HeaderFrom:   strings.ToLower(record.Identifiers.HeaderFrom),
+				Dispositions: map[string]int{},
+			}
+			sources[row.SourceIP] = source
+		}
+
+		// policy_evaluated carries the aligned results, auth_results the raw ones
+		spfAligned := strings.EqualFold(row.PolicyEvaluated.SPF, "pass")
+		dkimAligned := strings.EqualFold(row.PolicyEvaluated.DKIM, "pass")
+
+		source.MessageCount += row.Count
+		if spfAligned {
+			source.SPFAligned += row.Count
+		}
+		if dkimAligned {
+			source.DKIMAligned += row.Count
+		}
+		if spfAligned || dkimAligned {
+			source.DMARCPass += row.Count
+		} else {
+			source.DMARCFail += row.Count
+		}
+		disposition := strings.ToLower(row.PolicyEvaluated.Disposition)
+		if disposition == "" {
+			disposition = "none"
+		}
+		source.Dispositions[disposition] += row.Count
+	}
+
+	for _, source := range sources {
+		report.MessageCount += source.MessageCount
+		report.SPFAligned += source.SPFAligned
+		report.DKIMAligned += source.DKIMAligned
+		report.DMARCPass += source.DMARCPass
+		report.Sources = append(report.Sources, source)
+	}
+	sort.Slice(report.Sources, func(i, j int) bool {
+		if report.Sources[i].MessageCount != report.Sources[j].MessageCount {
+			return report.Sources[i].MessageCount > report.Sources[j].MessageCount
+		}
+		return report.Sources[i].SourceIP < report.Sources[j].SourceIP
+	})
+
+	return report, nil
+}
+
+func readLimited(reader io.Reader) ([]byte, error) {
+	content, err := io.ReadAll(io.LimitReader(reader, maxDMARCReportSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dmarc report: %w", err)
+	}
+	if len(content) > maxDMARCReportSize {
+		return nil, errors.New("dmarc report exceeds maximum size")
+	}
+	return content, nil
+}
@@ -0,0 +1,100 @@
+package domain
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleDMARCReport = `<?xml version="1.0" encoding="UTF-8" ?>
+<feedback>
+  <report_metadata>
+    <org_name>google.com</org_name>
+    <email>noreply-dmarc-support@google.com</email>
+    <report_id>1234567890</report_id>
+    <date_range><begin>1709251200</begin><end>1709337599</end></date_range>
+  </report_metadata>
+  <policy_published>
+    <domain>Example.com</domain><adkim>s</adkim><aspf>s</aspf><p>reject</p><sp>reject</sp><pct>100</pct>
+  </policy_published>
+  <record>
+    <row><source_ip>192.0.2.10</source_ip><count>5</count>
+      <policy_evaluated><disposition>none</disposition><dkim>pass</dkim><spf>pass</spf></policy_evaluated>
+    </row>
+    <identifiers><header_from>example.com</header_from></identifiers>
+  </record>
+  <record>
+    <row><source_ip>192.0.2.10</source_ip><count>2</count>
+      <policy_evaluated><disposition>none</disposition><dkim>pass</dkim><spf>fail</spf></policy_evaluated>
+    </row>
+    <identifiers><header_from>example.com</header_from></identifiers>
+  </record>
+  <record>
+    <row><source_ip>203.0.113.7</source_ip><count>3</count>
+      <policy_evaluated><disposition>reject</disposition><dkim>fail</dkim><spf>fail</spf></policy_evaluated>
+    </row>
+    <identifiers><header_from>example.com</header_from></identifiers>
+  </record>
+</feedback>`
+
+func TestParseDMARCFeedback(t *testing.T) {
+	report, err := parseDMARCFeedback([]byte(sampleDMARCReport))
+	require.NoError(t, err)
+
+	assert.Equal(t, "google.com", report.ReportingOrg)
+	assert.Equal(t, "1234567890", report.ReportID)
+	assert.Equal(t, "example.com", report.Domain)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), report.Start)
+	assert.Equal(t, 10, report.MessageCount)
+	assert.Equal(t, 5, report.SPFAligned)
+	assert.Equal(t, 7, report.DKIMAligned)
+	assert.Equal(t, 7, report.DMARCPass)
+
+	require.Len(t, report.Sources, 2)
+	assert.Equal(t, "192.0.2.10", report.Sources[0].SourceIP)
+	assert.Equal(t, 7, report.Sources[0].MessageCount)
+	assert.Equal(t, 0, report.Sources[0].DMARCFail)
+	assert.Equal(t, "203.0.113.7", report.Sources[1].SourceIP)
+	assert.Equal(t, 3, report.Sources[1].DMARCFail)
+	assert.Equal(t, 3, report.Sources[1].Dispositions["reject"])
+}
+
+func TestParseDMARCReports_Containers(t *testing.T) {
+	var gz bytes.Buffer
+	gzWriter := gzip.NewWriter(&gz)
+	_, _ = gzWriter.Write([]byte(sampleDMARCReport))
+	require.NoError(t, gzWriter.Close())
+
+	var zipped bytes.Buffer
+	zipWriter := zip.NewWriter(&zipped)
+	file, err := zipWriter.Create("google.com!example.com!1709251200!1709337599.xml")
+	require.NoError(t, err)
+	_, _ = file.Write([]byte(sampleDMARCReport))
+	require.NoError(t, zipWriter.Close())
+
+	for name, data := range map[string][]byte{
+		"xml":  []byte(sampleDMARCReport),
+		"gzip": gz.Bytes(),
+		"zip":  zipped.Bytes(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			reports, err := parseDMARCReports(data)
+			require.NoError(t, err)
+			require.Len(t, reports, 1)
+			assert.Equal(t, 10, reports[0].MessageCount)
+		})
+	}
+}
+
+func TestParseDMARCReports_Invalid(t *testing.T) {
+	_, err := parseDMARCReports([]byte("not a report"))
+	assert.Error(t, err)
+
+	_, err = parseDMARCReports([]byte{0x1f, 0x8b, 0x00})
+	assert.Error(t, err)
+}
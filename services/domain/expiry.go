@@ -0,0 +1,131 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	tracingLog "github.com/opentracing/opentracing-go/log"
+
+	"github.com/customeros/mailstack/dto"
+	"github.com/customeros/mailstack/internal/enum"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+var defaultExpiryNotifyDays = []int{30, 14, 7, 1}
+
+// NotifyExpiringDomains publishes a DomainExpiring event when an active domain crosses one of
+// the configured days-before-expiry thresholds. The last threshold sent is stored on the domain
+// so every threshold fires once, it is reset when the domain is renewed.
+func (s *domainService) NotifyExpiringDomains(ctx context.Context) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "DomainService.NotifyExpiringDomains")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+
+	thresholds := defaultExpiryNotifyDays
+	if s.cfg != nil && len(s.cfg.ExpiryNotifyDays) > 0 {
+		thresholds = s.cfg.ExpiryNotifyDays
+	}
+
+	mailStackDomains, err := s.postgres.DomainRepository.GetAllActiveDomainsCrossTenant(ctx)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	now := utils.Now()
+	notified, failed := 0, 0
+	for _, mailStackDomain := range mailStackDomains {
+		info, err := s.namecheap.GetDomainInfo(ctx, mailStackDomain.Tenant, mailStackDomain.Domain)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			failed++
+			continue
+		}
+		if info.ExpiresAt == nil {
+			tracing.TraceErr(span, fmt.Errorf("unknown expiration date %q for domain %s", info.ExpiredDate, mailStackDomain.Domain))
+			failed++
+			continue
+		}
+
+		daysLeft := daysUntil(*info.ExpiresAt, now)
+		threshold, notify := nextExpiryNotification(daysLeft, mailStackDomain.ExpiryNotifiedDays, thresholds)
+
+		switch {
+		case notify:
+			err = s.publishDomainExpiring(ctx, mailStackDomain.Tenant, dto.DomainExpiring{
+				Domain:    mailStackDomain.Domain,
+				ExpiresAt: *info.ExpiresAt,
+				DaysLeft:  daysLeft,
+				Threshold: threshold,
+				AutoRenew: mailStackDomain.AutoRenew,
+			})
+			if err != nil {
+				failed++
+				continue
+			}
+			notified++
+			err = s.postgres.DomainRepository.SetExpiryNotified(ctx, mailStackDomain.Tenant, mailStackDomain.Domain, info.ExpiresAt, &threshold)
+		case threshold == 0 && mailStackDomain.ExpiryNotifiedDays != nil:
+			// renewed outside of mailstack, start over for the next expiry
+			err = s.postgres.DomainRepository.SetExpiryNotified(ctx, mailStackDomain.Tenant, mailStackDomain.Domain, info.ExpiresAt, nil)
+		}
+		if err != nil {
+			tracing.TraceErr(span, err)
+			failed++
+		}
+	}
+
+	span.LogFields(tracingLog.Int("result.notified", notified), tracingLog.Int("result.failed", failed))
+	if failed > 0 {
+		return fmt.Errorf("failed to check expiry of %d of %d domains", failed, len(mailStackDomains))
+	}
+	return nil
+}
+
+func (s *domainService) publishDomainExpiring(ctx context.Context, tenant string, event dto.DomainExpiring) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "DomainService.publishDomainExpiring")
+	defer span.Finish()
+	tracing.TagTenant(span, tenant)
+	span.LogKV("domain", event.Domain, "daysLeft", event.DaysLeft)
+
+	if s.events == nil {
+		return nil
+	}
+
+	ctx = utils.WithTenantContext(ctx, tenant)
+	err := s.events.Publisher.PublishFanoutEvent(ctx, event.Domain, enum.DOMAIN, event)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+	return nil
+}
+
+// nextExpiryNotification returns the smallest threshold daysLeft falls within, 0 if none,
+// and whether a notification is due for it given the last threshold notified. Thresholds
+// skipped between two runs are collapsed into the smallest one.
+func nextExpiryNotification(daysLeft int, lastNotified *int, thresholds []int) (int, bool) {
+	sorted := append([]int(nil), thresholds...)
+	sort.Ints(sorted)
+
+	threshold := 0
+	for _, days := range sorted {
+		if days > 0 && daysLeft <= days {
+			threshold = days
+			break
+		}
+	}
+	if threshold == 0 {
+		return 0, false
+	}
+	return threshold, lastNotified == nil || threshold < *lastNotified
+}
+
+// daysUntil returns the whole days left until t, negative once t has passed
+func daysUntil(t, now time.Time) int {
+	return int(t.Sub(now).Hours() / 24)
+}
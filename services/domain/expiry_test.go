@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+func TestNextExpiryNotification(t *testing.T) {
+	thresholds := []int{30, 14, 7, 1}
+
+	cases := []struct {
+		name         string
+		daysLeft     int
+		lastNotified *int
+		threshold    int
+		notify       bool
+	}{
+		{"far from expiry", 45, nil, 0, false},
+		{"first threshold", 30, nil, 30, true},
+		{"same threshold again", 20, utils.IntPtr(30), 30, false},
+		{"next threshold", 14, utils.IntPtr(30), 14, true},
+		{"skipped thresholds collapse", 5, utils.IntPtr(30), 7, true},
+		{"last day", 0, utils.IntPtr(7), 1, true},
+		{"expired after last notification", -3, utils.IntPtr(1), 1, false},
+		{"renewed", 365, utils.IntPtr(1), 0, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			threshold, notify := nextExpiryNotification(tc.daysLeft, tc.lastNotified, thresholds)
+			assert.Equal(t, tc.threshold, threshold)
+			assert.Equal(t, tc.notify, notify)
+		})
+	}
+}
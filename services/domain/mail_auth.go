@@ -0,0 +1,264 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+	tracingLog "github.com/opentracing/opentracing-go/log"
+	"github.com/pkg/errors"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/config"
+	er "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/tracing"
+)
+
+const (
+	dkimSelector     = "dkim"
+	namecheapAutoTTL = 1799 // the "Automatic" ttl of Namecheap DNS
+)
+
+// dnsRecordPlan lists the changes needed to bring the published records in line with the expected ones
+type dnsRecordPlan struct {
+	Add    []interfaces.DNSRecord
+	Update []interfaces.DNSRecord // expected content with the ID of the record to overwrite
+	Delete []interfaces.DNSRecord
+}
+
+func (p dnsRecordPlan) empty() bool {
+	return len(p.Add)+len(p.Update)+len(p.Delete) == 0
+}
+
+// publishMailAuthRecords creates or updates the SPF, DKIM and DMARC TXT records of a domain
+// in its Namecheap host records and verifies them afterward. Running it again leaves correct
+// records untouched.
+func (s *domainService) publishMailAuthRecords(ctx context.Context, tenant, domain string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "DomainService.publishMailAuthRecords")
+	defer span.Finish()
+	tracing.TagTenant(span, tenant)
+	span.LogKV("domain", domain)
+
+	domainRecord, err := s.postgres.DomainRepository.GetDomain(ctx, tenant, domain)
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "failed to get domain record"))
+		return err
+	}
+	if domainRecord == nil || domainRecord.DkimPublic == "" {
+		err = errors.Wrap(er.ErrDomainConfigurationFailed, "dkim key missing")
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	hostRecords, err := s.namecheap.GetHostRecords(ctx, tenant, domain)
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "failed to get host records"))
+		return err
+	}
+	// Namecheap rejects host record changes once the nameservers point elsewhere,
+	// the delegated cloudflare zone serves the records from then on
+	if !hostRecords.UsingNamecheapDNS {
+		span.LogKV("result", "domain not using Namecheap DNS, skipped")
+		return nil
+	}
+
+	expected := mailAuthRecords(domain, domainRecord.DkimPublic, s.cfg)
+
+	plan := planMailAuthRecords(expected, hostDNSRecords(domain, hostRecords.Hosts))
+	span.LogFields(
+		tracingLog.Int("records.add", len(plan.Add)),
+		tracingLog.Int("records.update", len(plan.Update)),
+		tracingLog.Int("records.delete", len(plan.Delete)),
+	)
+	if plan.empty() {
+		return nil
+	}
+
+	hostRecords.Hosts = applyHostRecordPlan(domain, hostRecords.Hosts, plan)
+	err = s.namecheap.SetHostRecords(ctx, tenant, domain, hostRecords)
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "failed to set host records"))
+		return err
+	}
+
+	// verify against the provider, public resolvers lag behind
+	hostRecords, err = s.namecheap.GetHostRecords(ctx, tenant, domain)
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "failed to get host records"))
+		return err
+	}
+	plan = planMailAuthRecords(expected, hostDNSRecords(domain, hostRecords.Hosts))
+	if !plan.empty() {
+		err = errors.Wrapf(er.ErrDNSRecordsNotPublished, "%d missing, %d incorrect, %d duplicate", len(plan.Add), len(plan.Update), len(plan.Delete))
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	return nil
+}
+
+// mailAuthRecords returns the SPF, DKIM and DMARC TXT records a mailstack domain must publish
+func mailAuthRecords(domain, dkimPublic string, cfg *config.DomainConfig) []interfaces.DNSRecord {
+	spfInclude, policy := mailAuthSPFInclude(cfg), "reject"
+	rua, ruf := "monitor@customerosmail.com", "dmarc@customerosmail.com"
+	if cfg != nil {
+		if cfg.DMARCPolicy != "" {
+			policy = cfg.DMARCPolicy
+		}
+		if cfg.DMARCReportEmail != "" {
+			rua = cfg.DMARCReportEmail
+		}
+		if cfg.DMARCForensicEmail != "" {
+			ruf = cfg.DMARCForensicEmail
+		}
+	}
+
+	return []interfaces.DNSRecord{
+		{
+			Type:       "TXT",
+			RecordName: domain,
+			Content:    fmt.Sprintf("v=spf1 include:%s -all", spfInclude),
+		},
+		{
+			Type:       "TXT",
+			RecordName: fmt.Sprintf("%s._domainkey.%s", dkimSelector, domain),
+			Content:    dkimPublic,
+		},
+		{
+			Type:       "TXT",
+			RecordName: "_dmarc." + domain,
+			Content:    fmt.Sprintf("v=DMARC1; p=%s; aspf=s; adkim=s; sp=%s; pct=100; ruf=mailto:%s; rua=mailto:%s; fo=1; ri=86400", policy, policy, ruf, rua),
+		},
+	}
+}
+
+// mailAuthSPFInclude is the SPF include of the mail provider that sends for mailstack domains
+func mailAuthSPFInclude(cfg *config.DomainConfig) string {
+	if cfg != nil && cfg.SPFInclude != "" {
+		return cfg.SPFInclude
+	}
+	return "_spf.hostedemail.com"
+}
+
+// planMailAuthRecords compares the expected records with the published ones. A published TXT
+// record matches an expected one when name and version tag (v=spf1, v=DKIM1, v=DMARC1) agree,
+// other TXT records on the same name, like site verifications, are left alone.
+func planMailAuthRecords(expected, published []interfaces.DNSRecord) dnsRecordPlan {
+	var plan dnsRecordPlan
+
+	for _, want := range expected {
+		var matches []interfaces.DNSRecord
+		for _, record := range published {
+			if strings.EqualFold(record.Type, want.Type) &&
+				strings.EqualFold(strings.TrimSuffix(record.RecordName, "."), want.RecordName) &&
+				txtVersionTag(record.Content) == txtVersionTag(want.Content) {
+				matches = append(matches, record)
+			}
+		}
+
+		if len(matches) == 0 {
+			plan.Add = append(plan.Add, want)
+			continue
+		}
+
+		// keep an exact match if there is one, multiple SPF or DMARC records invalidate each other
+		keep := 0
+		for i, record := range matches {
+			if normalizeTXT(record.Content) == normalizeTXT(want.Content) {
+				keep = i
+				break
+			}
+		}
+		if normalizeTXT(matches[keep].Content) != normalizeTXT(want.Content) {
+			update := want
+			update.ID = matches[keep].ID
+			plan.Update = append(plan.Update, update)
+		}
+		for i, record := range matches {
+			if i != keep {
+				plan.Delete = append(plan.Delete, record)
+			}
+		}
+	}
+
+	return plan
+}
+
+// txtVersionTag returns the lowercased leading tag of a TXT record, e.g. "v=spf1"
+func txtVersionTag(content string) string {
+	content = normalizeTXT(content)
+	if idx := strings.IndexAny(content, "; "); idx >= 0 {
+		content = content[:idx]
+	}
+	return strings.ToLower(content)
+}
+
+// normalizeTXT strips the quoting and chunking TXT values come back with
+func normalizeTXT(content string) string {
+	content = strings.TrimSpace(content)
+	if strings.HasPrefix(content, `"`) && strings.HasSuffix(content, `"`) {
+		content = strings.ReplaceAll(content[1:len(content)-1], `" "`, "")
+	}
+	return content
+}
+
+// hostDNSRecords converts Namecheap host records to fully qualified DNS records,
+// the ID is the position of the host in the list
+func hostDNSRecords(domain string, hosts []interfaces.NamecheapHostRecord) []interfaces.DNSRecord {
+	records := make([]interfaces.DNSRecord, 0, len(hosts))
+	for i, host := range hosts {
+		name := domain
+		if host.Name != "" && host.Name != "@" {
+			name = host.Name + "." + domain
+		}
+		records = append(records, interfaces.DNSRecord{
+			ID:         strconv.Itoa(i),
+			Type:       host.Type,
+			RecordName: name,
+			Content:    host.Address,
+		})
+	}
+	return records
+}
+
+// applyHostRecordPlan returns the full host list with the plan applied, setHosts replaces all records
+func applyHostRecordPlan(domain string, hosts []interfaces.NamecheapHostRecord, plan dnsRecordPlan) []interfaces.NamecheapHostRecord {
+	updates := make(map[int]string, len(plan.Update))
+	for _, record := range plan.Update {
+		if i, err := strconv.Atoi(record.ID); err == nil {
+			updates[i] = record.Content
+		}
+	}
+	deletes := make(map[int]bool, len(plan.Delete))
+	for _, record := range plan.Delete {
+		if i, err := strconv.Atoi(record.ID); err == nil {
+			deletes[i] = true
+		}
+	}
+
+	result := make([]interfaces.NamecheapHostRecord, 0, len(hosts)+len(plan.Add))
+	for i, host := range hosts {
+		if deletes[i] {
+			continue
+		}
+		if content, ok := updates[i]; ok {
+			host.Address = content
+		}
+		result = append(result, host)
+	}
+	for _, record := range plan.Add {
+		name := "@"
+		if record.RecordName != domain {
+			name = strings.TrimSuffix(record.RecordName, "."+domain)
+		}
+		result = append(result, interfaces.NamecheapHostRecord{
+			Name:    name,
+			Type:    record.Type,
+			Address: record.Content,
+			TTL:     namecheapAutoTTL,
+		})
+	}
+	return result
+}
@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/customeros/mailstack/interfaces"
+)
+
+func TestPlanMailAuthRecords(t *testing.T) {
+	expected := mailAuthRecords("example.com", "v=DKIM1; k=rsa; p=NEWKEY", nil)
+
+	published := []interfaces.DNSRecord{
+		// correct spf, quoted the way some providers return it
+		{ID: "spf", Type: "TXT", RecordName: "example.com", Content: `"v=spf1 include:_spf.hostedemail.com -all"`},
+		// unrelated verification record on the apex stays
+		{ID: "verify", Type: "TXT", RecordName: "example.com", Content: "google-site-verification=abc"},
+		// rotated dkim key
+		{ID: "dkim", Type: "TXT", RecordName: "dkim._domainkey.example.com", Content: "v=DKIM1; k=rsa; p=OLDKEY"},
+		// no dmarc record published
+	}
+
+	plan := planMailAuthRecords(expected, published)
+
+	if assert.Len(t, plan.Add, 1) {
+		assert.Equal(t, "_dmarc.example.com", plan.Add[0].RecordName)
+	}
+	if assert.Len(t, plan.Update, 1) {
+		assert.Equal(t, "dkim", plan.Update[0].ID)
+		assert.Equal(t, "v=DKIM1; k=rsa; p=NEWKEY", plan.Update[0].Content)
+	}
+	assert.Empty(t, plan.Delete)
+}
+
+func TestPlanMailAuthRecords_Idempotent(t *testing.T) {
+	expected := mailAuthRecords("example.com", "v=DKIM1; k=rsa; p=KEY", nil)
+
+	published := make([]interfaces.DNSRecord, 0, len(expected))
+	for i, record := range expected {
+		record.ID = string(rune('a' + i))
+		published = append(published, record)
+	}
+
+	assert.Equal(t, dnsRecordPlan{}, planMailAuthRecords(expected, published))
+}
+
+func TestPlanMailAuthRecords_DuplicateSPF(t *testing.T) {
+	expected := mailAuthRecords("example.com", "v=DKIM1; k=rsa; p=KEY", nil)[:1]
+
+	published := []interfaces.DNSRecord{
+		{ID: "old", Type: "TXT", RecordName: "example.com", Content: "v=spf1 include:other.com ~all"},
+		{ID: "current", Type: "TXT", RecordName: "example.com", Content: "v=spf1 include:_spf.hostedemail.com -all"},
+	}
+
+	plan := planMailAuthRecords(expected, published)
+
+	assert.Empty(t, plan.Add)
+	assert.Empty(t, plan.Update)
+	if assert.Len(t, plan.Delete, 1) {
+		assert.Equal(t, "old", plan.Delete[0].ID)
+	}
+}
+
+func TestApplyHostRecordPlan(t *testing.T) {
+	hosts := []interfaces.NamecheapHostRecord{
+		{Name: "@", Type: "MX", Address: "mx.example.com.", MXPref: 10, TTL: 1800},
+		{Name: "@", Type: "TXT", Address: "v=spf1 include:other.com ~all", TTL: 1800},
+		{Name: "@", Type: "TXT", Address: "v=spf1 include:_spf.hostedemail.com -all", TTL: 1800},
+		{Name: "dkim._domainkey", Type: "TXT", Address: "v=DKIM1; k=rsa; p=OLDKEY", TTL: 1800},
+	}
+	expected := mailAuthRecords("example.com", "v=DKIM1; k=rsa; p=NEWKEY", nil)
+
+	plan := planMailAuthRecords(expected, hostDNSRecords("example.com", hosts))
+	result := applyHostRecordPlan("example.com", hosts, plan)
+
+	assert.Equal(t, []interfaces.NamecheapHostRecord{
+		{Name: "@", Type: "MX", Address: "mx.example.com.", MXPref: 10, TTL: 1800},
+		{Name: "@", Type: "TXT", Address: "v=spf1 include:_spf.hostedemail.com -all", TTL: 1800},
+		{Name: "dkim._domainkey", Type: "TXT", Address: "v=DKIM1; k=rsa; p=NEWKEY", TTL: 1800},
+		{Name: "_dmarc", Type: "TXT", Address: expected[2].Content, TTL: namecheapAutoTTL},
+	}, result)
+
+	assert.True(t, planMailAuthRecords(expected, hostDNSRecords("example.com", result)).empty())
+}
@@ -0,0 +1,210 @@
+package domain
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	tracingLog "github.com/opentracing/opentracing-go/log"
+	"github.com/pkg/errors"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+// a purchase still pending after this long never reached the registrar, or the registrar refused it
+const pendingPurchaseTimeout = time.Hour
+
+// ReconcilePurchasedDomains backfills MailStackDomain rows for purchases that were registered
+// at Namecheap but could not be stored. Returns the number of domains backfilled.
+func (s *domainService) ReconcilePurchasedDomains(ctx context.Context) (int, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "DomainService.ReconcilePurchasedDomains")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+
+	purchases, err := s.postgres.DomainRepository.GetUnreconciledPurchases(ctx)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return 0, err
+	}
+	if len(purchases) == 0 {
+		return 0, nil
+	}
+
+	listed, err := s.namecheap.ListDomains(ctx)
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "failed to list Namecheap domains"))
+		return 0, err
+	}
+	owned := make(map[string]bool, len(listed))
+	for _, domain := range listed {
+		owned[domain.DomainName] = true
+	}
+
+	now := utils.Now()
+	backfilled := 0
+	for _, purchase := range purchases {
+		status := reconcilePurchaseStatus(purchase, owned[purchase.Domain], now)
+		if status == purchase.Status {
+			continue
+		}
+
+		if status == models.DomainPurchaseStatusStored {
+			stored, err := s.storePurchasedDomain(ctx, purchase)
+			if err != nil {
+				tracing.TraceErr(span, err)
+				continue
+			}
+			if stored {
+				backfilled++
+				span.LogFields(tracingLog.String("backfilled", purchase.Domain))
+			}
+		}
+
+		err = s.postgres.DomainRepository.UpdatePurchaseStatus(ctx, purchase.ID, status, "")
+		if err != nil {
+			tracing.TraceErr(span, err)
+		}
+	}
+
+	span.LogFields(tracingLog.Int("result.backfilled", backfilled))
+	return backfilled, nil
+}
+
+// storePurchasedDomain creates the MailStackDomain row of a purchase unless it exists already.
+// Returns whether a row was created.
+func (s *domainService) storePurchasedDomain(ctx context.Context, purchase *models.MailStackDomainPurchase) (bool, error) {
+	existing, err := s.postgres.DomainRepository.GetDomainCrossTenant(ctx, purchase.Domain)
+	if err != nil {
+		return false, err
+	}
+	if existing != nil {
+		if existing.Tenant != purchase.Tenant {
+			return false, errors.Errorf("domain %s is stored for another tenant", purchase.Domain)
+		}
+		return false, nil
+	}
+
+	if _, err = s.postgres.DomainRepository.RegisterDomain(ctx, purchase.Tenant, purchase.Domain); err != nil {
+		return false, errors.Wrapf(err, "failed to store domain %s", purchase.Domain)
+	}
+	return true, nil
+}
+
+// reconcilePurchaseStatus decides the status of an unreconciled purchase. Domains the account
+// owns get stored. A pending purchase the account does not own is failed once it timed out,
+// registered ones stay as they are since getList may lag behind a fresh registration.
+func reconcilePurchaseStatus(purchase *models.MailStackDomainPurchase, owned bool, now time.Time) models.DomainPurchaseStatus {
+	if owned {
+		return models.DomainPurchaseStatusStored
+	}
+	if purchase.Status == models.DomainPurchaseStatusPending && now.Sub(purchase.CreatedAt) > pendingPurchaseTimeout {
+		return models.DomainPurchaseStatusFailed
+	}
+	return purchase.Status
+}
+
+// ReconcileDomainOwnership compares the domains registered in the Namecheap account with the
+// stored ones. Owned domains without a row are created for the tenant that purchased them,
+// active rows for domains no longer owned, or expired, are deactivated.
+func (s *domainService) ReconcileDomainOwnership(ctx context.Context) (interfaces.DomainOwnershipReport, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "DomainService.ReconcileDomainOwnership")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+
+	var report interfaces.DomainOwnershipReport
+
+	listed, err := s.namecheap.ListDomains(ctx)
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "failed to list Namecheap domains"))
+		return report, err
+	}
+	stored, err := s.postgres.DomainRepository.GetAllDomainsCrossTenant(ctx)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return report, err
+	}
+
+	// an empty listing is far more likely an account or API problem than every domain being gone
+	if len(listed) == 0 && len(stored) > 0 {
+		err = errors.New("Namecheap returned no domains, skipping reconciliation")
+		tracing.TraceErr(span, err)
+		return report, err
+	}
+
+	missing, notOwned := diffDomainOwnership(listed, stored)
+
+	for _, domain := range missing {
+		purchase, err := s.postgres.DomainRepository.GetLatestPurchase(ctx, domain)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			report.Failed = append(report.Failed, domain)
+			continue
+		}
+		if purchase == nil {
+			report.Unassigned = append(report.Unassigned, domain)
+			continue
+		}
+		if _, err = s.postgres.DomainRepository.RegisterDomain(ctx, purchase.Tenant, domain); err != nil {
+			tracing.TraceErr(span, err)
+			report.Failed = append(report.Failed, domain)
+			continue
+		}
+		if err = s.postgres.DomainRepository.UpdatePurchaseStatus(ctx, purchase.ID, models.DomainPurchaseStatusStored, ""); err != nil {
+			tracing.TraceErr(span, err)
+		}
+		report.Created = append(report.Created, domain)
+	}
+
+	for _, domain := range notOwned {
+		if err = s.postgres.DomainRepository.SetActive(ctx, domain.Tenant, domain.Domain, false); err != nil {
+			tracing.TraceErr(span, err)
+			report.Failed = append(report.Failed, domain.Domain)
+			continue
+		}
+		report.Deactivated = append(report.Deactivated, domain.Domain)
+	}
+
+	span.LogFields(
+		tracingLog.Object("result.created", report.Created),
+		tracingLog.Object("result.deactivated", report.Deactivated),
+		tracingLog.Object("result.unassigned", report.Unassigned),
+		tracingLog.Object("result.failed", report.Failed),
+	)
+	return report, nil
+}
+
+// diffDomainOwnership returns the owned, unexpired domains that are not stored and the active
+// stored domains that are not owned or expired
+func diffDomainOwnership(listed []interfaces.NamecheapListedDomain, stored []models.MailStackDomain) ([]string, []models.MailStackDomain) {
+	owned := make(map[string]bool, len(listed))
+	for _, domain := range listed {
+		owned[strings.ToLower(domain.DomainName)] = !domain.IsExpired
+	}
+	known := make(map[string]bool, len(stored))
+	for _, domain := range stored {
+		known[strings.ToLower(domain.Domain)] = true
+	}
+
+	var missing []string
+	for _, domain := range listed {
+		name := strings.ToLower(domain.DomainName)
+		if !domain.IsExpired && !known[name] {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+
+	var notOwned []models.MailStackDomain
+	for _, domain := range stored {
+		if domain.Active && !owned[strings.ToLower(domain.Domain)] {
+			notOwned = append(notOwned, domain)
+		}
+	}
+
+	return missing, notOwned
+}
@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/models"
+)
+
+func TestReconcilePurchaseStatus(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	fresh := now.Add(-10 * time.Minute)
+	old := now.Add(-2 * time.Hour)
+
+	cases := []struct {
+		name     string
+		status   models.DomainPurchaseStatus
+		created  time.Time
+		owned    bool
+		expected models.DomainPurchaseStatus
+	}{
+		{"registered and owned", models.DomainPurchaseStatusRegistered, old, true, models.DomainPurchaseStatusStored},
+		{"pending but owned", models.DomainPurchaseStatusPending, fresh, true, models.DomainPurchaseStatusStored},
+		{"pending within timeout", models.DomainPurchaseStatusPending, fresh, false, models.DomainPurchaseStatusPending},
+		{"pending timed out", models.DomainPurchaseStatusPending, old, false, models.DomainPurchaseStatusFailed},
+		{"registered not listed yet", models.DomainPurchaseStatusRegistered, old, false, models.DomainPurchaseStatusRegistered},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			purchase := &models.MailStackDomainPurchase{Status: tc.status, CreatedAt: tc.created}
+			assert.Equal(t, tc.expected, reconcilePurchaseStatus(purchase, tc.owned, now))
+		})
+	}
+}
+
+func TestDiffDomainOwnership(t *testing.T) {
+	listed := []interfaces.NamecheapListedDomain{
+		{DomainName: "stored.com"},
+		{DomainName: "Missing.com"},
+		{DomainName: "expired-unknown.com", IsExpired: true},
+		{DomainName: "expired-stored.com", IsExpired: true},
+	}
+	stored := []models.MailStackDomain{
+		{Domain: "stored.com", Active: true},
+		{Domain: "expired-stored.com", Active: true},
+		{Domain: "gone.com", Active: true},
+		{Domain: "gone-inactive.com", Active: false},
+	}
+
+	missing, notOwned := diffDomainOwnership(listed, stored)
+
+	assert.Equal(t, []string{"missing.com"}, missing)
+	var notOwnedNames []string
+	for _, domain := range notOwned {
+		notOwnedNames = append(notOwnedNames, domain.Domain)
+	}
+	assert.Equal(t, []string{"expired-stored.com", "gone.com"}, notOwnedNames)
+}
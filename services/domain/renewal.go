@@ -0,0 +1,116 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	tracingLog "github.com/opentracing/opentracing-go/log"
+
+	"github.com/customeros/mailstack/dto"
+	"github.com/customeros/mailstack/internal/enum"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+const (
+	defaultRenewBeforeDays = 30
+	defaultRenewYears      = 1
+)
+
+// RenewExpiringDomains renews active domains whose registration expires within the configured
+// threshold. Domains the tenant opted out of auto renewal for are skipped. A failure on one
+// domain does not stop the others.
+func (s *domainService) RenewExpiringDomains(ctx context.Context) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "DomainService.RenewExpiringDomains")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+
+	renewBefore, years := defaultRenewBeforeDays, defaultRenewYears
+	if s.cfg != nil {
+		if s.cfg.RenewBeforeDays > 0 {
+			renewBefore = s.cfg.RenewBeforeDays
+		}
+		if s.cfg.RenewYears > 0 {
+			years = s.cfg.RenewYears
+		}
+	}
+
+	mailStackDomains, err := s.postgres.DomainRepository.GetAllActiveDomainsCrossTenant(ctx)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	now := utils.Now()
+	renewed, failed := 0, 0
+	for _, mailStackDomain := range mailStackDomains {
+		if !mailStackDomain.AutoRenew {
+			span.LogFields(tracingLog.String("skipped.optedOut", mailStackDomain.Domain))
+			continue
+		}
+
+		// the registrar is authoritative, the stored expiration may predate a manual renewal
+		info, err := s.namecheap.GetDomainInfo(ctx, mailStackDomain.Tenant, mailStackDomain.Domain)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			failed++
+			continue
+		}
+		if info.ExpiresAt == nil {
+			tracing.TraceErr(span, fmt.Errorf("unknown expiration date %q for domain %s", info.ExpiredDate, mailStackDomain.Domain))
+			failed++
+			continue
+		}
+		if !renewalDue(*info.ExpiresAt, now, renewBefore) {
+			continue
+		}
+
+		err = s.namecheap.RenewDomain(ctx, mailStackDomain.Tenant, mailStackDomain.Domain, years)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			failed++
+			continue
+		}
+		renewed++
+
+		// GetDomainInfo reflects the new expiration date once the renewal went through
+		var expiresAt *time.Time
+		if info, err = s.namecheap.GetDomainInfo(ctx, mailStackDomain.Tenant, mailStackDomain.Domain); err == nil {
+			expiresAt = info.ExpiresAt
+		}
+		s.notifyDomainRenewed(ctx, mailStackDomain.Tenant, mailStackDomain.Domain, years, expiresAt)
+	}
+
+	span.LogFields(tracingLog.Int("result.renewed", renewed), tracingLog.Int("result.failed", failed))
+	if failed > 0 {
+		return fmt.Errorf("failed to renew %d of %d domains", failed, len(mailStackDomains))
+	}
+	return nil
+}
+
+func (s *domainService) notifyDomainRenewed(ctx context.Context, tenant, domain string, years int, expiresAt *time.Time) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "DomainService.notifyDomainRenewed")
+	defer span.Finish()
+	tracing.TagTenant(span, tenant)
+
+	if s.events == nil {
+		return
+	}
+
+	ctx = utils.WithTenantContext(ctx, tenant)
+	err := s.events.Publisher.PublishFanoutEvent(ctx, domain, enum.DOMAIN, dto.DomainRenewed{
+		Domain:    domain,
+		Years:     years,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		tracing.TraceErr(span, err)
+	}
+}
+
+// renewalDue reports whether a registration expiring at expiresAt is within renewBeforeDays of now
+func renewalDue(expiresAt, now time.Time, renewBeforeDays int) bool {
+	return expiresAt.Before(now.AddDate(0, 0, renewBeforeDays))
+}
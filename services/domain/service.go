@@ -3,6 +3,7 @@ package domain
 import (
 	"context"
 	"fmt"
+	"net"
 
 	"github.com/customeros/mailwatcher/blscan"
 	"github.com/customeros/mailwatcher/domainage"
@@ -11,10 +12,12 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/config"
 	"github.com/customeros/mailstack/internal/models"
 	"github.com/customeros/mailstack/internal/repository"
 	"github.com/customeros/mailstack/internal/tracing"
 	"github.com/customeros/mailstack/internal/utils"
+	"github.com/customeros/mailstack/services/events"
 )
 
 type domainService struct {
@@ -23,15 +26,21 @@ type domainService struct {
 	mailbox    interfaces.MailboxServiceOld
 	namecheap  interfaces.NamecheapService
 	opensrs    interfaces.OpenSrsService
+	events     *events.EventsService
+	cfg        *config.DomainConfig
+	resolver   dnsResolver
 }
 
-func NewDomainService(postgres *repository.Repositories, cloudflare interfaces.CloudflareService, namecheap interfaces.NamecheapService, mailbox interfaces.MailboxServiceOld, opensrs interfaces.OpenSrsService) interfaces.DomainService {
+func NewDomainService(postgres *repository.Repositories, cloudflare interfaces.CloudflareService, namecheap interfaces.NamecheapService, mailbox interfaces.MailboxServiceOld, opensrs interfaces.OpenSrsService, events *events.EventsService, cfg *config.DomainConfig) interfaces.DomainService {
 	return &domainService{
 		postgres:   postgres,
 		cloudflare: cloudflare,
 		mailbox:    mailbox,
 		namecheap:  namecheap,
 		opensrs:    opensrs,
+		events:     events,
+		cfg:        cfg,
+		resolver:   net.DefaultResolver,
 	}
 }
 
@@ -64,6 +73,13 @@ func (s *domainService) ConfigureDomain(ctx context.Context, domain, redirectWeb
 		return err
 	}
 
+	// publish SPF, DKIM and DMARC records, the DKIM key is generated during the Cloudflare setup
+	err = s.publishMailAuthRecords(ctx, tenant, domain)
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "Error publishing mail authentication records"))
+		return err
+	}
+
 	// replace nameservers in namecheap
 	err = s.namecheap.UpdateNameservers(ctx, tenant, domain, nameservers)
 	if err != nil {
@@ -137,23 +153,45 @@ func (s *domainService) reputationScore(ctx context.Context, domain, tenant stri
 	domainAgePenalty := s.domainAgePenalty(span, domain)
 	blacklistPenaltyPct := s.blacklistPenaltyPercent(domain)
 
-	score := (100 - domainAgePenalty) * (1 - (blacklistPenaltyPct)/100)
+	// configured DNSBLs for the domain and the sending IPs
+	listings := s.checkBlocklists(ctx, domain)
+	blacklistPenaltyPct = min(100, blacklistPenaltyPct+len(listings)*blocklistListingPct)
+
+	score := (100 - domainAgePenalty) * (100 - blacklistPenaltyPct) / 100
 
 	// todo, add:
 	// 7 day lookback on bounces
 	// 7 day lookback on dmarc and spf
 
+	previous, err := s.postgres.DomainRepository.GetLatestMailstackReputation(ctx, tenant, domain)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return 0, err
+	}
+
 	dbEntity := models.MailstackReputation{
 		CreatedAt:           utils.Now(),
 		Tenant:              tenant,
 		Domain:              domain,
 		DomainAgePenalty:    domainAgePenalty,
 		BlacklistPenaltyPct: blacklistPenaltyPct,
+		Score:               score,
+		BlocklistListings:   listings,
 	}
 
-	err := s.postgres.DomainRepository.CreateMailstackReputationScore(ctx, tenant, &dbEntity)
+	err = s.postgres.DomainRepository.CreateMailstackReputationScore(ctx, tenant, &dbEntity)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return score, err
+	}
+
+	var previousListings []string
+	if previous != nil {
+		previousListings = previous.BlocklistListings
+	}
+	s.notifyNewListings(ctx, tenant, domain, listings, previousListings)
 
-	return score, err
+	return score, nil
 }
 
 func (s *domainService) domainAgePenalty(span opentracing.Span, domain string) int {
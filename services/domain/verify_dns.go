@@ -0,0 +1,134 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+	tracingLog "github.com/opentracing/opentracing-go/log"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/enum"
+	er "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+	"github.com/customeros/mailstack/services/mailauth"
+)
+
+// dnsResolver is the subset of net.Resolver used for verification
+type dnsResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// VerifyDNS resolves the MX, SPF, DKIM and DMARC records of a tenant domain through public
+// DNS and reports for each whether it is present, missing or different from what we configured
+func (s *domainService) VerifyDNS(ctx context.Context, domain string) ([]interfaces.DNSRecordVerification, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "DomainService.VerifyDNS")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.LogKV("request.domain", domain)
+
+	tenant := utils.GetTenantFromContext(ctx)
+
+	domainRecord, err := s.postgres.DomainRepository.GetDomain(ctx, tenant, domain)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+	if domainRecord == nil {
+		tracing.TraceErr(span, er.ErrDomainNotFound)
+		return nil, er.ErrDomainNotFound
+	}
+
+	results := []interfaces.DNSRecordVerification{}
+
+	mx, err := s.verifyMX(ctx, domain)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+	results = append(results, mx)
+
+	for _, expected := range mailAuthRecords(domain, domainRecord.DkimPublic, s.cfg) {
+		txt, err := s.verifyTXT(ctx, expected)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return nil, err
+		}
+		results = append(results, txt)
+	}
+
+	for _, result := range results {
+		span.LogFields(tracingLog.String(result.Name+"."+result.Type, string(result.Status)))
+	}
+
+	return results, nil
+}
+
+func (s *domainService) verifyMX(ctx context.Context, domain string) (interfaces.DNSRecordVerification, error) {
+	result := interfaces.DNSRecordVerification{
+		Type:     "MX",
+		Name:     domain,
+		Expected: mailExchangeHost(domain),
+		Found:    []string{},
+	}
+
+	records, err := s.resolver.LookupMX(ctx, domain)
+	if err != nil && !mailauth.IsNotFound(err) {
+		return result, fmt.Errorf("mx lookup for %s failed: %w", domain, err)
+	}
+	for _, record := range records {
+		result.Found = append(result.Found, strings.ToLower(strings.TrimSuffix(record.Host, ".")))
+	}
+
+	result.Status = recordStatus(len(result.Found) > 0, utils.IsStringInSlice(result.Expected, result.Found))
+	return result, nil
+}
+
+func (s *domainService) verifyTXT(ctx context.Context, expected interfaces.DNSRecord) (interfaces.DNSRecordVerification, error) {
+	result := interfaces.DNSRecordVerification{
+		Type:     expected.Type,
+		Name:     expected.RecordName,
+		Expected: expected.Content,
+		Found:    []string{},
+	}
+
+	values, err := s.resolver.LookupTXT(ctx, expected.RecordName)
+	if err != nil && !mailauth.IsNotFound(err) {
+		return result, fmt.Errorf("txt lookup for %s failed: %w", expected.RecordName, err)
+	}
+
+	matched := false
+	for _, value := range values {
+		// only compare records of the same kind, the apex carries other TXT records too
+		if txtVersionTag(value) != txtVersionTag(expected.Content) {
+			continue
+		}
+		result.Found = append(result.Found, value)
+		if normalizeTXT(value) == normalizeTXT(expected.Content) {
+			matched = true
+		}
+	}
+
+	result.Status = recordStatus(len(result.Found) > 0, matched)
+	return result, nil
+}
+
+func recordStatus(found, matched bool) enum.DNSRecordStatus {
+	switch {
+	case matched:
+		return enum.DNSRecordPresent
+	case found:
+		return enum.DNSRecordMismatch
+	}
+	return enum.DNSRecordMissing
+}
+
+// mailExchangeHost is the MX target configured for mailstack domains
+func mailExchangeHost(domain string) string {
+	return fmt.Sprintf("mx.%s.cust.a.hostedemail.com", domain)
+}
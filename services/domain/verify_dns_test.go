@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/internal/enum"
+)
+
+type fakeResolver struct {
+	txt   map[string][]string
+	mx    map[string][]*net.MX
+	hosts map[string][]string
+}
+
+func (r *fakeResolver) LookupTXT(_ context.Context, name string) ([]string, error) {
+	if values, ok := r.txt[name]; ok {
+		return values, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (r *fakeResolver) LookupMX(_ context.Context, name string) ([]*net.MX, error) {
+	if values, ok := r.mx[name]; ok {
+		return values, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (r *fakeResolver) LookupHost(_ context.Context, host string) ([]string, error) {
+	if values, ok := r.hosts[host]; ok {
+		return values, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func TestVerifyRecords(t *testing.T) {
+	s := &domainService{resolver: &fakeResolver{
+		txt: map[string][]string{
+			"example.com":                 {"google-site-verification=abc", "v=spf1 include:_spf.hostedemail.com -all"},
+			"dkim._domainkey.example.com": {"v=DKIM1; k=rsa; p=OLDKEY"},
+		},
+		mx: map[string][]*net.MX{
+			"example.com": {{Host: "mx.example.com.cust.a.hostedemail.com.", Pref: 10}},
+		},
+	}}
+
+	mx, err := s.verifyMX(context.Background(), "example.com")
+	require.NoError(t, err)
+	assert.Equal(t, enum.DNSRecordPresent, mx.Status)
+
+	expected := mailAuthRecords("example.com", "v=DKIM1; k=rsa; p=NEWKEY", nil)
+	statuses := map[string]enum.DNSRecordStatus{}
+	for _, record := range expected {
+		result, err := s.verifyTXT(context.Background(), record)
+		require.NoError(t, err)
+		statuses[result.Name] = result.Status
+	}
+
+	assert.Equal(t, enum.DNSRecordPresent, statuses["example.com"])
+	assert.Equal(t, enum.DNSRecordMismatch, statuses["dkim._domainkey.example.com"])
+	assert.Equal(t, enum.DNSRecordMissing, statuses["_dmarc.example.com"])
+}
@@ -0,0 +1,102 @@
+package email
+
+import (
+	"context"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/enum"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+// reserveDailySend counts the email against the daily limit of the mailbox. When the limit is
+// reached the email is rescheduled for the next UTC day and deferred is true. Otherwise the
+// returned release gives the reservation back, for sends that end up not going out.
+func (s *emailService) reserveDailySend(ctx context.Context, mailbox *models.Mailbox, email *models.Email) (deferred bool, release func(context.Context), err error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailService.reserveDailySend")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+
+	now := utils.Now()
+	limit := dailySendLimit(mailbox, now)
+	span.LogFields(log.Int("dailyLimit", limit))
+
+	reserved, err := s.repositories.MailboxSendCountRepository.Reserve(ctx, mailbox.ID, now, limit)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return false, nil, err
+	}
+	if reserved {
+		return false, func(ctx context.Context) {
+			// failures are traced by the repository, the count is only off by one for the day
+			_ = s.repositories.MailboxSendCountRepository.Release(ctx, mailbox.ID, now)
+		}, nil
+	}
+
+	nextAt := nextDay(now)
+	span.LogFields(log.Bool("dailyLimitReached", true), log.String("nextAllowedAt", nextAt.String()))
+
+	// hand it back to the scheduled send dispatcher
+	email.Status = enum.EmailStatusScheduled
+	email.ScheduledFor = &nextAt
+	err = s.repositories.EmailRepository.Update(ctx, email)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return false, nil, err
+	}
+
+	return true, nil, nil
+}
+
+// GetSendQuota returns how many emails the mailbox may still send today
+func (s *emailService) GetSendQuota(ctx context.Context, mailbox *models.Mailbox) (*interfaces.SendQuota, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailService.GetSendQuota")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.SetTag("mailbox_id", mailbox.ID)
+
+	now := utils.Now()
+	sent, err := s.repositories.MailboxSendCountRepository.Get(ctx, mailbox.ID, now)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	quota := &interfaces.SendQuota{
+		Date:       utils.StartOfDayInUTC(now),
+		DailyLimit: dailySendLimit(mailbox, now),
+		Sent:       sent,
+		ResetsAt:   nextDay(now),
+	}
+	if quota.DailyLimit > 0 {
+		quota.Remaining = max(quota.DailyLimit-sent, 0)
+		quota.WarmingUp = quota.DailyLimit < mailbox.DailySendQuota || mailbox.DailySendQuota <= 0
+	}
+	return quota, nil
+}
+
+// dailySendLimit returns the number of emails the mailbox may send on the UTC day of now, 0 when
+// unlimited. During warm-up the limit grows every day since the mailbox was created, until it
+// reaches the daily quota of the mailbox.
+func dailySendLimit(mailbox *models.Mailbox, now time.Time) int {
+	limit := mailbox.DailySendQuota
+	if mailbox.WarmupStartLimit <= 0 {
+		return max(limit, 0)
+	}
+
+	days := int(utils.StartOfDayInUTC(now.UTC()).Sub(utils.StartOfDayInUTC(mailbox.CreatedAt.UTC())).Hours() / 24)
+	warmup := mailbox.WarmupStartLimit + max(days, 0)*max(mailbox.WarmupDailyIncrease, 0)
+	if limit <= 0 || warmup < limit {
+		return warmup
+	}
+	return limit
+}
+
+func nextDay(now time.Time) time.Time {
+	return utils.StartOfDayInUTC(now.UTC()).AddDate(0, 0, 1)
+}
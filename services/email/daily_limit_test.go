@@ -0,0 +1,44 @@
+package email
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/customeros/mailstack/internal/models"
+)
+
+func TestDailySendLimit(t *testing.T) {
+	created := time.Date(2025, 3, 10, 18, 0, 0, 0, time.UTC)
+
+	t.Run("first day starts the warm-up", func(t *testing.T) {
+		mailbox := &models.Mailbox{DailySendQuota: 100, WarmupStartLimit: 20, WarmupDailyIncrease: 10, CreatedAt: created}
+		assert.Equal(t, 20, dailySendLimit(mailbox, created.Add(time.Hour)))
+	})
+
+	t.Run("grows per calendar day", func(t *testing.T) {
+		mailbox := &models.Mailbox{DailySendQuota: 100, WarmupStartLimit: 20, WarmupDailyIncrease: 10, CreatedAt: created}
+		assert.Equal(t, 30, dailySendLimit(mailbox, time.Date(2025, 3, 11, 1, 0, 0, 0, time.UTC)))
+		assert.Equal(t, 50, dailySendLimit(mailbox, time.Date(2025, 3, 13, 23, 0, 0, 0, time.UTC)))
+	})
+
+	t.Run("capped by the daily quota", func(t *testing.T) {
+		mailbox := &models.Mailbox{DailySendQuota: 100, WarmupStartLimit: 20, WarmupDailyIncrease: 10, CreatedAt: created}
+		assert.Equal(t, 100, dailySendLimit(mailbox, created.AddDate(0, 1, 0)))
+	})
+
+	t.Run("warm-up disabled", func(t *testing.T) {
+		mailbox := &models.Mailbox{DailySendQuota: 100, CreatedAt: created}
+		assert.Equal(t, 100, dailySendLimit(mailbox, created))
+	})
+
+	t.Run("unlimited", func(t *testing.T) {
+		assert.Equal(t, 0, dailySendLimit(&models.Mailbox{CreatedAt: created}, created))
+	})
+}
+
+func TestNextDay(t *testing.T) {
+	now := time.Date(2025, 3, 10, 18, 30, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2025, 3, 11, 0, 0, 0, 0, time.UTC), nextDay(now))
+}
@@ -0,0 +1,62 @@
+package email
+
+import (
+	"context"
+
+	"github.com/opentracing/opentracing-go"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/enum"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+	"github.com/customeros/mailstack/services/smtp"
+)
+
+// PreviewEmail renders an outbound email through the SMTP message pipeline without sending it.
+// The email, its send attempts and the daily send counts are left untouched.
+func (s *emailService) PreviewEmail(ctx context.Context, emailID string) (*interfaces.EmailPreview, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailService.PreviewEmail")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.SetTag("email.id", emailID)
+
+	email, err := s.repositories.EmailRepository.GetByID(ctx, emailID)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+	if email == nil {
+		tracing.TraceErr(span, ErrEmailNotFound)
+		return nil, ErrEmailNotFound
+	}
+
+	// emails of other tenants are reported as not found
+	mailbox, err := s.repositories.MailboxRepository.GetMailbox(ctx, email.MailboxID)
+	if err != nil || mailbox == nil || mailbox.Tenant != utils.GetTenantFromContext(ctx) {
+		tracing.TraceErr(span, ErrEmailNotFound)
+		return nil, ErrEmailNotFound
+	}
+
+	if email.Direction != enum.EmailDirectionOutbound {
+		tracing.TraceErr(span, ErrEmailNotOutbound)
+		return nil, ErrEmailNotOutbound
+	}
+
+	var attachments []*models.EmailAttachment
+	if email.HasAttachment {
+		attachments, err = s.repositories.EmailAttachmentRepository.ListByEmail(ctx, email.ID)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return nil, err
+		}
+	}
+
+	preview, err := smtp.NewSMTPClient(s.repositories, mailbox, s.smtpConfig, nil).Preview(ctx, email, attachments)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	return preview, nil
+}
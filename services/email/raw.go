@@ -0,0 +1,75 @@
+package email
+
+import (
+	"context"
+
+	"github.com/opentracing/opentracing-go"
+
+	mailstack_errors "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+// GetRawEmail returns the original RFC 822 source of an email. The source is served from
+// attachment storage when it was stored, otherwise it is fetched from the IMAP server and stored.
+// Emails that are not on the server, e.g. sent emails not appended to the sent folder, have no source.
+func (s *emailService) GetRawEmail(ctx context.Context, emailID string) ([]byte, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailService.GetRawEmail")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.SetTag("email.id", emailID)
+
+	email, err := s.repositories.EmailRepository.GetByID(ctx, emailID)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+	if email == nil {
+		tracing.TraceErr(span, ErrEmailNotFound)
+		return nil, ErrEmailNotFound
+	}
+
+	// emails of other tenants are reported as not found
+	mailbox, err := s.repositories.MailboxRepository.GetMailbox(ctx, email.MailboxID)
+	if err != nil || mailbox == nil || mailbox.Tenant != utils.GetTenantFromContext(ctx) {
+		tracing.TraceErr(span, ErrEmailNotFound)
+		return nil, ErrEmailNotFound
+	}
+
+	if email.RawKey != "" {
+		raw, err := s.repositories.EmailRawRepository.Get(ctx, email.RawKey)
+		if err == nil {
+			span.LogKV("source", "storage")
+			return raw, nil
+		}
+		// the server still has the source
+		tracing.TraceErr(span, err)
+	}
+
+	if email.ImapUID == 0 || email.Folder == "" {
+		tracing.TraceErr(span, mailstack_errors.ErrMessageNotOnServer)
+		return nil, mailstack_errors.ErrMessageNotOnServer
+	}
+
+	raw, err := s.imapService.FetchRaw(ctx, mailbox.ID, email.Folder, email.ImapUID, email.MessageID)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+	// the UID may have been reused for another message, which must not be served or stored as this one
+	if !utils.RawHasMessageID(raw, email.MessageID) {
+		tracing.TraceErr(span, mailstack_errors.ErrMessageNotOnServer)
+		return nil, mailstack_errors.ErrMessageNotOnServer
+	}
+	span.LogKV("source", "imap")
+
+	key, err := s.repositories.EmailRawRepository.Save(ctx, email.ID, raw)
+	if err == nil {
+		err = s.repositories.EmailRepository.SetRawKey(ctx, email.ID, key)
+	}
+	if err != nil {
+		tracing.TraceErr(span, err)
+	}
+
+	return raw, nil
+}
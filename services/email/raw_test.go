@@ -0,0 +1,128 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/interfaces"
+	mailstack_errors "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/repository"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+type fakeEmailRepository struct {
+	interfaces.EmailRepository
+	emails map[string]*models.Email
+}
+
+func (r *fakeEmailRepository) GetByID(_ context.Context, id string) (*models.Email, error) {
+	return r.emails[id], nil
+}
+
+func (r *fakeEmailRepository) SetRawKey(_ context.Context, emailID, rawKey string) error {
+	r.emails[emailID].RawKey = rawKey
+	return nil
+}
+
+type fakeMailboxRepository struct {
+	interfaces.MailboxRepository
+	mailboxes map[string]*models.Mailbox
+}
+
+func (r *fakeMailboxRepository) GetMailbox(_ context.Context, id string) (*models.Mailbox, error) {
+	return r.mailboxes[id], nil
+}
+
+type fakeRawRepository struct {
+	raw map[string][]byte
+}
+
+func (r *fakeRawRepository) Get(_ context.Context, key string) ([]byte, error) {
+	raw, ok := r.raw[key]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return raw, nil
+}
+
+func (r *fakeRawRepository) Save(_ context.Context, emailID string, raw []byte) (string, error) {
+	key := "raw/" + emailID + ".eml"
+	r.raw[key] = raw
+	return key, nil
+}
+
+func (r *fakeRawRepository) Delete(_ context.Context, key string) error {
+	delete(r.raw, key)
+	return nil
+}
+
+type fakeIMAPService struct {
+	interfaces.IMAPService
+	fetches int
+}
+
+func (s *fakeIMAPService) FetchRaw(_ context.Context, mailboxID, folderName string, uid uint32, messageID string) ([]byte, error) {
+	s.fetches++
+	if uid == 43 {
+		return []byte("Message-ID: <other@acme.com>\r\nSubject: Other\r\n\r\nHi"), nil
+	}
+	return []byte("Message-ID: <hello@acme.com>\r\nSubject: Hello\r\n\r\nHi"), nil
+}
+
+func TestGetRawEmail(t *testing.T) {
+	imapService := &fakeIMAPService{}
+	emails := &fakeEmailRepository{emails: map[string]*models.Email{
+		"email_1": {ID: "email_1", MailboxID: "mbox_1", Folder: "INBOX", ImapUID: 42, MessageID: "hello@acme.com"},
+		"email_2": {ID: "email_2", MailboxID: "mbox_1"},
+		"email_3": {ID: "email_3", MailboxID: "mbox_1", Folder: "INBOX", ImapUID: 43, MessageID: "moved@acme.com"},
+	}}
+	service := &emailService{
+		imapService: imapService,
+		repositories: &repository.Repositories{
+			EmailRepository: emails,
+			MailboxRepository: &fakeMailboxRepository{mailboxes: map[string]*models.Mailbox{
+				"mbox_1": {ID: "mbox_1", Tenant: "acme"},
+			}},
+			EmailRawRepository: &fakeRawRepository{raw: map[string][]byte{}},
+		},
+	}
+	ctx := utils.SetTenantInContext(context.Background(), "acme")
+
+	t.Run("fetched from the server once, then stored", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			raw, err := service.GetRawEmail(ctx, "email_1")
+			require.NoError(t, err)
+			assert.Equal(t, "Message-ID: <hello@acme.com>\r\nSubject: Hello\r\n\r\nHi", string(raw))
+		}
+		assert.Equal(t, 1, imapService.fetches)
+		assert.Equal(t, "raw/email_1.eml", emails.emails["email_1"].RawKey)
+	})
+
+	t.Run("stored key that is gone falls back to the server", func(t *testing.T) {
+		emails.emails["email_1"].RawKey = "raw/missing.eml"
+		_, err := service.GetRawEmail(ctx, "email_1")
+		require.NoError(t, err)
+		assert.Equal(t, 2, imapService.fetches)
+	})
+
+	t.Run("email not stored on the server", func(t *testing.T) {
+		_, err := service.GetRawEmail(ctx, "email_2")
+		assert.ErrorIs(t, err, mailstack_errors.ErrMessageNotOnServer)
+	})
+
+	t.Run("uid holding another message", func(t *testing.T) {
+		_, err := service.GetRawEmail(ctx, "email_3")
+		assert.ErrorIs(t, err, mailstack_errors.ErrMessageNotOnServer)
+		assert.Empty(t, emails.emails["email_3"].RawKey)
+	})
+
+	t.Run("email of another tenant", func(t *testing.T) {
+		_, err := service.GetRawEmail(utils.SetTenantInContext(context.Background(), "other"), "email_1")
+		assert.ErrorIs(t, err, ErrEmailNotFound)
+	})
+}
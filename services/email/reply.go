@@ -0,0 +1,275 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	tracingLog "github.com/opentracing/opentracing-go/log"
+
+	"github.com/customeros/mailstack/internal/enum"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+const quoteDateLayout = "Mon, 2 Jan 2006 at 15:04"
+
+// ScheduleReply sends a reply, reply-all or forward of an existing email in the same thread.
+// Subject, recipients and threading headers are derived from the original and the original
+// body is quoted below the new one. Recipients set on email override the inherited ones.
+func (s *emailService) ScheduleReply(ctx context.Context, originalEmailID string, mode enum.ReplyMode, email *models.Email, attachmentIDs []string) (string, enum.EmailStatus, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailService.ScheduleReply")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.LogFields(tracingLog.String("originalEmailId", originalEmailID), tracingLog.String("mode", mode.String()))
+
+	original, err := s.repositories.EmailRepository.GetByID(ctx, originalEmailID)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return "", enum.EmailStatusFailed, err
+	}
+	if original == nil {
+		tracing.TraceErr(span, ErrEmailNotFound)
+		return "", enum.EmailStatusFailed, ErrEmailNotFound
+	}
+
+	// emails of other tenants are reported as not found
+	mailbox, err := s.repositories.MailboxRepository.GetMailbox(ctx, original.MailboxID)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return "", enum.EmailStatusFailed, err
+	}
+	if mailbox == nil || mailbox.Tenant != utils.GetTenantFromContext(ctx) {
+		tracing.TraceErr(span, ErrEmailNotFound)
+		return "", enum.EmailStatusFailed, ErrEmailNotFound
+	}
+
+	email.MailboxID = mailbox.ID
+	if email.FromAddress == "" {
+		email.FromAddress = mailbox.EmailAddress
+	}
+	buildReply(email, original, mode, mailbox.EmailAddress)
+
+	_, err = s.validateEmail(ctx, email, attachmentIDs)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return "", enum.EmailStatusFailed, err
+	}
+
+	setDefaultSendingValues(email, mailbox)
+	email.ThreadID = original.ThreadID
+
+	emailID, err := s.queueEmail(ctx, email)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return "", enum.EmailStatusFailed, err
+	}
+
+	// the reply is sent already, a stale thread summary is not worth failing it
+	if err = s.updateThreadForReply(ctx, email); err != nil {
+		tracing.TraceErr(span, err)
+	}
+
+	return emailID, email.Status, nil
+}
+
+func (s *emailService) updateThreadForReply(ctx context.Context, email *models.Email) error {
+	if email.ThreadID == "" {
+		return nil
+	}
+
+	thread, err := s.repositories.EmailThreadRepository.GetByID(ctx, email.ThreadID)
+	if err != nil {
+		return err
+	}
+
+	thread.LastMessageID = email.MessageID
+	thread.LastMessageAt = utils.NowPtr()
+	thread.HasAttachments = thread.HasAttachments || email.HasAttachment
+	for _, participant := range email.AllParticipants() {
+		if !containsAddress(thread.Participants, participant) {
+			thread.Participants = append(thread.Participants, participant)
+		}
+	}
+	if thread.ParticipantRoles == nil {
+		thread.ParticipantRoles = models.ThreadParticipants{}
+	}
+	thread.ParticipantRoles.Record(email)
+
+	return s.repositories.EmailThreadRepository.Update(ctx, thread)
+}
+
+// buildReply fills the reply subject, recipients, threading headers and quoted body from the original
+func buildReply(email, original *models.Email, mode enum.ReplyMode, mailboxAddress string) {
+	if email.Subject == "" {
+		subject := original.Subject
+		if subject == "" {
+			subject = original.CleanSubject
+		}
+		if mode == enum.ReplyModeForward {
+			email.Subject = prefixSubject("Fwd:", subject)
+		} else {
+			email.Subject = prefixSubject("Re:", subject)
+		}
+	}
+
+	if mode != enum.ReplyModeForward {
+		to, cc := replyRecipients(original, mode == enum.ReplyModeReplyAll, mailboxAddress)
+		if len(email.ToAddresses) == 0 {
+			email.ToAddresses = to
+		}
+		if len(email.CcAddresses) == 0 {
+			email.CcAddresses = cc
+		}
+	}
+
+	// forwards keep the threading headers too, so the forward shows up in the same conversation
+	if messageID := utils.FormatMessageID(original.MessageID); messageID != "" {
+		email.InReplyTo = messageID
+		for _, reference := range original.References {
+			if reference = utils.FormatMessageID(reference); reference != "" && !utils.IsStringInSlice(reference, email.References) {
+				email.References = append(email.References, reference)
+			}
+		}
+		if !utils.IsStringInSlice(messageID, email.References) {
+			email.References = append(email.References, messageID)
+		}
+	}
+
+	if email.BodyText != "" {
+		email.BodyText = email.BodyText + "\n\n" + quoteText(original, mode)
+	}
+	if email.BodyHTML != "" {
+		email.BodyHTML = email.BodyHTML + "<br>" + quoteHTML(original, mode)
+	}
+}
+
+// replyRecipients answers the sender, or the original recipients when replying to an email we sent.
+// Reply-all adds everyone else on the original as cc, except the mailbox itself.
+func replyRecipients(original *models.Email, all bool, mailboxAddress string) ([]string, []string) {
+	var to []string
+	switch {
+	case original.Direction == enum.EmailDirectionOutbound:
+		to = append(to, original.ToAddresses...)
+	case original.ReplyTo != "":
+		to = append(to, original.ReplyTo)
+	default:
+		to = append(to, original.FromAddress)
+	}
+
+	var cc []string
+	if !all {
+		return to, cc
+	}
+	others := append([]string{}, original.CcAddresses...)
+	if original.Direction != enum.EmailDirectionOutbound {
+		others = append(append([]string{}, original.ToAddresses...), others...)
+	}
+	for _, address := range others {
+		if strings.EqualFold(address, mailboxAddress) || containsAddress(to, address) || containsAddress(cc, address) {
+			continue
+		}
+		cc = append(cc, address)
+	}
+	return to, cc
+}
+
+func prefixSubject(prefix, subject string) string {
+	if strings.HasPrefix(strings.ToLower(strings.TrimSpace(subject)), strings.ToLower(prefix)) {
+		return subject
+	}
+	return prefix + " " + subject
+}
+
+func quoteText(original *models.Email, mode enum.ReplyMode) string {
+	body := original.BodyText
+	if body == "" {
+		body = original.VisibleText
+	}
+
+	if mode == enum.ReplyModeForward {
+		return forwardHeader(original, "\n") + "\n\n" + body
+	}
+
+	lines := strings.Split(strings.TrimRight(body, "\n"), "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ">") {
+			lines[i] = ">" + line
+		} else {
+			lines[i] = "> " + line
+		}
+	}
+	return quoteAttribution(original) + "\n" + strings.Join(lines, "\n")
+}
+
+func quoteHTML(original *models.Email, mode enum.ReplyMode) string {
+	body := original.BodyHTML
+	if body == "" {
+		body = strings.ReplaceAll(html.EscapeString(original.BodyText), "\n", "<br>")
+	}
+
+	if mode == enum.ReplyModeForward {
+		return `<div class="mailstack_quote">` + forwardHeader(original, "<br>") + "<br><br>" + body + "</div>"
+	}
+	return `<div class="mailstack_quote">` + html.EscapeString(quoteAttribution(original)) + "<br>" +
+		`<blockquote style="margin:0 0 0 .8ex;border-left:1px solid #ccc;padding-left:1ex">` + body + "</blockquote></div>"
+}
+
+func quoteAttribution(original *models.Email) string {
+	if sentAt := originalTime(original); sentAt != nil {
+		return fmt.Sprintf("On %s, %s wrote:", sentAt.Format(quoteDateLayout), formatSender(original))
+	}
+	return fmt.Sprintf("%s wrote:", formatSender(original))
+}
+
+// forwardHeader lists the original sender, date, subject and recipients, lines joined by separator.
+// Values are escaped when the separator is html.
+func forwardHeader(original *models.Email, separator string) string {
+	escape := func(value string) string { return value }
+	if separator == "<br>" {
+		escape = html.EscapeString
+	}
+
+	lines := []string{
+		"---------- Forwarded message ---------",
+		"From: " + escape(formatSender(original)),
+	}
+	if sentAt := originalTime(original); sentAt != nil {
+		lines = append(lines, "Date: "+sentAt.Format(quoteDateLayout))
+	}
+	lines = append(lines,
+		"Subject: "+escape(original.Subject),
+		"To: "+escape(strings.Join(original.ToAddresses, ", ")),
+	)
+	if len(original.CcAddresses) > 0 {
+		lines = append(lines, "Cc: "+escape(strings.Join(original.CcAddresses, ", ")))
+	}
+	return strings.Join(lines, separator)
+}
+
+func formatSender(email *models.Email) string {
+	if email.FromName != "" {
+		return fmt.Sprintf("%s <%s>", email.FromName, email.FromAddress)
+	}
+	return email.FromAddress
+}
+
+func originalTime(email *models.Email) *time.Time {
+	if email.SentAt != nil {
+		return email.SentAt
+	}
+	return email.ReceivedAt
+}
+
+func containsAddress(addresses []string, address string) bool {
+	for _, a := range addresses {
+		if strings.EqualFold(a, address) {
+			return true
+		}
+	}
+	return false
+}
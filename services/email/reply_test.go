@@ -0,0 +1,88 @@
+package email
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/customeros/mailstack/internal/enum"
+	"github.com/customeros/mailstack/internal/models"
+)
+
+func TestBuildReply(t *testing.T) {
+	sentAt := time.Date(2025, 3, 10, 12, 30, 0, 0, time.UTC)
+	original := func() *models.Email {
+		return &models.Email{
+			Direction:   enum.EmailDirectionInbound,
+			MessageID:   "abc@example.com",
+			References:  []string{"root@example.com"},
+			Subject:     "Pricing",
+			FromName:    "Jane",
+			FromAddress: "jane@example.com",
+			ToAddresses: []string{"me@mailstack.io", "bob@example.com"},
+			CcAddresses: []string{"carol@example.com"},
+			BodyText:    "first line\n> older",
+			SentAt:      &sentAt,
+		}
+	}
+
+	t.Run("reply", func(t *testing.T) {
+		email := &models.Email{BodyText: "Thanks"}
+		buildReply(email, original(), enum.ReplyModeReply, "me@mailstack.io")
+
+		assert.Equal(t, "Re: Pricing", email.Subject)
+		assert.Equal(t, []string{"jane@example.com"}, []string(email.ToAddresses))
+		assert.Empty(t, email.CcAddresses)
+		assert.Equal(t, "<abc@example.com>", email.InReplyTo)
+		assert.Equal(t, []string{"<root@example.com>", "<abc@example.com>"}, []string(email.References))
+		assert.Equal(t, "Thanks\n\nOn Mon, 10 Mar 2025 at 12:30, Jane <jane@example.com> wrote:\n> first line\n>> older", email.BodyText)
+		assert.Empty(t, email.BodyHTML)
+	})
+
+	t.Run("reply goes to reply-to", func(t *testing.T) {
+		o := original()
+		o.ReplyTo = "sales@example.com"
+		email := &models.Email{BodyText: "Thanks"}
+		buildReply(email, o, enum.ReplyModeReply, "me@mailstack.io")
+		assert.Equal(t, []string{"sales@example.com"}, []string(email.ToAddresses))
+	})
+
+	t.Run("reply all skips the mailbox", func(t *testing.T) {
+		email := &models.Email{BodyText: "Thanks"}
+		buildReply(email, original(), enum.ReplyModeReplyAll, "ME@mailstack.io")
+		assert.Equal(t, []string{"jane@example.com"}, []string(email.ToAddresses))
+		assert.Equal(t, []string{"bob@example.com", "carol@example.com"}, []string(email.CcAddresses))
+	})
+
+	t.Run("reply to own email answers its recipients", func(t *testing.T) {
+		o := original()
+		o.Direction = enum.EmailDirectionOutbound
+		o.FromAddress = "me@mailstack.io"
+		o.ToAddresses = []string{"jane@example.com"}
+		email := &models.Email{BodyText: "Following up"}
+		buildReply(email, o, enum.ReplyModeReplyAll, "me@mailstack.io")
+		assert.Equal(t, []string{"jane@example.com"}, []string(email.ToAddresses))
+		assert.Equal(t, []string{"carol@example.com"}, []string(email.CcAddresses))
+	})
+
+	t.Run("subject prefix is not repeated", func(t *testing.T) {
+		o := original()
+		o.Subject = "RE: Pricing"
+		email := &models.Email{BodyText: "Thanks"}
+		buildReply(email, o, enum.ReplyModeReply, "me@mailstack.io")
+		assert.Equal(t, "RE: Pricing", email.Subject)
+	})
+
+	t.Run("forward keeps requested recipients", func(t *testing.T) {
+		email := &models.Email{ToAddresses: []string{"dan@example.com"}, BodyHTML: "<p>FYI</p>"}
+		buildReply(email, original(), enum.ReplyModeForward, "me@mailstack.io")
+
+		assert.Equal(t, "Fwd: Pricing", email.Subject)
+		assert.Equal(t, []string{"dan@example.com"}, []string(email.ToAddresses))
+		assert.Empty(t, email.CcAddresses)
+		assert.Equal(t, "<abc@example.com>", email.InReplyTo)
+		assert.Contains(t, email.BodyHTML, "---------- Forwarded message ---------<br>From: Jane &lt;jane@example.com&gt;")
+		assert.Contains(t, email.BodyHTML, "first line<br>&gt; older")
+	})
+}
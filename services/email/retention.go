@@ -0,0 +1,118 @@
+package email
+
+import (
+	"context"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+// purgeBatchSize is how many emails a purge removes per transaction
+const purgeBatchSize = 500
+
+// EnforceRetention purges the mail of each tenant with a retention policy that was sent or
+// received before its window, with the threads left empty and the attachment files no other
+// email uses. A failing tenant does not stop the others.
+func (s *emailService) EnforceRetention(ctx context.Context) (interfaces.RetentionReport, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailService.EnforceRetention")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+
+	report := interfaces.RetentionReport{Tenants: map[string]interfaces.PurgeReport{}}
+	policies, err := s.repositories.TenantRetentionPolicyRepository.List(ctx)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return report, err
+	}
+
+	for _, policy := range policies {
+		if policy.RetentionDays <= 0 {
+			continue
+		}
+		before := utils.Now().AddDate(0, 0, -policy.RetentionDays)
+		tenantReport, err := s.purgeInBatches(ctx, func(ctx context.Context) (*interfaces.PurgedEmails, error) {
+			return s.repositories.EmailRepository.PurgeExpired(ctx, policy.Tenant, before, purgeBatchSize)
+		})
+		// a failed tenant still reports the batches purged before the failure
+		report.Tenants[policy.Tenant] = tenantReport
+		if err != nil {
+			tracing.TraceErr(span, err)
+			report.Failed = append(report.Failed, policy.Tenant)
+		}
+	}
+
+	span.LogFields(
+		log.Int("tenants.enforced", len(report.Tenants)),
+		log.Int("tenants.failed", len(report.Failed)),
+	)
+	return report, nil
+}
+
+// purgeInBatches calls purge until it removes less than a batch. The storage objects of each
+// batch are deleted after its transaction committed, so a failure never leaves rows pointing at
+// deleted files, only files nothing points at.
+func (s *emailService) purgeInBatches(ctx context.Context, purge func(ctx context.Context) (*interfaces.PurgedEmails, error)) (interfaces.PurgeReport, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailService.purgeInBatches")
+	defer span.Finish()
+
+	var report interfaces.PurgeReport
+	for {
+		purged, err := purge(ctx)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return report, err
+		}
+		report.Emails += len(purged.Emails)
+		report.Threads += purged.Threads
+		report.Attachments += len(purged.Attachments)
+		s.deletePurgedFiles(ctx, purged, &report)
+
+		if len(purged.Emails) < purgeBatchSize {
+			return report, nil
+		}
+	}
+}
+
+// deletePurgedFiles removes the stored sources and attachment files of purged rows
+func (s *emailService) deletePurgedFiles(ctx context.Context, purged *interfaces.PurgedEmails, report *interfaces.PurgeReport) {
+	span := opentracing.SpanFromContext(ctx)
+
+	for _, email := range purged.Emails {
+		if email.RawKey == "" {
+			continue
+		}
+		// the row is gone already, a source left behind is only wasted storage
+		if err := s.repositories.EmailRawRepository.Delete(ctx, email.RawKey); err != nil {
+			tracing.TraceErr(span, err)
+			continue
+		}
+		report.StorageObjects++
+		report.BytesReclaimed += int64(email.MessageSizeBytes)
+	}
+
+	for _, attachment := range purged.Attachments {
+		if attachment.StorageKey == "" {
+			continue
+		}
+		if err := s.repositories.EmailAttachmentRepository.DeleteFiles(ctx, attachment); err != nil {
+			tracing.TraceErr(span, err)
+			continue
+		}
+		report.StorageObjects++
+		report.BytesReclaimed += int64(attachment.Size)
+	}
+}
+
+func logPurgeReport(span opentracing.Span, report interfaces.PurgeReport) {
+	span.LogFields(
+		log.Int("emails.purged", report.Emails),
+		log.Int64("threads.purged", report.Threads),
+		log.Int("attachments.purged", report.Attachments),
+		log.Int("storage.objects.deleted", report.StorageObjects),
+		log.Int64("storage.bytes.reclaimed", report.BytesReclaimed),
+	)
+}
@@ -0,0 +1,92 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/repository"
+)
+
+type fakeRetentionPolicyRepository struct {
+	interfaces.TenantRetentionPolicyRepository
+	policies []models.TenantRetentionPolicy
+}
+
+func (r *fakeRetentionPolicyRepository) List(context.Context) ([]models.TenantRetentionPolicy, error) {
+	return r.policies, nil
+}
+
+type fakeExpiringEmailRepository struct {
+	interfaces.EmailRepository
+	expired map[string]*interfaces.PurgedEmails
+	before  map[string]time.Time
+}
+
+func (r *fakeExpiringEmailRepository) PurgeExpired(_ context.Context, tenant string, before time.Time, limit int) (*interfaces.PurgedEmails, error) {
+	if tenant == "broken" {
+		return nil, errors.New("connection reset")
+	}
+	r.before[tenant] = before
+	purged := r.expired[tenant]
+	if purged == nil {
+		purged = &interfaces.PurgedEmails{}
+	}
+	delete(r.expired, tenant)
+	return purged, nil
+}
+
+type fakeAttachmentRepository struct {
+	interfaces.EmailAttachmentRepository
+	deleted []string
+}
+
+func (r *fakeAttachmentRepository) DeleteFiles(_ context.Context, attachment *models.EmailAttachment) error {
+	r.deleted = append(r.deleted, attachment.StorageKey)
+	return nil
+}
+
+func TestEnforceRetention(t *testing.T) {
+	emails := &fakeExpiringEmailRepository{
+		before: map[string]time.Time{},
+		expired: map[string]*interfaces.PurgedEmails{"acme": {
+			Emails: []*models.Email{
+				{ID: "email_1", RawKey: "raw/email_1.eml", MessageSizeBytes: 2048},
+				{ID: "email_2"},
+			},
+			Threads: 1,
+			Attachments: []*models.EmailAttachment{
+				{ID: "file_1", StorageKey: "pdf/file_1.pdf", Size: 4096},
+				{ID: "file_2"}, // metadata only, nothing stored
+			},
+		}},
+	}
+	raw := &fakeRawRepository{raw: map[string][]byte{"raw/email_1.eml": []byte("Subject: Hi\r\n\r\nHi")}}
+	attachments := &fakeAttachmentRepository{}
+	service := &emailService{repositories: &repository.Repositories{
+		EmailRepository:           emails,
+		EmailRawRepository:        raw,
+		EmailAttachmentRepository: attachments,
+		TenantRetentionPolicyRepository: &fakeRetentionPolicyRepository{policies: []models.TenantRetentionPolicy{
+			{Tenant: "acme", RetentionDays: 30},
+			{Tenant: "broken", RetentionDays: 30},
+			{Tenant: "keep", RetentionDays: 0},
+		}},
+	}}
+
+	report, err := service.EnforceRetention(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, interfaces.PurgeReport{Emails: 2, Threads: 1, Attachments: 2, StorageObjects: 2, BytesReclaimed: 6144}, report.Tenants["acme"])
+	assert.Equal(t, []string{"broken"}, report.Failed)
+	assert.NotContains(t, report.Tenants, "keep")
+	assert.WithinDuration(t, time.Now().AddDate(0, 0, -30), emails.before["acme"], time.Minute)
+	assert.Empty(t, raw.raw)
+	assert.Equal(t, []string{"pdf/file_1.pdf"}, attachments.deleted)
+}
@@ -2,12 +2,17 @@ package email
 
 import (
 	"context"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/customeros/mailsherpa/mailvalidate"
 	"github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
 
 	"github.com/customeros/mailstack/internal/enum"
+	mailstack_errors "github.com/customeros/mailstack/internal/errors"
 	"github.com/customeros/mailstack/internal/models"
 	"github.com/customeros/mailstack/internal/tracing"
 	"github.com/customeros/mailstack/internal/utils"
@@ -18,13 +23,13 @@ func (s *emailService) ScheduleSend(ctx context.Context, email *models.Email, at
 	defer span.Finish()
 	tracing.SetDefaultServiceSpanTags(ctx, span)
 
-	err := s.validateEmail(ctx, email, attachmentIDs)
+	mailbox, err := s.validateEmail(ctx, email, attachmentIDs)
 	if err != nil {
 		tracing.TraceErr(span, err)
 		return "", enum.EmailStatusFailed, err
 	}
 
-	setDefaultSendingValues(email)
+	setDefaultSendingValues(email, mailbox)
 
 	// create a new email thraed & attach email to it
 	err = s.createNewEmailThreadForEmail(ctx, email)
@@ -33,26 +38,33 @@ func (s *emailService) ScheduleSend(ctx context.Context, email *models.Email, at
 		return "", enum.EmailStatusFailed, err
 	}
 
-	// save email to db
+	emailID, err := s.queueEmail(ctx, email)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return "", enum.EmailStatusFailed, err
+	}
+
+	return emailID, email.Status, nil
+}
+
+// queueEmail saves the email and, unless it is scheduled for later, fires the event to send it now
+func (s *emailService) queueEmail(ctx context.Context, email *models.Email) (string, error) {
 	if email.ScheduledFor != nil {
 		email.Status = enum.EmailStatusScheduled
 	}
 	emailID, err := s.repositories.EmailRepository.Create(ctx, email)
 	if err != nil {
-		tracing.TraceErr(span, err)
-		return "", enum.EmailStatusFailed, err
+		return "", err
 	}
 
-	// if scheduleFor is empty, fire event to send now
 	if email.ScheduledFor == nil {
 		err = s.eventsService.Publisher.PublishSendEmailEvent(ctx, email)
 		if err != nil {
-			tracing.TraceErr(span, err)
-			return "", enum.EmailStatusFailed, err
+			return "", err
 		}
 	}
 
-	return emailID, email.Status, nil
+	return emailID, nil
 }
 
 func (s *emailService) createNewEmailThreadForEmail(ctx context.Context, email *models.Email) error {
@@ -60,14 +72,18 @@ func (s *emailService) createNewEmailThreadForEmail(ctx context.Context, email *
 	defer span.Finish()
 	tracing.SetDefaultServiceSpanTags(ctx, span)
 
+	participantRoles := models.ThreadParticipants{}
+	participantRoles.Record(email)
+
 	thread := &models.EmailThread{
-		MailboxID:      email.MailboxID,
-		Subject:        email.Subject,
-		Participants:   email.AllParticipants(),
-		LastMessageID:  email.MessageID,
-		HasAttachments: email.HasAttachment,
-		FirstMessageAt: utils.NowPtr(),
-		LastMessageAt:  utils.NowPtr(),
+		MailboxID:        email.MailboxID,
+		Subject:          email.Subject,
+		Participants:     email.AllParticipants(),
+		ParticipantRoles: participantRoles,
+		LastMessageID:    email.MessageID,
+		HasAttachments:   email.HasAttachment,
+		FirstMessageAt:   utils.NowPtr(),
+		LastMessageAt:    utils.NowPtr(),
 	}
 
 	threadID, err := s.repositories.EmailThreadRepository.Create(ctx, thread)
@@ -85,65 +101,59 @@ func (s *emailService) createNewEmailThreadForEmail(ctx context.Context, email *
 	return nil
 }
 
-func setDefaultSendingValues(email *models.Email) {
+// setDefaultSendingValues prepares the email to be queued. Its Message-ID is fixed from here on, so
+// the header and the References of later replies agree even if the mailbox setting changes.
+func setDefaultSendingValues(email *models.Email, mailbox *models.Mailbox) {
 	email.Direction = enum.EmailDirectionOutbound
 	email.Status = enum.EmailStatusQueued
-	email.MessageID = utils.GenerateMessageID(email.FromDomain, "")
+	email.MessageID = utils.GenerateMessageID(mailbox.MessageIDHost(email.FromDomain), "")
 	email.SendAttempts = 1
 }
 
-func (s *emailService) validateEmail(ctx context.Context, email *models.Email, attachmentIDs []string) error {
+// validateEmail checks the email can be sent and returns the mailbox it is sent from
+func (s *emailService) validateEmail(ctx context.Context, email *models.Email, attachmentIDs []string) (*models.Mailbox, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "emailService.validateEmail")
 	defer span.Finish()
 	tracing.SetDefaultServiceSpanTags(ctx, span)
 
-	err := s.validateSender(ctx, email)
+	mailbox, err := s.validateSender(ctx, email)
 	if err != nil {
 		tracing.TraceErr(span, err)
-		return err
-	}
-
-	// validate recipients are valid emails
-	err = validateRecipients(email)
-	if err != nil {
-		tracing.TraceErr(span, err)
-		return err
+		return nil, err
 	}
 
-	// validate body and subject
-	if email.Subject == "" {
-		err = ErrEmptySubject
+	// sender and content problems are reported together, so all of them can be fixed at once
+	problems := validateFrom(email, mailbox)
+	problems = append(problems, validateContent(email)...)
+	if err = problems.Err(); err != nil {
 		tracing.TraceErr(span, err)
-		return err
-	}
-	if email.BodyHTML == "" && email.BodyText == "" {
-		err = ErrEmptyEmailBody
-		tracing.TraceErr(span, err)
-		return err
+		return nil, err
 	}
 
 	// validate attachments
+	attachmentsSize := 0
 	if attachmentIDs != nil {
 		email.HasAttachment = true
-		for _, attachment := range attachmentIDs {
-			err := s.validateAttachment(ctx, attachment)
+		for _, attachmentID := range attachmentIDs {
+			attachment, err := s.validateAttachment(ctx, attachmentID)
 			if err != nil {
 				tracing.TraceErr(span, err)
-				return errors.Wrap(err, attachment)
+				return nil, errors.Wrap(err, attachmentID)
 			}
+			attachmentsSize += attachment.Size
 		}
 	}
 
-	// validate scheduledFor
-	if email.ScheduledFor != nil && !utils.IsInFuture(*email.ScheduledFor) {
-		err = ErrScheduledSendNotValid
+	// reject early what the SMTP client would refuse to send
+	if err = s.checkMessageSize(email, attachmentsSize); err != nil {
 		tracing.TraceErr(span, err)
-		return err
+		return nil, err
 	}
-	return nil
+
+	return mailbox, nil
 }
 
-func (s *emailService) validateAttachment(ctx context.Context, attachmentID string) error {
+func (s *emailService) validateAttachment(ctx context.Context, attachmentID string) (*models.EmailAttachment, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "emailService.validateAttachment")
 	defer span.Finish()
 	tracing.SetDefaultServiceSpanTags(ctx, span)
@@ -151,46 +161,160 @@ func (s *emailService) validateAttachment(ctx context.Context, attachmentID stri
 	attachment, err := s.repositories.EmailAttachmentRepository.GetByID(ctx, attachmentID)
 	if err != nil {
 		tracing.TraceErr(span, err)
-		return err
+		return nil, err
 	}
 	if attachment == nil {
 		err = ErrAttachmentDoesNotExist
 		tracing.TraceErr(span, err)
-		return err
+		return nil, err
+	}
+	return attachment, nil
+}
+
+// checkMessageSize rejects an email whose estimated size is over the SMTP message size limit
+func (s *emailService) checkMessageSize(email *models.Email, attachmentsSize int) error {
+	if s.smtpConfig == nil || s.smtpConfig.MaxMessageSizeBytes <= 0 {
+		return nil
+	}
+	estimatedSize := estimateMessageSize(email, attachmentsSize)
+	if estimatedSize > s.smtpConfig.MaxMessageSizeBytes {
+		return errors.Wrapf(mailstack_errors.ErrMessageTooLarge, "estimated %d bytes, limit %d", estimatedSize, s.smtpConfig.MaxMessageSizeBytes)
 	}
 	return nil
 }
 
-func validateRecipients(email *models.Email) error {
-	if len(email.ToAddresses) == 0 {
-		err := ErrRecipientsMissing
-		return err
+// estimateMessageSize approximates the MIME size: base64 grows attachments by 4/3,
+// plus a small allowance for headers and part boundaries
+func estimateMessageSize(email *models.Email, attachmentsSize int) int {
+	return len(email.BodyText) + len(email.BodyHTML) + attachmentsSize*4/3 + 4096
+}
+
+// validateContent collects the problems of recipients, subject, body, headers, unsubscribe options
+// and schedule
+func validateContent(email *models.Email) mailstack_errors.ValidationErrors {
+	var problems mailstack_errors.ValidationErrors
+
+	validateRecipients(email, &problems)
+
+	if email.Subject == "" {
+		problems.Add(mailstack_errors.ValidationNoSubject, "subject", ErrEmptySubject.Error(), ErrEmptySubject)
+	}
+	if email.BodyHTML == "" && email.BodyText == "" {
+		problems.Add(mailstack_errors.ValidationEmptyBody, "body", ErrEmptyEmailBody.Error(), ErrEmptyEmailBody)
 	}
 
-	for i := range email.ToAddresses {
-		err := ValidateEmailAddress(&email.ToAddresses[i])
-		if err != nil {
-			return errors.Wrap(err, email.ToAddresses[i])
+	validateHeaders(email, &problems)
+	validateUnsubscribe(email, &problems)
+
+	if email.ScheduledFor != nil && !utils.IsInFuture(*email.ScheduledFor) {
+		problems.Add(mailstack_errors.ValidationInvalidSchedule, "scheduledFor", ErrScheduledSendNotValid.Error(), ErrScheduledSendNotValid)
+	}
+
+	return problems
+}
+
+// validateHeaders checks the reply-to address and the custom headers, which go in RawHeaders. Custom
+// headers can not replace the ones derived from the email, nor break out of their line.
+func validateHeaders(email *models.Email, problems *mailstack_errors.ValidationErrors) {
+	if email.ReplyTo != "" {
+		if err := ValidateEmailAddress(&email.ReplyTo); err != nil {
+			problems.Add(mailstack_errors.ValidationInvalidHeader, "replyTo", email.ReplyTo+": "+err.Error(), err)
 		}
 	}
 
-	for i := range email.CcAddresses {
-		err := ValidateEmailAddress(&email.CcAddresses[i])
-		if err != nil {
-			return errors.Wrap(err, email.CcAddresses[i])
+	names := make([]string, 0, len(email.RawHeaders))
+	for name := range email.RawHeaders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value, ok := email.RawHeaders[name].(string)
+		switch {
+		case !isHeaderName(name):
+			problems.Add(mailstack_errors.ValidationInvalidHeader, "headers", ErrInvalidHeader.Error()+": invalid name "+strconv.Quote(name), ErrInvalidHeader)
+		case models.IsReservedHeader(name):
+			problems.Add(mailstack_errors.ValidationInvalidHeader, "headers", ErrInvalidHeader.Error()+": "+name+" is set from the email itself", ErrInvalidHeader)
+		case !ok || strings.ContainsAny(value, "\r\n"):
+			problems.Add(mailstack_errors.ValidationInvalidHeader, "headers", ErrInvalidHeader.Error()+": invalid value of "+name, ErrInvalidHeader)
 		}
 	}
+}
 
-	for i := range email.BccAddresses {
-		err := ValidateEmailAddress(&email.BccAddresses[i])
+// isHeaderName tells whether name is a header field name, printable ASCII without colon (RFC 5322)
+func isHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if name[i] < 33 || name[i] > 126 || name[i] == ':' {
+			return false
+		}
+	}
+	return true
+}
+
+func validateUnsubscribe(email *models.Email, problems *mailstack_errors.ValidationErrors) {
+	if email.UnsubscribeURL != "" {
+		parsed, err := url.Parse(email.UnsubscribeURL)
+		if err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+			problems.Add(mailstack_errors.ValidationInvalidUnsubscribe, "unsubscribeUrl",
+				ErrInvalidUnsubscribe.Error()+": "+email.UnsubscribeURL, ErrInvalidUnsubscribe)
+		}
+	}
+	if email.UnsubscribeMailto != "" {
+		err := ValidateEmailAddress(&email.UnsubscribeMailto)
 		if err != nil {
-			return errors.Wrap(err, email.BccAddresses[i])
+			problems.Add(mailstack_errors.ValidationInvalidUnsubscribe, "unsubscribeMailto",
+				ErrInvalidUnsubscribe.Error()+": "+email.UnsubscribeMailto, ErrInvalidUnsubscribe)
 		}
 	}
-	return nil
 }
 
-func (s *emailService) validateSender(ctx context.Context, email *models.Email) error {
+func validateRecipients(email *models.Email, problems *mailstack_errors.ValidationErrors) {
+	if len(email.ToAddresses) == 0 {
+		problems.Add(mailstack_errors.ValidationNoRecipients, "toAddresses", ErrRecipientsMissing.Error(), ErrRecipientsMissing)
+	}
+
+	fields := []struct {
+		name      string
+		addresses []string
+	}{
+		{"toAddresses", email.ToAddresses},
+		{"ccAddresses", email.CcAddresses},
+		{"bccAddresses", email.BccAddresses},
+	}
+	for _, field := range fields {
+		for i := range field.addresses {
+			if err := ValidateEmailAddress(&field.addresses[i]); err != nil {
+				problems.Add(mailstack_errors.ValidationInvalidRecipient, field.name, field.addresses[i]+": "+err.Error(), err)
+			}
+		}
+	}
+}
+
+// validateFrom collects the problems of the from address and name, and sets the user and domain of
+// a valid from address on the email
+func validateFrom(email *models.Email, mailbox *models.Mailbox) mailstack_errors.ValidationErrors {
+	var problems mailstack_errors.ValidationErrors
+
+	validateSender := mailvalidate.ValidateEmailSyntax(email.FromAddress)
+	if !validateSender.IsValid || validateSender.IsSystemGenerated || validateSender.IsFreeAccount {
+		problems.Add(mailstack_errors.ValidationInvalidFrom, "fromAddress", ErrInvalidSender.Error()+": "+email.FromAddress, ErrInvalidSender)
+	} else {
+		email.FromUser = validateSender.User
+		email.FromDomain = validateSender.Domain
+	}
+
+	// sender profile or sender info provided in request
+	if mailbox.SenderID == "" && email.FromName == "" {
+		problems.Add(mailstack_errors.ValidationMissingFrom, "fromName", ErrUnknownSender.Error()+": no sender profile and no from name", ErrUnknownSender)
+	}
+
+	return problems
+}
+
+// validateSender checks the sender may send from the mailbox of the email and returns the mailbox
+func (s *emailService) validateSender(ctx context.Context, email *models.Email) (*models.Mailbox, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "emailService.validateSender")
 	defer span.Finish()
 	tracing.SetDefaultServiceSpanTags(ctx, span)
@@ -199,12 +323,12 @@ func (s *emailService) validateSender(ctx context.Context, email *models.Email)
 	mailbox, err := s.getMailbox(ctx, email)
 	if err != nil {
 		tracing.TraceErr(span, err)
-		return err
+		return nil, err
 	}
 	if mailbox == nil {
 		err = ErrMailboxDoesNotExist
 		tracing.TraceErr(span, err)
-		return err
+		return nil, err
 	}
 	email.MailboxID = mailbox.ID
 
@@ -214,52 +338,34 @@ func (s *emailService) validateSender(ctx context.Context, email *models.Email)
 		tracing.TraceErr(span, ErrUnauthorizedSender)
 		span.LogKV("mailboxTenant", mailbox.Tenant)
 		span.LogKV("ctxTenant", tenant)
-		return ErrUnauthorizedSender
+		return nil, ErrUnauthorizedSender
 	}
 	userID := utils.GetUserIdFromContext(ctx)
 	if mailbox.UserID != userID {
 		tracing.TraceErr(span, ErrUnauthorizedSender)
 		span.LogKV("mailboxUserId", mailbox.UserID)
 		span.LogKV("ctxTenant", userID)
-		return ErrUnauthorizedSender
+		return nil, ErrUnauthorizedSender
 	}
 
 	// validate outbound enabled
 	if !mailbox.OutboundEnabled {
 		err = ErrOutboundNotEnabled
 		tracing.TraceErr(span, err)
-		return err
-	}
-
-	// validate sender email and set user and domain on email
-	validateSender := mailvalidate.ValidateEmailSyntax(email.FromAddress)
-	if !validateSender.IsValid || validateSender.IsSystemGenerated || validateSender.IsFreeAccount {
-		err = ErrInvalidSender
-		tracing.TraceErr(span, err)
-		return err
-	}
-	email.FromUser = validateSender.User
-	email.FromDomain = validateSender.Domain
-
-	// validate sender profile exists or sender info provided in request
-	if mailbox.SenderID == "" && email.FromName == "" {
-		err = ErrUnknownSender
-		tracing.TraceErr(span, err)
-		span.LogKV("noSenderProfile", true)
-		return err
+		return nil, err
 	}
 
 	err = s.buildEmailSender(ctx, email, mailbox)
 	if err != nil {
 		tracing.TraceErr(span, err)
-		return err
+		return nil, err
 	}
 
 	if mailbox.ReplyToAddress != "" && email.ReplyTo == "" {
 		email.ReplyTo = mailbox.ReplyToAddress
 	}
 
-	return nil
+	return mailbox, nil
 }
 
 func (s *emailService) getMailbox(ctx context.Context, email *models.Email) (*models.Mailbox, error) {
@@ -0,0 +1,166 @@
+package email
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/internal/config"
+	"github.com/customeros/mailstack/internal/enum"
+	mailstack_errors "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/models"
+)
+
+func TestValidateContent(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		email := &models.Email{ToAddresses: []string{"jane@example.com"}, Subject: "Hi", BodyText: "Hello"}
+		assert.NoError(t, validateContent(email).Err())
+	})
+
+	t.Run("reports every problem", func(t *testing.T) {
+		past := time.Now().Add(-time.Hour)
+		email := &models.Email{
+			CcAddresses:    []string{"not-an-address"},
+			UnsubscribeURL: "http://example.com/unsubscribe",
+			ScheduledFor:   &past,
+		}
+
+		problems := validateContent(email)
+		codes := make([]mailstack_errors.ValidationCode, 0, len(problems))
+		for _, problem := range problems {
+			codes = append(codes, problem.Code)
+		}
+		assert.Equal(t, []mailstack_errors.ValidationCode{
+			mailstack_errors.ValidationNoRecipients,
+			mailstack_errors.ValidationInvalidRecipient,
+			mailstack_errors.ValidationNoSubject,
+			mailstack_errors.ValidationEmptyBody,
+			mailstack_errors.ValidationInvalidUnsubscribe,
+			mailstack_errors.ValidationInvalidSchedule,
+		}, codes)
+		assert.Equal(t, "ccAddresses", problems[1].Field)
+
+		err := problems.Err()
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrEmptySubject))
+		assert.True(t, errors.Is(err, ErrInvalidEmail))
+		found, ok := mailstack_errors.AsValidationErrors(errors.Wrap(err, "reply"))
+		assert.True(t, ok)
+		assert.Len(t, found, 6)
+	})
+	t.Run("headers", func(t *testing.T) {
+		email := &models.Email{ToAddresses: []string{"jane@example.com"}, Subject: "Hi", BodyText: "Hello", ReplyTo: "sales@example.com"}
+		email.SetCustomHeaders(map[string]string{"X-Campaign-ID": "q4-launch", "List-ID": "<news.example.com>"})
+		require.NoError(t, validateContent(email).Err())
+
+		email.ReplyTo = "not-an-address"
+		email.SetCustomHeaders(map[string]string{
+			"message-id":  "<forged@example.com>",
+			"DATE":        "Mon, 1 Jan 2024 00:00:00 +0000",
+			"X-Bad Name":  "value",
+			"X-Injection": "value\r\nBcc: eve@example.com",
+		})
+		problems := validateContent(email)
+		require.Len(t, problems, 5)
+		for _, problem := range problems {
+			assert.Equal(t, mailstack_errors.ValidationInvalidHeader, problem.Code)
+		}
+		assert.Equal(t, "replyTo", problems[0].Field)
+		assert.Contains(t, problems[1].Message, "DATE is set from the email itself")
+		assert.Contains(t, problems[2].Message, `invalid name "X-Bad Name"`)
+		assert.Contains(t, problems[3].Message, "invalid value of X-Injection")
+		assert.Contains(t, problems[4].Message, "message-id is set from the email itself")
+		assert.True(t, errors.Is(problems.Err(), ErrInvalidHeader))
+	})
+}
+
+func TestSetDefaultSendingValuesMessageIDHost(t *testing.T) {
+	mailbox := &models.Mailbox{MailboxDomain: "acme.io"}
+
+	email := &models.Email{FromDomain: "acme.io"}
+	setDefaultSendingValues(email, mailbox)
+	assert.Regexp(t, `^<\d+\.[a-z0-9]{12}@acme\.io>$`, email.MessageID)
+
+	mailbox.MessageIDDomain = "bounce.acme-mail.net"
+	email = &models.Email{FromDomain: "acme.io"}
+	setDefaultSendingValues(email, mailbox)
+	assert.Regexp(t, `^<\d+\.[a-z0-9]{12}@bounce\.acme-mail\.net>$`, email.MessageID)
+
+	// the header sent, and the threading headers of a reply to it, use the same id
+	assert.Equal(t, email.MessageID, email.BuildHeaders()["Message-ID"])
+	reply := &models.Email{BodyText: "Thanks"}
+	buildReply(reply, email, enum.ReplyModeReply, "jane@acme.io")
+	assert.Equal(t, email.MessageID, reply.InReplyTo)
+	assert.Equal(t, []string{email.MessageID}, []string(reply.References))
+}
+
+func TestEstimateMessageSize(t *testing.T) {
+	email := &models.Email{BodyText: strings.Repeat("a", 100), BodyHTML: strings.Repeat("b", 200)}
+	assert.Equal(t, 300+4096, estimateMessageSize(email, 0))
+	// base64 grows attachments by a third
+	assert.Equal(t, 300+4000+4096, estimateMessageSize(email, 3000))
+	assert.Equal(t, 4096, estimateMessageSize(&models.Email{}, 0))
+}
+
+func TestCheckMessageSize(t *testing.T) {
+	email := &models.Email{BodyText: strings.Repeat("a", 904)}
+	estimated := estimateMessageSize(email, 3000) // 9000
+
+	tests := []struct {
+		name     string
+		config   *config.SMTPConfig
+		tooLarge bool
+	}{
+		{"at the limit", &config.SMTPConfig{MaxMessageSizeBytes: estimated}, false},
+		{"one byte over the limit", &config.SMTPConfig{MaxMessageSizeBytes: estimated - 1}, true},
+		{"no limit", &config.SMTPConfig{}, false},
+		{"no config", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&emailService{smtpConfig: tt.config}).checkMessageSize(email, 3000)
+			if !tt.tooLarge {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, mailstack_errors.ErrMessageTooLarge)
+			assert.ErrorContains(t, err, "estimated 9000 bytes, limit 8999")
+		})
+	}
+}
+
+func TestValidateFrom(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		email := &models.Email{FromAddress: "jane@example.com", FromName: "Jane"}
+		assert.NoError(t, validateFrom(email, &models.Mailbox{}).Err())
+		assert.Equal(t, "jane", email.FromUser)
+		assert.Equal(t, "example.com", email.FromDomain)
+	})
+
+	t.Run("reported with content problems", func(t *testing.T) {
+		email := &models.Email{FromAddress: "not-an-address", Subject: "Hi", BodyText: "Hello"}
+		problems := validateFrom(email, &models.Mailbox{})
+		problems = append(problems, validateContent(email)...)
+
+		codes := make([]mailstack_errors.ValidationCode, 0, len(problems))
+		for _, problem := range problems {
+			codes = append(codes, problem.Code)
+		}
+		assert.Equal(t, []mailstack_errors.ValidationCode{
+			mailstack_errors.ValidationInvalidFrom,
+			mailstack_errors.ValidationMissingFrom,
+			mailstack_errors.ValidationNoRecipients,
+		}, codes)
+		assert.True(t, errors.Is(problems.Err(), ErrInvalidSender))
+		assert.True(t, errors.Is(problems.Err(), ErrUnknownSender))
+	})
+
+	t.Run("sender profile provides the name", func(t *testing.T) {
+		email := &models.Email{FromAddress: "jane@example.com"}
+		assert.NoError(t, validateFrom(email, &models.Mailbox{SenderID: "sender-1"}).Err())
+	})
+}
@@ -0,0 +1,134 @@
+package email
+
+import (
+	"context"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+
+	"github.com/customeros/mailstack/internal/enum"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+const SCHEDULED_SEND_BATCH_SIZE = 100
+
+// DispatchScheduled picks up scheduled emails whose send time has been reached
+// and publishes them to the send queue
+func (s *emailService) DispatchScheduled(ctx context.Context) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailService.DispatchScheduled")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+
+	now := utils.Now()
+	emails, err := s.repositories.EmailRepository.ListDueScheduled(ctx, now, SCHEDULED_SEND_BATCH_SIZE)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+	span.LogFields(log.Int("emails.count", len(emails)))
+
+	for _, email := range emails {
+		if !isDueForSend(email, now) {
+			continue
+		}
+		if err := s.dispatchScheduledEmail(ctx, email); err != nil {
+			tracing.TraceErr(span, err)
+			continue
+		}
+	}
+
+	return nil
+}
+
+func (s *emailService) dispatchScheduledEmail(ctx context.Context, email *models.Email) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailService.dispatchScheduledEmail")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.SetTag("email.id", email.ID)
+
+	mailbox, err := s.repositories.MailboxRepository.GetMailbox(ctx, email.MailboxID)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+	ctx = utils.WithTenantContext(ctx, mailbox.Tenant)
+
+	// claim the email so a concurrent cancel or another dispatcher cannot race us
+	claimed, err := s.repositories.EmailRepository.TransitionStatus(ctx, email.ID, enum.EmailStatusScheduled, enum.EmailStatusQueued)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+	if !claimed {
+		span.LogFields(log.Bool("claimed", false))
+		return nil
+	}
+	email.Status = enum.EmailStatusQueued
+
+	err = s.eventsService.Publisher.PublishSendEmailEvent(ctx, email)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		// put it back so the next run picks it up again
+		_, rollbackErr := s.repositories.EmailRepository.TransitionStatus(ctx, email.ID, enum.EmailStatusQueued, enum.EmailStatusScheduled)
+		if rollbackErr != nil {
+			tracing.TraceErr(span, rollbackErr)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// CancelScheduledSend moves a scheduled email back to draft so it will not be sent
+func (s *emailService) CancelScheduledSend(ctx context.Context, emailID string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailService.CancelScheduledSend")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.SetTag("email.id", emailID)
+
+	email, err := s.repositories.EmailRepository.GetByID(ctx, emailID)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+	if email == nil {
+		tracing.TraceErr(span, ErrEmailNotFound)
+		return ErrEmailNotFound
+	}
+
+	mailbox, err := s.repositories.MailboxRepository.GetMailbox(ctx, email.MailboxID)
+	if err != nil || mailbox == nil || mailbox.Tenant != utils.GetTenantFromContext(ctx) {
+		tracing.TraceErr(span, ErrEmailNotFound)
+		return ErrEmailNotFound
+	}
+
+	if email.Status != enum.EmailStatusScheduled {
+		tracing.TraceErr(span, ErrEmailNotScheduled)
+		return ErrEmailNotScheduled
+	}
+
+	cancelled, err := s.repositories.EmailRepository.TransitionStatus(ctx, emailID, enum.EmailStatusScheduled, enum.EmailStatusDraft)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+	if !cancelled {
+		// dispatcher claimed it between our read and the update
+		tracing.TraceErr(span, ErrEmailNotScheduled)
+		return ErrEmailNotScheduled
+	}
+
+	return nil
+}
+
+// isDueForSend reports whether a scheduled email should be sent at the given time.
+// An email is due from the exact scheduled timestamp onwards.
+func isDueForSend(email *models.Email, now time.Time) bool {
+	if email == nil || email.ScheduledFor == nil {
+		return false
+	}
+	return !email.ScheduledFor.After(now)
+}
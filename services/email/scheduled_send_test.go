@@ -0,0 +1,64 @@
+package email
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/customeros/mailstack/internal/models"
+)
+
+func TestIsDueForSend(t *testing.T) {
+	scheduledFor := time.Date(2025, 3, 10, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		email    *models.Email
+		now      time.Time
+		expected bool
+	}{
+		{
+			name:     "nil email",
+			email:    nil,
+			now:      scheduledFor,
+			expected: false,
+		},
+		{
+			name:     "not scheduled",
+			email:    &models.Email{},
+			now:      scheduledFor,
+			expected: false,
+		},
+		{
+			name:     "one nanosecond before scheduled time",
+			email:    &models.Email{ScheduledFor: &scheduledFor},
+			now:      scheduledFor.Add(-time.Nanosecond),
+			expected: false,
+		},
+		{
+			name:     "exactly at scheduled time",
+			email:    &models.Email{ScheduledFor: &scheduledFor},
+			now:      scheduledFor,
+			expected: true,
+		},
+		{
+			name:     "one nanosecond after scheduled time",
+			email:    &models.Email{ScheduledFor: &scheduledFor},
+			now:      scheduledFor.Add(time.Nanosecond),
+			expected: true,
+		},
+		{
+			name:     "same instant in a different timezone",
+			email:    &models.Email{ScheduledFor: &scheduledFor},
+			now:      scheduledFor.In(time.FixedZone("UTC+2", 2*60*60)),
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, isDueForSend(tt.email, tt.now))
+		})
+	}
+}
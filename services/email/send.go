@@ -5,6 +5,7 @@ import (
 
 	"github.com/opentracing/opentracing-go"
 
+	"github.com/customeros/mailstack/internal/enum"
 	"github.com/customeros/mailstack/internal/models"
 	"github.com/customeros/mailstack/internal/tracing"
 	"github.com/customeros/mailstack/services/smtp"
@@ -15,7 +16,33 @@ func (s *emailService) SendWithSMTP(ctx context.Context, mailbox *models.Mailbox
 	defer span.Finish()
 	tracing.SetDefaultServiceSpanTags(ctx, span)
 
-	client := smtp.NewSMTPClient(s.repositories, mailbox)
+	throttled, err := s.throttle(ctx, mailbox, email)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+	if throttled {
+		return nil
+	}
 
-	return client.Send(ctx, email, attachments)
+	deferred, releaseDailySend, err := s.reserveDailySend(ctx, mailbox, email)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+	if deferred {
+		return nil
+	}
+
+	client := smtp.NewSMTPClient(s.repositories, mailbox, s.smtpConfig, s.smtpPool)
+	client.SetMXChecker(s.mxChecker)
+	if s.smtpConfig != nil && s.smtpConfig.AppendToSentFolder {
+		client.SetSentFolderAppender(s.imapService)
+	}
+
+	err = client.Send(ctx, email, attachments)
+	if email.Status != enum.EmailStatusSent {
+		releaseDailySend(ctx)
+	}
+	return err
 }
@@ -6,25 +6,42 @@ import (
 	"github.com/customeros/mailsherpa/mailvalidate"
 
 	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/config"
 	"github.com/customeros/mailstack/internal/repository"
 	"github.com/customeros/mailstack/services/events"
+	"github.com/customeros/mailstack/services/smtp"
 )
 
 type emailService struct {
 	eventsService *events.EventsService
 	repositories  *repository.Repositories
+	smtpConfig    *config.SMTPConfig
+	smtpPool      *smtp.Pool
+	mxChecker     *smtp.MXChecker
+	imapService   interfaces.IMAPService
 }
 
 func NewEmailService(
 	eventsService *events.EventsService,
 	repositories *repository.Repositories,
+	smtpConfig *config.SMTPConfig,
+	imapService interfaces.IMAPService,
 ) interfaces.EmailService {
 	return &emailService{
 		repositories:  repositories,
 		eventsService: eventsService,
+		smtpConfig:    smtpConfig,
+		smtpPool:      smtp.NewPool(smtpConfig),
+		mxChecker:     smtp.NewMXChecker(smtpConfig),
+		imapService:   imapService,
 	}
 }
 
+// Close closes the pooled SMTP sessions, sends still in flight close theirs when done
+func (s *emailService) Close() {
+	s.smtpPool.Close()
+}
+
 var (
 	ErrMailboxDoesNotExist    = errors.New("mailbox does not exist")
 	ErrUnknownSender          = errors.New("unknown sender")
@@ -37,6 +54,11 @@ var (
 	ErrAttachmentDoesNotExist = errors.New("attachment does not exist")
 	ErrScheduledSendNotValid  = errors.New("invalid scheduled for time")
 	ErrInvalidSender          = errors.New("invalid sender")
+	ErrInvalidUnsubscribe     = errors.New("invalid unsubscribe url or mailbox")
+	ErrInvalidHeader          = errors.New("invalid header")
+	ErrEmailNotFound          = errors.New("email not found")
+	ErrEmailNotScheduled      = errors.New("email is not scheduled")
+	ErrEmailNotOutbound       = errors.New("email is not outbound")
 )
 
 func ValidateEmailAddress(email *string) error {
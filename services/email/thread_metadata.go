@@ -0,0 +1,105 @@
+package email
+
+import (
+	"context"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+
+	"github.com/customeros/mailstack/dto"
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/enum"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+// threadMetadataBatchSize is how many threads the sweep loads at once
+const threadMetadataBatchSize = 500
+
+// RecomputeThreadMetadata rebuilds the participants, attachment flag and message times of a
+// thread from its emails and corrects the stored thread when they drifted
+func (s *emailService) RecomputeThreadMetadata(ctx context.Context, threadID string) (*interfaces.ThreadMetadataCorrection, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailService.RecomputeThreadMetadata")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.SetTag("thread.id", threadID)
+
+	mailbox, err := s.threadsMailbox(ctx, []string{threadID})
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	thread, fields, err := s.repositories.EmailThreadRepository.RecomputeMetadata(ctx, threadID)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+	if len(fields) > 0 {
+		s.publishThreadCorrected(ctx, mailbox, thread, fields)
+	}
+
+	return &interfaces.ThreadMetadataCorrection{ThreadID: threadID, Fields: fields}, nil
+}
+
+// RecomputeThreadsMetadata walks all threads and corrects those whose metadata drifted from
+// their emails, after partial failures or deduplication. A failing thread does not stop the sweep.
+func (s *emailService) RecomputeThreadsMetadata(ctx context.Context) (interfaces.ThreadMetadataReport, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailService.RecomputeThreadsMetadata")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+
+	var report interfaces.ThreadMetadataReport
+	afterID := ""
+	for {
+		ids, err := s.repositories.EmailThreadRepository.ListIDsAfter(ctx, afterID, threadMetadataBatchSize)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return report, err
+		}
+
+		for _, id := range ids {
+			report.Checked++
+			thread, fields, err := s.repositories.EmailThreadRepository.RecomputeMetadata(ctx, id)
+			if err != nil {
+				// the thread may have been merged away since it was listed
+				tracing.TraceErr(span, err)
+				report.Failed = append(report.Failed, id)
+				continue
+			}
+			if len(fields) == 0 {
+				continue
+			}
+			report.Corrected = append(report.Corrected, interfaces.ThreadMetadataCorrection{ThreadID: id, Fields: fields})
+
+			mailbox, err := s.repositories.MailboxRepository.GetMailbox(ctx, thread.MailboxID)
+			if err != nil {
+				tracing.TraceErr(span, err)
+				continue
+			}
+			s.publishThreadCorrected(ctx, mailbox, thread, fields)
+		}
+
+		if len(ids) < threadMetadataBatchSize {
+			break
+		}
+		afterID = ids[len(ids)-1]
+	}
+
+	span.LogFields(
+		log.Int("threads.checked", report.Checked),
+		log.Int("threads.corrected", len(report.Corrected)),
+		log.Int("threads.failed", len(report.Failed)),
+	)
+	return report, nil
+}
+
+// publishThreadCorrected notifies the tenant of a thread whose metadata was corrected
+func (s *emailService) publishThreadCorrected(ctx context.Context, mailbox *models.Mailbox, thread *models.EmailThread, fields []string) {
+	if mailbox == nil || s.eventsService == nil || s.eventsService.Publisher == nil {
+		return
+	}
+	data := dto.ThreadMetadataCorrected{MailboxID: thread.MailboxID, ThreadID: thread.ID, Fields: fields}
+	s.eventsService.Publisher.PublishNotification(ctx, mailbox.Tenant, thread.ID, enum.THREAD, utils.NewEventCompletedDetails().WithUpdate().WithData(data))
+}
@@ -0,0 +1,62 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/repository"
+)
+
+type fakeRecomputeRepository struct {
+	interfaces.EmailThreadRepository
+	ids       []string
+	corrected map[string][]string
+	failing   map[string]bool
+}
+
+func (r *fakeRecomputeRepository) ListIDsAfter(_ context.Context, afterID string, limit int) ([]string, error) {
+	var ids []string
+	for _, id := range r.ids {
+		if id > afterID && len(ids) < limit {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (r *fakeRecomputeRepository) RecomputeMetadata(_ context.Context, threadID string) (*models.EmailThread, []string, error) {
+	if r.failing[threadID] {
+		return nil, nil, errors.New("thread not found")
+	}
+	return &models.EmailThread{ID: threadID, MailboxID: "mbox_1"}, r.corrected[threadID], nil
+}
+
+func TestRecomputeThreadsMetadata(t *testing.T) {
+	threads := &fakeRecomputeRepository{
+		corrected: map[string][]string{"thrd_0007": {"participants"}, "thrd_0612": {"hasAttachments", "lastMessageAt"}},
+		failing:   map[string]bool{"thrd_0300": true},
+	}
+	for i := 0; i < threadMetadataBatchSize+150; i++ {
+		threads.ids = append(threads.ids, fmt.Sprintf("thrd_%04d", i))
+	}
+	service := &emailService{repositories: &repository.Repositories{
+		EmailThreadRepository: threads,
+		MailboxRepository:     &fakeMailboxRepository{mailboxes: map[string]*models.Mailbox{"mbox_1": {ID: "mbox_1", Tenant: "acme"}}},
+	}}
+
+	report, err := service.RecomputeThreadsMetadata(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, threadMetadataBatchSize+150, report.Checked)
+	assert.Equal(t, []interfaces.ThreadMetadataCorrection{
+		{ThreadID: "thrd_0007", Fields: []string{"participants"}},
+		{ThreadID: "thrd_0612", Fields: []string{"hasAttachments", "lastMessageAt"}},
+	}, report.Corrected)
+	assert.Equal(t, []string{"thrd_0300"}, report.Failed)
+}
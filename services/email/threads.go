@@ -0,0 +1,106 @@
+package email
+
+import (
+	"context"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+
+	"github.com/customeros/mailstack/dto"
+	"github.com/customeros/mailstack/internal/enum"
+	mailstack_errors "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+// MergeThreads moves the emails of the source threads into the target thread, correcting
+// conversations that automatic threading split. The sources are deleted.
+func (s *emailService) MergeThreads(ctx context.Context, sourceIDs []string, targetID string) (*models.EmailThread, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailService.MergeThreads")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.SetTag("thread.id", targetID)
+	span.LogKV("source_ids", strings.Join(sourceIDs, ","))
+
+	mailbox, err := s.threadsMailbox(ctx, append([]string{targetID}, sourceIDs...))
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	thread, err := s.repositories.EmailThreadRepository.MergeThreads(ctx, sourceIDs, targetID)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	if s.eventsService != nil && s.eventsService.Publisher != nil {
+		data := dto.ThreadsMerged{MailboxID: thread.MailboxID, ThreadID: thread.ID, SourceThreadIDs: sourceIDs}
+		s.eventsService.Publisher.PublishNotification(ctx, mailbox.Tenant, thread.ID, enum.THREAD, utils.NewEventCompletedDetails().WithUpdate().WithData(data))
+		s.eventsService.Publisher.PublishNotificationBulk(ctx, mailbox.Tenant, sourceIDs, enum.THREAD, utils.NewEventCompletedDetails().WithDelete().WithData(data))
+	}
+
+	return thread, nil
+}
+
+// SplitThread moves emails of a thread into a new thread, correcting conversations that
+// automatic threading merged. It returns the new thread.
+func (s *emailService) SplitThread(ctx context.Context, threadID string, emailIDs []string) (*models.EmailThread, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailService.SplitThread")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.SetTag("thread.id", threadID)
+	span.LogKV("email_ids", strings.Join(emailIDs, ","))
+
+	mailbox, err := s.threadsMailbox(ctx, []string{threadID})
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	thread, split, err := s.repositories.EmailThreadRepository.SplitThread(ctx, threadID, emailIDs)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+	span.SetTag("result.thread.id", split.ID)
+
+	if s.eventsService != nil && s.eventsService.Publisher != nil {
+		data := dto.ThreadSplit{MailboxID: thread.MailboxID, ThreadID: thread.ID, NewThreadID: split.ID, EmailIDs: emailIDs}
+		s.eventsService.Publisher.PublishNotification(ctx, mailbox.Tenant, thread.ID, enum.THREAD, utils.NewEventCompletedDetails().WithUpdate().WithData(data))
+		s.eventsService.Publisher.PublishNotification(ctx, mailbox.Tenant, split.ID, enum.THREAD, utils.NewEventCompletedDetails().WithCreate().WithData(data))
+	}
+
+	return split, nil
+}
+
+// threadsMailbox returns the mailbox of the threads for the notifications. Threads that do not
+// exist, or belong to another tenant than the one of the context, are reported as not found.
+func (s *emailService) threadsMailbox(ctx context.Context, threadIDs []string) (*models.Mailbox, error) {
+	threads, err := s.repositories.EmailThreadRepository.GetByIDs(ctx, threadIDs)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]bool, len(threads))
+	for _, thread := range threads {
+		found[thread.ID] = true
+	}
+	for _, id := range threadIDs {
+		if !found[id] {
+			return nil, mailstack_errors.ErrThreadNotFound
+		}
+	}
+
+	mailbox, err := s.repositories.MailboxRepository.GetMailbox(ctx, threads[0].MailboxID)
+	if err != nil {
+		return nil, err
+	}
+	if mailbox == nil {
+		return nil, mailstack_errors.ErrThreadNotFound
+	}
+	if tenant := utils.GetTenantFromContext(ctx); tenant != "" && mailbox.Tenant != tenant {
+		return nil, mailstack_errors.ErrThreadNotFound
+	}
+	return mailbox, nil
+}
@@ -0,0 +1,76 @@
+package email
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/interfaces"
+	mailstack_errors "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/repository"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+type fakeThreadRepository struct {
+	interfaces.EmailThreadRepository
+	threads map[string]*models.EmailThread
+	merged  []string
+}
+
+func (r *fakeThreadRepository) GetByIDs(_ context.Context, ids []string) ([]*models.EmailThread, error) {
+	var threads []*models.EmailThread
+	for _, id := range ids {
+		if thread, ok := r.threads[id]; ok {
+			threads = append(threads, thread)
+		}
+	}
+	return threads, nil
+}
+
+func (r *fakeThreadRepository) MergeThreads(_ context.Context, sourceIDs []string, targetID string) (*models.EmailThread, error) {
+	r.merged = append(r.merged, sourceIDs...)
+	return r.threads[targetID], nil
+}
+
+func (r *fakeThreadRepository) SplitThread(_ context.Context, threadID string, emailIDs []string) (*models.EmailThread, *models.EmailThread, error) {
+	return r.threads[threadID], &models.EmailThread{ID: "thrd_new", MailboxID: r.threads[threadID].MailboxID}, nil
+}
+
+func TestRegroupThreads(t *testing.T) {
+	threads := &fakeThreadRepository{threads: map[string]*models.EmailThread{
+		"thrd_1": {ID: "thrd_1", MailboxID: "mbox_1"},
+		"thrd_2": {ID: "thrd_2", MailboxID: "mbox_1"},
+	}}
+	service := &emailService{repositories: &repository.Repositories{
+		EmailThreadRepository: threads,
+		MailboxRepository: &fakeMailboxRepository{mailboxes: map[string]*models.Mailbox{
+			"mbox_1": {ID: "mbox_1", Tenant: "acme"},
+		}},
+	}}
+
+	t.Run("admin merge without tenant", func(t *testing.T) {
+		thread, err := service.MergeThreads(context.Background(), []string{"thrd_2"}, "thrd_1")
+		require.NoError(t, err)
+		assert.Equal(t, "thrd_1", thread.ID)
+		assert.Equal(t, []string{"thrd_2"}, threads.merged)
+	})
+
+	t.Run("split of the tenant", func(t *testing.T) {
+		thread, err := service.SplitThread(utils.SetTenantInContext(context.Background(), "acme"), "thrd_1", []string{"email_1"})
+		require.NoError(t, err)
+		assert.Equal(t, "thrd_new", thread.ID)
+	})
+
+	t.Run("unknown thread", func(t *testing.T) {
+		_, err := service.MergeThreads(context.Background(), []string{"thrd_9"}, "thrd_1")
+		assert.ErrorIs(t, err, mailstack_errors.ErrThreadNotFound)
+	})
+
+	t.Run("thread of another tenant", func(t *testing.T) {
+		_, err := service.SplitThread(utils.SetTenantInContext(context.Background(), "other"), "thrd_1", []string{"email_1"})
+		assert.ErrorIs(t, err, mailstack_errors.ErrThreadNotFound)
+	})
+}
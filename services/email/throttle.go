@@ -0,0 +1,72 @@
+package email
+
+import (
+	"context"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+
+	"github.com/customeros/mailstack/internal/enum"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+// throttle checks the mailbox send limits and, if the email cannot go out yet,
+// reschedules it for the next allowed time. Returns true when the email was deferred.
+func (s *emailService) throttle(ctx context.Context, mailbox *models.Mailbox, email *models.Email) (bool, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailService.throttle")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+
+	now := utils.Now()
+	sentTimes, err := s.repositories.EmailRepository.ListSentAtSince(ctx, mailbox.ID, now.Add(-time.Hour))
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return false, err
+	}
+
+	nextAt := nextAllowedSendAt(mailbox, sentTimes, now)
+	if !nextAt.After(now) {
+		return false, nil
+	}
+	span.LogFields(log.Bool("throttled", true), log.String("nextAllowedAt", nextAt.String()))
+
+	// hand it back to the scheduled send dispatcher
+	email.Status = enum.EmailStatusScheduled
+	email.ScheduledFor = &nextAt
+	err = s.repositories.EmailRepository.Update(ctx, email)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// nextAllowedSendAt returns the earliest time the mailbox may send again given the
+// send times of the last hour (oldest first)
+func nextAllowedSendAt(mailbox *models.Mailbox, sentTimes []time.Time, now time.Time) time.Time {
+	next := now
+	if len(sentTimes) == 0 {
+		return next
+	}
+
+	if mailbox.MinSendIntervalSeconds > 0 {
+		gapEnd := sentTimes[len(sentTimes)-1].Add(time.Duration(mailbox.MinSendIntervalSeconds) * time.Second)
+		if gapEnd.After(next) {
+			next = gapEnd
+		}
+	}
+
+	if mailbox.HourlySendLimit > 0 && len(sentTimes) >= mailbox.HourlySendLimit {
+		// a slot frees up once the send that opened the current window ages out
+		windowEnd := sentTimes[len(sentTimes)-mailbox.HourlySendLimit].Add(time.Hour)
+		if windowEnd.After(next) {
+			next = windowEnd
+		}
+	}
+
+	return next
+}
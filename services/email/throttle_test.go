@@ -0,0 +1,51 @@
+package email
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/customeros/mailstack/internal/models"
+)
+
+func TestNextAllowedSendAt(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	mailbox := &models.Mailbox{HourlySendLimit: 3, MinSendIntervalSeconds: 60}
+
+	t.Run("no previous sends", func(t *testing.T) {
+		assert.Equal(t, now, nextAllowedSendAt(mailbox, nil, now))
+	})
+
+	t.Run("minimum gap is enforced", func(t *testing.T) {
+		sent := []time.Time{now.Add(-20 * time.Second)}
+		assert.Equal(t, now.Add(40*time.Second), nextAllowedSendAt(mailbox, sent, now))
+	})
+
+	t.Run("gap exactly elapsed", func(t *testing.T) {
+		sent := []time.Time{now.Add(-60 * time.Second)}
+		assert.Equal(t, now, nextAllowedSendAt(mailbox, sent, now))
+	})
+
+	t.Run("hourly limit reached", func(t *testing.T) {
+		sent := []time.Time{
+			now.Add(-50 * time.Minute),
+			now.Add(-30 * time.Minute),
+			now.Add(-10 * time.Minute),
+		}
+		assert.Equal(t, now.Add(10*time.Minute), nextAllowedSendAt(mailbox, sent, now))
+	})
+
+	t.Run("below hourly limit", func(t *testing.T) {
+		sent := []time.Time{
+			now.Add(-30 * time.Minute),
+			now.Add(-10 * time.Minute),
+		}
+		assert.Equal(t, now, nextAllowedSendAt(mailbox, sent, now))
+	})
+
+	t.Run("limits disabled", func(t *testing.T) {
+		sent := []time.Time{now.Add(-time.Second)}
+		assert.Equal(t, now, nextAllowedSendAt(&models.Mailbox{}, sent, now))
+	})
+}
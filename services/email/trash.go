@@ -0,0 +1,236 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+	"gorm.io/gorm"
+
+	"github.com/customeros/mailstack/dto"
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/enum"
+	mailstack_errors "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/repository"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+// DeleteThread moves a thread and its emails to the trash
+func (s *emailService) DeleteThread(ctx context.Context, threadID string) (*models.EmailThread, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailService.DeleteThread")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.SetTag("thread.id", threadID)
+
+	mailbox, err := s.threadsMailbox(ctx, []string{threadID})
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	thread, err := s.repositories.EmailThreadRepository.SoftDelete(ctx, threadID)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	s.publishTrashChanged(ctx, mailbox, dto.TrashChanged{MailboxID: thread.MailboxID, ThreadID: thread.ID, Deleted: true})
+	return thread, nil
+}
+
+// RestoreThread takes a thread out of the trash together with the emails deleted with it
+func (s *emailService) RestoreThread(ctx context.Context, threadID string) (*models.EmailThread, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailService.RestoreThread")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.SetTag("thread.id", threadID)
+
+	thread, err := s.repositories.EmailThreadRepository.GetByIDIncludingDeleted(ctx, threadID)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+	mailbox, err := s.tenantMailbox(ctx, thread.MailboxID)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+	if mailbox == nil {
+		tracing.TraceErr(span, mailstack_errors.ErrThreadNotFound)
+		return nil, mailstack_errors.ErrThreadNotFound
+	}
+
+	thread, err = s.repositories.EmailThreadRepository.Restore(ctx, threadID)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	s.publishTrashChanged(ctx, mailbox, dto.TrashChanged{MailboxID: thread.MailboxID, ThreadID: thread.ID})
+	return thread, nil
+}
+
+// DeleteEmail moves an email to the trash and recomputes the metadata of its thread from the
+// remaining emails. The thread is deleted with its last email.
+func (s *emailService) DeleteEmail(ctx context.Context, emailID string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailService.DeleteEmail")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.SetTag("email.id", emailID)
+
+	email, err := s.repositories.EmailRepository.GetByID(ctx, emailID)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+	if email == nil {
+		tracing.TraceErr(span, ErrEmailNotFound)
+		return ErrEmailNotFound
+	}
+	mailbox, err := s.tenantMailbox(ctx, email.MailboxID)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+	if mailbox == nil {
+		tracing.TraceErr(span, ErrEmailNotFound)
+		return ErrEmailNotFound
+	}
+
+	threadDeleted, err := s.repositories.EmailRepository.SoftDelete(ctx, emailID)
+	if errors.Is(err, repository.ErrEmailNotFound) {
+		err = ErrEmailNotFound
+	}
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+	if email.ThreadID != "" && !threadDeleted {
+		s.recomputeAfterTrashChange(ctx, email.ThreadID)
+	}
+
+	s.publishTrashChanged(ctx, mailbox, dto.TrashChanged{MailboxID: email.MailboxID, ThreadID: email.ThreadID, EmailID: email.ID, Deleted: true})
+	return nil
+}
+
+// RestoreEmail takes an email out of the trash, and its thread when that was deleted
+func (s *emailService) RestoreEmail(ctx context.Context, emailID string) (*models.Email, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailService.RestoreEmail")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.SetTag("email.id", emailID)
+
+	email, err := s.repositories.EmailRepository.GetByIDIncludingDeleted(ctx, emailID)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+	if email == nil {
+		tracing.TraceErr(span, ErrEmailNotFound)
+		return nil, ErrEmailNotFound
+	}
+	mailbox, err := s.tenantMailbox(ctx, email.MailboxID)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+	if mailbox == nil {
+		tracing.TraceErr(span, ErrEmailNotFound)
+		return nil, ErrEmailNotFound
+	}
+	if !email.DeletedAt.Valid {
+		tracing.TraceErr(span, mailstack_errors.ErrEmailNotDeleted)
+		return nil, mailstack_errors.ErrEmailNotDeleted
+	}
+
+	threadRestored, err := s.repositories.EmailRepository.Restore(ctx, emailID)
+	if errors.Is(err, repository.ErrEmailNotFound) {
+		err = mailstack_errors.ErrEmailNotDeleted
+	}
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+	span.SetTag("thread.restored", threadRestored)
+	if email.ThreadID != "" {
+		s.recomputeAfterTrashChange(ctx, email.ThreadID)
+	}
+
+	email.DeletedAt = gorm.DeletedAt{}
+	s.publishTrashChanged(ctx, mailbox, dto.TrashChanged{MailboxID: email.MailboxID, ThreadID: email.ThreadID, EmailID: email.ID})
+	return email, nil
+}
+
+// PurgeDeleted permanently removes the emails and threads deleted before the given time, with
+// the stored sources and the attachment files no other email uses
+func (s *emailService) PurgeDeleted(ctx context.Context, before time.Time) (interfaces.PurgeReport, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailService.PurgeDeleted")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.LogFields(log.Object("before", before))
+
+	report, err := s.purgeInBatches(ctx, func(ctx context.Context) (*interfaces.PurgedEmails, error) {
+		return s.repositories.EmailRepository.PurgeDeleted(ctx, before, purgeBatchSize)
+	})
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return report, err
+	}
+
+	// threads deleted without emails left in them
+	threads, err := s.repositories.EmailThreadRepository.PurgeDeleted(ctx, before)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return report, err
+	}
+	report.Threads += threads
+
+	logPurgeReport(span, report)
+	return report, nil
+}
+
+// tenantMailbox returns the mailbox, nil when it does not exist or belongs to another tenant
+// than the one of the context
+func (s *emailService) tenantMailbox(ctx context.Context, mailboxID string) (*models.Mailbox, error) {
+	mailbox, err := s.repositories.MailboxRepository.GetMailbox(ctx, mailboxID)
+	if err != nil || mailbox == nil {
+		return nil, err
+	}
+	if tenant := utils.GetTenantFromContext(ctx); tenant != "" && mailbox.Tenant != tenant {
+		return nil, nil
+	}
+	return mailbox, nil
+}
+
+// recomputeAfterTrashChange corrects the metadata of a thread after one of its emails was deleted
+// or restored. The email already moved, a failure is left to the recompute job.
+func (s *emailService) recomputeAfterTrashChange(ctx context.Context, threadID string) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailService.recomputeAfterTrashChange")
+	defer span.Finish()
+	span.SetTag("thread.id", threadID)
+
+	if _, _, err := s.repositories.EmailThreadRepository.RecomputeMetadata(ctx, threadID); err != nil {
+		tracing.TraceErr(span, err)
+	}
+}
+
+// publishTrashChanged notifies the tenant of a deleted or restored thread or email
+func (s *emailService) publishTrashChanged(ctx context.Context, mailbox *models.Mailbox, data dto.TrashChanged) {
+	if s.eventsService == nil || s.eventsService.Publisher == nil {
+		return
+	}
+	details := utils.NewEventCompletedDetails().WithCreate()
+	if data.Deleted {
+		details = utils.NewEventCompletedDetails().WithDelete()
+	}
+
+	entity, entityID := enum.THREAD, data.ThreadID
+	if data.EmailID != "" {
+		entity, entityID = enum.EMAIL, data.EmailID
+	}
+	s.eventsService.Publisher.PublishNotification(ctx, mailbox.Tenant, entityID, entity, details.WithData(data))
+}
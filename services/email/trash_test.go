@@ -0,0 +1,124 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/customeros/mailstack/interfaces"
+	mailstack_errors "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/repository"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+type fakeTrashEmailRepository struct {
+	fakeEmailRepository
+	deleted  []*models.Email
+	restored []string
+}
+
+func (r *fakeTrashEmailRepository) GetByIDIncludingDeleted(_ context.Context, id string) (*models.Email, error) {
+	return r.emails[id], nil
+}
+
+func (r *fakeTrashEmailRepository) Restore(_ context.Context, emailID string) (bool, error) {
+	r.restored = append(r.restored, emailID)
+	return false, nil
+}
+
+func (r *fakeTrashEmailRepository) PurgeDeleted(_ context.Context, before time.Time, limit int) (*interfaces.PurgedEmails, error) {
+	n := min(limit, len(r.deleted))
+	purged := r.deleted[:n]
+	r.deleted = r.deleted[n:]
+	return &interfaces.PurgedEmails{Emails: purged}, nil
+}
+
+type fakeTrashThreadRepository struct {
+	interfaces.EmailThreadRepository
+	recomputed []string
+}
+
+func (r *fakeTrashThreadRepository) RecomputeMetadata(_ context.Context, threadID string) (*models.EmailThread, []string, error) {
+	r.recomputed = append(r.recomputed, threadID)
+	return &models.EmailThread{ID: threadID}, nil, nil
+}
+
+func (r *fakeTrashThreadRepository) PurgeDeleted(_ context.Context, before time.Time) (int64, error) {
+	return 2, nil
+}
+
+func TestPurgeDeleted(t *testing.T) {
+	emails := &fakeTrashEmailRepository{}
+	raw := &fakeRawRepository{raw: map[string][]byte{}}
+	for i := 0; i < purgeBatchSize+3; i++ {
+		email := &models.Email{ID: fmt.Sprintf("email_%d", i)}
+		if i%2 == 0 {
+			email.RawKey = "raw/" + email.ID + ".eml"
+			raw.raw[email.RawKey] = []byte("Subject: Hi\r\n\r\nHi")
+		}
+		emails.deleted = append(emails.deleted, email)
+	}
+	raw.raw["raw/kept.eml"] = []byte("Subject: Kept\r\n\r\nKept")
+
+	service := &emailService{repositories: &repository.Repositories{
+		EmailRepository:       emails,
+		EmailThreadRepository: &fakeTrashThreadRepository{},
+		EmailRawRepository:    raw,
+	}}
+
+	report, err := service.PurgeDeleted(context.Background(), time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, purgeBatchSize+3, report.Emails)
+	assert.Equal(t, int64(2), report.Threads)
+	assert.Empty(t, emails.deleted)
+	assert.Equal(t, []string{"raw/kept.eml"}, keysOf(raw.raw))
+}
+
+func TestRestoreEmail(t *testing.T) {
+	emails := &fakeTrashEmailRepository{fakeEmailRepository: fakeEmailRepository{emails: map[string]*models.Email{
+		"email_1": {ID: "email_1", MailboxID: "mbox_1", ThreadID: "thrd_1", DeletedAt: gorm.DeletedAt{Time: time.Now(), Valid: true}},
+		"email_2": {ID: "email_2", MailboxID: "mbox_1", ThreadID: "thrd_1"},
+	}}}
+	threads := &fakeTrashThreadRepository{}
+	service := &emailService{repositories: &repository.Repositories{
+		EmailRepository:       emails,
+		EmailThreadRepository: threads,
+		MailboxRepository: &fakeMailboxRepository{mailboxes: map[string]*models.Mailbox{
+			"mbox_1": {ID: "mbox_1", Tenant: "acme"},
+		}},
+	}}
+	ctx := utils.SetTenantInContext(context.Background(), "acme")
+
+	t.Run("email of another tenant", func(t *testing.T) {
+		_, err := service.RestoreEmail(utils.SetTenantInContext(context.Background(), "other"), "email_1")
+		assert.ErrorIs(t, err, ErrEmailNotFound)
+		assert.Empty(t, emails.restored)
+	})
+
+	t.Run("email not deleted", func(t *testing.T) {
+		_, err := service.RestoreEmail(ctx, "email_2")
+		assert.ErrorIs(t, err, mailstack_errors.ErrEmailNotDeleted)
+	})
+
+	t.Run("restored and thread recomputed", func(t *testing.T) {
+		email, err := service.RestoreEmail(ctx, "email_1")
+		require.NoError(t, err)
+		assert.False(t, email.DeletedAt.Valid)
+		assert.Equal(t, []string{"email_1"}, emails.restored)
+		assert.Equal(t, []string{"thrd_1"}, threads.recomputed)
+	})
+}
+
+func keysOf(m map[string][]byte) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return keys
+}
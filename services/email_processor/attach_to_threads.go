@@ -2,29 +2,71 @@ package email_processor
 
 import (
 	"context"
+	"time"
 
 	"github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
 
+	"github.com/customeros/mailstack/internal/config"
 	"github.com/customeros/mailstack/internal/models"
 	"github.com/customeros/mailstack/internal/tracing"
 	"github.com/customeros/mailstack/internal/utils"
 )
 
+const (
+	defaultSubjectMatchWindowDays = 30
+	defaultSubjectMatchMinShared  = 2
+)
+
 func (p *emailProcessor) attachEmailToThread(ctx context.Context, email *models.Email) error {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "emailProcessor.attachMessageToThread")
 	defer span.Finish()
 	tracing.SetDefaultServiceSpanTags(ctx, span)
 
-	// Step 1: Try to find existing thread by headers and references
-	threadID, err := p.findExistingThread(ctx, email)
+	// Step 1: Check whether earlier replies are waiting for this message
+	orphan, err := p.findOrphanRecord(ctx, email)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	// Step 2: Try to find existing thread by headers and references. Replies waiting for
+	// this message already define its thread, so the subject fallback is skipped then.
+	threadID, err := p.findExistingThread(ctx, email, orphan == nil)
 	if err != nil {
 		tracing.TraceErr(span, err)
 		return err
 	}
 
-	// Step 2: Process based on thread existence
-	if threadID == "" {
+	// Step 3: Process based on thread existence
+	switch {
+	case threadID != "":
+		// Attach to existing thread
+		email.ThreadID = threadID
+
+		err = p.updateThreadMetadata(ctx, email, threadID)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return err
+		}
+
+	case orphan != nil:
+		// Join the thread of the replies that arrived first, our own parents are still missing
+		email.ThreadID = orphan.ThreadID
+
+		err = p.updateThreadMetadata(ctx, email, orphan.ThreadID)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return err
+		}
+
+		err = p.recordMissingParents(ctx, email)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return err
+		}
+
+	default:
 		// Create new thread if none exists
 		threadID, err = p.createNewThread(ctx, email)
 		if err != nil {
@@ -41,39 +83,27 @@ func (p *emailProcessor) attachEmailToThread(ctx context.Context, email *models.
 			tracing.TraceErr(span, err)
 			return err
 		}
-
-		return nil
 	}
 
-	// Attach to existing thread
-	email.ThreadID = threadID
-
-	// Update thread metadata
-	err = p.updateThreadMetadata(ctx, email, threadID)
-	if err != nil {
-		tracing.TraceErr(span, err)
-		return err
+	// Step 4: Move the waiting replies into the resolved thread
+	if orphan != nil {
+		err = p.adoptOrphanedChildren(ctx, orphan, email.ThreadID)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return err
+		}
 	}
 
 	return nil
 }
 
 // findExistingThread attempts to find an existing thread for the email
-func (p *emailProcessor) findExistingThread(ctx context.Context, email *models.Email) (string, error) {
+func (p *emailProcessor) findExistingThread(ctx context.Context, email *models.Email, allowSubjectMatch bool) (string, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "emailProcessor.findExistingThread")
 	defer span.Finish()
 	tracing.SetDefaultServiceSpanTags(ctx, span)
 
-	// Case 1: Check if this is a parent to a missing parent message
-	threadID, err := p.checkForOrphanedParentMessage(ctx, email)
-	if err != nil {
-		return "", err
-	}
-	if threadID != "" {
-		return threadID, nil
-	}
-
-	// Case 2: Check based on ReplyTo
+	// Case 1: Check based on ReplyTo
 	if email.ReplyTo != "" {
 		threadID, err := p.findThreadByMessageID(ctx, email.ReplyTo)
 		if err != nil {
@@ -86,7 +116,7 @@ func (p *emailProcessor) findExistingThread(ctx context.Context, email *models.E
 		}
 	}
 
-	// Case 3: Check based on References
+	// Case 2: Check based on References
 	for _, messageID := range email.References {
 		threadID, err := p.findThreadByMessageID(ctx, messageID)
 		if err != nil {
@@ -99,41 +129,92 @@ func (p *emailProcessor) findExistingThread(ctx context.Context, email *models.E
 		}
 	}
 
-	// Case 4: Try subject-based matching as a fallback
-	threadID, _ = p.findThreadBySubjectMatch(ctx, email)
+	// Case 3: Try subject-based matching as a fallback
+	if !allowSubjectMatch {
+		return "", nil
+	}
+	threadID, _ := p.findThreadBySubjectMatch(ctx, email)
 	return threadID, nil
 }
 
-// checkForOrphanedParentMessage attempts to find a thread where this email is the parent of orphaned messages
-func (p *emailProcessor) checkForOrphanedParentMessage(ctx context.Context, email *models.Email) (string, error) {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "emailProcessor.checkForOrphanedParentMessage")
+// findOrphanRecord returns the missing-parent record of this email's Message-ID, if earlier
+// replies in the same mailbox were threaded without it
+func (p *emailProcessor) findOrphanRecord(ctx context.Context, email *models.Email) (*models.OrphanEmail, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailProcessor.findOrphanRecord")
 	defer span.Finish()
 	tracing.SetDefaultServiceSpanTags(ctx, span)
 
-	// Skip if this email is a reply or has references
-	if email.ReplyTo != "" || len(email.References) > 0 {
-		return "", nil
+	if email.MessageID == "" {
+		return nil, nil
 	}
 
-	orphan, err := p.repositories.OrphanEmailRepository.GetByMessageID(ctx, email.MessageID)
+	// scoped to the mailbox, other mailboxes may wait for a message with the same Message-ID
+	orphan, err := p.repositories.OrphanEmailRepository.GetByMessageID(ctx, email.MailboxID, email.MessageID)
 	if err != nil {
 		tracing.TraceErr(span, err)
-		return "", err
+		return nil, err
+	}
+	if orphan == nil || orphan.ThreadID == "" {
+		return nil, nil
 	}
 
-	// Return early if no matching orphan found
-	if orphan == nil || orphan.ThreadID == "" || orphan.MailboxID != email.MailboxID {
-		return "", nil
+	return orphan, nil
+}
+
+// adoptOrphanedChildren moves the replies recorded against a missing parent into the
+// thread the parent resolved to, along with the orphan records still open for them
+func (p *emailProcessor) adoptOrphanedChildren(ctx context.Context, orphan *models.OrphanEmail, threadID string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailProcessor.adoptOrphanedChildren")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.SetTag("orphan_thread_id", orphan.ThreadID)
+	span.SetTag("thread_id", threadID)
+
+	if orphan.ThreadID != threadID {
+		children, err := p.repositories.EmailRepository.ListByThread(ctx, orphan.ThreadID)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return err
+		}
+
+		for _, child := range children {
+			child.ThreadID = threadID
+			err = p.repositories.EmailRepository.Update(ctx, child)
+			if err != nil {
+				tracing.TraceErr(span, err)
+				return err
+			}
+
+			err = p.updateThreadMetadata(ctx, child, threadID)
+			if err != nil {
+				tracing.TraceErr(span, err)
+				return err
+			}
+		}
+
+		// the old thread is empty now
+		err = p.repositories.EmailThreadRepository.Delete(ctx, orphan.ThreadID)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return err
+		}
+
+		// other ancestors of the children may still be missing
+		err = p.repositories.OrphanEmailRepository.MoveToThread(ctx, orphan.ThreadID, threadID)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return err
+		}
 	}
 
-	// Clean up orphan records for this thread
-	err = p.repositories.OrphanEmailRepository.DeleteByThreadID(ctx, orphan.ThreadID)
+	// the parent has arrived, its record is resolved
+	err := p.repositories.OrphanEmailRepository.Delete(ctx, orphan.ID)
 	if err != nil {
 		tracing.TraceErr(span, err)
-		return "", err
+		return err
 	}
 
-	return orphan.ThreadID, nil
+	return nil
 }
 
 // findThreadByMessageID finds a thread containing a specific message ID
@@ -165,7 +246,14 @@ func (p *emailProcessor) findThreadBySubjectMatch(ctx context.Context, email *mo
 		return "", nil
 	}
 
-	threadID, err := p.findThreadBySubjectAndParticipants(ctx, normalizedSubject, email.MailboxID, email.AllParticipants())
+	emailTime := utils.Now()
+	if email.SentAt != nil {
+		emailTime = *email.SentAt
+	} else if email.ReceivedAt != nil {
+		emailTime = *email.ReceivedAt
+	}
+
+	threadID, err := p.findThreadBySubjectAndParticipants(ctx, normalizedSubject, email.MailboxID, email.AllParticipants(), emailTime)
 	if err != nil {
 		tracing.TraceErr(span, err)
 		// Just log this error and continue - subject matching is a best-effort fallback
@@ -176,8 +264,8 @@ func (p *emailProcessor) findThreadBySubjectMatch(ctx context.Context, email *mo
 	return threadID, nil
 }
 
-// findThreadBySubjectAndParticipants finds a thread by normalized subject and participants
-func (p *emailProcessor) findThreadBySubjectAndParticipants(ctx context.Context, subject string, mailboxID string, participants []string) (string, error) {
+// findThreadBySubjectAndParticipants finds a recent thread by normalized subject and participants
+func (p *emailProcessor) findThreadBySubjectAndParticipants(ctx context.Context, subject string, mailboxID string, participants []string, emailTime time.Time) (string, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "emailProcessor.findThreadBySubjectAndParticipants")
 	defer span.Finish()
 	span.SetTag("subject", subject)
@@ -195,20 +283,36 @@ func (p *emailProcessor) findThreadBySubjectAndParticipants(ctx context.Context,
 		return "", err
 	}
 
-	if len(threads) == 0 {
-		return "", nil
-	}
+	return selectSubjectMatch(threads, participants, emailTime, p.threading), nil
+}
 
-	// If only one thread matches, return it
-	if len(threads) == 1 {
-		return threads[0].ID, nil
+// selectSubjectMatch picks the thread with the most shared participants among the threads
+// active within the configured window. The mailbox owner takes part in every thread, so a
+// single shared participant is not enough to call two conversations the same.
+func selectSubjectMatch(threads []*models.EmailThread, participants []string, emailTime time.Time, cfg *config.ThreadingConfig) string {
+	windowDays, minShared := defaultSubjectMatchWindowDays, defaultSubjectMatchMinShared
+	if cfg != nil {
+		if cfg.SubjectMatchWindowDays > 0 {
+			windowDays = cfg.SubjectMatchWindowDays
+		}
+		if cfg.SubjectMatchMinSharedContacts > 0 {
+			minShared = cfg.SubjectMatchMinSharedContacts
+		}
+	}
+	// a one-to-one conversation can never share more than its own participants
+	if len(participants) < minShared {
+		minShared = len(participants)
 	}
+	window := time.Duration(windowDays) * 24 * time.Hour
 
-	// If multiple threads match, find the one with most participant overlap
 	bestMatchThreadID := ""
 	highestOverlap := 0
 
 	for _, thread := range threads {
+		if threadActivityGap(thread, emailTime) > window {
+			continue
+		}
+
 		// Calculate the number of participants that overlap
 		overlap := 0
 		for _, emailParticipant := range participants {
@@ -218,19 +322,32 @@ func (p *emailProcessor) findThreadBySubjectAndParticipants(ctx context.Context,
 		}
 
 		// If this thread has more overlap than the previous best match, use it
-		if overlap > highestOverlap {
+		if overlap >= minShared && overlap > highestOverlap {
 			highestOverlap = overlap
 			bestMatchThreadID = thread.ID
 		}
 	}
 
-	// Only return a match if we have at least one participant overlap
-	if highestOverlap > 0 {
-		return bestMatchThreadID, nil
+	return bestMatchThreadID
+}
+
+// threadActivityGap returns how far the email time lies outside the thread's message range
+func threadActivityGap(thread *models.EmailThread, emailTime time.Time) time.Duration {
+	first, last := thread.CreatedAt, thread.CreatedAt
+	if thread.FirstMessageAt != nil {
+		first = *thread.FirstMessageAt
+	}
+	if thread.LastMessageAt != nil {
+		last = *thread.LastMessageAt
 	}
 
-	// No good match found
-	return "", nil
+	switch {
+	case emailTime.After(last):
+		return emailTime.Sub(last)
+	case emailTime.Before(first):
+		return first.Sub(emailTime)
+	}
+	return 0
 }
 
 // updateThreadMetadata updates thread metadata with data from the new email
@@ -277,6 +394,11 @@ func (p *emailProcessor) updateThreadMetadata(ctx context.Context, email *models
 		}
 	}
 
+	if threadRecord.ParticipantRoles == nil {
+		threadRecord.ParticipantRoles = models.ThreadParticipants{}
+	}
+	threadRecord.ParticipantRoles.Record(email)
+
 	// Save thread updates
 	return p.repositories.EmailThreadRepository.Update(ctx, threadRecord)
 }
@@ -286,14 +408,18 @@ func (p *emailProcessor) createNewThread(ctx context.Context, email *models.Emai
 	span, ctx := opentracing.StartSpanFromContext(ctx, "emailProcesssor.createNewThread")
 	defer span.Finish()
 
+	participantRoles := models.ThreadParticipants{}
+	participantRoles.Record(email)
+
 	threadID, err := p.repositories.EmailThreadRepository.Create(ctx, &models.EmailThread{
-		MailboxID:      email.MailboxID,
-		Subject:        utils.NormalizeSubject(email.Subject),
-		Participants:   email.AllParticipants(),
-		LastMessageID:  email.MessageID,
-		HasAttachments: email.HasAttachment,
-		FirstMessageAt: email.ReceivedAt,
-		LastMessageAt:  email.ReceivedAt,
+		MailboxID:        email.MailboxID,
+		Subject:          utils.NormalizeSubject(email.Subject),
+		Participants:     email.AllParticipants(),
+		ParticipantRoles: participantRoles,
+		LastMessageID:    email.MessageID,
+		HasAttachments:   email.HasAttachment,
+		FirstMessageAt:   email.ReceivedAt,
+		LastMessageAt:    email.ReceivedAt,
 	})
 	if err != nil {
 		tracing.TraceErr(span, err)
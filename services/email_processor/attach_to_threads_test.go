@@ -0,0 +1,329 @@
+package email_processor
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/config"
+	"github.com/customeros/mailstack/internal/enum"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/repository"
+)
+
+func TestSelectSubjectMatch(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	daysAgo := func(days int) *time.Time {
+		at := now.AddDate(0, 0, -days)
+		return &at
+	}
+	cfg := &config.ThreadingConfig{SubjectMatchWindowDays: 30, SubjectMatchMinSharedContacts: 2}
+
+	tests := []struct {
+		name         string
+		threads      []*models.EmailThread
+		participants []string
+		expected     string
+	}{
+		{
+			name: "old conversation with the same subject is not merged",
+			threads: []*models.EmailThread{
+				{ID: "thrd_old", Participants: []string{"me@acme.com", "jane@example.com"}, FirstMessageAt: daysAgo(800), LastMessageAt: daysAgo(790)},
+			},
+			participants: []string{"me@acme.com", "jane@example.com"},
+			expected:     "",
+		},
+		{
+			name: "only the mailbox owner in common is not merged",
+			threads: []*models.EmailThread{
+				{ID: "thrd_other", Participants: []string{"me@acme.com", "bob@example.com"}, FirstMessageAt: daysAgo(3), LastMessageAt: daysAgo(2)},
+			},
+			participants: []string{"me@acme.com", "jane@example.com"},
+			expected:     "",
+		},
+		{
+			name: "recent conversation with the same people is matched",
+			threads: []*models.EmailThread{
+				{ID: "thrd_recent", Participants: []string{"me@acme.com", "jane@example.com"}, FirstMessageAt: daysAgo(5), LastMessageAt: daysAgo(1)},
+			},
+			participants: []string{"me@acme.com", "jane@example.com"},
+			expected:     "thrd_recent",
+		},
+		{
+			name: "best overlap inside the window wins",
+			threads: []*models.EmailThread{
+				{ID: "thrd_two", Participants: []string{"me@acme.com", "jane@example.com"}, FirstMessageAt: daysAgo(4), LastMessageAt: daysAgo(4)},
+				{ID: "thrd_three", Participants: []string{"me@acme.com", "jane@example.com", "ann@example.com"}, FirstMessageAt: daysAgo(10), LastMessageAt: daysAgo(9)},
+				{ID: "thrd_stale", Participants: []string{"me@acme.com", "jane@example.com", "ann@example.com"}, FirstMessageAt: daysAgo(400), LastMessageAt: daysAgo(400)},
+			},
+			participants: []string{"me@acme.com", "jane@example.com", "ann@example.com"},
+			expected:     "thrd_three",
+		},
+		{
+			name: "email predating the thread by more than the window is not merged",
+			threads: []*models.EmailThread{
+				{ID: "thrd_future", Participants: []string{"me@acme.com", "jane@example.com"}, FirstMessageAt: daysAgo(-60), LastMessageAt: daysAgo(-60)},
+			},
+			participants: []string{"me@acme.com", "jane@example.com"},
+			expected:     "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, selectSubjectMatch(tt.threads, tt.participants, now, cfg))
+		})
+	}
+}
+
+func TestSelectSubjectMatch_DefaultsWithoutConfig(t *testing.T) {
+	now := time.Now()
+	last := now.AddDate(0, 0, -45)
+	threads := []*models.EmailThread{
+		{ID: "thrd_1", Participants: []string{"me@acme.com", "jane@example.com"}, LastMessageAt: &last},
+	}
+
+	assert.Equal(t, "", selectSubjectMatch(threads, []string{"me@acme.com", "jane@example.com"}, now, nil))
+}
+
+type fakeThreadingEmailRepository struct {
+	interfaces.EmailRepository
+	emails  []*models.Email
+	updated []string
+}
+
+func (r *fakeThreadingEmailRepository) GetByMessageID(_ context.Context, messageID string) (*models.Email, error) {
+	for _, email := range r.emails {
+		if email.MessageID == messageID {
+			return email, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeThreadingEmailRepository) GetOutboundByMessageID(_ context.Context, mailboxID, messageID string) (*models.Email, error) {
+	for _, email := range r.emails {
+		if email.MailboxID == mailboxID && strings.Trim(email.MessageID, "<>") == strings.Trim(messageID, "<>") && email.Direction == enum.EmailDirectionOutbound {
+			return email, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeThreadingEmailRepository) ListByThread(_ context.Context, threadID string) ([]*models.Email, error) {
+	var emails []*models.Email
+	for _, email := range r.emails {
+		if email.ThreadID == threadID {
+			emails = append(emails, email)
+		}
+	}
+	return emails, nil
+}
+
+func (r *fakeThreadingEmailRepository) Update(_ context.Context, email *models.Email) error {
+	r.updated = append(r.updated, email.ID)
+	return nil
+}
+
+type fakeThreadRepository struct {
+	interfaces.EmailThreadRepository
+	threads map[string]*models.EmailThread
+	deleted []string
+}
+
+func (r *fakeThreadRepository) GetByID(_ context.Context, id string) (*models.EmailThread, error) {
+	return r.threads[id], nil
+}
+
+func (r *fakeThreadRepository) Create(_ context.Context, thread *models.EmailThread) (string, error) {
+	thread.ID = "thrd_new"
+	r.threads[thread.ID] = thread
+	return thread.ID, nil
+}
+
+func (r *fakeThreadRepository) Update(_ context.Context, thread *models.EmailThread) error {
+	r.threads[thread.ID] = thread
+	return nil
+}
+
+func (r *fakeThreadRepository) Delete(_ context.Context, threadID string) error {
+	r.deleted = append(r.deleted, threadID)
+	delete(r.threads, threadID)
+	return nil
+}
+
+type fakeOrphanRepository struct {
+	interfaces.OrphanEmailRepository
+	orphans []*models.OrphanEmail
+	created []*models.OrphanEmail
+	moved   [][2]string
+	deleted []string
+}
+
+func (r *fakeOrphanRepository) GetByMessageID(_ context.Context, mailboxID, messageID string) (*models.OrphanEmail, error) {
+	for _, orphan := range r.orphans {
+		if orphan.MailboxID == mailboxID && orphan.MessageID == messageID {
+			return orphan, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeOrphanRepository) Create(_ context.Context, orphan *models.OrphanEmail) (string, error) {
+	r.created = append(r.created, orphan)
+	return "orpn_new", nil
+}
+
+func (r *fakeOrphanRepository) MoveToThread(_ context.Context, fromThreadID, toThreadID string) error {
+	r.moved = append(r.moved, [2]string{fromThreadID, toThreadID})
+	return nil
+}
+
+func (r *fakeOrphanRepository) Delete(_ context.Context, id string) error {
+	r.deleted = append(r.deleted, id)
+	return nil
+}
+
+// newThreadingProcessor has a conversation in thrd_parent and two replies waiting in
+// thrd_orphan for their parent <parent@acme.com>
+func newThreadingProcessor() (*emailProcessor, *fakeThreadingEmailRepository, *fakeThreadRepository, *fakeOrphanRepository) {
+	emails := &fakeThreadingEmailRepository{emails: []*models.Email{
+		{ID: "email_root", MessageID: "<root@acme.com>", ThreadID: "thrd_parent", MailboxID: "mbox_1"},
+		{ID: "email_child_1", MessageID: "<child1@example.com>", ThreadID: "thrd_orphan", MailboxID: "mbox_1", ReplyTo: "<parent@acme.com>", FromAddress: "jane@example.com"},
+		{ID: "email_child_2", MessageID: "<child2@example.com>", ThreadID: "thrd_orphan", MailboxID: "mbox_1", ReplyTo: "<child1@example.com>", FromAddress: "bob@example.com"},
+	}}
+	threads := &fakeThreadRepository{threads: map[string]*models.EmailThread{
+		"thrd_parent": {ID: "thrd_parent"},
+		"thrd_orphan": {ID: "thrd_orphan"},
+	}}
+	orphans := &fakeOrphanRepository{orphans: []*models.OrphanEmail{
+		{ID: "orpn_parent", MessageID: "<parent@acme.com>", ReferencedBy: "<child1@example.com>", ThreadID: "thrd_orphan", MailboxID: "mbox_1"},
+	}}
+
+	p := &emailProcessor{repositories: &repository.Repositories{
+		EmailRepository:       emails,
+		EmailThreadRepository: threads,
+		OrphanEmailRepository: orphans,
+	}}
+	return p, emails, threads, orphans
+}
+
+func TestAttachEmailToThread_LateParentAdoptsChildren(t *testing.T) {
+	p, emails, threads, orphans := newThreadingProcessor()
+	parent := &models.Email{ID: "email_parent", MessageID: "<parent@acme.com>", MailboxID: "mbox_1", ReplyTo: "<root@acme.com>", FromAddress: "me@acme.com"}
+
+	require.NoError(t, p.attachEmailToThread(context.Background(), parent))
+
+	assert.Equal(t, "thrd_parent", parent.ThreadID)
+	for _, email := range emails.emails {
+		assert.Equal(t, "thrd_parent", email.ThreadID, email.ID)
+	}
+	assert.Equal(t, []string{"email_child_1", "email_child_2"}, emails.updated)
+	assert.Subset(t, threads.threads["thrd_parent"].Participants, []string{"me@acme.com", "jane@example.com", "bob@example.com"})
+
+	assert.Equal(t, []string{"thrd_orphan"}, threads.deleted)
+	assert.Equal(t, [][2]string{{"thrd_orphan", "thrd_parent"}}, orphans.moved)
+	assert.Equal(t, []string{"orpn_parent"}, orphans.deleted)
+	assert.Empty(t, orphans.created)
+}
+
+func TestAttachEmailToThread_LateParentJoinsOrphanThread(t *testing.T) {
+	p, emails, threads, orphans := newThreadingProcessor()
+	// the parent's own parent has not arrived either
+	parent := &models.Email{ID: "email_parent", MessageID: "<parent@acme.com>", MailboxID: "mbox_1", ReplyTo: "<unknown@acme.com>", FromAddress: "me@acme.com"}
+
+	require.NoError(t, p.attachEmailToThread(context.Background(), parent))
+
+	assert.Equal(t, "thrd_orphan", parent.ThreadID)
+	assert.Empty(t, emails.updated)
+	assert.Empty(t, threads.deleted)
+	assert.Empty(t, orphans.moved)
+	assert.Equal(t, []string{"orpn_parent"}, orphans.deleted)
+	require.Len(t, orphans.created, 1)
+	assert.Equal(t, "<unknown@acme.com>", orphans.created[0].MessageID)
+	assert.Equal(t, "thrd_orphan", orphans.created[0].ThreadID)
+}
+
+func TestAttachEmailToThread_SameMessageIDInAnotherTenant(t *testing.T) {
+	p, emails, threads, orphans := newThreadingProcessor()
+	// a message with the Message-ID the replies of mbox_1 wait for arrives in another tenant's mailbox
+	other := &models.Email{ID: "email_other", MessageID: "<parent@acme.com>", MailboxID: "mbox_other_tenant", FromAddress: "eve@evil.com"}
+
+	require.NoError(t, p.attachEmailToThread(context.Background(), other))
+
+	assert.Equal(t, "thrd_new", other.ThreadID)
+	assert.Empty(t, emails.updated)
+	assert.Empty(t, threads.deleted)
+	assert.Empty(t, orphans.moved)
+	assert.Empty(t, orphans.deleted)
+}
+
+func TestFindOrphanRecord(t *testing.T) {
+	tests := []struct {
+		name     string
+		orphans  []*models.OrphanEmail
+		email    *models.Email
+		expected string
+	}{
+		{
+			name:     "email without message id",
+			orphans:  []*models.OrphanEmail{{ID: "orpn_1", MessageID: "", ThreadID: "thrd_1", MailboxID: "mbox_1"}},
+			email:    &models.Email{MailboxID: "mbox_1"},
+			expected: "",
+		},
+		{
+			name:     "nothing waiting for the email",
+			email:    &models.Email{MessageID: "<a@acme.com>", MailboxID: "mbox_1"},
+			expected: "",
+		},
+		{
+			name:     "record without a thread",
+			orphans:  []*models.OrphanEmail{{ID: "orpn_1", MessageID: "<a@acme.com>", MailboxID: "mbox_1"}},
+			email:    &models.Email{MessageID: "<a@acme.com>", MailboxID: "mbox_1"},
+			expected: "",
+		},
+		{
+			name:     "record of another mailbox",
+			orphans:  []*models.OrphanEmail{{ID: "orpn_1", MessageID: "<a@acme.com>", ThreadID: "thrd_1", MailboxID: "mbox_2"}},
+			email:    &models.Email{MessageID: "<a@acme.com>", MailboxID: "mbox_1"},
+			expected: "",
+		},
+		{
+			name:     "replies waiting in the same mailbox",
+			orphans:  []*models.OrphanEmail{{ID: "orpn_1", MessageID: "<a@acme.com>", ThreadID: "thrd_1", MailboxID: "mbox_1"}},
+			email:    &models.Email{MessageID: "<a@acme.com>", MailboxID: "mbox_1"},
+			expected: "orpn_1",
+		},
+		{
+			name: "same message id waiting in two tenants",
+			orphans: []*models.OrphanEmail{
+				{ID: "orpn_other", MessageID: "<a@acme.com>", ThreadID: "thrd_other", MailboxID: "mbox_other_tenant"},
+				{ID: "orpn_1", MessageID: "<a@acme.com>", ThreadID: "thrd_1", MailboxID: "mbox_1"},
+			},
+			email:    &models.Email{MessageID: "<a@acme.com>", MailboxID: "mbox_1"},
+			expected: "orpn_1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &emailProcessor{repositories: &repository.Repositories{
+				OrphanEmailRepository: &fakeOrphanRepository{orphans: tt.orphans},
+			}}
+
+			orphan, err := p.findOrphanRecord(context.Background(), tt.email)
+			require.NoError(t, err)
+			if tt.expected == "" {
+				assert.Nil(t, orphan)
+				return
+			}
+			require.NotNil(t, orphan)
+			assert.Equal(t, tt.expected, orphan.ID)
+		})
+	}
+}
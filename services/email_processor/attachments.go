@@ -0,0 +1,156 @@
+package email_processor
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+
+	"github.com/customeros/mailstack/dto"
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/enum"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+// matches src=cid:..., src="cid:..." and src='cid:...'
+var cidSrcPattern = regexp.MustCompile(`(?i)\bsrc\s*=\s*(?:"cid:([^"]*)"|'cid:([^']*)'|cid:([^\s>]+))`)
+
+func (p *emailProcessor) storeAttachments(ctx context.Context, email *models.Email, attachments []*models.EmailAttachment, files []*interfaces.AttachmentFile) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailProcessor.storeAttachments")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+
+	if len(attachments) == 0 {
+		return nil
+	}
+
+	data := make(map[string][]byte, len(files))
+	for _, file := range files {
+		if file != nil {
+			data[file.ID] = file.Data
+		}
+	}
+
+	inlineURLs := make(map[string]string)
+	var previews []previewSource
+	for _, attachment := range attachments {
+		if attachment == nil {
+			continue
+		}
+		content, ok := data[attachment.ID]
+		if !ok || len(content) == 0 {
+			// no content to upload (e.g. over the size cap), keep the metadata only
+			err := p.repositories.EmailAttachmentRepository.StoreMetadata(ctx, attachment, email.ThreadID, email.ID)
+			if err != nil {
+				tracing.TraceErr(span, err)
+				return err
+			}
+			continue
+		}
+
+		infected, err := p.scanAttachment(ctx, email, attachment, content)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return err
+		}
+		if infected {
+			continue
+		}
+
+		err = p.repositories.EmailAttachmentRepository.Store(ctx, attachment, email.ThreadID, email.ID, content)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return err
+		}
+
+		if attachment.IsInline && attachment.ContentID != "" {
+			inlineURLs[normalizeContentID(attachment.ContentID)] = attachment.PublicURL()
+		}
+
+		// deduplicated files keep the preview generated the first time
+		if p.previewsEnabled() && attachment.PreviewKey == "" && previewSupported(attachment.ContentType) {
+			stored := *attachment
+			previews = append(previews, previewSource{attachment: &stored, content: content})
+		}
+	}
+
+	if len(previews) > 0 && !p.previewQueue.enqueue(previewJob{ctx: context.WithoutCancel(ctx), sources: previews}, p.generatePreviews) {
+		span.LogFields(log.String("previews", "skipped, queue is full"))
+	}
+
+	email.BodyHTML = rewriteInlineImages(email.BodyHTML, inlineURLs)
+	return nil
+}
+
+// scanAttachment runs the configured scanner over the content. Infected attachments are
+// recorded without their content and reported; a failed scan does not block storage.
+func (p *emailProcessor) scanAttachment(ctx context.Context, email *models.Email, attachment *models.EmailAttachment, content []byte) (bool, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailProcessor.scanAttachment")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.SetTag("attachment.id", attachment.ID)
+
+	result, err := p.scanner.Scan(ctx, content)
+	attachment.ScannedAt = utils.NowPtr()
+	switch {
+	case err != nil:
+		tracing.TraceErr(span, err)
+		attachment.ScanStatus = enum.AttachmentScanFailed
+		return false, nil
+	case result.Skipped:
+		attachment.ScanStatus = enum.AttachmentNotScanned
+		attachment.ScannedAt = nil
+		return false, nil
+	case !result.Infected:
+		attachment.ScanStatus = enum.AttachmentClean
+		return false, nil
+	}
+
+	span.LogFields(log.String("signature", result.Signature))
+	attachment.ScanStatus = enum.AttachmentInfected
+	attachment.ScanSignature = result.Signature
+
+	err = p.repositories.EmailAttachmentRepository.StoreMetadata(ctx, attachment, email.ThreadID, email.ID)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return true, err
+	}
+
+	err = p.eventsService.Publisher.PublishFanoutEvent(ctx, email.ID, enum.EMAIL, dto.AttachmentInfected{
+		AttachmentID: attachment.ID,
+		EmailID:      email.ID,
+		Filename:     attachment.Filename,
+		Signature:    result.Signature,
+	})
+	if err != nil {
+		tracing.TraceErr(span, err)
+	}
+
+	return true, nil
+}
+
+// rewriteInlineImages replaces cid: image sources with the stored attachment URLs.
+// References without a matching attachment are left untouched.
+func rewriteInlineImages(body string, urlsByContentID map[string]string) string {
+	if body == "" || len(urlsByContentID) == 0 {
+		return body
+	}
+
+	return cidSrcPattern.ReplaceAllStringFunc(body, func(match string) string {
+		groups := cidSrcPattern.FindStringSubmatch(match)
+		contentID := groups[1] + groups[2] + groups[3]
+		url, ok := urlsByContentID[normalizeContentID(contentID)]
+		if !ok {
+			return match
+		}
+		return `src="` + url + `"`
+	})
+}
+
+func normalizeContentID(contentID string) string {
+	return strings.ToLower(strings.Trim(strings.TrimSpace(contentID), "<>"))
+}
@@ -0,0 +1,51 @@
+package email_processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRewriteInlineImages(t *testing.T) {
+	urls := map[string]string{
+		"logo@acme.com": "https://files.cust.cx/image/file_1.png",
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "double quoted",
+			input:    `<img src="cid:logo@acme.com">`,
+			expected: `<img src="https://files.cust.cx/image/file_1.png">`,
+		},
+		{
+			name:     "single quoted",
+			input:    `<img src='cid:logo@acme.com'>`,
+			expected: `<img src="https://files.cust.cx/image/file_1.png">`,
+		},
+		{
+			name:     "unquoted with uppercase scheme",
+			input:    `<img alt=x SRC=CID:logo@acme.com>`,
+			expected: `<img alt=x src="https://files.cust.cx/image/file_1.png">`,
+		},
+		{
+			name:     "bracketed content id",
+			input:    `<img src="cid:<logo@acme.com>">`,
+			expected: `<img src="https://files.cust.cx/image/file_1.png">`,
+		},
+		{
+			name:     "unknown content id left as is",
+			input:    `<img src="cid:other@acme.com">`,
+			expected: `<img src="cid:other@acme.com">`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, rewriteInlineImages(tt.input, urls))
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package email_processor
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+
+	"github.com/customeros/mailstack/dto"
+	"github.com/customeros/mailstack/internal/enum"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+// ProcessAutoResponder notifies downstream systems of an auto-reply, with the out of office dates
+// when they can be read from the reply, so outreach to the sender can be paused
+func (p *emailProcessor) ProcessAutoResponder(ctx context.Context, email *models.Email) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailProcessor.ProcessAutoResponder")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+
+	event := dto.EmailAutoResponded{
+		MailboxID:         email.MailboxID,
+		MessageID:         email.MessageID,
+		FromAddress:       email.FromAddress,
+		FromName:          email.FromName,
+		Subject:           email.Subject,
+		Reason:            email.ClassificationReason,
+		OriginalRecipient: email.FromAddress,
+	}
+
+	if email.InReplyTo != "" {
+		original, err := p.repositories.EmailRepository.GetOutboundByMessageID(ctx, email.MailboxID, email.InReplyTo)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return err
+		}
+		if original != nil {
+			event.OriginalEmailID = original.ID
+			event.OriginalRecipient = originalRecipient(original, email.FromAddress)
+		}
+	}
+
+	reference := utils.Now()
+	if email.ReceivedAt != nil {
+		reference = *email.ReceivedAt
+	}
+	event.OutOfOfficeFrom, event.OutOfOfficeUntil = parseOutOfOfficeDates(email.Subject+"\n"+email.BodyText, reference)
+	span.LogFields(log.Bool("outOfOfficeDates", event.OutOfOfficeUntil != nil))
+
+	entityID := event.OriginalEmailID
+	if entityID == "" {
+		entityID = email.ID
+	}
+	err := p.eventsService.Publisher.PublishFanoutEvent(ctx, entityID, enum.EMAIL, event)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	return nil
+}
+
+// originalRecipient is the recipient of the original email that the auto-reply came from. Replies
+// from another address, e.g. a personal address behind an alias, fall back to the only recipient.
+func originalRecipient(original *models.Email, autoReplyFrom string) string {
+	recipients := append(append([]string{}, original.ToAddresses...), original.CcAddresses...)
+	for _, recipient := range recipients {
+		if strings.EqualFold(recipient, autoReplyFrom) {
+			return recipient
+		}
+	}
+	if len(original.ToAddresses) == 1 {
+		return original.ToAddresses[0]
+	}
+	return autoReplyFrom
+}
+
+const (
+	// only the start of the reply is read, the rest is mostly the quoted original
+	outOfOfficeScanLimit = 2000
+	oooMonthNames        = `jan(?:uary)?|feb(?:ruary)?|mar(?:ch)?|apr(?:il)?|may|june?|july?|aug(?:ust)?|sept?(?:ember)?|oct(?:ober)?|nov(?:ember)?|dec(?:ember)?`
+	oooWeekday           = `(?:(?:mon|tues?|wed(?:nes)?|thu(?:rs)?|fri|sat(?:ur)?|sun)(?:day)?\.?,?\s*)?`
+)
+
+var (
+	oooDatePatterns = []struct {
+		pattern *regexp.Regexp
+		parse   func(match []string) (year, month, day int)
+	}{
+		// 2025-03-14
+		{regexp.MustCompile(`\b(\d{4})-(\d{1,2})-(\d{1,2})\b`), func(m []string) (int, int, int) {
+			return atoi(m[1]), atoi(m[2]), atoi(m[3])
+		}},
+		// 14 March 2025, 14th of march
+		{regexp.MustCompile(`\b(\d{1,2})(?:st|nd|rd|th)?\.?\s+(?:of\s+)?(` + oooMonthNames + `)\.?(?:,?\s+(\d{4}))?\b`), func(m []string) (int, int, int) {
+			return atoi(m[3]), int(oooMonth(m[2])), atoi(m[1])
+		}},
+		// March 14, 2025
+		{regexp.MustCompile(`\b(` + oooMonthNames + `)\.?\s+(\d{1,2})(?:st|nd|rd|th)?(?:,?\s+(\d{4}))?\b`), func(m []string) (int, int, int) {
+			return atoi(m[3]), int(oooMonth(m[1])), atoi(m[2])
+		}},
+		// 14.03.2025 is day first, 03/14/2025 month first unless the first number can only be a day
+		{regexp.MustCompile(`\b(\d{1,2})([./])(\d{1,2})(?:[./](\d{2}|\d{4}))?\b`), func(m []string) (int, int, int) {
+			first, second := atoi(m[1]), atoi(m[3])
+			if m[2] == "/" && first <= 12 {
+				first, second = second, first
+			}
+			return atoi(m[4]), second, first
+		}},
+	}
+
+	oooFromContext  = regexp.MustCompile(`(?:from|since|starting(?:\s+on)?|between|leaving(?:\s+on)?|as\s+of)\s+(?:on\s+)?(?:the\s+)?` + oooWeekday + `$`)
+	oooUntilContext = regexp.MustCompile(`(?:until|till|til|through|thru|back(?:\s+in\s+the\s+office)?(?:\s+on)?|return(?:s|ing)?(?:\s+to\s+the\s+office)?(?:\s+on)?)\s+(?:on\s+)?(?:the\s+)?` + oooWeekday + `$`)
+	// range separators only count once a start date was found
+	oooRangeContext = regexp.MustCompile(`(?:to|and|-|–)\s+(?:the\s+)?` + oooWeekday + `$`)
+)
+
+type oooDate struct {
+	start, end int
+	date       time.Time
+}
+
+// parseOutOfOfficeDates reads the absence dates from common out of office phrasings like
+// "out of the office until March 14", "back on 17.03.2025" or "from 3 March to 14 March".
+// Dates without year are placed at or after the reference time.
+func parseOutOfOfficeDates(text string, reference time.Time) (*time.Time, *time.Time) {
+	if len(text) > outOfOfficeScanLimit {
+		text = text[:outOfOfficeScanLimit]
+	}
+	text = strings.ToLower(text)
+
+	dates := findOutOfOfficeDates(text, reference)
+
+	var from, until *time.Time
+	previousEnd := 0
+	for _, d := range dates {
+		contextStart := d.start - 60
+		if contextStart < previousEnd {
+			contextStart = previousEnd
+		}
+		if contextStart < 0 {
+			contextStart = 0
+		}
+		before := strings.TrimRight(text[contextStart:d.start], " ") + " "
+		previousEnd = d.end
+
+		date := d.date
+		switch {
+		case oooUntilContext.MatchString(before), from != nil && oooRangeContext.MatchString(before):
+			if until == nil {
+				until = &date
+			}
+		case oooFromContext.MatchString(before):
+			if from == nil {
+				from = &date
+			}
+		}
+		if until != nil {
+			break
+		}
+	}
+
+	if from != nil && until != nil && until.Before(*from) {
+		return nil, nil
+	}
+	return from, until
+}
+
+// findOutOfOfficeDates returns the valid dates of the text in order, overlapping matches are dropped
+func findOutOfOfficeDates(text string, reference time.Time) []oooDate {
+	var dates []oooDate
+	for _, datePattern := range oooDatePatterns {
+		for _, index := range datePattern.pattern.FindAllStringSubmatchIndex(text, -1) {
+			match := make([]string, len(index)/2)
+			for i := range match {
+				if index[2*i] >= 0 {
+					match[i] = text[index[2*i]:index[2*i+1]]
+				}
+			}
+			year, month, day := datePattern.parse(match)
+			if date, valid := oooCalendarDate(year, month, day, reference); valid {
+				dates = append(dates, oooDate{start: index[0], end: index[1], date: date})
+			}
+		}
+	}
+
+	sort.SliceStable(dates, func(i, j int) bool { return dates[i].start < dates[j].start })
+	var result []oooDate
+	for _, d := range dates {
+		if len(result) > 0 && d.start < result[len(result)-1].end {
+			continue
+		}
+		result = append(result, d)
+	}
+	return result
+}
+
+func oooCalendarDate(year, month, day int, reference time.Time) (time.Time, bool) {
+	if month < 1 || month > 12 || day < 1 || day > 31 {
+		return time.Time{}, false
+	}
+
+	yearGiven := year > 0
+	switch {
+	case !yearGiven:
+		year = reference.Year()
+	case year < 100:
+		year += 2000
+	}
+
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if date.Day() != day {
+		return time.Time{}, false // e.g. 31 April
+	}
+	// a reply in December about January means next year
+	if !yearGiven && date.Before(reference.AddDate(0, 0, -7)) {
+		date = date.AddDate(1, 0, 0)
+	}
+	return date, true
+}
+
+func oooMonth(name string) time.Month {
+	months := []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	for i, prefix := range months {
+		if strings.HasPrefix(strings.ToLower(name), prefix) {
+			return time.Month(i + 1)
+		}
+	}
+	return 0
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
@@ -0,0 +1,53 @@
+package email_processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/customeros/mailstack/internal/models"
+)
+
+func TestParseOutOfOfficeDates(t *testing.T) {
+	reference := time.Date(2025, 3, 5, 9, 0, 0, 0, time.UTC)
+	date := func(year int, month time.Month, day int) *time.Time {
+		d := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+		return &d
+	}
+
+	tests := []struct {
+		name  string
+		text  string
+		from  *time.Time
+		until *time.Time
+	}{
+		{"until month name", "I am out of the office until Monday, March 17th with limited access to email.", nil, date(2025, 3, 17)},
+		{"back on day first", "Automatic reply: I'll be back on 17.03.2025.", nil, date(2025, 3, 17)},
+		{"returning on iso", "Returning on 2025-03-20, for urgent matters contact ops@acme.com", nil, date(2025, 3, 20)},
+		{"range with month names", "I'm on vacation from 3 March to 14 March.", date(2025, 3, 3), date(2025, 3, 14)},
+		{"range with slashes", "Out of office between 03/10/2025 and 03/14/2025", date(2025, 3, 10), date(2025, 3, 14)},
+		{"day that can only be first", "Back in the office on 14/03", nil, date(2025, 3, 14)},
+		{"next year", "Out until January 6", nil, date(2026, 1, 6)},
+		{"no dates", "Thank you for your email, I will answer as soon as possible.", nil, nil},
+		{"dates without context are ignored", "Sent on March 1 from my phone. Meeting at 10.30", nil, nil},
+		{"invalid date", "Back on 31 April", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, until := parseOutOfOfficeDates(tt.text, reference)
+			assert.Equal(t, tt.from, from)
+			assert.Equal(t, tt.until, until)
+		})
+	}
+}
+
+func TestOriginalRecipient(t *testing.T) {
+	original := &models.Email{ToAddresses: []string{"jane@acme.com", "bob@acme.com"}}
+	assert.Equal(t, "jane@acme.com", originalRecipient(original, "Jane@acme.com"))
+	assert.Equal(t, "jd@personal.com", originalRecipient(original, "jd@personal.com"))
+
+	original = &models.Email{ToAddresses: []string{"sales@acme.com"}}
+	assert.Equal(t, "sales@acme.com", originalRecipient(original, "jane@acme.com"))
+}
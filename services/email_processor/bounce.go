@@ -0,0 +1,192 @@
+package email_processor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/mail"
+	"net/textproto"
+	"regexp"
+	"strings"
+
+	"github.com/jhillyerd/enmime"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+
+	"github.com/customeros/mailstack/dto"
+	"github.com/customeros/mailstack/internal/enum"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+)
+
+var messageIDPattern = regexp.MustCompile(`(?i)message-id:\s*<([^>\s]+)>`)
+
+// bounceReport holds the data extracted from a delivery status notification (RFC 3464)
+type bounceReport struct {
+	FailedRecipients  []string
+	OriginalMessageID string
+	Status            string
+	DiagnosticCode    string
+}
+
+// ProcessBounce parses a bounce notification, marks the original outbound email as
+// bounced and notifies downstream systems
+func (p *emailProcessor) ProcessBounce(ctx context.Context, email *models.Email, rawMessage []byte) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailProcessor.ProcessBounce")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+
+	report := parseBounceReport(rawMessage, email)
+	if report.OriginalMessageID == "" {
+		span.LogFields(log.String("result", "original message id not found"))
+		return nil
+	}
+	span.LogFields(log.String("originalMessageId", report.OriginalMessageID))
+
+	// a bounce can only concern mail sent from the mailbox that received it
+	original, err := p.repositories.EmailRepository.GetOutboundByMessageID(ctx, email.MailboxID, report.OriginalMessageID)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+	if original == nil {
+		span.LogFields(log.String("result", "original email not found"))
+		return nil
+	}
+
+	original.Status = enum.EmailStatusBounced
+	original.StatusDetail = report.statusDetail()
+	err = p.repositories.EmailRepository.Update(ctx, original)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	err = p.eventsService.Publisher.PublishFanoutEvent(ctx, original.ID, enum.EMAIL, dto.EmailBounced{
+		EmailID:          original.ID,
+		MessageID:        original.MessageID,
+		MailboxID:        original.MailboxID,
+		FailedRecipients: report.FailedRecipients,
+		Status:           report.Status,
+		DiagnosticCode:   report.DiagnosticCode,
+	})
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *bounceReport) statusDetail() string {
+	detail := "bounced"
+	if len(r.FailedRecipients) > 0 {
+		detail = fmt.Sprintf("%s: %s", detail, strings.Join(r.FailedRecipients, ", "))
+	}
+	if r.Status != "" {
+		detail = fmt.Sprintf("%s (%s)", detail, r.Status)
+	}
+	if r.DiagnosticCode != "" {
+		detail = fmt.Sprintf("%s %s", detail, r.DiagnosticCode)
+	}
+	return detail
+}
+
+// parseBounceReport extracts the failed recipients and original Message-ID from a bounce,
+// falling back to headers and body text when the message is not a well-formed DSN
+func parseBounceReport(rawMessage []byte, email *models.Email) *bounceReport {
+	report := &bounceReport{}
+
+	if len(rawMessage) > 0 {
+		envelope, err := enmime.ReadEnvelope(bytes.NewReader(rawMessage))
+		if err == nil && envelope.Root != nil {
+			parts := envelope.Root.BreadthMatchAll(func(part *enmime.Part) bool { return true })
+			for _, part := range parts {
+				switch strings.ToLower(part.ContentType) {
+				case "message/delivery-status", "message/global-delivery-status":
+					parseDeliveryStatus(part.Content, report)
+				case "message/rfc822", "text/rfc822-headers", "message/rfc822-headers", "message/global-headers":
+					if report.OriginalMessageID == "" {
+						report.OriginalMessageID = parseOriginalMessageID(part.Content)
+					}
+				}
+			}
+		}
+	}
+
+	if email == nil {
+		return report
+	}
+
+	if len(report.FailedRecipients) == 0 {
+		if headers, err := email.Headers(); err == nil && headers != nil {
+			report.FailedRecipients = headers.XFailedRecipients
+		}
+	}
+
+	if report.OriginalMessageID == "" {
+		if match := messageIDPattern.FindStringSubmatch(email.BodyText); len(match) == 2 {
+			report.OriginalMessageID = match[1]
+		} else if email.InReplyTo != "" {
+			report.OriginalMessageID = email.InReplyTo
+		}
+	}
+
+	return report
+}
+
+// parseDeliveryStatus reads the per-message and per-recipient field groups of a
+// message/delivery-status body and records the recipients whose action is failed
+func parseDeliveryStatus(content []byte, report *bounceReport) {
+	reader := textproto.NewReader(bufio.NewReader(bytes.NewReader(content)))
+	for {
+		fields, err := reader.ReadMIMEHeader()
+		if len(fields) > 0 {
+			action := strings.ToLower(strings.TrimSpace(fields.Get("Action")))
+			recipient := addressFromTypedField(fields.Get("Final-Recipient"))
+			if recipient == "" {
+				recipient = addressFromTypedField(fields.Get("Original-Recipient"))
+			}
+			if recipient != "" && (action == "failed" || action == "") {
+				report.FailedRecipients = append(report.FailedRecipients, recipient)
+				if report.Status == "" {
+					report.Status = strings.TrimSpace(fields.Get("Status"))
+				}
+				if report.DiagnosticCode == "" {
+					report.DiagnosticCode = strings.TrimSpace(fields.Get("Diagnostic-Code"))
+				}
+			}
+		}
+		if err != nil {
+			// io.EOF after the last group, anything else is a malformed report
+			return
+		}
+	}
+}
+
+// addressFromTypedField strips the address-type prefix, e.g. "rfc822; jane@example.com"
+func addressFromTypedField(value string) string {
+	value = strings.TrimSpace(value)
+	if idx := strings.Index(value, ";"); idx >= 0 {
+		value = value[idx+1:]
+	}
+	return strings.ToLower(strings.Trim(strings.TrimSpace(value), "<>"))
+}
+
+// parseOriginalMessageID reads the Message-ID from an embedded original message or its headers
+func parseOriginalMessageID(content []byte) string {
+	if !bytes.Contains(content, []byte("\n\n")) && !bytes.Contains(content, []byte("\r\n\r\n")) {
+		content = append(bytes.Clone(content), []byte("\r\n\r\n")...)
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(content))
+	if err == nil {
+		if id := strings.Trim(strings.TrimSpace(msg.Header.Get("Message-Id")), "<>"); id != "" {
+			return id
+		}
+	}
+	if match := messageIDPattern.FindSubmatch(content); len(match) == 2 {
+		return string(match[1])
+	}
+	return ""
+}
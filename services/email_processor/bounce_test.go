@@ -0,0 +1,90 @@
+package email_processor
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/internal/enum"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/repository"
+)
+
+func TestParseBounceReport(t *testing.T) {
+	raw := strings.ReplaceAll(`From: Mail Delivery System <MAILER-DAEMON@mx.example.com>
+To: jane@acme.com
+Subject: Undelivered Mail Returned to Sender
+MIME-Version: 1.0
+Content-Type: multipart/report; report-type=delivery-status; boundary="BOUNDARY"
+
+--BOUNDARY
+Content-Type: text/plain
+
+This is the mail system at host mx.example.com.
+
+--BOUNDARY
+Content-Type: message/delivery-status
+
+Reporting-MTA: dns; mx.example.com
+
+Final-Recipient: rfc822; John@Example.com
+Original-Recipient: rfc822;john@example.com
+Action: failed
+Status: 5.1.1
+Diagnostic-Code: smtp; 550 5.1.1 <john@example.com>: Recipient address rejected
+
+--BOUNDARY
+Content-Type: text/rfc822-headers
+
+From: jane@acme.com
+To: john@example.com
+Subject: Hello
+Message-ID: <abc123@acme.com>
+
+--BOUNDARY--
+`, "\n", "\r\n")
+
+	report := parseBounceReport([]byte(raw), nil)
+
+	assert.Equal(t, []string{"john@example.com"}, report.FailedRecipients)
+	assert.Equal(t, "abc123@acme.com", report.OriginalMessageID)
+	assert.Equal(t, "5.1.1", report.Status)
+	assert.Equal(t, "smtp; 550 5.1.1 <john@example.com>: Recipient address rejected", report.DiagnosticCode)
+}
+
+func TestProcessBounce_IgnoresEmailsOfOtherMailboxes(t *testing.T) {
+	raw := strings.ReplaceAll(`From: MAILER-DAEMON@mx.example.com
+To: jane@other.com
+Subject: Undelivered Mail Returned to Sender
+Content-Type: multipart/report; report-type=delivery-status; boundary="BOUNDARY"
+
+--BOUNDARY
+Content-Type: message/delivery-status
+
+Final-Recipient: rfc822; john@example.com
+Action: failed
+Status: 5.1.1
+
+--BOUNDARY
+Content-Type: text/rfc822-headers
+
+Message-ID: <abc123@acme.com>
+
+--BOUNDARY--
+`, "\n", "\r\n")
+
+	sent := &models.Email{ID: "email_sent", MailboxID: "mbox_1", MessageID: "abc123@acme.com", Direction: enum.EmailDirectionOutbound, Status: enum.EmailStatusSent}
+	received := &models.Email{ID: "email_received", MailboxID: "mbox_2", MessageID: "abc123@acme.com", Direction: enum.EmailDirectionInbound}
+	emails := &fakeThreadingEmailRepository{emails: []*models.Email{sent, received}}
+	p := &emailProcessor{repositories: &repository.Repositories{EmailRepository: emails}}
+
+	dsn := &models.Email{ID: "email_dsn", MailboxID: "mbox_2"}
+	require.NoError(t, p.ProcessBounce(context.Background(), dsn, []byte(raw)))
+
+	assert.Empty(t, emails.updated)
+	assert.Equal(t, enum.EmailStatusSent, sent.Status)
+	assert.Empty(t, received.Status)
+}
@@ -0,0 +1,193 @@
+package email_processor
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jhillyerd/enmime"
+
+	"github.com/customeros/mailstack/internal/models"
+)
+
+// iCalendar (RFC 5545) date-time layouts
+const (
+	icsDateTimeUTC = "20060102T150405Z"
+	icsDateTime    = "20060102T150405"
+	icsDate        = "20060102"
+)
+
+// extractCalendarEvent returns the event of the first text/calendar part or .ics attachment
+func extractCalendarEvent(emailParser *enmime.Envelope) *models.CalendarEvent {
+	if emailParser == nil || emailParser.Root == nil {
+		return nil
+	}
+
+	parts := emailParser.Root.DepthMatchAll(func(part *enmime.Part) bool {
+		return strings.EqualFold(part.ContentType, "text/calendar") ||
+			strings.HasSuffix(strings.ToLower(part.FileName), ".ics")
+	})
+	for _, part := range parts {
+		if event := parseCalendarEvent(part.Content); event != nil {
+			if event.Method == "" && part.ContentTypeParams != nil {
+				event.Method = strings.ToUpper(part.ContentTypeParams["method"])
+			}
+			return event
+		}
+	}
+	return nil
+}
+
+// parseCalendarEvent reads the basic metadata of the first VEVENT, nil when there is none
+func parseCalendarEvent(data []byte) *models.CalendarEvent {
+	// unfold continuation lines, they start with a space or tab
+	content := strings.ReplaceAll(string(data), "\r\n", "\n")
+	content = strings.ReplaceAll(content, "\n ", "")
+	content = strings.ReplaceAll(content, "\n\t", "")
+
+	var event *models.CalendarEvent
+	var method string
+	inEvent, done := false, false
+
+	for _, line := range strings.Split(content, "\n") {
+		name, params, value, ok := parseCalendarLine(line)
+		if !ok || done {
+			continue
+		}
+
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VEVENT"):
+			inEvent = true
+			event = &models.CalendarEvent{}
+		case name == "END" && strings.EqualFold(value, "VEVENT"):
+			inEvent, done = false, true
+		case name == "METHOD" && !inEvent:
+			method = strings.ToUpper(value)
+		case !inEvent:
+		case name == "UID":
+			event.UID = value
+		case name == "STATUS":
+			event.Status = strings.ToUpper(value)
+		case name == "SUMMARY":
+			event.Summary = unescapeCalendarText(value)
+		case name == "LOCATION":
+			event.Location = unescapeCalendarText(value)
+		case name == "DTSTART":
+			event.Start, event.AllDay = parseCalendarTime(value, params)
+		case name == "DTEND":
+			event.End, _ = parseCalendarTime(value, params)
+		case name == "ORGANIZER":
+			event.OrganizerName = strings.Trim(params["CN"], `"`)
+			event.OrganizerEmail = calendarAddress(value)
+		case name == "ATTENDEE":
+			if address := calendarAddress(value); address != "" {
+				event.Attendees = append(event.Attendees, address)
+			}
+		}
+	}
+
+	if event != nil {
+		event.Method = method
+	}
+	return event
+}
+
+// parseCalendarLine splits a content line into its upper case name, parameters and value.
+// Parameter values may be quoted and contain colons and semicolons.
+func parseCalendarLine(line string) (string, map[string]string, string, bool) {
+	line = strings.TrimRight(line, "\r")
+
+	inQuotes := false
+	separator := -1
+	for i, c := range line {
+		if c == '"' {
+			inQuotes = !inQuotes
+		} else if c == ':' && !inQuotes {
+			separator = i
+			break
+		}
+	}
+	if separator <= 0 {
+		return "", nil, "", false
+	}
+
+	nameAndParams := splitOutsideQuotes(line[:separator], ';')
+	params := make(map[string]string, len(nameAndParams)-1)
+	for _, param := range nameAndParams[1:] {
+		if key, value, found := strings.Cut(param, "="); found {
+			params[strings.ToUpper(key)] = value
+		}
+	}
+	return strings.ToUpper(nameAndParams[0]), params, line[separator+1:], true
+}
+
+func splitOutsideQuotes(s string, separator rune) []string {
+	var parts []string
+	inQuotes := false
+	start := 0
+	for i, c := range s {
+		if c == '"' {
+			inQuotes = !inQuotes
+		} else if c == separator && !inQuotes {
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// parseCalendarTime reads UTC, TZID-local and floating date-times and all-day dates
+func parseCalendarTime(value string, params map[string]string) (*time.Time, bool) {
+	value = strings.TrimSpace(value)
+
+	if params["VALUE"] == "DATE" || len(value) == len(icsDate) {
+		t, err := time.Parse(icsDate, value)
+		if err != nil {
+			return nil, false
+		}
+		return &t, true
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(icsDateTimeUTC, value)
+		if err != nil {
+			return nil, false
+		}
+		return &t, false
+	}
+
+	location := time.UTC
+	if tzid := strings.Trim(params["TZID"], `"`); tzid != "" {
+		if loaded, err := time.LoadLocation(tzid); err == nil {
+			location = loaded
+		}
+	}
+	t, err := time.ParseInLocation(icsDateTime, value, location)
+	if err != nil {
+		return nil, false
+	}
+	t = t.UTC()
+	return &t, false
+}
+
+func calendarAddress(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) > len("mailto:") && strings.EqualFold(value[:len("mailto:")], "mailto:") {
+		value = value[len("mailto:"):]
+	}
+	return strings.ToLower(value)
+}
+
+var calendarTextReplacer = strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+
+func unescapeCalendarText(value string) string {
+	return calendarTextReplacer.Replace(value)
+}
+
+func calendarInviteReason(event *models.CalendarEvent) string {
+	method := event.Method
+	if method == "" {
+		method = "PUBLISH"
+	}
+	return fmt.Sprintf("Calendar %s for '%s'", strings.ToLower(method), event.Summary)
+}
@@ -0,0 +1,94 @@
+package email_processor
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/internal/models"
+)
+
+func TestParseWithEnmime_CalendarInvite(t *testing.T) {
+	raw := strings.Join([]string{
+		"From: Jane Doe <jane@acme.com>",
+		"To: john@acme.com",
+		"Subject: Invitation: Quarterly review",
+		"Message-ID: <invite@acme.com>",
+		"MIME-Version: 1.0",
+		`Content-Type: multipart/alternative; boundary="b1"`,
+		"",
+		"--b1",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		"You have been invited",
+		"--b1",
+		`Content-Type: text/calendar; charset=UTF-8; method=REQUEST`,
+		"",
+		"BEGIN:VCALENDAR",
+		"METHOD:REQUEST",
+		"BEGIN:VTIMEZONE",
+		"TZID:Europe/Berlin",
+		"BEGIN:STANDARD",
+		"DTSTART:19701025T030000",
+		"END:STANDARD",
+		"END:VTIMEZONE",
+		"BEGIN:VEVENT",
+		"UID:evt-123@acme.com",
+		"SUMMARY:Quarterly review\\, Q3",
+		"DTSTART;TZID=Europe/Berlin:20250310T140000",
+		"DTEND;TZID=Europe/Berlin:20250310T150000",
+		`ORGANIZER;CN="Doe, Jane":mailto:Jane@acme.com`,
+		"ATTENDEE;ROLE=REQ-PARTICIPANT;CN=John:mailto:john@acme.com",
+		"ATTENDEE;CN=Room:MAILTO:room-1@acme.com",
+		"LOCATION:Room 1",
+		"STATUS:CONFIRMED",
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"--b1--",
+		"",
+	}, "\r\n")
+
+	email := &models.Email{}
+	parseWithEnmime(email, []byte(raw))
+
+	event := email.CalendarEvent
+	require.NotNil(t, event)
+	assert.Equal(t, "REQUEST", event.Method)
+	assert.Equal(t, "evt-123@acme.com", event.UID)
+	assert.Equal(t, "Quarterly review, Q3", event.Summary)
+	assert.Equal(t, "Room 1", event.Location)
+	assert.Equal(t, "CONFIRMED", event.Status)
+	require.NotNil(t, event.Start)
+	assert.Equal(t, time.Date(2025, 3, 10, 13, 0, 0, 0, time.UTC), *event.Start)
+	assert.Equal(t, time.Date(2025, 3, 10, 14, 0, 0, 0, time.UTC), *event.End)
+	assert.False(t, event.AllDay)
+	assert.Equal(t, "Doe, Jane", event.OrganizerName)
+	assert.Equal(t, "jane@acme.com", event.OrganizerEmail)
+	assert.Equal(t, []string{"john@acme.com", "room-1@acme.com"}, event.Attendees)
+	assert.Equal(t, "Calendar request for 'Quarterly review, Q3'", calendarInviteReason(event))
+}
+
+func TestParseCalendarEvent(t *testing.T) {
+	t.Run("all day event with folded summary", func(t *testing.T) {
+		event := parseCalendarEvent([]byte("BEGIN:VCALENDAR\nBEGIN:VEVENT\nSUMMARY:Company\n  offsite\nDTSTART;VALUE=DATE:20250401\nDTEND;VALUE=DATE:20250402\nEND:VEVENT\nEND:VCALENDAR\n"))
+		require.NotNil(t, event)
+		assert.Equal(t, "Company offsite", event.Summary)
+		assert.True(t, event.AllDay)
+		assert.Equal(t, time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), *event.Start)
+	})
+
+	t.Run("utc times", func(t *testing.T) {
+		event := parseCalendarEvent([]byte("BEGIN:VEVENT\r\nDTSTART:20250310T090000Z\r\nEND:VEVENT\r\n"))
+		require.NotNil(t, event)
+		assert.Equal(t, time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC), *event.Start)
+		assert.Nil(t, event.End)
+	})
+
+	t.Run("no event", func(t *testing.T) {
+		assert.Nil(t, parseCalendarEvent([]byte("BEGIN:VCALENDAR\nBEGIN:VTODO\nEND:VTODO\nEND:VCALENDAR\n")))
+		assert.Nil(t, parseCalendarEvent(nil))
+	})
+}
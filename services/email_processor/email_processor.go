@@ -12,6 +12,7 @@ import (
 
 	"github.com/customeros/mailstack/dto"
 	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/config"
 	"github.com/customeros/mailstack/internal/enum"
 	"github.com/customeros/mailstack/internal/models"
 	"github.com/customeros/mailstack/internal/repository"
@@ -24,17 +25,41 @@ type emailProcessor struct {
 	repositories  *repository.Repositories
 	eventsService *events.EventsService
 	aiService     interfaces.AIService
+	scanner       interfaces.AttachmentScanner
+	spamScorer    interfaces.SpamScorer
+	threading     *config.ThreadingConfig
+	htmlSanitizer *HTMLSanitizer
+	previews      *config.AttachmentPreviewConfig
+	spoofing      *spoofingRules
+
+	sensitiveRules *sensitiveRulesCache
+	previewQueue   *previewQueue
 }
 
 func NewEmailProcessor(
 	repositories *repository.Repositories,
 	eventsService *events.EventsService,
 	aiService interfaces.AIService,
+	scanner interfaces.AttachmentScanner,
+	spamScorer interfaces.SpamScorer,
+	threadingConfig *config.ThreadingConfig,
+	sanitizerConfig *config.HTMLSanitizerConfig,
+	previewConfig *config.AttachmentPreviewConfig,
+	spoofingConfig *config.SpoofingConfig,
 ) interfaces.EmailProcessor {
 	return &emailProcessor{
 		repositories:  repositories,
 		eventsService: eventsService,
 		aiService:     aiService,
+		scanner:       scanner,
+		spamScorer:    spamScorer,
+		threading:     threadingConfig,
+		htmlSanitizer: NewHTMLSanitizer(sanitizerConfig),
+		previews:      previewConfig,
+		spoofing:      newSpoofingRules(spoofingConfig),
+
+		sensitiveRules: newSensitiveRulesCache(),
+		previewQueue:   newPreviewQueue(),
 	}
 }
 
@@ -49,9 +74,7 @@ func (p *emailProcessor) NewInboundEmail() *models.Email {
 
 func (p *emailProcessor) NewAttachment() *models.EmailAttachment {
 	return &models.EmailAttachment{
-		ID:             utils.GenerateNanoIDWithPrefix("file", 12),
-		StorageService: "R2",
-		StorageBucket:  "attachments",
+		ID: utils.GenerateNanoIDWithPrefix("file", 12),
 	}
 }
 
@@ -69,6 +92,9 @@ func (p *emailProcessor) ProcessEmail(
 	defer span.Finish()
 	tracing.SetDefaultServiceSpanTags(ctx, span)
 
+	// strip scripts and dangerous markup before the body is stored or rendered
+	email.BodyHTML = p.htmlSanitizer.Sanitize(email.BodyHTML)
+
 	// attach message to thread
 	err := p.attachEmailToThread(ctx, email)
 	if err != nil {
@@ -76,8 +102,17 @@ func (p *emailProcessor) ProcessEmail(
 		return err
 	}
 
-	// Clean message body
-	err = p.getStructuredMessageBody(ctx, email)
+	// Separate the latest reply from quoted history, independent of the AI service
+	email.VisibleText, email.QuotedText = splitQuotedText(email.BodyText)
+
+	// Detect the language of the new content, quoted history may be in another language
+	email.Language = detectLanguage(email.VisibleText)
+	if email.Language == "" {
+		email.Language = detectLanguage(email.BodyText)
+	}
+
+	// Upload attachments and point inline images at the stored copies
+	err = p.storeAttachments(ctx, email, attachments, files)
 	if err != nil {
 		tracing.TraceErr(span, err)
 		return err
@@ -94,48 +129,19 @@ func (p *emailProcessor) ProcessEmail(
 	// Throw events
 	err = p.eventsService.Publisher.PublishFanoutEvent(ctx, emailID, enum.EMAIL, dto.EmailParticipants{Emails: email.AllParticipants()})
 
-	return nil
-}
-
-func (p *emailProcessor) getStructuredMessageBody(ctx context.Context, email *models.Email) error {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "emailProcessor.getStructuredMessageBody")
-	defer span.Finish()
-	tracing.SetDefaultServiceSpanTags(ctx, span)
-
-	structuredData, err := p.aiService.GetStructuredEmailBody(ctx, dto.StructuredEmailRequest{
-		FromName:         email.FromName,
-		FromEmailAddress: email.FromAddress,
-		ToEmailAddress:   email.ToAddresses[0],
-		EmailBodyText:    email.BodyText,
-		EmailBodyHTML:    email.BodyHTML,
-	})
-	if err != nil {
-		tracing.TraceErr(span, err)
-		return err
-	}
-
-	if structuredData == nil {
-		return nil
-	}
-
-	email.HasSignature = structuredData.EmailData.HasSignature
-	email.BodyMarkdown = structuredData.EmailData.MessageBody
-
-	if !structuredData.EmailData.HasSignature {
-		return nil
-	}
-
-	structuredData.EmailData.Signature.CompanyInfo.Domain = email.FromDomain
-	err = p.eventsService.Publisher.PublishFanoutEvent(ctx, email.ID, enum.EMAIL_SIGNATURE, structuredData.EmailData.Signature)
-	if err != nil {
-		tracing.TraceErr(span, err)
-		return err
+	// Clean the message body asynchronously, the deterministic split above stays until then.
+	// Invites are rendered from their event, their generated body is not worth the AI call.
+	// Sensitive content is not sent to the AI service.
+	if email.Classification != enum.EmailCalendarInvite && email.Classification != enum.EmailSensitive {
+		if err = p.eventsService.Publisher.PublishEnrichEmailEvent(ctx, emailID); err != nil {
+			tracing.TraceErr(span, err)
+		}
 	}
 
 	return nil
 }
 
-func (p *emailProcessor) EmailFilter(ctx context.Context, email *models.Email) error {
+func (p *emailProcessor) EmailFilter(ctx context.Context, email *models.Email, rawMessage []byte) error {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "emailFilterService.ScanEmail")
 	tracing.SetDefaultServiceSpanTags(ctx, span)
 	defer span.Finish()
@@ -153,16 +159,35 @@ func (p *emailProcessor) EmailFilter(ctx context.Context, email *models.Email) e
 
 	isBounceNotification, reason := isBounceNotification(headers, email.Subject, email.FromAddress)
 	if isBounceNotification {
-		// todo determine what email bounced
-		// todo send bounced email event
+		// the bounced email is resolved from the DSN in ProcessBounce
 		email.Classification = enum.EmailBounceNotification
 		email.ClassificationReason = reason
 		return nil
 	}
 
+	// checked before invites, bulk and internal, spoofed mail imitates them all
+	isSuspicious, reason := p.spoofing.match(email)
+	if isSuspicious {
+		email.Classification = enum.EmailSuspicious
+		email.ClassificationReason = reason
+		return nil
+	}
+
+	// invites are often sent by calendar systems that would look like bulk email, but attaching
+	// an invite must not get spam past the scorer
+	if email.CalendarEvent != nil {
+		if isSpam, reason := p.isSpam(ctx, rawMessage); isSpam {
+			email.Classification = enum.EmailSpam
+			email.ClassificationReason = reason
+			return nil
+		}
+		email.Classification = enum.EmailCalendarInvite
+		email.ClassificationReason = calendarInviteReason(email.CalendarEvent)
+		return nil
+	}
+
 	isAutoresponder, reason := isAutoresponder(headers)
 	if isAutoresponder {
-		// todo analyze autoresponder content and do something
 		email.Classification = enum.EmailAutoResponder
 		email.ClassificationReason = reason
 		return nil
@@ -181,99 +206,48 @@ func (p *emailProcessor) EmailFilter(ctx context.Context, email *models.Email) e
 		return nil
 	}
 
-	isSensitive, reason := isSensitiveSubject(email.Subject)
+	rules := p.tenantSensitiveRules(ctx, email)
+	isSensitive, reason := rules.matchSubject(email.Subject)
+	if !isSensitive {
+		isSensitive, reason = rules.matchBody(email.BodyText)
+	}
 	if isSensitive {
 		email.Classification = enum.EmailSensitive
 		email.ClassificationReason = reason
 		return nil
 	}
 
-	// todo add spam check + email warmer check (if required)
+	// todo email warmer check (if required)
+
+	isSpam, reason := p.isSpam(ctx, rawMessage)
+	if isSpam {
+		email.Classification = enum.EmailSpam
+		email.ClassificationReason = reason
+		return nil
+	}
 
 	email.Classification = enum.EmailOK
 	return nil
 }
 
-func isSensitiveSubject(subject string) (bool, string) {
-	// Convert subject to lowercase for case-insensitive matching
-	lowerSubject := strings.ToLower(subject)
-
-	// Define keyword groups with associated reasons
-	sensitiveKeywords := map[string][]string{
-		"confidentiality": {
-			"confidential", "private", "sensitive", "do not share", "do not forward",
-			"nda", "under nda", "confidentiality agreement", "privileged",
-			"secret", "restricted", "internal only", "internal use", "not for distribution",
-		},
-		"financial": {
-			"financial report", "quarterly results", "annual results", "revenue",
-			"profit margin", "earnings", "balance sheet", "tax", "invoice", "salary",
-			"compensation", "bonus", "stock options", "equity",
-		},
-		"legal": {
-			"legal", "lawsuit", "litigation", "settlement", "contract review",
-			"agreement", "terms", "legal review", "compliance", "regulatory",
-			"attorney", "counsel", "court", "subpoena", "trademark",
-		},
-		"personal": {
-			"personal", "medical", "health", "patient", "ssn", "social security",
-			"date of birth", "dob", "passport", "driver license", "id number",
-			"background check", "performance review",
-		},
-		"security": {
-			"password", "login", "credentials", "access code", "security", "breach",
-			"vulnerability", "hack", "incident", "authentication",
-		},
-		"merger": {
-			"merger", "acquisition", "m&a", "due diligence", "deal", "takeover",
-			"buyout", "transaction", "valuation", "term sheet", "loi", "letter of intent",
-		},
-		"hr": {
-			"termination", "firing", "layoff", "severance", "redundancy",
-			"disciplinary", "complaint", "grievance", "harassment", "discrimination",
-			"interview", "candidate", "recruitment", "hiring",
-		},
-	}
-
-	// Check each keyword group
-	for category, keywords := range sensitiveKeywords {
-		for _, keyword := range keywords {
-			if strings.Contains(lowerSubject, keyword) {
-				reason := fmt.Sprintf("Subject contains %s-related sensitive keyword: '%s'", category, keyword)
-				return true, reason
-			}
-		}
-	}
-
-	// Check for explicit confidentiality markings
-	confidentialityMarkers := []string{
-		"[confidential]", "(confidential)", "***confidential***", "###confidential###",
-		"[sensitive]", "(sensitive)", "***sensitive***", "###sensitive###",
-		"[private]", "(private)", "***private***", "###private###",
-	}
+// isSpam scores the raw message, scoring failures let the email through
+func (p *emailProcessor) isSpam(ctx context.Context, rawMessage []byte) (bool, string) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailProcessor.isSpam")
+	defer span.Finish()
 
-	for _, marker := range confidentialityMarkers {
-		if strings.Contains(lowerSubject, marker) {
-			reason := fmt.Sprintf("Subject contains explicit confidentiality marker: '%s'", marker)
-			return true, reason
-		}
+	if p.spamScorer == nil || len(rawMessage) == 0 {
+		return false, ""
 	}
 
-	// Check for classification levels
-	classificationLevels := []string{
-		"top secret", "secret", "confidential", "restricted", "classified",
-		"sensitive but unclassified", "sbu", "for official use only", "fouo",
-		"controlled unclassified", "cui",
+	score, err := p.spamScorer.Score(ctx, rawMessage)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return false, ""
 	}
-
-	for _, level := range classificationLevels {
-		if strings.Contains(lowerSubject, level) {
-			reason := fmt.Sprintf("Subject contains formal classification level: '%s'", level)
-			return true, reason
-		}
+	if score.Skipped || !score.Spam {
+		return false, ""
 	}
-
-	return false, ""
+	return true, fmt.Sprintf("spam score %.2f exceeds threshold %.2f", score.Score, score.Threshold)
 }
 
 func isInternalEmail(email *models.Email) bool {
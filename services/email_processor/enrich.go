@@ -0,0 +1,167 @@
+package email_processor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+	tracingLog "github.com/opentracing/opentracing-go/log"
+
+	"github.com/customeros/mailstack/dto"
+	"github.com/customeros/mailstack/internal/enum"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+// EnrichEmail stores the AI structured body of a stored email and publishes its signature.
+// Results are cached by the hash of the tenant, sender, recipient and normalized body, so duplicate
+// deliveries reach the AI service once while the signature is never taken from another email. Already enriched emails are skipped. An error leaves
+// the email as it was stored, the event is retried.
+func (p *emailProcessor) EnrichEmail(ctx context.Context, emailID string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailProcessor.EnrichEmail")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.SetTag("email_id", emailID)
+
+	email, err := p.repositories.EmailRepository.GetByID(ctx, emailID)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+	if email == nil || email.BodyMarkdown != "" || email.Classification == enum.EmailCalendarInvite {
+		span.LogFields(tracingLog.Bool("skipped", true))
+		return nil
+	}
+
+	tenant := utils.GetTenantFromContext(ctx)
+	if tenant == "" && email.MailboxID != "" {
+		mailbox, err := p.repositories.MailboxRepository.GetMailbox(ctx, email.MailboxID)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return err
+		}
+		tenant = mailbox.Tenant
+	}
+
+	request := structuredEmailRequest(email)
+	bodyHash := structuredBodyHash(tenant, request)
+	span.SetTag("body_hash", bodyHash)
+
+	structured, cached, err := p.cachedStructuredBody(ctx, bodyHash)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+	span.LogFields(tracingLog.Bool("cached", cached))
+
+	if !cached {
+		response, err := p.aiService.GetStructuredEmailBody(ctx, request)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return err
+		}
+		if response != nil {
+			structured = response.EmailData
+		}
+		if err = p.cacheStructuredBody(ctx, bodyHash, structured); err != nil {
+			// the result is still stored on the email
+			tracing.TraceErr(span, err)
+		}
+	}
+
+	err = p.repositories.EmailRepository.SetStructuredBody(ctx, email.ID, structured.HasSignature, structured.MessageBody)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	if !structured.HasSignature {
+		return nil
+	}
+
+	structured.Signature.CompanyInfo.Domain = email.FromDomain
+	err = p.eventsService.Publisher.PublishFanoutEvent(ctx, email.ID, enum.EMAIL_SIGNATURE, structured.Signature)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	return nil
+}
+
+// structuredEmailRequest builds the AI request, emails without a To recipient, like BCC only
+// deliveries, send an empty recipient
+func structuredEmailRequest(email *models.Email) dto.StructuredEmailRequest {
+	request := dto.StructuredEmailRequest{
+		FromName:         email.FromName,
+		FromEmailAddress: email.FromAddress,
+		EmailBodyText:    email.BodyText,
+		EmailBodyHTML:    email.BodyHTML,
+	}
+	if len(email.ToAddresses) > 0 {
+		request.ToEmailAddress = email.ToAddresses[0]
+	}
+	return request
+}
+
+func (p *emailProcessor) cachedStructuredBody(ctx context.Context, bodyHash string) (dto.EmailData, bool, error) {
+	var data dto.EmailData
+
+	entry, err := p.repositories.StructuredBodyCacheRepository.Get(ctx, bodyHash)
+	if err != nil || entry == nil {
+		return data, false, err
+	}
+
+	raw, err := json.Marshal(entry.EmailData)
+	if err != nil {
+		return data, false, err
+	}
+	if err = json.Unmarshal(raw, &data); err != nil {
+		return data, false, err
+	}
+	return data, true, nil
+}
+
+func (p *emailProcessor) cacheStructuredBody(ctx context.Context, bodyHash string, data dto.EmailData) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	var emailData models.JSONMap
+	if err = json.Unmarshal(raw, &emailData); err != nil {
+		return err
+	}
+
+	return p.repositories.StructuredBodyCacheRepository.Save(ctx, &models.StructuredBodyCache{
+		BodyHash:  bodyHash,
+		EmailData: emailData,
+	})
+}
+
+// structuredBodyHash hashes the tenant, the sender and recipient and the bodies with line endings
+// and whitespace runs collapsed, which differ between deliveries of the same message without
+// changing its content. The structured body depends on who wrote the email, the same body from
+// another sender has another signature.
+func structuredBodyHash(tenant string, request dto.StructuredEmailRequest) string {
+	normalize := func(body string) string {
+		return strings.Join(strings.Fields(body), " ")
+	}
+
+	hash := sha256.New()
+	for _, part := range []string{
+		tenant,
+		request.FromName,
+		strings.ToLower(request.FromEmailAddress),
+		strings.ToLower(request.ToEmailAddress),
+		normalize(request.EmailBodyText),
+		normalize(request.EmailBodyHTML),
+	} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
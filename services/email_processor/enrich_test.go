@@ -0,0 +1,38 @@
+package email_processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/customeros/mailstack/dto"
+)
+
+func TestStructuredBodyHash(t *testing.T) {
+	request := func(from, to, text, html string) dto.StructuredEmailRequest {
+		return dto.StructuredEmailRequest{FromName: "Jane", FromEmailAddress: from, ToEmailAddress: to, EmailBodyText: text, EmailBodyHTML: html}
+	}
+	hash := structuredBodyHash("acme", request("jane@acme.io", "bob@corp.io", "Hi Bob,\r\n\r\nThanks!\r\n", "<p>Hi Bob,</p>"))
+
+	assert.Equal(t, hash, structuredBodyHash("acme", request("Jane@acme.io", "bob@corp.io", "Hi Bob,\n\nThanks!", "<p>Hi Bob,</p>\n")))
+	assert.NotEqual(t, hash, structuredBodyHash("acme", request("jane@acme.io", "bob@corp.io", "Hi Bob, Thanks!", "<p>Hi Alice,</p>")))
+	// text and html are hashed apart, moving content between them is a different body
+	assert.NotEqual(t, structuredBodyHash("acme", request("", "", "a", "b")), structuredBodyHash("acme", request("", "", "a b", "")))
+
+	// the same body from another sender, to another recipient or of another tenant has its own entry
+	assert.NotEqual(t, hash, structuredBodyHash("acme", request("eve@evil.io", "bob@corp.io", "Hi Bob,\n\nThanks!", "<p>Hi Bob,</p>")))
+	assert.NotEqual(t, hash, structuredBodyHash("acme", request("jane@acme.io", "alice@corp.io", "Hi Bob,\n\nThanks!", "<p>Hi Bob,</p>")))
+	assert.NotEqual(t, hash, structuredBodyHash("globex", request("jane@acme.io", "bob@corp.io", "Hi Bob,\n\nThanks!", "<p>Hi Bob,</p>")))
+}
+
+func TestStructuredEmailRequestWithoutRecipients(t *testing.T) {
+	email := (&emailProcessor{}).NewInboundEmail()
+	email.BccAddresses = []string{"hidden@acme.io"}
+	email.BodyText = "Sent to undisclosed recipients"
+
+	var request dto.StructuredEmailRequest
+	assert.NotPanics(t, func() { request = structuredEmailRequest(email) })
+	assert.Empty(t, request.ToEmailAddress)
+	assert.Empty(t, request.FromEmailAddress)
+	assert.Equal(t, "Sent to undisclosed recipients", request.EmailBodyText)
+}
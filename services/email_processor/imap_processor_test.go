@@ -0,0 +1,120 @@
+package email_processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/internal/config"
+)
+
+func TestProcessAttachment(t *testing.T) {
+	p := &ImapProcessor{EmailProcessor: &emailProcessor{}}
+
+	t.Run("parsed inline attachment", func(t *testing.T) {
+		attachment, files, err := p.processAttachment(map[string]interface{}{
+			"filename":     "logo.png",
+			"content_type": "image/png",
+			"disposition":  "inline",
+			"content_id":   "logo@acme.com",
+			"size":         3,
+			"content":      []byte("png"),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "logo.png", attachment.Filename)
+		assert.True(t, attachment.IsInline)
+		assert.Equal(t, "logo@acme.com", attachment.ContentID)
+		assert.Equal(t, 3, attachment.Size)
+		assert.Len(t, files, 1)
+	})
+
+	t.Run("body structure size", func(t *testing.T) {
+		attachment, files, err := p.processAttachment(map[string]interface{}{
+			"filename":     "report.pdf",
+			"content_type": "application/pdf",
+			"disposition":  "attachment",
+			"size":         uint32(2048),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 2048, attachment.Size)
+		assert.Empty(t, files)
+	})
+
+	t.Run("partial part gets defaults", func(t *testing.T) {
+		attachment, files, err := p.processAttachment(map[string]interface{}{
+			"content": []byte("hello"),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "attachment", attachment.Filename)
+		assert.Equal(t, "application/octet-stream", attachment.ContentType)
+		assert.Equal(t, 5, attachment.Size)
+		assert.False(t, attachment.IsInline)
+		assert.Len(t, files, 1)
+	})
+
+	t.Run("odd size types", func(t *testing.T) {
+		for _, size := range []interface{}{int64(10), float64(10), uint64(10), int32(10)} {
+			attachment, _, err := p.processAttachment(map[string]interface{}{"filename": "a.txt", "size": size})
+			require.NoError(t, err)
+			assert.Equal(t, 10, attachment.Size, "%T", size)
+		}
+	})
+
+	t.Run("inline part without content id", func(t *testing.T) {
+		attachment, _, err := p.processAttachment(map[string]interface{}{"filename": "a.png", "disposition": "inline"})
+		require.NoError(t, err)
+		assert.True(t, attachment.IsInline)
+		assert.Empty(t, attachment.ContentID)
+	})
+
+	t.Run("missing data", func(t *testing.T) {
+		_, _, err := p.processAttachment(nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("wrongly typed fields are defaulted", func(t *testing.T) {
+		attachment, files, err := p.processAttachment(map[string]interface{}{
+			"filename":     42,
+			"content_type": []string{"text/plain"},
+			"size":         "12",
+			"disposition":  "inline",
+			"content_id":   []byte("x"),
+			"content":      "abc",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "attachment", attachment.Filename)
+		assert.Equal(t, "application/octet-stream", attachment.ContentType)
+		assert.Equal(t, 3, attachment.Size)
+		assert.True(t, attachment.IsInline)
+		assert.Empty(t, attachment.ContentID)
+		assert.Len(t, files, 1)
+
+		attachment, files, err = p.processAttachment(map[string]interface{}{"filename": "a.txt", "content": 42})
+		require.NoError(t, err)
+		assert.Equal(t, "a.txt", attachment.Filename)
+		assert.Empty(t, files)
+	})
+}
+
+func TestProcessAttachmentSizeCap(t *testing.T) {
+	p := &ImapProcessor{EmailProcessor: &emailProcessor{}, config: &config.InboundConfig{MaxAttachmentSizeBytes: 8}}
+
+	for _, tt := range []struct {
+		content []byte
+		stored  bool
+	}{
+		{[]byte("1234567"), true},
+		{[]byte("12345678"), true},
+		{[]byte("123456789"), false},
+	} {
+		attachment, files, err := p.processAttachment(map[string]interface{}{"filename": "a.bin", "content": tt.content})
+		require.NoError(t, err)
+		// oversized content keeps its metadata
+		assert.Equal(t, len(tt.content), attachment.Size)
+		assert.Equal(t, tt.stored, len(files) == 1, "%d bytes", len(tt.content))
+	}
+
+	assert.False(t, (&ImapProcessor{}).exceedsAttachmentCap(1<<30))
+	assert.False(t, (&ImapProcessor{config: &config.InboundConfig{}}).exceedsAttachmentCap(1<<30))
+}
@@ -0,0 +1,26 @@
+package email_processor
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/abadojack/whatlanggo"
+)
+
+// texts shorter than this, like "Thanks!", do not carry enough trigrams for a reliable guess
+const minLanguageDetectionLength = 30
+
+// detectLanguage returns the ISO 639-1 code of the text language, empty when the text is
+// too short or the detection is not reliable
+func detectLanguage(text string) string {
+	text = strings.TrimSpace(text)
+	if utf8.RuneCountInString(text) < minLanguageDetectionLength {
+		return ""
+	}
+
+	info := whatlanggo.Detect(text)
+	if !info.IsReliable() {
+		return ""
+	}
+	return info.Lang.Iso6391()
+}
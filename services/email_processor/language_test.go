@@ -0,0 +1,52 @@
+package email_processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{
+			name:     "english",
+			text:     "Thanks for the update, I will review the proposal and get back to you by the end of the week.",
+			expected: "en",
+		},
+		{
+			name:     "german",
+			text:     "Vielen Dank für Ihre Nachricht, ich werde mir das Angebot ansehen und mich bis Ende der Woche melden.",
+			expected: "de",
+		},
+		{
+			name:     "french",
+			text:     "Merci pour votre message, je vais regarder la proposition et je vous répondrai avant la fin de la semaine.",
+			expected: "fr",
+		},
+		{
+			name:     "spanish",
+			text:     "Gracias por tu mensaje, voy a revisar la propuesta y te respondo antes del final de la semana.",
+			expected: "es",
+		},
+		{
+			name:     "too short",
+			text:     "Thanks!",
+			expected: "",
+		},
+		{
+			name:     "empty",
+			text:     "   ",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, detectLanguage(tt.text))
+		})
+	}
+}
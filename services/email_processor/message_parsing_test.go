@@ -0,0 +1,130 @@
+package email_processor
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	go_imap "github.com/emersion/go-imap"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/internal/models"
+)
+
+func TestProcessEnvelope(t *testing.T) {
+	sentAt := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	envelope := &go_imap.Envelope{
+		Date:      sentAt,
+		Subject:   "Re: Quarterly review",
+		MessageId: "<reply@acme.com>",
+		InReplyTo: "<first@acme.com> <second@acme.com> <first@acme.com>",
+		From:      []*go_imap.Address{{PersonalName: "Jane Doe", MailboxName: "Jane", HostName: "Acme.com"}},
+		To:        []*go_imap.Address{{MailboxName: "john", HostName: "acme.com"}, {MailboxName: "undisclosed-recipients"}},
+		Cc:        []*go_imap.Address{{MailboxName: "ops", HostName: "acme.com"}},
+	}
+
+	email := &models.Email{}
+	processEnvelope(email, envelope)
+
+	require.NotNil(t, email.SentAt)
+	assert.Equal(t, sentAt, *email.SentAt)
+	assert.Equal(t, "Re: Quarterly review", email.Subject)
+	assert.Equal(t, "reply@acme.com", email.MessageID)
+	assert.Equal(t, "first@acme.com", email.InReplyTo)
+	assert.Equal(t, pq.StringArray{"first@acme.com", "second@acme.com"}, email.References)
+	assert.Equal(t, "Jane Doe", email.FromName)
+	assert.Equal(t, "jane@acme.com", email.FromAddress)
+	assert.Equal(t, "acme.com", email.FromDomain)
+	assert.Equal(t, pq.StringArray{"john@acme.com"}, email.ToAddresses)
+	assert.Equal(t, pq.StringArray{"ops@acme.com"}, email.CcAddresses)
+	assert.Equal(t, pq.StringArray{}, email.BccAddresses)
+	assert.Equal(t, "<reply@acme.com>", email.Envelope["message_id"])
+}
+
+func TestProcessEnvelope_Nil(t *testing.T) {
+	email := &models.Email{Subject: "unchanged"}
+	processEnvelope(email, nil)
+	assert.Equal(t, "unchanged", email.Subject)
+}
+
+func TestParseWithEnmime(t *testing.T) {
+	raw := strings.Join([]string{
+		"From: Jane Doe <jane@acme.com>",
+		"To: john@acme.com",
+		"Subject: Report",
+		"Message-ID: <reply@acme.com>",
+		"In-Reply-To: <second@acme.com>",
+		"References: <first@acme.com>\r\n <second@acme.com>",
+		"MIME-Version: 1.0",
+		`Content-Type: multipart/mixed; boundary="outer"`,
+		"",
+		"--outer",
+		`Content-Type: text/plain; charset="utf-8"`,
+		"",
+		"See attached.",
+		"--outer",
+		`Content-Type: text/csv; name="report.csv"`,
+		`Content-Disposition: attachment; filename="report.csv"`,
+		"",
+		"a,b",
+		"--outer--",
+		"",
+	}, "\r\n")
+
+	// references from the envelope come after the References header, without duplicates
+	email := &models.Email{References: pq.StringArray{"second@acme.com", "other@acme.com"}}
+	attachments := parseWithEnmime(email, []byte(raw))
+
+	assert.Equal(t, "See attached.", strings.TrimSpace(email.BodyText))
+	assert.Equal(t, pq.StringArray{"first@acme.com", "second@acme.com", "other@acme.com"}, email.References)
+	assert.Contains(t, email.RawHeaders, "Message-Id")
+	assert.True(t, email.HasAttachment)
+	require.Len(t, attachments, 1)
+	assert.Equal(t, "report.csv", attachments[0]["filename"])
+	assert.Equal(t, "text/csv", attachments[0]["content_type"])
+	assert.Equal(t, []byte("a,b"), attachments[0]["content"])
+	assert.Equal(t, false, email.BodyStructure["has_html"])
+}
+
+func TestExtractContentManually_DecodesParts(t *testing.T) {
+	msg := &go_imap.Message{
+		BodyStructure: &go_imap.BodyStructure{
+			MIMEType:    "multipart",
+			MIMESubType: "alternative",
+			Parts: []*go_imap.BodyStructure{
+				{MIMEType: "text", MIMESubType: "plain", Encoding: "quoted-printable", Params: map[string]string{"charset": "ISO-8859-1"}},
+				{MIMEType: "text", MIMESubType: "html", Encoding: "base64", Params: map[string]string{"charset": "utf-8"}},
+			},
+		},
+		Body: map[*go_imap.BodySectionName]go_imap.Literal{
+			{BodyPartName: go_imap.BodyPartName{Path: []int{1}}}: bytes.NewBufferString("Caf=E9 cr=E8me, =E0 bient=F4t=\r\n!"),
+			{BodyPartName: go_imap.BodyPartName{Path: []int{2}}}: bytes.NewBufferString("PHA+R3LDvMOfZTwvcD4="),
+		},
+	}
+
+	email := &models.Email{}
+	extractContentManually(email, msg)
+
+	assert.Equal(t, "Café crème, à bientôt!", email.BodyText)
+	assert.Equal(t, "<p>Grüße</p>", email.BodyHTML)
+	assert.False(t, email.HasAttachment)
+}
+
+func TestExtractContentManually_SinglePartText(t *testing.T) {
+	msg := &go_imap.Message{
+		BodyStructure: &go_imap.BodyStructure{
+			MIMEType: "text", MIMESubType: "plain", Encoding: "quoted-printable", Params: map[string]string{"charset": "iso-8859-1"},
+		},
+		Body: map[*go_imap.BodySectionName]go_imap.Literal{
+			{BodyPartName: go_imap.BodyPartName{Specifier: go_imap.TextSpecifier}}: bytes.NewBufferString("Gr=FC=DFe"),
+		},
+	}
+
+	email := &models.Email{}
+	extractContentManually(email, msg)
+
+	assert.Equal(t, "Grüße", email.BodyText)
+}
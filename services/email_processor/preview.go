@@ -0,0 +1,218 @@
+package email_processor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"mime"
+	"strings"
+	"sync"
+
+	"github.com/opentracing/opentracing-go"
+
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+)
+
+const (
+	defaultPreviewMaxSize = 320
+	previewJPEGQuality    = 80
+	// larger images are not decoded, protects against decompression bombs. A decoded image takes
+	// up to 4 bytes a pixel, so each worker holds at most 64MB.
+	previewMaxSourcePixels = 16_000_000
+	previewWorkers         = 2
+	// emails waiting for their previews, more are stored without previews
+	previewQueueSize = 100
+)
+
+var errPreviewTooLarge = errors.New("image too large for a preview")
+
+// previewSource is an attachment waiting for its preview, generated after the email is stored.
+// The attachment is a copy, the ingest path keeps using its own.
+type previewSource struct {
+	attachment *models.EmailAttachment
+	content    []byte
+}
+
+// previewJob holds the previews of an email with the context of its ingestion
+type previewJob struct {
+	ctx     context.Context
+	sources []previewSource
+}
+
+// previewQueue generates previews on a fixed number of workers, started with the first job.
+// Ingestion never waits for a preview: when the queue is full the email gets none.
+type previewQueue struct {
+	jobs  chan previewJob
+	start sync.Once
+}
+
+func newPreviewQueue() *previewQueue {
+	return &previewQueue{jobs: make(chan previewJob, previewQueueSize)}
+}
+
+// enqueue hands the sources to the workers, reporting false when the queue is full
+func (q *previewQueue) enqueue(job previewJob, generate func(context.Context, []previewSource)) bool {
+	q.start.Do(func() {
+		for i := 0; i < previewWorkers; i++ {
+			go func() {
+				for job := range q.jobs {
+					generate(job.ctx, job.sources)
+				}
+			}()
+		}
+	})
+
+	select {
+	case q.jobs <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *emailProcessor) previewsEnabled() bool {
+	return p.previews != nil && p.previews.Enabled && p.previewQueue != nil
+}
+
+// previewSupported tells whether a preview can be generated for the content type
+func previewSupported(contentType string) bool {
+	switch previewMediaType(contentType) {
+	case "image/jpeg", "image/jpg", "image/png", "image/gif", "application/pdf":
+		return true
+	}
+	return false
+}
+
+func previewMediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return mediaType
+}
+
+// generatePreviews creates and stores the previews one by one, it runs off the ingest path so
+// failures are only traced
+func (p *emailProcessor) generatePreviews(ctx context.Context, sources []previewSource) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailProcessor.generatePreviews")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+
+	maxSize := defaultPreviewMaxSize
+	if p.previews != nil && p.previews.MaxSizePx > 0 {
+		maxSize = p.previews.MaxSizePx
+	}
+
+	for _, source := range sources {
+		preview, err := generatePreview(source.content, source.attachment.ContentType, maxSize)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			continue
+		}
+		if preview == nil {
+			continue
+		}
+		err = p.repositories.EmailAttachmentRepository.StorePreview(ctx, source.attachment, preview)
+		if err != nil {
+			tracing.TraceErr(span, err)
+		}
+	}
+}
+
+// generatePreview returns a JPEG thumbnail fitting maxSize x maxSize. PDFs are previewed from their
+// first embedded JPEG image, which for scans is the first page; PDFs without one get no preview.
+func generatePreview(content []byte, contentType string, maxSize int) ([]byte, error) {
+	if previewMediaType(contentType) == "application/pdf" {
+		content = firstPDFImage(content)
+		if content == nil {
+			return nil, nil
+		}
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	if config.Width*config.Height > previewMaxSourcePixels {
+		return nil, errPreviewTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err = jpeg.Encode(&buf, thumbnail(img, maxSize), &jpeg.Options{Quality: previewJPEGQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// thumbnail scales the image down to fit maxSize, averaging the source pixels of each target pixel.
+// Transparent areas are drawn on white since JPEG has no alpha.
+func thumbnail(src image.Image, maxSize int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	targetWidth, targetHeight := width, height
+	if width > maxSize || height > maxSize {
+		if width >= height {
+			targetWidth, targetHeight = maxSize, max(1, height*maxSize/width)
+		} else {
+			targetWidth, targetHeight = max(1, width*maxSize/height), maxSize
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, targetWidth, targetHeight))
+	for y := 0; y < targetHeight; y++ {
+		y0 := bounds.Min.Y + y*height/targetHeight
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*height/targetHeight)
+		for x := 0; x < targetWidth; x++ {
+			x0 := bounds.Min.X + x*width/targetWidth
+			x1 := max(x0+1, bounds.Min.X+(x+1)*width/targetWidth)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			// premultiplied colors over white
+			white := 0xffff - a/n
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r/n + white),
+				G: uint16(g/n + white),
+				B: uint16(b/n + white),
+				A: 0xffff,
+			})
+		}
+	}
+	return dst
+}
+
+// firstPDFImage returns the data of the first DCTDecode (JPEG) stream of a PDF
+func firstPDFImage(content []byte) []byte {
+	filter := bytes.Index(content, []byte("/DCTDecode"))
+	if filter < 0 {
+		return nil
+	}
+	start := bytes.Index(content[filter:], []byte("stream"))
+	if start < 0 {
+		return nil
+	}
+	data := content[filter+start+len("stream"):]
+	data = bytes.TrimPrefix(data, []byte("\r"))
+	data = bytes.TrimPrefix(data, []byte("\n"))
+	if end := bytes.Index(data, []byte("endstream")); end >= 0 {
+		data = data[:end]
+	}
+	return data
+}
@@ -0,0 +1,123 @@
+package email_processor
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodedImage(t *testing.T, width, height int, encode func(*bytes.Buffer, image.Image) error) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.NRGBA{R: 200, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, encode(&buf, img))
+	return buf.Bytes()
+}
+
+func encodePNG(buf *bytes.Buffer, img image.Image) error { return png.Encode(buf, img) }
+
+func encodeJPEG(buf *bytes.Buffer, img image.Image) error { return jpeg.Encode(buf, img, nil) }
+
+func TestGeneratePreview(t *testing.T) {
+	t.Run("image is scaled to fit", func(t *testing.T) {
+		preview, err := generatePreview(encodedImage(t, 800, 400, encodePNG), "image/png; name=chart.png", 320)
+		require.NoError(t, err)
+
+		img, format, err := image.Decode(bytes.NewReader(preview))
+		require.NoError(t, err)
+		assert.Equal(t, "jpeg", format)
+		assert.Equal(t, image.Rect(0, 0, 320, 160), img.Bounds())
+		r, g, _, _ := img.At(10, 10).RGBA()
+		assert.InDelta(t, 200, r>>8, 8)
+		assert.InDelta(t, 0, g>>8, 8)
+	})
+
+	t.Run("small image keeps its size", func(t *testing.T) {
+		preview, err := generatePreview(encodedImage(t, 40, 90, encodePNG), "image/png", 320)
+		require.NoError(t, err)
+		config, err := jpeg.DecodeConfig(bytes.NewReader(preview))
+		require.NoError(t, err)
+		assert.Equal(t, 40, config.Width)
+		assert.Equal(t, 90, config.Height)
+	})
+
+	t.Run("transparent pixels become white", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 10, 10))))
+		preview, err := generatePreview(buf.Bytes(), "image/png", 320)
+		require.NoError(t, err)
+		img, err := jpeg.Decode(bytes.NewReader(preview))
+		require.NoError(t, err)
+		r, g, b, _ := img.At(5, 5).RGBA()
+		assert.Equal(t, []uint32{255, 255, 255}, []uint32{r >> 8, g >> 8, b >> 8})
+	})
+
+	t.Run("pdf with a scanned page", func(t *testing.T) {
+		pdf := append([]byte("%PDF-1.4\n4 0 obj\n<< /Type /XObject /Subtype /Image /Width 600 /Height 900 /Filter /DCTDecode >>\nstream\r\n"),
+			encodedImage(t, 600, 900, encodeJPEG)...)
+		pdf = append(pdf, []byte("\nendstream\nendobj\n%%EOF")...)
+
+		preview, err := generatePreview(pdf, "application/pdf", 320)
+		require.NoError(t, err)
+		config, err := jpeg.DecodeConfig(bytes.NewReader(preview))
+		require.NoError(t, err)
+		assert.Equal(t, 213, config.Width)
+		assert.Equal(t, 320, config.Height)
+	})
+
+	t.Run("pdf without images has no preview", func(t *testing.T) {
+		preview, err := generatePreview([]byte("%PDF-1.4\n1 0 obj\n<< /Type /Catalog >>\nendobj\n%%EOF"), "application/pdf", 320)
+		require.NoError(t, err)
+		assert.Nil(t, preview)
+	})
+
+	t.Run("corrupt image", func(t *testing.T) {
+		_, err := generatePreview([]byte("not an image"), "image/jpeg", 320)
+		require.Error(t, err)
+	})
+}
+
+func TestPreviewSupported(t *testing.T) {
+	assert.True(t, previewSupported("image/JPEG"))
+	assert.True(t, previewSupported("application/pdf; name=invoice.pdf"))
+	assert.False(t, previewSupported("image/svg+xml"))
+	assert.False(t, previewSupported("application/zip"))
+	assert.False(t, previewSupported(""))
+}
+
+func TestPreviewQueue(t *testing.T) {
+	queue := newPreviewQueue()
+	blocked := make(chan struct{})
+	var running atomic.Int32
+	generate := func(context.Context, []previewSource) {
+		running.Add(1)
+		<-blocked
+	}
+
+	// the workers take a job each, then the queue fills up
+	accepted := 0
+	for i := 0; i < previewWorkers+previewQueueSize+10; i++ {
+		if queue.enqueue(previewJob{ctx: context.Background()}, generate) {
+			accepted++
+		}
+		if i < previewWorkers {
+			require.Eventually(t, func() bool { return running.Load() == int32(i+1) }, time.Second, time.Millisecond)
+		}
+	}
+	assert.Equal(t, previewWorkers+previewQueueSize, accepted)
+	assert.Equal(t, int32(previewWorkers), running.Load())
+	close(blocked)
+}
@@ -0,0 +1,78 @@
+package email_processor
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	replyHeaderPattern     = regexp.MustCompile(`(?i)^on\s.+\swrote:$`)
+	originalMessagePattern = regexp.MustCompile(`(?i)^-{2,}\s*original message\s*-{2,}$`)
+	outlookSeparator       = regexp.MustCompile(`^_{10,}$`)
+	outlookFromPattern     = regexp.MustCompile(`(?i)^\*?from:\*?\s`)
+	outlookSentPattern     = regexp.MustCompile(`(?i)^\*?(sent|date):\*?\s`)
+)
+
+// splitQuotedText separates the new content of a plain text reply from the quoted
+// history and signature below it. Returns the visible part and the removed part.
+func splitQuotedText(text string) (string, string) {
+	if strings.TrimSpace(text) == "" {
+		return "", ""
+	}
+
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	cut := len(lines)
+	for i := range lines {
+		if isQuoteStart(lines, i) {
+			cut = i
+			break
+		}
+	}
+
+	// signature delimiter (RFC 3676) above the quoted history
+	for i := 0; i < cut; i++ {
+		if lines[i] == "-- " || lines[i] == "--" {
+			cut = i
+			break
+		}
+	}
+
+	visible := strings.TrimSpace(strings.Join(lines[:cut], "\n"))
+	quoted := strings.TrimSpace(strings.Join(lines[cut:], "\n"))
+	return visible, quoted
+}
+
+func isQuoteStart(lines []string, i int) bool {
+	line := strings.TrimSpace(lines[i])
+
+	switch {
+	case strings.HasPrefix(line, ">"):
+		return true
+	case originalMessagePattern.MatchString(line):
+		return true
+	case replyHeaderPattern.MatchString(line):
+		return true
+	}
+
+	// "On ... wrote:" wrapped over two lines by the sending client
+	if strings.HasPrefix(strings.ToLower(line), "on ") && i+1 < len(lines) {
+		if replyHeaderPattern.MatchString(line + " " + strings.TrimSpace(lines[i+1])) {
+			return true
+		}
+	}
+
+	// Outlook: optional underscore separator followed by a From:/Sent: header block
+	if outlookSeparator.MatchString(line) && i+1 < len(lines) && outlookFromPattern.MatchString(strings.TrimSpace(lines[i+1])) {
+		return true
+	}
+	if outlookFromPattern.MatchString(line) {
+		for j := i + 1; j < len(lines) && j <= i+3; j++ {
+			if outlookSentPattern.MatchString(strings.TrimSpace(lines[j])) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
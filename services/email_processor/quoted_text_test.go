@@ -0,0 +1,91 @@
+package email_processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitQuotedText(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		visible string
+		quoted  string
+	}{
+		{
+			name:    "no quote",
+			text:    "Hi Jane,\n\nSounds good, see you Monday.\n",
+			visible: "Hi Jane,\n\nSounds good, see you Monday.",
+			quoted:  "",
+		},
+		{
+			name:    "empty",
+			text:    " \r\n ",
+			visible: "",
+			quoted:  "",
+		},
+		{
+			name:    "reply header",
+			text:    "Works for me.\r\n\r\nOn Mon, Mar 10, 2025 at 9:00 AM Jane Doe <jane@example.com> wrote:\r\n> Does Monday work?\r\n",
+			visible: "Works for me.",
+			quoted:  "On Mon, Mar 10, 2025 at 9:00 AM Jane Doe <jane@example.com> wrote:\n> Does Monday work?",
+		},
+		{
+			name:    "reply header wrapped over two lines",
+			text:    "Works for me.\n\nOn Mon, Mar 10, 2025 at 9:00 AM Jane Doe\n<jane@example.com> wrote:\n> Does Monday work?",
+			visible: "Works for me.",
+			quoted:  "On Mon, Mar 10, 2025 at 9:00 AM Jane Doe\n<jane@example.com> wrote:\n> Does Monday work?",
+		},
+		{
+			name:    "quote markers without header",
+			text:    "Yes.\n> Does Monday work?\n>> Let's meet next week",
+			visible: "Yes.",
+			quoted:  "> Does Monday work?\n>> Let's meet next week",
+		},
+		{
+			name:    "outlook separator",
+			text:    "Approved.\n\n________________________________\nFrom: Jane Doe <jane@example.com>\nSent: Monday, March 10, 2025 9:00 AM\nSubject: Budget",
+			visible: "Approved.",
+			quoted:  "________________________________\nFrom: Jane Doe <jane@example.com>\nSent: Monday, March 10, 2025 9:00 AM\nSubject: Budget",
+		},
+		{
+			name:    "outlook header block without separator",
+			text:    "Approved.\n\n*From:* Jane Doe <jane@example.com>\n*Date:* Monday, March 10, 2025\n*Subject:* Budget",
+			visible: "Approved.",
+			quoted:  "*From:* Jane Doe <jane@example.com>\n*Date:* Monday, March 10, 2025\n*Subject:* Budget",
+		},
+		{
+			name:    "original message divider",
+			text:    "Forwarding this.\n-----Original Message-----\nFrom: bob@example.com",
+			visible: "Forwarding this.",
+			quoted:  "-----Original Message-----\nFrom: bob@example.com",
+		},
+		{
+			name:    "signature above the quote",
+			text:    "Thanks!\n-- \nJane Doe\nAcme Inc.\n\nOn Mon, Mar 10, 2025 Bob wrote:\n> Invoice attached",
+			visible: "Thanks!",
+			quoted:  "-- \nJane Doe\nAcme Inc.\n\nOn Mon, Mar 10, 2025 Bob wrote:\n> Invoice attached",
+		},
+		{
+			name:    "from line in the body is kept",
+			text:    "From: the sales team, with love.\nSee you soon.",
+			visible: "From: the sales team, with love.\nSee you soon.",
+			quoted:  "",
+		},
+		{
+			name:    "sentence starting with on is kept",
+			text:    "On second thought, let's do Tuesday.\nThanks",
+			visible: "On second thought, let's do Tuesday.\nThanks",
+			quoted:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			visible, quoted := splitQuotedText(tt.text)
+			assert.Equal(t, tt.visible, visible)
+			assert.Equal(t, tt.quoted, quoted)
+		})
+	}
+}
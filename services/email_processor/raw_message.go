@@ -0,0 +1,36 @@
+package email_processor
+
+import (
+	"context"
+
+	"github.com/opentracing/opentracing-go"
+
+	"github.com/customeros/mailstack/internal/tracing"
+)
+
+// StoreRawMessage keeps the original source of a stored email in attachment storage and points
+// the email at it, so it can be parsed again when the parsing improves
+func (p *emailProcessor) StoreRawMessage(ctx context.Context, emailID string, rawMessage []byte) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailProcessor.StoreRawMessage")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.SetTag("email.id", emailID)
+	span.LogKV("size", len(rawMessage))
+
+	if emailID == "" || len(rawMessage) == 0 {
+		return nil
+	}
+
+	key, err := p.repositories.EmailRawRepository.Save(ctx, emailID, rawMessage)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	if err = p.repositories.EmailRepository.SetRawKey(ctx, emailID, key); err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	return nil
+}
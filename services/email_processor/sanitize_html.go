@@ -0,0 +1,205 @@
+package email_processor
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"github.com/customeros/mailstack/internal/config"
+)
+
+var defaultAllowedTags = []string{
+	"a", "abbr", "address", "b", "bdi", "bdo", "big", "blockquote", "body", "br", "caption", "center",
+	"cite", "code", "col", "colgroup", "dd", "del", "dfn", "div", "dl", "dt", "em", "font", "h1", "h2",
+	"h3", "h4", "h5", "h6", "head", "hr", "html", "i", "img", "ins", "kbd", "li", "mark", "ol", "p",
+	"pre", "q", "s", "samp", "small", "span", "strike", "strong", "style", "sub", "sup", "table",
+	"tbody", "td", "tfoot", "th", "thead", "title", "tr", "tt", "u", "ul", "var", "wbr",
+}
+
+var defaultAllowedAttributes = []string{
+	"align", "alt", "bgcolor", "border", "cellpadding", "cellspacing", "class", "color", "colspan",
+	"dir", "face", "height", "href", "hspace", "id", "lang", "name", "rowspan", "size", "span", "src",
+	"start", "style", "summary", "target", "title", "type", "valign", "vspace", "width",
+}
+
+// tags removed together with everything inside them
+var strippedWithContent = map[string]bool{
+	"script": true, "iframe": true, "object": true, "embed": true, "applet": true, "frame": true,
+	"frameset": true, "noscript": true, "template": true, "form": true, "button": true, "select": true,
+	"textarea": true, "svg": true, "math": true, "base": true, "meta": true, "link": true,
+}
+
+// attributes holding a url that must use a safe scheme
+var urlAttributes = map[string]bool{
+	"href": true, "src": true, "background": true, "action": true, "poster": true, "cite": true,
+	"longdesc": true, "usemap": true,
+}
+
+var (
+	unsafeCSSPattern = regexp.MustCompile(`(?i)(expression\s*\(|javascript\s*:|vbscript\s*:|behavior\s*:|-moz-binding|@import)`)
+	urlSchemePattern = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9+.\-]*):`)
+	safeURLSchemes   = map[string]bool{"http": true, "https": true, "mailto": true, "tel": true, "cid": true}
+)
+
+// HTMLSanitizer strips scripts, event handlers and dangerous attributes from inbound
+// HTML while keeping the formatting markup emails rely on
+type HTMLSanitizer struct {
+	allowedTags       map[string]bool
+	allowedAttributes map[string]bool
+}
+
+func NewHTMLSanitizer(cfg *config.HTMLSanitizerConfig) *HTMLSanitizer {
+	tags, attributes := defaultAllowedTags, defaultAllowedAttributes
+	if cfg != nil && len(cfg.AllowedTags) > 0 {
+		tags = cfg.AllowedTags
+	}
+	if cfg != nil && len(cfg.AllowedAttributes) > 0 {
+		attributes = cfg.AllowedAttributes
+	}
+
+	return &HTMLSanitizer{
+		allowedTags:       toLowerSet(tags),
+		allowedAttributes: toLowerSet(attributes),
+	}
+}
+
+// Sanitize returns the cleaned HTML. Tags outside the allowlist are unwrapped, keeping their text.
+func (s *HTMLSanitizer) Sanitize(input string) string {
+	if input == "" {
+		return ""
+	}
+
+	var out strings.Builder
+	tokenizer := html.NewTokenizer(strings.NewReader(input))
+
+	skipTag := ""
+	skipDepth := 0
+	inStyle := false
+
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case html.ErrorToken:
+			return out.String()
+
+		case html.TextToken:
+			if skipDepth > 0 {
+				continue
+			}
+			text := string(tokenizer.Text())
+			if inStyle {
+				// css is raw text, escaping it would break selectors
+				if !unsafeCSSPattern.MatchString(text) {
+					out.WriteString(text)
+				}
+				continue
+			}
+			out.WriteString(html.EscapeString(text))
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			name := strings.ToLower(token.Data)
+
+			if skipDepth > 0 {
+				if name == skipTag && tokenType == html.StartTagToken {
+					skipDepth++
+				}
+				continue
+			}
+			if strippedWithContent[name] {
+				if tokenType == html.StartTagToken && !isVoidElement(name) {
+					skipTag = name
+					skipDepth = 1
+				}
+				continue
+			}
+			if !s.allowedTags[name] {
+				continue
+			}
+			if name == "style" && tokenType == html.StartTagToken {
+				inStyle = true
+			}
+
+			token.Attr = s.sanitizeAttributes(token.Attr)
+			out.WriteString(token.String())
+
+		case html.EndTagToken:
+			token := tokenizer.Token()
+			name := strings.ToLower(token.Data)
+
+			if skipDepth > 0 {
+				if name == skipTag {
+					skipDepth--
+				}
+				continue
+			}
+			if name == "style" {
+				inStyle = false
+			}
+			if s.allowedTags[name] {
+				out.WriteString(token.String())
+			}
+
+		case html.CommentToken, html.DoctypeToken:
+			// dropped, conditional comments can carry markup
+		}
+	}
+}
+
+func (s *HTMLSanitizer) sanitizeAttributes(attributes []html.Attribute) []html.Attribute {
+	cleaned := make([]html.Attribute, 0, len(attributes))
+	for _, attr := range attributes {
+		key := strings.ToLower(attr.Key)
+
+		if attr.Namespace != "" || strings.HasPrefix(key, "on") || !s.allowedAttributes[key] {
+			continue
+		}
+		if urlAttributes[key] && !isSafeURL(attr.Val) {
+			continue
+		}
+		if key == "style" && unsafeCSSPattern.MatchString(attr.Val) {
+			continue
+		}
+
+		attr.Key = key
+		cleaned = append(cleaned, attr)
+	}
+	return cleaned
+}
+
+// isSafeURL allows relative urls and the schemes in safeURLSchemes. Inline cid: images are kept.
+func isSafeURL(value string) bool {
+	// browsers ignore control characters and whitespace inside the scheme
+	normalized := strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, value)
+
+	match := urlSchemePattern.FindStringSubmatch(normalized)
+	if match == nil {
+		return true
+	}
+	return safeURLSchemes[strings.ToLower(match[1])]
+}
+
+func isVoidElement(name string) bool {
+	switch name {
+	case "area", "base", "br", "col", "embed", "hr", "img", "input", "link", "meta", "source", "track", "wbr":
+		return true
+	}
+	return false
+}
+
+func toLowerSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value != "" {
+			set[value] = true
+		}
+	}
+	return set
+}
@@ -0,0 +1,109 @@
+package email_processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/customeros/mailstack/internal/config"
+)
+
+func TestHTMLSanitizer_XSSPayloads(t *testing.T) {
+	sanitizer := NewHTMLSanitizer(nil)
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "script tag",
+			input:    `<p>hi</p><script>alert(1)</script>`,
+			expected: `<p>hi</p>`,
+		},
+		{
+			name:     "nested script content",
+			input:    `<div><script>document.write("<b>x</b>")</script>ok</div>`,
+			expected: `<div>ok</div>`,
+		},
+		{
+			name:     "event handler",
+			input:    `<img src="https://example.com/a.png" onerror="alert(1)">`,
+			expected: `<img src="https://example.com/a.png">`,
+		},
+		{
+			name:     "uppercase event handler",
+			input:    `<div ONMOUSEOVER="alert(1)">x</div>`,
+			expected: `<div>x</div>`,
+		},
+		{
+			name:     "javascript href",
+			input:    `<a href="javascript:alert(1)">click</a>`,
+			expected: `<a>click</a>`,
+		},
+		{
+			name:     "obfuscated javascript href",
+			input:    `<a href="jav&#x09;ascript:alert(1)">click</a>`,
+			expected: `<a>click</a>`,
+		},
+		{
+			name:     "data uri",
+			input:    `<a href="data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==">x</a>`,
+			expected: `<a>x</a>`,
+		},
+		{
+			name:     "iframe",
+			input:    `<iframe src="https://evil.com"></iframe><p>text</p>`,
+			expected: `<p>text</p>`,
+		},
+		{
+			name:     "svg onload",
+			input:    `<svg onload="alert(1)"><circle/></svg>after`,
+			expected: `after`,
+		},
+		{
+			name:     "css expression",
+			input:    `<div style="width: expression(alert(1))">x</div>`,
+			expected: `<div>x</div>`,
+		},
+		{
+			name:     "style block with javascript url",
+			input:    `<style>body { background: url("javascript:alert(1)") }</style><p>x</p>`,
+			expected: `<style></style><p>x</p>`,
+		},
+		{
+			name:     "unknown tag is unwrapped",
+			input:    `<blink>hello</blink>`,
+			expected: `hello`,
+		},
+		{
+			name:     "comment dropped",
+			input:    `<!--[if mso]><script>alert(1)</script><![endif]--><p>x</p>`,
+			expected: `<p>x</p>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, sanitizer.Sanitize(tt.input))
+		})
+	}
+}
+
+func TestHTMLSanitizer_PreservesFormatting(t *testing.T) {
+	sanitizer := NewHTMLSanitizer(nil)
+
+	input := `<table width="100%" cellpadding="0"><tr><td style="color: #333; font-weight: bold">Hi &amp; welcome</td></tr></table>` +
+		`<img src="cid:logo@acme.com" alt="logo"><a href="https://acme.com?a=1&amp;b=2">link</a><style>p > a { color: red; }</style>`
+
+	assert.Equal(t, input, sanitizer.Sanitize(input))
+}
+
+func TestHTMLSanitizer_ConfigurableAllowlist(t *testing.T) {
+	sanitizer := NewHTMLSanitizer(&config.HTMLSanitizerConfig{
+		AllowedTags:       []string{"p"},
+		AllowedAttributes: []string{"class"},
+	})
+
+	assert.Equal(t, `<p class="x">a b</p>`, sanitizer.Sanitize(`<p class="x" id="y">a <b>b</b></p>`))
+}
@@ -0,0 +1,399 @@
+package email_processor
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/opentracing/opentracing-go"
+
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+const (
+	// a single keyword in a body is mostly incidental, it takes several of one category to be sensitive
+	sensitiveBodyMinKeywords = 3
+	// only the start of long bodies is scanned, the rest is mostly quoted history
+	sensitiveBodyScanLimit = 64 * 1024
+)
+
+// genericBodyKeywords are common in ordinary business mail. They classify a subject but are not
+// counted in a body, where they say little about the content.
+var genericBodyKeywords = map[string]bool{
+	"private": true, "sensitive": true, "confidential": true, "secret": true, "restricted": true,
+	"privileged": true, "internal use": true, "legal": true, "terms": true, "agreement": true,
+	"compliance": true, "regulatory": true, "court": true, "counsel": true, "settlement": true,
+	"tax": true, "invoice": true, "revenue": true, "earnings": true, "bonus": true, "equity": true,
+	"compensation": true, "personal": true, "health": true, "dob": true, "login": true,
+	"password": true, "security": true, "incident": true, "authentication": true, "hack": true,
+	"deal": true, "transaction": true, "valuation": true, "acquisition": true, "loi": true,
+	"interview": true, "candidate": true, "hiring": true, "recruitment": true, "complaint": true,
+	"termination": true, "sbu": true, "cui": true, "fouo": true,
+}
+
+// disclaimerPhrases mark the legal footer paragraphs appended by mail servers, which are full of
+// confidentiality keywords
+var disclaimerPhrases = []string{
+	"intended recipient", "intended solely", "intended only for", "received this email in error",
+	"received this message in error", "received this e-mail in error", "disclaimer",
+}
+
+// defaultSensitiveRules are compiled once and shared by all tenants without overrides
+var defaultSensitiveRules = newDefaultSensitiveRules().compile()
+
+// sensitiveRules are the keywords that classify an email as sensitive by its subject or body,
+// all lowercase
+type sensitiveRules struct {
+	keywordGroups          map[string][]string
+	confidentialityMarkers []string
+	classificationLevels   []string
+
+	body *sensitiveBodyMatcher
+}
+
+// sensitiveBodyMatcher matches the keywords as whole words in a single pass over the body
+type sensitiveBodyMatcher struct {
+	keywords   *regexp.Regexp
+	categories map[string]string
+	markers    *regexp.Regexp
+}
+
+func newDefaultSensitiveRules() sensitiveRules {
+	return sensitiveRules{
+		keywordGroups: map[string][]string{
+			"confidentiality": {
+				"confidential", "private", "sensitive", "do not share", "do not forward",
+				"nda", "under nda", "confidentiality agreement", "privileged",
+				"secret", "restricted", "internal only", "internal use", "not for distribution",
+			},
+			"financial": {
+				"financial report", "quarterly results", "annual results", "revenue",
+				"profit margin", "earnings", "balance sheet", "tax", "invoice", "salary",
+				"compensation", "bonus", "stock options", "equity",
+			},
+			"legal": {
+				"legal", "lawsuit", "litigation", "settlement", "contract review",
+				"agreement", "terms", "legal review", "compliance", "regulatory",
+				"attorney", "counsel", "court", "subpoena", "trademark",
+			},
+			"personal": {
+				"personal", "medical", "health", "patient", "ssn", "social security",
+				"date of birth", "dob", "passport", "driver license", "id number",
+				"background check", "performance review",
+			},
+			"security": {
+				"password", "login", "credentials", "access code", "security", "breach",
+				"vulnerability", "hack", "incident", "authentication",
+			},
+			"merger": {
+				"merger", "acquisition", "m&a", "due diligence", "deal", "takeover",
+				"buyout", "transaction", "valuation", "term sheet", "loi", "letter of intent",
+			},
+			"hr": {
+				"termination", "firing", "layoff", "severance", "redundancy",
+				"disciplinary", "complaint", "grievance", "harassment", "discrimination",
+				"interview", "candidate", "recruitment", "hiring",
+			},
+		},
+		confidentialityMarkers: []string{
+			"[confidential]", "(confidential)", "***confidential***", "###confidential###",
+			"[sensitive]", "(sensitive)", "***sensitive***", "###sensitive###",
+			"[private]", "(private)", "***private***", "###private###",
+		},
+		classificationLevels: []string{
+			"top secret", "secret", "confidential", "restricted", "classified",
+			"sensitive but unclassified", "sbu", "for official use only", "fouo",
+			"controlled unclassified", "cui",
+		},
+	}
+}
+
+// withOverrides applies the tenant overrides on top of the rules. An override replaces the
+// keywords of its category or turns the category off, unknown categories add a keyword group.
+func (r sensitiveRules) withOverrides(overrides []models.SensitiveSubjectKeywords) sensitiveRules {
+	result := sensitiveRules{
+		keywordGroups:          make(map[string][]string, len(r.keywordGroups)),
+		confidentialityMarkers: r.confidentialityMarkers,
+		classificationLevels:   r.classificationLevels,
+	}
+	for category, keywords := range r.keywordGroups {
+		result.keywordGroups[category] = keywords
+	}
+
+	for _, override := range overrides {
+		category := strings.ToLower(strings.TrimSpace(override.Category))
+		var keywords []string
+		if !override.Disabled {
+			keywords = normalizeKeywords(override.Keywords)
+		}
+
+		switch category {
+		case models.SensitiveCategoryConfidentialityMarkers:
+			result.confidentialityMarkers = keywords
+		case models.SensitiveCategoryClassificationLevels:
+			result.classificationLevels = keywords
+		default:
+			if len(keywords) == 0 {
+				delete(result.keywordGroups, category)
+			} else {
+				result.keywordGroups[category] = keywords
+			}
+		}
+	}
+
+	return result.compile()
+}
+
+// compile prepares the body matcher of the rules
+func (r sensitiveRules) compile() sensitiveRules {
+	matcher := &sensitiveBodyMatcher{categories: make(map[string]string)}
+
+	var keywords []string
+	for category, groupKeywords := range r.keywordGroups {
+		for _, keyword := range groupKeywords {
+			if _, exists := matcher.categories[keyword]; !exists {
+				matcher.categories[keyword] = category
+				keywords = append(keywords, keyword)
+			}
+		}
+	}
+	for _, level := range r.classificationLevels {
+		if _, exists := matcher.categories[level]; !exists {
+			matcher.categories[level] = "classification"
+			keywords = append(keywords, level)
+		}
+	}
+
+	matcher.keywords = alternation(keywords, true)
+	matcher.markers = alternation(r.confidentialityMarkers, false)
+	r.body = matcher
+	return r
+}
+
+// alternation compiles the phrases into one regexp, longest first so phrases win over the words
+// they contain. Whole word matching only adds boundaries next to word characters, "m&a" matches.
+func alternation(phrases []string, wholeWords bool) *regexp.Regexp {
+	if len(phrases) == 0 {
+		return nil
+	}
+
+	sorted := append([]string{}, phrases...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if len(sorted[i]) != len(sorted[j]) {
+			return len(sorted[i]) > len(sorted[j])
+		}
+		return sorted[i] < sorted[j]
+	})
+
+	patterns := make([]string, len(sorted))
+	for i, phrase := range sorted {
+		pattern := regexp.QuoteMeta(phrase)
+		if wholeWords && isWordChar(phrase[0]) {
+			pattern = `\b` + pattern
+		}
+		if wholeWords && isWordChar(phrase[len(phrase)-1]) {
+			pattern = pattern + `\b`
+		}
+		patterns[i] = pattern
+	}
+	return regexp.MustCompile(strings.Join(patterns, "|"))
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || ('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// matchSubject returns whether the subject contains a sensitive keyword and the reason, case-insensitive
+func (r sensitiveRules) matchSubject(subject string) (bool, string) {
+	lowerSubject := strings.ToLower(subject)
+
+	// categories are checked in a stable order so the same subject always gives the same reason
+	categories := make([]string, 0, len(r.keywordGroups))
+	for category := range r.keywordGroups {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	for _, category := range categories {
+		for _, keyword := range r.keywordGroups[category] {
+			if strings.Contains(lowerSubject, keyword) {
+				return true, fmt.Sprintf("Subject contains %s-related sensitive keyword: '%s'", category, keyword)
+			}
+		}
+	}
+
+	for _, marker := range r.confidentialityMarkers {
+		if strings.Contains(lowerSubject, marker) {
+			return true, fmt.Sprintf("Subject contains explicit confidentiality marker: '%s'", marker)
+		}
+	}
+
+	for _, level := range r.classificationLevels {
+		if strings.Contains(lowerSubject, level) {
+			return true, fmt.Sprintf("Subject contains formal classification level: '%s'", level)
+		}
+	}
+
+	return false, ""
+}
+
+// matchBody returns whether the body is sensitive and the reason. Only the new content is scanned,
+// without quoted history, signature and disclaimers. A confidentiality marker is enough, otherwise
+// one category needs sensitiveBodyMinKeywords distinct keywords that are not generic.
+func (r sensitiveRules) matchBody(body string) (bool, string) {
+	if r.body == nil || body == "" {
+		return false, ""
+	}
+	if len(body) > sensitiveBodyScanLimit {
+		body = body[:sensitiveBodyScanLimit]
+	}
+	lowerBody := strings.ToLower(withoutDisclaimers(body))
+
+	if r.body.markers != nil {
+		if marker := r.body.markers.FindString(lowerBody); marker != "" {
+			return true, fmt.Sprintf("Body contains explicit confidentiality marker: '%s'", marker)
+		}
+	}
+
+	if r.body.keywords == nil {
+		return false, ""
+	}
+	var categories []string
+	matches := make(map[string][]string)
+	seen := make(map[string]bool)
+	for _, keyword := range r.body.keywords.FindAllString(lowerBody, -1) {
+		if seen[keyword] || genericBodyKeywords[keyword] {
+			continue
+		}
+		seen[keyword] = true
+		category := r.body.categories[keyword]
+		if _, exists := matches[category]; !exists {
+			categories = append(categories, category)
+		}
+		matches[category] = append(matches[category], fmt.Sprintf("'%s'", keyword))
+	}
+	for _, category := range categories {
+		if len(matches[category]) >= sensitiveBodyMinKeywords {
+			return true, fmt.Sprintf("Body contains %d %s-related sensitive keywords: %s",
+				len(matches[category]), category, strings.Join(matches[category], ", "))
+		}
+	}
+	return false, ""
+}
+
+// withoutDisclaimers returns the visible text of the body without the paragraphs of legal disclaimers
+func withoutDisclaimers(body string) string {
+	visible, _ := splitQuotedText(body)
+
+	paragraphs := strings.Split(visible, "\n\n")
+	kept := paragraphs[:0]
+	for _, paragraph := range paragraphs {
+		lowerParagraph := strings.ToLower(paragraph)
+		disclaimer := false
+		for _, phrase := range disclaimerPhrases {
+			if strings.Contains(lowerParagraph, phrase) {
+				disclaimer = true
+				break
+			}
+		}
+		if !disclaimer {
+			kept = append(kept, paragraph)
+		}
+	}
+	return strings.Join(kept, "\n\n")
+}
+
+// tenantSensitiveRules returns the rules of the email tenant, the defaults when the tenant has
+// no overrides or they can not be loaded
+func (p *emailProcessor) tenantSensitiveRules(ctx context.Context, email *models.Email) sensitiveRules {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailProcessor.tenantSensitiveRules")
+	defer span.Finish()
+
+	defaults := defaultSensitiveRules
+	if p.repositories == nil || p.repositories.SensitiveSubjectKeywordsRepository == nil {
+		return defaults
+	}
+
+	tenant := utils.GetTenantFromContext(ctx)
+	if tenant == "" && email.MailboxID != "" {
+		mailbox, err := p.repositories.MailboxRepository.GetMailbox(ctx, email.MailboxID)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return defaults
+		}
+		tenant = mailbox.Tenant
+	}
+	if tenant == "" {
+		return defaults
+	}
+
+	overrides, err := p.repositories.SensitiveSubjectKeywordsRepository.GetByTenant(ctx, tenant)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return defaults
+	}
+	if len(overrides) == 0 {
+		return defaults
+	}
+	if p.sensitiveRules == nil {
+		return defaults.withOverrides(overrides)
+	}
+	return p.sensitiveRules.get(tenant, overrides)
+}
+
+// sensitiveRulesCache keeps the compiled rules of each tenant with overrides. The overrides are
+// read for every email, the rules are compiled again only when they changed.
+type sensitiveRulesCache struct {
+	mu      sync.Mutex
+	entries map[string]sensitiveRulesEntry
+}
+
+type sensitiveRulesEntry struct {
+	fingerprint string
+	rules       sensitiveRules
+}
+
+func newSensitiveRulesCache() *sensitiveRulesCache {
+	return &sensitiveRulesCache{entries: make(map[string]sensitiveRulesEntry)}
+}
+
+func (c *sensitiveRulesCache) get(tenant string, overrides []models.SensitiveSubjectKeywords) sensitiveRules {
+	fingerprint := overridesFingerprint(overrides)
+
+	c.mu.Lock()
+	entry, ok := c.entries[tenant]
+	c.mu.Unlock()
+	if ok && entry.fingerprint == fingerprint {
+		return entry.rules
+	}
+
+	rules := defaultSensitiveRules.withOverrides(overrides)
+	c.mu.Lock()
+	c.entries[tenant] = sensitiveRulesEntry{fingerprint: fingerprint, rules: rules}
+	c.mu.Unlock()
+	return rules
+}
+
+// overridesFingerprint identifies the content of the overrides, in the order they were loaded
+func overridesFingerprint(overrides []models.SensitiveSubjectKeywords) string {
+	var b strings.Builder
+	for _, override := range overrides {
+		fmt.Fprintf(&b, "%q %t %q;", override.Category, override.Disabled, []string(override.Keywords))
+	}
+	return b.String()
+}
+
+func normalizeKeywords(keywords []string) []string {
+	var normalized []string
+	for _, keyword := range keywords {
+		if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" {
+			normalized = append(normalized, keyword)
+		}
+	}
+	return normalized
+}
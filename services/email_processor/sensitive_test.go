@@ -0,0 +1,150 @@
+package email_processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/customeros/mailstack/internal/enum"
+	"github.com/customeros/mailstack/internal/models"
+)
+
+func TestSensitiveSubjectRules(t *testing.T) {
+	defaults := newDefaultSensitiveRules()
+
+	t.Run("defaults are case-insensitive", func(t *testing.T) {
+		sensitive, reason := defaults.matchSubject("Q3 INVOICE attached")
+		assert.True(t, sensitive)
+		assert.Equal(t, "Subject contains financial-related sensitive keyword: 'invoice'", reason)
+
+		sensitive, _ = defaults.matchSubject("Lunch on friday?")
+		assert.False(t, sensitive)
+	})
+
+	t.Run("disabled category", func(t *testing.T) {
+		rules := defaults.withOverrides([]models.SensitiveSubjectKeywords{
+			{Category: "hr", Disabled: true},
+		})
+		sensitive, _ := rules.matchSubject("Interview schedule")
+		assert.False(t, sensitive)
+
+		sensitive, _ = defaults.matchSubject("Interview schedule")
+		assert.True(t, sensitive, "defaults are not changed by overrides")
+	})
+
+	t.Run("replaced and added keywords", func(t *testing.T) {
+		rules := defaults.withOverrides([]models.SensitiveSubjectKeywords{
+			{Category: "financial", Keywords: []string{" Payroll "}},
+			{Category: "Projects", Keywords: []string{"Project Falcon"}},
+		})
+
+		sensitive, _ := rules.matchSubject("Invoice #123")
+		assert.False(t, sensitive)
+
+		sensitive, reason := rules.matchSubject("PAYROLL run")
+		assert.True(t, sensitive)
+		assert.Equal(t, "Subject contains financial-related sensitive keyword: 'payroll'", reason)
+
+		sensitive, reason = rules.matchSubject("project falcon kickoff")
+		assert.True(t, sensitive)
+		assert.Equal(t, "Subject contains projects-related sensitive keyword: 'project falcon'", reason)
+	})
+
+	t.Run("markers and classification levels", func(t *testing.T) {
+		rules := defaults.withOverrides([]models.SensitiveSubjectKeywords{
+			{Category: "confidentiality", Disabled: true},
+			{Category: models.SensitiveCategoryConfidentialityMarkers, Keywords: []string{"[eyes only]"}},
+			{Category: models.SensitiveCategoryClassificationLevels, Disabled: true},
+		})
+
+		sensitive, reason := rules.matchSubject("[EYES ONLY] roadmap")
+		assert.True(t, sensitive)
+		assert.Equal(t, "Subject contains explicit confidentiality marker: '[eyes only]'", reason)
+
+		sensitive, _ = rules.matchSubject("Top secret roadmap")
+		assert.False(t, sensitive)
+	})
+}
+
+func TestSensitiveBody(t *testing.T) {
+	t.Run("single keyword is not enough", func(t *testing.T) {
+		sensitive, _ := defaultSensitiveRules.matchBody("Hi,\n\nsee you tomorrow.\n\nThis email is confidential and intended for the recipient only.")
+		assert.False(t, sensitive)
+	})
+
+	t.Run("several keywords of one category", func(t *testing.T) {
+		sensitive, reason := defaultSensitiveRules.matchBody("Attached are the Salary bands, the stock options grant and the salary review.\nAlso the balance sheet.")
+		assert.True(t, sensitive)
+		assert.Equal(t, "Body contains 3 financial-related sensitive keywords: 'salary', 'stock options', 'balance sheet'", reason)
+	})
+
+	t.Run("keywords of different categories", func(t *testing.T) {
+		sensitive, _ := defaultSensitiveRules.matchBody("The lawsuit, the merger and the layoff plans are on the agenda.")
+		assert.False(t, sensitive)
+	})
+
+	t.Run("ordinary contract mail", func(t *testing.T) {
+		body := "Hi Jane,\n\nThanks for the call. Attached is the agreement with the updated terms of the deal, " +
+			"legal signed off on the tax and security sections. Let me know if the invoice schedule works.\n\n" +
+			"Best,\nBob"
+		sensitive, reason := defaultSensitiveRules.matchBody(body)
+		assert.False(t, sensitive, reason)
+	})
+
+	t.Run("disclaimer and signature are not scanned", func(t *testing.T) {
+		body := "Please review the contract review notes before Friday.\n\n" +
+			"CONFIDENTIALITY NOTICE: This message may contain privileged information. If you are not the intended " +
+			"recipient, do not forward or share it and notify the sender. Litigation, settlement and attorney " +
+			"communications are not waived.\n\n" +
+			"-- \nBob Smith, Attorney\nCounsel, litigation and subpoena support"
+		sensitive, reason := defaultSensitiveRules.matchBody(body)
+		assert.False(t, sensitive, reason)
+	})
+
+	t.Run("quoted history is not scanned", func(t *testing.T) {
+		body := "Sounds good, see you then.\n\nOn Mon, Jan 6, 2025 at 10:00 AM Bob <bob@acme.io> wrote:\n" +
+			"> The lawsuit, the litigation hold and the subpoena are attached."
+		sensitive, reason := defaultSensitiveRules.matchBody(body)
+		assert.False(t, sensitive, reason)
+	})
+
+	t.Run("whole words only", func(t *testing.T) {
+		sensitive, _ := defaultSensitiveRules.matchBody("The syntax of the dealer taxonomy and the ssnake hack-a-thon")
+		assert.False(t, sensitive)
+	})
+
+	t.Run("marker is enough", func(t *testing.T) {
+		sensitive, reason := defaultSensitiveRules.matchBody("[CONFIDENTIAL] draft below")
+		assert.True(t, sensitive)
+		assert.Equal(t, "Body contains explicit confidentiality marker: '[confidential]'", reason)
+	})
+
+	t.Run("overrides apply to the body", func(t *testing.T) {
+		rules := defaultSensitiveRules.withOverrides([]models.SensitiveSubjectKeywords{
+			{Category: "financial", Disabled: true},
+		})
+		sensitive, _ := rules.matchBody("salary, balance sheet and stock options")
+		assert.False(t, sensitive)
+	})
+}
+
+func TestSensitiveRulesCache(t *testing.T) {
+	cache := newSensitiveRulesCache()
+	overrides := []models.SensitiveSubjectKeywords{{Category: "financial", Keywords: []string{"payroll"}}}
+
+	rules := cache.get("acme", overrides)
+	assert.Same(t, rules.body, cache.get("acme", overrides).body, "unchanged overrides are not compiled again")
+
+	changed := []models.SensitiveSubjectKeywords{{Category: "financial", Keywords: []string{"payroll", "payslip"}}}
+	sensitive, _ := cache.get("acme", changed).matchSubject("Your payslip")
+	assert.True(t, sensitive)
+	sensitive, _ = cache.get("other", overrides).matchSubject("Your payslip")
+	assert.False(t, sensitive)
+}
+
+func TestSensitiveEmailsAreStored(t *testing.T) {
+	assert.True(t, isStoredClassification(enum.EmailSensitive))
+	assert.True(t, isStoredClassification(enum.EmailOK))
+	assert.False(t, isStoredClassification(enum.EmailSpam))
+	assert.False(t, isStoredClassification(enum.EmailBulk))
+}
@@ -0,0 +1,44 @@
+package email_processor
+
+import (
+	"context"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+)
+
+// MatchSentCopy reports whether a synced email is the copy of an email sent from its mailbox, e.g.
+// appended to the sent folder after delivery. The sent email takes the folder and UID of the copy
+// so its source can be fetched from the server.
+func (p *emailProcessor) MatchSentCopy(ctx context.Context, email *models.Email) (bool, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "emailProcessor.MatchSentCopy")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+
+	if email.MessageID == "" {
+		return false, nil
+	}
+
+	sent, err := p.repositories.EmailRepository.GetOutboundByMessageID(ctx, email.MailboxID, email.MessageID)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return false, err
+	}
+	if sent == nil {
+		return false, nil
+	}
+	span.LogFields(log.String("sentEmailId", sent.ID))
+
+	if sent.ImapUID == 0 {
+		sent.Folder = email.Folder
+		sent.ImapUID = email.ImapUID
+		if err = p.repositories.EmailRepository.Update(ctx, sent); err != nil {
+			tracing.TraceErr(span, err)
+			return false, err
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,44 @@
+package email_processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/internal/enum"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/repository"
+)
+
+func TestMatchSentCopy(t *testing.T) {
+	sent := &models.Email{ID: "email_sent", MailboxID: "mbox_1", MessageID: "<123.abc@acme.com>", Direction: enum.EmailDirectionOutbound}
+	emails := &fakeThreadingEmailRepository{emails: []*models.Email{sent}}
+	p := &emailProcessor{repositories: &repository.Repositories{EmailRepository: emails}}
+	ctx := context.Background()
+
+	copied := &models.Email{MailboxID: "mbox_1", MessageID: "123.abc@acme.com", Folder: "Sent", ImapUID: 7}
+	matched, err := p.MatchSentCopy(ctx, copied)
+	require.NoError(t, err)
+	assert.True(t, matched)
+	assert.Equal(t, []string{"email_sent"}, emails.updated)
+	assert.Equal(t, "Sent", sent.Folder)
+	assert.Equal(t, uint32(7), sent.ImapUID)
+
+	// another copy, e.g. sent to the mailbox itself, is skipped as well and changes nothing
+	matched, err = p.MatchSentCopy(ctx, &models.Email{MailboxID: "mbox_1", MessageID: "123.abc@acme.com", Folder: "INBOX", ImapUID: 9})
+	require.NoError(t, err)
+	assert.True(t, matched)
+	assert.Len(t, emails.updated, 1)
+
+	for _, email := range []*models.Email{
+		{MailboxID: "mbox_2", MessageID: "123.abc@acme.com", Folder: "INBOX", ImapUID: 3},
+		{MailboxID: "mbox_1", MessageID: "other@acme.com", Folder: "INBOX", ImapUID: 4},
+		{MailboxID: "mbox_1", Folder: "INBOX", ImapUID: 5},
+	} {
+		matched, err = p.MatchSentCopy(ctx, email)
+		require.NoError(t, err)
+		assert.False(t, matched, email.MessageID)
+	}
+}
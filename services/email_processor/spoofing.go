@@ -0,0 +1,232 @@
+package email_processor
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/customeros/mailstack/internal/config"
+	"github.com/customeros/mailstack/internal/enum"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+const (
+	spoofRuleBrandDisplayName = "brand_display_name"
+	spoofRuleReplyToDMARCFail = "reply_to_dmarc_fail"
+	spoofRuleLookalikeDomain  = "lookalike_domain"
+)
+
+// defaultSpoofedBrands are brands commonly impersonated in phishing, with the domains they send from
+var defaultSpoofedBrands = map[string][]string{
+	"amazon":          {"amazon.com", "amazonses.com", "amazonaws.com", "amazon.co.uk", "amazon.de"},
+	"apple":           {"apple.com", "icloud.com"},
+	"bank of america": {"bankofamerica.com", "bofa.com"},
+	"chase bank":      {"chase.com", "jpmorgan.com"},
+	"dhl":             {"dhl.com", "dhl.de"},
+	"docusign":        {"docusign.com", "docusign.net"},
+	"dropbox":         {"dropbox.com", "dropboxmail.com"},
+	"fedex":           {"fedex.com"},
+	"google":          {"google.com", "gmail.com", "googlemail.com"},
+	"linkedin":        {"linkedin.com"},
+	"microsoft":       {"microsoft.com", "office.com", "outlook.com", "microsoftonline.com", "live.com"},
+	"netflix":         {"netflix.com"},
+	"office 365":      {"microsoft.com", "office.com", "office365.com", "microsoftonline.com"},
+	"paypal":          {"paypal.com", "paypal.me"},
+	"stripe":          {"stripe.com"},
+	"wells fargo":     {"wellsfargo.com"},
+}
+
+// confusables maps letters of other scripts to the latin letter they are mistaken for
+var confusables = map[rune]rune{
+	'а': 'a', 'е': 'e', 'о': 'o', 'р': 'p', 'с': 'c', 'у': 'y', 'х': 'x', 'і': 'i', 'ј': 'j',
+	'ѕ': 's', 'ԁ': 'd', 'ӏ': 'l', 'һ': 'h', 'ԛ': 'q', 'ԝ': 'w', 'к': 'k', 'м': 'm', 'ɡ': 'g', 'ı': 'i',
+	'α': 'a', 'ο': 'o', 'ν': 'v', 'ι': 'i', 'κ': 'k', 'ρ': 'p', 'τ': 't', 'υ': 'u',
+}
+
+// asciiConfusables folds the latin sequences that read alike, both sides of a comparison are folded
+var asciiConfusables = strings.NewReplacer("rn", "m", "vv", "w", "0", "o", "1", "l", "i", "l", "|", "l")
+
+// spoofingRules flag inbound email that likely impersonates a brand or a domain the recipient
+// trusts. Each rule can be turned off in the config.
+type spoofingRules struct {
+	disabled map[string]bool
+	brands   []spoofedBrand
+}
+
+type spoofedBrand struct {
+	name    string
+	pattern *regexp.Regexp // whole words of the skeleton of the name
+	domains []string
+}
+
+func newSpoofingRules(cfg *config.SpoofingConfig) *spoofingRules {
+	brands := defaultSpoofedBrands
+	rules := &spoofingRules{disabled: map[string]bool{}}
+	if cfg != nil {
+		for _, rule := range cfg.DisabledRules {
+			rules.disabled[strings.ToLower(strings.TrimSpace(rule))] = true
+		}
+		if configured := parseSpoofedBrands(cfg.Brands); len(configured) > 0 {
+			brands = configured
+		}
+	}
+
+	names := make([]string, 0, len(brands))
+	for name := range brands {
+		names = append(names, name)
+	}
+	// sorted so the same email always gives the same reason
+	sort.Strings(names)
+	for _, name := range names {
+		rules.brands = append(rules.brands, spoofedBrand{
+			name:    name,
+			pattern: regexp.MustCompile(`\b` + regexp.QuoteMeta(skeleton(name)) + `\b`),
+			domains: brands[name],
+		})
+	}
+	return rules
+}
+
+// parseSpoofedBrands reads "name=domain|domain" entries, skipping malformed ones
+func parseSpoofedBrands(entries []string) map[string][]string {
+	brands := map[string][]string{}
+	for _, entry := range entries {
+		name, domains, ok := strings.Cut(entry, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !ok || name == "" {
+			continue
+		}
+		for _, domain := range strings.Split(domains, "|") {
+			if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+				brands[name] = append(brands[name], domain)
+			}
+		}
+	}
+	return brands
+}
+
+// match returns whether the email looks spoofed and the reason
+func (r *spoofingRules) match(email *models.Email) (bool, string) {
+	if r == nil {
+		return false, ""
+	}
+	fromDomain := strings.ToLower(utils.ExtractDomainFromEmail(email.FromAddress))
+	if fromDomain == "" {
+		return false, ""
+	}
+	replyToDomain := strings.ToLower(utils.ExtractDomainFromEmail(email.ReplyTo))
+
+	if !r.disabled[spoofRuleBrandDisplayName] && email.FromName != "" {
+		displayName := skeleton(email.FromName)
+		for _, brand := range r.brands {
+			if brand.pattern.MatchString(displayName) && !inDomains(fromDomain, brand.domains) {
+				return true, fmt.Sprintf("Display name '%s' mentions %s but the sender domain is %s", email.FromName, brand.name, fromDomain)
+			}
+		}
+	}
+
+	if !r.disabled[spoofRuleReplyToDMARCFail] && replyToDomain != "" && email.AuthResults != nil &&
+		email.AuthResults.DMARC.Result == enum.AuthResultFail && registrableDomain(replyToDomain) != registrableDomain(fromDomain) {
+		return true, fmt.Sprintf("Reply-To domain %s differs from sender domain %s, which fails DMARC", replyToDomain, fromDomain)
+	}
+
+	if !r.disabled[spoofRuleLookalikeDomain] {
+		protected := r.protectedDomains(email)
+		for _, domain := range []string{fromDomain, replyToDomain} {
+			if lookalike := lookalikeOf(domain, protected); lookalike != "" {
+				return true, fmt.Sprintf("Domain %s looks like %s", domain, lookalike)
+			}
+		}
+	}
+
+	return false, ""
+}
+
+// protectedDomains are the registrable domains of the brands and of the recipients, whose
+// lookalikes are used to impersonate colleagues
+func (r *spoofingRules) protectedDomains(email *models.Email) []string {
+	seen := map[string]bool{}
+	var domains []string
+	add := func(domain string) {
+		if domain = registrableDomain(domain); domain != "" && !seen[domain] {
+			seen[domain] = true
+			domains = append(domains, domain)
+		}
+	}
+	for _, brand := range r.brands {
+		for _, domain := range brand.domains {
+			add(domain)
+		}
+	}
+	for _, recipient := range append(append([]string{}, email.ToAddresses...), email.CcAddresses...) {
+		add(utils.ExtractDomainFromEmail(recipient))
+	}
+	return domains
+}
+
+// lookalikeOf returns the protected domain that domain differs from but reads the same as
+func lookalikeOf(domain string, protected []string) string {
+	registrable := registrableDomain(domain)
+	if registrable == "" {
+		return ""
+	}
+	folded := skeleton(registrable)
+	for _, candidate := range protected {
+		if candidate != registrable && skeleton(candidate) == folded {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// skeleton lowercases s, decodes punycode, drops diacritics and folds confusable letters, so
+// strings that read alike get the same skeleton
+func skeleton(s string) string {
+	s = strings.ToLower(s)
+	if strings.Contains(s, "xn--") {
+		if decoded, err := idna.ToUnicode(s); err == nil {
+			s = decoded
+		}
+	}
+
+	var folded strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		if latin, ok := confusables[r]; ok {
+			r = latin
+		}
+		folded.WriteRune(r)
+	}
+	return asciiConfusables.Replace(folded.String())
+}
+
+func registrableDomain(domain string) string {
+	domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+	if domain == "" {
+		return ""
+	}
+	registrable, err := publicsuffix.EffectiveTLDPlusOne(domain)
+	if err != nil {
+		return domain
+	}
+	return registrable
+}
+
+// inDomains tells whether domain is one of domains or a subdomain of one
+func inDomains(domain string, domains []string) bool {
+	for _, candidate := range domains {
+		if domain == candidate || strings.HasSuffix(domain, "."+candidate) {
+			return true
+		}
+	}
+	return false
+}
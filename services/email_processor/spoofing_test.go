@@ -0,0 +1,158 @@
+package email_processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/config"
+	"github.com/customeros/mailstack/internal/enum"
+	"github.com/customeros/mailstack/internal/models"
+)
+
+func TestSpoofingRules(t *testing.T) {
+	rules := newSpoofingRules(nil)
+	dmarcFail := &models.MailAuthResults{DMARC: models.DMARCResult{Result: enum.AuthResultFail}}
+
+	for _, tc := range []struct {
+		name       string
+		email      *models.Email
+		suspicious bool
+		reason     string
+	}{
+		{
+			name:       "brand in display name from another domain",
+			email:      &models.Email{FromName: "PayPal Security", FromAddress: "alerts@secure-login.io"},
+			suspicious: true,
+			reason:     "Display name 'PayPal Security' mentions paypal but the sender domain is secure-login.io",
+		},
+		{
+			name:       "brand with homoglyphs in display name",
+			email:      &models.Email{FromName: "Міcrоsоft Account Team", FromAddress: "noreply@account-verify.net"},
+			suspicious: true,
+			reason:     "Display name 'Міcrоsоft Account Team' mentions microsoft but the sender domain is account-verify.net",
+		},
+		{
+			name:  "brand from its own subdomain",
+			email: &models.Email{FromName: "PayPal", FromAddress: "service@mail.paypal.com"},
+		},
+		{
+			name:  "brand as part of a word",
+			email: &models.Email{FromName: "Applegate Consulting", FromAddress: "jane@applegate.io"},
+		},
+		{
+			name:       "reply-to elsewhere and dmarc fails",
+			email:      &models.Email{FromAddress: "ceo@acme.io", ReplyTo: "ceo.acme@freemail.io", AuthResults: dmarcFail},
+			suspicious: true,
+			reason:     "Reply-To domain freemail.io differs from sender domain acme.io, which fails DMARC",
+		},
+		{
+			name:  "reply-to elsewhere and dmarc not verified",
+			email: &models.Email{FromAddress: "news@acme.io", ReplyTo: "support@helpdesk.io"},
+		},
+		{
+			name:  "reply-to in a subdomain and dmarc fails",
+			email: &models.Email{FromAddress: "news@acme.io", ReplyTo: "support@help.acme.io", AuthResults: dmarcFail},
+		},
+		{
+			name:       "digit lookalike of a brand",
+			email:      &models.Email{FromAddress: "billing@paypa1.com"},
+			suspicious: true,
+			reason:     "Domain paypa1.com looks like paypal.com",
+		},
+		{
+			name:       "punycode homoglyph of a brand",
+			email:      &models.Email{FromAddress: "billing@xn--pypal-4ve.com"},
+			suspicious: true,
+			reason:     "Domain xn--pypal-4ve.com looks like paypal.com",
+		},
+		{
+			name:       "lookalike of the recipient domain",
+			email:      &models.Email{FromAddress: "ceo@rnailstack.io", ToAddresses: pq.StringArray{"bob@mailstack.io"}},
+			suspicious: true,
+			reason:     "Domain rnailstack.io looks like mailstack.io",
+		},
+		{
+			name:       "lookalike reply-to",
+			email:      &models.Email{FromAddress: "ceo@mailstack.io", ReplyTo: "ceo@mai1stack.io", CcAddresses: pq.StringArray{"bob@mailstack.io"}},
+			suspicious: true,
+			reason:     "Domain mai1stack.io looks like mailstack.io",
+		},
+		{
+			name:  "colleague",
+			email: &models.Email{FromAddress: "ceo@mailstack.io", ToAddresses: pq.StringArray{"bob@mailstack.io"}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			suspicious, reason := rules.match(tc.email)
+			assert.Equal(t, tc.suspicious, suspicious)
+			assert.Equal(t, tc.reason, reason)
+		})
+	}
+}
+
+func TestSpoofingRulesConfig(t *testing.T) {
+	email := &models.Email{FromName: "Acme Billing", FromAddress: "billing@acrne.io"}
+
+	rules := newSpoofingRules(&config.SpoofingConfig{Brands: []string{"acme=acme.io|acme.com", "broken"}})
+	suspicious, reason := rules.match(email)
+	assert.True(t, suspicious)
+	assert.Equal(t, "Display name 'Acme Billing' mentions acme but the sender domain is acrne.io", reason)
+
+	rules = newSpoofingRules(&config.SpoofingConfig{
+		Brands:        []string{"acme=acme.io"},
+		DisabledRules: []string{"brand_display_name"},
+	})
+	suspicious, reason = rules.match(email)
+	assert.True(t, suspicious)
+	assert.Equal(t, "Domain acrne.io looks like acme.io", reason)
+
+	rules = newSpoofingRules(&config.SpoofingConfig{
+		Brands:        []string{"acme=acme.io"},
+		DisabledRules: []string{"brand_display_name", " Lookalike_Domain "},
+	})
+	suspicious, _ = rules.match(email)
+	assert.False(t, suspicious)
+}
+
+type fakeSpamScorer struct {
+	spam bool
+}
+
+func (s *fakeSpamScorer) Score(_ context.Context, _ []byte) (*interfaces.SpamScore, error) {
+	return &interfaces.SpamScore{Score: 7.5, Threshold: 5, Spam: s.spam}, nil
+}
+
+func TestEmailFilterChecksInvitesForSpoofingAndSpam(t *testing.T) {
+	invite := func(fromName, fromAddress string) *models.Email {
+		return &models.Email{
+			FromName:      fromName,
+			FromAddress:   fromAddress,
+			ToAddresses:   pq.StringArray{"jane@acme.com"},
+			CalendarEvent: &models.CalendarEvent{Method: "REQUEST", Summary: "Account review"},
+		}
+	}
+	raw := []byte("Subject: Account review\r\n\r\nSee invite\r\n")
+
+	for _, tc := range []struct {
+		name           string
+		email          *models.Email
+		spam           bool
+		classification enum.EmailClassification
+	}{
+		{"spoofed invite", invite("PayPal Security", "alerts@secure-login.io"), false, enum.EmailSuspicious},
+		{"spam invite", invite("Sales", "sales@vendor.io"), true, enum.EmailSpam},
+		{"invite", invite("Joe", "joe@beta.com"), false, enum.EmailCalendarInvite},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &emailProcessor{spoofing: newSpoofingRules(nil), spamScorer: &fakeSpamScorer{spam: tc.spam}}
+
+			require.NoError(t, p.EmailFilter(context.Background(), tc.email, raw))
+			assert.Equal(t, tc.classification, tc.email.Classification)
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultDedupWindow is how long a processed message id is remembered by a subscriber
+const DefaultDedupWindow = 10 * time.Minute
+
+// processedMessages remembers the ids of messages handled successfully per queue, so
+// redelivered duplicates within the window are acknowledged without processing them again.
+// An id is reserved before its message is handled, which keeps concurrent workers from
+// handling the same message twice. Failed messages release their id and can be replayed
+// from the DLQ.
+type processedMessages struct {
+	mu         sync.Mutex
+	window     time.Duration
+	seen       map[string]time.Time // processed ids with the time they completed
+	inFlight   map[string]struct{}  // reserved ids still being handled
+	lastPruned time.Time
+	now        func() time.Time
+}
+
+func newProcessedMessages(window time.Duration) *processedMessages {
+	return &processedMessages{
+		window:   window,
+		seen:     map[string]time.Time{},
+		inFlight: map[string]struct{}{},
+		now:      time.Now,
+	}
+}
+
+func (p *processedMessages) key(queue, messageId string) string {
+	return queue + "/" + messageId
+}
+
+func (p *processedMessages) enabled(messageId string) bool {
+	return p != nil && p.window > 0 && messageId != ""
+}
+
+// reserve claims a message id for handling. It returns false when the message was processed
+// within the window or is being handled by another worker. Messages without an id are never
+// deduplicated.
+func (p *processedMessages) reserve(queue, messageId string) bool {
+	if !p.enabled(messageId) {
+		return true
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := p.key(queue, messageId)
+	if _, busy := p.inFlight[key]; busy {
+		return false
+	}
+	if processedAt, ok := p.seen[key]; ok && p.now().Sub(processedAt) < p.window {
+		return false
+	}
+	p.inFlight[key] = struct{}{}
+	return true
+}
+
+// release gives up the reservation of a message that failed, so a redelivery is handled again
+func (p *processedMessages) release(queue, messageId string) {
+	if !p.enabled(messageId) {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.inFlight, p.key(queue, messageId))
+}
+
+// complete records a reserved message as processed
+func (p *processedMessages) complete(queue, messageId string) {
+	if !p.enabled(messageId) {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	key := p.key(queue, messageId)
+	delete(p.inFlight, key)
+	p.seen[key] = now
+
+	if now.Sub(p.lastPruned) < p.window {
+		return
+	}
+	for key, processedAt := range p.seen {
+		if now.Sub(processedAt) >= p.window {
+			delete(p.seen, key)
+		}
+	}
+	p.lastPruned = now
+}
@@ -0,0 +1,38 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessedMessages(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	processed := newProcessedMessages(time.Minute)
+	processed.now = func() time.Time { return now }
+
+	assert.True(t, processed.reserve(QueueReceiveEmail, "event_1"))
+	// in flight on another worker
+	assert.False(t, processed.reserve(QueueReceiveEmail, "event_1"))
+	processed.complete(QueueReceiveEmail, "event_1")
+	assert.False(t, processed.reserve(QueueReceiveEmail, "event_1"))
+
+	// ids are tracked per queue, fanout delivers the same message to several queues
+	assert.True(t, processed.reserve(QueueMailstack, "event_1"))
+
+	// a failed message is handled again when redelivered
+	assert.True(t, processed.reserve(QueueReceiveEmail, "event_2"))
+	processed.release(QueueReceiveEmail, "event_2")
+	assert.True(t, processed.reserve(QueueReceiveEmail, "event_2"))
+
+	// messages without an id are never deduplicated
+	assert.True(t, processed.reserve(QueueReceiveEmail, ""))
+	processed.complete(QueueReceiveEmail, "")
+	assert.True(t, processed.reserve(QueueReceiveEmail, ""))
+
+	now = now.Add(2 * time.Minute)
+	assert.True(t, processed.reserve(QueueReceiveEmail, "event_1"))
+	processed.complete(QueueReceiveEmail, "event_3")
+	assert.Len(t, processed.seen, 1)
+}
@@ -0,0 +1,221 @@
+package events
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/opentracing/opentracing-go"
+	tracingLog "github.com/opentracing/opentracing-go/log"
+	"github.com/pkg/errors"
+	"github.com/rabbitmq/amqp091-go"
+
+	"github.com/customeros/mailstack/internal/tracing"
+)
+
+const (
+	// HeaderReplayCount counts how often a dead lettered message was replayed
+	HeaderReplayCount = "x-replay-count"
+
+	DefaultMaxReplays = 3
+)
+
+var deadLetterQueues = map[string]bool{
+	DLQMailstack:     true,
+	DLQNotifications: true,
+	DLQSendEmail:     true,
+	DLQReceiveEmail:  true,
+	DLQEnrichEmail:   true,
+}
+
+type DLQReplayResult struct {
+	Queue    string `json:"queue"`
+	DryRun   bool   `json:"dryRun"`
+	Pending  int    `json:"pending"`
+	Replayed int    `json:"replayed"`
+	Parked   int    `json:"parked"` // over the replay limit, moved to the back of the DLQ
+}
+
+// ReplayDLQ re-publishes up to limit messages of a dead letter queue to the exchange and routing
+// key they were dead lettered from, limit <= 0 replays all. Body and headers are kept, so the
+// trace id in the event metadata is preserved. Messages replayed maxReplays times already are
+// parked at the back of the DLQ. A dry run only returns the number of pending messages.
+func (r *RabbitMQPublisher) ReplayDLQ(ctx context.Context, queue string, limit int, dryRun bool) (DLQReplayResult, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "RabbitMQPublisher.ReplayDLQ")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.LogKV("queue", queue, "limit", limit, "dryRun", dryRun)
+
+	result := DLQReplayResult{Queue: queue, DryRun: dryRun}
+
+	if !deadLetterQueues[queue] {
+		err := fmt.Errorf("unknown dead letter queue %s", queue)
+		tracing.TraceErr(span, err)
+		return result, err
+	}
+
+	r.connectionMutex.Lock()
+	connection := r.connection
+	r.connectionMutex.Unlock()
+	if connection == nil || connection.IsClosed() {
+		err := errors.New("RabbitMQ connection is not available")
+		tracing.TraceErr(span, err)
+		return result, err
+	}
+
+	channel, err := connection.Channel()
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "Failed to open replay channel"))
+		return result, err
+	}
+	defer channel.Close()
+
+	state, err := channel.QueueDeclarePassive(queue, true, false, false, false, nil)
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrapf(err, "Failed to inspect queue %s", queue))
+		return result, err
+	}
+	result.Pending = state.Messages
+	if dryRun || state.Messages == 0 {
+		return result, nil
+	}
+
+	if err = channel.Confirm(false); err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "Failed to enable publisher confirms"))
+		return result, err
+	}
+	confirms := channel.NotifyPublish(make(chan amqp091.Confirmation, 1))
+	returns := channel.NotifyReturn(make(chan amqp091.Return, 1))
+
+	maxReplays := r.config.MaxReplays
+	if maxReplays <= 0 {
+		maxReplays = DefaultMaxReplays
+	}
+
+	// bounded by the initial count, parked messages are appended to the same queue
+	todo := state.Messages
+	if limit > 0 && limit < todo {
+		todo = limit
+	}
+	for i := 0; i < todo; i++ {
+		delivery, ok, err := channel.Get(queue, false)
+		if err != nil {
+			tracing.TraceErr(span, errors.Wrapf(err, "Failed to get message from %s", queue))
+			return result, err
+		}
+		if !ok {
+			break
+		}
+
+		exchange, routingKey, found := deadLetterOrigin(delivery.Headers)
+		replays := replayCount(delivery.Headers)
+		parked := !found || replays >= maxReplays
+		if parked {
+			// the default exchange routes to the queue named by the routing key
+			exchange, routingKey = "", queue
+		} else {
+			replays++
+		}
+
+		err = r.republish(ctx, channel, confirms, returns, delivery, exchange, routingKey, replays)
+		if err != nil {
+			_ = delivery.Nack(false, true)
+			tracing.TraceErr(span, err)
+			return result, err
+		}
+		if err = delivery.Ack(false); err != nil {
+			tracing.TraceErr(span, errors.Wrap(err, "Failed to ack replayed message"))
+			return result, err
+		}
+
+		if parked {
+			result.Parked++
+		} else {
+			result.Replayed++
+		}
+	}
+
+	span.LogFields(tracingLog.Int("result.replayed", result.Replayed), tracingLog.Int("result.parked", result.Parked))
+	return result, nil
+}
+
+func (r *RabbitMQPublisher) republish(ctx context.Context, channel *amqp091.Channel, confirms <-chan amqp091.Confirmation, returns <-chan amqp091.Return,
+	delivery amqp091.Delivery, exchange, routingKey string, replays int) error {
+	headers := amqp091.Table{}
+	for key, value := range delivery.Headers {
+		headers[key] = value
+	}
+	headers[HeaderReplayCount] = int32(replays)
+
+	err := channel.PublishWithContext(ctx,
+		exchange,
+		routingKey,
+		true,  // mandatory
+		false, // immediate
+		amqp091.Publishing{
+			Headers:       headers,
+			DeliveryMode:  amqp091.Persistent,
+			ContentType:   delivery.ContentType,
+			CorrelationId: delivery.CorrelationId,
+			MessageId:     delivery.MessageId,
+			Timestamp:     delivery.Timestamp,
+			Type:          delivery.Type,
+			Body:          delivery.Body,
+		})
+	if err != nil {
+		return errors.Wrap(err, "Failed to republish message")
+	}
+
+	select {
+	case confirm := <-confirms:
+		if !confirm.Ack {
+			return errors.New("Republished message was not confirmed by server")
+		}
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	// a return is delivered before the confirm of the same message
+	select {
+	case returned := <-returns:
+		return fmt.Errorf("republished message was returned: %s", returned.ReplyText)
+	default:
+	}
+
+	return nil
+}
+
+// deadLetterOrigin returns the exchange and routing key a message had before it was dead
+// lettered, taken from the most recent x-death entry
+func deadLetterOrigin(headers amqp091.Table) (string, string, bool) {
+	deaths, ok := headers["x-death"].([]interface{})
+	if !ok || len(deaths) == 0 {
+		return "", "", false
+	}
+	death, ok := deaths[0].(amqp091.Table)
+	if !ok {
+		return "", "", false
+	}
+
+	exchange, ok := death["exchange"].(string)
+	if !ok {
+		return "", "", false
+	}
+	routingKey := ""
+	if keys, ok := death["routing-keys"].([]interface{}); ok && len(keys) > 0 {
+		routingKey, _ = keys[0].(string)
+	}
+	return exchange, routingKey, true
+}
+
+func replayCount(headers amqp091.Table) int {
+	switch count := headers[HeaderReplayCount].(type) {
+	case int32:
+		return int(count)
+	case int64:
+		return int(count)
+	case int:
+		return count
+	default:
+		return 0
+	}
+}
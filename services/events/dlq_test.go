@@ -0,0 +1,42 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeadLetterOrigin(t *testing.T) {
+	headers := amqp091.Table{
+		"x-death": []interface{}{
+			amqp091.Table{
+				"queue":        QueueReceiveEmail,
+				"reason":       "rejected",
+				"exchange":     ExchangeMailstackDirect,
+				"routing-keys": []interface{}{RoutingKeyReceiveEmail},
+				"count":        int64(1),
+			},
+		},
+	}
+	exchange, routingKey, ok := deadLetterOrigin(headers)
+	assert.True(t, ok)
+	assert.Equal(t, ExchangeMailstackDirect, exchange)
+	assert.Equal(t, RoutingKeyReceiveEmail, routingKey)
+
+	// fanout exchanges route with an empty key
+	headers = amqp091.Table{"x-death": []interface{}{amqp091.Table{"exchange": ExchangeCustomerOS, "routing-keys": []interface{}{""}}}}
+	exchange, routingKey, ok = deadLetterOrigin(headers)
+	assert.True(t, ok)
+	assert.Equal(t, ExchangeCustomerOS, exchange)
+	assert.Equal(t, "", routingKey)
+
+	_, _, ok = deadLetterOrigin(amqp091.Table{})
+	assert.False(t, ok)
+}
+
+func TestReplayCount(t *testing.T) {
+	assert.Equal(t, 0, replayCount(amqp091.Table{}))
+	assert.Equal(t, 2, replayCount(amqp091.Table{HeaderReplayCount: int32(2)}))
+	assert.Equal(t, 3, replayCount(amqp091.Table{HeaderReplayCount: int64(3)}))
+}
@@ -0,0 +1,57 @@
+package events
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/customeros/mailstack/internal/metrics"
+)
+
+// dlqCollector reports the depth of the dead letter queues, asked from RabbitMQ on every scrape
+// so the value is current and the same on every pod
+type dlqCollector struct {
+	publisher *RabbitMQPublisher
+}
+
+// NewDLQCollector returns a Prometheus collector of the dead letter queue depths
+func NewDLQCollector(publisher *RabbitMQPublisher) prometheus.Collector {
+	return &dlqCollector{publisher: publisher}
+}
+
+func (c *dlqCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- metrics.DLQDepthDesc
+}
+
+func (c *dlqCollector) Collect(ch chan<- prometheus.Metric) {
+	for queue, depth := range c.publisher.dlqDepths() {
+		ch <- prometheus.MustNewConstMetric(metrics.DLQDepthDesc, prometheus.GaugeValue, float64(depth), queue)
+	}
+}
+
+// dlqDepths returns the messages waiting in each dead letter queue. Queues that can not be
+// inspected, e.g. while RabbitMQ is unreachable, are left out rather than reported empty.
+func (r *RabbitMQPublisher) dlqDepths() map[string]int {
+	r.connectionMutex.Lock()
+	connection := r.connection
+	r.connectionMutex.Unlock()
+	if connection == nil || connection.IsClosed() {
+		return nil
+	}
+
+	depths := make(map[string]int, len(deadLetterQueues))
+	for queue := range deadLetterQueues {
+		// a failed passive declare closes the channel, every queue gets its own
+		channel, err := connection.Channel()
+		if err != nil {
+			r.logger.Warnf("Failed to open channel to inspect %s: %v", queue, err)
+			return depths
+		}
+		state, err := channel.QueueDeclarePassive(queue, true, false, false, false, nil)
+		_ = channel.Close()
+		if err != nil {
+			r.logger.Warnf("Failed to inspect queue %s: %v", queue, err)
+			continue
+		}
+		depths[queue] = state.Messages
+	}
+	return depths
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/customeros/mailstack/dto"
 	"github.com/customeros/mailstack/internal/enum"
 	"github.com/customeros/mailstack/internal/logger"
+	"github.com/customeros/mailstack/internal/metrics"
 	"github.com/customeros/mailstack/internal/models"
 	"github.com/customeros/mailstack/internal/tracing"
 	"github.com/customeros/mailstack/internal/utils"
@@ -32,15 +33,18 @@ const (
 	QueueMailstack     = "events-mailstack"
 	QueueSendEmail     = "send-email"
 	QueueReceiveEmail  = "receive-email"
+	QueueEnrichEmail   = "enrich-email"
 	DLQMailstack       = QueueMailstack + "-dlq"
 	DLQNotifications   = QueueNotifications + "-dlq"
 	DLQSendEmail       = QueueSendEmail + "-dlq"
 	DLQReceiveEmail    = QueueReceiveEmail + "-dlq"
+	DLQEnrichEmail     = QueueEnrichEmail + "-dlq"
 
 	// routing keys
 	RoutingKeyDeadLetter   = "dead-letter"
 	RoutingKeySendEmail    = "mailstack-send-email"
 	RoutingKeyReceiveEmail = "mailstack-receive-email"
+	RoutingKeyEnrichEmail  = "mailstack-enrich-email"
 
 	// Default configurations
 	DefaultMessageTTL          = 240 * time.Hour // after TTL message moves to DLQ
@@ -50,12 +54,16 @@ const (
 	DefaultMaxReconnectBackoff = 30 * time.Second
 )
 
+// ErrMessageReturned is returned when the broker could not route a mandatory message to any queue
+var ErrMessageReturned = errors.New("message returned as unroutable")
+
 type PublisherConfig struct {
 	MessageTTL          time.Duration
 	MaxRetries          int
 	PublishTimeout      time.Duration
 	ReconnectBackoff    time.Duration
 	MaxReconnectBackoff time.Duration
+	MaxReplays          int // replays of a dead lettered message before it is parked
 }
 
 type RabbitMQPublisher struct {
@@ -66,6 +74,7 @@ type RabbitMQPublisher struct {
 	url             string
 	logger          logger.Logger
 	confirms        chan amqp091.Confirmation
+	returns         chan amqp091.Return
 	config          PublisherConfig
 }
 
@@ -77,6 +86,7 @@ func NewRabbitMQPublisher(rabbitmqURL string, logger logger.Logger, config *Publ
 			PublishTimeout:      DefaultPublishTimeout,
 			ReconnectBackoff:    DefaultReconnectBackoff,
 			MaxReconnectBackoff: DefaultMaxReconnectBackoff,
+			MaxReplays:          DefaultMaxReplays,
 		}
 	}
 
@@ -97,15 +107,21 @@ func NewRabbitMQPublisher(rabbitmqURL string, logger logger.Logger, config *Publ
 func (r *RabbitMQPublisher) PublishRecieveEmailEvent(ctx context.Context, message dto.EmailReceived) error {
 	switch message.Source {
 	case enum.EmailImportIMAP:
-		id := fmt.Sprintf("%s-%s-%s", message.MailboxID, message.Folder, message.ImapUID)
-		return r.publishEventOnExchange(ctx, id, enum.EMAIL, message, ExchangeMailstackDirect, RoutingKeyReceiveEmail)
+		id := fmt.Sprintf("%s-%s-%d", message.MailboxID, message.Folder, message.ImapUID)
+		// a message found again by a later sync is processed once
+		return r.publishEventOnExchange(ctx, id, id, enum.EMAIL, message, ExchangeMailstackDirect, RoutingKeyReceiveEmail)
 	default:
 		return errors.New("not implemented yet")
 	}
 }
 
 func (r *RabbitMQPublisher) PublishSendEmailEvent(ctx context.Context, email *models.Email) error {
-	return r.publishEventOnExchange(ctx, email.ID, enum.EMAIL, dto.SendEmail{Email: email}, ExchangeMailstackDirect, RoutingKeySendEmail)
+	return r.publishEventOnExchange(ctx, "", email.ID, enum.EMAIL, dto.SendEmail{Email: email}, ExchangeMailstackDirect, RoutingKeySendEmail)
+}
+
+// PublishEnrichEmailEvent queues the AI enrichment of a stored email
+func (r *RabbitMQPublisher) PublishEnrichEmailEvent(ctx context.Context, emailID string) error {
+	return r.publishEventOnExchange(ctx, "", emailID, enum.EMAIL, dto.EnrichEmail{EmailID: emailID}, ExchangeMailstackDirect, RoutingKeyEnrichEmail)
 }
 
 func (r *RabbitMQPublisher) PublishFanoutEvent(ctx context.Context, entityId string, entityType enum.EntityType, message interface{}) error {
@@ -113,7 +129,7 @@ func (r *RabbitMQPublisher) PublishFanoutEvent(ctx context.Context, entityId str
 	if err != nil {
 		return err
 	}
-	return r.publishEventOnExchange(ctx, entityId, entityType, message, ExchangeCustomerOS, "")
+	return r.publishEventOnExchange(ctx, "", entityId, entityType, message, ExchangeCustomerOS, "")
 }
 
 func (r *RabbitMQPublisher) PublishNotification(ctx context.Context, tenant string, entityId string, entityType enum.EntityType, details *utils.EventCompletedDetails) {
@@ -142,9 +158,10 @@ func (r *RabbitMQPublisher) PublishNotificationBulk(ctx context.Context, tenant
 		event.Create = details.Create
 		event.Update = details.Update
 		event.Delete = details.Delete
+		event.Data = details.Data
 	}
 
-	err := r.publishMessageOnExchange(ctx, event, ExchangeNotifications, "")
+	err := r.publishMessageOnExchange(ctx, event, utils.GenerateNanoIDWithPrefix("event", 21), ExchangeNotifications, "")
 	if err != nil {
 		tracing.TraceErr(span, err)
 		r.logger.Errorf("Failed to publish event completed notification: %v", err)
@@ -166,6 +183,8 @@ func (r *RabbitMQPublisher) setupPublishChannel() error {
 	}
 
 	r.confirms = channel.NotifyPublish(make(chan amqp091.Confirmation, 1))
+	// mandatory publishes without a matching binding come back here, before their confirm
+	r.returns = channel.NotifyReturn(make(chan amqp091.Return, 1))
 	r.publishChannel = channel
 	return nil
 }
@@ -354,6 +373,22 @@ func (r *RabbitMQPublisher) declareAndBindQueues(channel *amqp091.Channel) error
 		return errors.Wrapf(err, "Failed to bind queue %s to exchange %s", QueueSendEmail, ExchangeMailstackDirect)
 	}
 
+	// Mailstack EnrichEmail direct queue with DLQ
+	err = r.declareQueueWithDLQ(channel, QueueEnrichEmail, DLQEnrichEmail)
+	if err != nil {
+		return err
+	}
+	err = channel.QueueBind(
+		QueueEnrichEmail,
+		RoutingKeyEnrichEmail,
+		ExchangeMailstackDirect,
+		false,
+		nil,
+	)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to bind queue %s to exchange %s", QueueEnrichEmail, ExchangeMailstackDirect)
+	}
+
 	return nil
 }
 
@@ -445,7 +480,10 @@ func (r *RabbitMQPublisher) ensureConnectionAndChannel() error {
 	return nil
 }
 
-func (r *RabbitMQPublisher) publishEventOnExchange(ctx context.Context, entityId string, entityType enum.EntityType, message interface{}, exchange, routingKey string) error {
+// publishEventOnExchange wraps the message in an event and publishes it. Consumers drop deliveries
+// with a message id they processed already: the id is the idempotency key when the caller has one,
+// otherwise the event id, which only covers retries of this publish.
+func (r *RabbitMQPublisher) publishEventOnExchange(ctx context.Context, idempotencyKey, entityId string, entityType enum.EntityType, message interface{}, exchange, routingKey string) error {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "RabbitMQPublisher.PublishEventOnExchange")
 	defer span.Finish()
 	tracing.SetDefaultServiceSpanTags(ctx, span)
@@ -474,34 +512,58 @@ func (r *RabbitMQPublisher) publishEventOnExchange(ctx context.Context, entityId
 		},
 	}
 
-	return r.publishMessageOnExchange(ctx, eventMessage, exchange, routingKey)
+	messageId := eventMessage.Event.Id
+	if idempotencyKey != "" {
+		messageId = idempotencyKey
+	}
+
+	return r.publishMessageOnExchange(ctx, eventMessage, messageId, exchange, routingKey)
 }
 
-func (r *RabbitMQPublisher) publishMessageOnExchange(ctx context.Context, message interface{}, exchange, routingKey string) error {
+// publishMessageOnExchange publishes the message, retrying failures with the same message id
+func (r *RabbitMQPublisher) publishMessageOnExchange(ctx context.Context, message interface{}, messageId, exchange, routingKey string) error {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "RabbitMQPublisher.PublishMessageOnExchange")
 	defer span.Finish()
 	tracing.SetDefaultServiceSpanTags(ctx, span)
 
 	tracing.LogObjectAsJson(span, "message", message)
 
+	body, err := json.Marshal(message)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return errors.Wrap(err, "Failed to marshal message")
+	}
+
+	span.LogKV("messageId", messageId)
+
 	for attempt := 0; attempt < r.config.MaxRetries; attempt++ {
-		err := r.publishWithConfirm(ctx, message, exchange, routingKey)
+		err = r.publishWithConfirm(ctx, body, messageId, exchange, routingKey)
 		if err == nil {
 			return nil
 		}
 
 		r.logger.Warnf("Publish attempt %d failed: %v", attempt+1, err)
+		// a missing binding does not fix itself between attempts
+		if errors.Is(err, ErrMessageReturned) {
+			tracing.TraceErr(span, err)
+			return err
+		}
 		if attempt < r.config.MaxRetries-1 {
 			time.Sleep(time.Millisecond * 100 * time.Duration(attempt+1))
 		}
 	}
 
-	return errors.New("Failed to publish message after all retries")
+	return errors.Wrap(err, "Failed to publish message after all retries")
 }
 
-func (r *RabbitMQPublisher) publishWithConfirm(ctx context.Context, message interface{}, exchange, routingKey string) error {
+func (r *RabbitMQPublisher) publishWithConfirm(ctx context.Context, body []byte, messageId, exchange, routingKey string) (err error) {
 	r.publishMutex.Lock()
 	defer r.publishMutex.Unlock()
+	defer func() {
+		if err != nil {
+			metrics.QueuePublishFailures.WithLabelValues(exchange).Inc()
+		}
+	}()
 
 	// Check context cancellation
 	select {
@@ -515,16 +577,14 @@ func (r *RabbitMQPublisher) publishWithConfirm(ctx context.Context, message inte
 		return err
 	}
 
-	jsonBody, err := json.Marshal(message)
-	if err != nil {
-		return errors.Wrap(err, "Failed to marshal message")
-	}
-
 	actualRoutingKey := routingKey
 	if exchange == ExchangeCustomerOS || exchange == ExchangeNotifications {
 		actualRoutingKey = ""
 	}
 
+	// drop returns left over from a publish that timed out
+	drainReturns(r.returns)
+
 	err = r.publishChannel.Publish(
 		exchange,
 		actualRoutingKey,
@@ -533,28 +593,51 @@ func (r *RabbitMQPublisher) publishWithConfirm(ctx context.Context, message inte
 		amqp091.Publishing{
 			DeliveryMode: amqp091.Persistent,
 			ContentType:  "application/json",
-			Body:         jsonBody,
+			MessageId:    messageId,
+			Body:         body,
 			Timestamp:    time.Now(),
 		})
 	if err != nil {
 		return errors.Wrap(err, "Failed to publish message")
 	}
 
-	// Wait for confirmation with timeout
+	return awaitConfirm(ctx, r.confirms, r.returns, r.config.PublishTimeout)
+}
+
+// awaitConfirm waits for the broker confirm of the last publish. The broker acks unroutable
+// mandatory messages too, after returning them, so a pending return fails the publish.
+func awaitConfirm(ctx context.Context, confirms <-chan amqp091.Confirmation, returns <-chan amqp091.Return, timeout time.Duration) error {
 	select {
-	case confirm := <-r.confirms:
+	case confirm := <-confirms:
 		if !confirm.Ack {
 			return errors.New("Message was not confirmed by server")
 		}
-	case <-time.After(r.config.PublishTimeout):
+	case <-time.After(timeout):
 		return errors.New("Publish confirmation timeout")
 	case <-ctx.Done():
 		return ctx.Err()
 	}
 
+	select {
+	case returned := <-returns:
+		return errors.Wrapf(ErrMessageReturned, "exchange %s, routing key %q: %d %s",
+			returned.Exchange, returned.RoutingKey, returned.ReplyCode, returned.ReplyText)
+	default:
+	}
+
 	return nil
 }
 
+func drainReturns(returns <-chan amqp091.Return) {
+	for {
+		select {
+		case <-returns:
+		default:
+			return
+		}
+	}
+}
+
 // Close gracefully shuts down the publisher
 func (r *RabbitMQPublisher) Close() error {
 	r.connectionMutex.Lock()
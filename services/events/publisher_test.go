@@ -0,0 +1,61 @@
+package events
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/internal/logger"
+)
+
+func TestAwaitConfirm(t *testing.T) {
+	ctx := context.Background()
+
+	confirms := make(chan amqp091.Confirmation, 1)
+	returns := make(chan amqp091.Return, 1)
+	confirms <- amqp091.Confirmation{Ack: true}
+	assert.NoError(t, awaitConfirm(ctx, confirms, returns, time.Second))
+
+	// unroutable: returned first, then acked
+	returns <- amqp091.Return{Exchange: "unbound", RoutingKey: "key", ReplyCode: 312, ReplyText: "NO_ROUTE"}
+	confirms <- amqp091.Confirmation{Ack: true}
+	err := awaitConfirm(ctx, confirms, returns, time.Second)
+	assert.True(t, errors.Is(err, ErrMessageReturned))
+
+	confirms <- amqp091.Confirmation{Ack: false}
+	assert.Error(t, awaitConfirm(ctx, confirms, returns, time.Second))
+
+	assert.Error(t, awaitConfirm(ctx, confirms, returns, 10*time.Millisecond))
+}
+
+// TestPublishToExchangeWithoutQueue needs a broker, set RABBITMQ_URL to run it
+func TestPublishToExchangeWithoutQueue(t *testing.T) {
+	url := os.Getenv("RABBITMQ_URL")
+	if url == "" {
+		t.Skip("RABBITMQ_URL not set")
+	}
+
+	log := logger.NewAppLogger(&logger.Config{DevMode: true})
+	log.InitLogger()
+
+	publisher, err := NewRabbitMQPublisher(url, log, nil)
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	channel, err := publisher.connection.Channel()
+	require.NoError(t, err)
+	defer channel.Close()
+
+	exchange := "test-unbound-" + time.Now().Format("20060102150405.000000")
+	require.NoError(t, channel.ExchangeDeclare(exchange, "direct", false, true, false, false, nil))
+	defer channel.ExchangeDelete(exchange, false, false)
+
+	err = publisher.publishMessageOnExchange(context.Background(), map[string]string{"test": "unroutable"}, "event_unroutable", exchange, "no-binding")
+	assert.True(t, errors.Is(err, ErrMessageReturned), "got %v", err)
+}
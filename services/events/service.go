@@ -1,6 +1,7 @@
 package events
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
@@ -35,6 +36,29 @@ func NewEventsService(rabbitmqURL string, log logger.Logger, publisherConfig *Pu
 	}, nil
 }
 
+// Shutdown drains the consumers first, their handlers may still publish, then closes the publisher
+func (s *EventsService) Shutdown(ctx context.Context) error {
+	var errs []error
+
+	if s.Subscriber != nil {
+		if err := s.Subscriber.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if s.Publisher != nil {
+		if err := s.Publisher.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("errors shutting down events service: %v", errs)
+	}
+
+	return nil
+}
+
 func (s *EventsService) Close() error {
 	var errs []error
 
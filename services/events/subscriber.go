@@ -17,10 +17,19 @@ import (
 	"github.com/customeros/mailstack/internal/utils"
 )
 
+const (
+	DefaultPrefetchCount   = 10
+	DefaultConsumerWorkers = 4
+	DefaultShutdownTimeout = 30 * time.Second
+)
+
 type SubscriberConfig struct {
 	MaxRetries          int
 	ReconnectBackoff    time.Duration
 	MaxReconnectBackoff time.Duration
+	DedupWindow         time.Duration // processed message ids are skipped for this long, 0 disables
+	PrefetchCount       int           // unacknowledged deliveries per queue
+	Workers             int           // concurrent handlers per queue
 }
 
 type RabbitMQSubscriber struct {
@@ -31,30 +40,57 @@ type RabbitMQSubscriber struct {
 	config          SubscriberConfig
 	listeners       map[string]interfaces.EventListener
 	listenerMutex   sync.RWMutex
+	processed       *processedMessages
+
+	// shutdown
+	consumers     map[string]*amqp091.Channel // by consumer tag
+	consumerMutex sync.Mutex
+	consumersWg   sync.WaitGroup
+	shutdown      chan struct{}
+	shutdownOnce  sync.Once
 }
 
 func NewRabbitMQSubscriber(rabbitmqURL string, logger logger.Logger, config *SubscriberConfig) (*RabbitMQSubscriber, error) {
+	subscriber := newSubscriber(rabbitmqURL, logger, config)
+
+	err := subscriber.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	return subscriber, nil
+}
+
+// newSubscriber sets up a subscriber without connecting. Every worker of a queue needs a
+// delivery to work on, so the prefetch is at least the number of workers.
+func newSubscriber(rabbitmqURL string, logger logger.Logger, config *SubscriberConfig) *RabbitMQSubscriber {
 	if config == nil {
 		config = &SubscriberConfig{
 			MaxRetries:          5,
 			ReconnectBackoff:    time.Second,
 			MaxReconnectBackoff: time.Second * 30,
+			DedupWindow:         DefaultDedupWindow,
+			PrefetchCount:       DefaultPrefetchCount,
+			Workers:             DefaultConsumerWorkers,
 		}
 	}
+	settings := *config
+	if settings.Workers <= 0 {
+		settings.Workers = 1
+	}
+	if settings.PrefetchCount < settings.Workers {
+		settings.PrefetchCount = settings.Workers
+	}
 
-	subscriber := &RabbitMQSubscriber{
+	return &RabbitMQSubscriber{
 		url:       rabbitmqURL,
 		logger:    logger,
-		config:    *config,
+		config:    settings,
 		listeners: make(map[string]interfaces.EventListener),
+		processed: newProcessedMessages(settings.DedupWindow),
+		consumers: make(map[string]*amqp091.Channel),
+		shutdown:  make(chan struct{}),
 	}
-
-	err := subscriber.connect()
-	if err != nil {
-		return nil, err
-	}
-
-	return subscriber, nil
 }
 
 func (r *RabbitMQSubscriber) RegisterListener(listener interfaces.EventListener) {
@@ -79,58 +115,194 @@ func (r *RabbitMQSubscriber) ListenQueueExclusive(queueName string) error {
 
 // listenQueueWithExclusive is the internal method to listen to a queue with optional exclusivity
 func (r *RabbitMQSubscriber) listenQueueWithExclusive(queueName string, exclusive bool) error {
+	if r.isShuttingDown() {
+		return errors.Errorf("subscriber is shutting down, not listening on queue %s", queueName)
+	}
+
+	r.consumersWg.Add(1)
 	go func() {
-		for {
-			channel, err := r.connection.Channel()
+		defer r.consumersWg.Done()
+
+		for !r.isShuttingDown() {
+			channel, err := r.openChannel()
 			if err != nil {
 				r.logger.Errorf("Failed to open channel for queue %s: %v. Retrying...", queueName, err)
-				time.Sleep(5 * time.Second)
+				r.sleep(5 * time.Second)
+				continue
+			}
+
+			// bounds the deliveries buffered by this pod, the broker holds back the rest
+			err = channel.Qos(r.config.PrefetchCount, 0, false)
+			if err != nil {
+				channel.Close()
+				r.logger.Errorf("Failed to set prefetch for queue %s: %v. Retrying...", queueName, err)
+				r.sleep(5 * time.Second)
 				continue
 			}
-			defer channel.Close()
 
+			consumerTag := utils.GenerateNanoIDWithPrefix(queueName, 12)
 			msgs, err := channel.Consume(
-				queueName, // queue
-				"",        // consumer tag
-				false,     // auto-ack
-				exclusive, // exclusive
-				false,     // no-local
-				false,     // no-wait
-				nil,       // args
+				queueName,   // queue
+				consumerTag, // consumer tag
+				false,       // auto-ack
+				exclusive,   // exclusive
+				false,       // no-local
+				false,       // no-wait
+				nil,         // args
 			)
 			if err != nil {
+				channel.Close()
 				if exclusive && strings.Contains(err.Error(), "ACCESS_REFUSED") && strings.Contains(err.Error(), "exclusive") {
 					r.logger.Warnf("Exclusive consumer conflict for queue %s. Only one instance can consume exclusively.", queueName)
-					time.Sleep(10 * time.Second)
+					r.sleep(10 * time.Second)
 					continue
 				}
 				r.logger.Errorf("Failed to register consumer on queue %s: %v. Retrying...", queueName, err)
-				time.Sleep(5 * time.Second)
+				r.sleep(5 * time.Second)
 				continue
 			}
 
-			r.logger.Infof("Listening for messages on queue %s", queueName)
+			r.consumerMutex.Lock()
+			r.consumers[consumerTag] = channel
+			r.consumerMutex.Unlock()
+
+			r.logger.Infof("Listening for messages on queue %s with %d workers, prefetch %d", queueName, r.config.Workers, r.config.PrefetchCount)
+			r.consume(msgs, queueName)
+
+			r.consumerMutex.Lock()
+			delete(r.consumers, consumerTag)
+			r.consumerMutex.Unlock()
+			channel.Close()
+
+			if r.isShuttingDown() {
+				r.logger.Infof("Stopped consuming queue %s", queueName)
+				return
+			}
+			r.logger.Warnf("Connection lost for queue %s. Reconnecting...", queueName)
+			r.sleep(5 * time.Second)
+		}
+	}()
+
+	return nil
+}
 
+// consume runs the workers of a queue until the deliveries channel closes, on cancel or
+// connection loss. Deliveries still buffered once shutdown started are requeued.
+func (r *RabbitMQSubscriber) consume(msgs <-chan amqp091.Delivery, queueName string) {
+	var wg sync.WaitGroup
+	for i := 0; i < r.config.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
 			for d := range msgs {
+				if r.isShuttingDown() {
+					_ = d.Nack(false, true)
+					continue
+				}
 				r.handleMessage(d, queueName)
 			}
+		}()
+	}
+	wg.Wait()
+}
 
-			r.logger.Warnf("Connection lost for queue %s. Reconnecting...", queueName)
-			time.Sleep(5 * time.Second)
+// Shutdown stops accepting deliveries, waits for the handlers in flight and closes the
+// channels before the connection. Returns ctx.Err() if the handlers do not finish in time.
+func (r *RabbitMQSubscriber) Shutdown(ctx context.Context) error {
+	r.shutdownOnce.Do(func() { close(r.shutdown) })
+
+	r.consumerMutex.Lock()
+	for consumerTag, channel := range r.consumers {
+		if err := channel.Cancel(consumerTag, false); err != nil {
+			r.logger.Warnf("Failed to cancel consumer %s: %v", consumerTag, err)
 		}
+	}
+	r.consumerMutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.consumersWg.Wait()
+		close(done)
 	}()
 
-	return nil
+	var result error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		result = errors.Wrap(ctx.Err(), "timed out waiting for message handlers")
+	}
+
+	r.consumerMutex.Lock()
+	for _, channel := range r.consumers {
+		_ = channel.Close()
+	}
+	r.consumerMutex.Unlock()
+
+	r.connectionMutex.Lock()
+	defer r.connectionMutex.Unlock()
+	if r.connection != nil && !r.connection.IsClosed() {
+		if err := r.connection.Close(); err != nil && result == nil {
+			result = err
+		}
+	}
+
+	return result
 }
 
+func (r *RabbitMQSubscriber) isShuttingDown() bool {
+	select {
+	case <-r.shutdown:
+		return true
+	default:
+		return false
+	}
+}
+
+// sleep waits for the duration or until shutdown starts
+func (r *RabbitMQSubscriber) sleep(d time.Duration) {
+	select {
+	case <-time.After(d):
+	case <-r.shutdown:
+	}
+}
+
+func (r *RabbitMQSubscriber) openChannel() (*amqp091.Channel, error) {
+	r.connectionMutex.Lock()
+	connection := r.connection
+	r.connectionMutex.Unlock()
+
+	if connection == nil || connection.IsClosed() {
+		return nil, errors.New("connection is not open")
+	}
+	return connection.Channel()
+}
+
+// handleMessage processes a delivery once per message id. A duplicate of a message processed
+// within the dedup window, or being processed by another worker, is acknowledged unhandled.
 func (r *RabbitMQSubscriber) handleMessage(d amqp091.Delivery, queueName string) {
 	defer tracing.RecoverAndLogToJaeger(r.logger)
 
+	if !r.processed.reserve(queueName, d.MessageId) {
+		r.logger.Infof("Skipping duplicate message %s on queue %s", d.MessageId, queueName)
+		r.retryAckNack(d, true)
+		return
+	}
+
+	completed := false
+	defer func() {
+		// a panicking handler releases the id too
+		if !completed {
+			r.processed.release(queueName, d.MessageId)
+		}
+	}()
+
 	err := r.processMessage(d, queueName)
 	if err != nil {
 		r.logger.Errorf("Failed to process message on queue %s: %v", queueName, err)
 		r.retryAckNack(d, false)
 	} else {
+		r.processed.complete(queueName, d.MessageId)
+		completed = true
 		r.retryAckNack(d, true)
 	}
 }
@@ -186,8 +358,11 @@ func (r *RabbitMQSubscriber) connect() error {
 	}
 
 	go func() {
-		notifyClose := r.connection.NotifyClose(make(chan *amqp091.Error))
+		notifyClose := r.connection.NotifyClose(make(chan *amqp091.Error, 1))
 		<-notifyClose
+		if r.isShuttingDown() {
+			return
+		}
 		r.logger.Warn("RabbitMQ connection closed, attempting to reconnect")
 		_ = r.connect()
 	}()
@@ -220,11 +395,7 @@ func (r *RabbitMQSubscriber) retryAckNack(d amqp091.Delivery, ack bool) {
 }
 
 func (r *RabbitMQSubscriber) Close() error {
-	r.connectionMutex.Lock()
-	defer r.connectionMutex.Unlock()
-
-	if r.connection != nil && !r.connection.IsClosed() {
-		return r.connection.Close()
-	}
-	return nil
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultShutdownTimeout)
+	defer cancel()
+	return r.Shutdown(ctx)
 }
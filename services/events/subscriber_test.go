@@ -0,0 +1,242 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/dto"
+	"github.com/customeros/mailstack/internal/logger"
+)
+
+const testQueue = "test-queue"
+
+// fakeAcknowledger counts the acks and nacks of deliveries
+type fakeAcknowledger struct {
+	acks, nacks, requeues atomic.Int32
+}
+
+func (a *fakeAcknowledger) Ack(uint64, bool) error {
+	a.acks.Add(1)
+	return nil
+}
+
+func (a *fakeAcknowledger) Nack(_ uint64, _ bool, requeue bool) error {
+	a.nacks.Add(1)
+	if requeue {
+		a.requeues.Add(1)
+	}
+	return nil
+}
+
+func (a *fakeAcknowledger) Reject(_ uint64, requeue bool) error {
+	return a.Nack(0, false, requeue)
+}
+
+type fakeListener struct {
+	handle func(ctx context.Context, event any) error
+}
+
+func (l *fakeListener) Handle(ctx context.Context, event any) error { return l.handle(ctx, event) }
+func (l *fakeListener) GetEventType() string                        { return "TEST_EVENT" }
+func (l *fakeListener) GetQueueName() string                        { return testQueue }
+
+func newTestSubscriber(t *testing.T, config *SubscriberConfig, handle func(ctx context.Context, event any) error) *RabbitMQSubscriber {
+	log := logger.NewAppLogger(&logger.Config{DevMode: true})
+	log.InitLogger()
+	r := newSubscriber("", log, config)
+	r.RegisterListener(&fakeListener{handle: handle})
+	return r
+}
+
+func delivery(t *testing.T, ack amqp091.Acknowledger, messageId string) amqp091.Delivery {
+	body, err := json.Marshal(dto.Event{Event: dto.EventDetails{Id: messageId, EventType: "TEST_EVENT"}})
+	require.NoError(t, err)
+	return amqp091.Delivery{Acknowledger: ack, MessageId: messageId, Body: body}
+}
+
+func TestNewSubscriberConfig(t *testing.T) {
+	r := newSubscriber("", nil, nil)
+	assert.Equal(t, DefaultConsumerWorkers, r.config.Workers)
+	assert.Equal(t, DefaultPrefetchCount, r.config.PrefetchCount)
+
+	// every worker needs a delivery, the qos prefetch is raised to the workers
+	r = newSubscriber("", nil, &SubscriberConfig{Workers: 8, PrefetchCount: 2})
+	assert.Equal(t, 8, r.config.PrefetchCount)
+
+	r = newSubscriber("", nil, &SubscriberConfig{})
+	assert.Equal(t, 1, r.config.Workers)
+	assert.Equal(t, 1, r.config.PrefetchCount)
+}
+
+func TestHandleMessageConcurrentDuplicates(t *testing.T) {
+	var handled atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	r := newTestSubscriber(t, &SubscriberConfig{DedupWindow: time.Minute}, func(context.Context, any) error {
+		handled.Add(1)
+		close(started)
+		<-release
+		return nil
+	})
+
+	const deliveries = 5
+	ack := &fakeAcknowledger{}
+	var wg sync.WaitGroup
+	for i := 0; i < deliveries; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.handleMessage(delivery(t, ack, "event_1"), testQueue)
+		}()
+	}
+
+	// the worker that reserved the id is held in the handler, the duplicates are acked unhandled
+	<-started
+	require.Eventually(t, func() bool { return ack.acks.Load() == deliveries-1 }, time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), handled.Load())
+	assert.Equal(t, int32(deliveries), ack.acks.Load())
+	assert.Zero(t, ack.nacks.Load())
+}
+
+func TestHandleMessageFailureReleasesId(t *testing.T) {
+	var calls atomic.Int32
+	r := newTestSubscriber(t, &SubscriberConfig{DedupWindow: time.Minute}, func(context.Context, any) error {
+		switch calls.Add(1) {
+		case 1:
+			return errors.New("database unavailable")
+		case 2:
+			panic("listener crashed")
+		}
+		return nil
+	})
+	ack := &fakeAcknowledger{}
+
+	r.handleMessage(delivery(t, ack, "event_1"), testQueue)
+	assert.Equal(t, int32(1), ack.nacks.Load())
+	assert.Zero(t, ack.requeues.Load()) // dead lettered
+
+	r.handleMessage(delivery(t, ack, "event_1"), testQueue)
+	r.handleMessage(delivery(t, ack, "event_1"), testQueue)
+	assert.Equal(t, int32(1), ack.acks.Load())
+
+	// processed, redeliveries are skipped
+	r.handleMessage(delivery(t, ack, "event_1"), testQueue)
+	assert.Equal(t, int32(3), calls.Load())
+	assert.Equal(t, int32(2), ack.acks.Load())
+}
+
+func TestConsumeRunsWorkersConcurrently(t *testing.T) {
+	const workers = 3
+	var barrier sync.WaitGroup
+	barrier.Add(workers)
+	var handled, active, maxActive atomic.Int32
+	r := newTestSubscriber(t, &SubscriberConfig{Workers: workers}, func(context.Context, any) error {
+		current := active.Add(1)
+		defer active.Add(-1)
+		for {
+			seen := maxActive.Load()
+			if current <= seen || maxActive.CompareAndSwap(seen, current) {
+				break
+			}
+		}
+		// the first deliveries wait for each other, which only works when handled in parallel
+		if handled.Add(1) <= workers {
+			barrier.Done()
+			waitTimeout(&barrier, time.Second)
+		}
+		return nil
+	})
+
+	ack := &fakeAcknowledger{}
+	msgs := make(chan amqp091.Delivery, 9)
+	for i := 0; i < cap(msgs); i++ {
+		msgs <- delivery(t, ack, string(rune('a'+i)))
+	}
+	close(msgs)
+	r.consume(msgs, testQueue)
+
+	assert.Equal(t, int32(9), handled.Load())
+	assert.Equal(t, int32(9), ack.acks.Load())
+	assert.Equal(t, int32(workers), maxActive.Load())
+}
+
+func TestConsumeRequeuesAfterShutdown(t *testing.T) {
+	var handled atomic.Int32
+	r := newTestSubscriber(t, nil, func(context.Context, any) error {
+		handled.Add(1)
+		return nil
+	})
+	r.shutdownOnce.Do(func() { close(r.shutdown) })
+
+	ack := &fakeAcknowledger{}
+	msgs := make(chan amqp091.Delivery, 3)
+	for _, id := range []string{"a", "b", "c"} {
+		msgs <- delivery(t, ack, id)
+	}
+	close(msgs)
+	r.consume(msgs, testQueue)
+
+	assert.Zero(t, handled.Load())
+	assert.Equal(t, int32(3), ack.requeues.Load())
+	assert.Error(t, r.ListenQueue(testQueue))
+}
+
+func TestShutdown(t *testing.T) {
+	t.Run("waits for handlers in flight", func(t *testing.T) {
+		r := newTestSubscriber(t, nil, nil)
+		var finished atomic.Bool
+		r.consumersWg.Add(1)
+		go func() {
+			defer r.consumersWg.Done()
+			<-r.shutdown
+			time.Sleep(20 * time.Millisecond)
+			finished.Store(true)
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		require.NoError(t, r.Shutdown(ctx))
+		assert.True(t, finished.Load())
+		assert.True(t, r.isShuttingDown())
+	})
+
+	t.Run("gives up when the handlers do not finish in time", func(t *testing.T) {
+		r := newTestSubscriber(t, nil, nil)
+		stuck := make(chan struct{})
+		defer close(stuck)
+		r.consumersWg.Add(1)
+		go func() {
+			defer r.consumersWg.Done()
+			<-stuck
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err := r.Shutdown(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+// waitTimeout waits for wg, giving up after timeout
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+	}
+}
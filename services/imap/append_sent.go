@@ -0,0 +1,128 @@
+package imap
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	"github.com/opentracing/opentracing-go"
+	tracingLog "github.com/opentracing/opentracing-go/log"
+
+	mailstack_errors "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+)
+
+// sentSpecialUse is the RFC 6154 attribute servers put on the sent folder
+const sentSpecialUse = "\\Sent"
+
+// commonSentFolders are tried in order when the server does not mark its sent folder
+var commonSentFolders = []string{"Sent", "Sent Items", "Sent Messages", "Sent Mail", "INBOX.Sent", "[Gmail]/Sent Mail"}
+
+// serversSavingSentMail store messages sent through their SMTP server in the sent folder
+// themselves, appending them again would show every message twice
+var serversSavingSentMail = []string{"imap.gmail.com", "outlook.office365.com"}
+
+// AppendToSent stores a message sent over SMTP in the sent folder of the mailbox, flagged as seen.
+// The folder is the one configured on the mailbox, or detected from the folder list when unset.
+// Returns ErrSentFolderNotFound when the server has no sent folder.
+func (s *IMAPService) AppendToSent(ctx context.Context, mailbox *models.Mailbox, message []byte, date time.Time) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "IMAPService.AppendToSent")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.SetTag("mailbox_id", mailbox.ID)
+
+	if mailbox.ImapServer == "" || savesSentMail(mailbox.ImapServer) {
+		span.LogFields(tracingLog.String("result", "skipped"))
+		return nil
+	}
+
+	c, done, err := s.sessionClient(ctx, mailbox)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+	defer done()
+
+	folder := mailbox.SentFolder
+	if folder == "" {
+		folder, err = detectSentFolder(c)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return err
+		}
+	}
+	span.SetTag("folder", folder)
+
+	err = c.Append(folder, []string{imap.SeenFlag}, date, bytes.NewReader(message))
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+	return nil
+}
+
+// sessionClient opens a short lived connection for a one-off command. The connection of a monitored
+// mailbox is never shared: the sync loop selects folders and idles on it, so a command sent from
+// another goroutine would interleave with its own. The connection counts against the connection limit.
+func (s *IMAPService) sessionClient(ctx context.Context, mailbox *models.Mailbox) (*client.Client, func(), error) {
+	release, err := s.connections.acquire(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	connectCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	c, err := s.connectToIMAPServer(connectCtx, mailbox)
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	return c, func() {
+		c.Logout()
+		release()
+	}, nil
+}
+
+// detectSentFolder lists the folders of the server and picks the sent one
+func detectSentFolder(c *client.Client) (string, error) {
+	folders, err := listFolders(c, "*")
+	if err != nil {
+		return "", err
+	}
+	return pickSentFolder(folders)
+}
+
+// pickSentFolder prefers the folder marked as sent, then the common sent folder names
+func pickSentFolder(folders []*imap.MailboxInfo) (string, error) {
+	for _, folder := range folders {
+		for _, attr := range folder.Attributes {
+			if strings.EqualFold(attr, sentSpecialUse) {
+				return folder.Name, nil
+			}
+		}
+	}
+
+	for _, name := range commonSentFolders {
+		for _, folder := range folders {
+			if strings.EqualFold(folder.Name, name) {
+				return folder.Name, nil
+			}
+		}
+	}
+
+	return "", mailstack_errors.ErrSentFolderNotFound
+}
+
+func savesSentMail(server string) bool {
+	for _, s := range serversSavingSentMail {
+		if strings.EqualFold(server, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+package imap
+
+import (
+	"testing"
+
+	"github.com/emersion/go-imap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	mailstack_errors "github.com/customeros/mailstack/internal/errors"
+)
+
+func TestPickSentFolder(t *testing.T) {
+	t.Run("special use attribute wins", func(t *testing.T) {
+		folder, err := pickSentFolder([]*imap.MailboxInfo{
+			{Name: "Sent"},
+			{Name: "Gesendet", Attributes: []string{"\\HasNoChildren", "\\Sent"}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "Gesendet", folder)
+	})
+
+	t.Run("common name", func(t *testing.T) {
+		folder, err := pickSentFolder([]*imap.MailboxInfo{{Name: "INBOX"}, {Name: "sent items"}})
+		require.NoError(t, err)
+		assert.Equal(t, "sent items", folder)
+	})
+
+	t.Run("no sent folder", func(t *testing.T) {
+		_, err := pickSentFolder([]*imap.MailboxInfo{{Name: "INBOX"}, {Name: "Archive"}})
+		assert.ErrorIs(t, err, mailstack_errors.ErrSentFolderNotFound)
+	})
+}
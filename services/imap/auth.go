@@ -0,0 +1,43 @@
+package imap
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/emersion/go-imap/client"
+)
+
+// transientLoginReplies are parts of NO replies to LOGIN that servers send when they cannot
+// check the credentials right now, e.g. during maintenance or when rate limiting
+var transientLoginReplies = []string{"unavailable", "try again", "temporar", "too many", "rate limit", "throttl"}
+
+// isAuthFailure tells whether a failed LOGIN was a rejection of the credentials rather than a
+// connectivity problem. The IMAP client only keeps the text of the server reply, so anything the
+// server refused that does not read as transient counts as rejected credentials.
+func isAuthFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, client.ErrLoginDisabled) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed) {
+		return false
+	}
+
+	reply := strings.ToLower(err.Error())
+	if strings.Contains(reply, "connection closed") {
+		return false
+	}
+	for _, transient := range transientLoginReplies {
+		if strings.Contains(reply, transient) {
+			return false
+		}
+	}
+	return true
+}
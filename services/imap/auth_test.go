@@ -0,0 +1,24 @@
+package imap
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/emersion/go-imap/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsAuthFailure(t *testing.T) {
+	assert.True(t, isAuthFailure(errors.New("Invalid credentials (Failure)")))
+	assert.True(t, isAuthFailure(errors.New("LOGIN failed.")))
+	assert.True(t, isAuthFailure(client.ErrLoginDisabled))
+
+	assert.False(t, isAuthFailure(nil))
+	assert.False(t, isAuthFailure(io.EOF))
+	assert.False(t, isAuthFailure(&net.OpError{Op: "read", Err: errors.New("connection reset by peer")}))
+	assert.False(t, isAuthFailure(errors.New("imap: connection closed during command execution")))
+	assert.False(t, isAuthFailure(errors.New("Authentication service temporarily unavailable, try again later")))
+	assert.False(t, isAuthFailure(errors.New("Too many login attempts")))
+}
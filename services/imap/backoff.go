@@ -0,0 +1,84 @@
+package imap
+
+import (
+	"context"
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"github.com/customeros/mailstack/internal/models"
+)
+
+const (
+	// backoffMultiplier grows the delay after every failed reconnect
+	backoffMultiplier = 1.5
+	// stableConnection is how long a connection has to last before a later disconnect starts
+	// over from the initial delay, shorter ones keep growing the delay
+	stableConnection = 5 * time.Minute
+)
+
+// reconnectBackoff is the delay before reconnecting a mailbox. Delays use equal jitter: half of
+// the current backoff plus a random share of the other half, which spreads mailboxes that lost
+// their connection at the same moment instead of reconnecting them in lockstep.
+type reconnectBackoff struct {
+	initial     time.Duration
+	max         time.Duration
+	current     time.Duration
+	connectedAt time.Time
+	random      func() float64
+}
+
+func newReconnectBackoff(config *models.Mailbox) *reconnectBackoff {
+	initial := models.DefaultReconnectInitialBackoffSeconds * time.Second
+	maxBackoff := models.DefaultReconnectMaxBackoffSeconds * time.Second
+	if config != nil {
+		if config.ReconnectInitialBackoffSeconds > 0 && config.ReconnectInitialBackoffSeconds <= models.MaxReconnectBackoffSeconds {
+			initial = time.Duration(config.ReconnectInitialBackoffSeconds) * time.Second
+		}
+		if config.ReconnectMaxBackoffSeconds > 0 && config.ReconnectMaxBackoffSeconds <= models.MaxReconnectBackoffSeconds {
+			maxBackoff = time.Duration(config.ReconnectMaxBackoffSeconds) * time.Second
+		}
+	}
+	maxBackoff = max(maxBackoff, initial)
+
+	return &reconnectBackoff{
+		initial: initial,
+		max:     maxBackoff,
+		current: initial,
+		random:  rand.Float64,
+	}
+}
+
+// next returns the jittered delay before the next attempt and grows the backoff for the one after
+func (b *reconnectBackoff) next() time.Duration {
+	delay := b.current/2 + time.Duration(b.random()*float64(b.current/2))
+	b.current = min(time.Duration(float64(b.current)*backoffMultiplier), b.max)
+	return delay
+}
+
+// connected records the start of a connection
+func (b *reconnectBackoff) connected(now time.Time) {
+	b.connectedAt = now
+}
+
+// disconnected records the loss of the connection. The backoff starts over only when the
+// connection was up long enough, so a server dropping connections right away is backed off from.
+func (b *reconnectBackoff) disconnected(now time.Time) {
+	if !b.connectedAt.IsZero() && now.Sub(b.connectedAt) >= stableConnection {
+		b.current = b.initial
+	}
+	b.connectedAt = time.Time{}
+}
+
+// waitBeforeReconnect sleeps the next backoff delay, returning early when the context is done
+func waitBeforeReconnect(ctx context.Context, mailboxID string, backoff *reconnectBackoff) error {
+	delay := backoff.next()
+	log.Printf("[%s] Will retry in %v", mailboxID, delay)
+
+	select {
+	case <-time.After(delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
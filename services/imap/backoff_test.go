@@ -0,0 +1,66 @@
+package imap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/customeros/mailstack/internal/models"
+)
+
+func TestReconnectBackoffJitterSpreadsMailboxes(t *testing.T) {
+	config := &models.Mailbox{ReconnectInitialBackoffSeconds: 10, ReconnectMaxBackoffSeconds: 60}
+
+	const mailboxes = 1000
+	buckets := make(map[time.Duration]int)
+	lowest, highest := time.Duration(1<<62), time.Duration(0)
+	for i := 0; i < mailboxes; i++ {
+		delay := newReconnectBackoff(config).next()
+		assert.GreaterOrEqual(t, delay, 5*time.Second)
+		assert.LessOrEqual(t, delay, 10*time.Second)
+		lowest, highest = min(lowest, delay), max(highest, delay)
+		buckets[delay.Truncate(500*time.Millisecond)]++
+	}
+
+	// the delays cover most of the jitter window and no half second of it takes a crowd
+	assert.Greater(t, highest-lowest, 4*time.Second)
+	for bucket, count := range buckets {
+		assert.Less(t, count, mailboxes/4, "bucket %v", bucket)
+	}
+}
+
+func TestReconnectBackoffGrowth(t *testing.T) {
+	backoff := newReconnectBackoff(&models.Mailbox{ReconnectInitialBackoffSeconds: 2, ReconnectMaxBackoffSeconds: 5})
+	backoff.random = func() float64 { return 1 }
+
+	assert.Equal(t, 2*time.Second, backoff.next())
+	assert.Equal(t, 3*time.Second, backoff.next())
+	assert.Equal(t, 4500*time.Millisecond, backoff.next())
+	assert.Equal(t, 5*time.Second, backoff.next())
+	assert.Equal(t, 5*time.Second, backoff.next())
+
+	t.Run("short connection keeps backing off", func(t *testing.T) {
+		now := time.Now()
+		backoff.connected(now)
+		backoff.disconnected(now.Add(10 * time.Second))
+		assert.Equal(t, 5*time.Second, backoff.next())
+	})
+
+	t.Run("sustained connection starts over", func(t *testing.T) {
+		now := time.Now()
+		backoff.connected(now)
+		backoff.disconnected(now.Add(stableConnection))
+		assert.Equal(t, 2*time.Second, backoff.next())
+	})
+}
+
+func TestReconnectBackoffDefaults(t *testing.T) {
+	backoff := newReconnectBackoff(&models.Mailbox{ReconnectInitialBackoffSeconds: 30, ReconnectMaxBackoffSeconds: 10})
+	assert.Equal(t, 30*time.Second, backoff.initial)
+	assert.Equal(t, 30*time.Second, backoff.max)
+
+	backoff = newReconnectBackoff(nil)
+	assert.Equal(t, time.Second, backoff.initial)
+	assert.Equal(t, 2*time.Minute, backoff.max)
+}
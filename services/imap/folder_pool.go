@@ -0,0 +1,116 @@
+package imap
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/emersion/go-imap/client"
+)
+
+// folderConnector returns the connection a folder worker syncs over and how to close it
+type folderConnector func(ctx context.Context, worker int) (*client.Client, func(), error)
+
+// folderProcessor syncs a folder over a connection
+type folderProcessor func(ctx context.Context, c *client.Client, folder string) error
+
+// syncFolderPool syncs the folders, in order, over up to workers connections. A connection only
+// ever processes one folder at a time. Workers whose connection can not be opened leave their
+// folders to the others. Worker 0 syncs over the mailbox connection: its connectivity error stops
+// all workers and is returned so the mailbox reconnects. A connectivity error of an extra
+// connection only stops that worker, its folder goes back to the queue.
+func syncFolderPool(
+	ctx context.Context,
+	mailboxID string,
+	folders []string,
+	workers int,
+	connect folderConnector,
+	process folderProcessor,
+) (processedFolders map[string]bool, connectivityError error) {
+	processedFolders = make(map[string]bool)
+	workers = max(1, min(workers, len(folders)))
+
+	poolCtx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	queue := &folderQueue{folders: append([]string{}, folders...)}
+	// workers still waiting for their connection give up once there is nothing left to sync
+	connectCtx, cancelConnects := context.WithCancel(poolCtx)
+	defer cancelConnects()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+
+			c, closeConnection, err := connect(connectCtx, worker)
+			if err != nil {
+				log.Printf("[%s] Failed to open folder sync connection #%d, continuing with fewer: %v", mailboxID, worker, err)
+				return
+			}
+			defer closeConnection()
+
+			for poolCtx.Err() == nil {
+				folder, ok := queue.next()
+				if !ok {
+					cancelConnects()
+					return
+				}
+				log.Printf("[%s] About to process folder: %s", mailboxID, folder)
+
+				err := process(poolCtx, c, folder)
+
+				if isConnectionError(err) && worker > 0 {
+					log.Printf("[%s][%s] Connection error on folder sync connection #%d, continuing with fewer: %v", mailboxID, folder, worker, err)
+					queue.requeue(folder)
+					return
+				}
+
+				mu.Lock()
+				if isConnectionError(err) {
+					if connectivityError == nil {
+						connectivityError = err
+					}
+					mu.Unlock()
+					log.Printf("[%s][%s] Connection error, will stop processing folders", mailboxID, folder)
+					stop()
+					return
+				}
+				if err != nil {
+					log.Printf("[%s][%s] Non-connectivity error, continuing with other folders", mailboxID, folder)
+				}
+				processedFolders[folder] = err == nil
+				mu.Unlock()
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	return processedFolders, connectivityError
+}
+
+// folderQueue hands out the folders left to sync
+type folderQueue struct {
+	mu      sync.Mutex
+	folders []string
+}
+
+func (q *folderQueue) next() (string, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.folders) == 0 {
+		return "", false
+	}
+	folder := q.folders[0]
+	q.folders = q.folders[1:]
+	return folder, true
+}
+
+// requeue puts back a folder whose connection was lost, ahead of the others
+func (q *folderQueue) requeue(folder string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.folders = append([]string{folder}, q.folders...)
+}
@@ -0,0 +1,153 @@
+package imap
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeConnections hands every worker its own client and checks none syncs two folders at once
+type fakeConnections struct {
+	mu        sync.Mutex
+	selected  map[*client.Client]string
+	overlaps  int
+	active    int
+	maxActive int
+	failed    map[int]bool
+	workers   map[*client.Client]int
+}
+
+func (f *fakeConnections) connect(_ context.Context, worker int) (*client.Client, func(), error) {
+	if f.failed[worker] {
+		return nil, nil, errors.New("too many simultaneous connections")
+	}
+	c := &client.Client{}
+	f.mu.Lock()
+	f.workers[c] = worker
+	f.mu.Unlock()
+	return c, func() {}, nil
+}
+
+func (f *fakeConnections) worker(c *client.Client) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.workers[c]
+}
+
+func (f *fakeConnections) enter(c *client.Client, folder string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, busy := f.selected[c]; busy {
+		f.overlaps++
+	}
+	f.selected[c] = folder
+	f.active++
+	f.maxActive = max(f.maxActive, f.active)
+}
+
+func (f *fakeConnections) leave(c *client.Client) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.selected, c)
+	f.active--
+}
+
+func newFakeConnections() *fakeConnections {
+	return &fakeConnections{selected: make(map[*client.Client]string), failed: make(map[int]bool), workers: make(map[*client.Client]int)}
+}
+
+func TestSyncFolderPoolBoundsConcurrency(t *testing.T) {
+	conns := newFakeConnections()
+	folders := []string{"INBOX", "Sent", "Archive", "Clients/Acme", "Clients/Beta", "Drafts"}
+
+	processed, err := syncFolderPool(context.Background(), "mbox_1", folders, 3, conns.connect,
+		func(_ context.Context, c *client.Client, folder string) error {
+			conns.enter(c, folder)
+			defer conns.leave(c)
+			time.Sleep(10 * time.Millisecond)
+			if folder == "Drafts" {
+				return errors.New("error selecting folder: no such folder")
+			}
+			return nil
+		})
+
+	require.NoError(t, err)
+	assert.Len(t, processed, len(folders))
+	assert.False(t, processed["Drafts"])
+	assert.True(t, processed["Clients/Beta"])
+	assert.Zero(t, conns.overlaps)
+	assert.LessOrEqual(t, conns.maxActive, 3)
+}
+
+func TestSyncFolderPoolStopsOnConnectionError(t *testing.T) {
+	conns := newFakeConnections()
+	folders := []string{"INBOX", "Sent", "Archive", "Clients/Acme"}
+	var started atomic.Int32
+	var bothStarted sync.WaitGroup
+	bothStarted.Add(2)
+
+	processed, err := syncFolderPool(context.Background(), "mbox_1", folders, 2, conns.connect,
+		func(ctx context.Context, c *client.Client, folder string) error {
+			started.Add(1)
+			bothStarted.Done()
+			if conns.worker(c) == 0 {
+				bothStarted.Wait()
+				return errors.New("connection lost: imap: connection closed")
+			}
+			// polls until the pool is stopped
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "connection closed")
+	assert.Equal(t, int32(2), started.Load()) // the queued folders were not started
+	assert.NotContains(t, processed, "Archive")
+}
+
+func TestSyncFolderPoolDropsLostExtraConnection(t *testing.T) {
+	conns := newFakeConnections()
+	folders := []string{"INBOX", "Sent", "Archive"}
+	lost := make(chan struct{})
+
+	processed, err := syncFolderPool(context.Background(), "mbox_1", folders, 2, conns.connect,
+		func(_ context.Context, c *client.Client, _ string) error {
+			if conns.worker(c) == 1 {
+				close(lost)
+				return errors.New("connection lost: imap: connection closed")
+			}
+			// the mailbox connection keeps its first folder until the extra one is lost
+			<-lost
+			return nil
+		})
+
+	require.NoError(t, err)
+	assert.Len(t, processed, 3) // the folder of the lost connection was synced over the other one
+	for _, folder := range folders {
+		assert.True(t, processed[folder], folder)
+	}
+}
+
+func TestSyncFolderPoolFallsBackWhenConnectionsFail(t *testing.T) {
+	conns := newFakeConnections()
+	conns.failed[1], conns.failed[2] = true, true
+	folders := []string{"INBOX", "Sent", "Archive"}
+
+	var order []string
+	processed, err := syncFolderPool(context.Background(), "mbox_1", folders, 3, conns.connect,
+		func(_ context.Context, _ *client.Client, folder string) error {
+			order = append(order, folder)
+			return nil
+		})
+
+	require.NoError(t, err)
+	assert.Equal(t, folders, order) // all on the mailbox connection, in order
+	assert.Len(t, processed, 3)
+}
@@ -0,0 +1,292 @@
+package imap
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	"github.com/opentracing/opentracing-go"
+	tracingLog "github.com/opentracing/opentracing-go/log"
+
+	"github.com/customeros/mailstack/interfaces"
+	mailstack_errors "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+// inboxFolder is the folder every server has, it can not be deleted
+const inboxFolder = "INBOX"
+
+// ListFolders returns the folder tree of the mailbox server, with the message and unseen counts
+// of every folder that can hold messages and whether the mailbox syncs it
+func (s *IMAPService) ListFolders(ctx context.Context, mailbox *models.Mailbox) ([]*interfaces.IMAPFolder, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "IMAPService.ListFolders")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.SetTag("mailbox_id", mailbox.ID)
+
+	if mailbox.ImapServer == "" {
+		tracing.TraceErr(span, mailstack_errors.ErrIMAPNotConfigured)
+		return nil, mailstack_errors.ErrIMAPNotConfigured
+	}
+
+	c, done, err := s.sessionClient(ctx, mailbox)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+	defer done()
+
+	infos, err := listFolders(c, "*")
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	counts := make(map[string]*imap.MailboxStatus, len(infos))
+	for _, info := range infos {
+		if !selectable(info) {
+			continue
+		}
+		status, err := c.Status(info.Name, []imap.StatusItem{imap.StatusMessages, imap.StatusUnseen})
+		if err != nil {
+			// a folder removed since the listing should not fail the whole tree
+			span.LogFields(tracingLog.String("status.failed", info.Name), tracingLog.Error(err))
+			continue
+		}
+		counts[info.Name] = status
+	}
+	span.LogFields(tracingLog.Int("folders.count", len(infos)))
+
+	return folderTree(infos, counts, mailbox.SyncFolders), nil
+}
+
+// CreateFolder creates a folder on the mailbox server. Names of nested folders use the hierarchy
+// delimiter of the server.
+func (s *IMAPService) CreateFolder(ctx context.Context, mailbox *models.Mailbox, name string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "IMAPService.CreateFolder")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.SetTag("mailbox_id", mailbox.ID)
+	span.SetTag("folder", name)
+
+	if err := validateFolderName(name); err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+	if mailbox.ImapServer == "" {
+		tracing.TraceErr(span, mailstack_errors.ErrIMAPNotConfigured)
+		return mailstack_errors.ErrIMAPNotConfigured
+	}
+
+	c, done, err := s.sessionClient(ctx, mailbox)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+	defer done()
+
+	existing, err := listFolders(c, name)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+	if len(existing) > 0 {
+		tracing.TraceErr(span, mailstack_errors.ErrFolderExists)
+		return mailstack_errors.ErrFolderExists
+	}
+
+	if err = c.Create(name); err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+	return nil
+}
+
+// DeleteFolder deletes a folder from the mailbox server. When the mailbox synced the folder it is
+// removed from its sync folders together with its sync state, and the sync restarts without it.
+func (s *IMAPService) DeleteFolder(ctx context.Context, mailbox *models.Mailbox, name string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "IMAPService.DeleteFolder")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.SetTag("mailbox_id", mailbox.ID)
+	span.SetTag("folder", name)
+
+	if err := validateFolderName(name); err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+	if strings.EqualFold(name, inboxFolder) {
+		tracing.TraceErr(span, mailstack_errors.ErrFolderProtected)
+		return mailstack_errors.ErrFolderProtected
+	}
+	if mailbox.ImapServer == "" {
+		tracing.TraceErr(span, mailstack_errors.ErrIMAPNotConfigured)
+		return mailstack_errors.ErrIMAPNotConfigured
+	}
+
+	c, done, err := s.sessionClient(ctx, mailbox)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+	err = deleteFolder(c, name)
+	// released before the sync restarts, a monitored mailbox shares its connection
+	done()
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	if !utils.IsStringInSlice(name, mailbox.SyncFolders) {
+		return nil
+	}
+	if err = s.unsyncFolder(ctx, mailbox, name); err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+	return nil
+}
+
+func deleteFolder(c *client.Client, name string) error {
+	existing, err := listFolders(c, name)
+	if err != nil {
+		return err
+	}
+	if len(existing) == 0 {
+		return mailstack_errors.ErrFolderNotFound
+	}
+	return c.Delete(name)
+}
+
+// unsyncFolder drops a deleted folder from the sync folders of the mailbox and forgets its sync
+// state and stats
+func (s *IMAPService) unsyncFolder(ctx context.Context, mailbox *models.Mailbox, name string) error {
+	folders := make([]string, 0, len(mailbox.SyncFolders))
+	for _, folder := range mailbox.SyncFolders {
+		if folder != name {
+			folders = append(folders, folder)
+		}
+	}
+
+	if err := s.repositories.MailboxRepository.UpdateSyncFolders(ctx, mailbox.ID, folders); err != nil {
+		return err
+	}
+	mailbox.SyncFolders = folders
+	if err := s.forgetSyncFolder(ctx, mailbox.ID, name); err != nil {
+		return err
+	}
+
+	s.clientsMutex.RLock()
+	_, monitored := s.mailboxConfigs[mailbox.ID]
+	s.clientsMutex.RUnlock()
+	if !monitored {
+		return nil
+	}
+	return s.ReloadMailbox(ctx, mailbox)
+}
+
+// forgetSyncFolder deletes the sync state and stats of a folder the mailbox no longer syncs
+func (s *IMAPService) forgetSyncFolder(ctx context.Context, mailboxID, name string) error {
+	if err := s.repositories.MailboxSyncRepository.DeleteSyncState(ctx, mailboxID, name); err != nil {
+		return err
+	}
+	if err := s.repositories.MailboxFolderStatsRepository.DeleteByFolder(ctx, mailboxID, name); err != nil {
+		return err
+	}
+	s.forgetFolderStatus(mailboxID, name)
+	return nil
+}
+
+// listFolders lists the folders of the server matching the pattern
+func listFolders(c *client.Client, pattern string) ([]*imap.MailboxInfo, error) {
+	mailboxes := make(chan *imap.MailboxInfo, 20)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.List("", pattern, mailboxes)
+	}()
+
+	var folders []*imap.MailboxInfo
+	for m := range mailboxes {
+		folders = append(folders, m)
+	}
+	if err := <-done; err != nil {
+		return nil, err
+	}
+	return folders, nil
+}
+
+// validateFolderName rejects names that are empty or hold the LIST wildcards, which would make
+// the existence checks match other folders
+func validateFolderName(name string) error {
+	if strings.TrimSpace(name) == "" || strings.ContainsAny(name, "*%\r\n") {
+		return mailstack_errors.ErrInvalidFolderName
+	}
+	return nil
+}
+
+func selectable(info *imap.MailboxInfo) bool {
+	for _, attr := range info.Attributes {
+		if strings.EqualFold(attr, imap.NoSelectAttr) || strings.EqualFold(attr, "\\NonExistent") {
+			return false
+		}
+	}
+	return true
+}
+
+// folderTree nests the listed folders under their parents by the hierarchy delimiter. A folder
+// whose parent was not listed is kept at the top level. Siblings are sorted by name.
+func folderTree(infos []*imap.MailboxInfo, counts map[string]*imap.MailboxStatus, synced []string) []*interfaces.IMAPFolder {
+	byName := make(map[string]*interfaces.IMAPFolder, len(infos))
+	for _, info := range infos {
+		folder := &interfaces.IMAPFolder{
+			Name:       info.Name,
+			Delimiter:  info.Delimiter,
+			Attributes: info.Attributes,
+			Synced:     utils.IsStringInSlice(info.Name, synced),
+			Children:   []*interfaces.IMAPFolder{},
+		}
+		if folder.Attributes == nil {
+			folder.Attributes = []string{}
+		}
+		if status := counts[info.Name]; status != nil {
+			folder.Messages = status.Messages
+			folder.Unseen = status.Unseen
+		}
+		byName[info.Name] = folder
+	}
+
+	roots := []*interfaces.IMAPFolder{}
+	for _, info := range infos {
+		folder := byName[info.Name]
+		if parent := byName[parentFolder(info)]; parent != nil && parent != folder {
+			parent.Children = append(parent.Children, folder)
+		} else {
+			roots = append(roots, folder)
+		}
+	}
+
+	sortFolders(roots)
+	return roots
+}
+
+func parentFolder(info *imap.MailboxInfo) string {
+	if info.Delimiter == "" {
+		return ""
+	}
+	i := strings.LastIndex(info.Name, info.Delimiter)
+	if i <= 0 {
+		return ""
+	}
+	return info.Name[:i]
+}
+
+func sortFolders(folders []*interfaces.IMAPFolder) {
+	sort.Slice(folders, func(i, j int) bool { return folders[i].Name < folders[j].Name })
+	for _, folder := range folders {
+		sortFolders(folder.Children)
+	}
+}
@@ -0,0 +1,56 @@
+package imap
+
+import (
+	"testing"
+
+	"github.com/emersion/go-imap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	mailstack_errors "github.com/customeros/mailstack/internal/errors"
+)
+
+func TestFolderTree(t *testing.T) {
+	infos := []*imap.MailboxInfo{
+		{Name: "Projects/Acme", Delimiter: "/"},
+		{Name: "INBOX", Delimiter: "/"},
+		{Name: "Projects", Delimiter: "/", Attributes: []string{imap.NoSelectAttr}},
+		{Name: "Projects/Acme/2026", Delimiter: "/"},
+		{Name: "Archive/Old", Delimiter: "/"},
+		{Name: "Projects/Beta", Delimiter: "/"},
+	}
+	counts := map[string]*imap.MailboxStatus{
+		"INBOX":         {Messages: 120, Unseen: 4},
+		"Projects/Acme": {Messages: 7},
+	}
+
+	tree := folderTree(infos, counts, []string{"INBOX", "Projects/Acme"})
+
+	require.Len(t, tree, 3)
+	assert.Equal(t, "Archive/Old", tree[0].Name) // parent not listed
+	inbox := tree[1]
+	assert.Equal(t, "INBOX", inbox.Name)
+	assert.Equal(t, uint32(120), inbox.Messages)
+	assert.Equal(t, uint32(4), inbox.Unseen)
+	assert.True(t, inbox.Synced)
+	assert.Empty(t, inbox.Children)
+
+	projects := tree[2]
+	assert.False(t, projects.Synced)
+	assert.Zero(t, projects.Messages)
+	require.Len(t, projects.Children, 2)
+	acme := projects.Children[0]
+	assert.Equal(t, "Projects/Acme", acme.Name)
+	assert.True(t, acme.Synced)
+	assert.Equal(t, uint32(7), acme.Messages)
+	require.Len(t, acme.Children, 1)
+	assert.Equal(t, "Projects/Acme/2026", acme.Children[0].Name)
+	assert.Equal(t, "Projects/Beta", projects.Children[1].Name)
+}
+
+func TestValidateFolderName(t *testing.T) {
+	assert.NoError(t, validateFolderName("Clients/Acme"))
+	for _, name := range []string{"", "  ", "Clients/*", "50%"} {
+		assert.ErrorIs(t, validateFolderName(name), mailstack_errors.ErrInvalidFolderName, name)
+	}
+}
@@ -3,11 +3,14 @@ package imap
 import (
 	"context"
 	"fmt"
+	"io"
 
 	"github.com/emersion/go-imap"
 	"github.com/opentracing/opentracing-go"
 
+	mailstack_errors "github.com/customeros/mailstack/internal/errors"
 	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
 )
 
 func (s *IMAPService) GetMessageByUID(ctx context.Context, mailboxID, folderName string, uid uint32) (*imap.Message, error) {
@@ -80,3 +83,66 @@ func (s *IMAPService) GetMessageByUID(ctx context.Context, mailboxID, folderName
 
 	return msg, nil
 }
+
+// FetchRaw returns the RFC 822 source of a message as stored on the server. BODY.PEEK[] is used,
+// so reading the source does not mark the message as seen. A UID that now holds another message
+// than messageID, e.g. after the folder was recreated, is reported as not on the server.
+func (s *IMAPService) FetchRaw(ctx context.Context, mailboxID, folderName string, uid uint32, messageID string) ([]byte, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "IMAPService.FetchRaw")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.SetTag("mailbox_id", mailboxID)
+	span.SetTag("folder", folderName)
+	span.SetTag("uid", uid)
+
+	client, err := s.getConnectedClient(ctx, mailboxID)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	if _, err = client.Select(folderName, true); err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	seqSet := new(imap.SeqSet)
+	seqSet.AddNum(uid)
+	section := &imap.BodySectionName{Peek: true}
+
+	messages := make(chan *imap.Message, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- client.UidFetch(seqSet, []imap.FetchItem{section.FetchItem()}, messages)
+	}()
+
+	var raw []byte
+	for msg := range messages {
+		if body := msg.GetBody(section); body != nil {
+			if raw, err = io.ReadAll(body); err != nil {
+				err = fmt.Errorf("failed to read message source: %w", err)
+			}
+		}
+	}
+	if fetchErr := <-done; fetchErr != nil {
+		err = fetchErr
+	}
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	if raw == nil {
+		err = fmt.Errorf("%w: UID %d in %s", mailstack_errors.ErrMessageNotOnServer, uid, folderName)
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+	if !utils.RawHasMessageID(raw, messageID) {
+		err = fmt.Errorf("%w: UID %d in %s holds another message", mailstack_errors.ErrMessageNotOnServer, uid, folderName)
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+	span.LogKV("size", len(raw))
+
+	return raw, nil
+}
@@ -0,0 +1,137 @@
+package imap
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	"github.com/emersion/go-imap/commands"
+	"github.com/opentracing/opentracing-go"
+	tracingLog "github.com/opentracing/opentracing-go/log"
+
+	"github.com/customeros/mailstack/dto"
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/enum"
+	mailstack_errors "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+// ImportMessages appends messages to a folder of the mailbox with their flags and internal date,
+// reporting the outcome per message. A rejected message does not stop the others, a lost
+// connection fails the rest of the batch. With process set the imported messages are queued for
+// processing like synced ones, except in sync folders whose sync picks them up anyway. Queuing
+// needs the UID of the message, which the server only returns when it supports UIDPLUS.
+func (s *IMAPService) ImportMessages(ctx context.Context, mailbox *models.Mailbox, folder string, messages []interfaces.ImportMessage, process bool) ([]interfaces.ImportResult, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "IMAPService.ImportMessages")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.SetTag("mailbox_id", mailbox.ID)
+	span.SetTag("folder", folder)
+	span.LogFields(tracingLog.Int("messages", len(messages)), tracingLog.Bool("process", process))
+
+	if err := validateFolderName(folder); err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+	if mailbox.ImapServer == "" {
+		tracing.TraceErr(span, mailstack_errors.ErrIMAPNotConfigured)
+		return nil, mailstack_errors.ErrIMAPNotConfigured
+	}
+
+	c, done, err := s.sessionClient(ctx, mailbox)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+	defer done()
+
+	existing, err := listFolders(c, folder)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+	if len(existing) == 0 {
+		tracing.TraceErr(span, mailstack_errors.ErrFolderNotFound)
+		return nil, mailstack_errors.ErrFolderNotFound
+	}
+
+	// the sync of a sync folder ingests the new messages itself
+	queue := process && !utils.IsStringInSlice(folder, mailbox.SyncFolders)
+
+	results := make([]interfaces.ImportResult, len(messages))
+	var connectionErr error
+	imported := 0
+	for i, message := range messages {
+		results[i].Name = message.Name
+		if connectionErr != nil {
+			results[i].Error = connectionErr.Error()
+			continue
+		}
+
+		uid, err := appendMessage(c, folder, message)
+		if err != nil {
+			tracing.TraceErr(span, fmt.Errorf("failed to import %s: %w", message.Name, err))
+			results[i].Error = err.Error()
+			if isConnectionError(err) {
+				connectionErr = err
+			}
+			continue
+		}
+		imported++
+		results[i].Imported = true
+		results[i].UID = uid
+
+		if queue && uid > 0 {
+			err = s.events.Publisher.PublishRecieveEmailEvent(ctx, dto.EmailReceived{
+				Source:    enum.EmailImportIMAP,
+				MailboxID: mailbox.ID,
+				Folder:    folder,
+				ImapUID:   uid,
+			})
+			recordQueued(err)
+			if err != nil {
+				tracing.TraceErr(span, fmt.Errorf("failed to queue imported uid %d: %w", uid, err))
+				results[i].Error = "imported but not queued for processing"
+				continue
+			}
+			results[i].Queued = true
+		}
+	}
+
+	span.LogFields(tracingLog.Int("imported", imported))
+	return results, nil
+}
+
+// appendMessage appends a message and returns its UID from the APPENDUID response code, 0 when
+// the server does not support UIDPLUS
+func appendMessage(c *client.Client, folder string, message interfaces.ImportMessage) (uint32, error) {
+	status, err := c.Execute(&commands.Append{
+		Mailbox: folder,
+		Flags:   message.Flags,
+		Date:    message.Date,
+		Message: bytes.NewBuffer(message.Raw),
+	}, nil)
+	if err != nil {
+		return 0, err
+	}
+	if err = status.Err(); err != nil {
+		return 0, err
+	}
+	return appendedUID(status), nil
+}
+
+// appendedUID reads the UID from a "[APPENDUID <uidvalidity> <uid>]" response code
+func appendedUID(status *imap.StatusResp) uint32 {
+	if status.Code != "APPENDUID" || len(status.Arguments) < 2 {
+		return 0
+	}
+	uid, err := imap.ParseNumber(status.Arguments[1])
+	if err != nil {
+		return 0
+	}
+	return uid
+}
@@ -0,0 +1,14 @@
+package imap
+
+import (
+	"testing"
+
+	"github.com/emersion/go-imap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppendedUID(t *testing.T) {
+	assert.Equal(t, uint32(4021), appendedUID(&imap.StatusResp{Type: imap.StatusRespOk, Code: "APPENDUID", Arguments: []interface{}{"38505", "4021"}}))
+	assert.Equal(t, uint32(7), appendedUID(&imap.StatusResp{Type: imap.StatusRespOk, Code: "APPENDUID", Arguments: []interface{}{uint32(1), uint32(7)}}))
+	assert.Zero(t, appendedUID(&imap.StatusResp{Type: imap.StatusRespOk, Info: "APPEND completed"}))
+}
@@ -25,13 +25,14 @@ func (s *IMAPService) performInitialSync(
 	ctx context.Context,
 	c *client.Client,
 	mailboxID, folderName string,
+	settings syncSettings,
 ) error {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "IMAPService.performInitialSync")
 	defer span.Finish()
 	tracing.SetDefaultServiceSpanTags(ctx, span)
 
 	// Get all UIDs that need to be synced
-	syncState, uidsToProcess, err := s.getUIDsToSync(ctx, c, mailboxID, folderName)
+	syncState, uidsToProcess, err := s.getUIDsToSync(ctx, c, mailboxID, folderName, settings.maxTotal)
 	if err != nil {
 		tracing.TraceErr(span, err)
 		return err
@@ -42,20 +43,41 @@ func (s *IMAPService) performInitialSync(
 		return err
 	}
 
+	// a resumed sync keeps counting from where the interrupted one stopped
+	if syncState.LastUID == 0 {
+		syncState.InitialSyncProcessed = 0
+	}
+	syncState.InitialSyncTotal = syncState.InitialSyncProcessed + len(uidsToProcess)
+	syncState.InitialSyncComplete = false
+
 	if len(uidsToProcess) == 0 {
 		log.Printf("[%s][%s] No messages to sync", mailboxID, folderName)
+		syncState.InitialSyncComplete = true
+		s.saveInitialSyncProgress(ctx, syncState)
 		return nil
 	}
 
 	totalMessagesToProcess := len(uidsToProcess)
 	log.Printf("[%s][%s] Starting initial sync of %d messages", mailboxID, folderName, totalMessagesToProcess)
+	s.saveInitialSyncProgress(ctx, syncState)
 
 	// Process in batches
-	return s.processBatches(ctx, c, *syncState, uidsToProcess, totalMessagesToProcess)
+	return s.processBatches(ctx, c, *syncState, uidsToProcess, totalMessagesToProcess, settings.batchSize)
+}
+
+// saveInitialSyncProgress persists the initial sync progress and exposes it in the mailbox status
+func (s *IMAPService) saveInitialSyncProgress(ctx context.Context, syncState *models.MailboxSyncState) {
+	s.recordInitialSyncProgress(syncState)
+
+	err := s.repositories.MailboxSyncRepository.SaveInitialSyncProgress(ctx, syncState)
+	if err != nil {
+		// progress is informational, the sync goes on without it
+		log.Printf("[%s][%s] Error saving initial sync progress: %v", syncState.MailboxID, syncState.FolderName, err)
+	}
 }
 
 // getUIDsToSync returns a slice of UIDs that need to be synced
-func (s *IMAPService) getUIDsToSync(ctx context.Context, c *client.Client, mailboxID, folderName string,
+func (s *IMAPService) getUIDsToSync(ctx context.Context, c *client.Client, mailboxID, folderName string, maxToProcess int,
 ) (*models.MailboxSyncState, []uint32, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "IMAPService.getUIDsToSync")
 	defer span.Finish()
@@ -82,8 +104,20 @@ func (s *IMAPService) getUIDsToSync(ctx context.Context, c *client.Client, mailb
 		return nil, nil, err
 	}
 
+	if syncState == nil {
+		syncState = &models.MailboxSyncState{
+			MailboxID:  mailboxID,
+			FolderName: folderName,
+		}
+		err = s.repositories.MailboxSyncRepository.SaveSyncState(ctx, syncState)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return nil, nil, err
+		}
+	}
+
 	if len(allUIDs) == 0 {
-		return nil, nil, nil
+		return syncState, nil, nil
 	}
 
 	// Sort UIDs in ascending order (oldest first)
@@ -106,7 +140,6 @@ func (s *IMAPService) getUIDsToSync(ctx context.Context, c *client.Client, mailb
 	}
 
 	// Limit the number of messages if needed
-	maxToProcess := INITIAL_SYNC_MAX_TOTAL
 	if len(uidsToProcess) > maxToProcess {
 		log.Printf("[%s][%s] Limiting initial sync to %d of %d messages",
 			mailboxID, folderName, maxToProcess, len(uidsToProcess))
@@ -123,12 +156,12 @@ func (s *IMAPService) processBatches(
 	syncState models.MailboxSyncState,
 	uidsToProcess []uint32,
 	totalMessagesToProcess int,
+	batchSize int,
 ) error {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "IMAPService.processBatches")
 	defer span.Finish()
 	tracing.SetDefaultServiceSpanTags(ctx, span)
 
-	batchSize := INITIAL_SYNC_BATCH_SIZE
 	processedCount := 0
 
 	for i := 0; i < len(uidsToProcess); i += batchSize {
@@ -157,6 +190,7 @@ func (s *IMAPService) processBatches(
 
 		// Update processed count
 		processedCount += batchMessageCount
+		syncState.InitialSyncProcessed += batchMessageCount
 
 		log.Printf("[%s][%s] Successfully processed %d messages in batch (%d/%d total)",
 			syncState.MailboxID, syncState.FolderName, batchMessageCount, processedCount, totalMessagesToProcess)
@@ -173,6 +207,7 @@ func (s *IMAPService) processBatches(
 			log.Printf("[%s][%s] Saved batch progress (UID %d, %d/%d messages)",
 				syncState.MailboxID, syncState.FolderName, batchHighestUID, processedCount, totalMessagesToProcess)
 		}
+		s.saveInitialSyncProgress(ctx, &syncState)
 
 		// Add a small delay between batches
 		select {
@@ -182,6 +217,9 @@ func (s *IMAPService) processBatches(
 		}
 	}
 
+	syncState.InitialSyncComplete = true
+	s.saveInitialSyncProgress(ctx, &syncState)
+
 	log.Printf("[%s][%s] Completed initial sync of %d messages",
 		syncState.MailboxID, syncState.FolderName, processedCount)
 	return nil
@@ -224,7 +262,7 @@ func (s *IMAPService) processSingleBatch(
 		done <- c.UidFetch(seqSet, items, messages)
 	}()
 
-	messageCount := s.processMessages(ctx, c, mailboxID, folderName, messages, &wg, eventErrors)
+	messageCount := s.processMessages(ctx, c, mailboxID, folderName, messages, &wg, eventErrors, max(1, len(batchUIDs)/2))
 
 	// Reset IMAP timeout
 	c.Timeout = 0
@@ -254,9 +292,10 @@ func (s *IMAPService) processMessages(
 	messages <-chan *imap.Message,
 	wg *sync.WaitGroup,
 	eventErrors chan<- error,
+	concurrency int,
 ) int {
 	// Create a semaphore to limit concurrent goroutines
-	sem := make(chan struct{}, INITIAL_SYNC_BATCH_SIZE/2)
+	sem := make(chan struct{}, concurrency)
 	messageCount := 0
 
 	for msg := range messages {
@@ -294,7 +333,7 @@ func (s *IMAPService) processMessages(
 					}
 				}()
 
-				s.events.Publisher.PublishRecieveEmailEvent(eventCtx, dto.EmailReceived{
+				err := s.events.Publisher.PublishRecieveEmailEvent(eventCtx, dto.EmailReceived{
 					Source:      enum.EmailImportIMAP,
 					MailboxID:   mailboxID,
 					Folder:      folderName,
@@ -302,6 +341,14 @@ func (s *IMAPService) processMessages(
 					ImapSeqNum:  msg.SeqNum,
 					ImapUID:     msg.Uid,
 				})
+				recordQueued(err)
+				if err != nil {
+					select {
+					case eventErrors <- fmt.Errorf("failed to publish message uid %d: %w", msg.Uid, err):
+					default:
+						log.Printf("[%s][%s] Failed to send error: %v", mailboxID, folderName, err)
+					}
+				}
 			}()
 		}(msg)
 	}
@@ -0,0 +1,46 @@
+package imap
+
+import (
+	"context"
+	"sync"
+)
+
+// connectionLimiter caps the mailboxes connected at the same time, the others wait for a slot
+// in the order they asked for one. A nil limiter does not limit.
+type connectionLimiter struct {
+	slots chan struct{}
+}
+
+func newConnectionLimiter(maxConnections int) *connectionLimiter {
+	if maxConnections <= 0 {
+		return nil
+	}
+	return &connectionLimiter{slots: make(chan struct{}, maxConnections)}
+}
+
+// acquire blocks until a slot is free or the context is done. The returned release may be
+// called more than once, only the first call frees the slot.
+func (l *connectionLimiter) acquire(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { <-l.slots })
+	}, nil
+}
+
+// inUse returns the number of taken slots
+func (l *connectionLimiter) inUse() int {
+	if l == nil {
+		return 0
+	}
+	return len(l.slots)
+}
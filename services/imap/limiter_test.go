@@ -0,0 +1,57 @@
+package imap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectionLimiter(t *testing.T) {
+	t.Run("unlimited", func(t *testing.T) {
+		limiter := newConnectionLimiter(0)
+		release, err := limiter.acquire(context.Background())
+		require.NoError(t, err)
+		release()
+		assert.Equal(t, 0, limiter.inUse())
+	})
+
+	t.Run("waits for a released slot", func(t *testing.T) {
+		limiter := newConnectionLimiter(1)
+		release, err := limiter.acquire(context.Background())
+		require.NoError(t, err)
+
+		acquired := make(chan struct{})
+		go func() {
+			next, err := limiter.acquire(context.Background())
+			if err == nil {
+				defer next()
+			}
+			close(acquired)
+		}()
+
+		select {
+		case <-acquired:
+			t.Fatal("second mailbox got a slot while the first one holds it")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		release()
+		release() // a second release must not free another slot
+		<-acquired
+	})
+
+	t.Run("gives up when the mailbox stops", func(t *testing.T) {
+		limiter := newConnectionLimiter(1)
+		_, err := limiter.acquire(context.Background())
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err = limiter.acquire(ctx)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, 1, limiter.inUse())
+	})
+}
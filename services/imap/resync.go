@@ -0,0 +1,151 @@
+package imap
+
+import (
+	"context"
+
+	"github.com/opentracing/opentracing-go"
+	tracingLog "github.com/opentracing/opentracing-go/log"
+
+	er "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+type resyncJob struct {
+	id      string
+	folders []string
+}
+
+// Resync resets the sync state of a mailbox folder, or of all its sync folders when folderName is
+// empty, and restarts the mailbox so the initial sync runs again. It returns the job id right
+// away, the reset is stored once the sync loop has exited and progress is reported in the mailbox
+// status. Only one resync per mailbox runs at a time.
+func (s *IMAPService) Resync(ctx context.Context, mailboxID, folderName string) (string, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "IMAPService.Resync")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.SetTag("mailbox_id", mailboxID)
+	span.LogFields(tracingLog.String("folder", folderName))
+
+	job, stopped, err := s.startResync(mailboxID, folderName)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return "", err
+	}
+	span.LogFields(tracingLog.String("job_id", job.id))
+
+	// the job outlives the request that started it
+	go s.completeResync(context.WithoutCancel(ctx), mailboxID, job, stopped)
+
+	return job.id, nil
+}
+
+// completeResync stores the reset state once the stopped sync loop has exited and starts the
+// sync loop again. A failed reset ends the job so it can be started again.
+func (s *IMAPService) completeResync(ctx context.Context, mailboxID string, job resyncJob, stopped <-chan struct{}) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "IMAPService.completeResync")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.SetTag("mailbox_id", mailboxID)
+	span.LogFields(tracingLog.String("job_id", job.id))
+
+	// the old sync loop saves its state until it exits, the reset must be written after that
+	if stopped != nil {
+		<-stopped
+	}
+	err := s.resetSyncState(ctx, mailboxID, job)
+
+	s.clientsMutex.Lock()
+	defer s.clientsMutex.Unlock()
+
+	if err != nil {
+		tracing.TraceErr(span, err)
+		if current, running := s.resyncs[mailboxID]; running && current.id == job.id {
+			delete(s.resyncs, mailboxID)
+		}
+	}
+
+	// the sync loop picks up the reset state when it starts again, unless the mailbox was removed
+	if config, exists := s.mailboxConfigs[mailboxID]; exists {
+		s.startMonitoring(mailboxID, config)
+	}
+}
+
+// startResync registers the resync job and stops the sync loop of the mailbox. It returns the
+// channel closed once the loop has exited.
+func (s *IMAPService) startResync(mailboxID, folderName string) (resyncJob, <-chan struct{}, error) {
+	s.clientsMutex.Lock()
+	defer s.clientsMutex.Unlock()
+
+	config, exists := s.mailboxConfigs[mailboxID]
+	if !exists {
+		return resyncJob{}, nil, er.ErrMailboxNotFound
+	}
+
+	folders := config.SyncFolders
+	if folderName != "" {
+		if !utils.IsStringInSlice(folderName, config.SyncFolders) {
+			return resyncJob{}, nil, er.ErrSyncFolderNotFound
+		}
+		folders = []string{folderName}
+	}
+
+	if job, running := s.resyncs[mailboxID]; running && !s.resyncDone(mailboxID, job) {
+		return resyncJob{}, nil, er.ErrResyncInProgress
+	}
+
+	job := resyncJob{
+		id:      utils.GenerateNanoIDWithPrefix("resync", 12),
+		folders: folders,
+	}
+	s.resyncs[mailboxID] = job
+
+	// the folders show as not synced right away, so the job counts as running from here on
+	for _, folder := range folders {
+		s.recordInitialSyncProgress(&models.MailboxSyncState{MailboxID: mailboxID, FolderName: folder})
+	}
+
+	s.statusMutex.Lock()
+	status := s.statuses[mailboxID]
+	status.ResyncJobID = job.id
+	s.statuses[mailboxID] = status
+	s.statusMutex.Unlock()
+
+	return job, s.stopMonitoring(mailboxID), nil
+}
+
+// resetSyncState stores the reset state of the folders of the job
+func (s *IMAPService) resetSyncState(ctx context.Context, mailboxID string, job resyncJob) error {
+	for _, folder := range job.folders {
+		syncState := &models.MailboxSyncState{
+			MailboxID:  mailboxID,
+			FolderName: folder,
+			LastUID:    0,
+		}
+		err := s.repositories.MailboxSyncRepository.SaveSyncState(ctx, syncState)
+		if err != nil {
+			return err
+		}
+		err = s.repositories.MailboxSyncRepository.SaveInitialSyncProgress(ctx, syncState)
+		if err != nil {
+			return err
+		}
+		s.recordInitialSyncProgress(syncState)
+	}
+	return nil
+}
+
+// resyncDone tells whether every folder of the job finished its initial sync again
+func (s *IMAPService) resyncDone(mailboxID string, job resyncJob) bool {
+	s.statusMutex.RLock()
+	defer s.statusMutex.RUnlock()
+
+	folders := s.statuses[mailboxID].Folders
+	for _, folder := range job.folders {
+		if !folders[folder].InitialSync.Complete {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,164 @@
+package imap
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/interfaces"
+	er "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/repository"
+)
+
+type fakeResyncStateRepository struct {
+	interfaces.MailboxSyncRepository
+	mu      sync.Mutex
+	saved   []string
+	failing bool
+}
+
+func (r *fakeResyncStateRepository) SaveSyncState(_ context.Context, state *models.MailboxSyncState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failing {
+		return errors.New("connection reset")
+	}
+	r.saved = append(r.saved, state.FolderName)
+	return nil
+}
+
+func (r *fakeResyncStateRepository) SaveInitialSyncProgress(context.Context, *models.MailboxSyncState) error {
+	return nil
+}
+
+func (r *fakeResyncStateRepository) savedFolders() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.saved...)
+}
+
+// newResyncService has mbox_1 syncing INBOX and Sent. The service is not started, so no
+// sync loop runs unless the test installs one.
+func newResyncService() (*IMAPService, *fakeResyncStateRepository) {
+	syncStates := &fakeResyncStateRepository{}
+	s := NewIMAPService(nil, &repository.Repositories{MailboxSyncRepository: syncStates}, nil).(*IMAPService)
+	s.mailboxConfigs["mbox_1"] = &models.Mailbox{ID: "mbox_1", SyncFolders: []string{"INBOX", "Sent"}}
+	return s, syncStates
+}
+
+// installMonitor stands in for a sync loop that only exits when exit is closed
+func installMonitor(s *IMAPService, mailboxID string) (stopped context.Context, exit chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	exit = make(chan struct{})
+	s.monitors[mailboxID] = &mailboxMonitor{cancel: cancel, done: exit}
+	return ctx, exit
+}
+
+func completeInitialSync(s *IMAPService, mailboxID string, folders ...string) {
+	for _, folder := range folders {
+		s.recordInitialSyncProgress(&models.MailboxSyncState{MailboxID: mailboxID, FolderName: folder, InitialSyncComplete: true})
+	}
+}
+
+// resyncRunning tells whether a resync job of the mailbox is registered
+func resyncRunning(s *IMAPService, mailboxID string) bool {
+	s.clientsMutex.Lock()
+	defer s.clientsMutex.Unlock()
+	_, running := s.resyncs[mailboxID]
+	return running
+}
+
+func TestResyncReturnsBeforeTheSyncLoopExits(t *testing.T) {
+	s, syncStates := newResyncService()
+	stopped, exit := installMonitor(s, "mbox_1")
+
+	jobID, err := s.Resync(context.Background(), "mbox_1", "")
+	require.NoError(t, err)
+	assert.NotEmpty(t, jobID)
+	assert.Equal(t, jobID, s.Status()["mbox_1"].ResyncJobID)
+
+	<-stopped.Done()
+	// the loop could still save its state, nothing is reset before it exits
+	assert.Never(t, func() bool { return len(syncStates.savedFolders()) > 0 }, 50*time.Millisecond, 5*time.Millisecond)
+
+	_, err = s.Resync(context.Background(), "mbox_1", "INBOX")
+	assert.ErrorIs(t, err, er.ErrResyncInProgress)
+
+	close(exit)
+	assert.Eventually(t, func() bool { return len(syncStates.savedFolders()) == 2 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"INBOX", "Sent"}, syncStates.savedFolders())
+	assert.Equal(t, jobID, s.Status()["mbox_1"].ResyncJobID)
+}
+
+func TestResyncInProgressGuard(t *testing.T) {
+	s, syncStates := newResyncService()
+	completeInitialSync(s, "mbox_1", "INBOX", "Sent")
+
+	jobID, err := s.Resync(context.Background(), "mbox_1", "INBOX")
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool { return len(syncStates.savedFolders()) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"INBOX"}, syncStates.savedFolders())
+
+	_, err = s.Resync(context.Background(), "mbox_1", "")
+	assert.ErrorIs(t, err, er.ErrResyncInProgress)
+
+	// one resync per mailbox, whatever the folder
+	_, err = s.Resync(context.Background(), "mbox_1", "Sent")
+	assert.ErrorIs(t, err, er.ErrResyncInProgress)
+
+	completeInitialSync(s, "mbox_1", "INBOX")
+	nextJobID, err := s.Resync(context.Background(), "mbox_1", "Sent")
+	require.NoError(t, err)
+	assert.NotEqual(t, jobID, nextJobID)
+}
+
+func TestResyncRejectsUnknownMailboxAndFolder(t *testing.T) {
+	s, syncStates := newResyncService()
+
+	_, err := s.Resync(context.Background(), "mbox_unknown", "")
+	assert.ErrorIs(t, err, er.ErrMailboxNotFound)
+
+	_, err = s.Resync(context.Background(), "mbox_1", "Archive")
+	assert.ErrorIs(t, err, er.ErrSyncFolderNotFound)
+
+	assert.Empty(t, syncStates.savedFolders())
+	assert.Empty(t, s.resyncs)
+}
+
+func TestResyncFailureEndsTheJob(t *testing.T) {
+	s, syncStates := newResyncService()
+	syncStates.mu.Lock()
+	syncStates.failing = true
+	syncStates.mu.Unlock()
+
+	_, err := s.Resync(context.Background(), "mbox_1", "")
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool { return !resyncRunning(s, "mbox_1") }, time.Second, 5*time.Millisecond)
+
+	syncStates.mu.Lock()
+	syncStates.failing = false
+	syncStates.mu.Unlock()
+	_, err = s.Resync(context.Background(), "mbox_1", "")
+	assert.NoError(t, err)
+}
+
+func TestResyncOutlivesTheRequest(t *testing.T) {
+	s, syncStates := newResyncService()
+	stopped, exit := installMonitor(s, "mbox_1")
+	ctx, cancel := context.WithCancel(context.Background())
+
+	_, err := s.Resync(ctx, "mbox_1", "")
+	require.NoError(t, err)
+	<-stopped.Done()
+	cancel()
+
+	close(exit)
+	assert.Eventually(t, func() bool { return len(syncStates.savedFolders()) == 2 }, time.Second, 5*time.Millisecond)
+	assert.True(t, resyncRunning(s, "mbox_1"))
+}
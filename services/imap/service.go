@@ -3,6 +3,7 @@ package imap
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"log"
 	"net"
@@ -18,7 +19,10 @@ import (
 
 	"github.com/customeros/mailstack/dto"
 	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/config"
 	"github.com/customeros/mailstack/internal/enum"
+	mailstack_errors "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/metrics"
 	"github.com/customeros/mailstack/internal/models"
 	"github.com/customeros/mailstack/internal/repository"
 	"github.com/customeros/mailstack/internal/tracing"
@@ -26,36 +30,89 @@ import (
 	"github.com/customeros/mailstack/services/events"
 )
 
+// mailboxMonitor is the sync loop of a mailbox, done is closed once the loop has exited
+type mailboxMonitor struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
 type IMAPService struct {
 	events         *events.EventsService
 	repositories   *repository.Repositories
 	clients        map[string]*client.Client
 	mailboxConfigs map[string]*models.Mailbox
+	monitors       map[string]*mailboxMonitor
 	clientsMutex   sync.RWMutex
 	wg             sync.WaitGroup
 	ctx            context.Context
 	cancel         context.CancelFunc
 	statuses       map[string]interfaces.MailboxStatus
 	statusMutex    sync.RWMutex
+	resyncs        map[string]resyncJob
+	connections    *connectionLimiter
+	// folders of a mailbox synced at the same time, each over its own connection
+	folderConcurrency int
 }
 
-func NewIMAPService(events *events.EventsService, repos *repository.Repositories) interfaces.IMAPService {
+func NewIMAPService(events *events.EventsService, repos *repository.Repositories, cfg *config.IMAPConfig) interfaces.IMAPService {
+	var maxConnections int
+	folderConcurrency := 1
+	if cfg != nil {
+		maxConnections = cfg.MaxConcurrentConnections
+		if cfg.FolderConcurrency > 1 {
+			folderConcurrency = cfg.FolderConcurrency
+		}
+	}
+
 	return &IMAPService{
 		events:         events,
 		repositories:   repos,
 		clients:        make(map[string]*client.Client),
 		mailboxConfigs: make(map[string]*models.Mailbox),
+		monitors:       make(map[string]*mailboxMonitor),
 		statuses:       make(map[string]interfaces.MailboxStatus),
+		resyncs:        make(map[string]resyncJob),
+		connections:    newConnectionLimiter(maxConnections),
+
+		folderConcurrency: folderConcurrency,
 	}
 }
 
 const (
-	DEFAULT_IMAP_LOGOUT     = 25 // minutes
-	DEFAULT_POLLING_PERIOD  = 20 // minutes
-	INITIAL_SYNC_BATCH_SIZE = 20
-	INITIAL_SYNC_MAX_TOTAL  = 50000
+	DEFAULT_IMAP_LOGOUT    = 25 // minutes
+	DEFAULT_POLLING_PERIOD = 20 // minutes
 )
 
+// syncSettings are the per-mailbox sync limits, resolved against the defaults
+type syncSettings struct {
+	batchSize    int
+	maxTotal     int
+	pollInterval time.Duration
+}
+
+// mailboxSyncSettings falls back to the defaults for unset or out of range values. The poll
+// interval stays below the 4 minute idle threshold, above it every tick would only send a NOOP.
+func mailboxSyncSettings(config *models.Mailbox) syncSettings {
+	settings := syncSettings{
+		batchSize:    models.DefaultSyncBatchSize,
+		maxTotal:     models.DefaultSyncMaxMessages,
+		pollInterval: models.DefaultSyncPollIntervalSeconds * time.Second,
+	}
+	if config == nil {
+		return settings
+	}
+	if config.SyncBatchSize > 0 && config.SyncBatchSize <= models.MaxSyncBatchSize {
+		settings.batchSize = config.SyncBatchSize
+	}
+	if config.SyncMaxMessages > 0 && config.SyncMaxMessages <= models.MaxSyncMaxMessages {
+		settings.maxTotal = config.SyncMaxMessages
+	}
+	if config.SyncPollIntervalSeconds >= models.MinSyncPollIntervalSeconds && config.SyncPollIntervalSeconds <= models.MaxSyncPollIntervalSeconds {
+		settings.pollInterval = time.Duration(config.SyncPollIntervalSeconds) * time.Second
+	}
+	return settings
+}
+
 // Start initializes the service and connects to mailboxes
 func (s *IMAPService) Start(ctx context.Context) error {
 	span, ctx := tracing.StartTracerSpan(ctx, "IMAPService.Start")
@@ -66,18 +123,55 @@ func (s *IMAPService) Start(ctx context.Context) error {
 	span.LogFields(tracingLog.Int("mailbox_count", len(s.mailboxConfigs)))
 
 	// Start each mailbox sequentially for easier debugging
+	s.clientsMutex.Lock()
 	for id, config := range s.mailboxConfigs {
-		// Create a mailbox-specific context with tenant information
-		// but don't create a span here since we're passing to a goroutine
-		mailboxCtx := utils.SetTenantInContext(ctx, config.Tenant)
-
 		log.Printf("Starting mailbox: %s (%s)", id, config.ImapUsername)
-		go s.runSingleMailbox(mailboxCtx, id, config)
+		s.startMonitoring(id, config)
 	}
+	s.clientsMutex.Unlock()
 
 	return nil
 }
 
+// startMonitoring runs the sync loop of a mailbox until the service stops or the
+// mailbox is removed. Must be called with clientsMutex held.
+func (s *IMAPService) startMonitoring(mailboxID string, config *models.Mailbox) {
+	if s.ctx == nil {
+		return
+	}
+	if monitor, exists := s.monitors[mailboxID]; exists {
+		monitor.cancel()
+	}
+
+	// mailbox-specific context with tenant information, no span since it is passed to a goroutine
+	mailboxCtx, cancel := context.WithCancel(utils.SetTenantInContext(s.ctx, config.Tenant))
+	monitor := &mailboxMonitor{cancel: cancel, done: make(chan struct{})}
+	s.monitors[mailboxID] = monitor
+	s.initStatus(mailboxID)
+	go func() {
+		defer close(monitor.done)
+		s.runSingleMailbox(mailboxCtx, mailboxID, config)
+	}()
+}
+
+// stopMonitoring stops the sync loop of a mailbox and logs out its client. The loop exits in the
+// background, the returned channel is closed once it has, it is nil when no loop was running.
+// Must be called with clientsMutex held.
+func (s *IMAPService) stopMonitoring(mailboxID string) <-chan struct{} {
+	var done <-chan struct{}
+	if monitor, exists := s.monitors[mailboxID]; exists {
+		monitor.cancel()
+		delete(s.monitors, mailboxID)
+		done = monitor.done
+	}
+	if client, exists := s.clients[mailboxID]; exists {
+		client.Timeout = 5 * time.Second
+		go client.Logout() // Ignore errors in a goroutine
+		delete(s.clients, mailboxID)
+	}
+	return done
+}
+
 // Stop gracefully shuts down the service
 func (s *IMAPService) Stop() error {
 	log.Println("Stopping IMAP service...")
@@ -175,9 +269,39 @@ func (s *IMAPService) AddMailbox(ctx context.Context, config *models.Mailbox) er
 	s.mailboxConfigs[config.ID] = config
 
 	// Start monitoring if service is running
-	if s.ctx != nil {
-		go s.runSingleMailbox(s.ctx, config.ID, config)
+	s.startMonitoring(config.ID, config)
+
+	return nil
+}
+
+// ReloadMailbox drops the connection of a mailbox and starts it again with the new
+// configuration, e.g. after a password change. Sync progress is kept.
+func (s *IMAPService) ReloadMailbox(ctx context.Context, config *models.Mailbox) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "IMAPService.ReloadMailbox")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+
+	if config == nil {
+		err := errors.New("config is nil")
+		tracing.TraceErr(span, err)
+		return err
 	}
+	span.SetTag("mailbox_id", config.ID)
+
+	s.clientsMutex.Lock()
+	_, exists := s.mailboxConfigs[config.ID]
+	s.clientsMutex.Unlock()
+	if !exists {
+		span.LogFields(tracingLog.Bool("mailbox.registered", false))
+		return s.AddMailbox(ctx, config)
+	}
+
+	s.clientsMutex.Lock()
+	defer s.clientsMutex.Unlock()
+
+	s.stopMonitoring(config.ID)
+	s.mailboxConfigs[config.ID] = config
+	s.startMonitoring(config.ID, config)
 
 	return nil
 }
@@ -191,22 +315,28 @@ func (s *IMAPService) RemoveMailbox(ctx context.Context, mailboxID string) error
 	s.clientsMutex.Lock()
 	defer s.clientsMutex.Unlock()
 
-	// Disconnect if connected
-	if client, exists := s.clients[mailboxID]; exists {
-		client.Logout()
-		delete(s.clients, mailboxID)
-	}
+	// Stop monitoring and disconnect if connected
+	s.stopMonitoring(mailboxID)
 
 	// Remove configuration
 	delete(s.mailboxConfigs, mailboxID)
+	delete(s.resyncs, mailboxID)
 	err := s.repositories.MailboxSyncRepository.DeleteMailboxSyncStates(ctx, mailboxID)
 	if err != nil {
 		tracing.TraceErr(span, err)
 		return err
 	}
+	err = s.repositories.MailboxFolderStatsRepository.DeleteByMailbox(ctx, mailboxID)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
 
 	// Remove status
 	s.statusMutex.Lock()
+	if s.statuses[mailboxID].Connected {
+		metrics.IMAPConnections.Dec()
+	}
 	delete(s.statuses, mailboxID)
 	s.statusMutex.Unlock()
 
@@ -233,6 +363,15 @@ func (s *IMAPService) getConnectedClient(ctx context.Context, mailboxID string)
 		return nil, err
 	}
 
+	// Rejected credentials are not tried again until they are updated
+	s.statusMutex.RLock()
+	authFailed := s.statuses[mailboxID].AuthFailed
+	s.statusMutex.RUnlock()
+	if authFailed {
+		tracing.TraceErr(span, mailstack_errors.ErrIMAPAuthFailed)
+		return nil, mailstack_errors.ErrIMAPAuthFailed
+	}
+
 	// If we have an existing client, check if it's still connected
 	if exists {
 		// Perform a simple NOOP operation to check connection health
@@ -295,16 +434,21 @@ func (s *IMAPService) runSingleMailbox(ctx context.Context, mailboxID string, co
 
 	log.Printf("[%s] Starting mailbox monitoring with folders: %v", mailboxID, config.SyncFolders)
 
-	backoff := time.Second
-	maxBackoff := 2 * time.Minute
+	backoff := newReconnectBackoff(config)
 	attempts := 0
 
 	for {
-		if err := s.processSingleMailboxIteration(ctx, mailboxID, config, &attempts, &backoff, maxBackoff); err != nil {
+		if err := s.processSingleMailboxIteration(ctx, mailboxID, config, &attempts, backoff); err != nil {
 			// If context is cancelled, we should exit
 			if errors.Is(err, context.Canceled) {
 				return
 			}
+			// Retrying rejected credentials only gets the account locked, the mailbox is started
+			// again when its credentials are updated
+			if errors.Is(err, mailstack_errors.ErrIMAPAuthFailed) {
+				log.Printf("[%s] Authentication failed, stopping until the credentials are updated", mailboxID)
+				return
+			}
 			// Other errors are handled within processSingleMailboxIteration
 			continue
 		}
@@ -325,8 +469,7 @@ func (s *IMAPService) processSingleMailboxIteration(
 	mailboxID string,
 	config *models.Mailbox,
 	attempts *int,
-	backoff *time.Duration,
-	maxBackoff time.Duration,
+	backoff *reconnectBackoff,
 ) error {
 	// Create a new span for each iteration of the connection loop
 	span, ctx := tracing.StartTracerSpan(ctx, "IMAPService.processSingleMailboxIteration")
@@ -337,6 +480,9 @@ func (s *IMAPService) processSingleMailboxIteration(
 
 	*attempts++
 	log.Printf("[%s] Connection attempt #%d", mailboxID, *attempts)
+	if *attempts > 1 {
+		metrics.IMAPReconnects.Inc()
+	}
 
 	// Check if we should stop
 	select {
@@ -348,6 +494,16 @@ func (s *IMAPService) processSingleMailboxIteration(
 		// Continue processing
 	}
 
+	// Wait for a connection slot, held while connected and released before any backoff
+	s.setWaitingForSlot(mailboxID, true)
+	release, err := s.connections.acquire(ctx)
+	s.setWaitingForSlot(mailboxID, false)
+	if err != nil {
+		return err
+	}
+	defer release()
+	span.LogFields(tracingLog.Int("connections.inUse", s.connections.inUse()))
+
 	// Use connection timeout
 	connectCtx, connectCancel := context.WithTimeout(ctx, 1*time.Minute)
 	defer connectCancel()
@@ -357,22 +513,25 @@ func (s *IMAPService) processSingleMailboxIteration(
 	if err != nil {
 		log.Printf("[%s] Connection error: %v", mailboxID, err)
 		tracing.TraceErr(span, err)
+		release()
+
+		if errors.Is(err, mailstack_errors.ErrIMAPAuthFailed) {
+			s.markAuthFailed(mailboxID, err)
+			if statusErr := s.repositories.MailboxRepository.UpdateConnectionStatus(ctx, mailboxID, enum.ConnectionAuthFailed, err.Error()); statusErr != nil {
+				tracing.TraceErr(span, statusErr)
+			}
+			return err
+		}
+
+		s.markDisconnected(mailboxID, err)
 		err = s.repositories.MailboxRepository.UpdateConnectionStatus(ctx, mailboxID, enum.ConnectionNotActive, err.Error())
 		if err != nil {
 			tracing.TraceErr(span, err)
 		}
 
 		// Sleep with backoff before reconnecting
-		select {
-		case <-time.After(*backoff):
-			// Increase backoff for next attempt
-			*backoff = time.Duration(float64(*backoff) * 1.5)
-			if *backoff > maxBackoff {
-				*backoff = maxBackoff
-			}
-			log.Printf("[%s] Will retry in %v", mailboxID, *backoff)
-		case <-ctx.Done():
-			return ctx.Err()
+		if waitErr := waitBeforeReconnect(ctx, mailboxID, backoff); waitErr != nil {
+			return waitErr
 		}
 		return err
 	}
@@ -388,19 +547,21 @@ func (s *IMAPService) processSingleMailboxIteration(
 	s.clientsMutex.Unlock()
 
 	// Update status
+	s.markConnected(mailboxID)
 	err = s.repositories.MailboxRepository.UpdateConnectionStatus(ctx, mailboxID, enum.ConnectionActive, "")
 	if err != nil {
 		tracing.TraceErr(span, err)
 	}
 
-	// Reset backoff on successful connection
-	*backoff = time.Second
+	// The backoff starts over once the connection proves stable
+	backoff.connected(time.Now())
 
 	// Log the folders being processed
 	span.LogFields(tracingLog.String("folders", fmt.Sprintf("%v", config.SyncFolders)))
 
-	// Process each folder sequentially
-	_, connectivityError := s.syncFolders(ctx, client, config.ID, config.SyncFolders)
+	// Process the folders, spread over extra connections when folder concurrency is set
+	_, connectivityError := s.syncFolders(ctx, client, config, mailboxSyncSettings(config))
+	backoff.disconnected(time.Now())
 
 	// Handle connectivity errors
 	if connectivityError != nil {
@@ -408,13 +569,20 @@ func (s *IMAPService) processSingleMailboxIteration(
 		delete(s.clients, mailboxID)
 		s.clientsMutex.Unlock()
 
+		s.markDisconnected(mailboxID, connectivityError)
 		err = s.repositories.MailboxRepository.UpdateConnectionStatus(ctx, mailboxID, enum.ConnectionNotActive, connectivityError.Error())
 		if err != nil {
 			tracing.TraceErr(span, err)
 		}
 
 		tracing.TraceErr(span, connectivityError)
-		*backoff = 5 * time.Second
+		release()
+
+		// Sleep with backoff before reconnecting, after a server outage every mailbox gets here
+		// at once
+		if waitErr := waitBeforeReconnect(ctx, mailboxID, backoff); waitErr != nil {
+			return waitErr
+		}
 		return connectivityError
 	}
 
@@ -441,12 +609,16 @@ func (s *IMAPService) connectToIMAPServer(ctx context.Context, config *models.Ma
 	var c *client.Client
 	var err error
 
-	if config.ImapSecurity == enum.EmailSecurityTLS {
-		tlsConfig := &tls.Config{
-			ServerName: config.ImapServer,
-		}
+	tlsConfig, err := imapTLSConfig(config)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	switch config.ImapSecurity {
+	case enum.EmailSecurityTLS, enum.EmailSecuritySSL:
 		c, err = client.DialWithDialerTLS(dialer, serverAddr, tlsConfig)
-	} else {
+	default:
 		c, err = client.DialWithDialer(dialer, serverAddr)
 	}
 
@@ -456,6 +628,15 @@ func (s *IMAPService) connectToIMAPServer(ctx context.Context, config *models.Ma
 		return nil, err
 	}
 
+	// Upgrade the plaintext connection before any credentials are sent
+	if config.ImapSecurity == enum.EmailSecurityStartTLS {
+		if err = startTLS(c, tlsConfig); err != nil {
+			c.Logout()
+			tracing.TraceErr(span, err)
+			return nil, err
+		}
+	}
+
 	// Check capabilities
 	c.Timeout = 30 * time.Second
 	caps, err := c.Capability()
@@ -472,7 +653,11 @@ func (s *IMAPService) connectToIMAPServer(ctx context.Context, config *models.Ma
 	err = c.Login(config.ImapUsername, config.ImapPassword)
 	if err != nil {
 		c.Logout()
-		err := fmt.Errorf("login error: %w", err)
+		if isAuthFailure(err) {
+			err = fmt.Errorf("%w: %v", mailstack_errors.ErrIMAPAuthFailed, err)
+		} else {
+			err = fmt.Errorf("login error: %w", err)
+		}
 		tracing.TraceErr(span, err)
 		return nil, err
 	}
@@ -484,36 +669,80 @@ func (s *IMAPService) connectToIMAPServer(ctx context.Context, config *models.Ma
 	return c, nil
 }
 
-// syncFolders processes all folders and returns information about the sync process
+// imapTLSConfig builds the TLS configuration of a mailbox. Certificates are verified against the
+// system roots unless a CA bundle is configured or verification is explicitly skipped.
+func imapTLSConfig(config *models.Mailbox) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         config.ImapServer,
+		InsecureSkipVerify: config.ImapInsecureSkipVerify,
+	}
+
+	if strings.TrimSpace(config.ImapCACert) != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(config.ImapCACert)) {
+			return nil, errors.New("invalid IMAP CA certificate, expected PEM encoded certificates")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+// startTLS upgrades a plaintext connection, servers that do not advertise STARTTLS are refused
+// rather than continuing without encryption
+func startTLS(c *client.Client, tlsConfig *tls.Config) error {
+	supported, err := c.SupportStartTLS()
+	if err != nil {
+		return fmt.Errorf("capability error: %w", err)
+	}
+	if !supported {
+		return errors.New("server does not support STARTTLS")
+	}
+	if err = c.StartTLS(tlsConfig); err != nil {
+		return fmt.Errorf("starttls error: %w", err)
+	}
+	return nil
+}
+
+// syncFolders processes all sync folders of the mailbox and returns information about the sync
+// process. The folders are spread over up to folderConcurrency connections, the connected client
+// and extra ones opened for this sync, as a connection has only one folder selected at a time.
+// Extra connections wait for a slot of the connection limit like mailboxes do.
 func (s *IMAPService) syncFolders(
 	ctx context.Context,
-	client *client.Client,
-	mailboxID string,
-	folders []string,
+	c *client.Client,
+	config *models.Mailbox,
+	settings syncSettings,
 ) (processedFolders map[string]bool, connectivityError error) {
-	processedFolders = make(map[string]bool)
-
-	log.Printf("[%s] Starting sync for %d folders: %v", mailboxID, len(folders), folders)
+	log.Printf("[%s] Starting sync for %d folders: %v", config.ID, len(config.SyncFolders), config.SyncFolders)
 
-	for _, folder := range folders {
-		log.Printf("[%s] About to process folder: %s", mailboxID, folder)
-
-		err := s.processSingleFolder(ctx, client, mailboxID, folder)
+	connect := func(ctx context.Context, worker int) (*client.Client, func(), error) {
+		if worker == 0 {
+			return c, func() {}, nil
+		}
+		// extra connections take a slot of their own, the mailbox connection holds one already
+		release, err := s.connections.acquire(ctx)
 		if err != nil {
-			if isConnectionError(err) {
-				connectivityError = err
-				log.Printf("[%s][%s] Connection error, will stop processing folders", mailboxID, folder)
-				break
-			}
-
-			// Non-connectivity error, log and continue
-			log.Printf("[%s][%s] Non-connectivity error, continuing with other folders", mailboxID, folder)
+			return nil, nil, err
 		}
-
-		processedFolders[folder] = err == nil
+		connectCtx, cancel := context.WithTimeout(ctx, 1*time.Minute)
+		defer cancel()
+		extra, err := s.connectToIMAPServer(connectCtx, config)
+		if err != nil {
+			release()
+			return nil, nil, err
+		}
+		return extra, func() {
+			extra.Timeout = 5 * time.Second
+			_ = extra.Logout()
+			release()
+		}, nil
+	}
+	process := func(ctx context.Context, c *client.Client, folder string) error {
+		return s.processSingleFolder(ctx, c, config.ID, folder, settings)
 	}
 
-	return processedFolders, connectivityError
+	return syncFolderPool(ctx, config.ID, config.SyncFolders, s.folderConcurrency, connect, process)
 }
 
 // processSingleFolder handles the logic for processing a single folder
@@ -522,6 +751,7 @@ func (s *IMAPService) processSingleFolder(
 	client *client.Client,
 	mailboxID string,
 	folder string,
+	settings syncSettings,
 ) error {
 	folderSpan, folderCtx := opentracing.StartSpanFromContext(ctx, "IMAPService.processSingleFolder")
 	defer folderSpan.Finish()
@@ -533,7 +763,7 @@ func (s *IMAPService) processSingleFolder(
 	folderCtx, cancel := context.WithTimeout(ctx, 10*time.Minute)
 	defer cancel() // Ensure cancel is always called
 
-	err := s.processFolder(folderCtx, client, mailboxID, folder)
+	err := s.processFolder(folderCtx, client, mailboxID, folder, settings)
 	if err != nil {
 		log.Printf("[%s][%s] Error processing folder: %v", mailboxID, folder, err)
 		tracing.TraceErr(folderSpan, err)
@@ -546,7 +776,7 @@ func (s *IMAPService) processSingleFolder(
 }
 
 // processFolder handles a single IMAP folder
-func (s *IMAPService) processFolder(ctx context.Context, c *client.Client, mailboxID, folderName string) error {
+func (s *IMAPService) processFolder(ctx context.Context, c *client.Client, mailboxID, folderName string, settings syncSettings) error {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "IMAPService.processFolder")
 	defer span.Finish()
 	tracing.TagEntity(span, mailboxID)
@@ -580,10 +810,14 @@ func (s *IMAPService) processFolder(ctx context.Context, c *client.Client, mailb
 		return err
 	}
 
-	if syncState == nil || syncState.LastUID == 0 {
-		// Initial sync (no previous sync state or LastUID is 0)
+	if syncState != nil {
+		s.recordInitialSyncProgress(syncState)
+	}
+
+	if syncState == nil || syncState.LastUID == 0 || !syncState.InitialSyncComplete {
+		// Initial sync (no previous sync state, LastUID is 0 or an interrupted initial sync)
 		log.Printf("[%s][%s] Performing initial sync", mailboxID, folderName)
-		err = s.performInitialSync(ctx, c, mailboxID, folderName)
+		err = s.performInitialSync(ctx, c, mailboxID, folderName, settings)
 		if err != nil {
 			err = fmt.Errorf("error performing initial sync: %w", err)
 			tracing.TraceErr(span, err)
@@ -600,13 +834,15 @@ func (s *IMAPService) processFolder(ctx context.Context, c *client.Client, mailb
 		}
 	}
 
+	s.recordFolderStats(ctx, c, mailboxID, folderName, mbox)
+
 	// Use simple polling instead of IDLE for easier debugging
 	log.Printf("[%s][%s] Starting polling after sync", mailboxID, folderName)
-	return s.simplePolling(ctx, c, mailboxID, folderName)
+	return s.simplePolling(ctx, c, mailboxID, folderName, settings.pollInterval)
 }
 
 // simplePolling periodically checks for new messages
-func (s *IMAPService) simplePolling(ctx context.Context, c *client.Client, mailboxID, folderName string) error {
+func (s *IMAPService) simplePolling(ctx context.Context, c *client.Client, mailboxID, folderName string, pollInterval time.Duration) error {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "IMAPService.simplePolling")
 	defer span.Finish()
 	tracing.SetDefaultServiceSpanTags(ctx, span)
@@ -614,7 +850,7 @@ func (s *IMAPService) simplePolling(ctx context.Context, c *client.Client, mailb
 	log.Printf("[%s][%s] Starting simple polling", mailboxID, folderName)
 
 	// Use a shorter polling interval to keep the connection alive
-	ticker := time.NewTicker(30 * time.Second)
+	ticker := time.NewTicker(pollInterval)
 	defer ticker.Stop()
 
 	var lastCount uint32
@@ -680,6 +916,8 @@ func (s *IMAPService) simplePolling(ctx context.Context, c *client.Client, mailb
 				continue
 			}
 
+			s.recordFolderStats(ctx, c, mailboxID, folderName, mbox)
+
 			// Check for new messages (skip first run to establish baseline)
 			if !firstRun && mbox.Messages > lastCount {
 				newCount := mbox.Messages - lastCount
@@ -761,7 +999,7 @@ func (s *IMAPService) fetchNewMessages(
 	}()
 
 	// Process messages
-	var highestUID uint32
+	var highestUID, firstFailedUID uint32
 	messageCount := 0
 
 	for msg := range messages {
@@ -771,8 +1009,8 @@ func (s *IMAPService) fetchNewMessages(
 			highestUID = msg.Uid
 		}
 
-		// Process the message
-		s.events.Publisher.PublishRecieveEmailEvent(ctx, dto.EmailReceived{
+		// Queue the message for the receive email listener
+		err := s.events.Publisher.PublishRecieveEmailEvent(ctx, dto.EmailReceived{
 			Source:      enum.EmailImportIMAP,
 			MailboxID:   mailboxID,
 			Folder:      folderName,
@@ -780,6 +1018,18 @@ func (s *IMAPService) fetchNewMessages(
 			ImapUID:     msg.Uid,
 			InitialSync: false,
 		})
+		recordQueued(err)
+		if err != nil {
+			tracing.TraceErr(span, fmt.Errorf("failed to publish message uid %d: %w", msg.Uid, err))
+			if firstFailedUID == 0 || msg.Uid < firstFailedUID {
+				firstFailedUID = msg.Uid
+			}
+		}
+	}
+
+	// keep the synced UID below unpublished messages so the next sync fetches them again
+	if firstFailedUID > 0 && highestUID >= firstFailedUID {
+		highestUID = firstFailedUID - 1
 	}
 
 	// Reset timeout
@@ -877,7 +1127,7 @@ func (s *IMAPService) syncNewMessagesSince(
 	}()
 
 	// Process messages
-	var highestUID uint32
+	var highestUID, firstFailedUID uint32
 	messageCount := 0
 
 	for msg := range messages {
@@ -887,8 +1137,8 @@ func (s *IMAPService) syncNewMessagesSince(
 			highestUID = msg.Uid
 		}
 
-		// Process the message
-		s.events.Publisher.PublishRecieveEmailEvent(ctx, dto.EmailReceived{
+		// Queue the message for the receive email listener
+		err := s.events.Publisher.PublishRecieveEmailEvent(ctx, dto.EmailReceived{
 			Source:      enum.EmailImportIMAP,
 			MailboxID:   mailboxID,
 			Folder:      folderName,
@@ -896,6 +1146,18 @@ func (s *IMAPService) syncNewMessagesSince(
 			ImapUID:     msg.Uid,
 			InitialSync: false,
 		})
+		recordQueued(err)
+		if err != nil {
+			tracing.TraceErr(span, fmt.Errorf("failed to publish message uid %d: %w", msg.Uid, err))
+			if firstFailedUID == 0 || msg.Uid < firstFailedUID {
+				firstFailedUID = msg.Uid
+			}
+		}
+	}
+
+	// keep the synced UID below unpublished messages so the next sync fetches them again
+	if firstFailedUID > 0 && highestUID >= firstFailedUID {
+		highestUID = firstFailedUID - 1
 	}
 
 	// Reset timeout
@@ -0,0 +1,50 @@
+package imap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/customeros/mailstack/internal/models"
+)
+
+func TestImapTLSConfig(t *testing.T) {
+	config, err := imapTLSConfig(&models.Mailbox{ImapServer: "imap.example.com"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.InsecureSkipVerify || config.RootCAs != nil || config.ServerName != "imap.example.com" {
+		t.Errorf("expected strict verification against system roots, got %+v", config)
+	}
+
+	config, err = imapTLSConfig(&models.Mailbox{ImapServer: "imap.example.com", ImapInsecureSkipVerify: true})
+	if err != nil || !config.InsecureSkipVerify {
+		t.Errorf("expected verification to be skipped, got %+v, %v", config, err)
+	}
+
+	if _, err = imapTLSConfig(&models.Mailbox{ImapCACert: "not a certificate"}); err == nil {
+		t.Error("expected an error for an invalid CA bundle")
+	}
+}
+
+func TestMailboxSyncSettings(t *testing.T) {
+	settings := mailboxSyncSettings(&models.Mailbox{})
+	if settings.batchSize != models.DefaultSyncBatchSize || settings.maxTotal != models.DefaultSyncMaxMessages ||
+		settings.pollInterval != models.DefaultSyncPollIntervalSeconds*time.Second {
+		t.Errorf("expected defaults for unset values, got %+v", settings)
+	}
+
+	settings = mailboxSyncSettings(&models.Mailbox{SyncBatchSize: 5, SyncMaxMessages: 1000, SyncPollIntervalSeconds: 60})
+	if settings.batchSize != 5 || settings.maxTotal != 1000 || settings.pollInterval != time.Minute {
+		t.Errorf("expected configured values, got %+v", settings)
+	}
+
+	settings = mailboxSyncSettings(&models.Mailbox{
+		SyncBatchSize:           models.MaxSyncBatchSize + 1,
+		SyncMaxMessages:         -1,
+		SyncPollIntervalSeconds: models.MaxSyncPollIntervalSeconds + 1,
+	})
+	if settings.batchSize != models.DefaultSyncBatchSize || settings.maxTotal != models.DefaultSyncMaxMessages ||
+		settings.pollInterval != models.DefaultSyncPollIntervalSeconds*time.Second {
+		t.Errorf("expected defaults for out of range values, got %+v", settings)
+	}
+}
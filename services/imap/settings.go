@@ -0,0 +1,153 @@
+package imap
+
+import (
+	"context"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+	tracingLog "github.com/opentracing/opentracing-go/log"
+	"github.com/pkg/errors"
+
+	"github.com/customeros/mailstack/interfaces"
+	mailstack_errors "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+// UpdateMailboxSettings changes the sync folders, poll interval and IMAP credentials of a mailbox.
+// Folders no longer synced lose their sync state and stats, folders kept continue from their last
+// synced UID and new folders start an initial sync. A monitored mailbox restarts its sync with the
+// new settings, connecting again with the new credentials.
+func (s *IMAPService) UpdateMailboxSettings(ctx context.Context, mailbox *models.Mailbox, update interfaces.MailboxSettingsUpdate) (*models.Mailbox, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "IMAPService.UpdateMailboxSettings")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.SetTag("mailbox_id", mailbox.ID)
+
+	if mailbox.ImapServer == "" {
+		tracing.TraceErr(span, mailstack_errors.ErrIMAPNotConfigured)
+		return nil, mailstack_errors.ErrIMAPNotConfigured
+	}
+	updated, err := applySettingsUpdate(mailbox, update)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	added, removed := diffSyncFolders(mailbox.SyncFolders, updated.SyncFolders)
+	credentialsChanged := updated.ImapUsername != mailbox.ImapUsername || updated.ImapPassword != mailbox.ImapPassword
+	span.LogFields(
+		tracingLog.Object("folders.added", added),
+		tracingLog.Object("folders.removed", removed),
+		tracingLog.Bool("credentials.changed", credentialsChanged),
+	)
+	if len(added) == 0 && len(removed) == 0 && !credentialsChanged &&
+		updated.SyncPollIntervalSeconds == mailbox.SyncPollIntervalSeconds {
+		return mailbox, nil
+	}
+
+	if _, err = s.repositories.MailboxRepository.SaveMailbox(ctx, *updated); err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "failed to save mailbox settings"))
+		return nil, err
+	}
+
+	for _, folder := range removed {
+		if err = s.forgetSyncFolder(ctx, mailbox.ID, folder); err != nil {
+			tracing.TraceErr(span, err)
+			return nil, err
+		}
+	}
+	for _, folder := range added {
+		if err = s.initSyncFolder(ctx, mailbox.ID, folder); err != nil {
+			tracing.TraceErr(span, err)
+			return nil, err
+		}
+	}
+
+	s.clientsMutex.RLock()
+	_, monitored := s.mailboxConfigs[mailbox.ID]
+	s.clientsMutex.RUnlock()
+	if !monitored {
+		return updated, nil
+	}
+	if err = s.ReloadMailbox(ctx, updated); err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+	return updated, nil
+}
+
+// applySettingsUpdate validates the update and returns a copy of the mailbox with it applied
+func applySettingsUpdate(mailbox *models.Mailbox, update interfaces.MailboxSettingsUpdate) (*models.Mailbox, error) {
+	updated := *mailbox
+
+	if update.SyncFolders != nil {
+		folders := make([]string, 0, len(update.SyncFolders))
+		for _, folder := range update.SyncFolders {
+			if err := validateFolderName(folder); err != nil {
+				return nil, err
+			}
+			if !utils.IsStringInSlice(folder, folders) {
+				folders = append(folders, folder)
+			}
+		}
+		if len(folders) == 0 {
+			return nil, errors.Wrap(mailstack_errors.ErrInvalidSyncSettings, "at least one sync folder is required")
+		}
+		updated.SyncFolders = folders
+	}
+
+	if update.SyncPollIntervalSeconds != nil {
+		interval := *update.SyncPollIntervalSeconds
+		if interval < models.MinSyncPollIntervalSeconds || interval > models.MaxSyncPollIntervalSeconds {
+			return nil, errors.Wrapf(mailstack_errors.ErrInvalidSyncSettings, "poll interval must be between %d and %d seconds",
+				models.MinSyncPollIntervalSeconds, models.MaxSyncPollIntervalSeconds)
+		}
+		updated.SyncPollIntervalSeconds = interval
+	}
+
+	if update.ImapUsername != nil {
+		if strings.TrimSpace(*update.ImapUsername) == "" {
+			return nil, errors.Wrap(mailstack_errors.ErrInvalidSyncSettings, "imap username can not be empty")
+		}
+		updated.ImapUsername = strings.TrimSpace(*update.ImapUsername)
+	}
+	if update.ImapPassword != nil {
+		if *update.ImapPassword == "" {
+			return nil, errors.Wrap(mailstack_errors.ErrInvalidSyncSettings, "imap password can not be empty")
+		}
+		updated.ImapPassword = *update.ImapPassword
+	}
+
+	return &updated, nil
+}
+
+// diffSyncFolders returns the folders only in the new and only in the old sync folders
+func diffSyncFolders(current, next []string) (added, removed []string) {
+	for _, folder := range next {
+		if !utils.IsStringInSlice(folder, current) {
+			added = append(added, folder)
+		}
+	}
+	for _, folder := range current {
+		if !utils.IsStringInSlice(folder, next) {
+			removed = append(removed, folder)
+		}
+	}
+	return added, removed
+}
+
+// initSyncFolder adds the sync state a new sync folder starts its initial sync from, a state left
+// from an earlier sync of the folder is kept
+func (s *IMAPService) initSyncFolder(ctx context.Context, mailboxID, folder string) error {
+	state, err := s.repositories.MailboxSyncRepository.GetSyncState(ctx, mailboxID, folder)
+	if err != nil || state != nil {
+		return err
+	}
+	return s.repositories.MailboxSyncRepository.SaveSyncState(ctx, &models.MailboxSyncState{
+		MailboxID:  mailboxID,
+		FolderName: folder,
+		LastUID:    0,
+	})
+}
@@ -0,0 +1,65 @@
+package imap
+
+import (
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/interfaces"
+	mailstack_errors "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/models"
+)
+
+func TestApplySettingsUpdate(t *testing.T) {
+	mailbox := &models.Mailbox{
+		ID:                      "mbox_1",
+		SyncFolders:             pq.StringArray{"INBOX", "Sent"},
+		SyncPollIntervalSeconds: 30,
+		ImapUsername:            "jane@acme.com",
+		ImapPassword:            "old-secret",
+	}
+	interval, username, password := 60, " jane.doe@acme.com ", "new-secret"
+
+	updated, err := applySettingsUpdate(mailbox, interfaces.MailboxSettingsUpdate{
+		SyncFolders:             []string{"INBOX", "Clients/Acme", "INBOX"},
+		SyncPollIntervalSeconds: &interval,
+		ImapUsername:            &username,
+		ImapPassword:            &password,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, pq.StringArray{"INBOX", "Clients/Acme"}, updated.SyncFolders)
+	assert.Equal(t, 60, updated.SyncPollIntervalSeconds)
+	assert.Equal(t, "jane.doe@acme.com", updated.ImapUsername)
+	assert.Equal(t, "new-secret", updated.ImapPassword)
+	assert.Equal(t, "old-secret", mailbox.ImapPassword) // the loaded mailbox is left alone
+
+	kept, err := applySettingsUpdate(mailbox, interfaces.MailboxSettingsUpdate{})
+	require.NoError(t, err)
+	assert.Equal(t, *mailbox, *kept)
+
+	tooFast, empty := 5, ""
+	for name, update := range map[string]interfaces.MailboxSettingsUpdate{
+		"no folders":      {SyncFolders: []string{}},
+		"poll too fast":   {SyncPollIntervalSeconds: &tooFast},
+		"empty password":  {ImapPassword: &empty},
+		"wildcard folder": {SyncFolders: []string{"INBOX", "Clients/*"}},
+		"blank username":  {ImapUsername: &empty},
+	} {
+		_, err := applySettingsUpdate(mailbox, update)
+		assert.Error(t, err, name)
+	}
+	_, err = applySettingsUpdate(mailbox, interfaces.MailboxSettingsUpdate{SyncPollIntervalSeconds: &tooFast})
+	assert.ErrorIs(t, err, mailstack_errors.ErrInvalidSyncSettings)
+}
+
+func TestDiffSyncFolders(t *testing.T) {
+	added, removed := diffSyncFolders([]string{"INBOX", "Sent", "Archive"}, []string{"INBOX", "Archive", "Clients/Acme"})
+	assert.Equal(t, []string{"Clients/Acme"}, added)
+	assert.Equal(t, []string{"Sent"}, removed)
+
+	added, removed = diffSyncFolders([]string{"INBOX"}, []string{"INBOX"})
+	assert.Empty(t, added)
+	assert.Empty(t, removed)
+}
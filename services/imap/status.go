@@ -0,0 +1,198 @@
+package imap
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/enum"
+	"github.com/customeros/mailstack/internal/metrics"
+	"github.com/customeros/mailstack/internal/models"
+)
+
+// initStatus registers a monitored mailbox as not connected yet, unless it has a status already.
+// A previous authentication failure is cleared, the mailbox is about to try again.
+func (s *IMAPService) initStatus(mailboxID string) {
+	s.statusMutex.Lock()
+	defer s.statusMutex.Unlock()
+
+	if status, exists := s.statuses[mailboxID]; exists {
+		status.AuthFailed = false
+		s.statuses[mailboxID] = status
+		return
+	}
+	now := time.Now()
+	s.statuses[mailboxID] = interfaces.MailboxStatus{
+		LastChecked:       now,
+		DisconnectedSince: now,
+	}
+}
+
+func (s *IMAPService) markConnected(mailboxID string) {
+	s.statusMutex.Lock()
+	defer s.statusMutex.Unlock()
+
+	status := s.statuses[mailboxID]
+	if !status.Connected {
+		metrics.IMAPConnections.Inc()
+	}
+	status.Connected = true
+	status.AuthFailed = false
+	status.LastError = ""
+	status.LastChecked = time.Now()
+	status.DisconnectedSince = time.Time{}
+	s.statuses[mailboxID] = status
+}
+
+// setWaitingForSlot flags a mailbox queued behind the connection limit
+func (s *IMAPService) setWaitingForSlot(mailboxID string, waiting bool) {
+	s.statusMutex.Lock()
+	defer s.statusMutex.Unlock()
+
+	status := s.statuses[mailboxID]
+	status.WaitingForSlot = waiting
+	s.statuses[mailboxID] = status
+}
+
+// markDisconnected keeps the time the mailbox first lost its connection across failed reconnects
+func (s *IMAPService) markDisconnected(mailboxID string, err error) {
+	s.statusMutex.Lock()
+	defer s.statusMutex.Unlock()
+
+	now := time.Now()
+	status := s.statuses[mailboxID]
+	if status.Connected || status.DisconnectedSince.IsZero() {
+		status.DisconnectedSince = now
+	}
+	if status.Connected {
+		metrics.IMAPConnections.Dec()
+	}
+	status.Connected = false
+	status.LastChecked = now
+	if err != nil {
+		status.LastError = err.Error()
+	}
+	s.statuses[mailboxID] = status
+}
+
+// markAuthFailed flags a mailbox whose credentials were rejected, it is not reconnected until
+// they are updated
+func (s *IMAPService) markAuthFailed(mailboxID string, err error) {
+	s.markDisconnected(mailboxID, err)
+
+	s.statusMutex.Lock()
+	defer s.statusMutex.Unlock()
+
+	status := s.statuses[mailboxID]
+	status.AuthFailed = true
+	s.statuses[mailboxID] = status
+}
+
+// recordFolderStats stores the counts of a folder after it was synced or polled, in memory and in
+// the database for the other pods. SELECT only reports the first unseen message, the unseen count
+// is asked with STATUS.
+func (s *IMAPService) recordFolderStats(ctx context.Context, c *client.Client, mailboxID, folderName string, mbox *imap.MailboxStatus) {
+	if mbox == nil {
+		return
+	}
+
+	stats := interfaces.FolderStats{
+		Total:    mbox.Messages,
+		LastSync: time.Now(),
+	}
+	if mbox.UidNext > 0 {
+		stats.LastSeen = mbox.UidNext - 1
+	}
+
+	c.Timeout = 30 * time.Second
+	folderStatus, err := c.Status(folderName, []imap.StatusItem{imap.StatusUnseen})
+	c.Timeout = 0
+
+	s.updateFolderStatus(mailboxID, folderName, func(folder *interfaces.FolderStats) {
+		folder.Total = stats.Total
+		folder.LastSeen = stats.LastSeen
+		folder.LastSync = stats.LastSync
+		if err == nil {
+			folder.Unseen = folderStatus.Unseen
+		}
+		stats.Unseen = folder.Unseen
+	})
+
+	err = s.repositories.MailboxFolderStatsRepository.Save(ctx, &models.MailboxFolderStats{
+		MailboxID:   mailboxID,
+		FolderName:  folderName,
+		Total:       stats.Total,
+		Unseen:      stats.Unseen,
+		LastSeenUID: stats.LastSeen,
+		LastSync:    stats.LastSync,
+	})
+	if err != nil {
+		log.Printf("[%s][%s] Failed to save folder stats: %v", mailboxID, folderName, err)
+	}
+}
+
+// recordInitialSyncProgress exposes the persisted initial sync progress of a folder
+func (s *IMAPService) recordInitialSyncProgress(syncState *models.MailboxSyncState) {
+	s.updateFolderStatus(syncState.MailboxID, syncState.FolderName, func(folder *interfaces.FolderStats) {
+		folder.InitialSync = interfaces.InitialSyncProgress{
+			Processed: syncState.InitialSyncProcessed,
+			Total:     syncState.InitialSyncTotal,
+			Complete:  syncState.InitialSyncComplete,
+		}
+	})
+}
+
+func (s *IMAPService) updateFolderStatus(mailboxID, folderName string, update func(folder *interfaces.FolderStats)) {
+	s.statusMutex.Lock()
+	defer s.statusMutex.Unlock()
+
+	// the folders map is replaced rather than updated, Status hands out the current one
+	status := s.statuses[mailboxID]
+	folders := make(map[string]interfaces.FolderStats, len(status.Folders)+1)
+	for name, folder := range status.Folders {
+		folders[name] = folder
+	}
+	folder := folders[folderName]
+	update(&folder)
+	folders[folderName] = folder
+
+	status.Folders = folders
+	status.LastChecked = time.Now()
+	status.InitialSyncComplete = true
+	for _, f := range folders {
+		status.InitialSyncComplete = status.InitialSyncComplete && f.InitialSync.Complete
+	}
+	s.statuses[mailboxID] = status
+}
+
+// forgetFolderStatus removes a folder that is no longer synced from the mailbox status
+func (s *IMAPService) forgetFolderStatus(mailboxID, folderName string) {
+	s.statusMutex.Lock()
+	defer s.statusMutex.Unlock()
+
+	status, exists := s.statuses[mailboxID]
+	if !exists {
+		return
+	}
+	folders := make(map[string]interfaces.FolderStats, len(status.Folders))
+	for name, folder := range status.Folders {
+		if name != folderName {
+			folders[name] = folder
+		}
+	}
+	status.Folders = folders
+	s.statuses[mailboxID] = status
+}
+
+// recordQueued counts a fetched message as ingested, or as failed when it could not be queued
+func recordQueued(err error) {
+	if err != nil {
+		metrics.EmailsFailed.WithLabelValues(metrics.OperationIngest).Inc()
+		return
+	}
+	metrics.EmailsIngested.WithLabelValues(string(enum.EmailImportIMAP)).Inc()
+}
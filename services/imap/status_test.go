@@ -0,0 +1,52 @@
+package imap
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/metrics"
+	"github.com/customeros/mailstack/internal/repository"
+)
+
+type fakeSyncStateRepository struct {
+	interfaces.MailboxSyncRepository
+}
+
+func (fakeSyncStateRepository) DeleteMailboxSyncStates(context.Context, string) error {
+	return nil
+}
+
+type fakeFolderStatsRepository struct {
+	interfaces.MailboxFolderStatsRepository
+}
+
+func (fakeFolderStatsRepository) DeleteByMailbox(context.Context, string) error {
+	return nil
+}
+
+func TestIMAPConnectionsGauge(t *testing.T) {
+	s := NewIMAPService(nil, &repository.Repositories{
+		MailboxSyncRepository:        fakeSyncStateRepository{},
+		MailboxFolderStatsRepository: fakeFolderStatsRepository{},
+	}, nil).(*IMAPService)
+	start := testutil.ToFloat64(metrics.IMAPConnections)
+
+	s.initStatus("mbox_1")
+	s.initStatus("mbox_2")
+	s.markConnected("mbox_1")
+	s.markConnected("mbox_1") // still the same connection
+	s.markConnected("mbox_2")
+	assert.Equal(t, start+2, testutil.ToFloat64(metrics.IMAPConnections))
+
+	s.markDisconnected("mbox_1", errors.New("connection reset"))
+	s.markAuthFailed("mbox_1", errors.New("invalid credentials")) // was not connected anymore
+	assert.Equal(t, start+1, testutil.ToFloat64(metrics.IMAPConnections))
+
+	assert.NoError(t, s.RemoveMailbox(context.Background(), "mbox_2"))
+	assert.Equal(t, start, testutil.ToFloat64(metrics.IMAPConnections))
+}
@@ -6,21 +6,25 @@ import (
 	"github.com/customeros/mailstack/internal/logger"
 	"github.com/customeros/mailstack/internal/repository"
 	"github.com/customeros/mailstack/services/ai"
+	"github.com/customeros/mailstack/services/audit"
 	"github.com/customeros/mailstack/services/cloudflare"
 	"github.com/customeros/mailstack/services/domain"
 	"github.com/customeros/mailstack/services/email"
 	"github.com/customeros/mailstack/services/email_processor"
 	"github.com/customeros/mailstack/services/events"
 	"github.com/customeros/mailstack/services/imap"
+	"github.com/customeros/mailstack/services/mailauth"
 	"github.com/customeros/mailstack/services/mailbox"
 	mailboxold "github.com/customeros/mailstack/services/mailbox_old"
 	"github.com/customeros/mailstack/services/namecheap"
 	"github.com/customeros/mailstack/services/opensrs"
+	"github.com/customeros/mailstack/services/scanner"
 )
 
 type Services struct {
 	EventsService     *events.EventsService
 	AIService         interfaces.AIService
+	AuditService      interfaces.AuditService
 	CloudflareService interfaces.CloudflareService
 	EmailProcessor    interfaces.EmailProcessor
 	EmailService      interfaces.EmailService
@@ -42,12 +46,16 @@ func InitServices(rabbitmqURL string, log logger.Logger, repos *repository.Repos
 		PublishTimeout:      events.DefaultPublishTimeout,
 		ReconnectBackoff:    events.DefaultReconnectBackoff,
 		MaxReconnectBackoff: events.DefaultMaxReconnectBackoff,
+		MaxReplays:          events.DefaultMaxReplays,
 	}
 
 	subscriberConfig := &events.SubscriberConfig{
 		MaxRetries:          events.DefaultMaxRetries,
 		ReconnectBackoff:    events.DefaultReconnectBackoff,
 		MaxReconnectBackoff: events.DefaultMaxReconnectBackoff,
+		DedupWindow:         events.DefaultDedupWindow,
+		PrefetchCount:       cfg.AppConfig.RabbitMQPrefetchCount,
+		Workers:             cfg.AppConfig.RabbitMQConsumerWorkers,
 	}
 
 	events, err := events.NewEventsService(rabbitmqURL, log, publisherConfig, subscriberConfig)
@@ -59,27 +67,35 @@ func InitServices(rabbitmqURL string, log logger.Logger, repos *repository.Repos
 	}
 
 	aiServiceImpl := ai.NewAIService(cfg.CustomerOSAPIConfig)
-	namecheapImpl := namecheap.NewNamecheapService(cfg.NamecheapConfig, repos)
+	namecheapImpl, err := namecheap.NewNamecheapService(cfg.NamecheapConfig, repos)
+	if err != nil {
+		return nil, err
+	}
 	cloudflareImpl := cloudflare.NewCloudflareService(log, cfg.CloudflareConfig, repos)
-	opensrsImpl := opensrs.NewOpenSRSService(log, cfg.OpenSrsConfig, repos)
+	imapImpl := imap.NewIMAPService(events, repos, cfg.IMAPConfig)
+	opensrsImpl := opensrs.NewOpenSRSService(log, cfg.OpenSrsConfig, repos, imapImpl)
 	mailboxOldImpl := mailboxold.NewMailboxServiceOld(log, repos, opensrsImpl)
-	imapImpl := imap.NewIMAPService(events, repos)
-	emailProcessorImpl := email_processor.NewEmailProcessor(repos, events, aiServiceImpl)
+	mailAuthVerifier, err := mailauth.NewMailAuthVerifier(cfg.InboundConfig.TrustedRelayNetworks)
+	if err != nil {
+		return nil, err
+	}
+	emailProcessorImpl := email_processor.NewEmailProcessor(repos, events, aiServiceImpl, scanner.NewAttachmentScanner(cfg.AttachmentScannerConfig), scanner.NewSpamScorer(cfg.SpamScorerConfig), cfg.ThreadingConfig, cfg.HTMLSanitizerConfig, cfg.AttachmentPreviewConfig, cfg.SpoofingConfig)
 
 	services := Services{
 		EventsService:     events,
 		AIService:         aiServiceImpl,
+		AuditService:      audit.NewAuditService(log, repos),
 		CloudflareService: cloudflareImpl,
 		EmailProcessor:    emailProcessorImpl,
-		EmailService:      email.NewEmailService(events, repos),
-		IMAPProcessor:     email_processor.NewImapProcessor(emailProcessorImpl, imapImpl),
+		EmailService:      email.NewEmailService(events, repos, cfg.SMTPConfig, imapImpl),
+		IMAPProcessor:     email_processor.NewImapProcessor(emailProcessorImpl, imapImpl, mailAuthVerifier, cfg.InboundConfig),
 		IMAPService:       imapImpl,
-		MailboxService:    mailbox.NewMailboxService(repos, imapImpl),
+		MailboxService:    mailbox.NewMailboxService(repos, imapImpl, opensrsImpl),
 		NamecheapService:  namecheapImpl,
 		OpenSrsService:    opensrsImpl,
 
 		MailboxServiceOld: mailboxOldImpl,
-		DomainService:     domain.NewDomainService(repos, cloudflareImpl, namecheapImpl, mailboxOldImpl, opensrsImpl),
+		DomainService:     domain.NewDomainService(repos, cloudflareImpl, namecheapImpl, mailboxOldImpl, opensrsImpl, events, cfg.DomainConfig),
 	}
 
 	return &services, nil
@@ -0,0 +1,333 @@
+package mailauth
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/customeros/mailstack/internal/enum"
+	"github.com/customeros/mailstack/internal/models"
+)
+
+const (
+	// maxDKIMSignatures caps the signatures verified per message, each costs a key lookup
+	maxDKIMSignatures = 5
+	// minRSAKeyBits is the smallest RSA key accepted (RFC 8301)
+	minRSAKeyBits = 1024
+
+	canonicalizationSimple  = "simple"
+	canonicalizationRelaxed = "relaxed"
+)
+
+var (
+	whitespaceRun = regexp.MustCompile(`[ \t]+`)
+	// signatureValue matches the b= tag of a DKIM-Signature value, whose content is left out when
+	// the signature header signs itself
+	signatureValue = regexp.MustCompile(`((?:^|;)[ \t\r\n]*b[ \t\r\n]*=)[^;]*`)
+)
+
+type dkimSignature struct {
+	algorithm   string
+	domain      string
+	selector    string
+	headerCanon string
+	bodyCanon   string
+	headers     []string
+	bodyHash    []byte
+	signature   []byte
+	bodyLength  int64 // -1 when the whole body is signed
+	expires     time.Time
+	field       headerField
+}
+
+// verifyDKIM verifies the DKIM-Signature headers of msg, top to bottom
+func (v *verifier) verifyDKIM(ctx context.Context, msg *message) []models.DKIMResult {
+	results := []models.DKIMResult{}
+	for i, field := range msg.get("DKIM-Signature") {
+		if i == maxDKIMSignatures {
+			break
+		}
+		results = append(results, v.verifyDKIMSignature(ctx, msg, field))
+	}
+	return results
+}
+
+func (v *verifier) verifyDKIMSignature(ctx context.Context, msg *message, field headerField) models.DKIMResult {
+	sig, err := parseDKIMSignature(field)
+	if err != nil {
+		result := models.DKIMResult{Result: enum.AuthResultPermError, Detail: err.Error()}
+		if sig != nil {
+			result.Domain, result.Selector = sig.domain, sig.selector
+		}
+		return result
+	}
+	result := models.DKIMResult{Domain: sig.domain, Selector: sig.selector}
+
+	if !sig.expires.IsZero() && v.now().After(sig.expires) {
+		result.Result, result.Detail = enum.AuthResultFail, "signature expired"
+		return result
+	}
+
+	// content appended past the l= limit is not covered by the signature, so it would pass with
+	// anything added to the body
+	if sig.bodyLength >= 0 {
+		if unsigned := int64(len(canonicalizeBody(msg.body, sig.bodyCanon, -1))) - sig.bodyLength; unsigned > 0 {
+			result.Result, result.Detail = enum.AuthResultFail, fmt.Sprintf("body length limit l= leaves %d bytes unsigned", unsigned)
+			return result
+		}
+	}
+
+	bodyHash := sha256.New()
+	bodyHash.Write(canonicalizeBody(msg.body, sig.bodyCanon, sig.bodyLength))
+	if subtle.ConstantTimeCompare(bodyHash.Sum(nil), sig.bodyHash) != 1 {
+		result.Result, result.Detail = enum.AuthResultFail, "body hash did not verify"
+		return result
+	}
+
+	key, status, detail := v.lookupDKIMKey(ctx, sig)
+	if key == nil {
+		result.Result, result.Detail = status, detail
+		return result
+	}
+
+	headerHash := sha256.New()
+	headerHash.Write(signedHeaders(msg, sig))
+	digest := headerHash.Sum(nil)
+
+	valid := false
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		valid = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest, sig.signature) == nil
+	case ed25519.PublicKey:
+		// RFC 8463 signs the hash of the headers rather than the headers themselves
+		valid = ed25519.Verify(key, digest, sig.signature)
+	}
+	if !valid {
+		result.Result, result.Detail = enum.AuthResultFail, "signature did not verify"
+		return result
+	}
+	result.Result = enum.AuthResultPass
+	return result
+}
+
+// parseDKIMSignature reads the tags of a DKIM-Signature header (RFC 6376 section 3.5). The
+// signature is returned along with the error when at least its domain is known.
+func parseDKIMSignature(field headerField) (*dkimSignature, error) {
+	tags := ParseTagList(field.value())
+	sig := &dkimSignature{
+		algorithm:  strings.ToLower(tags["a"]),
+		domain:     strings.ToLower(strings.TrimSuffix(tags["d"], ".")),
+		selector:   strings.ToLower(tags["s"]),
+		bodyLength: -1,
+		field:      field,
+	}
+
+	for _, tag := range []string{"v", "a", "b", "bh", "d", "h", "s"} {
+		if tags[tag] == "" {
+			return sig, fmt.Errorf("signature has no %s= tag", tag)
+		}
+	}
+	if tags["v"] != "1" {
+		return sig, fmt.Errorf("unsupported signature version %q", tags["v"])
+	}
+	if sig.algorithm == "rsa-sha1" {
+		// RFC 8301 forbids verifying with rsa-sha1
+		return sig, fmt.Errorf("algorithm rsa-sha1 is not accepted")
+	}
+	if sig.algorithm != "rsa-sha256" && sig.algorithm != "ed25519-sha256" {
+		return sig, fmt.Errorf("unsupported algorithm %q", sig.algorithm)
+	}
+
+	sig.headerCanon, sig.bodyCanon = canonicalizationSimple, canonicalizationSimple
+	if c := strings.ToLower(tags["c"]); c != "" {
+		header, body, hasBody := strings.Cut(c, "/")
+		sig.headerCanon = header
+		if hasBody {
+			sig.bodyCanon = body
+		}
+	}
+	for _, canon := range []string{sig.headerCanon, sig.bodyCanon} {
+		if canon != canonicalizationSimple && canon != canonicalizationRelaxed {
+			return sig, fmt.Errorf("unsupported canonicalization %q", canon)
+		}
+	}
+
+	for _, name := range strings.Split(tags["h"], ":") {
+		if name = strings.TrimSpace(name); name != "" {
+			sig.headers = append(sig.headers, name)
+		}
+	}
+	signsFrom := false
+	for _, name := range sig.headers {
+		signsFrom = signsFrom || strings.EqualFold(name, "From")
+	}
+	if !signsFrom {
+		return sig, fmt.Errorf("signature does not sign the From header")
+	}
+
+	if identity := strings.ToLower(tags["i"]); identity != "" {
+		_, identityDomain, _ := strings.Cut(identity, "@")
+		if identityDomain != sig.domain && !strings.HasSuffix(identityDomain, "."+sig.domain) {
+			return sig, fmt.Errorf("identity %s is not in the signing domain", identity)
+		}
+	}
+
+	var err error
+	if sig.bodyHash, err = base64.StdEncoding.DecodeString(stripWhitespace(tags["bh"])); err != nil {
+		return sig, fmt.Errorf("invalid body hash: %w", err)
+	}
+	if sig.signature, err = base64.StdEncoding.DecodeString(stripWhitespace(tags["b"])); err != nil {
+		return sig, fmt.Errorf("invalid signature: %w", err)
+	}
+	if l := tags["l"]; l != "" {
+		if sig.bodyLength, err = strconv.ParseInt(l, 10, 64); err != nil || sig.bodyLength < 0 {
+			return sig, fmt.Errorf("invalid body length %q", l)
+		}
+	}
+	if x := tags["x"]; x != "" {
+		expires, err := strconv.ParseInt(x, 10, 64)
+		if err != nil {
+			return sig, fmt.Errorf("invalid expiration %q", x)
+		}
+		sig.expires = time.Unix(expires, 0)
+	}
+	return sig, nil
+}
+
+// lookupDKIMKey fetches the public key of the signature from <selector>._domainkey.<domain>.
+// Without a key the result and detail say why.
+func (v *verifier) lookupDKIMKey(ctx context.Context, sig *dkimSignature) (crypto.PublicKey, enum.AuthResult, string) {
+	name := sig.selector + "._domainkey." + sig.domain
+	records, err := v.resolver.LookupTXT(ctx, name)
+	if err != nil {
+		if IsNotFound(err) {
+			return nil, enum.AuthResultPermError, "no key published at " + name
+		}
+		return nil, enum.AuthResultTempError, fmt.Sprintf("key lookup for %s failed: %v", name, err)
+	}
+
+	for _, record := range records {
+		tags := ParseTagList(record)
+		if version, ok := tags["v"]; ok && version != "DKIM1" {
+			continue
+		}
+		encoded := stripWhitespace(tags["p"])
+		if encoded == "" {
+			return nil, enum.AuthResultPermError, "key at " + name + " is revoked"
+		}
+		data, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, enum.AuthResultPermError, "key at " + name + " is not valid base64"
+		}
+
+		keyType := strings.ToLower(tags["k"])
+		if keyType == "" {
+			keyType = "rsa"
+		}
+		if !strings.HasPrefix(sig.algorithm, keyType+"-") {
+			return nil, enum.AuthResultPermError, fmt.Sprintf("key at %s is %s, signature is %s", name, keyType, sig.algorithm)
+		}
+		key, err := parseDKIMKey(keyType, data)
+		if err != nil {
+			return nil, enum.AuthResultPermError, fmt.Sprintf("key at %s: %v", name, err)
+		}
+		return key, "", ""
+	}
+	return nil, enum.AuthResultPermError, "no key published at " + name
+}
+
+func parseDKIMKey(keyType string, data []byte) (crypto.PublicKey, error) {
+	if keyType == "ed25519" {
+		if len(data) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("ed25519 key has %d bytes", len(data))
+		}
+		return ed25519.PublicKey(data), nil
+	}
+
+	var key *rsa.PublicKey
+	if parsed, err := x509.ParsePKIXPublicKey(data); err == nil {
+		var ok bool
+		if key, ok = parsed.(*rsa.PublicKey); !ok {
+			return nil, fmt.Errorf("key is %T, expected rsa", parsed)
+		}
+	} else if key, err = x509.ParsePKCS1PublicKey(data); err != nil {
+		return nil, fmt.Errorf("invalid rsa key: %w", err)
+	}
+	if key.N.BitLen() < minRSAKeyBits {
+		return nil, fmt.Errorf("rsa key of %d bits is too short", key.N.BitLen())
+	}
+	return key, nil
+}
+
+// signedHeaders builds the header hash input: the headers listed in h=, each name taking the
+// next instance from the bottom up, then the signature header itself without its b= value
+// and without its final CRLF
+func signedHeaders(msg *message, sig *dkimSignature) []byte {
+	var buffer bytes.Buffer
+	used := map[string]int{}
+	for _, name := range sig.headers {
+		key := strings.ToLower(name)
+		fields := msg.get(name)
+		if used[key] >= len(fields) {
+			// listing a missing header signs its absence and adds nothing
+			continue
+		}
+		buffer.WriteString(canonicalizeHeader(fields[len(fields)-1-used[key]].raw, sig.headerCanon))
+		used[key]++
+	}
+
+	name, value, _ := strings.Cut(strings.TrimSuffix(sig.field.raw, "\r\n"), ":")
+	unsigned := name + ":" + signatureValue.ReplaceAllString(value, "$1")
+	buffer.WriteString(strings.TrimSuffix(canonicalizeHeader(unsigned, sig.headerCanon), "\r\n"))
+	return buffer.Bytes()
+}
+
+// canonicalizeHeader applies simple or relaxed header canonicalization (RFC 6376 section 3.4)
+func canonicalizeHeader(raw, canon string) string {
+	if canon != canonicalizationRelaxed {
+		return raw
+	}
+	name, value, _ := strings.Cut(raw, ":")
+	value = strings.ReplaceAll(value, "\r\n", "")
+	value = strings.TrimSpace(whitespaceRun.ReplaceAllString(value, " "))
+	return strings.ToLower(strings.TrimSpace(name)) + ":" + value + "\r\n"
+}
+
+// canonicalizeBody applies simple or relaxed body canonicalization, truncated to length bytes
+// when the signature has an l= tag
+func canonicalizeBody(body []byte, canon string, length int64) []byte {
+	lines := strings.Split(string(body), "\r\n")
+	if canon == canonicalizationRelaxed {
+		for i, line := range lines {
+			lines[i] = strings.TrimRight(whitespaceRun.ReplaceAllString(line, " "), " ")
+		}
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var canonical []byte
+	switch {
+	case len(lines) > 0:
+		canonical = []byte(strings.Join(lines, "\r\n") + "\r\n")
+	case canon == canonicalizationSimple:
+		// an empty body is a single CRLF in simple canonicalization, nothing in relaxed
+		canonical = []byte("\r\n")
+	}
+
+	if length >= 0 && length < int64(len(canonical)) {
+		canonical = canonical[:length]
+	}
+	return canonical
+}
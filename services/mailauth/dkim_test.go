@@ -0,0 +1,163 @@
+package mailauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/internal/enum"
+)
+
+const testMessage = "From: Jane <jane@acme.io>\r\n" +
+	"To: bob@corp.io\r\n" +
+	"Subject:   Quarterly   numbers\r\n" +
+	"\r\n" +
+	"Hi Bob,  \r\n" +
+	"the numbers are in.\r\n" +
+	"\r\n" +
+	"\r\n"
+
+// signMessage prepends a DKIM-Signature of domain acme.io and selector sel to raw
+func signMessage(t *testing.T, raw string, key crypto.Signer, algorithm, canon string) string {
+	msg, err := parseMessage([]byte(raw))
+	require.NoError(t, err)
+	headerCanon, bodyCanon, _ := strings.Cut(canon, "/")
+
+	bodyHash := sha256.Sum256(canonicalizeBody(msg.body, bodyCanon, -1))
+	header := fmt.Sprintf("DKIM-Signature: v=1; a=%s; c=%s; d=acme.io; s=sel;\r\n\th=from:to:subject; bh=%s;\r\n\tb=",
+		algorithm, canon, base64.StdEncoding.EncodeToString(bodyHash[:]))
+	sig := &dkimSignature{
+		headers:     []string{"from", "to", "subject"},
+		headerCanon: headerCanon,
+		field:       headerField{name: "DKIM-Signature", raw: header + "\r\n"},
+	}
+	digest := sha256.Sum256(signedHeaders(msg, sig))
+
+	var signature []byte
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(key, digest[:])
+	}
+	return header + base64.StdEncoding.EncodeToString(signature) + "\r\n" + raw
+}
+
+func TestCanonicalization(t *testing.T) {
+	// the example of RFC 6376 section 3.4.6
+	msg, err := parseMessage([]byte("A: X\r\nB : Y\t\r\n\tZ  \r\n\r\n C \r\nD \t E\r\n\r\n\r\n"))
+	require.NoError(t, err)
+	require.Len(t, msg.headers, 2)
+
+	relaxed := canonicalizeHeader(msg.headers[0].raw, canonicalizationRelaxed) + canonicalizeHeader(msg.headers[1].raw, canonicalizationRelaxed)
+	assert.Equal(t, "a:X\r\nb:Y Z\r\n", relaxed)
+	assert.Equal(t, " C\r\nD E\r\n", string(canonicalizeBody(msg.body, canonicalizationRelaxed, -1)))
+
+	simple := canonicalizeHeader(msg.headers[0].raw, canonicalizationSimple) + canonicalizeHeader(msg.headers[1].raw, canonicalizationSimple)
+	assert.Equal(t, "A: X\r\nB : Y\t\r\n\tZ  \r\n", simple)
+	assert.Equal(t, " C \r\nD \t E\r\n", string(canonicalizeBody(msg.body, canonicalizationSimple, -1)))
+
+	assert.Equal(t, "\r\n", string(canonicalizeBody(nil, canonicalizationSimple, -1)))
+	assert.Empty(t, canonicalizeBody([]byte("\r\n\r\n"), canonicalizationRelaxed, -1))
+	assert.Equal(t, " C", string(canonicalizeBody(msg.body, canonicalizationRelaxed, 2)))
+}
+
+func TestVerifyDKIM(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	rsaPublic, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+	edPublic, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	resolver := &fakeResolver{txt: map[string][]string{
+		"sel._domainkey.acme.io": {"v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(rsaPublic)},
+	}}
+	v := &verifier{resolver: resolver, now: time.Now}
+
+	verify := func(raw string) []string {
+		msg, err := parseMessage([]byte(raw))
+		require.NoError(t, err)
+		var results []string
+		for _, result := range v.verifyDKIM(context.Background(), msg) {
+			results = append(results, string(result.Result)+" "+result.Detail)
+		}
+		return results
+	}
+
+	for _, canon := range []string{"relaxed/relaxed", "simple/simple", "relaxed/simple"} {
+		t.Run(canon, func(t *testing.T) {
+			signed := signMessage(t, testMessage, rsaKey, "rsa-sha256", canon)
+			assert.Equal(t, []string{"pass "}, verify(signed))
+
+			// LF line endings and header whitespace a relay may touch
+			if strings.HasPrefix(canon, "relaxed") {
+				assert.Equal(t, []string{"pass "}, verify(strings.ReplaceAll(signed, "\r\n", "\n")))
+				assert.Equal(t, []string{"pass "}, verify(strings.Replace(signed, "Subject:   Quarterly", "Subject: Quarterly", 1)))
+			}
+
+			assert.Equal(t, []string{"fail body hash did not verify"}, verify(strings.Replace(signed, "numbers are in", "numbers are out", 1)))
+			assert.Equal(t, []string{"fail signature did not verify"}, verify(strings.Replace(signed, "bob@corp.io", "eve@corp.io", 1)))
+		})
+	}
+
+	t.Run("ed25519", func(t *testing.T) {
+		resolver.txt["sel._domainkey.acme.io"] = []string{"v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(edPublic)}
+		signed := signMessage(t, testMessage, edKey, "ed25519-sha256", "relaxed/relaxed")
+		assert.Equal(t, []string{"pass "}, verify(signed))
+		assert.Equal(t, []string{"fail signature did not verify"}, verify(strings.Replace(signed, "To: bob", "To: eve", 1)))
+	})
+
+	t.Run("key problems", func(t *testing.T) {
+		signed := signMessage(t, testMessage, rsaKey, "rsa-sha256", "relaxed/relaxed")
+
+		resolver.txt["sel._domainkey.acme.io"] = []string{"v=DKIM1; p="}
+		assert.Equal(t, []string{"permerror key at sel._domainkey.acme.io is revoked"}, verify(signed))
+
+		delete(resolver.txt, "sel._domainkey.acme.io")
+		assert.Equal(t, []string{"permerror no key published at sel._domainkey.acme.io"}, verify(signed))
+
+		resolver.failing = true
+		assert.Equal(t, enum.AuthResultTempError, v.verifyDKIM(context.Background(), mustParse(t, signed))[0].Result)
+		resolver.failing = false
+	})
+
+	t.Run("rsa-sha1", func(t *testing.T) {
+		resolver.txt["sel._domainkey.acme.io"] = []string{"v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(rsaPublic)}
+		signed := signMessage(t, testMessage, rsaKey, "rsa-sha1", "relaxed/relaxed")
+		assert.Equal(t, []string{"permerror algorithm rsa-sha1 is not accepted"}, verify(signed))
+	})
+
+	t.Run("body length limit", func(t *testing.T) {
+		// the simple body of testMessage is 32 bytes
+		assert.Equal(t, []string{"fail body length limit l= leaves 27 bytes unsigned"},
+			verify("DKIM-Signature: v=1; a=rsa-sha256; d=acme.io; s=sel; h=from; l=5; bh=AA==; b=AA==\r\n"+testMessage))
+		assert.Equal(t, []string{"fail body hash did not verify"},
+			verify("DKIM-Signature: v=1; a=rsa-sha256; d=acme.io; s=sel; h=from; l=32; bh=AA==; b=AA==\r\n"+testMessage))
+	})
+
+	t.Run("unsigned and malformed", func(t *testing.T) {
+		assert.Empty(t, verify(testMessage))
+		assert.Equal(t, []string{"permerror signature does not sign the From header"},
+			verify("DKIM-Signature: v=1; a=rsa-sha256; d=acme.io; s=sel; h=to; bh=AA==; b=AA==\r\n"+testMessage))
+	})
+}
+
+func mustParse(t *testing.T, raw string) *message {
+	msg, err := parseMessage([]byte(raw))
+	require.NoError(t, err)
+	return msg
+}
@@ -0,0 +1,124 @@
+package mailauth
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"github.com/customeros/mailstack/internal/enum"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+// verifyDMARC looks up the DMARC policy of the From domain, falling back to its organizational
+// domain, and passes when SPF or one of the DKIM signatures passes aligned with the From domain
+func (v *verifier) verifyDMARC(ctx context.Context, msg *message, spf models.SPFResult, dkim []models.DKIMResult) models.DMARCResult {
+	result := models.DMARCResult{Result: enum.AuthResultNone}
+
+	froms := msg.get("From")
+	if len(froms) != 1 {
+		result.Result, result.Detail = enum.AuthResultPermError, fmt.Sprintf("message has %d From headers", len(froms))
+		return result
+	}
+	result.Domain = fromDomain(froms[0].value())
+	if result.Domain == "" {
+		result.Result, result.Detail = enum.AuthResultPermError, "From header has no domain"
+		return result
+	}
+
+	tags, fromOrganization, err := v.lookupDMARC(ctx, result.Domain)
+	if err != nil {
+		result.Result, result.Detail = enum.AuthResultTempError, err.Error()
+		return result
+	}
+	if tags == nil {
+		result.Detail = result.Domain + " publishes no DMARC record"
+		return result
+	}
+
+	result.Policy = strings.ToLower(tags["p"])
+	if subdomainPolicy := strings.ToLower(tags["sp"]); fromOrganization && subdomainPolicy != "" {
+		result.Policy = subdomainPolicy
+	}
+	if result.Policy == "" {
+		result.Policy = "none"
+	}
+
+	result.SPFAligned = spf.Result == enum.AuthResultPass && DomainsAligned(spf.Domain, result.Domain, strings.EqualFold(tags["aspf"], "s"))
+	for _, signature := range dkim {
+		if signature.Result == enum.AuthResultPass && DomainsAligned(signature.Domain, result.Domain, strings.EqualFold(tags["adkim"], "s")) {
+			result.DKIMAligned = true
+		}
+	}
+
+	result.Result = enum.AuthResultFail
+	if result.SPFAligned || result.DKIMAligned {
+		result.Result = enum.AuthResultPass
+	} else {
+		result.Detail = "neither SPF nor DKIM passed aligned with " + result.Domain
+	}
+	return result
+}
+
+// lookupDMARC returns the tags of the DMARC record of domain or, without one, of its
+// organizational domain, nil when neither publishes a record
+func (v *verifier) lookupDMARC(ctx context.Context, domain string) (map[string]string, bool, error) {
+	tags, err := v.dmarcRecord(ctx, domain)
+	if err != nil || tags != nil {
+		return tags, false, err
+	}
+	organization := organizationalDomain(domain)
+	if organization == domain {
+		return nil, false, nil
+	}
+	tags, err = v.dmarcRecord(ctx, organization)
+	return tags, tags != nil, err
+}
+
+func (v *verifier) dmarcRecord(ctx context.Context, domain string) (map[string]string, error) {
+	values, err := v.resolver.LookupTXT(ctx, "_dmarc."+domain)
+	if err != nil {
+		if IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("dmarc lookup for %s failed: %w", domain, err)
+	}
+	for _, value := range values {
+		if tags := ParseTagList(value); tags["v"] == "DMARC1" {
+			return tags, nil
+		}
+	}
+	return nil, nil
+}
+
+// fromDomain returns the lowercased domain of the From address
+func fromDomain(value string) string {
+	if address, err := mail.ParseAddress(value); err == nil {
+		value = address.Address
+	}
+	return strings.ToLower(strings.Trim(utils.ExtractDomainFromEmail(value), "<> "))
+}
+
+// DomainsAligned compares an authenticated domain with the From domain. Relaxed alignment
+// compares the organizational domains.
+func DomainsAligned(domain, fromDomain string, strict bool) bool {
+	if domain == "" {
+		return false
+	}
+	if strict || strings.EqualFold(domain, fromDomain) {
+		return strings.EqualFold(domain, fromDomain)
+	}
+	return strings.EqualFold(organizationalDomain(domain), organizationalDomain(fromDomain))
+}
+
+// organizationalDomain returns the registrable domain by the public suffix list, e.g. bank.co.uk
+// for mail.bank.co.uk. Public suffixes themselves are returned as they are.
+func organizationalDomain(domain string) string {
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+	sld, tld, err := utils.SplitDomain(domain)
+	if err != nil {
+		return domain
+	}
+	return sld + "." + tld
+}
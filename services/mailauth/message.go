@@ -0,0 +1,91 @@
+package mailauth
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+)
+
+var errNoHeaders = errors.New("message has no headers")
+
+// headerField is one header of a message as it appears in the source, folding and the
+// terminating CRLF included, which DKIM simple canonicalization signs unchanged
+type headerField struct {
+	name string
+	raw  string
+}
+
+// value returns the unfolded value of the header
+func (f headerField) value() string {
+	_, value, _ := strings.Cut(f.raw, ":")
+	value = strings.ReplaceAll(value, "\r\n", "")
+	return strings.TrimSpace(value)
+}
+
+type message struct {
+	headers []headerField
+	body    []byte
+}
+
+// parseMessage splits a raw message into its header fields and body. Bare LF line endings, left
+// by some stores, are turned into CRLF first as signatures are computed over CRLF lines.
+func parseMessage(raw []byte) (*message, error) {
+	data := bytes.ReplaceAll(raw, []byte("\r\n"), []byte("\n"))
+	data = bytes.ReplaceAll(data, []byte("\n"), []byte("\r\n"))
+
+	headerSection, body := data, []byte(nil)
+	if index := bytes.Index(data, []byte("\r\n\r\n")); index >= 0 {
+		headerSection, body = data[:index+2], data[index+4:]
+	}
+
+	msg := &message{body: body}
+	for _, line := range strings.SplitAfter(string(headerSection), "\r\n") {
+		if line == "" || line == "\r\n" {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			if len(msg.headers) > 0 {
+				msg.headers[len(msg.headers)-1].raw += line
+			}
+			continue
+		}
+		name, _, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		msg.headers = append(msg.headers, headerField{name: strings.TrimRight(name, " \t"), raw: line})
+	}
+
+	if len(msg.headers) == 0 {
+		return nil, errNoHeaders
+	}
+	return msg, nil
+}
+
+// get returns the headers named name, top to bottom
+func (m *message) get(name string) []headerField {
+	var fields []headerField
+	for _, field := range m.headers {
+		if strings.EqualFold(field.name, name) {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// ParseTagList parses "k=v; k=v" records and header values like DKIM and DMARC, keys lowercased
+func ParseTagList(record string) map[string]string {
+	tags := map[string]string{}
+	for _, part := range strings.Split(record, ";") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok {
+			tags[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+		}
+	}
+	return tags
+}
+
+// stripWhitespace removes the folding whitespace base64 values of DKIM tags may contain
+func stripWhitespace(value string) string {
+	return strings.Join(strings.Fields(value), "")
+}
@@ -0,0 +1,484 @@
+package mailauth
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/customeros/mailstack/internal/enum"
+	"github.com/customeros/mailstack/internal/models"
+)
+
+const (
+	// maxSPFLookups is the RFC 7208 limit of DNS querying terms in one SPF evaluation
+	maxSPFLookups = 10
+	// maxSPFMXHosts is the RFC 7208 limit of hosts an mx mechanism resolves
+	maxSPFMXHosts = 10
+)
+
+// receivedAddress matches the bracketed or parenthesized address of the sending host in the
+// from clause of a Received header, "(mail.acme.io [203.0.113.5])" or "(2001:db8::1)"
+var receivedAddress = regexp.MustCompile(`[\[(](?:IPv6:)?([0-9A-Fa-f:.]{3,})[\])]`)
+
+// spfError ends an evaluation with a temperror or permerror
+type spfError struct {
+	result enum.AuthResult
+	detail string
+}
+
+func (e *spfError) Error() string {
+	return e.detail
+}
+
+// spfCheck is one SPF evaluation, check_host() of RFC 7208, sharing its lookup count with
+// included and redirected domains
+type spfCheck struct {
+	resolver       DNSResolver
+	ip             net.IP
+	sender         string
+	trustedInclude string
+	lookups        int
+}
+
+// EvaluateSPF runs check_host() for ip sending as sender on behalf of domain and returns the
+// result with a detail. An include of trustedInclude, when set, matches without being looked
+// up, e.g. the include of a provider relaying from hosts we do not know.
+func EvaluateSPF(ctx context.Context, resolver DNSResolver, ip net.IP, sender, domain, trustedInclude string) (enum.AuthResult, string) {
+	check := &spfCheck{resolver: resolver, ip: ip, sender: sender, trustedInclude: trustedInclude}
+	return check.evaluate(ctx, strings.ToLower(domain))
+}
+
+// verifySPF evaluates SPF for the host that handed the message to our provider, the topmost
+// Received hop with a public address outside the trusted networks, and the MAIL FROM domain of
+// the Return-Path
+func (v *verifier) verifySPF(ctx context.Context, msg *message) models.SPFResult {
+	result := models.SPFResult{Result: enum.AuthResultNone}
+
+	returnPaths := msg.get("Return-Path")
+	if len(returnPaths) == 0 {
+		result.Detail = "message has no Return-Path"
+		return result
+	}
+	sender := strings.Trim(returnPaths[0].value(), "<> ")
+	_, domain, ok := strings.Cut(sender, "@")
+	if !ok || domain == "" {
+		result.Detail = "null sender"
+		return result
+	}
+	result.Domain = strings.ToLower(domain)
+
+	ip := receivedClientIP(msg, v.trustedNetworks)
+	if ip == nil {
+		result.Detail = "no Received header names the sending host"
+		return result
+	}
+	result.ClientIP = ip.String()
+
+	result.Result, result.Detail = EvaluateSPF(ctx, v.resolver, ip, sender, result.Domain, "")
+	return result
+}
+
+// receivedClientIP returns the address of the topmost Received hop coming from a public address
+// outside trusted. Hops between the relays of our provider are skipped that way, while the
+// address of the first hop from outside is written by the provider and can not be forged.
+func receivedClientIP(msg *message, trusted []*net.IPNet) net.IP {
+	for _, field := range msg.get("Received") {
+		from := field.value()
+		if index := strings.Index(strings.ToLower(from), " by "); index >= 0 {
+			from = from[:index]
+		}
+		if !strings.HasPrefix(strings.ToLower(from), "from ") {
+			continue
+		}
+		for _, match := range receivedAddress.FindAllStringSubmatch(from, -1) {
+			ip := net.ParseIP(match[1])
+			if ip != nil && ip.IsGlobalUnicast() && !ip.IsPrivate() && !inNetworks(ip, trusted) {
+				return ip
+			}
+		}
+	}
+	return nil
+}
+
+func inNetworks(ip net.IP, networks []*net.IPNet) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluate runs check_host() for domain and returns the result with a detail
+func (c *spfCheck) evaluate(ctx context.Context, domain string) (enum.AuthResult, string) {
+	record, err := c.record(ctx, domain)
+	if err != nil {
+		return spfErrorResult(err)
+	}
+	if record == "" {
+		return enum.AuthResultNone, domain + " publishes no SPF record"
+	}
+
+	redirect := ""
+	for _, term := range strings.Fields(record)[1:] {
+		if name, value, ok := strings.Cut(term, "="); ok && isSPFModifierName(name) {
+			if strings.EqualFold(name, "redirect") {
+				redirect = value
+			}
+			continue
+		}
+
+		qualifier := byte('+')
+		if strings.ContainsRune("+-~?", rune(term[0])) {
+			qualifier, term = term[0], term[1:]
+		}
+		matched, err := c.matches(ctx, domain, term)
+		if err != nil {
+			return spfErrorResult(err)
+		}
+		if matched {
+			return qualifierResult(qualifier), fmt.Sprintf("%s matched %s of %s", c.ip, term, domain)
+		}
+	}
+
+	if redirect == "" {
+		return enum.AuthResultNeutral, fmt.Sprintf("no mechanism of %s matched %s", domain, c.ip)
+	}
+	target, err := c.expand(redirect, domain)
+	if err == nil {
+		err = c.countLookup()
+	}
+	if err != nil {
+		return spfErrorResult(err)
+	}
+	result, detail := c.evaluate(ctx, target)
+	if result == enum.AuthResultNone {
+		return enum.AuthResultPermError, "redirect to " + target + " without an SPF record"
+	}
+	return result, detail
+}
+
+// matches tells whether the client address matches one mechanism of the record of domain
+func (c *spfCheck) matches(ctx context.Context, domain, term string) (bool, error) {
+	name, value := term, ""
+	if index := strings.IndexAny(term, ":/"); index >= 0 {
+		name, value = term[:index], term[index:]
+	}
+	name = strings.ToLower(name)
+
+	switch name {
+	case "all":
+		return true, nil
+	case "ip4", "ip6":
+		return ipMatches(c.ip, strings.TrimPrefix(value, ":"))
+	case "include":
+		target, err := c.targetDomain(value, domain, true)
+		if err != nil {
+			return false, err
+		}
+		if c.trustedInclude != "" && strings.EqualFold(target, c.trustedInclude) {
+			return true, nil
+		}
+		result, detail := c.evaluate(ctx, target)
+		switch result {
+		case enum.AuthResultPass:
+			return true, nil
+		case enum.AuthResultTempError:
+			return false, &spfError{enum.AuthResultTempError, detail}
+		case enum.AuthResultPermError, enum.AuthResultNone:
+			return false, &spfError{enum.AuthResultPermError, "include of " + target + ": " + detail}
+		}
+		return false, nil
+	case "a", "mx":
+		spec, ip4Prefix, ip6Prefix, err := parseDualCIDR(value)
+		if err != nil {
+			return false, err
+		}
+		target, err := c.targetDomain(spec, domain, false)
+		if err != nil {
+			return false, err
+		}
+		return c.hostsMatch(ctx, name, target, ip4Prefix, ip6Prefix)
+	case "exists":
+		target, err := c.targetDomain(value, domain, true)
+		if err != nil {
+			return false, err
+		}
+		addrs, err := c.resolver.LookupHost(ctx, target)
+		if err != nil && !IsNotFound(err) {
+			return false, &spfError{enum.AuthResultTempError, fmt.Sprintf("lookup of %s failed: %v", target, err)}
+		}
+		return len(addrs) > 0, nil
+	case "ptr":
+		// deprecated by RFC 7208 and slow, treated as never matching
+		return false, c.countLookup()
+	}
+	return false, &spfError{enum.AuthResultPermError, "unknown mechanism " + term}
+}
+
+// targetDomain counts the lookup of a domain querying mechanism and returns its domain spec
+// with macros expanded, the current domain when the spec is empty and may be
+func (c *spfCheck) targetDomain(value, domain string, required bool) (string, error) {
+	if err := c.countLookup(); err != nil {
+		return "", err
+	}
+	spec := strings.TrimPrefix(value, ":")
+	if spec == "" {
+		if required {
+			return "", &spfError{enum.AuthResultPermError, "mechanism without a domain"}
+		}
+		return domain, nil
+	}
+	return c.expand(spec, domain)
+}
+
+// hostsMatch resolves the a or mx targets and compares them with the client address
+func (c *spfCheck) hostsMatch(ctx context.Context, mechanism, domain string, ip4Prefix, ip6Prefix int) (bool, error) {
+	hosts := []string{domain}
+	if mechanism == "mx" {
+		records, err := c.resolver.LookupMX(ctx, domain)
+		if err != nil && !IsNotFound(err) {
+			return false, &spfError{enum.AuthResultTempError, fmt.Sprintf("mx lookup for %s failed: %v", domain, err)}
+		}
+		if len(records) > maxSPFMXHosts {
+			return false, &spfError{enum.AuthResultPermError, domain + " has more than 10 mx hosts"}
+		}
+		hosts = hosts[:0]
+		for _, record := range records {
+			hosts = append(hosts, strings.TrimSuffix(record.Host, "."))
+		}
+	}
+
+	for _, host := range hosts {
+		addrs, err := c.resolver.LookupHost(ctx, host)
+		if err != nil && !IsNotFound(err) {
+			return false, &spfError{enum.AuthResultTempError, fmt.Sprintf("host lookup for %s failed: %v", host, err)}
+		}
+		for _, addr := range addrs {
+			ip := net.ParseIP(addr)
+			if ip == nil || (ip.To4() == nil) != (c.ip.To4() == nil) {
+				continue
+			}
+			prefix, bits := ip6Prefix, 128
+			if ip.To4() != nil {
+				ip, prefix, bits = ip.To4(), ip4Prefix, 32
+			}
+			if (&net.IPNet{IP: ip.Mask(net.CIDRMask(prefix, bits)), Mask: net.CIDRMask(prefix, bits)}).Contains(c.ip) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// record returns the SPF record of domain, empty when it publishes none
+func (c *spfCheck) record(ctx context.Context, domain string) (string, error) {
+	values, err := c.resolver.LookupTXT(ctx, domain)
+	if err != nil {
+		if IsNotFound(err) {
+			return "", nil
+		}
+		return "", &spfError{enum.AuthResultTempError, fmt.Sprintf("txt lookup for %s failed: %v", domain, err)}
+	}
+
+	var records []string
+	for _, value := range values {
+		lower := strings.ToLower(value)
+		if lower == "v=spf1" || strings.HasPrefix(lower, "v=spf1 ") {
+			records = append(records, value)
+		}
+	}
+	if len(records) > 1 {
+		return "", &spfError{enum.AuthResultPermError, domain + " publishes more than one SPF record"}
+	}
+	if len(records) == 0 {
+		return "", nil
+	}
+	return records[0], nil
+}
+
+func (c *spfCheck) countLookup() error {
+	if c.lookups++; c.lookups > maxSPFLookups {
+		return &spfError{enum.AuthResultPermError, "spf evaluation exceeds 10 dns lookups"}
+	}
+	return nil
+}
+
+// expand replaces the macros of a domain spec (RFC 7208 section 7). The HELO name is not
+// known from the message, %{h} expands to the current domain.
+func (c *spfCheck) expand(spec, domain string) (string, error) {
+	if !strings.Contains(spec, "%") {
+		return spec, nil
+	}
+
+	var expanded strings.Builder
+	for i := 0; i < len(spec); i++ {
+		if spec[i] != '%' {
+			expanded.WriteByte(spec[i])
+			continue
+		}
+		if i+1 == len(spec) {
+			return "", &spfError{enum.AuthResultPermError, "invalid macro in " + spec}
+		}
+		i++
+		switch spec[i] {
+		case '%':
+			expanded.WriteByte('%')
+		case '_':
+			expanded.WriteByte(' ')
+		case '-':
+			expanded.WriteString("%20")
+		case '{':
+			end := strings.IndexByte(spec[i:], '}')
+			if end < 2 {
+				return "", &spfError{enum.AuthResultPermError, "invalid macro in " + spec}
+			}
+			value, err := c.macro(spec[i+1:i+end], domain)
+			if err != nil {
+				return "", err
+			}
+			expanded.WriteString(value)
+			i += end
+		default:
+			return "", &spfError{enum.AuthResultPermError, "invalid macro in " + spec}
+		}
+	}
+	return expanded.String(), nil
+}
+
+// macro expands one "{letter digits r delimiters}" macro
+func (c *spfCheck) macro(macro, domain string) (string, error) {
+	local, senderDomain, _ := strings.Cut(c.sender, "@")
+	var value string
+	switch strings.ToLower(macro[:1]) {
+	case "s":
+		value = c.sender
+	case "l":
+		value = local
+	case "o":
+		value = senderDomain
+	case "d", "h":
+		value = domain
+	case "i":
+		value = macroIP(c.ip)
+	case "p":
+		value = "unknown"
+	case "v":
+		value = "ip6"
+		if c.ip.To4() != nil {
+			value = "in-addr"
+		}
+	default:
+		return "", &spfError{enum.AuthResultPermError, "unknown macro letter in %{" + macro + "}"}
+	}
+
+	transformers := macro[1:]
+	digits := strings.TrimLeft(transformers, "0123456789")
+	keep := 0
+	if count := transformers[:len(transformers)-len(digits)]; count != "" {
+		keep, _ = strconv.Atoi(count)
+		if keep == 0 {
+			return "", &spfError{enum.AuthResultPermError, "invalid macro %{" + macro + "}"}
+		}
+	}
+	reverse := strings.HasPrefix(strings.ToLower(digits), "r")
+	delimiters := strings.TrimPrefix(strings.TrimPrefix(digits, "r"), "R")
+	if delimiters == "" {
+		delimiters = "."
+	}
+
+	parts := strings.FieldsFunc(value, func(r rune) bool { return strings.ContainsRune(delimiters, r) })
+	if reverse {
+		for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+			parts[i], parts[j] = parts[j], parts[i]
+		}
+	}
+	if keep > 0 && keep < len(parts) {
+		parts = parts[len(parts)-keep:]
+	}
+	return strings.Join(parts, "."), nil
+}
+
+// macroIP writes an IPv4 address dotted and an IPv6 address as dot separated nibbles
+func macroIP(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.String()
+	}
+	nibbles := make([]string, 0, 32)
+	for _, b := range ip.To16() {
+		nibbles = append(nibbles, strconv.FormatUint(uint64(b>>4), 16), strconv.FormatUint(uint64(b&0xf), 16))
+	}
+	return strings.Join(nibbles, ".")
+}
+
+// parseDualCIDR splits ":domain/24//64" of an a or mx mechanism into the domain spec and the
+// IPv4 and IPv6 prefix lengths
+func parseDualCIDR(value string) (string, int, int, error) {
+	ip4Prefix, ip6Prefix := 32, 128
+	spec, ip6, hasIP6 := strings.Cut(value, "//")
+	spec, ip4, hasIP4 := strings.Cut(spec, "/")
+
+	var err error
+	if hasIP4 {
+		if ip4Prefix, err = strconv.Atoi(ip4); err != nil || ip4Prefix < 0 || ip4Prefix > 32 {
+			return "", 0, 0, &spfError{enum.AuthResultPermError, "invalid cidr length in " + value}
+		}
+	}
+	if hasIP6 {
+		if ip6Prefix, err = strconv.Atoi(ip6); err != nil || ip6Prefix < 0 || ip6Prefix > 128 {
+			return "", 0, 0, &spfError{enum.AuthResultPermError, "invalid cidr length in " + value}
+		}
+	}
+	return spec, ip4Prefix, ip6Prefix, nil
+}
+
+// ipMatches tells whether ip is the address or inside the CIDR of an ip4 or ip6 mechanism
+func ipMatches(ip net.IP, value string) (bool, error) {
+	if !strings.Contains(value, "/") {
+		parsed := net.ParseIP(value)
+		if parsed == nil {
+			return false, &spfError{enum.AuthResultPermError, "invalid address " + value}
+		}
+		return ip.Equal(parsed), nil
+	}
+	_, network, err := net.ParseCIDR(value)
+	if err != nil {
+		return false, &spfError{enum.AuthResultPermError, "invalid network " + value}
+	}
+	return network.Contains(ip), nil
+}
+
+// isSPFModifierName tells a modifier (name=value) from a mechanism with a macro containing "="
+func isSPFModifierName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+func qualifierResult(qualifier byte) enum.AuthResult {
+	switch qualifier {
+	case '-':
+		return enum.AuthResultFail
+	case '~':
+		return enum.AuthResultSoftFail
+	case '?':
+		return enum.AuthResultNeutral
+	}
+	return enum.AuthResultPass
+}
+
+func spfErrorResult(err error) (enum.AuthResult, string) {
+	if spfErr, ok := err.(*spfError); ok {
+		return spfErr.result, spfErr.detail
+	}
+	return enum.AuthResultTempError, err.Error()
+}
@@ -0,0 +1,150 @@
+package mailauth
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/internal/enum"
+)
+
+type fakeResolver struct {
+	txt     map[string][]string
+	hosts   map[string][]string
+	mx      map[string][]*net.MX
+	failing bool // every lookup fails with a server error
+}
+
+func (r *fakeResolver) lookup(name string, found bool) error {
+	if r.failing {
+		return &net.DNSError{Err: "server misbehaving", Name: name, IsTemporary: true}
+	}
+	if !found {
+		return &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return nil
+}
+
+func (r *fakeResolver) LookupTXT(_ context.Context, name string) ([]string, error) {
+	values, ok := r.txt[name]
+	return values, r.lookup(name, ok)
+}
+
+func (r *fakeResolver) LookupMX(_ context.Context, name string) ([]*net.MX, error) {
+	values, ok := r.mx[name]
+	return values, r.lookup(name, ok)
+}
+
+func (r *fakeResolver) LookupHost(_ context.Context, host string) ([]string, error) {
+	values, ok := r.hosts[host]
+	return values, r.lookup(host, ok)
+}
+
+func TestSPFEvaluate(t *testing.T) {
+	resolver := &fakeResolver{
+		txt: map[string][]string{
+			"acme.io":          {"google-site-verification=abc", "v=spf1 ip4:203.0.113.0/24 mx a:out.acme.io/28 include:_spf.mailer.io -all"},
+			"_spf.mailer.io":   {"v=spf1 ip6:2001:db8::/32 exists:%{i}._ip.mailer.io ~all"},
+			"soft.io":          {"v=spf1 redirect=acme.io"},
+			"neutral.io":       {"v=spf1 ?all"},
+			"double.io":        {"v=spf1 -all", "v=spf1 +all"},
+			"broken.io":        {"v=spf1 foo:bar -all"},
+			"redirect-none.io": {"v=spf1 redirect=nowhere.io"},
+			"loop.io":          {"v=spf1 include:loop.io -all"},
+		},
+		hosts: map[string][]string{
+			"mx1.acme.io":                 {"192.0.2.10"},
+			"out.acme.io":                 {"198.51.100.16"},
+			"198.51.100.99._ip.mailer.io": {"127.0.0.2"},
+		},
+		mx: map[string][]*net.MX{
+			"acme.io": {{Host: "mx1.acme.io.", Pref: 10}},
+		},
+	}
+	evaluate := func(ip, domain string) enum.AuthResult {
+		result, _ := EvaluateSPF(context.Background(), resolver, net.ParseIP(ip), "jane@"+domain, domain, "")
+		return result
+	}
+
+	assert.Equal(t, enum.AuthResultPass, evaluate("203.0.113.7", "acme.io"))   // ip4
+	assert.Equal(t, enum.AuthResultPass, evaluate("192.0.2.10", "acme.io"))    // mx
+	assert.Equal(t, enum.AuthResultPass, evaluate("198.51.100.30", "acme.io")) // a with cidr
+	assert.Equal(t, enum.AuthResultPass, evaluate("2001:db8::25", "acme.io"))  // include ip6
+	assert.Equal(t, enum.AuthResultPass, evaluate("198.51.100.99", "acme.io")) // include exists macro
+	assert.Equal(t, enum.AuthResultFail, evaluate("192.0.2.99", "acme.io"))
+	assert.Equal(t, enum.AuthResultFail, evaluate("192.0.2.99", "soft.io")) // redirect
+	assert.Equal(t, enum.AuthResultNeutral, evaluate("192.0.2.99", "neutral.io"))
+	assert.Equal(t, enum.AuthResultNone, evaluate("192.0.2.99", "unknown.io"))
+	assert.Equal(t, enum.AuthResultPermError, evaluate("192.0.2.99", "double.io"))
+	assert.Equal(t, enum.AuthResultPermError, evaluate("192.0.2.99", "broken.io"))
+	assert.Equal(t, enum.AuthResultPermError, evaluate("192.0.2.99", "redirect-none.io"))
+	assert.Equal(t, enum.AuthResultPermError, evaluate("192.0.2.99", "loop.io")) // lookup limit
+
+	// a trusted include matches without being looked up
+	result, _ := EvaluateSPF(context.Background(), resolver, net.ParseIP("192.0.2.99"), "jane@acme.io", "acme.io", "_spf.mailer.io")
+	assert.Equal(t, enum.AuthResultPass, result)
+
+	resolver.failing = true
+	assert.Equal(t, enum.AuthResultTempError, evaluate("192.0.2.99", "acme.io"))
+}
+
+func TestSPFMacros(t *testing.T) {
+	check := &spfCheck{ip: net.ParseIP("192.0.2.3"), sender: "strong-bad@email.example.com"}
+
+	for spec, expected := range map[string]string{
+		"%{s}":                  "strong-bad@email.example.com",
+		"%{o}":                  "email.example.com",
+		"%{d4}":                 "email.example.com",
+		"%{d2}":                 "example.com",
+		"%{dr}":                 "com.example.email",
+		"%{d2r}":                "example.email",
+		"%{l-}":                 "strong.bad",
+		"%{lr-}":                "bad.strong",
+		"%{ir}.%{v}._spf.%{d2}": "3.2.0.192.in-addr._spf.example.com",
+		"%%%_%-":                "% %20",
+	} {
+		expanded, err := check.expand(spec, "email.example.com")
+		assert.NoError(t, err, spec)
+		assert.Equal(t, expected, expanded, spec)
+	}
+
+	check.ip = net.ParseIP("2001:db8::cb01")
+	expanded, err := check.expand("%{ir}.%{v}._spf.%{d2}", "email.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0.b.c.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6._spf.example.com", expanded)
+
+	_, err = check.expand("%{x}", "email.example.com")
+	var spfErr *spfError
+	assert.True(t, errors.As(err, &spfErr))
+}
+
+func TestReceivedClientIP(t *testing.T) {
+	msg := mustParse(t, "Received: by mx.hostedemail.com (Postfix) id 1234\r\n"+
+		"Received: from relay.internal (relay.internal [10.0.0.5])\r\n\tby mx.hostedemail.com with ESMTP id 99\r\n"+
+		"Received: from mail.acme.io (mail.acme.io [203.0.113.5])\r\n\tby relay.internal with ESMTPS id 88\r\n"+
+		"Received: from laptop (unknown [198.51.100.1]) by mail.acme.io\r\n"+
+		"From: jane@acme.io\r\n\r\n")
+	assert.Equal(t, "203.0.113.5", receivedClientIP(msg, nil).String())
+
+	msg = mustParse(t, "Received: from BN8PR (2001:db8:408:8c::19) by BN9PR with HTTPS\r\nFrom: jane@acme.io\r\n\r\n")
+	assert.Equal(t, "2001:db8:408:8c::19", receivedClientIP(msg, nil).String())
+
+	msg = mustParse(t, "From: jane@acme.io\r\n\r\n")
+	assert.Nil(t, receivedClientIP(msg, nil))
+
+	// relays of the provider between public addresses are skipped
+	msg = mustParse(t, "Received: from edge.hostedemail.com (edge.hostedemail.com [192.0.2.10])\r\n\tby mx.hostedemail.com with ESMTP id 99\r\n"+
+		"Received: from mail.acme.io (mail.acme.io [203.0.113.5])\r\n\tby edge.hostedemail.com with ESMTPS id 88\r\n"+
+		"From: jane@acme.io\r\n\r\n")
+	assert.Equal(t, "192.0.2.10", receivedClientIP(msg, nil).String())
+	trusted, err := parseNetworks([]string{"192.0.2.0/24", " "})
+	require.NoError(t, err)
+	assert.Equal(t, "203.0.113.5", receivedClientIP(msg, trusted).String())
+
+	_, err = parseNetworks([]string{"192.0.2.1"})
+	assert.Error(t, err)
+}
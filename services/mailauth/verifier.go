@@ -0,0 +1,107 @@
+package mailauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	tracingLog "github.com/opentracing/opentracing-go/log"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+)
+
+// verifyTimeout bounds the DNS lookups of verifying one message, slow lookups end in temperror
+const verifyTimeout = 15 * time.Second
+
+var errRawMessageRequired = errors.New("raw message is required to verify mail authentication")
+
+// DNSResolver is the subset of net.Resolver used for verification and SPF evaluation
+type DNSResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+type verifier struct {
+	resolver        DNSResolver
+	trustedNetworks []*net.IPNet
+	now             func() time.Time
+}
+
+// NewMailAuthVerifier returns a verifier that skips Received hops from trustedNetworks, the CIDR
+// ranges of our provider's own relays, when looking for the host that sent the message
+func NewMailAuthVerifier(trustedNetworks []string) (interfaces.MailAuthVerifier, error) {
+	networks, err := parseNetworks(trustedNetworks)
+	if err != nil {
+		return nil, err
+	}
+	return &verifier{
+		resolver:        net.DefaultResolver,
+		trustedNetworks: networks,
+		now:             time.Now,
+	}, nil
+}
+
+func parseNetworks(cidrs []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted network %q: %w", cidr, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// Verify checks the DKIM signatures of the raw message against the keys its signers publish,
+// evaluates SPF for the host that handed it to our provider and DMARC for its From domain.
+// Failures to verify are results, only a message that cannot be parsed is an error.
+func (v *verifier) Verify(ctx context.Context, rawMessage []byte) (*models.MailAuthResults, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "MailAuthVerifier.Verify")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+
+	if len(rawMessage) == 0 {
+		tracing.TraceErr(span, errRawMessageRequired)
+		return nil, errRawMessageRequired
+	}
+	msg, err := parseMessage(rawMessage)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, verifyTimeout)
+	defer cancel()
+
+	results := &models.MailAuthResults{
+		DKIM: v.verifyDKIM(ctx, msg),
+		SPF:  v.verifySPF(ctx, msg),
+	}
+	results.DMARC = v.verifyDMARC(ctx, msg, results.SPF, results.DKIM)
+	results.VerifiedAt = v.now().UTC()
+
+	span.LogFields(
+		tracingLog.String("spf", string(results.SPF.Result)),
+		tracingLog.Int("dkimSignatures", len(results.DKIM)),
+		tracingLog.String("dmarc", string(results.DMARC.Result)),
+	)
+	return results, nil
+}
+
+// IsNotFound tells whether a DNS lookup failed because the name or record does not exist
+func IsNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
@@ -0,0 +1,120 @@
+package mailauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/internal/enum"
+)
+
+func TestVerify(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	public, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+
+	resolver := &fakeResolver{txt: map[string][]string{
+		"sel._domainkey.acme.io": {"v=DKIM1; p=" + base64.StdEncoding.EncodeToString(public)},
+		"acme.io":                {"v=spf1 ip4:203.0.113.0/24 -all"},
+		"bounces.acme.io":        {"v=spf1 ip4:198.51.100.0/24 -all"},
+		"_dmarc.acme.io":         {"v=DMARC1; p=reject; sp=quarantine; adkim=s"},
+		"evil.co.uk":             {"v=spf1 ip4:192.0.2.0/24 -all"},
+		"_dmarc.bank.co.uk":      {"v=DMARC1; p=reject; sp=quarantine"},
+		"_dmarc.co.uk":           {"v=DMARC1; p=none"},
+	}}
+	now := time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)
+	v := &verifier{resolver: resolver, now: func() time.Time { return now }}
+
+	envelope := func(returnPath, clientIP string) string {
+		return "Return-Path: <" + returnPath + ">\r\n" +
+			"Received: from mail.acme.io (mail.acme.io [" + clientIP + "])\r\n\tby mx.hostedemail.com with ESMTPS id 88\r\n"
+	}
+	signed := signMessage(t, testMessage, key, "rsa-sha256", "relaxed/relaxed")
+
+	t.Run("everything passes", func(t *testing.T) {
+		results, err := v.Verify(context.Background(), []byte(envelope("jane@acme.io", "203.0.113.5")+signed))
+		require.NoError(t, err)
+		assert.Equal(t, enum.AuthResultPass, results.SPF.Result)
+		assert.Equal(t, "203.0.113.5", results.SPF.ClientIP)
+		require.Len(t, results.DKIM, 1)
+		assert.Equal(t, enum.AuthResultPass, results.DKIM[0].Result)
+		assert.Equal(t, enum.AuthResultPass, results.DMARC.Result)
+		assert.Equal(t, "reject", results.DMARC.Policy)
+		assert.True(t, results.DMARC.SPFAligned)
+		assert.True(t, results.DMARC.DKIMAligned)
+		assert.Equal(t, now, results.VerifiedAt)
+	})
+
+	t.Run("relaxed spf alignment of a subdomain", func(t *testing.T) {
+		results, err := v.Verify(context.Background(), []byte(envelope("bounce@bounces.acme.io", "198.51.100.5")+testMessage))
+		require.NoError(t, err)
+		assert.Equal(t, enum.AuthResultPass, results.SPF.Result)
+		assert.Empty(t, results.DKIM)
+		assert.Equal(t, enum.AuthResultPass, results.DMARC.Result)
+		assert.True(t, results.DMARC.SPFAligned)
+	})
+
+	t.Run("spoofed from", func(t *testing.T) {
+		results, err := v.Verify(context.Background(), []byte(envelope("eve@evil.io", "192.0.2.66")+testMessage))
+		require.NoError(t, err)
+		assert.Equal(t, enum.AuthResultNone, results.SPF.Result)
+		assert.Equal(t, enum.AuthResultFail, results.DMARC.Result)
+		assert.False(t, results.DMARC.SPFAligned)
+		assert.False(t, results.DMARC.DKIMAligned)
+	})
+
+	t.Run("subdomain falls back to the organizational policy", func(t *testing.T) {
+		raw := envelope("jane@acme.io", "203.0.113.5") + "From: jane@eu.acme.io\r\nSubject: Hi\r\n\r\nHi\r\n"
+		results, err := v.Verify(context.Background(), []byte(raw))
+		require.NoError(t, err)
+		assert.Equal(t, "eu.acme.io", results.DMARC.Domain)
+		assert.Equal(t, "quarantine", results.DMARC.Policy)
+		assert.Equal(t, enum.AuthResultPass, results.DMARC.Result)
+	})
+
+	t.Run("multi label public suffix", func(t *testing.T) {
+		raw := envelope("eve@evil.co.uk", "192.0.2.66") + "From: ceo@bank.co.uk\r\nSubject: Hi\r\n\r\nHi\r\n"
+		results, err := v.Verify(context.Background(), []byte(raw))
+		require.NoError(t, err)
+		assert.Equal(t, enum.AuthResultPass, results.SPF.Result)
+		assert.False(t, results.DMARC.SPFAligned)
+		assert.Equal(t, enum.AuthResultFail, results.DMARC.Result)
+		assert.Equal(t, "reject", results.DMARC.Policy)
+	})
+
+	t.Run("organizational fallback below a multi label suffix", func(t *testing.T) {
+		raw := envelope("eve@evil.co.uk", "192.0.2.66") + "From: ceo@mail.bank.co.uk\r\nSubject: Hi\r\n\r\nHi\r\n"
+		results, err := v.Verify(context.Background(), []byte(raw))
+		require.NoError(t, err)
+		assert.Equal(t, "quarantine", results.DMARC.Policy) // from _dmarc.bank.co.uk, not _dmarc.co.uk
+		assert.Equal(t, enum.AuthResultFail, results.DMARC.Result)
+
+		raw = envelope("eve@evil.co.uk", "192.0.2.66") + "From: ceo@unknown.co.uk\r\nSubject: Hi\r\n\r\nHi\r\n"
+		results, err = v.Verify(context.Background(), []byte(raw))
+		require.NoError(t, err)
+		assert.Equal(t, enum.AuthResultNone, results.DMARC.Result)
+	})
+
+	t.Run("strict dkim alignment", func(t *testing.T) {
+		assert.True(t, DomainsAligned("acme.io", "acme.io", true))
+		assert.False(t, DomainsAligned("mail.acme.io", "acme.io", true))
+		assert.True(t, DomainsAligned("mail.acme.io", "acme.io", false))
+		assert.False(t, DomainsAligned("acme.io.evil.io", "acme.io", false))
+		assert.False(t, DomainsAligned("evil.co.uk", "bank.co.uk", false))
+		assert.False(t, DomainsAligned("a.co.uk", "b.co.uk", false))
+		assert.True(t, DomainsAligned("mail.bank.co.uk", "bank.co.uk", false))
+	})
+
+	t.Run("raw source required", func(t *testing.T) {
+		_, err := v.Verify(context.Background(), nil)
+		assert.ErrorIs(t, err, errRawMessageRequired)
+	})
+}
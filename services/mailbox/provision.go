@@ -0,0 +1,161 @@
+package mailbox
+
+import (
+	"context"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+	tracingLog "github.com/opentracing/opentracing-go/log"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/enum"
+	er "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+// ProvisionMailbox creates a hosted mailbox end to end: the tenant mailbox row, the account at
+// OpenSRS, the mailbox row with the platform IMAP and SMTP defaults and the IMAP sync. A failing
+// step undoes the steps before it, so a failed request leaves nothing behind and can be retried.
+func (s *mailboxService) ProvisionMailbox(ctx context.Context, request interfaces.ProvisionMailboxRequest) (*models.Mailbox, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "mailboxService.ProvisionMailbox")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.LogFields(
+		tracingLog.String("username", request.Username),
+		tracingLog.String("domain", request.Domain),
+		tracingLog.Bool("webmailEnabled", request.WebmailEnabled),
+		tracingLog.Object("forwardingTo", request.ForwardingTo),
+	)
+
+	tenant := utils.GetTenantFromContext(ctx)
+	if tenant == "" {
+		tracing.TraceErr(span, er.ErrTenantMissing)
+		return nil, er.ErrTenantMissing
+	}
+
+	domain := strings.ToLower(strings.TrimSpace(request.Domain))
+	emailAddress := strings.ToLower(strings.TrimSpace(request.Username)) + "@" + domain
+
+	ownsDomain, err := s.repositories.DomainRepository.CheckDomainOwnership(ctx, tenant, domain)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+	if !ownsDomain {
+		tracing.TraceErr(span, er.ErrDomainNotFound)
+		return nil, er.ErrDomainNotFound
+	}
+
+	mailbox := &models.Mailbox{
+		Tenant:          tenant,
+		UserID:          request.UserID,
+		Provider:        enum.EmailMailstack,
+		EmailAddress:    emailAddress,
+		MailboxDomain:   domain,
+		SenderID:        request.SenderID,
+		InboundEnabled:  true,
+		OutboundEnabled: true,
+		ImapUsername:    emailAddress,
+		ImapPassword:    request.Password,
+		SmtpUsername:    emailAddress,
+		SmtpPassword:    request.Password,
+	}
+	if err = validateMailboxInput(mailbox); err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+	mailbox.MailboxUser = strings.Split(mailbox.EmailAddress, "@")[0]
+
+	existing, err := s.repositories.MailboxRepository.GetMailboxByEmailAddress(ctx, mailbox.EmailAddress)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+	if existing != nil {
+		tracing.TraceErr(span, er.ErrMailboxExists)
+		return nil, er.ErrMailboxExists
+	}
+	existingTenantMailbox, err := s.repositories.TenantSettingsMailboxRepository.GetByMailbox(ctx, mailbox.EmailAddress)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+	if existingTenantMailbox != nil {
+		tracing.TraceErr(span, er.ErrMailboxExists)
+		return nil, er.ErrMailboxExists
+	}
+
+	// OpenSRS operations look the mailbox up by its tenant mailbox row, it is created first so
+	// the account can be deleted through OpenSrsService.DeleteMailbox, also by the rollback
+	tenantMailbox := &models.TenantSettingsMailbox{
+		Tenant:          tenant,
+		Domain:          domain,
+		MailboxUsername: mailbox.EmailAddress,
+		MailboxPassword: request.Password,
+		Username:        mailbox.MailboxUser,
+		UserId:          request.UserID,
+		ForwardingTo:    strings.Join(request.ForwardingTo, ","),
+		WebmailEnabled:  request.WebmailEnabled,
+		Status:          models.MailboxStatusProvisioned,
+	}
+	if err = s.repositories.TenantSettingsMailboxRepository.Create(ctx, nil, tenantMailbox); err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "failed to create tenant mailbox"))
+		return nil, errors.Wrap(err, "failed to create tenant mailbox")
+	}
+
+	err = s.openSrsService.SetupMailbox(ctx, tenant, mailbox.EmailAddress, request.Password, request.ForwardingTo, request.WebmailEnabled)
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "failed to set up mailbox with OpenSRS"))
+		if deleteErr := s.repositories.TenantSettingsMailboxRepository.Delete(ctx, tenantMailbox.ID); deleteErr != nil {
+			tracing.TraceErr(span, deleteErr)
+		}
+		return nil, errors.Wrap(err, "failed to set up mailbox with OpenSRS")
+	}
+
+	mailboxID, err := s.repositories.MailboxRepository.SaveMailbox(ctx, *mailbox)
+	if err == nil && mailboxID == "" {
+		err = errors.New("unable to create mailbox")
+	}
+	if err != nil {
+		tracing.TraceErr(span, err)
+		s.rollbackProvisioning(ctx, tenant, mailbox.EmailAddress, "")
+		return nil, err
+	}
+	mailbox.ID = mailboxID
+
+	if err = s.imapService.AddMailbox(ctx, mailbox); err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "failed to start mailbox sync"))
+		s.rollbackProvisioning(ctx, tenant, mailbox.EmailAddress, mailbox.ID)
+		return nil, errors.Wrap(err, "failed to start mailbox sync")
+	}
+
+	return mailbox, nil
+}
+
+// rollbackProvisioning removes what a failed provisioning created after the OpenSRS account.
+// Deleting the account also deletes the tenant mailbox row, which stays when OpenSRS fails so the
+// mailbox can still be deleted later. Failures are only traced, the error of the failed step is
+// the one returned to the caller.
+func (s *mailboxService) rollbackProvisioning(ctx context.Context, tenant, emailAddress, mailboxID string) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "mailboxService.rollbackProvisioning")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.LogFields(tracingLog.String("emailAddress", emailAddress), tracingLog.String("mailboxId", mailboxID))
+
+	if mailboxID != "" {
+		if err := s.imapService.RemoveMailbox(ctx, mailboxID); err != nil {
+			tracing.TraceErr(span, err)
+		}
+		if err := s.repositories.MailboxRepository.DeleteMailbox(ctx, mailboxID); err != nil {
+			tracing.TraceErr(span, err)
+		}
+	}
+
+	if err := s.openSrsService.DeleteMailbox(ctx, tenant, emailAddress); err != nil {
+		tracing.TraceErr(span, err)
+	}
+}
@@ -0,0 +1,303 @@
+package mailbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/config"
+	er "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/logger"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/repository"
+	"github.com/customeros/mailstack/internal/utils"
+	"github.com/customeros/mailstack/services/opensrs"
+)
+
+type fakeDomainRepository struct {
+	repository.DomainRepository
+}
+
+func (r *fakeDomainRepository) CheckDomainOwnership(_ context.Context, tenant, domain string) (bool, error) {
+	return tenant == "acme" && domain == "acme.io", nil
+}
+
+type fakeTenantMailboxRepository struct {
+	repository.TenantSettingsMailboxRepository
+	mailboxes map[string]*models.TenantSettingsMailbox
+	deleted   []string
+}
+
+func (r *fakeTenantMailboxRepository) GetByMailbox(ctx context.Context, mailbox string) (*models.TenantSettingsMailbox, error) {
+	tenantMailbox, ok := r.mailboxes[mailbox]
+	if !ok || tenantMailbox.Tenant != utils.GetTenantFromContext(ctx) {
+		return nil, nil
+	}
+	return tenantMailbox, nil
+}
+
+func (r *fakeTenantMailboxRepository) Create(ctx context.Context, _ *gorm.DB, mailbox *models.TenantSettingsMailbox) error {
+	mailbox.ID = "tsm_" + strings.Split(mailbox.MailboxUsername, "@")[0]
+	r.mailboxes[mailbox.MailboxUsername] = mailbox
+	return nil
+}
+
+func (r *fakeTenantMailboxRepository) Delete(_ context.Context, id string) error {
+	r.deleted = append(r.deleted, id)
+	for email, mailbox := range r.mailboxes {
+		if mailbox.ID == id {
+			delete(r.mailboxes, email)
+		}
+	}
+	return nil
+}
+
+type fakeMailboxRepository struct {
+	interfaces.MailboxRepository
+	saveID    string
+	saveErr   error
+	mailboxes map[string]*models.Mailbox
+	deleted   []string
+}
+
+func (r *fakeMailboxRepository) GetMailboxByEmailAddress(_ context.Context, emailAddress string) (*models.Mailbox, error) {
+	if mailbox, ok := r.mailboxes[emailAddress]; ok {
+		return mailbox, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeMailboxRepository) SaveMailbox(_ context.Context, mailbox models.Mailbox) (string, error) {
+	if r.saveErr != nil || r.saveID == "" {
+		return "", r.saveErr
+	}
+	mailbox.ID = r.saveID
+	r.mailboxes[mailbox.EmailAddress] = &mailbox
+	return r.saveID, nil
+}
+
+func (r *fakeMailboxRepository) DeleteMailbox(_ context.Context, id string) error {
+	r.deleted = append(r.deleted, id)
+	for email, mailbox := range r.mailboxes {
+		if mailbox.ID == id {
+			delete(r.mailboxes, email)
+		}
+	}
+	return nil
+}
+
+type fakeIMAPService struct {
+	interfaces.IMAPService
+	addErr  error
+	added   []string
+	removed []string
+}
+
+func (s *fakeIMAPService) AddMailbox(_ context.Context, mailbox *models.Mailbox) error {
+	s.added = append(s.added, mailbox.ID)
+	return s.addErr
+}
+
+func (s *fakeIMAPService) RemoveMailbox(_ context.Context, mailboxID string) error {
+	s.removed = append(s.removed, mailboxID)
+	return nil
+}
+
+// fakeOpenSRS answers the email API, each method with its response or success. The requests are
+// recorded by method.
+type fakeOpenSRS struct {
+	mu        sync.Mutex
+	responses map[string]string
+	calls     []string
+	passwords []string
+}
+
+func (f *fakeOpenSRS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		User       string                 `json:"user"`
+		Attributes map[string]interface{} `json:"attributes"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	method := strings.TrimPrefix(r.URL.Path, "/api/")
+
+	f.mu.Lock()
+	f.calls = append(f.calls, method+" "+body.User)
+	if password, ok := body.Attributes["password"].(string); ok {
+		f.passwords = append(f.passwords, password)
+	}
+	response, ok := f.responses[method]
+	f.mu.Unlock()
+
+	if !ok {
+		response = `{"success":true}`
+	}
+	_, _ = w.Write([]byte(response))
+}
+
+type provisioningTest struct {
+	service   *mailboxService
+	openSRS   *fakeOpenSRS
+	tenant    *fakeTenantMailboxRepository
+	mailboxes *fakeMailboxRepository
+	imap      *fakeIMAPService
+}
+
+// newProvisioningTest wires the mailbox service to the real OpenSRS service, talking to a fake
+// email API, so the rollback goes through the real DeleteMailbox
+func newProvisioningTest(t *testing.T, mailboxes *fakeMailboxRepository, imap *fakeIMAPService, responses map[string]string) *provisioningTest {
+	fake := &fakeOpenSRS{responses: responses}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	if mailboxes.mailboxes == nil {
+		mailboxes.mailboxes = map[string]*models.Mailbox{}
+	}
+	tenantMailboxes := &fakeTenantMailboxRepository{mailboxes: map[string]*models.TenantSettingsMailbox{}}
+	repos := &repository.Repositories{
+		DomainRepository:                &fakeDomainRepository{},
+		MailboxRepository:               mailboxes,
+		TenantSettingsMailboxRepository: tenantMailboxes,
+	}
+
+	log := logger.NewAppLogger(&logger.Config{DevMode: true})
+	log.InitLogger()
+	openSrs := opensrs.NewOpenSRSService(log, &config.OpenSRSConfig{Url: server.URL, Username: "reseller", ApiKey: "key"}, repos, imap)
+
+	return &provisioningTest{
+		service:   &mailboxService{repositories: repos, imapService: imap, openSrsService: openSrs},
+		openSRS:   fake,
+		tenant:    tenantMailboxes,
+		mailboxes: mailboxes,
+		imap:      imap,
+	}
+}
+
+func TestProvisionMailbox(t *testing.T) {
+	ctx := utils.SetTenantInContext(context.Background(), "acme")
+	request := interfaces.ProvisionMailboxRequest{Username: "Jane", Domain: "acme.io", Password: " s3cret pass "}
+
+	t.Run("success", func(t *testing.T) {
+		test := newProvisioningTest(t, &fakeMailboxRepository{saveID: "mbox_1"}, &fakeIMAPService{}, nil)
+
+		mailbox, err := test.service.ProvisionMailbox(ctx, request)
+		require.NoError(t, err)
+		assert.Equal(t, "mbox_1", mailbox.ID)
+		assert.Equal(t, "jane@acme.io", mailbox.EmailAddress)
+		assert.Equal(t, "mail.hostedemail.com", mailbox.ImapServer)
+		assert.Equal(t, " s3cret pass ", mailbox.ImapPassword)
+		assert.Equal(t, []string{"change_user jane@acme.io"}, test.openSRS.calls)
+		assert.Equal(t, []string{" s3cret pass "}, test.openSRS.passwords)
+		assert.Equal(t, []string{"mbox_1"}, test.imap.added)
+
+		tenantMailbox := test.tenant.mailboxes["jane@acme.io"]
+		require.NotNil(t, tenantMailbox)
+		assert.Equal(t, models.MailboxStatusProvisioned, tenantMailbox.Status)
+		assert.Equal(t, "jane", tenantMailbox.Username)
+		assert.Equal(t, " s3cret pass ", tenantMailbox.MailboxPassword)
+	})
+
+	t.Run("provisioned mailbox can be deleted", func(t *testing.T) {
+		test := newProvisioningTest(t, &fakeMailboxRepository{saveID: "mbox_1"}, &fakeIMAPService{}, nil)
+
+		_, err := test.service.ProvisionMailbox(ctx, request)
+		require.NoError(t, err)
+
+		require.NoError(t, test.service.openSrsService.DeleteMailbox(ctx, "acme", "jane@acme.io"))
+		assert.Equal(t, []string{"mbox_1"}, test.imap.removed)
+		assert.Equal(t, []string{"mbox_1"}, test.mailboxes.deleted)
+		assert.Equal(t, []string{"tsm_jane"}, test.tenant.deleted)
+	})
+
+	t.Run("OpenSRS setup fails", func(t *testing.T) {
+		test := newProvisioningTest(t, &fakeMailboxRepository{saveID: "mbox_1"}, &fakeIMAPService{}, map[string]string{
+			"change_user": `{"success":false,"error":"Invalid password","error_number":3}`,
+		})
+
+		_, err := test.service.ProvisionMailbox(ctx, request)
+		assert.ErrorIs(t, err, er.ErrOpenSRSInvalidRequest)
+		assert.Equal(t, []string{"change_user jane@acme.io"}, test.openSRS.calls, "nothing to delete at OpenSRS")
+		assert.Empty(t, test.mailboxes.mailboxes)
+		assert.Empty(t, test.imap.added)
+		assert.Equal(t, []string{"tsm_jane"}, test.tenant.deleted)
+		assert.Empty(t, test.tenant.mailboxes)
+	})
+
+	t.Run("saving the mailbox fails", func(t *testing.T) {
+		saveErr := errors.New("connection refused")
+		test := newProvisioningTest(t, &fakeMailboxRepository{saveErr: saveErr}, &fakeIMAPService{}, nil)
+
+		_, err := test.service.ProvisionMailbox(ctx, request)
+		assert.ErrorIs(t, err, saveErr)
+		assert.Equal(t, []string{"change_user jane@acme.io", "delete_user jane@acme.io"}, test.openSRS.calls)
+		assert.Empty(t, test.imap.added)
+		assert.Empty(t, test.imap.removed)
+		assert.Empty(t, test.mailboxes.deleted)
+		assert.Equal(t, []string{"tsm_jane"}, test.tenant.deleted)
+		assert.Empty(t, test.tenant.mailboxes)
+	})
+
+	t.Run("saving the mailbox returns no id", func(t *testing.T) {
+		test := newProvisioningTest(t, &fakeMailboxRepository{}, &fakeIMAPService{}, nil)
+
+		_, err := test.service.ProvisionMailbox(ctx, request)
+		require.Error(t, err)
+		assert.Equal(t, []string{"change_user jane@acme.io", "delete_user jane@acme.io"}, test.openSRS.calls)
+		assert.Empty(t, test.imap.added)
+		assert.Empty(t, test.tenant.mailboxes)
+	})
+
+	t.Run("starting the sync fails", func(t *testing.T) {
+		addErr := errors.New("login failed")
+		test := newProvisioningTest(t, &fakeMailboxRepository{saveID: "mbox_1"}, &fakeIMAPService{addErr: addErr}, nil)
+
+		_, err := test.service.ProvisionMailbox(ctx, request)
+		assert.ErrorIs(t, err, addErr)
+		assert.Equal(t, []string{"change_user jane@acme.io", "delete_user jane@acme.io"}, test.openSRS.calls)
+		assert.Equal(t, []string{"mbox_1"}, test.imap.removed)
+		assert.Equal(t, []string{"mbox_1"}, test.mailboxes.deleted)
+		assert.Empty(t, test.mailboxes.mailboxes)
+		assert.Equal(t, []string{"tsm_jane"}, test.tenant.deleted)
+		assert.Empty(t, test.tenant.mailboxes)
+	})
+
+	t.Run("OpenSRS delete fails during rollback", func(t *testing.T) {
+		test := newProvisioningTest(t, &fakeMailboxRepository{saveID: "mbox_1"}, &fakeIMAPService{addErr: errors.New("login failed")}, map[string]string{
+			"delete_user": `{"success":false,"error":"Permission denied","error_number":2}`,
+		})
+
+		_, err := test.service.ProvisionMailbox(ctx, request)
+		require.Error(t, err)
+		assert.Equal(t, []string{"mbox_1"}, test.mailboxes.deleted)
+		// the account is still at OpenSRS, its row is kept so the mailbox can be deleted later
+		assert.Empty(t, test.tenant.deleted)
+		assert.Contains(t, test.tenant.mailboxes, "jane@acme.io")
+	})
+
+	t.Run("tenant mailbox exists", func(t *testing.T) {
+		test := newProvisioningTest(t, &fakeMailboxRepository{saveID: "mbox_1"}, &fakeIMAPService{}, nil)
+		test.tenant.mailboxes["jane@acme.io"] = &models.TenantSettingsMailbox{ID: "tsm_old", Tenant: "acme", MailboxUsername: "jane@acme.io"}
+
+		_, err := test.service.ProvisionMailbox(ctx, request)
+		assert.ErrorIs(t, err, er.ErrMailboxExists)
+		assert.Empty(t, test.openSRS.calls)
+	})
+
+	t.Run("domain of another tenant", func(t *testing.T) {
+		test := newProvisioningTest(t, &fakeMailboxRepository{saveID: "mbox_1"}, &fakeIMAPService{}, nil)
+
+		_, err := test.service.ProvisionMailbox(ctx, interfaces.ProvisionMailboxRequest{Username: "jane", Domain: "corp.io", Password: "s3cret"})
+		assert.ErrorIs(t, err, er.ErrDomainNotFound)
+		assert.Empty(t, test.openSRS.calls)
+		assert.Empty(t, test.tenant.mailboxes)
+	})
+}
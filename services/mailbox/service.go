@@ -2,6 +2,7 @@ package mailbox
 
 import (
 	"context"
+	"crypto/x509"
 	"fmt"
 	"strings"
 
@@ -12,6 +13,7 @@ import (
 
 	"github.com/customeros/mailstack/interfaces"
 	"github.com/customeros/mailstack/internal/enum"
+	er "github.com/customeros/mailstack/internal/errors"
 	"github.com/customeros/mailstack/internal/models"
 	"github.com/customeros/mailstack/internal/repository"
 	"github.com/customeros/mailstack/internal/tracing"
@@ -19,18 +21,20 @@ import (
 )
 
 type mailboxService struct {
-	repositories *repository.Repositories
-	imapService  interfaces.IMAPService
+	repositories   *repository.Repositories
+	imapService    interfaces.IMAPService
+	openSrsService interfaces.OpenSrsService
 }
 
-func NewMailboxService(repos *repository.Repositories, imap interfaces.IMAPService) interfaces.MailboxService {
+func NewMailboxService(repos *repository.Repositories, imap interfaces.IMAPService, openSrs interfaces.OpenSrsService) interfaces.MailboxService {
 	return &mailboxService{
-		repositories: repos,
-		imapService:  imap,
+		repositories:   repos,
+		imapService:    imap,
+		openSrsService: openSrs,
 	}
 }
 
-var ErrMailboxExists = errors.New("Mailbox already exists")
+var ErrMailboxValidation = errors.New("validation failed")
 
 func (s *mailboxService) EnrollMailbox(ctx context.Context, mailbox *models.Mailbox) (*models.Mailbox, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "mailboxService.CreateMailbox")
@@ -67,8 +71,8 @@ func (s *mailboxService) EnrollMailbox(ctx context.Context, mailbox *models.Mail
 		return nil, err
 	}
 	if mboxCheck != nil {
-		tracing.TraceErr(span, ErrMailboxExists)
-		return nil, ErrMailboxExists
+		tracing.TraceErr(span, er.ErrMailboxExists)
+		return nil, er.ErrMailboxExists
 	}
 
 	// save mailbox
@@ -157,6 +161,9 @@ func validateMailboxInput(input *models.Mailbox) error {
 			validationErrors = append(validationErrors, "IMAP security is required when IMAP config is provided")
 		}
 	}
+	if input.ImapCACert != "" && !x509.NewCertPool().AppendCertsFromPEM([]byte(input.ImapCACert)) {
+		validationErrors = append(validationErrors, "IMAP CA certificate must be PEM encoded")
+	}
 
 	// Validate SMTP configuration if provided
 	if input.SmtpPassword != "" {
@@ -174,13 +181,45 @@ func validateMailboxInput(input *models.Mailbox) error {
 		}
 	}
 
+	// Validate sync settings, zero keeps the defaults
+	if input.SyncBatchSize < 0 || input.SyncBatchSize > models.MaxSyncBatchSize {
+		validationErrors = append(validationErrors, fmt.Sprintf("syncBatchSize must be between 1 and %d", models.MaxSyncBatchSize))
+	}
+	if input.SyncMaxMessages < 0 || input.SyncMaxMessages > models.MaxSyncMaxMessages {
+		validationErrors = append(validationErrors, fmt.Sprintf("syncMaxMessages must be between 1 and %d", models.MaxSyncMaxMessages))
+	}
+	if input.SyncPollIntervalSeconds != 0 &&
+		(input.SyncPollIntervalSeconds < models.MinSyncPollIntervalSeconds || input.SyncPollIntervalSeconds > models.MaxSyncPollIntervalSeconds) {
+		validationErrors = append(validationErrors, fmt.Sprintf("syncPollIntervalSeconds must be between %d and %d",
+			models.MinSyncPollIntervalSeconds, models.MaxSyncPollIntervalSeconds))
+	}
+
+	if input.ReconnectInitialBackoffSeconds < 0 || input.ReconnectInitialBackoffSeconds > models.MaxReconnectBackoffSeconds {
+		validationErrors = append(validationErrors, fmt.Sprintf("reconnectInitialBackoffSeconds must be between 1 and %d", models.MaxReconnectBackoffSeconds))
+	}
+	if input.ReconnectMaxBackoffSeconds < 0 || input.ReconnectMaxBackoffSeconds > models.MaxReconnectBackoffSeconds {
+		validationErrors = append(validationErrors, fmt.Sprintf("reconnectMaxBackoffSeconds must be between 1 and %d", models.MaxReconnectBackoffSeconds))
+	}
+	if input.ReconnectInitialBackoffSeconds > 0 && input.ReconnectMaxBackoffSeconds > 0 &&
+		input.ReconnectInitialBackoffSeconds > input.ReconnectMaxBackoffSeconds {
+		validationErrors = append(validationErrors, "reconnectInitialBackoffSeconds must not exceed reconnectMaxBackoffSeconds")
+	}
+
+	if input.MessageIDDomain != "" {
+		host, err := utils.NormalizeHostname(input.MessageIDDomain)
+		if err != nil {
+			validationErrors = append(validationErrors, "messageIdDomain must be a domain name")
+		}
+		input.MessageIDDomain = host
+	}
+
 	if input.SenderID != "" {
 		input.OutboundEnabled = true
 	}
 
 	// Check if there are any validation errors
 	if len(validationErrors) > 0 {
-		return fmt.Errorf("validation failed: %s", strings.Join(validationErrors, ", "))
+		return fmt.Errorf("%w: %s", ErrMailboxValidation, strings.Join(validationErrors, ", "))
 	}
 
 	return nil
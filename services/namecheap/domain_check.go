@@ -0,0 +1,152 @@
+package namecheap
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+	tracingLog "github.com/opentracing/opentracing-go/log"
+	"github.com/pkg/errors"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/tracing"
+)
+
+// maximum number of domains namecheap.domains.check accepts in one DomainList
+const domainCheckBatchSize = 50
+
+type namecheapDomainCheckResult struct {
+	XMLName xml.Name `xml:"ApiResponse"`
+	Status  string   `xml:"Status,attr"`
+	Errors  struct {
+		Error []struct {
+			Number  string `xml:"Number,attr"`
+			Message string `xml:",chardata"`
+		} `xml:"Error"`
+	} `xml:"Errors"`
+	CommandResponse struct {
+		DomainCheckResults []struct {
+			Domain        string `xml:"Domain,attr"`
+			Available     bool   `xml:"Available,attr"`
+			IsPremiumName bool   `xml:"IsPremiumName,attr"`
+		} `xml:"DomainCheckResult"`
+	} `xml:"CommandResponse"`
+}
+
+// CheckDomainsAvailability checks many domains with one namecheap.domains.check call per batch.
+// Results are returned in the order of the given domains, duplicates are checked once.
+func (s *namecheapService) CheckDomainsAvailability(ctx context.Context, domains []string) ([]interfaces.NamecheapDomainAvailability, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "NamecheapService.CheckDomainsAvailability")
+	defer span.Finish()
+	span.LogFields(tracingLog.Int("domains.count", len(domains)))
+
+	// validate if namecheap is configured
+	if s.cfg.ApiKey == "" || s.cfg.ApiUser == "" || s.cfg.ApiUsername == "" || s.cfg.ApiClientIp == "" {
+		err := errors.New("Namecheap API configuration is missing")
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	var unique []string
+	seen := make(map[string]bool, len(domains))
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if domain == "" || seen[domain] {
+			continue
+		}
+		seen[domain] = true
+		unique = append(unique, domain)
+	}
+
+	results := make([]interfaces.NamecheapDomainAvailability, 0, len(unique))
+	for _, batch := range chunkDomains(unique, domainCheckBatchSize) {
+		checked, err := s.checkDomainsBatch(ctx, batch)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return nil, err
+		}
+		results = append(results, checked...)
+	}
+
+	span.LogFields(tracingLog.Int("result.count", len(results)))
+	return results, nil
+}
+
+func (s *namecheapService) checkDomainsBatch(ctx context.Context, domains []string) ([]interfaces.NamecheapDomainAvailability, error) {
+	params := url.Values{}
+	params.Add("ApiKey", s.cfg.ApiKey)
+	params.Add("ApiUser", s.cfg.ApiUser)
+	params.Add("UserName", s.cfg.ApiUsername)
+	params.Add("ClientIp", s.cfg.ApiClientIp)
+	params.Add("Command", "namecheap.domains.check")
+	params.Add("DomainList", strings.Join(domains, ","))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.Url, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create Namecheap request")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to call Namecheap API for domain check")
+	}
+	defer resp.Body.Close()
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read Namecheap response")
+	}
+
+	return parseDomainCheckResponse(responseBody, domains)
+}
+
+// parseDomainCheckResponse maps the check results back to the requested domains, a domain missing
+// from the response is an error rather than silently reported as unavailable
+func parseDomainCheckResponse(responseBody []byte, domains []string) ([]interfaces.NamecheapDomainAvailability, error) {
+	var result namecheapDomainCheckResult
+	if err := xml.Unmarshal(responseBody, &result); err != nil {
+		return nil, errors.Wrap(err, "failed to parse Namecheap XML response")
+	}
+	if len(result.Errors.Error) > 0 {
+		e := result.Errors.Error[0]
+		return nil, fmt.Errorf("Namecheap API returned errors: Error %s: %s", e.Number, e.Message)
+	}
+
+	byDomain := make(map[string]interfaces.NamecheapDomainAvailability, len(result.CommandResponse.DomainCheckResults))
+	for _, checked := range result.CommandResponse.DomainCheckResults {
+		domain := strings.ToLower(checked.Domain)
+		byDomain[domain] = interfaces.NamecheapDomainAvailability{
+			Domain:    domain,
+			Available: checked.Available,
+			Premium:   checked.IsPremiumName,
+		}
+	}
+
+	availability := make([]interfaces.NamecheapDomainAvailability, 0, len(domains))
+	for _, domain := range domains {
+		checked, ok := byDomain[domain]
+		if !ok {
+			return nil, fmt.Errorf("Namecheap API returned no result for %s", domain)
+		}
+		availability = append(availability, checked)
+	}
+	return availability, nil
+}
+
+func chunkDomains(domains []string, size int) [][]string {
+	var chunks [][]string
+	for len(domains) > size {
+		chunks = append(chunks, domains[:size])
+		domains = domains[size:]
+	}
+	if len(domains) > 0 {
+		chunks = append(chunks, domains)
+	}
+	return chunks
+}
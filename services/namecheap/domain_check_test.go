@@ -0,0 +1,48 @@
+package namecheap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/interfaces"
+)
+
+func TestParseDomainCheckResponse(t *testing.T) {
+	response := []byte(`<?xml version="1.0" encoding="utf-8"?>
+<ApiResponse Status="OK" xmlns="http://api.namecheap.com/xml.response">
+  <Errors />
+  <CommandResponse Type="namecheap.domains.check">
+    <DomainCheckResult Domain="Trymailstack.com" Available="true" IsPremiumName="false" />
+    <DomainCheckResult Domain="getmailstack.com" Available="false" IsPremiumName="false" />
+    <DomainCheckResult Domain="mail.io" Available="true" IsPremiumName="true" />
+  </CommandResponse>
+</ApiResponse>`)
+
+	t.Run("results follow the requested order", func(t *testing.T) {
+		availability, err := parseDomainCheckResponse(response, []string{"mail.io", "trymailstack.com", "getmailstack.com"})
+		require.NoError(t, err)
+		assert.Equal(t, []interfaces.NamecheapDomainAvailability{
+			{Domain: "mail.io", Available: true, Premium: true},
+			{Domain: "trymailstack.com", Available: true},
+			{Domain: "getmailstack.com"},
+		}, availability)
+	})
+
+	t.Run("missing domain", func(t *testing.T) {
+		_, err := parseDomainCheckResponse(response, []string{"trymailstack.com", "other.com"})
+		require.Error(t, err)
+	})
+
+	t.Run("api error", func(t *testing.T) {
+		_, err := parseDomainCheckResponse([]byte(`<ApiResponse Status="ERROR"><Errors><Error Number="2030280">TLD is not supported</Error></Errors></ApiResponse>`), []string{"a.zz"})
+		require.EqualError(t, err, "Namecheap API returned errors: Error 2030280: TLD is not supported")
+	})
+}
+
+func TestChunkDomains(t *testing.T) {
+	assert.Nil(t, chunkDomains(nil, 50))
+	assert.Equal(t, [][]string{{"a", "b"}, {"c", "d"}, {"e"}}, chunkDomains([]string{"a", "b", "c", "d", "e"}, 2))
+	assert.Equal(t, [][]string{{"a", "b"}}, chunkDomains([]string{"a", "b"}, 2))
+}
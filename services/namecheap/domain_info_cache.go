@@ -0,0 +1,169 @@
+package namecheap
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/tracing"
+)
+
+const (
+	defaultDomainInfoTTL    = 5 * time.Minute
+	defaultDomainInfoMaxAge = 24 * time.Hour
+)
+
+// domainInfoCache keeps the domain info per tenant and domain in memory. Entries younger than
+// the ttl are fresh, entries up to maxAge are served stale while one refresh runs.
+type domainInfoCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	maxAge  time.Duration
+	entries map[string]*domainInfoEntry
+	now     func() time.Time
+}
+
+type domainInfoEntry struct {
+	info       interfaces.NamecheapDomainInfo
+	fetchedAt  time.Time
+	cached     bool
+	refreshing bool
+	generation uint64 // bumped on invalidation, fetches started earlier are not stored
+}
+
+type cacheState int
+
+const (
+	cacheMiss cacheState = iota
+	cacheFresh
+	cacheStale
+)
+
+func newDomainInfoCache(ttl, maxAge time.Duration) *domainInfoCache {
+	if ttl <= 0 {
+		ttl = defaultDomainInfoTTL
+	}
+	if maxAge < ttl {
+		maxAge = max(ttl, defaultDomainInfoMaxAge)
+	}
+	return &domainInfoCache{
+		ttl:     ttl,
+		maxAge:  maxAge,
+		entries: map[string]*domainInfoEntry{},
+		now:     time.Now,
+	}
+}
+
+func domainInfoKey(tenant, domain string) string {
+	return tenant + "/" + domain
+}
+
+func (c *domainInfoCache) entry(tenant, domain string) *domainInfoEntry {
+	key := domainInfoKey(tenant, domain)
+	e, ok := c.entries[key]
+	if !ok {
+		e = &domainInfoEntry{}
+		c.entries[key] = e
+	}
+	return e
+}
+
+// get returns the cached info and its state. A stale result with refresh set means the
+// caller owns the refresh and must call set or endRefresh.
+func (c *domainInfoCache) get(tenant, domain string) (info interfaces.NamecheapDomainInfo, state cacheState, refresh bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[domainInfoKey(tenant, domain)]
+	if !ok || !e.cached {
+		return interfaces.NamecheapDomainInfo{}, cacheMiss, false
+	}
+
+	age := c.now().Sub(e.fetchedAt)
+	switch {
+	case age < c.ttl:
+		return e.info, cacheFresh, false
+	case age < c.maxAge:
+		if !e.refreshing {
+			e.refreshing = true
+			refresh = true
+		}
+		return e.info, cacheStale, refresh
+	default:
+		return interfaces.NamecheapDomainInfo{}, cacheMiss, false
+	}
+}
+
+// generation must be read before fetching, set ignores results of fetches an invalidation overtook
+func (c *domainInfoCache) generation(tenant, domain string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entry(tenant, domain).generation
+}
+
+func (c *domainInfoCache) set(tenant, domain string, generation uint64, info interfaces.NamecheapDomainInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := c.entry(tenant, domain)
+	e.refreshing = false
+	if e.generation != generation {
+		return
+	}
+	e.info = info
+	e.fetchedAt = c.now()
+	e.cached = true
+}
+
+func (c *domainInfoCache) endRefresh(tenant, domain string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entry(tenant, domain).refreshing = false
+}
+
+func (c *domainInfoCache) invalidate(tenant, domain string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := c.entry(tenant, domain)
+	e.info = interfaces.NamecheapDomainInfo{}
+	e.cached = false
+	e.generation++
+}
+
+// GetCachedDomainInfo serves the domain info from cache. Stale entries are returned right away
+// and refreshed in the background, forceRefresh always calls Namecheap.
+func (s *namecheapService) GetCachedDomainInfo(ctx context.Context, tenant, domain string, forceRefresh bool) (interfaces.NamecheapDomainInfo, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "NamecheapService.GetCachedDomainInfo")
+	defer span.Finish()
+	tracing.TagTenant(span, tenant)
+	span.LogKV("domain", domain, "forceRefresh", forceRefresh)
+
+	if !forceRefresh {
+		info, state, refresh := s.domainInfo.get(tenant, domain)
+		span.LogKV("cache.state", state)
+		if refresh {
+			go s.refreshDomainInfo(context.WithoutCancel(ctx), tenant, domain)
+		}
+		if state != cacheMiss {
+			return info, nil
+		}
+	}
+
+	return s.GetDomainInfo(ctx, tenant, domain)
+}
+
+func (s *namecheapService) refreshDomainInfo(ctx context.Context, tenant, domain string) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "NamecheapService.refreshDomainInfo")
+	defer span.Finish()
+	tracing.TagTenant(span, tenant)
+
+	if _, err := s.GetDomainInfo(ctx, tenant, domain); err != nil {
+		// keep serving the stale entry, the next read retries
+		s.domainInfo.endRefresh(tenant, domain)
+		tracing.TraceErr(span, err)
+	}
+}
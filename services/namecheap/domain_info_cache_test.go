@@ -0,0 +1,64 @@
+package namecheap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/customeros/mailstack/interfaces"
+)
+
+func TestDomainInfoCache(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := newDomainInfoCache(time.Minute, time.Hour)
+	cache.now = func() time.Time { return now }
+
+	_, state, _ := cache.get("tenant", "example.com")
+	assert.Equal(t, cacheMiss, state)
+
+	cache.set("tenant", "example.com", cache.generation("tenant", "example.com"), interfaces.NamecheapDomainInfo{DomainName: "example.com"})
+	info, state, refresh := cache.get("tenant", "example.com")
+	assert.Equal(t, cacheFresh, state)
+	assert.False(t, refresh)
+	assert.Equal(t, "example.com", info.DomainName)
+
+	// other tenants do not share entries
+	_, state, _ = cache.get("other", "example.com")
+	assert.Equal(t, cacheMiss, state)
+
+	// stale: only the first reader refreshes
+	now = now.Add(2 * time.Minute)
+	_, state, refresh = cache.get("tenant", "example.com")
+	assert.Equal(t, cacheStale, state)
+	assert.True(t, refresh)
+	_, _, refresh = cache.get("tenant", "example.com")
+	assert.False(t, refresh)
+	cache.endRefresh("tenant", "example.com")
+	_, _, refresh = cache.get("tenant", "example.com")
+	assert.True(t, refresh)
+
+	// expired beyond the max age
+	now = now.Add(2 * time.Hour)
+	_, state, _ = cache.get("tenant", "example.com")
+	assert.Equal(t, cacheMiss, state)
+}
+
+func TestDomainInfoCacheInvalidate(t *testing.T) {
+	cache := newDomainInfoCache(time.Minute, time.Hour)
+
+	generation := cache.generation("tenant", "example.com")
+	cache.set("tenant", "example.com", generation, interfaces.NamecheapDomainInfo{Nameservers: []string{"old.ns"}})
+
+	// a fetch started before the invalidation must not bring the old data back
+	inFlight := cache.generation("tenant", "example.com")
+	cache.invalidate("tenant", "example.com")
+	cache.set("tenant", "example.com", inFlight, interfaces.NamecheapDomainInfo{Nameservers: []string{"old.ns"}})
+	_, state, _ := cache.get("tenant", "example.com")
+	assert.Equal(t, cacheMiss, state)
+
+	cache.set("tenant", "example.com", cache.generation("tenant", "example.com"), interfaces.NamecheapDomainInfo{Nameservers: []string{"new.ns"}})
+	info, state, _ := cache.get("tenant", "example.com")
+	assert.Equal(t, cacheFresh, state)
+	assert.Equal(t, []string{"new.ns"}, info.Nameservers)
+}
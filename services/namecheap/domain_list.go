@@ -0,0 +1,127 @@
+package namecheap
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+	tracingLog "github.com/opentracing/opentracing-go/log"
+	"github.com/pkg/errors"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/tracing"
+)
+
+// maximum page size accepted by namecheap.domains.getList
+const domainListPageSize = 100
+
+type namecheapDomainListResult struct {
+	XMLName xml.Name `xml:"ApiResponse"`
+	Status  string   `xml:"Status,attr"`
+	Errors  struct {
+		Error []struct {
+			Number  string `xml:"Number,attr"`
+			Message string `xml:",chardata"`
+		} `xml:"Error"`
+	} `xml:"Errors"`
+	CommandResponse struct {
+		DomainGetListResult struct {
+			Domains []struct {
+				Name      string `xml:"Name,attr"`
+				Expires   string `xml:"Expires,attr"`
+				IsExpired bool   `xml:"IsExpired,attr"`
+				IsLocked  bool   `xml:"IsLocked,attr"`
+				AutoRenew bool   `xml:"AutoRenew,attr"`
+			} `xml:"Domain"`
+		} `xml:"DomainGetListResult"`
+		Paging struct {
+			TotalItems  int `xml:"TotalItems"`
+			CurrentPage int `xml:"CurrentPage"`
+			PageSize    int `xml:"PageSize"`
+		} `xml:"Paging"`
+	} `xml:"CommandResponse"`
+}
+
+// ListDomains returns all domains registered in the Namecheap account, across pages
+func (s *namecheapService) ListDomains(ctx context.Context) ([]interfaces.NamecheapListedDomain, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "NamecheapService.ListDomains")
+	defer span.Finish()
+
+	// validate if namecheap is configured
+	if s.cfg.ApiKey == "" || s.cfg.ApiUser == "" || s.cfg.ApiUsername == "" || s.cfg.ApiClientIp == "" {
+		err := errors.New("Namecheap API configuration is missing")
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	var domains []interfaces.NamecheapListedDomain
+	for page := 1; ; page++ {
+		result, err := s.getDomainListPage(ctx, page)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return nil, err
+		}
+
+		for _, domain := range result.CommandResponse.DomainGetListResult.Domains {
+			domains = append(domains, interfaces.NamecheapListedDomain{
+				DomainName: strings.ToLower(domain.Name),
+				ExpiresAt:  parseNamecheapDate(domain.Expires),
+				IsExpired:  domain.IsExpired,
+				AutoRenew:  domain.AutoRenew,
+			})
+		}
+
+		paging := result.CommandResponse.Paging
+		if len(result.CommandResponse.DomainGetListResult.Domains) == 0 || page*domainListPageSize >= paging.TotalItems {
+			break
+		}
+	}
+
+	span.LogFields(tracingLog.Int("result.count", len(domains)))
+	return domains, nil
+}
+
+func (s *namecheapService) getDomainListPage(ctx context.Context, page int) (*namecheapDomainListResult, error) {
+	params := url.Values{}
+	params.Add("ApiKey", s.cfg.ApiKey)
+	params.Add("ApiUser", s.cfg.ApiUser)
+	params.Add("UserName", s.cfg.ApiUsername)
+	params.Add("ClientIp", s.cfg.ApiClientIp)
+	params.Add("Command", "namecheap.domains.getList")
+	params.Add("PageSize", strconv.Itoa(domainListPageSize))
+	params.Add("Page", strconv.Itoa(page))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.Url, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create Namecheap request")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to call Namecheap API for domain list")
+	}
+	defer resp.Body.Close()
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read Namecheap response")
+	}
+
+	var result namecheapDomainListResult
+	if err = xml.Unmarshal(responseBody, &result); err != nil {
+		return nil, errors.Wrap(err, "failed to parse Namecheap XML response")
+	}
+	if len(result.Errors.Error) > 0 {
+		e := result.Errors.Error[0]
+		return nil, fmt.Errorf("Namecheap API returned errors: Error %s: %s", e.Number, e.Message)
+	}
+
+	return &result, nil
+}
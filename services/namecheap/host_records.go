@@ -0,0 +1,236 @@
+package namecheap
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+	tracingLog "github.com/opentracing/opentracing-go/log"
+	"github.com/pkg/errors"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+type namecheapGetHostsResult struct {
+	XMLName xml.Name `xml:"ApiResponse"`
+	Status  string   `xml:"Status,attr"`
+	Errors  struct {
+		Error []struct {
+			Number  string `xml:"Number,attr"`
+			Message string `xml:",chardata"`
+		} `xml:"Error"`
+	} `xml:"Errors"`
+	CommandResponse struct {
+		DomainDNSGetHostsResult struct {
+			Domain        string `xml:"Domain,attr"`
+			EmailType     string `xml:"EmailType,attr"`
+			IsUsingOurDNS bool   `xml:"IsUsingOurDNS,attr"`
+			Hosts         []struct {
+				HostId  string `xml:"HostId,attr"`
+				Name    string `xml:"Name,attr"`
+				Type    string `xml:"Type,attr"`
+				Address string `xml:"Address,attr"`
+				MXPref  int    `xml:"MXPref,attr"`
+				TTL     int    `xml:"TTL,attr"`
+			} `xml:"host"`
+		} `xml:"DomainDNSGetHostsResult"`
+	} `xml:"CommandResponse"`
+}
+
+type namecheapSetHostsResult struct {
+	XMLName xml.Name `xml:"ApiResponse"`
+	Status  string   `xml:"Status,attr"`
+	Errors  struct {
+		Error []struct {
+			Number  string `xml:"Number,attr"`
+			Message string `xml:",chardata"`
+		} `xml:"Error"`
+	} `xml:"Errors"`
+	CommandResponse struct {
+		DomainDNSSetHostsResult struct {
+			Domain    string `xml:"Domain,attr"`
+			IsSuccess bool   `xml:"IsSuccess,attr"`
+		} `xml:"DomainDNSSetHostsResult"`
+	} `xml:"CommandResponse"`
+}
+
+// GetHostRecords returns the host records Namecheap DNS holds for the domain
+func (s *namecheapService) GetHostRecords(ctx context.Context, tenant, domain string) (interfaces.NamecheapHostRecords, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "NamecheapService.GetHostRecords")
+	defer span.Finish()
+	tracing.TagTenant(span, tenant)
+	span.LogKV("domain", domain)
+
+	params, err := s.hostRecordsParams(ctx, tenant, domain, "namecheap.domains.dns.getHosts")
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return interfaces.NamecheapHostRecords{}, err
+	}
+
+	responseBody, err := s.postHostRecords(ctx, params)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return interfaces.NamecheapHostRecords{}, err
+	}
+
+	records, err := parseGetHostsResponse(responseBody)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return interfaces.NamecheapHostRecords{}, err
+	}
+
+	span.LogFields(tracingLog.Int("result.count", len(records.Hosts)), tracingLog.Bool("result.usingNamecheapDNS", records.UsingNamecheapDNS))
+	return records, nil
+}
+
+// SetHostRecords replaces all host records of the domain, records left out are removed by Namecheap
+func (s *namecheapService) SetHostRecords(ctx context.Context, tenant, domain string, records interfaces.NamecheapHostRecords) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "NamecheapService.SetHostRecords")
+	defer span.Finish()
+	tracing.TagTenant(span, tenant)
+	span.LogKV("domain", domain)
+	span.LogFields(tracingLog.Int("records.count", len(records.Hosts)))
+
+	params, err := s.hostRecordsParams(ctx, tenant, domain, "namecheap.domains.dns.setHosts")
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+	for key, values := range setHostsParams(records) {
+		params[key] = values
+	}
+
+	responseBody, err := s.postHostRecords(ctx, params)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	var result namecheapSetHostsResult
+	if err = xml.Unmarshal(responseBody, &result); err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "failed to parse Namecheap XML response"))
+		return err
+	}
+	if len(result.Errors.Error) > 0 {
+		e := result.Errors.Error[0]
+		err = fmt.Errorf("Namecheap API returned errors: Error %s: %s", e.Number, e.Message)
+		tracing.TraceErr(span, err)
+		return err
+	}
+	if !result.CommandResponse.DomainDNSSetHostsResult.IsSuccess {
+		err = fmt.Errorf("failed to set host records for domain %s", domain)
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	return nil
+}
+
+// hostRecordsParams validates the configuration and domain ownership and returns the common request parameters
+func (s *namecheapService) hostRecordsParams(ctx context.Context, tenant, domain, command string) (url.Values, error) {
+	// validate if namecheap is configured
+	if s.cfg.ApiKey == "" || s.cfg.ApiUser == "" || s.cfg.ApiUsername == "" || s.cfg.ApiClientIp == "" {
+		return nil, errors.New("Namecheap API configuration is missing")
+	}
+
+	exists, err := s.postgres.DomainRepository.CheckDomainOwnership(ctx, tenant, domain)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to check domain ownership in postgres")
+	}
+	if !exists {
+		return nil, fmt.Errorf("domain %s does not belong to tenant %s or is not active", domain, tenant)
+	}
+
+	sld, tld, err := utils.SplitDomain(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Add("ApiKey", s.cfg.ApiKey)
+	params.Add("ApiUser", s.cfg.ApiUser)
+	params.Add("UserName", s.cfg.ApiUsername)
+	params.Add("ClientIp", s.cfg.ApiClientIp)
+	params.Add("Command", command)
+	params.Add("SLD", sld)
+	params.Add("TLD", tld)
+	return params, nil
+}
+
+func (s *namecheapService) postHostRecords(ctx context.Context, params url.Values) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.Url, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create Namecheap request")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to call Namecheap API for host records")
+	}
+	defer resp.Body.Close()
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read Namecheap response")
+	}
+	return responseBody, nil
+}
+
+func parseGetHostsResponse(body []byte) (interfaces.NamecheapHostRecords, error) {
+	var result namecheapGetHostsResult
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return interfaces.NamecheapHostRecords{}, errors.Wrap(err, "failed to parse Namecheap XML response")
+	}
+	if len(result.Errors.Error) > 0 {
+		e := result.Errors.Error[0]
+		return interfaces.NamecheapHostRecords{}, fmt.Errorf("Namecheap API returned errors: Error %s: %s", e.Number, e.Message)
+	}
+
+	hostsResult := result.CommandResponse.DomainDNSGetHostsResult
+	records := interfaces.NamecheapHostRecords{
+		EmailType:         hostsResult.EmailType,
+		UsingNamecheapDNS: hostsResult.IsUsingOurDNS,
+	}
+	for _, host := range hostsResult.Hosts {
+		records.Hosts = append(records.Hosts, interfaces.NamecheapHostRecord{
+			ID:      host.HostId,
+			Name:    host.Name,
+			Type:    host.Type,
+			Address: host.Address,
+			MXPref:  host.MXPref,
+			TTL:     host.TTL,
+		})
+	}
+	return records, nil
+}
+
+// setHostsParams numbers the host records the way namecheap.domains.dns.setHosts expects them
+func setHostsParams(records interfaces.NamecheapHostRecords) url.Values {
+	params := url.Values{}
+	for i, host := range records.Hosts {
+		n := strconv.Itoa(i + 1)
+		params.Add("HostName"+n, host.Name)
+		params.Add("RecordType"+n, host.Type)
+		params.Add("Address"+n, host.Address)
+		if strings.EqualFold(host.Type, "MX") {
+			params.Add("MXPref"+n, strconv.Itoa(host.MXPref))
+		}
+		if host.TTL > 0 {
+			params.Add("TTL"+n, strconv.Itoa(host.TTL))
+		}
+	}
+	// the email type has to be sent back, otherwise Namecheap resets the mail settings
+	if records.EmailType != "" {
+		params.Add("EmailType", records.EmailType)
+	}
+	return params
+}
@@ -0,0 +1,61 @@
+package namecheap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/interfaces"
+)
+
+func TestParseGetHostsResponse(t *testing.T) {
+	response := []byte(`<?xml version="1.0" encoding="utf-8"?>
+<ApiResponse Status="OK" xmlns="http://api.namecheap.com/xml.response">
+  <Errors />
+  <CommandResponse Type="namecheap.domains.dns.getHosts">
+    <DomainDNSGetHostsResult Domain="example.com" EmailType="MX" IsUsingOurDNS="true">
+      <host HostId="12" Name="@" Type="MX" Address="mx.example.com." MXPref="10" TTL="1800" />
+      <host HostId="14" Name="_dmarc" Type="TXT" Address="v=DMARC1; p=none" MXPref="10" TTL="1799" />
+    </DomainDNSGetHostsResult>
+  </CommandResponse>
+</ApiResponse>`)
+
+	t.Run("hosts", func(t *testing.T) {
+		records, err := parseGetHostsResponse(response)
+		require.NoError(t, err)
+		assert.Equal(t, interfaces.NamecheapHostRecords{
+			EmailType:         "MX",
+			UsingNamecheapDNS: true,
+			Hosts: []interfaces.NamecheapHostRecord{
+				{ID: "12", Name: "@", Type: "MX", Address: "mx.example.com.", MXPref: 10, TTL: 1800},
+				{ID: "14", Name: "_dmarc", Type: "TXT", Address: "v=DMARC1; p=none", MXPref: 10, TTL: 1799},
+			},
+		}, records)
+	})
+
+	t.Run("api error", func(t *testing.T) {
+		_, err := parseGetHostsResponse([]byte(`<ApiResponse Status="ERROR"><Errors><Error Number="2019166">Domain not found</Error></Errors></ApiResponse>`))
+		require.EqualError(t, err, "Namecheap API returned errors: Error 2019166: Domain not found")
+	})
+}
+
+func TestSetHostsParams(t *testing.T) {
+	params := setHostsParams(interfaces.NamecheapHostRecords{
+		EmailType: "MX",
+		Hosts: []interfaces.NamecheapHostRecord{
+			{Name: "@", Type: "MX", Address: "mx.example.com.", MXPref: 10, TTL: 1800},
+			{Name: "_dmarc", Type: "TXT", Address: "v=DMARC1; p=reject"},
+		},
+	})
+
+	assert.Equal(t, "@", params.Get("HostName1"))
+	assert.Equal(t, "MX", params.Get("RecordType1"))
+	assert.Equal(t, "10", params.Get("MXPref1"))
+	assert.Equal(t, "1800", params.Get("TTL1"))
+	assert.Equal(t, "_dmarc", params.Get("HostName2"))
+	assert.Equal(t, "v=DMARC1; p=reject", params.Get("Address2"))
+	assert.False(t, params.Has("MXPref2"))
+	assert.False(t, params.Has("TTL2"))
+	assert.Equal(t, "MX", params.Get("EmailType"))
+}
@@ -0,0 +1,218 @@
+package namecheap
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	tracingLog "github.com/opentracing/opentracing-go/log"
+	"github.com/pkg/errors"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+const (
+	defaultPriceCacheTTL = time.Hour
+	baseCurrency         = "USD"
+)
+
+// tldPriceCache keeps the Namecheap registration price per TLD, prices change rarely
+type tldPriceCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]tldPrice
+	now     func() time.Time
+}
+
+type tldPrice struct {
+	price     float64
+	currency  string
+	fetchedAt time.Time
+}
+
+func newTLDPriceCache(ttl time.Duration) *tldPriceCache {
+	if ttl <= 0 {
+		ttl = defaultPriceCacheTTL
+	}
+	return &tldPriceCache{
+		ttl:     ttl,
+		entries: map[string]tldPrice{},
+		now:     time.Now,
+	}
+}
+
+func (c *tldPriceCache) get(tld string) (tldPrice, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[strings.ToLower(tld)]
+	if !ok || c.now().Sub(entry.fetchedAt) > c.ttl {
+		return tldPrice{}, false
+	}
+	return entry, true
+}
+
+func (c *tldPriceCache) set(tld string, price float64, currency string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[strings.ToLower(tld)] = tldPrice{price: price, currency: currency, fetchedAt: c.now()}
+}
+
+// GetDomainPrice quotes the one year registration of the domain in the given currency, the
+// tenant's or the configured one. Cost is what Namecheap charges, Price adds the markup.
+func (s *namecheapService) GetDomainPrice(ctx context.Context, tenant, domain, currency string) (interfaces.NamecheapDomainPrice, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "NamecheapService.GetDomainPrice")
+	defer span.Finish()
+	tracing.TagTenant(span, tenant)
+	span.LogKV("domain", domain, "currency", currency)
+
+	// Extract the TLD from the domain (e.g., "co.uk" from "example.co.uk")
+	_, tld, err := utils.SplitDomain(domain)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return interfaces.NamecheapDomainPrice{}, err
+	}
+
+	registration, ok := s.prices.get(tld)
+	if !ok {
+		price, priceCurrency, err := s.getTLDPrice(ctx, tld)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return interfaces.NamecheapDomainPrice{}, err
+		}
+		s.prices.set(tld, price, priceCurrency)
+		registration = tldPrice{price: price, currency: priceCurrency}
+	}
+	span.LogFields(tracingLog.Bool("cached", ok), tracingLog.Float64("registration.price", registration.price))
+
+	currency, err = s.quoteCurrency(ctx, tenant, currency)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return interfaces.NamecheapDomainPrice{}, err
+	}
+	rate, err := s.rates.Rate(ctx, registration.currency, currency)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return interfaces.NamecheapDomainPrice{}, err
+	}
+
+	quote := quoteDomainPrice(domain, registration.price, rate, s.cfg.PriceMarkupPercent, currency)
+	tracing.LogObjectAsJson(span, "result", quote)
+	return quote, nil
+}
+
+// PriceExceedsMax tells whether Namecheap's cost of the domain is over the configured max price.
+// The cost is converted to the currency the max price is set in, the markup does not count.
+func (s *namecheapService) PriceExceedsMax(ctx context.Context, domain string) (bool, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "NamecheapService.PriceExceedsMax")
+	defer span.Finish()
+	span.LogKV("domain", domain)
+
+	quote, err := s.GetDomainPrice(ctx, "", domain, s.cfg.MaxPriceCurrency)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return false, err
+	}
+	return priceExceedsMax(quote.Cost, s.cfg.MaxPrice), nil
+}
+
+// quoteCurrency returns the requested currency, else the tenant's, else the configured one
+func (s *namecheapService) quoteCurrency(ctx context.Context, tenant, requested string) (string, error) {
+	if requested != "" || tenant == "" {
+		return firstCurrency(requested, s.cfg.PriceCurrency), nil
+	}
+
+	tenantCurrency, err := s.postgres.TenantCurrencyRepository.GetByTenant(ctx, tenant)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get tenant currency")
+	}
+	if tenantCurrency == nil {
+		return firstCurrency("", s.cfg.PriceCurrency), nil
+	}
+	return firstCurrency(tenantCurrency.Currency, s.cfg.PriceCurrency), nil
+}
+
+func firstCurrency(currencies ...string) string {
+	for _, currency := range currencies {
+		if currency = strings.TrimSpace(currency); currency != "" {
+			return strings.ToUpper(currency)
+		}
+	}
+	return baseCurrency
+}
+
+func quoteDomainPrice(domain string, registrationPrice, rate, markupPercent float64, currency string) interfaces.NamecheapDomainPrice {
+	cost := registrationPrice * rate
+	return interfaces.NamecheapDomainPrice{
+		Domain:   domain,
+		Currency: strings.ToUpper(currency),
+		Cost:     roundPrice(cost),
+		Price:    roundPrice(applyMarkup(cost, markupPercent)),
+	}
+}
+
+func applyMarkup(price, markupPercent float64) float64 {
+	return price * (1 + markupPercent/100)
+}
+
+// priceExceedsMax compares in cents, so a price equal to the max after rounding passes
+func priceExceedsMax(price, maxPrice float64) bool {
+	return roundPrice(price) > roundPrice(maxPrice)
+}
+
+func roundPrice(price float64) float64 {
+	return math.Round(price*100) / 100
+}
+
+// staticExchangeRates converts with rates set in config, as units of a currency per USD
+type staticExchangeRates struct {
+	perUSD map[string]float64
+}
+
+// newStaticExchangeRates parses rates like "EUR:0.92,GBP:0.79"
+func newStaticExchangeRates(rates string) (interfaces.ExchangeRateSource, error) {
+	perUSD := map[string]float64{baseCurrency: 1}
+	for _, pair := range strings.Split(rates, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		currency, value, ok := strings.Cut(pair, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid exchange rate %q, expected CURRENCY:RATE", pair)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("invalid exchange rate %q, expected CURRENCY:RATE", pair)
+		}
+		perUSD[strings.ToUpper(strings.TrimSpace(currency))] = rate
+	}
+	return &staticExchangeRates{perUSD: perUSD}, nil
+}
+
+func (r *staticExchangeRates) Rate(_ context.Context, from, to string) (float64, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == "" {
+		from = baseCurrency
+	}
+	if to == "" || to == from {
+		return 1, nil
+	}
+
+	fromRate, ok := r.perUSD[from]
+	if !ok {
+		return 0, fmt.Errorf("no exchange rate for %s", from)
+	}
+	toRate, ok := r.perUSD[to]
+	if !ok {
+		return 0, fmt.Errorf("no exchange rate for %s", to)
+	}
+	return toRate / fromRate, nil
+}
@@ -0,0 +1,121 @@
+package namecheap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/config"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/repository"
+)
+
+func TestQuoteDomainPrice(t *testing.T) {
+	assert.Equal(t, interfaces.NamecheapDomainPrice{Domain: "acme.com", Currency: "USD", Cost: 10.98, Price: 10.98},
+		quoteDomainPrice("acme.com", 10.98, 1, 0, "usd"))
+	assert.Equal(t, interfaces.NamecheapDomainPrice{Domain: "acme.com", Currency: "USD", Cost: 10.98, Price: 13.18},
+		quoteDomainPrice("acme.com", 10.98, 1, 20, "USD"))
+	assert.Equal(t, interfaces.NamecheapDomainPrice{Domain: "acme.com", Currency: "EUR", Cost: 10.1, Price: 11.11},
+		quoteDomainPrice("acme.com", 10.98, 0.92, 10, "EUR"))
+}
+
+func TestPriceExceedsMax(t *testing.T) {
+	assert.False(t, priceExceedsMax(19.99, 20))
+	assert.False(t, priceExceedsMax(20.004, 20))
+	assert.True(t, priceExceedsMax(20.01, 20))
+
+	// a 20 EUR max with a 10.98 USD domain and 0.92 EUR per USD
+	assert.False(t, priceExceedsMax(10.98*0.92, 20))
+	// the same domain at 2 EUR per USD
+	assert.True(t, priceExceedsMax(10.98*2, 20))
+}
+
+func TestStaticExchangeRates(t *testing.T) {
+	rates, err := newStaticExchangeRates("EUR:0.92, gbp:0.8")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	rate, err := rates.Rate(ctx, "USD", "EUR")
+	require.NoError(t, err)
+	assert.Equal(t, 0.92, rate)
+
+	rate, err = rates.Rate(ctx, "GBP", "USD")
+	require.NoError(t, err)
+	assert.Equal(t, 1.25, rate)
+
+	rate, err = rates.Rate(ctx, "EUR", "")
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, rate)
+
+	_, err = rates.Rate(ctx, "USD", "JPY")
+	require.Error(t, err)
+
+	_, err = newStaticExchangeRates("EUR=0.92")
+	require.Error(t, err)
+	_, err = newStaticExchangeRates("EUR:-1")
+	require.Error(t, err)
+}
+
+func TestTLDPriceCache(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	cache := newTLDPriceCache(time.Hour)
+	cache.now = func() time.Time { return now }
+
+	_, ok := cache.get("com")
+	assert.False(t, ok)
+
+	cache.set("COM", 10.98, "USD")
+	price, ok := cache.get("com")
+	require.True(t, ok)
+	assert.Equal(t, 10.98, price.price)
+
+	now = now.Add(61 * time.Minute)
+	_, ok = cache.get("com")
+	assert.False(t, ok)
+}
+
+type fakeTenantCurrencyRepository struct {
+	interfaces.TenantCurrencyRepository
+	currencies map[string]string
+}
+
+func (f *fakeTenantCurrencyRepository) GetByTenant(_ context.Context, tenant string) (*models.TenantCurrency, error) {
+	currency, ok := f.currencies[tenant]
+	if !ok {
+		return nil, nil
+	}
+	return &models.TenantCurrency{Tenant: tenant, Currency: currency}, nil
+}
+
+func TestQuoteCurrency(t *testing.T) {
+	s := &namecheapService{
+		cfg: &config.NamecheapConfig{PriceCurrency: "usd"},
+		postgres: &repository.Repositories{
+			TenantCurrencyRepository: &fakeTenantCurrencyRepository{currencies: map[string]string{"acme": "eur"}},
+		},
+	}
+	ctx := context.Background()
+
+	cases := []struct {
+		tenant, requested, want string
+	}{
+		{tenant: "acme", requested: "gbp", want: "GBP"},
+		{tenant: "acme", want: "EUR"},
+		{tenant: "other", want: "USD"},
+		{want: "USD"},
+	}
+	for _, tc := range cases {
+		got, err := s.quoteCurrency(ctx, tc.tenant, tc.requested)
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, got, tc)
+	}
+}
+
+func TestNewNamecheapServiceInvalidRates(t *testing.T) {
+	_, err := NewNamecheapService(&config.NamecheapConfig{ExchangeRates: "EUR=0.92"}, nil)
+	require.Error(t, err)
+}
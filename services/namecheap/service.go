@@ -1,6 +1,7 @@
 package namecheap
 
 import (
+	"context"
 	"encoding/xml"
 	"fmt"
 	"io"
@@ -8,30 +9,43 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/opentracing/opentracing-go"
 	tracingLog "github.com/opentracing/opentracing-go/log"
 	"github.com/pkg/errors"
-	"golang.org/x/net/context"
 
 	"github.com/customeros/mailstack/interfaces"
 	"github.com/customeros/mailstack/internal/config"
 	er "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/models"
 	"github.com/customeros/mailstack/internal/repository"
 	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
 )
 
 // Namecheap supported commands: https://www.namecheap.com/support/api/methods/
 type namecheapService struct {
-	cfg      *config.NamecheapConfig
-	postgres *repository.Repositories
+	cfg        *config.NamecheapConfig
+	postgres   *repository.Repositories
+	domainInfo *domainInfoCache
+	prices     *tldPriceCache
+	rates      interfaces.ExchangeRateSource
 }
 
-func NewNamecheapService(cfg *config.NamecheapConfig, postgres *repository.Repositories) interfaces.NamecheapService {
-	return &namecheapService{
-		cfg:      cfg,
-		postgres: postgres,
+func NewNamecheapService(cfg *config.NamecheapConfig, postgres *repository.Repositories) (interfaces.NamecheapService, error) {
+	rates, err := newStaticExchangeRates(cfg.ExchangeRates)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load Namecheap exchange rates")
 	}
+
+	return &namecheapService{
+		cfg:        cfg,
+		postgres:   postgres,
+		domainInfo: newDomainInfoCache(time.Duration(cfg.DomainInfoCacheTTLSeconds)*time.Second, time.Duration(cfg.DomainInfoCacheMaxAgeSeconds)*time.Second),
+		prices:     newTLDPriceCache(time.Duration(cfg.PriceCacheTTLSeconds) * time.Second),
+		rates:      rates,
+	}, nil
 }
 
 // CheckDomainAvailability checks if the domain is available using Namecheap API
@@ -127,6 +141,13 @@ func (s *namecheapService) PurchaseDomain(ctx context.Context, tenant, domain st
 		return err
 	}
 
+	// record the purchase first, a registration we fail to store can then be reconciled
+	purchase, err := s.postgres.DomainRepository.CreatePurchase(ctx, tenant, domain)
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "failed to record domain purchase"))
+		return err
+	}
+
 	params := url.Values{}
 	params.Add("ApiKey", s.cfg.ApiKey)
 	params.Add("ApiUser", s.cfg.ApiUser)
@@ -232,6 +253,7 @@ func (s *namecheapService) PurchaseDomain(ctx context.Context, tenant, domain st
 			errMsg := fmt.Sprintf("Error %s: %s", e.Number, e.Message)
 			tracing.TraceErr(span, fmt.Errorf(errMsg))
 		}
+		s.updatePurchaseStatus(ctx, span, purchase.ID, models.DomainPurchaseStatusFailed, "")
 		return fmt.Errorf("Namecheap API returned errors")
 	}
 
@@ -239,8 +261,10 @@ func (s *namecheapService) PurchaseDomain(ctx context.Context, tenant, domain st
 	if !result.CommandResponse.DomainCreateResult.Registered {
 		err = fmt.Errorf("failed to register domain %s: Namecheap API returned unsuccessful status", domain)
 		tracing.TraceErr(span, err)
+		s.updatePurchaseStatus(ctx, span, purchase.ID, models.DomainPurchaseStatusFailed, "")
 		return err
 	}
+	s.updatePurchaseStatus(ctx, span, purchase.ID, models.DomainPurchaseStatusRegistered, result.CommandResponse.DomainCreateResult.OrderID)
 
 	// Log and store the purchase details
 	span.LogFields(
@@ -250,32 +274,37 @@ func (s *namecheapService) PurchaseDomain(ctx context.Context, tenant, domain st
 		tracingLog.String("result.chargedAmount", result.CommandResponse.DomainCreateResult.ChargedAmount),
 	)
 
-	// Store domain
+	// Store domain, the purchase stays REGISTERED on failure and is picked up by the reconciliation
 	_, err = s.postgres.DomainRepository.RegisterDomain(ctx, tenant, domain)
 	if err != nil {
 		tracing.TraceErr(span, errors.Wrap(err, "failed to store mailstack domain in postgres"))
-		return nil
+		return fmt.Errorf("%w: %v", er.ErrDomainNotStored, err)
 	}
+	s.updatePurchaseStatus(ctx, span, purchase.ID, models.DomainPurchaseStatusStored, "")
 
-	// Return purchase result details
 	return nil
 }
 
-func (s *namecheapService) GetDomainPrice(ctx context.Context, domain string) (float64, error) {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "NamecheapService.GetDomainPrice")
+// updatePurchaseStatus only traces failures, the registrar outcome decides the result of the purchase
+func (s *namecheapService) updatePurchaseStatus(ctx context.Context, span opentracing.Span, id uint64, status models.DomainPurchaseStatus, orderID string) {
+	if err := s.postgres.DomainRepository.UpdatePurchaseStatus(ctx, id, status, orderID); err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "failed to update domain purchase status"))
+	}
+}
+
+// getTLDPrice returns the one year registration price of the TLD and its currency
+func (s *namecheapService) getTLDPrice(ctx context.Context, tld string) (float64, string, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "NamecheapService.getTLDPrice")
 	defer span.Finish()
-	span.LogKV("domain", domain)
+	span.LogKV("tld", tld)
 
 	// validate if namecheap is configured
 	if s.cfg.ApiKey == "" || s.cfg.ApiUser == "" || s.cfg.ApiUsername == "" || s.cfg.ApiClientIp == "" {
 		err := errors.New("Namecheap API configuration is missing")
 		tracing.TraceErr(span, err)
-		return 0, err
+		return 0, "", err
 	}
 
-	// Extract the TLD from the domain (e.g., "com" from "example.com")
-	tld := strings.Split(domain, ".")[1]
-
 	params := url.Values{}
 	params.Add("ApiKey", s.cfg.ApiKey)
 	params.Add("ApiUser", s.cfg.ApiUser)
@@ -289,7 +318,7 @@ func (s *namecheapService) GetDomainPrice(ctx context.Context, domain string) (f
 	resp, err := http.PostForm(s.cfg.Url, params)
 	if err != nil {
 		tracing.TraceErr(span, errors.Wrap(err, "failed to call Namecheap API for domain pricing"))
-		return 0, err
+		return 0, "", err
 	}
 	defer resp.Body.Close()
 
@@ -297,7 +326,7 @@ func (s *namecheapService) GetDomainPrice(ctx context.Context, domain string) (f
 	span.LogFields(tracingLog.String("responseBody", string(responseBody)))
 	if err != nil {
 		tracing.TraceErr(span, errors.Wrap(err, "failed to read Namecheap response"))
-		return 0, err
+		return 0, "", err
 	}
 
 	// Define the XML struct for domain pricing response
@@ -337,7 +366,7 @@ func (s *namecheapService) GetDomainPrice(ctx context.Context, domain string) (f
 
 	if err = xml.Unmarshal(responseBody, &result); err != nil {
 		tracing.TraceErr(span, errors.Wrap(err, "failed to parse Namecheap XML response"))
-		return 0, err
+		return 0, "", err
 	}
 	// Check if any errors exist
 	if len(result.Errors.Error) > 0 {
@@ -345,7 +374,7 @@ func (s *namecheapService) GetDomainPrice(ctx context.Context, domain string) (f
 			errMsg := fmt.Sprintf("Error %s: %s", e.Number, e.Message)
 			tracing.TraceErr(span, fmt.Errorf(errMsg))
 		}
-		return 0, fmt.Errorf("Namecheap API returned errors")
+		return 0, "", fmt.Errorf("Namecheap API returned errors")
 	}
 
 	// Search for the TLD pricing information
@@ -359,10 +388,10 @@ func (s *namecheapService) GetDomainPrice(ctx context.Context, domain string) (f
 							parsedPrice, err := strconv.ParseFloat(price.YourPrice, 64)
 							if err != nil {
 								tracing.TraceErr(span, errors.Wrap(err, "failed to parse registration price"))
-								return 0, err
+								return 0, "", err
 							}
-							span.LogKV("result.price", parsedPrice)
-							return parsedPrice, nil
+							span.LogKV("result.price", parsedPrice, "result.currency", price.Currency)
+							return parsedPrice, price.Currency, nil
 						}
 					}
 				}
@@ -370,7 +399,7 @@ func (s *namecheapService) GetDomainPrice(ctx context.Context, domain string) (f
 		}
 	}
 
-	return 0, errors.New("domain price not found")
+	return 0, "", errors.New("domain price not found")
 }
 
 func (s *namecheapService) GetDomainInfo(ctx context.Context, tenant, domain string) (interfaces.NamecheapDomainInfo, error) {
@@ -404,6 +433,7 @@ func (s *namecheapService) GetDomainInfo(ctx context.Context, tenant, domain str
 	params.Add("UserName", s.cfg.ApiUsername)
 	params.Add("ClientIp", s.cfg.ApiClientIp)
 	params.Add("Command", "namecheap.domains.getInfo")
+	generation := s.domainInfo.generation(tenant, domain)
 	params.Add("DomainName", domain)
 
 	// Execute the request
@@ -489,12 +519,14 @@ func (s *namecheapService) GetDomainInfo(ctx context.Context, tenant, domain str
 		DomainName:  result.CommandResponse.DomainGetInfoResult.DomainName,
 		CreatedDate: result.CommandResponse.DomainGetInfoResult.DomainDetails.CreatedDate,
 		ExpiredDate: result.CommandResponse.DomainGetInfoResult.DomainDetails.ExpiredDate,
+		ExpiresAt:   parseNamecheapDate(result.CommandResponse.DomainGetInfoResult.DomainDetails.ExpiredDate),
 		Nameservers: result.CommandResponse.DomainGetInfoResult.DnsDetails.Nameservers,
 		WhoisGuard:  result.CommandResponse.DomainGetInfoResult.WhoisGuard.Enabled,
 	}
 
 	// Log retrieved domain info
 	span.LogKV("domainInfo", domainInfo)
+	s.domainInfo.set(tenant, domain, generation, domainInfo)
 
 	return domainInfo, nil
 }
@@ -524,8 +556,11 @@ func (s *namecheapService) UpdateNameservers(ctx context.Context, tenant, domain
 		return err
 	}
 
-	sld := strings.Split(domain, ".")[0]
-	tld := strings.Split(domain, ".")[1]
+	sld, tld, err := utils.SplitDomain(domain)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
 
 	// Prepare the parameters for the Namecheap API call
 	params := url.Values{}
@@ -595,6 +630,136 @@ func (s *namecheapService) UpdateNameservers(ctx context.Context, tenant, domain
 
 	// Log success
 	span.LogKV("result", "success")
+	s.domainInfo.invalidate(tenant, domain)
+
+	return nil
+}
+
+// RenewDomain renews a registered domain for the given number of years and records the renewal
+func (s *namecheapService) RenewDomain(ctx context.Context, tenant, domain string, years int) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "NamecheapService.RenewDomain")
+	defer span.Finish()
+	tracing.TagTenant(span, tenant)
+	span.LogKV("domain", domain, "years", years)
+
+	// validate if namecheap is configured
+	if s.cfg.ApiKey == "" || s.cfg.ApiUser == "" || s.cfg.ApiUsername == "" || s.cfg.ApiClientIp == "" {
+		err := errors.New("Namecheap API configuration is missing")
+		tracing.TraceErr(span, err)
+		return err
+	}
+	if years < 1 {
+		years = 1
+	}
+
+	// Check if domain belongs to the tenant in PostgreSQL and is active
+	exists, err := s.postgres.DomainRepository.CheckDomainOwnership(ctx, tenant, domain)
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "failed to check domain ownership in postgres"))
+		return err
+	}
+	if !exists {
+		err := fmt.Errorf("domain %s does not belong to tenant %s or is not active", domain, tenant)
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	params := url.Values{}
+	params.Add("ApiKey", s.cfg.ApiKey)
+	params.Add("ApiUser", s.cfg.ApiUser)
+	params.Add("UserName", s.cfg.ApiUsername)
+	params.Add("ClientIp", s.cfg.ApiClientIp)
+	params.Add("Command", "namecheap.domains.renew")
+	params.Add("DomainName", domain)
+	params.Add("Years", strconv.Itoa(years))
+
+	// Execute the request
+	resp, err := http.PostForm(s.cfg.Url, params)
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "failed to call Namecheap API for domain renewal"))
+		return err
+	}
+	defer resp.Body.Close()
 
+	responseBody, err := io.ReadAll(resp.Body)
+	span.LogFields(tracingLog.String("responseBody", string(responseBody)))
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "failed to read Namecheap response"))
+		return err
+	}
+
+	// Define namecheap XML struct for domain renewal result
+	type NamecheapRenewResult struct {
+		XMLName xml.Name `xml:"ApiResponse"`
+		Status  string   `xml:"Status,attr"`
+		Errors  struct {
+			Error []struct {
+				Number  string `xml:"Number,attr"`
+				Message string `xml:",chardata"`
+			} `xml:"Error"`
+		} `xml:"Errors"`
+		CommandResponse struct {
+			DomainRenewResult struct {
+				DomainName    string `xml:"DomainName,attr"`
+				Renew         bool   `xml:"Renew,attr"`
+				OrderID       string `xml:"OrderID,attr"`
+				TransactionID string `xml:"TransactionID,attr"`
+				ChargedAmount string `xml:"ChargedAmount,attr"`
+				DomainDetails struct {
+					ExpiredDate string `xml:"ExpiredDate"`
+				} `xml:"DomainDetails"`
+			} `xml:"DomainRenewResult"`
+		} `xml:"CommandResponse"`
+	}
+	var result NamecheapRenewResult
+
+	if err = xml.Unmarshal(responseBody, &result); err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "failed to parse Namecheap XML response"))
+		return err
+	}
+	// Check if any errors exist
+	if len(result.Errors.Error) > 0 {
+		for _, e := range result.Errors.Error {
+			errMsg := fmt.Sprintf("Error %s: %s", e.Number, e.Message)
+			tracing.TraceErr(span, fmt.Errorf(errMsg))
+		}
+		return fmt.Errorf("Namecheap API returned errors")
+	}
+
+	renewal := result.CommandResponse.DomainRenewResult
+	if !renewal.Renew {
+		err = fmt.Errorf("failed to renew domain %s: Namecheap API returned unsuccessful status", domain)
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	span.LogFields(
+		tracingLog.String("result.orderID", renewal.OrderID),
+		tracingLog.String("result.transactionID", renewal.TransactionID),
+		tracingLog.String("result.chargedAmount", renewal.ChargedAmount),
+		tracingLog.String("result.expiredDate", renewal.DomainDetails.ExpiredDate),
+	)
+
+	s.domainInfo.invalidate(tenant, domain)
+
+	// the domain is renewed at this point, a failed write only loses the bookkeeping
+	err = s.postgres.DomainRepository.RecordRenewal(ctx, tenant, domain, parseNamecheapDate(renewal.DomainDetails.ExpiredDate))
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "failed to record domain renewal in postgres"))
+	}
+
+	return nil
+}
+
+// namecheap returns dates as MM/DD/YYYY, the renew command adds a time of day
+var namecheapDateLayouts = []string{"01/02/2006", "1/2/2006 3:04:05 PM", "1/2/2006"}
+
+func parseNamecheapDate(value string) *time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range namecheapDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return &t
+		}
+	}
 	return nil
 }
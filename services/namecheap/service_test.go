@@ -0,0 +1,25 @@
+package namecheap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseNamecheapDate(t *testing.T) {
+	cases := map[string]time.Time{
+		"10/11/2026":            time.Date(2026, 10, 11, 0, 0, 0, 0, time.UTC),
+		"11/9/2026 11:31:26 AM": time.Date(2026, 11, 9, 11, 31, 26, 0, time.UTC),
+		" 3/4/2027 ":            time.Date(2027, 3, 4, 0, 0, 0, 0, time.UTC),
+	}
+	for input, want := range cases {
+		got := parseNamecheapDate(input)
+		if assert.NotNil(t, got, input) {
+			assert.True(t, want.Equal(*got), input)
+		}
+	}
+
+	assert.Nil(t, parseNamecheapDate(""))
+	assert.Nil(t, parseNamecheapDate("2026-10-11"))
+}
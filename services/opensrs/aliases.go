@@ -0,0 +1,207 @@
+package opensrs
+
+import (
+	"context"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+
+	er "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+// SetupAlias creates or updates a forward-only address that delivers to forwardTo. An address
+// that is already a mailbox is refused with ErrInvalidAlias.
+func (s *openSRSService) SetupAlias(ctx context.Context, tenant, alias, forwardTo string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "OpensrsService.SetupAlias")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	tracing.TagTenant(span, tenant)
+	span.LogKV("alias", alias, "forwardTo", forwardTo)
+
+	alias = normalizeAddress(alias)
+	forwardTo = normalizeAddress(forwardTo)
+	if !isValidAddress(alias) || !isValidAddress(forwardTo) || alias == forwardTo {
+		tracing.TraceErr(span, er.ErrInvalidAlias)
+		return er.ErrInvalidAlias
+	}
+	domain := utils.ExtractDomainFromEmail(alias)
+
+	if err := s.checkDomainOwnership(ctx, tenant, domain); err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	// change_user would turn an existing mailbox into a forward, only aliases created here are updated
+	record, err := s.postgres.MailboxAliasRepository.GetByAlias(ctx, tenant, alias)
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "failed to get alias"))
+		return err
+	}
+	exists, err := s.userExists(ctx, alias)
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "failed to get user from OpenSRS"))
+		return err
+	}
+	if exists && (record == nil || record.CatchAll) {
+		tracing.TraceErr(span, er.ErrInvalidAlias)
+		return er.ErrInvalidAlias
+	}
+
+	err = s.callAPI(ctx, "change_user", map[string]interface{}{
+		"user": alias,
+		"attributes": map[string]interface{}{
+			"type":               "forward",
+			"delivery_forward":   true,
+			"forward_recipients": []string{forwardTo},
+		},
+	}, nil)
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "failed to configure alias in OpenSRS"))
+		s.log.Error("failed to configure alias in OpenSRS", err)
+		return err
+	}
+
+	err = s.postgres.MailboxAliasRepository.Upsert(ctx, &models.MailboxAlias{
+		Tenant:    tenant,
+		Domain:    domain,
+		Alias:     alias,
+		ForwardTo: forwardTo,
+	})
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "failed to save alias"))
+		return err
+	}
+
+	return nil
+}
+
+// RemoveAlias deletes a forward-only address created by SetupAlias
+func (s *openSRSService) RemoveAlias(ctx context.Context, tenant, alias string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "OpensrsService.RemoveAlias")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	tracing.TagTenant(span, tenant)
+	span.LogKV("alias", alias)
+
+	alias = normalizeAddress(alias)
+
+	// only aliases we created are removed, delete_user would drop real mailboxes too
+	record, err := s.postgres.MailboxAliasRepository.GetByAlias(ctx, tenant, alias)
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "failed to get alias"))
+		return err
+	}
+	if record == nil || record.CatchAll {
+		tracing.TraceErr(span, er.ErrInvalidAlias)
+		return er.ErrInvalidAlias
+	}
+
+	err = s.deleteUser(ctx, alias)
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "failed to delete alias in OpenSRS"))
+		s.log.Error("failed to delete alias in OpenSRS", err)
+		return err
+	}
+
+	err = s.postgres.MailboxAliasRepository.Delete(ctx, tenant, alias)
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "failed to delete alias"))
+		return err
+	}
+
+	return nil
+}
+
+// SetDomainCatchAll delivers mail for unknown addresses of the domain to destination.
+// An empty destination turns the catch-all off.
+func (s *openSRSService) SetDomainCatchAll(ctx context.Context, tenant, domain, destination string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "OpensrsService.SetDomainCatchAll")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	tracing.TagTenant(span, tenant)
+	span.LogKV("domain", domain, "destination", destination)
+
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	destination = normalizeAddress(destination)
+	if destination != "" && !isValidAddress(destination) {
+		tracing.TraceErr(span, er.ErrInvalidAlias)
+		return er.ErrInvalidAlias
+	}
+
+	if err := s.checkDomainOwnership(ctx, tenant, domain); err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	err := s.callAPI(ctx, "change_domain", map[string]interface{}{
+		"domain": domain,
+		"attributes": map[string]interface{}{
+			"catchall": destination,
+		},
+	}, nil)
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "failed to configure catch-all in OpenSRS"))
+		s.log.Error("failed to configure catch-all in OpenSRS", err)
+		return err
+	}
+
+	if destination == "" {
+		err = s.postgres.MailboxAliasRepository.Delete(ctx, tenant, models.CatchAllAlias(domain))
+	} else {
+		err = s.postgres.MailboxAliasRepository.Upsert(ctx, &models.MailboxAlias{
+			Tenant:    tenant,
+			Domain:    domain,
+			Alias:     models.CatchAllAlias(domain),
+			ForwardTo: destination,
+			CatchAll:  true,
+		})
+	}
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "failed to save catch-all"))
+		return err
+	}
+
+	return nil
+}
+
+// userExists asks OpenSRS for the user, mailboxes and forwards alike
+func (s *openSRSService) userExists(ctx context.Context, user string) (bool, error) {
+	err := s.callAPI(ctx, "get_user", map[string]interface{}{
+		"user": user,
+	}, nil)
+	if errors.Is(err, er.ErrOpenSRSObjectNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *openSRSService) checkDomainOwnership(ctx context.Context, tenant, domain string) error {
+	belongsToTenant, err := s.postgres.DomainRepository.CheckDomainOwnership(ctx, tenant, domain)
+	if err != nil {
+		return errors.Wrap(err, "failed to check domain ownership")
+	}
+	if !belongsToTenant {
+		return er.ErrDomainNotFound
+	}
+	return nil
+}
+
+func normalizeAddress(address string) string {
+	return strings.ToLower(strings.TrimSpace(address))
+}
+
+// isValidAddress checks for a single local part and domain, without display names
+func isValidAddress(address string) bool {
+	local, domain, found := strings.Cut(address, "@")
+	if !found || local == "" || domain == "" {
+		return false
+	}
+	return !strings.ContainsAny(address, " <>,*") && !strings.Contains(domain, "@") && strings.Contains(domain, ".")
+}
@@ -0,0 +1,90 @@
+package opensrs
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	er "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/repository"
+)
+
+func TestIsValidAddress(t *testing.T) {
+	assert.True(t, isValidAddress("sales@example.com"))
+	assert.False(t, isValidAddress("sales"))
+	assert.False(t, isValidAddress("@example.com"))
+	assert.False(t, isValidAddress("sales@"))
+	assert.False(t, isValidAddress("sales@localhost"))
+	assert.False(t, isValidAddress("*@example.com"))
+	assert.False(t, isValidAddress("a@b@example.com"))
+	assert.False(t, isValidAddress("Sales <sales@example.com>"))
+}
+
+type fakeOwnedDomainRepository struct {
+	repository.DomainRepository
+}
+
+func (r *fakeOwnedDomainRepository) CheckDomainOwnership(_ context.Context, tenant, domain string) (bool, error) {
+	return tenant == "acme" && domain == "acme.io", nil
+}
+
+type fakeAliasRepository struct {
+	repository.MailboxAliasRepository
+	aliases  map[string]*models.MailboxAlias
+	upserted []string
+}
+
+func (r *fakeAliasRepository) GetByAlias(_ context.Context, _, alias string) (*models.MailboxAlias, error) {
+	return r.aliases[alias], nil
+}
+
+func (r *fakeAliasRepository) Upsert(_ context.Context, alias *models.MailboxAlias) error {
+	r.upserted = append(r.upserted, alias.Alias)
+	return nil
+}
+
+func TestSetupAlias(t *testing.T) {
+	found := respond(http.StatusOK, `{"success":true,"attributes":{"type":"mailbox"}}`)
+	notFound := respond(http.StatusOK, `{"success":false,"error":"User not found","error_number":4}`)
+	ok := respond(http.StatusOK, `{"success":true}`)
+
+	newAliasService := func(t *testing.T, responses ...func(w http.ResponseWriter)) (*openSRSService, *fakeAliasRepository, func() int32) {
+		s, calls := newAPIServer(t, responses...)
+		aliases := &fakeAliasRepository{aliases: map[string]*models.MailboxAlias{
+			"sales@acme.io": {Tenant: "acme", Domain: "acme.io", Alias: "sales@acme.io", ForwardTo: "jane@acme.io"},
+		}}
+		s.postgres = &repository.Repositories{
+			DomainRepository:       &fakeOwnedDomainRepository{},
+			MailboxAliasRepository: aliases,
+		}
+		return s, aliases, calls.Load
+	}
+
+	t.Run("new alias", func(t *testing.T) {
+		s, aliases, calls := newAliasService(t, notFound, ok)
+
+		require.NoError(t, s.SetupAlias(context.Background(), "acme", "info@acme.io", "jane@acme.io"))
+		assert.Equal(t, []string{"info@acme.io"}, aliases.upserted)
+		assert.Equal(t, int32(2), calls())
+	})
+
+	t.Run("existing alias is updated", func(t *testing.T) {
+		s, aliases, _ := newAliasService(t, found, ok)
+
+		require.NoError(t, s.SetupAlias(context.Background(), "acme", "sales@acme.io", "bob@acme.io"))
+		assert.Equal(t, []string{"sales@acme.io"}, aliases.upserted)
+	})
+
+	t.Run("existing mailbox is not turned into an alias", func(t *testing.T) {
+		s, aliases, calls := newAliasService(t, found, ok)
+
+		err := s.SetupAlias(context.Background(), "acme", "bob@acme.io", "jane@acme.io")
+		assert.ErrorIs(t, err, er.ErrInvalidAlias)
+		assert.Empty(t, aliases.upserted)
+		assert.Equal(t, int32(1), calls())
+	})
+}
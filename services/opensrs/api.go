@@ -0,0 +1,203 @@
+package opensrs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	tracingLog "github.com/opentracing/opentracing-go/log"
+	"github.com/pkg/errors"
+
+	er "github.com/customeros/mailstack/internal/errors"
+)
+
+const (
+	apiTimeout           = 10 * time.Second
+	defaultAPIAttempts   = 3
+	defaultAPIRetryDelay = 500 * time.Millisecond
+)
+
+// error_number values of failed email API responses
+const (
+	errorNumberAuthFailed       = 1
+	errorNumberPermissionDenied = 2
+	errorNumberInvalidData      = 3
+	errorNumberNotFound         = 4
+	errorNumberInternal         = 6
+)
+
+// APIError is a request the OpenSRS email API did not complete, either with an HTTP error status
+// or with an unsuccessful response. It unwraps to the mailstack error of its error number or
+// status, so callers can use errors.Is.
+type APIError struct {
+	Method     string
+	StatusCode int
+	Number     int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.StatusCode != http.StatusOK {
+		return fmt.Sprintf("API request %s failed, status code: %d", e.Method, e.StatusCode)
+	}
+	return fmt.Sprintf("API request %s failed: %s (error %d)", e.Method, e.Message, e.Number)
+}
+
+func (e *APIError) Unwrap() error {
+	if e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError {
+		return er.ErrOpenSRSUnavailable
+	}
+	if e.StatusCode != http.StatusOK {
+		return nil
+	}
+	switch e.Number {
+	case errorNumberAuthFailed:
+		return er.ErrOpenSRSAuthFailed
+	case errorNumberPermissionDenied:
+		return er.ErrOpenSRSPermissionDenied
+	case errorNumberInvalidData:
+		return er.ErrOpenSRSInvalidRequest
+	case errorNumberNotFound:
+		return er.ErrOpenSRSObjectNotFound
+	case errorNumberInternal:
+		return er.ErrOpenSRSUnavailable
+	}
+	return nil
+}
+
+// isTransientAPIError reports whether a failed request is worth retrying: network failures,
+// rate limiting, server errors and internal errors of OpenSRS
+func isTransientAPIError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, er.ErrOpenSRSUnavailable) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// callAPI posts a request to the OpenSRS email API and checks the success flag of the response.
+// The response is decoded into result when it is not nil. Transient failures are retried with a
+// doubling delay. The get and change methods are safe to repeat, delete_user is not: a retry
+// after an attempt that went through finds the user gone, use deleteUser for it.
+func (s *openSRSService) callAPI(ctx context.Context, method string, requestBody map[string]interface{}, result interface{}) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "OpensrsService.callAPI")
+	defer span.Finish()
+	span.LogKV("method", method)
+
+	if s.openSrsConfig.Username == "" || s.openSrsConfig.ApiKey == "" {
+		return errors.New("OpenSRS credentials not set")
+	}
+
+	requestBody["credentials"] = map[string]string{
+		"user":     s.openSrsConfig.Username,
+		"password": s.openSrsConfig.ApiKey,
+	}
+	requestData, err := json.Marshal(requestBody)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal request body")
+	}
+
+	var body []byte
+	delay := s.apiRetryDelay()
+	for attempt := 1; ; attempt++ {
+		body, err = s.post(ctx, method, requestData)
+		if len(body) > 0 {
+			span.LogKV("responseBody", string(body))
+		}
+		if err == nil || !isTransientAPIError(err) || attempt >= s.apiAttempts() {
+			break
+		}
+
+		span.LogFields(tracingLog.Int("attempt", attempt), tracingLog.String("retryIn", delay.String()))
+		s.log.Warnf("OpenSRS %s failed on attempt %d, retrying in %v: %v", method, attempt, delay, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+		delay *= 2
+	}
+	if err != nil {
+		span.LogFields(tracingLog.Bool("transient", isTransientAPIError(err)))
+		return err
+	}
+
+	if result != nil {
+		if err = json.Unmarshal(body, result); err != nil {
+			return errors.Wrap(err, "failed to unmarshal response")
+		}
+	}
+	return nil
+}
+
+// deleteUser deletes a mailbox or forward. A user that is already gone counts as deleted, it is
+// what a retry sees when the first attempt went through but its response was lost.
+func (s *openSRSService) deleteUser(ctx context.Context, user string) error {
+	err := s.callAPI(ctx, "delete_user", map[string]interface{}{
+		"user": user,
+	}, nil)
+	if errors.Is(err, er.ErrOpenSRSObjectNotFound) {
+		return nil
+	}
+	return err
+}
+
+// post makes a single attempt of a request, returning the response body whenever one was read
+func (s *openSRSService) post(ctx context.Context, method string, requestData []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", s.openSrsConfig.Url+"/api/"+method, bytes.NewReader(requestData))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create HTTP request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: apiTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to make API request")
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read response body")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return body, &APIError{Method: method, StatusCode: resp.StatusCode}
+	}
+
+	var response OpenSRSResponse
+	if err = json.Unmarshal(body, &response); err != nil {
+		return body, errors.Wrap(err, "failed to unmarshal response")
+	}
+	if !response.Success {
+		return body, &APIError{Method: method, StatusCode: resp.StatusCode, Number: response.ErrorNumber, Message: response.Error}
+	}
+	return body, nil
+}
+
+func (s *openSRSService) apiAttempts() int {
+	if s.apiMaxAttempts > 0 {
+		return s.apiMaxAttempts
+	}
+	return defaultAPIAttempts
+}
+
+func (s *openSRSService) apiRetryDelay() time.Duration {
+	if s.apiRetryBaseDelay > 0 {
+		return s.apiRetryBaseDelay
+	}
+	return defaultAPIRetryDelay
+}
@@ -0,0 +1,132 @@
+package opensrs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/internal/config"
+	er "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/logger"
+)
+
+// newAPIServer answers the email API with the responses in order, repeating the last one
+func newAPIServer(t *testing.T, responses ...func(w http.ResponseWriter)) (*openSRSService, *atomic.Int32) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.NotNil(t, body["credentials"])
+
+		call := int(calls.Add(1))
+		responses[min(call, len(responses))-1](w)
+	}))
+	t.Cleanup(server.Close)
+
+	log := logger.NewAppLogger(&logger.Config{DevMode: true})
+	log.InitLogger()
+	return &openSRSService{
+		log:               log,
+		openSrsConfig:     &config.OpenSRSConfig{Url: server.URL, Username: "reseller", ApiKey: "key"},
+		apiRetryBaseDelay: time.Millisecond,
+	}, &calls
+}
+
+func respond(status int, body string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}
+}
+
+func TestCallAPIRetriesTransientFailures(t *testing.T) {
+	s, calls := newAPIServer(t,
+		respond(http.StatusBadGateway, "bad gateway"),
+		respond(http.StatusOK, `{"success":false,"error":"Internal error","error_number":6}`),
+		respond(http.StatusOK, `{"success":true}`),
+	)
+
+	require.NoError(t, s.callAPI(context.Background(), "change_user", map[string]interface{}{"user": "jane@acme.io"}, nil))
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestCallAPIGivesUpAfterMaxAttempts(t *testing.T) {
+	s, calls := newAPIServer(t, respond(http.StatusServiceUnavailable, ""))
+
+	err := s.callAPI(context.Background(), "get_user", map[string]interface{}{"user": "jane@acme.io"}, nil)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, er.ErrOpenSRSUnavailable)
+	assert.Equal(t, int32(defaultAPIAttempts), calls.Load())
+}
+
+func TestCallAPIClassifiesErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		expected error
+	}{
+		{"authentication", `{"success":false,"error":"Authentication failed","error_number":1}`, er.ErrOpenSRSAuthFailed},
+		{"permission", `{"success":false,"error":"Permission denied","error_number":2}`, er.ErrOpenSRSPermissionDenied},
+		{"invalid data", `{"success":false,"error":"Invalid password","error_number":3}`, er.ErrOpenSRSInvalidRequest},
+		{"not found", `{"success":false,"error":"No such user","error_number":4}`, er.ErrOpenSRSObjectNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, calls := newAPIServer(t, respond(http.StatusOK, tt.response))
+
+			err := s.callAPI(context.Background(), "get_user", map[string]interface{}{"user": "jane@acme.io"}, nil)
+			assert.ErrorIs(t, err, tt.expected)
+			var apiErr *APIError
+			require.True(t, errors.As(err, &apiErr))
+			assert.Equal(t, "get_user", apiErr.Method)
+			assert.Equal(t, int32(1), calls.Load()) // permanent errors are not retried
+		})
+	}
+}
+
+func TestSetupMailboxReturnsFailure(t *testing.T) {
+	s, _ := newAPIServer(t, respond(http.StatusOK, `{"success":false,"error":"Invalid password","error_number":3}`))
+
+	err := s.SetupMailbox(context.Background(), "acme", "jane@acme.io", "x", nil, true)
+	assert.ErrorIs(t, err, er.ErrOpenSRSInvalidRequest)
+
+	s, _ = newAPIServer(t, respond(http.StatusOK, `not json`))
+	assert.Error(t, s.SetupMailbox(context.Background(), "acme", "jane@acme.io", "x", nil, true))
+}
+
+func TestGetMailboxDetails(t *testing.T) {
+	s, _ := newAPIServer(t, respond(http.StatusOK, `{"success":true,"attributes":{"delivery_forward":true,"forward_recipients":["bob@corp.io"],"service_webmail":"enabled"}}`))
+
+	details, err := s.GetMailboxDetails(context.Background(), "jane@acme.io")
+	require.NoError(t, err)
+	assert.True(t, details.ForwardingEnabled)
+	assert.Equal(t, []string{"bob@corp.io"}, details.ForwardingTo)
+	assert.True(t, details.WebmailEnabled)
+}
+
+func TestGetMailboxDetailsMissingAttributes(t *testing.T) {
+	// OpenSRS leaves out the forwarding and webmail attributes of a mailbox without forwarding
+	for _, response := range []string{
+		`{"success":true,"attributes":{"type":"mailbox","forward_recipients":null}}`,
+		`{"success":true,"attributes":{}}`,
+		`{"success":true}`,
+	} {
+		s, _ := newAPIServer(t, respond(http.StatusOK, response))
+
+		details, err := s.GetMailboxDetails(context.Background(), "jane@acme.io")
+		require.NoError(t, err, response)
+		assert.Equal(t, "jane@acme.io", details.Email)
+		assert.False(t, details.ForwardingEnabled)
+		assert.NotNil(t, details.ForwardingTo)
+		assert.Empty(t, details.ForwardingTo)
+		assert.False(t, details.WebmailEnabled)
+	}
+}
@@ -0,0 +1,160 @@
+package opensrs
+
+import (
+	"context"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	er "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+// DeleteMailbox removes a hosted mailbox from OpenSRS, stops syncing it and deletes the
+// stored credentials
+func (s *openSRSService) DeleteMailbox(ctx context.Context, tenant, email string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "OpensrsService.DeleteMailbox")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	tracing.TagTenant(span, tenant)
+	span.LogKV("email", email)
+
+	ctx = utils.WithTenantContext(ctx, tenant)
+	email = normalizeAddress(email)
+
+	tenantMailbox, err := s.getTenantMailbox(ctx, tenant, email)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	err = s.deleteUser(ctx, email)
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "failed to delete mailbox in OpenSRS"))
+		s.log.Error("failed to delete mailbox in OpenSRS", err)
+		return err
+	}
+	s.usageCache.invalidate(email)
+
+	mailbox, err := s.getSyncedMailbox(ctx, tenant, email)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+	if mailbox != nil {
+		if s.imap != nil {
+			if err = s.imap.RemoveMailbox(ctx, mailbox.ID); err != nil {
+				tracing.TraceErr(span, errors.Wrap(err, "failed to remove mailbox from IMAP sync"))
+				return err
+			}
+		}
+		if err = s.postgres.MailboxRepository.DeleteMailbox(ctx, mailbox.ID); err != nil {
+			tracing.TraceErr(span, errors.Wrap(err, "failed to delete mailbox"))
+			return err
+		}
+	}
+
+	err = s.postgres.TenantSettingsMailboxRepository.Delete(ctx, tenantMailbox.ID)
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "failed to delete tenant mailbox"))
+		return err
+	}
+
+	return nil
+}
+
+// ChangeMailboxPassword sets a new password in OpenSRS and updates the stored credentials.
+// SMTP reads the credentials on every send, the IMAP connection is restarted with them.
+func (s *openSRSService) ChangeMailboxPassword(ctx context.Context, tenant, email, newPassword string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "OpensrsService.ChangeMailboxPassword")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	tracing.TagTenant(span, tenant)
+	span.LogKV("email", email)
+
+	if newPassword == "" {
+		err := errors.New("password is empty")
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	ctx = utils.WithTenantContext(ctx, tenant)
+	email = normalizeAddress(email)
+
+	tenantMailbox, err := s.getTenantMailbox(ctx, tenant, email)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	err = s.callAPI(ctx, "change_user", map[string]interface{}{
+		"user": email,
+		"attributes": map[string]interface{}{
+			"password": newPassword,
+		},
+	}, nil)
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "failed to change password in OpenSRS"))
+		s.log.Error("failed to change password in OpenSRS", err)
+		return err
+	}
+
+	err = s.postgres.TenantSettingsMailboxRepository.UpdatePassword(ctx, tenantMailbox.ID, newPassword)
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "failed to update tenant mailbox password"))
+		return err
+	}
+
+	mailbox, err := s.getSyncedMailbox(ctx, tenant, email)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+	if mailbox == nil {
+		return nil
+	}
+
+	mailbox.ImapPassword = newPassword
+	mailbox.SmtpPassword = newPassword
+	if _, err = s.postgres.MailboxRepository.SaveMailbox(ctx, *mailbox); err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "failed to update mailbox credentials"))
+		return err
+	}
+
+	if s.imap != nil {
+		if err = s.imap.ReloadMailbox(ctx, mailbox); err != nil {
+			tracing.TraceErr(span, errors.Wrap(err, "failed to reload mailbox in IMAP sync"))
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getTenantMailbox returns the provisioned mailbox, or ErrMailboxNotFound when the tenant does not own it.
+// ctx must carry the tenant.
+func (s *openSRSService) getTenantMailbox(ctx context.Context, tenant, email string) (*models.TenantSettingsMailbox, error) {
+	tenantMailbox, err := s.postgres.TenantSettingsMailboxRepository.GetByMailbox(ctx, email)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tenant mailbox")
+	}
+	if tenantMailbox == nil || tenantMailbox.Tenant != tenant {
+		return nil, er.ErrMailboxNotFound
+	}
+	return tenantMailbox, nil
+}
+
+// getSyncedMailbox returns the mailbox enrolled for IMAP/SMTP, nil if there is none
+func (s *openSRSService) getSyncedMailbox(ctx context.Context, tenant, email string) (*models.Mailbox, error) {
+	mailbox, err := s.postgres.MailboxRepository.GetMailboxByEmailAddress(ctx, email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.Wrap(err, "failed to get mailbox")
+	}
+	if mailbox == nil || mailbox.Tenant != tenant {
+		return nil, nil
+	}
+	return mailbox, nil
+}
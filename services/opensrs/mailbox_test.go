@@ -0,0 +1,193 @@
+package opensrs
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/customeros/mailstack/interfaces"
+	er "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/repository"
+)
+
+type fakeTenantMailboxRepository struct {
+	repository.TenantSettingsMailboxRepository
+	mailboxes map[string]*models.TenantSettingsMailbox
+	passwords map[string]string
+	deleted   []string
+}
+
+func (r *fakeTenantMailboxRepository) GetByMailbox(_ context.Context, mailbox string) (*models.TenantSettingsMailbox, error) {
+	return r.mailboxes[mailbox], nil
+}
+
+func (r *fakeTenantMailboxRepository) UpdatePassword(_ context.Context, id, password string) error {
+	r.passwords[id] = password
+	return nil
+}
+
+func (r *fakeTenantMailboxRepository) Delete(_ context.Context, id string) error {
+	r.deleted = append(r.deleted, id)
+	return nil
+}
+
+type fakeSyncedMailboxRepository struct {
+	interfaces.MailboxRepository
+	mailboxes map[string]*models.Mailbox
+	saved     []models.Mailbox
+	deleted   []string
+}
+
+func (r *fakeSyncedMailboxRepository) GetMailboxByEmailAddress(_ context.Context, emailAddress string) (*models.Mailbox, error) {
+	if mailbox, ok := r.mailboxes[emailAddress]; ok {
+		return mailbox, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeSyncedMailboxRepository) SaveMailbox(_ context.Context, mailbox models.Mailbox) (string, error) {
+	r.saved = append(r.saved, mailbox)
+	return mailbox.ID, nil
+}
+
+func (r *fakeSyncedMailboxRepository) DeleteMailbox(_ context.Context, id string) error {
+	r.deleted = append(r.deleted, id)
+	return nil
+}
+
+type fakeMailboxIMAP struct {
+	interfaces.IMAPService
+	removed  []string
+	reloaded []*models.Mailbox
+}
+
+func (s *fakeMailboxIMAP) RemoveMailbox(_ context.Context, mailboxID string) error {
+	s.removed = append(s.removed, mailboxID)
+	return nil
+}
+
+func (s *fakeMailboxIMAP) ReloadMailbox(_ context.Context, mailbox *models.Mailbox) error {
+	s.reloaded = append(s.reloaded, mailbox)
+	return nil
+}
+
+// newMailboxService has jane@acme.io provisioned for acme and synced, and bob@acme.io
+// provisioned without sync
+func newMailboxService(t *testing.T, responses ...func(w http.ResponseWriter)) (*openSRSService, *fakeTenantMailboxRepository, *fakeSyncedMailboxRepository, *fakeMailboxIMAP) {
+	s, _ := newAPIServer(t, responses...)
+	tenantMailboxes := &fakeTenantMailboxRepository{
+		mailboxes: map[string]*models.TenantSettingsMailbox{
+			"jane@acme.io": {ID: "tsm_jane", Tenant: "acme", MailboxUsername: "jane@acme.io", MailboxPassword: "old"},
+			"bob@acme.io":  {ID: "tsm_bob", Tenant: "acme", MailboxUsername: "bob@acme.io", MailboxPassword: "old"},
+		},
+		passwords: map[string]string{},
+	}
+	mailboxes := &fakeSyncedMailboxRepository{mailboxes: map[string]*models.Mailbox{
+		"jane@acme.io": {ID: "mbox_jane", Tenant: "acme", EmailAddress: "jane@acme.io", ImapPassword: "old", SmtpPassword: "old"},
+	}}
+	imap := &fakeMailboxIMAP{}
+	s.postgres = &repository.Repositories{
+		TenantSettingsMailboxRepository: tenantMailboxes,
+		MailboxRepository:               mailboxes,
+	}
+	s.imap = imap
+	s.usageCache = newUsageCache(time.Minute)
+	return s, tenantMailboxes, mailboxes, imap
+}
+
+func TestDeleteMailbox(t *testing.T) {
+	t.Run("synced mailbox", func(t *testing.T) {
+		s, tenantMailboxes, mailboxes, imap := newMailboxService(t, respond(http.StatusOK, `{"success":true}`))
+
+		require.NoError(t, s.DeleteMailbox(context.Background(), "acme", " Jane@Acme.io "))
+		assert.Equal(t, []string{"mbox_jane"}, imap.removed)
+		assert.Equal(t, []string{"mbox_jane"}, mailboxes.deleted)
+		assert.Equal(t, []string{"tsm_jane"}, tenantMailboxes.deleted)
+	})
+
+	t.Run("mailbox without sync", func(t *testing.T) {
+		s, tenantMailboxes, mailboxes, imap := newMailboxService(t, respond(http.StatusOK, `{"success":true}`))
+
+		require.NoError(t, s.DeleteMailbox(context.Background(), "acme", "bob@acme.io"))
+		assert.Empty(t, imap.removed)
+		assert.Empty(t, mailboxes.deleted)
+		assert.Equal(t, []string{"tsm_bob"}, tenantMailboxes.deleted)
+	})
+
+	t.Run("rows are kept when OpenSRS fails", func(t *testing.T) {
+		s, tenantMailboxes, mailboxes, imap := newMailboxService(t, respond(http.StatusOK, `{"success":false,"error":"Permission denied","error_number":2}`))
+
+		err := s.DeleteMailbox(context.Background(), "acme", "jane@acme.io")
+		assert.ErrorIs(t, err, er.ErrOpenSRSPermissionDenied)
+		assert.Empty(t, imap.removed)
+		assert.Empty(t, mailboxes.deleted)
+		assert.Empty(t, tenantMailboxes.deleted)
+	})
+
+	t.Run("retry after a lost response finds the user gone", func(t *testing.T) {
+		s, tenantMailboxes, mailboxes, _ := newMailboxService(t,
+			respond(http.StatusBadGateway, "bad gateway"),
+			respond(http.StatusOK, `{"success":false,"error":"User not found","error_number":4}`),
+		)
+
+		require.NoError(t, s.DeleteMailbox(context.Background(), "acme", "jane@acme.io"))
+		assert.Equal(t, []string{"mbox_jane"}, mailboxes.deleted)
+		assert.Equal(t, []string{"tsm_jane"}, tenantMailboxes.deleted)
+	})
+
+	t.Run("mailbox of another tenant", func(t *testing.T) {
+		// OpenSRS is not asked, it would fail
+		s, tenantMailboxes, _, _ := newMailboxService(t, respond(http.StatusServiceUnavailable, ""))
+
+		err := s.DeleteMailbox(context.Background(), "other", "jane@acme.io")
+		assert.ErrorIs(t, err, er.ErrMailboxNotFound)
+		assert.Empty(t, tenantMailboxes.deleted)
+	})
+}
+
+func TestChangeMailboxPassword(t *testing.T) {
+	t.Run("synced mailbox", func(t *testing.T) {
+		s, tenantMailboxes, mailboxes, imap := newMailboxService(t, respond(http.StatusOK, `{"success":true}`))
+
+		require.NoError(t, s.ChangeMailboxPassword(context.Background(), "acme", "jane@acme.io", "n3w-secret"))
+		assert.Equal(t, map[string]string{"tsm_jane": "n3w-secret"}, tenantMailboxes.passwords)
+		require.Len(t, mailboxes.saved, 1)
+		assert.Equal(t, "n3w-secret", mailboxes.saved[0].ImapPassword)
+		assert.Equal(t, "n3w-secret", mailboxes.saved[0].SmtpPassword)
+		require.Len(t, imap.reloaded, 1)
+		assert.Equal(t, "mbox_jane", imap.reloaded[0].ID)
+		assert.Equal(t, "n3w-secret", imap.reloaded[0].ImapPassword)
+	})
+
+	t.Run("mailbox without sync", func(t *testing.T) {
+		s, tenantMailboxes, mailboxes, imap := newMailboxService(t, respond(http.StatusOK, `{"success":true}`))
+
+		require.NoError(t, s.ChangeMailboxPassword(context.Background(), "acme", "bob@acme.io", "n3w-secret"))
+		assert.Equal(t, map[string]string{"tsm_bob": "n3w-secret"}, tenantMailboxes.passwords)
+		assert.Empty(t, mailboxes.saved)
+		assert.Empty(t, imap.reloaded)
+	})
+
+	t.Run("credentials are kept when OpenSRS rejects the password", func(t *testing.T) {
+		s, tenantMailboxes, mailboxes, imap := newMailboxService(t, respond(http.StatusOK, `{"success":false,"error":"Invalid password","error_number":3}`))
+
+		err := s.ChangeMailboxPassword(context.Background(), "acme", "jane@acme.io", "short")
+		assert.ErrorIs(t, err, er.ErrOpenSRSInvalidRequest)
+		assert.Empty(t, tenantMailboxes.passwords)
+		assert.Empty(t, mailboxes.saved)
+		assert.Empty(t, imap.reloaded)
+	})
+
+	t.Run("empty password", func(t *testing.T) {
+		s, tenantMailboxes, _, _ := newMailboxService(t, respond(http.StatusOK, `{"success":true}`))
+
+		assert.Error(t, s.ChangeMailboxPassword(context.Background(), "acme", "jane@acme.io", ""))
+		assert.Empty(t, tenantMailboxes.passwords)
+	})
+}
@@ -0,0 +1,218 @@
+package opensrs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// HTMLToPlainText renders the text alternative of an HTML email. Block elements become lines and
+// paragraphs, list items get a marker, links keep their url as "text (url)" and preformatted text
+// keeps its spacing.
+func HTMLToPlainText(content string) (string, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return "", err
+	}
+
+	w := &plainTextWriter{}
+	for _, node := range doc.Find("body").Nodes {
+		w.children(node)
+	}
+
+	lines := strings.Split(w.b.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n")), nil
+}
+
+// paragraphElements are separated from their surroundings by a blank line, lineElements by a line break
+var (
+	paragraphElements = map[string]bool{
+		"p": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+		"ul": true, "ol": true, "table": true, "blockquote": true, "pre": true, "hr": true,
+	}
+	lineElements = map[string]bool{
+		"div": true, "li": true, "tr": true, "section": true, "article": true, "header": true,
+		"footer": true, "nav": true, "address": true, "dl": true, "dt": true, "dd": true,
+	}
+	skippedElements = map[string]bool{"head": true, "script": true, "style": true, "title": true}
+)
+
+type plainTextWriter struct {
+	b strings.Builder
+	// line breaks to write before the next text, at most two for a blank line
+	breaks int
+	// whether whitespace separates the next text from the last
+	space bool
+	pre   int
+	lists []*plainTextList
+	// a list marker was written, the item text follows on its line even if it is in a paragraph
+	marker bool
+}
+
+type plainTextList struct {
+	ordered bool
+	item    int
+}
+
+func (w *plainTextWriter) children(n *html.Node) {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		w.node(child)
+	}
+}
+
+func (w *plainTextWriter) node(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		w.text(n.Data)
+		return
+	case html.ElementNode:
+	default:
+		w.children(n)
+		return
+	}
+
+	tag := n.Data
+	switch {
+	case skippedElements[tag]:
+		return
+	case tag == "br":
+		w.lineBreak()
+		return
+	case tag == "a":
+		w.link(n)
+		return
+	case tag == "td" || tag == "th":
+		w.space = true
+		w.children(n)
+		w.space = true
+		return
+	}
+
+	breaks := 0
+	if paragraphElements[tag] {
+		breaks = 2
+	} else if lineElements[tag] {
+		breaks = 1
+	}
+	// nested lists continue their item
+	if (tag == "ul" || tag == "ol") && len(w.lists) > 0 {
+		breaks = 1
+	}
+	w.breakLines(breaks)
+
+	switch tag {
+	case "ul", "ol":
+		w.lists = append(w.lists, &plainTextList{ordered: tag == "ol"})
+		w.children(n)
+		w.lists = w.lists[:len(w.lists)-1]
+	case "li":
+		w.listMarker()
+		w.children(n)
+	case "pre":
+		w.pre++
+		w.children(n)
+		w.pre--
+	default:
+		w.children(n)
+	}
+
+	w.breakLines(breaks)
+}
+
+// text writes text, collapsing whitespace outside of preformatted elements
+func (w *plainTextWriter) text(text string) {
+	if w.pre > 0 {
+		w.write(text)
+		return
+	}
+	if text != "" && strings.TrimLeft(text, " \t\r\n\f") != text {
+		w.space = true
+	}
+	if words := strings.Fields(text); len(words) > 0 {
+		w.write(strings.Join(words, " "))
+		w.space = false
+	}
+	if text != "" && strings.TrimRight(text, " \t\r\n\f") != text {
+		w.space = true
+	}
+}
+
+// write writes text after the pending line breaks or separating space
+func (w *plainTextWriter) write(text string) {
+	if w.b.Len() > 0 {
+		if w.breaks > 0 {
+			w.b.WriteString(strings.Repeat("\n", max(0, w.breaks-w.trailingBreaks())))
+		} else if w.space && !strings.HasSuffix(w.b.String(), " ") && w.trailingBreaks() == 0 {
+			w.b.WriteString(" ")
+		}
+	}
+	w.breaks, w.space, w.marker = 0, false, false
+	w.b.WriteString(text)
+}
+
+func (w *plainTextWriter) breakLines(n int) {
+	if w.marker {
+		return
+	}
+	w.breaks = max(w.breaks, n)
+}
+
+// lineBreak writes a <br>, up to one blank line in a row
+func (w *plainTextWriter) lineBreak() {
+	if w.b.Len() > 0 && w.trailingBreaks() < 2 {
+		w.b.WriteString("\n")
+	}
+	w.space = false
+}
+
+func (w *plainTextWriter) trailingBreaks() int {
+	text := w.b.String()
+	return len(text) - len(strings.TrimRight(text, "\n"))
+}
+
+func (w *plainTextWriter) listMarker() {
+	indent := ""
+	marker := "- "
+	if depth := len(w.lists); depth > 0 {
+		indent = strings.Repeat("  ", depth-1)
+		list := w.lists[depth-1]
+		list.item++
+		if list.ordered {
+			marker = fmt.Sprintf("%d. ", list.item)
+		}
+	}
+	w.write(indent + marker)
+	w.marker = true
+}
+
+// link writes the text of a link followed by its url, or only the url when the text is the url
+func (w *plainTextWriter) link(n *html.Node) {
+	start := w.b.Len()
+	w.children(n)
+	label := strings.TrimSpace(w.b.String()[start:])
+
+	href := ""
+	for _, attr := range n.Attr {
+		if attr.Key == "href" {
+			href = strings.TrimSpace(attr.Val)
+		}
+	}
+	lower := strings.ToLower(href)
+	if href == "" || strings.HasPrefix(lower, "#") || strings.HasPrefix(lower, "javascript:") {
+		return
+	}
+
+	switch label {
+	case "":
+		w.write(href)
+	case href, strings.TrimPrefix(href, "mailto:"):
+	default:
+		w.space = true
+		w.write("(" + href + ")")
+	}
+}
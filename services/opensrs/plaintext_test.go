@@ -0,0 +1,76 @@
+package opensrs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTMLToPlainText(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		expected string
+	}{
+		{
+			name:     "paragraphs",
+			html:     "<p>Hi Jane,</p>\n<p>Thanks for the call.\n  Notes   below.</p><p>Bob</p>",
+			expected: "Hi Jane,\n\nThanks for the call. Notes below.\n\nBob",
+		},
+		{
+			name:     "line breaks",
+			html:     "<div>Best,<br>Bob<br/>Acme Inc.</div><div>Sales</div>",
+			expected: "Best,\nBob\nAcme Inc.\nSales",
+		},
+		{
+			name:     "repeated line breaks",
+			html:     "<p>one<br><br><br><br>two</p>",
+			expected: "one\n\ntwo",
+		},
+		{
+			name:     "links",
+			html:     `<p>See <a href="https://acme.io/pricing">our pricing</a>, <a href="https://acme.io">https://acme.io</a> or <a href="mailto:sales@acme.io">sales@acme.io</a>.</p><p><a href="#top">Top</a></p>`,
+			expected: "See our pricing (https://acme.io/pricing), https://acme.io or sales@acme.io.\n\nTop",
+		},
+		{
+			name:     "image link",
+			html:     `<a href="https://acme.io"><img src="logo.png"></a>`,
+			expected: "https://acme.io",
+		},
+		{
+			name: "lists",
+			html: `<p>Agenda:</p><ol><li>Pricing</li><li>Rollout<ul><li>EU</li><li>US</li></ul></li></ol>` +
+				`<ul><li><p>Questions</p></li></ul><p>Done</p>`,
+			expected: "Agenda:\n\n1. Pricing\n2. Rollout\n  - EU\n  - US\n\n- Questions\n\nDone",
+		},
+		{
+			name:     "headings and tables",
+			html:     "<h1>Invoice</h1><table><tr><th>Item</th><th>Price</th></tr><tr><td>Seats</td><td>$10</td></tr></table>",
+			expected: "Invoice\n\nItem Price\nSeats $10",
+		},
+		{
+			name:     "preformatted",
+			html:     "<p>Run:</p><pre>make build\n  make test</pre>",
+			expected: "Run:\n\nmake build\n  make test",
+		},
+		{
+			name:     "scripts and styles",
+			html:     "<html><head><title>Mail</title><style>p{color:red}</style></head><body><script>track()</script><p>Hello</p></body></html>",
+			expected: "Hello",
+		},
+		{
+			name:     "inline elements",
+			html:     "<p>This is <b>very</b> <i>important</i>!</p>",
+			expected: "This is very important!",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, err := HTMLToPlainText(tt.html)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, text)
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package opensrs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/internal/models"
+)
+
+func TestEmailFromMessage(t *testing.T) {
+	email, err := emailFromMessage(&models.EmailMessage{
+		FromName:           "Jane Doe",
+		From:               "jane@acme.io",
+		To:                 []string{"bob@corp.io"},
+		Bcc:                []string{"audit@acme.io"},
+		Subject:            "Re: Offer",
+		Content:            "<p>Grüße</p>",
+		ProviderInReplyTo:  "first@corp.io",
+		ProviderReferences: "<root@corp.io> first@corp.io",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "jane@acme.io", email.FromAddress)
+	assert.Equal(t, []string{"audit@acme.io"}, []string(email.BccAddresses))
+	assert.Equal(t, "Grüße", email.BodyText)
+	assert.Equal(t, "<p>Grüße</p>", email.BodyHTML)
+	assert.Equal(t, "<first@corp.io>", email.InReplyTo)
+	assert.Equal(t, []string{"<root@corp.io>", "<first@corp.io>"}, []string(email.References))
+}
@@ -0,0 +1,153 @@
+package opensrs
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	tracingLog "github.com/opentracing/opentracing-go/log"
+	"github.com/pkg/errors"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+const (
+	bytesPerMB           = 1 << 20
+	defaultUsageCacheTTL = 5 * time.Minute
+)
+
+// GetMailboxUsage returns the storage used by a mailbox and its quota. Results are cached
+// briefly since get_user is slow.
+func (s *openSRSService) GetMailboxUsage(ctx context.Context, email string) (interfaces.MailboxUsage, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "OpensrsService.GetMailboxUsage")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.LogKV("email", email)
+
+	email = normalizeAddress(email)
+
+	if usage, ok := s.usageCache.get(email); ok {
+		span.LogFields(tracingLog.Bool("cache.hit", true))
+		return usage, nil
+	}
+
+	var response struct {
+		Attributes map[string]interface{} `json:"attributes"`
+	}
+	err := s.callAPI(ctx, "get_user", map[string]interface{}{
+		"user": email,
+	}, &response)
+	if err != nil {
+		tracing.TraceErr(span, errors.Wrap(err, "failed to get mailbox from OpenSRS"))
+		s.log.Error("failed to get mailbox from OpenSRS", err)
+		return interfaces.MailboxUsage{}, err
+	}
+
+	usage := parseMailboxUsage(email, response.Attributes)
+	s.usageCache.set(email, usage)
+
+	span.LogFields(tracingLog.Int64("usage.used", usage.UsedBytes), tracingLog.Int64("usage.quota", usage.QuotaBytes))
+	return usage, nil
+}
+
+// parseMailboxUsage reads the quota and disk usage attributes, both reported in megabytes.
+// A missing, zero or negative quota means unlimited.
+func parseMailboxUsage(email string, attributes map[string]interface{}) interfaces.MailboxUsage {
+	usage := interfaces.MailboxUsage{Email: email}
+
+	for _, key := range []string{"disk_usage", "diskusage", "usage"} {
+		if used, ok := attributeNumber(attributes, key); ok {
+			usage.UsedBytes = int64(used * bytesPerMB)
+			break
+		}
+	}
+
+	quota, ok := attributeNumber(attributes, "quota")
+	if !ok || quota <= 0 {
+		usage.Unlimited = true
+		return usage
+	}
+
+	usage.QuotaBytes = int64(quota * bytesPerMB)
+	usage.UsedPercent = math.Round(float64(usage.UsedBytes)/float64(usage.QuotaBytes)*10000) / 100
+	return usage
+}
+
+// attributeNumber returns a numeric attribute, OpenSRS returns numbers either as JSON numbers or strings
+func attributeNumber(attributes map[string]interface{}, key string) (float64, bool) {
+	switch value := attributes[key].(type) {
+	case float64:
+		return value, true
+	case json.Number:
+		number, err := value.Float64()
+		return number, err == nil
+	case string:
+		number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return number, err == nil
+	default:
+		return 0, false
+	}
+}
+
+type usageCacheEntry struct {
+	usage     interfaces.MailboxUsage
+	fetchedAt time.Time
+}
+
+type usageCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]usageCacheEntry
+}
+
+func newUsageCache(ttl time.Duration) *usageCache {
+	if ttl <= 0 {
+		ttl = defaultUsageCacheTTL
+	}
+	return &usageCache{
+		ttl:     ttl,
+		entries: make(map[string]usageCacheEntry),
+	}
+}
+
+func (c *usageCache) get(email string) (interfaces.MailboxUsage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[email]
+	if !ok {
+		return interfaces.MailboxUsage{}, false
+	}
+	if utils.Now().Sub(entry.fetchedAt) > c.ttl {
+		delete(c.entries, email)
+		return interfaces.MailboxUsage{}, false
+	}
+	return entry.usage, true
+}
+
+func (c *usageCache) set(email string, usage interfaces.MailboxUsage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// expired entries of other mailboxes are dropped here, get only removes the one it reads
+	now := utils.Now()
+	for key, entry := range c.entries {
+		if now.Sub(entry.fetchedAt) > c.ttl {
+			delete(c.entries, key)
+		}
+	}
+	c.entries[email] = usageCacheEntry{usage: usage, fetchedAt: now}
+}
+
+func (c *usageCache) invalidate(email string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, email)
+}
@@ -0,0 +1,33 @@
+package opensrs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMailboxUsage(t *testing.T) {
+	usage := parseMailboxUsage("a@example.com", map[string]interface{}{
+		"quota":      float64(1024),
+		"disk_usage": "256",
+	})
+	assert.Equal(t, int64(256*bytesPerMB), usage.UsedBytes)
+	assert.Equal(t, int64(1024*bytesPerMB), usage.QuotaBytes)
+	assert.False(t, usage.Unlimited)
+	assert.Equal(t, 25.0, usage.UsedPercent)
+}
+
+func TestParseMailboxUsage_Unlimited(t *testing.T) {
+	for _, attributes := range []map[string]interface{}{
+		{"disk_usage": float64(10)},
+		{"disk_usage": float64(10), "quota": float64(0)},
+		{"disk_usage": float64(10), "quota": "-1"},
+		{"disk_usage": float64(10), "quota": "unlimited"},
+	} {
+		usage := parseMailboxUsage("a@example.com", attributes)
+		assert.True(t, usage.Unlimited)
+		assert.Equal(t, int64(0), usage.QuotaBytes)
+		assert.Equal(t, int64(10*bytesPerMB), usage.UsedBytes)
+		assert.Zero(t, usage.UsedPercent)
+	}
+}
@@ -0,0 +1,113 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/config"
+	"github.com/customeros/mailstack/internal/tracing"
+)
+
+const clamavChunkSize = 64 * 1024
+
+// clamavScanner streams content to clamd using the INSTREAM command
+type clamavScanner struct {
+	address string
+	timeout time.Duration
+}
+
+func NewAttachmentScanner(cfg *config.AttachmentScannerConfig) interfaces.AttachmentScanner {
+	if cfg == nil || cfg.ClamAVAddress == "" {
+		return NewNoopScanner()
+	}
+	return &clamavScanner{
+		address: cfg.ClamAVAddress,
+		timeout: time.Duration(cfg.TimeoutSeconds) * time.Second,
+	}
+}
+
+func (s *clamavScanner) Scan(ctx context.Context, data []byte) (*interfaces.ScanResult, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "clamavScanner.Scan")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.LogKV("size", len(data))
+
+	dialer := net.Dialer{Timeout: s.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.address)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, fmt.Errorf("failed to connect to clamd: %w", err)
+	}
+	defer conn.Close()
+
+	if s.timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(s.timeout))
+	}
+
+	if _, err = conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	// each chunk is prefixed with its length, a zero length chunk ends the stream
+	reader := bytes.NewReader(data)
+	chunk := make([]byte, clamavChunkSize)
+	size := make([]byte, 4)
+	for {
+		n, readErr := reader.Read(chunk)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err = conn.Write(size); err != nil {
+				tracing.TraceErr(span, err)
+				return nil, err
+			}
+			if _, err = conn.Write(chunk[:n]); err != nil {
+				tracing.TraceErr(span, err)
+				return nil, err
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+	}
+	if _, err = conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	response, err := io.ReadAll(conn)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	return parseClamavResponse(string(response))
+}
+
+// parseClamavResponse handles "stream: OK", "stream: <signature> FOUND" and "... ERROR"
+func parseClamavResponse(response string) (*interfaces.ScanResult, error) {
+	response = strings.TrimSpace(strings.TrimRight(response, "\x00"))
+	response = strings.TrimPrefix(response, "stream:")
+	response = strings.TrimSpace(response)
+
+	switch {
+	case response == "OK":
+		return &interfaces.ScanResult{}, nil
+	case strings.HasSuffix(response, "FOUND"):
+		return &interfaces.ScanResult{
+			Infected:  true,
+			Signature: strings.TrimSpace(strings.TrimSuffix(response, "FOUND")),
+		}, nil
+	default:
+		return nil, fmt.Errorf("clamd scan failed: %s", response)
+	}
+}
@@ -0,0 +1,126 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseClamavResponse(t *testing.T) {
+	result, err := parseClamavResponse("stream: OK\x00")
+	require.NoError(t, err)
+	assert.False(t, result.Infected)
+	assert.Empty(t, result.Signature)
+
+	result, err = parseClamavResponse("stream: Win.Test.EICAR_HDB-1 FOUND\x00")
+	require.NoError(t, err)
+	assert.True(t, result.Infected)
+	assert.Equal(t, "Win.Test.EICAR_HDB-1", result.Signature)
+
+	_, err = parseClamavResponse("INSTREAM size limit exceeded. ERROR\x00")
+	assert.ErrorContains(t, err, "size limit exceeded")
+
+	_, err = parseClamavResponse("")
+	assert.Error(t, err)
+}
+
+// fakeClamd accepts one INSTREAM scan, recording the chunk sizes and the streamed content
+type fakeClamd struct {
+	address  string
+	command  chan string
+	chunks   chan []int
+	content  chan []byte
+	response string
+}
+
+func newFakeClamd(t *testing.T, response string) *fakeClamd {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	clamd := &fakeClamd{
+		address:  listener.Addr().String(),
+		command:  make(chan string, 1),
+		chunks:   make(chan []int, 1),
+		content:  make(chan []byte, 1),
+		response: response,
+	}
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		command := make([]byte, len("zINSTREAM\x00"))
+		if _, err = io.ReadFull(conn, command); err != nil {
+			return
+		}
+		clamd.command <- string(command)
+
+		var sizes []int
+		var content bytes.Buffer
+		size := make([]byte, 4)
+		for {
+			if _, err = io.ReadFull(conn, size); err != nil {
+				return
+			}
+			n := binary.BigEndian.Uint32(size)
+			if n == 0 {
+				break
+			}
+			sizes = append(sizes, int(n))
+			if _, err = io.CopyN(&content, conn, int64(n)); err != nil {
+				return
+			}
+		}
+		clamd.chunks <- sizes
+		clamd.content <- content.Bytes()
+
+		_, _ = conn.Write([]byte(clamd.response))
+	}()
+	return clamd
+}
+
+func TestClamavScanStreamsChunks(t *testing.T) {
+	clamd := newFakeClamd(t, "stream: OK\x00")
+	data := bytes.Repeat([]byte("attachment"), (2*clamavChunkSize+100)/10)
+
+	scanner := &clamavScanner{address: clamd.address, timeout: 5 * time.Second}
+	result, err := scanner.Scan(context.Background(), data)
+	require.NoError(t, err)
+	assert.False(t, result.Infected)
+
+	assert.Equal(t, "zINSTREAM\x00", <-clamd.command)
+	assert.Equal(t, []int{clamavChunkSize, clamavChunkSize, len(data) - 2*clamavChunkSize}, <-clamd.chunks)
+	assert.Equal(t, data, <-clamd.content)
+}
+
+func TestClamavScanInfected(t *testing.T) {
+	clamd := newFakeClamd(t, "stream: Eicar-Signature FOUND\x00")
+
+	scanner := &clamavScanner{address: clamd.address, timeout: 5 * time.Second}
+	data := []byte("X5O!P%@AP[4\\PZX54(P^)7CC)7}$EICAR")
+	result, err := scanner.Scan(context.Background(), data)
+	require.NoError(t, err)
+	assert.True(t, result.Infected)
+	assert.Equal(t, "Eicar-Signature", result.Signature)
+	assert.Equal(t, []int{len(data)}, <-clamd.chunks)
+}
+
+func TestClamavScanUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	_, err = (&clamavScanner{address: address, timeout: time.Second}).Scan(context.Background(), []byte("data"))
+	assert.ErrorContains(t, err, "failed to connect to clamd")
+}
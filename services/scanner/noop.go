@@ -0,0 +1,18 @@
+package scanner
+
+import (
+	"context"
+
+	"github.com/customeros/mailstack/interfaces"
+)
+
+// noopScanner skips scanning, used when no scanner is configured
+type noopScanner struct{}
+
+func NewNoopScanner() interfaces.AttachmentScanner {
+	return &noopScanner{}
+}
+
+func (s *noopScanner) Scan(ctx context.Context, data []byte) (*interfaces.ScanResult, error) {
+	return &interfaces.ScanResult{Skipped: true}, nil
+}
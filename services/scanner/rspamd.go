@@ -0,0 +1,93 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/config"
+	"github.com/customeros/mailstack/internal/tracing"
+)
+
+// rspamdScorer posts the raw message to the rspamd checkv2 endpoint
+type rspamdScorer struct {
+	url       string
+	password  string
+	threshold float64
+	client    *http.Client
+}
+
+func newRspamdScorer(cfg *config.SpamScorerConfig, timeout time.Duration) *rspamdScorer {
+	return &rspamdScorer{
+		url:       strings.TrimRight(cfg.Address, "/") + "/checkv2",
+		password:  cfg.Password,
+		threshold: cfg.Threshold,
+		client:    &http.Client{Timeout: timeout},
+	}
+}
+
+func (s *rspamdScorer) Score(ctx context.Context, rawMessage []byte) (*interfaces.SpamScore, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "rspamdScorer.Score")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.LogKV("size", len(rawMessage))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(rawMessage))
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+	if s.password != "" {
+		req.Header.Set("Password", s.password)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, fmt.Errorf("failed to call rspamd: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("rspamd returned status %d", resp.StatusCode)
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	result, err := parseRspamdResponse(body, s.threshold)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+	span.LogKV("score", result.Score)
+	return result, nil
+}
+
+// parseRspamdResponse reads the score of a checkv2 response, skipped messages count as clean
+func parseRspamdResponse(body []byte, threshold float64) (*interfaces.SpamScore, error) {
+	var response struct {
+		IsSkipped bool    `json:"is_skipped"`
+		Score     float64 `json:"score"`
+		Action    string  `json:"action"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse rspamd response: %w", err)
+	}
+	if response.IsSkipped {
+		return &interfaces.SpamScore{Skipped: true, Threshold: threshold}, nil
+	}
+	return newSpamScore(response.Score, threshold), nil
+}
@@ -0,0 +1,54 @@
+package scanner
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/config"
+)
+
+const (
+	SpamScorerRspamd       = "rspamd"
+	SpamScorerSpamAssassin = "spamassassin"
+)
+
+func NewSpamScorer(cfg *config.SpamScorerConfig) interfaces.SpamScorer {
+	if cfg == nil || cfg.Address == "" {
+		return NewNoopSpamScorer()
+	}
+
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+	switch strings.ToLower(cfg.Provider) {
+	case SpamScorerRspamd:
+		return newRspamdScorer(cfg, timeout)
+	case SpamScorerSpamAssassin:
+		return &spamAssassinScorer{
+			address:   cfg.Address,
+			threshold: cfg.Threshold,
+			timeout:   timeout,
+		}
+	default:
+		return NewNoopSpamScorer()
+	}
+}
+
+// noopSpamScorer skips scoring, used when no scorer is configured
+type noopSpamScorer struct{}
+
+func NewNoopSpamScorer() interfaces.SpamScorer {
+	return &noopSpamScorer{}
+}
+
+func (s *noopSpamScorer) Score(ctx context.Context, rawMessage []byte) (*interfaces.SpamScore, error) {
+	return &interfaces.SpamScore{Skipped: true}, nil
+}
+
+func newSpamScore(score, threshold float64) *interfaces.SpamScore {
+	return &interfaces.SpamScore{
+		Score:     score,
+		Threshold: threshold,
+		Spam:      score > threshold,
+	}
+}
@@ -0,0 +1,44 @@
+package scanner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRspamdResponse(t *testing.T) {
+	result, err := parseRspamdResponse([]byte(`{"is_skipped":false,"score":12.4,"required_score":15,"action":"add header"}`), 6)
+	require.NoError(t, err)
+	assert.True(t, result.Spam)
+	assert.Equal(t, 12.4, result.Score)
+
+	result, err = parseRspamdResponse([]byte(`{"is_skipped":false,"score":-1.2,"action":"no action"}`), 6)
+	require.NoError(t, err)
+	assert.False(t, result.Spam)
+
+	result, err = parseRspamdResponse([]byte(`{"is_skipped":true}`), 6)
+	require.NoError(t, err)
+	assert.True(t, result.Skipped)
+
+	_, err = parseRspamdResponse([]byte(`not json`), 6)
+	assert.Error(t, err)
+}
+
+func TestParseSpamdResponse(t *testing.T) {
+	result, err := parseSpamdResponse("SPAMD/1.1 0 EX_OK\r\nSpam: True ; 15.3 / 5.0\r\n\r\n", 6)
+	require.NoError(t, err)
+	assert.True(t, result.Spam)
+	assert.Equal(t, 15.3, result.Score)
+
+	// the configured threshold wins over the spamd required score
+	result, err = parseSpamdResponse("SPAMD/1.1 0 EX_OK\r\nSpam: True ; 5.5 / 5.0\r\n\r\n", 6)
+	require.NoError(t, err)
+	assert.False(t, result.Spam)
+
+	_, err = parseSpamdResponse("SPAMD/1.0 76 Bad header line: CHECK\r\n", 6)
+	assert.Error(t, err)
+
+	_, err = parseSpamdResponse("SPAMD/1.1 0 EX_OK\r\n\r\n", 6)
+	assert.Error(t, err)
+}
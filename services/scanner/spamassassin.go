@@ -0,0 +1,98 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/tracing"
+)
+
+// spamAssassinScorer sends the raw message to spamd using the CHECK command of the SPAMC protocol
+type spamAssassinScorer struct {
+	address   string
+	threshold float64
+	timeout   time.Duration
+}
+
+func (s *spamAssassinScorer) Score(ctx context.Context, rawMessage []byte) (*interfaces.SpamScore, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "spamAssassinScorer.Score")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.LogKV("size", len(rawMessage))
+
+	dialer := net.Dialer{Timeout: s.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.address)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, fmt.Errorf("failed to connect to spamd: %w", err)
+	}
+	defer conn.Close()
+
+	if s.timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(s.timeout))
+	}
+
+	request := fmt.Sprintf("CHECK SPAMC/1.5\r\nContent-length: %d\r\n\r\n", len(rawMessage))
+	if _, err = conn.Write(append([]byte(request), rawMessage...)); err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		_ = tcpConn.CloseWrite()
+	}
+
+	response, err := io.ReadAll(conn)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	result, err := parseSpamdResponse(string(response), s.threshold)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+	span.LogKV("score", result.Score)
+	return result, nil
+}
+
+// parseSpamdResponse reads the score from the "Spam: True ; 15.3 / 5.0" header of a spamd reply.
+// The configured threshold is used rather than the spamd required score.
+func parseSpamdResponse(response string, threshold float64) (*interfaces.SpamScore, error) {
+	scanner := bufio.NewScanner(strings.NewReader(response))
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("empty spamd response")
+	}
+	status := strings.Fields(scanner.Text())
+	if len(status) < 3 || !strings.HasPrefix(status[0], "SPAMD/") || status[1] != "0" {
+		return nil, fmt.Errorf("spamd check failed: %s", scanner.Text())
+	}
+
+	for scanner.Scan() {
+		name, value, found := strings.Cut(scanner.Text(), ":")
+		if !found || !strings.EqualFold(strings.TrimSpace(name), "Spam") {
+			continue
+		}
+		_, scores, found := strings.Cut(value, ";")
+		if !found {
+			break
+		}
+		scoreValue, _, _ := strings.Cut(scores, "/")
+		score, err := strconv.ParseFloat(strings.TrimSpace(scoreValue), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid spamd score: %s", value)
+		}
+		return newSpamScore(score, threshold), nil
+	}
+
+	return nil, fmt.Errorf("spamd response has no Spam header")
+}
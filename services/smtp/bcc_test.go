@@ -0,0 +1,90 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/smtp"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/jhillyerd/enmime"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/internal/models"
+)
+
+// recordingConn keeps a copy of everything the client sent to the server
+type recordingConn struct {
+	net.Conn
+	sent *bytes.Buffer
+}
+
+func (c recordingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.sent.Write(p[:n])
+	return n, err
+}
+
+var rcptTo = regexp.MustCompile(`RCPT TO:<([^>]+)>`)
+
+// Bcc recipients must receive the message without anyone, them included, learning who they are
+func TestBccRecipientsOnlyInEnvelope(t *testing.T) {
+	bcc := []string{"dave@partner.io", "erin@partner.io"}
+	email := newRichEmail()
+	email.CcAddresses = pq.StringArray{"carol@corp.io"}
+	email.BccAddresses = pq.StringArray(bcc)
+	// headers carried over from elsewhere, e.g. a copied draft, can not bring the list back
+	email.RawHeaders = models.JSONMap{"Bcc": strings.Join(bcc, ", "), "resent-bcc": bcc[0], "X-Campaign": "q4"}
+
+	client := &SMTPClient{}
+	buffer, err := client.renderMessage(context.Background(), email, nil)
+	require.NoError(t, err)
+	recipients, err := client.checkRecipients(context.Background(), email, email.AllRecipients())
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"bob@corp.io", "carol@corp.io", "dave@partner.io", "erin@partner.io"}, recipients)
+
+	clientConn, serverConn := net.Pipe()
+	var transcript, received bytes.Buffer
+	go fakeServer(t, recordingConn{Conn: serverConn, sent: &transcript}, "", &received)
+	smtpClient, err := smtp.NewClient(clientConn, "fake")
+	require.NoError(t, err)
+	_, err = client.transmit(smtpClient, email.FromAddress, recipients, bytes.NewBuffer(buffer.Bytes()))
+	require.NoError(t, err)
+	require.NoError(t, smtpClient.Quit())
+
+	// every recipient, bcc included, is in the envelope
+	var envelope []string
+	for _, match := range rcptTo.FindAllStringSubmatch(transcript.String(), -1) {
+		envelope = append(envelope, match[1])
+	}
+	assert.ElementsMatch(t, recipients, envelope)
+
+	// the message every recipient gets, and the copy kept in the sent folder, does not name them
+	message, err := enmime.ReadEnvelope(bytes.NewReader(received.Bytes()))
+	require.NoError(t, err)
+	assert.Empty(t, message.GetHeader("Bcc"))
+	assert.Empty(t, message.GetHeader("Resent-Bcc"))
+	assert.Equal(t, "bob@corp.io", message.GetHeader("To"))
+	assert.Equal(t, "carol@corp.io", message.GetHeader("Cc"))
+	assert.Equal(t, "q4", message.GetHeader("X-Campaign"))
+	for _, address := range bcc {
+		assert.NotContains(t, received.String(), address)
+		assert.NotContains(t, buffer.String(), address)
+	}
+
+	// neither do the headers stored with the email
+	for name, value := range email.RawHeaders {
+		assert.False(t, isBcc(name), name)
+		for _, address := range bcc {
+			assert.NotContains(t, value, address, name)
+		}
+	}
+}
+
+func isBcc(name string) bool {
+	return strings.EqualFold(name, "Bcc") || strings.EqualFold(name, "Resent-Bcc")
+}
@@ -0,0 +1,49 @@
+package smtp
+
+import (
+	"context"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+)
+
+// Deliver builds the message like Send and transmits it over the server of the mailbox, without
+// storing anything or appending to the sent folder. It is for send channels keeping their own
+// records, e.g. OpenSRS, and returns the reply of the server. The Message-ID, the headers and the
+// skipped recipients are set on email.
+func (s *SMTPClient) Deliver(ctx context.Context, email *models.Email, attachments []*models.EmailAttachment) (string, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "SMTPClient.Deliver")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+
+	if err := s.validateEmail(ctx, email); err != nil {
+		tracing.TraceErr(span, err)
+		return "", err
+	}
+
+	buffer, err := s.renderMessage(ctx, email, attachments)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return "", err
+	}
+
+	recipients, err := s.checkRecipients(ctx, email, email.AllRecipients())
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return "", err
+	}
+
+	email.MessageSizeBytes = buffer.Len()
+	email.RecipientCount = len(recipients)
+	start := time.Now()
+	response, err := s.sendToServer(ctx, email.FromAddress, recipients, buffer)
+	recordSendMetrics(time.Since(start), err)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return response, err
+	}
+	return response, nil
+}
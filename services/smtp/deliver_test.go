@@ -0,0 +1,66 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/jhillyerd/enmime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/repository"
+)
+
+func TestDeliver(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	var received bytes.Buffer
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		fakeServer(t, conn, "", &received)
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	mailbox := &models.Mailbox{
+		MailboxDomain:   "acme.io",
+		MessageIDDomain: "bounce.acme-mail.net",
+		SmtpServer:      addr.IP.String(),
+		SmtpPort:        addr.Port,
+	}
+	repos := &repository.Repositories{
+		EmailAttachmentRepository: &fakeAttachmentRepository{content: map[string][]byte{"att_pdf": []byte("%PDF")}},
+	}
+	email := newRichEmail()
+	email.MessageID = ""
+	email.BodyText = "Grüße, Jane"
+	email.BodyHTML = ""
+	email.HasAttachment = true
+
+	response, err := NewSMTPClient(repos, mailbox, nil, nil).Deliver(context.Background(), email, []*models.EmailAttachment{
+		{ID: "att_pdf", Filename: "offer.pdf", ContentType: "application/pdf"},
+	})
+	require.NoError(t, err)
+	<-served
+	assert.Equal(t, "250 2.0.0 Ok: queued as ABC123", response)
+	assert.True(t, strings.HasSuffix(email.MessageID, "@bounce.acme-mail.net>"))
+	assert.Equal(t, 1, email.RecipientCount)
+
+	envelope, err := enmime.ReadEnvelope(bytes.NewReader(received.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, email.MessageID, envelope.GetHeader("Message-ID"))
+	assert.True(t, strings.HasPrefix(envelope.GetHeader("Content-Type"), "multipart/mixed"))
+	assert.Equal(t, "Grüße, Jane", envelope.Text)
+	require.Len(t, envelope.Attachments, 1)
+	assert.Equal(t, []byte("%PDF"), envelope.Attachments[0].Content)
+}
@@ -0,0 +1,126 @@
+package smtp
+
+import (
+	"context"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+	"regexp"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+	"github.com/customeros/mailstack/internal/utils"
+)
+
+// cidReferencePattern matches cid: references in HTML, in image sources as well as in styles
+var cidReferencePattern = regexp.MustCompile(`(?i)cid:([^"'\s>)]+)`)
+
+// splitInlineAttachments separates the attachments shown inside the HTML body from the ones
+// attached to the message. An attachment is inline when it is marked so or when the HTML
+// references its Content-ID. Without an HTML body every attachment is a regular one.
+func splitInlineAttachments(html string, attachments []*models.EmailAttachment) (inline, regular []*models.EmailAttachment) {
+	if html == "" {
+		return nil, attachments
+	}
+
+	referenced := make(map[string]bool)
+	for _, match := range cidReferencePattern.FindAllStringSubmatch(html, -1) {
+		referenced[normalizeContentID(match[1])] = true
+	}
+
+	for _, attachment := range attachments {
+		if attachment == nil {
+			continue
+		}
+		if attachment.IsInline || (attachment.ContentID != "" && referenced[normalizeContentID(attachment.ContentID)]) {
+			inline = append(inline, attachment)
+		} else {
+			regular = append(regular, attachment)
+		}
+	}
+	return inline, regular
+}
+
+// assignContentIDs gives inline attachments without a Content-ID one on the sender domain, so
+// every part of a multipart/related can be addressed
+func assignContentIDs(attachments []*models.EmailAttachment, fromAddress string) {
+	domain := utils.ExtractDomainFromEmail(fromAddress)
+	if domain == "" {
+		domain = "mailstack.local"
+	}
+	for _, attachment := range attachments {
+		if normalizeContentID(attachment.ContentID) == "" {
+			attachment.ContentID = fmt.Sprintf("%s@%s", utils.GenerateNanoID(16), domain)
+		}
+	}
+}
+
+func normalizeContentID(contentID string) string {
+	return strings.ToLower(strings.Trim(strings.TrimSpace(contentID), "<>"))
+}
+
+// addHtmlBody adds the HTML part, wrapped in a multipart/related together with its inline
+// attachments when there are any, and returns the body structure metadata of what it wrote
+func (s *SMTPClient) addHtmlBody(ctx context.Context, createPart createPartFunc, html string, inline []*models.EmailAttachment) (models.JSONMap, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "SMTPClient.addHtmlBody")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.LogKV("inlineAttachments", len(inline))
+
+	if len(inline) == 0 {
+		if err := s.addHtmlPart(ctx, createPart, html); err != nil {
+			return nil, err
+		}
+		return s.createPartMetadata("text/html", len(html), ""), nil
+	}
+
+	related, boundary, err := createNestedMultipart(createPart, "related")
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	if err = s.addHtmlPart(ctx, related.CreatePart, html); err != nil {
+		return nil, err
+	}
+	parts := []models.JSONMap{s.createPartMetadata("text/html", len(html), "")}
+
+	for _, attachment := range inline {
+		if err = s.addInlineAttachment(ctx, related, attachment); err != nil {
+			return nil, err
+		}
+		parts = append(parts, s.createInlineAttachmentMetadata(attachment))
+	}
+
+	if err = related.Close(); err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	return models.JSONMap{
+		"type":     "multipart/related",
+		"boundary": boundary,
+		"parts":    parts,
+	}, nil
+}
+
+// addInlineAttachment adds an attachment shown inside the HTML body, addressed by its Content-ID
+func (s *SMTPClient) addInlineAttachment(ctx context.Context, writer *multipart.Writer, attachment *models.EmailAttachment) error {
+	return s.writeAttachmentPart(ctx, writer, attachment, textproto.MIMEHeader{
+		"Content-Type":              {fmt.Sprintf("%s; name=%q", attachment.ContentType, attachment.Filename)},
+		"Content-Disposition":       {fmt.Sprintf("inline; filename=%q", attachment.Filename)},
+		"Content-Id":                {"<" + strings.Trim(strings.TrimSpace(attachment.ContentID), "<>") + ">"},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+}
+
+// createInlineAttachmentMetadata creates metadata for an inline attachment part
+func (s *SMTPClient) createInlineAttachmentMetadata(attachment *models.EmailAttachment) models.JSONMap {
+	metadata := s.createAttachmentMetadata(attachment)
+	metadata["disposition"] = "inline"
+	metadata["contentId"] = strings.Trim(strings.TrimSpace(attachment.ContentID), "<>")
+	return metadata
+}
@@ -0,0 +1,121 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/repository"
+)
+
+type fakeAttachmentRepository struct {
+	interfaces.EmailAttachmentRepository
+	content map[string][]byte
+}
+
+func (r *fakeAttachmentRepository) DownloadAttachment(_ context.Context, id string) ([]byte, error) {
+	return r.content[id], nil
+}
+
+// readParts returns the content type, headers and decoded body of every part of a multipart body
+func readParts(t *testing.T, body io.Reader, contentType string) (string, []*multipart.Part, [][]byte) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	require.NoError(t, err)
+
+	var parts []*multipart.Part
+	var bodies [][]byte
+	reader := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		data, err := io.ReadAll(part)
+		require.NoError(t, err)
+		parts = append(parts, part)
+		bodies = append(bodies, data)
+	}
+	return mediaType, parts, bodies
+}
+
+func TestRenderMessageWithInlineImage(t *testing.T) {
+	logo := []byte("\x89PNG fake image bytes")
+	client := &SMTPClient{repositories: &repository.Repositories{
+		EmailAttachmentRepository: &fakeAttachmentRepository{content: map[string][]byte{
+			"att_logo": logo,
+			"att_pdf":  []byte("%PDF"),
+		}},
+	}}
+
+	email := &models.Email{
+		MessageID:     "abc@acme.io",
+		FromAddress:   "jane@acme.io",
+		ToAddresses:   pq.StringArray{"bob@corp.io"},
+		Subject:       "Hello",
+		BodyText:      "Hi Bob",
+		BodyHTML:      `<p>Hi Bob</p><img src="cid:logo@acme.io">`,
+		HasAttachment: true,
+	}
+	attachments := []*models.EmailAttachment{
+		{ID: "att_logo", Filename: "logo.png", ContentType: "image/png", ContentID: "<logo@acme.io>"},
+		{ID: "att_pdf", Filename: "offer.pdf", ContentType: "application/pdf"},
+	}
+
+	buffer, err := client.renderMessage(context.Background(), email, attachments)
+	require.NoError(t, err)
+
+	message, err := mail.ReadMessage(bytes.NewReader(buffer.Bytes()))
+	require.NoError(t, err)
+
+	mediaType, mixed, mixedBodies := readParts(t, message.Body, message.Header.Get("Content-Type"))
+	assert.Equal(t, "multipart/mixed", mediaType)
+	require.Len(t, mixed, 2)
+	assert.Equal(t, `attachment; filename="offer.pdf"`, mixed[1].Header.Get("Content-Disposition"))
+
+	mediaType, alternative, alternativeBodies := readParts(t, bytes.NewReader(mixedBodies[0]), mixed[0].Header.Get("Content-Type"))
+	assert.Equal(t, "multipart/alternative", mediaType)
+	require.Len(t, alternative, 2)
+	assert.Equal(t, "Hi Bob", string(alternativeBodies[0]))
+
+	mediaType, related, relatedBodies := readParts(t, bytes.NewReader(alternativeBodies[1]), alternative[1].Header.Get("Content-Type"))
+	assert.Equal(t, "multipart/related", mediaType)
+	require.Len(t, related, 2)
+	assert.Contains(t, string(relatedBodies[0]), "cid:logo@acme.io")
+	assert.Equal(t, "<logo@acme.io>", related[1].Header.Get("Content-Id"))
+	assert.True(t, strings.HasPrefix(related[1].Header.Get("Content-Disposition"), "inline"))
+
+	image, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(relatedBodies[1]), "\r\n", ""))
+	require.NoError(t, err)
+	assert.Equal(t, logo, image)
+}
+
+func TestSplitInlineAttachments(t *testing.T) {
+	referenced := &models.EmailAttachment{ID: "a", ContentID: "Logo@Acme.io"}
+	marked := &models.EmailAttachment{ID: "b", IsInline: true}
+	regular := &models.EmailAttachment{ID: "c", ContentID: "unused@acme.io"}
+	attachments := []*models.EmailAttachment{referenced, marked, regular}
+
+	inline, rest := splitInlineAttachments(`<img src='cid:logo@acme.io'>`, attachments)
+	assert.Equal(t, []*models.EmailAttachment{referenced, marked}, inline)
+	assert.Equal(t, []*models.EmailAttachment{regular}, rest)
+
+	inline, rest = splitInlineAttachments("", attachments)
+	assert.Empty(t, inline)
+	assert.Equal(t, attachments, rest)
+
+	assignContentIDs([]*models.EmailAttachment{marked}, "jane@acme.io")
+	assert.True(t, strings.HasSuffix(marked.ContentID, "@acme.io"))
+}
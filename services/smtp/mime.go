@@ -0,0 +1,54 @@
+package smtp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+)
+
+// createPartFunc creates an entity with the given header and returns the writer of its body,
+// either a part of a multipart or the top level entity of the message
+type createPartFunc func(header textproto.MIMEHeader) (io.Writer, error)
+
+// messagePart makes the top level entity of the message: its header is written together with the
+// message headers and its body is the message body. It can be created only once.
+func messagePart(headers map[string]string, buffer *bytes.Buffer) createPartFunc {
+	return func(header textproto.MIMEHeader) (io.Writer, error) {
+		for k := range header {
+			headers[k] = header.Get(k)
+		}
+		writeHeaders(headers, buffer)
+		return buffer, nil
+	}
+}
+
+// createNestedMultipart creates a multipart entity of the given subtype and returns the writer for
+// its children together with its boundary. The writer has to be closed once the children are added.
+func createNestedMultipart(createPart createPartFunc, subtype string) (*multipart.Writer, string, error) {
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+
+	part, err := createPart(textproto.MIMEHeader{
+		"Content-Type": {fmt.Sprintf("multipart/%s; boundary=%s", subtype, boundary)},
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create multipart/%s part: %w", subtype, err)
+	}
+
+	nested := multipart.NewWriter(part)
+	if err = nested.SetBoundary(boundary); err != nil {
+		return nil, "", err
+	}
+	return nested, boundary, nil
+}
+
+// writeQuotedPrintable writes content quoted-printable encoded, as the text parts are declared
+func writeQuotedPrintable(w io.Writer, content string) error {
+	encoder := quotedprintable.NewWriter(w)
+	if _, err := encoder.Write([]byte(content)); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
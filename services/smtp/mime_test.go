@@ -0,0 +1,156 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jhillyerd/enmime"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/internal/config"
+	mailstack_errors "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/repository"
+)
+
+func newRichEmail() *models.Email {
+	return &models.Email{
+		MessageID:   "abc@acme.io",
+		FromAddress: "jane@acme.io",
+		ToAddresses: pq.StringArray{"bob@corp.io"},
+		Subject:     "Hello",
+		BodyText:    "Hi Bob, 50% off = great deal",
+		BodyHTML:    `<p style="color:red">Hi Bob, 50% off = great deal</p>`,
+	}
+}
+
+func TestRenderMessageAlternativeBodies(t *testing.T) {
+	t.Run("without attachments", func(t *testing.T) {
+		email := newRichEmail()
+
+		buffer, err := (&SMTPClient{}).renderMessage(context.Background(), email, nil)
+		require.NoError(t, err)
+
+		envelope, err := enmime.ReadEnvelope(bytes.NewReader(buffer.Bytes()))
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(envelope.GetHeader("Content-Type"), "multipart/alternative"))
+		assert.Equal(t, email.BodyText, envelope.Text)
+		assert.Equal(t, email.BodyHTML, envelope.HTML)
+		assert.Empty(t, envelope.Attachments)
+
+		assert.Equal(t, "multipart/alternative", email.BodyStructure["type"])
+		parts := email.BodyStructure["parts"].([]models.JSONMap)
+		require.Len(t, parts, 2)
+		assert.Equal(t, "text/plain", parts[0]["type"])
+		assert.Equal(t, "text/html", parts[1]["type"])
+	})
+
+	t.Run("with attachment", func(t *testing.T) {
+		email := newRichEmail()
+		email.HasAttachment = true
+		client := &SMTPClient{repositories: &repository.Repositories{
+			EmailAttachmentRepository: &fakeAttachmentRepository{content: map[string][]byte{"att_pdf": []byte("%PDF")}},
+		}}
+
+		buffer, err := client.renderMessage(context.Background(), email, []*models.EmailAttachment{
+			{ID: "att_pdf", Filename: "offer.pdf", ContentType: "application/pdf"},
+		})
+		require.NoError(t, err)
+
+		envelope, err := enmime.ReadEnvelope(bytes.NewReader(buffer.Bytes()))
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(envelope.GetHeader("Content-Type"), "multipart/mixed"))
+		assert.Equal(t, email.BodyText, envelope.Text)
+		assert.Equal(t, email.BodyHTML, envelope.HTML)
+		require.Len(t, envelope.Attachments, 1)
+		assert.Equal(t, "offer.pdf", envelope.Attachments[0].FileName)
+		assert.Equal(t, []byte("%PDF"), envelope.Attachments[0].Content)
+
+		assert.Equal(t, "multipart/mixed", email.BodyStructure["type"])
+		parts := email.BodyStructure["parts"].([]models.JSONMap)
+		require.Len(t, parts, 2)
+		assert.Equal(t, "multipart/alternative", parts[0]["type"])
+		assert.Equal(t, "attachment", parts[1]["disposition"])
+	})
+
+	t.Run("html only", func(t *testing.T) {
+		email := newRichEmail()
+		email.BodyText = ""
+
+		buffer, err := (&SMTPClient{}).renderMessage(context.Background(), email, nil)
+		require.NoError(t, err)
+
+		envelope, err := enmime.ReadEnvelope(bytes.NewReader(buffer.Bytes()))
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(envelope.GetHeader("Content-Type"), "text/html"))
+		assert.Equal(t, email.BodyHTML, envelope.HTML)
+		assert.Equal(t, "text/html", email.BodyStructure["type"])
+	})
+}
+
+func TestRenderMessageListUnsubscribe(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         string
+		mailto      string
+		unsubscribe string
+		post        string
+	}{
+		{
+			name:        "https url and mailto",
+			url:         "https://acme.io/unsubscribe?u=42",
+			mailto:      "unsubscribe@acme.io",
+			unsubscribe: "<mailto:unsubscribe@acme.io?subject=unsubscribe>, <https://acme.io/unsubscribe?u=42>",
+			post:        "List-Unsubscribe=One-Click",
+		},
+		{
+			name:        "http url has no one-click",
+			url:         "http://acme.io/unsubscribe?u=42",
+			unsubscribe: "<http://acme.io/unsubscribe?u=42>",
+		},
+		{
+			name:        "mailto only has no one-click",
+			mailto:      "unsubscribe@acme.io",
+			unsubscribe: "<mailto:unsubscribe@acme.io?subject=unsubscribe>",
+		},
+		{
+			name: "not a bulk email",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email := newRichEmail()
+			email.UnsubscribeURL = tt.url
+			email.UnsubscribeMailto = tt.mailto
+
+			buffer, err := (&SMTPClient{}).renderMessage(context.Background(), email, nil)
+			require.NoError(t, err)
+
+			envelope, err := enmime.ReadEnvelope(bytes.NewReader(buffer.Bytes()))
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(envelope.GetHeader("Content-Type"), "multipart/alternative"))
+			assert.Equal(t, tt.unsubscribe, envelope.GetHeader("List-Unsubscribe"))
+			assert.Equal(t, tt.post, envelope.GetHeader("List-Unsubscribe-Post"))
+			assert.Equal(t, email.BodyHTML, envelope.HTML)
+		})
+	}
+}
+
+func TestRenderMessageSizeLimit(t *testing.T) {
+	buffer, err := (&SMTPClient{}).renderMessage(context.Background(), newRichEmail(), nil)
+	require.NoError(t, err)
+	size := buffer.Len()
+
+	// the rendered size does not depend on the run, a limit of exactly that size is accepted
+	buffer, err = (&SMTPClient{config: &config.SMTPConfig{MaxMessageSizeBytes: size}}).renderMessage(context.Background(), newRichEmail(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, size, buffer.Len())
+
+	_, err = (&SMTPClient{config: &config.SMTPConfig{MaxMessageSizeBytes: size - 1}}).renderMessage(context.Background(), newRichEmail(), nil)
+	assert.ErrorIs(t, err, mailstack_errors.ErrMessageTooLarge)
+}
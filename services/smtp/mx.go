@@ -0,0 +1,120 @@
+package smtp
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/customeros/mailstack/internal/config"
+)
+
+const (
+	defaultMXCacheTTL = time.Hour
+	mxLookupTimeout   = 5 * time.Second
+)
+
+type mxResolver interface {
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+type mxCacheEntry struct {
+	deliverable bool
+	expiresAt   time.Time
+}
+
+// MXChecker tells whether a domain accepts mail, from its MX records or, without any, its
+// address records (RFC 5321 implicit MX). Results are cached per domain. Lookups that fail
+// for other reasons than a missing domain are not cached and count as deliverable, a flaky
+// resolver should not stop sends.
+type MXChecker struct {
+	resolver mxResolver
+	ttl      time.Duration
+	mu       sync.Mutex
+	cache    map[string]mxCacheEntry
+}
+
+// NewMXChecker returns nil when the precheck is disabled
+func NewMXChecker(cfg *config.SMTPConfig) *MXChecker {
+	if cfg == nil || !cfg.MXPrecheckEnabled {
+		return nil
+	}
+
+	ttl := defaultMXCacheTTL
+	if cfg.MXPrecheckCacheSeconds > 0 {
+		ttl = time.Duration(cfg.MXPrecheckCacheSeconds) * time.Second
+	}
+	return newMXChecker(net.DefaultResolver, ttl)
+}
+
+func newMXChecker(resolver mxResolver, ttl time.Duration) *MXChecker {
+	return &MXChecker{
+		resolver: resolver,
+		ttl:      ttl,
+		cache:    make(map[string]mxCacheEntry),
+	}
+}
+
+// Deliverable looks up the domain, or answers from the cache
+func (m *MXChecker) Deliverable(ctx context.Context, domain string) bool {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	m.mu.Lock()
+	entry, ok := m.cache[domain]
+	m.mu.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.deliverable
+	}
+
+	deliverable, err := m.lookup(ctx, domain)
+	if err != nil {
+		return true
+	}
+
+	m.mu.Lock()
+	m.cache[domain] = mxCacheEntry{deliverable: deliverable, expiresAt: time.Now().Add(m.ttl)}
+	m.mu.Unlock()
+	return deliverable
+}
+
+func (m *MXChecker) lookup(ctx context.Context, domain string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, mxLookupTimeout)
+	defer cancel()
+
+	records, err := m.resolver.LookupMX(ctx, domain)
+	if err != nil && !isNotFound(err) {
+		return false, err
+	}
+	if len(records) > 0 {
+		// a single "." record is a null MX, the domain accepts no mail (RFC 7505)
+		return !(len(records) == 1 && records[0].Host == "."), nil
+	}
+
+	addresses, err := m.resolver.LookupHost(ctx, domain)
+	if err != nil && !isNotFound(err) {
+		return false, err
+	}
+	return len(addresses) > 0, nil
+}
+
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// splitDeliverable separates the recipients whose domain accepts no mail
+func (m *MXChecker) splitDeliverable(ctx context.Context, recipients []string) (deliverable, undeliverable []string) {
+	for _, recipient := range recipients {
+		at := strings.LastIndex(recipient, "@")
+		if at >= 0 && !m.Deliverable(ctx, recipient[at+1:]) {
+			undeliverable = append(undeliverable, recipient)
+			continue
+		}
+		deliverable = append(deliverable, recipient)
+	}
+	return deliverable, undeliverable
+}
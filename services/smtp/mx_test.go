@@ -0,0 +1,83 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	mailstack_errors "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/models"
+)
+
+type fakeResolver struct {
+	mx      map[string][]*net.MX
+	hosts   map[string][]string
+	fail    map[string]bool
+	lookups int
+}
+
+func (r *fakeResolver) LookupMX(_ context.Context, name string) ([]*net.MX, error) {
+	r.lookups++
+	if r.fail[name] {
+		return nil, &net.DNSError{Err: "server misbehaving", Name: name, IsTemporary: true}
+	}
+	if records, ok := r.mx[name]; ok {
+		return records, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (r *fakeResolver) LookupHost(_ context.Context, host string) ([]string, error) {
+	if addresses, ok := r.hosts[host]; ok {
+		return addresses, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func TestMXChecker(t *testing.T) {
+	resolver := &fakeResolver{
+		mx: map[string][]*net.MX{
+			"example.com": {{Host: "mx.example.com.", Pref: 10}},
+			"nomail.com":  {{Host: ".", Pref: 0}},
+		},
+		hosts: map[string][]string{"implicit.com": {"192.0.2.1"}},
+		fail:  map[string]bool{"flaky.com": true},
+	}
+	checker := newMXChecker(resolver, time.Hour)
+	ctx := context.Background()
+
+	assert.True(t, checker.Deliverable(ctx, "example.com"))
+	assert.True(t, checker.Deliverable(ctx, "implicit.com"), "address records are an implicit MX")
+	assert.False(t, checker.Deliverable(ctx, "nomail.com"), "null MX")
+	assert.False(t, checker.Deliverable(ctx, "missing.com"))
+	assert.True(t, checker.Deliverable(ctx, "flaky.com"), "lookup errors do not block sends")
+
+	lookups := resolver.lookups
+	assert.False(t, checker.Deliverable(ctx, "MISSING.com"))
+	assert.True(t, checker.Deliverable(ctx, "example.com"))
+	assert.Equal(t, lookups, resolver.lookups, "answers are cached")
+
+	checker.Deliverable(ctx, "flaky.com")
+	assert.Equal(t, lookups+1, resolver.lookups, "failed lookups are not cached")
+}
+
+func TestCheckRecipientsWithMXPrecheck(t *testing.T) {
+	resolver := &fakeResolver{mx: map[string][]*net.MX{"example.com": {{Host: "mx.example.com.", Pref: 10}}}}
+	client := &SMTPClient{mxChecker: newMXChecker(resolver, time.Hour)}
+
+	email := &models.Email{}
+	recipients, err := client.checkRecipients(context.Background(), email, []string{"jane@example.com", "bob@nomx.io", "broken@"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"jane@example.com"}, recipients)
+	assert.Equal(t, []string{"broken@", "bob@nomx.io"}, []string(email.SkippedRecipients))
+
+	_, err = client.checkRecipients(context.Background(), &models.Email{}, []string{"bob@nomx.io"})
+	var problems mailstack_errors.ValidationErrors
+	require.True(t, errors.As(err, &problems))
+	assert.Equal(t, mailstack_errors.ValidationUndeliverable, problems[0].Code)
+}
@@ -0,0 +1,240 @@
+package smtp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/smtp"
+	"sync"
+	"time"
+
+	"github.com/customeros/mailstack/internal/config"
+	"github.com/customeros/mailstack/internal/models"
+)
+
+const (
+	defaultPoolMaxIdle = time.Minute
+	healthCheckTimeout = 10 * time.Second
+)
+
+// Conn is an authenticated SMTP session, pooled per mailbox
+type Conn struct {
+	Client *smtp.Client
+
+	conn        net.Conn
+	mailboxID   string
+	fingerprint string
+	lastUsed    time.Time
+}
+
+func (c *Conn) close() {
+	c.Client.Close()
+}
+
+// Pool keeps authenticated SMTP sessions warm per mailbox, so bursts of sends to the same server
+// skip the TCP, TLS and AUTH handshakes. Sessions are checked with RSET before reuse, dropped
+// after an error and closed once idle for longer than the max idle time.
+type Pool struct {
+	mu             sync.Mutex
+	mailboxes      map[string]*mailboxConns
+	maxConnections int
+	maxIdle        time.Duration
+	stop           chan struct{}
+	closeOnce      sync.Once
+	closed         bool // sessions returned after Close are closed instead of kept idle
+}
+
+type mailboxConns struct {
+	idle []*Conn
+	open int
+	// closed and replaced whenever a connection is returned or closed, wakes up waiting senders
+	released chan struct{}
+}
+
+// NewPool returns nil when pooling is disabled, senders then open a session per send
+func NewPool(cfg *config.SMTPConfig) *Pool {
+	if cfg == nil || cfg.PoolMaxConnectionsPerMailbox <= 0 {
+		return nil
+	}
+
+	maxIdle := defaultPoolMaxIdle
+	if cfg.PoolMaxIdleSeconds > 0 {
+		maxIdle = time.Duration(cfg.PoolMaxIdleSeconds) * time.Second
+	}
+
+	p := &Pool{
+		mailboxes:      make(map[string]*mailboxConns),
+		maxConnections: cfg.PoolMaxConnectionsPerMailbox,
+		maxIdle:        maxIdle,
+		stop:           make(chan struct{}),
+	}
+	go p.closeIdleLoop()
+	return p
+}
+
+// Get returns a healthy idle session of the mailbox or dials a new one. When the mailbox
+// already has the max number of sessions open it waits for one to be released.
+func (p *Pool) Get(ctx context.Context, mailbox *models.Mailbox, dial func(ctx context.Context) (*Conn, error)) (*Conn, error) {
+	fingerprint := mailboxFingerprint(mailbox)
+
+	for {
+		p.mu.Lock()
+		mc := p.mailboxConnsLocked(mailbox.ID)
+
+		if n := len(mc.idle); n > 0 {
+			conn := mc.idle[n-1]
+			mc.idle = mc.idle[:n-1]
+			p.mu.Unlock()
+
+			if conn.fingerprint == fingerprint && time.Since(conn.lastUsed) <= p.maxIdle && healthy(conn) {
+				return conn, nil
+			}
+			p.Discard(conn)
+			continue
+		}
+
+		if mc.open < p.maxConnections {
+			mc.open++
+			p.mu.Unlock()
+
+			conn, err := dial(ctx)
+			if err != nil {
+				p.release(mailbox.ID)
+				return nil, err
+			}
+			conn.mailboxID = mailbox.ID
+			conn.fingerprint = fingerprint
+			return conn, nil
+		}
+
+		released := mc.released
+		p.mu.Unlock()
+
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Put returns a session after a successful send
+func (p *Pool) Put(conn *Conn) {
+	conn.lastUsed = time.Now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	mc := p.mailboxConnsLocked(conn.mailboxID)
+	if p.closed {
+		conn.close()
+		mc.open--
+	} else {
+		mc.idle = append(mc.idle, conn)
+	}
+	mc.notifyLocked()
+}
+
+// Discard closes a session that failed or can not be trusted anymore
+func (p *Pool) Discard(conn *Conn) {
+	conn.close()
+	p.release(conn.mailboxID)
+}
+
+// Close closes all idle sessions and stops the idle timer, sessions in use are closed when returned
+func (p *Pool) Close() {
+	if p == nil {
+		return
+	}
+	p.closeOnce.Do(func() { close(p.stop) })
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	for _, mc := range p.mailboxes {
+		for _, conn := range mc.idle {
+			conn.close()
+			mc.open--
+		}
+		mc.idle = nil
+		mc.notifyLocked()
+	}
+}
+
+func (p *Pool) release(mailboxID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	mc := p.mailboxConnsLocked(mailboxID)
+	mc.open--
+	mc.notifyLocked()
+}
+
+func (p *Pool) closeIdleLoop() {
+	ticker := time.NewTicker(p.maxIdle / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.closeIdle(time.Now())
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// closeIdle closes the sessions idle for longer than the max idle time
+func (p *Pool) closeIdle(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for mailboxID, mc := range p.mailboxes {
+		kept := mc.idle[:0]
+		for _, conn := range mc.idle {
+			if now.Sub(conn.lastUsed) > p.maxIdle {
+				conn.close()
+				mc.open--
+				continue
+			}
+			kept = append(kept, conn)
+		}
+		mc.idle = kept
+		mc.notifyLocked()
+
+		if mc.open == 0 {
+			delete(p.mailboxes, mailboxID)
+		}
+	}
+}
+
+func (p *Pool) mailboxConnsLocked(mailboxID string) *mailboxConns {
+	mc, ok := p.mailboxes[mailboxID]
+	if !ok {
+		mc = &mailboxConns{released: make(chan struct{})}
+		p.mailboxes[mailboxID] = mc
+	}
+	return mc
+}
+
+func (mc *mailboxConns) notifyLocked() {
+	close(mc.released)
+	mc.released = make(chan struct{})
+}
+
+// healthy resets the session with RSET, which also tells whether the server still talks to us
+func healthy(conn *Conn) bool {
+	if conn.conn != nil {
+		conn.conn.SetDeadline(time.Now().Add(healthCheckTimeout))
+		defer conn.conn.SetDeadline(time.Time{})
+	}
+	return conn.Client.Reset() == nil
+}
+
+// mailboxFingerprint changes with the server or credentials, sessions opened before are not reused
+func mailboxFingerprint(mailbox *models.Mailbox) string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%s|%s|%s", mailbox.SmtpServer, mailbox.SmtpPort, mailbox.SmtpSecurity, mailbox.SmtpUsername, mailbox.SmtpPassword)))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,112 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/smtp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/internal/config"
+	"github.com/customeros/mailstack/internal/models"
+)
+
+func TestPool(t *testing.T) {
+	mailbox := &models.Mailbox{ID: "mb1", SmtpServer: "fake", SmtpPort: 587, SmtpUsername: "me", SmtpPassword: "secret"}
+
+	dials := 0
+	dial := func(ctx context.Context) (*Conn, error) {
+		dials++
+		clientConn, serverConn := net.Pipe()
+		go fakeServer(t, serverConn, "", &bytes.Buffer{})
+		client, err := smtp.NewClient(clientConn, "fake")
+		if err != nil {
+			return nil, err
+		}
+		return &Conn{Client: client, conn: clientConn}, nil
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		assert.Nil(t, NewPool(&config.SMTPConfig{PoolMaxConnectionsPerMailbox: 0}))
+	})
+
+	pool := NewPool(&config.SMTPConfig{PoolMaxConnectionsPerMailbox: 1, PoolMaxIdleSeconds: 60})
+	defer pool.Close()
+
+	t.Run("reuses returned sessions", func(t *testing.T) {
+		conn, err := pool.Get(context.Background(), mailbox, dial)
+		require.NoError(t, err)
+		pool.Put(conn)
+
+		again, err := pool.Get(context.Background(), mailbox, dial)
+		require.NoError(t, err)
+		assert.Same(t, conn, again)
+		assert.Equal(t, 1, dials)
+		pool.Put(again)
+	})
+
+	t.Run("waits for a free session", func(t *testing.T) {
+		conn, err := pool.Get(context.Background(), mailbox, dial)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err = pool.Get(ctx, mailbox, dial)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+		go pool.Put(conn)
+		again, err := pool.Get(context.Background(), mailbox, dial)
+		require.NoError(t, err)
+		pool.Put(again)
+	})
+
+	t.Run("changed credentials open a new session", func(t *testing.T) {
+		changed := *mailbox
+		changed.SmtpPassword = "rotated"
+		before := dials
+
+		conn, err := pool.Get(context.Background(), &changed, dial)
+		require.NoError(t, err)
+		assert.Equal(t, before+1, dials)
+		pool.Put(conn)
+	})
+
+	t.Run("discarded and idle sessions are closed", func(t *testing.T) {
+		conn, err := pool.Get(context.Background(), mailbox, dial)
+		require.NoError(t, err)
+		pool.Discard(conn)
+
+		before := dials
+		conn, err = pool.Get(context.Background(), mailbox, dial)
+		require.NoError(t, err)
+		assert.Equal(t, before+1, dials)
+		pool.Put(conn)
+
+		pool.closeIdle(time.Now().Add(2 * time.Minute))
+		pool.mu.Lock()
+		_, ok := pool.mailboxes[mailbox.ID]
+		pool.mu.Unlock()
+		assert.False(t, ok)
+	})
+
+	t.Run("sessions returned after close are closed", func(t *testing.T) {
+		closing := NewPool(&config.SMTPConfig{PoolMaxConnectionsPerMailbox: 1})
+		var nilPool *Pool
+		assert.NotPanics(t, nilPool.Close)
+
+		conn, err := closing.Get(context.Background(), mailbox, dial)
+		require.NoError(t, err)
+		closing.Close()
+		closing.Put(conn)
+
+		closing.mu.Lock()
+		mc := closing.mailboxes[mailbox.ID]
+		closing.mu.Unlock()
+		assert.Empty(t, mc.idle)
+		assert.Equal(t, 0, mc.open)
+	})
+}
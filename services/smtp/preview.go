@@ -0,0 +1,43 @@
+package smtp
+
+import (
+	"context"
+
+	"github.com/opentracing/opentracing-go"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/models"
+	"github.com/customeros/mailstack/internal/tracing"
+)
+
+// Preview renders the message Send would transmit for email, without connecting to the server.
+// It works on a copy: nothing is stored, and neither the email nor any send counter changes.
+func (s *SMTPClient) Preview(ctx context.Context, email *models.Email, attachments []*models.EmailAttachment) (*interfaces.EmailPreview, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "SMTPClient.Preview")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+
+	draft := *email
+	buffer, err := s.renderMessage(ctx, &draft, attachments)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+
+	headers := make(map[string]string, len(draft.RawHeaders))
+	for name, value := range draft.RawHeaders {
+		if v, ok := value.(string); ok {
+			headers[name] = v
+		}
+	}
+
+	return &interfaces.EmailPreview{
+		EmailID:       email.ID,
+		Headers:       headers,
+		Envelope:      draft.Envelope,
+		BodyStructure: draft.BodyStructure,
+		Recipients:    draft.AllRecipients(),
+		Raw:           buffer.String(),
+		SizeBytes:     buffer.Len(),
+	}, nil
+}
@@ -0,0 +1,41 @@
+package smtp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/internal/models"
+)
+
+func TestPreviewLeavesEmailUntouched(t *testing.T) {
+	email := &models.Email{
+		ID:           "email_1",
+		MessageID:    "abc@acme.io",
+		FromAddress:  "jane@acme.io",
+		ToAddresses:  pq.StringArray{"bob@corp.io"},
+		BccAddresses: pq.StringArray{"audit@acme.io"},
+		Subject:      "Hello",
+		BodyText:     "Hi Bob",
+		InReplyTo:    "<parent@corp.io>",
+	}
+
+	preview, err := (&SMTPClient{}).Preview(context.Background(), email, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, "<abc@acme.io>", preview.Headers["Message-ID"])
+	assert.Equal(t, "<parent@corp.io>", preview.Headers["In-Reply-To"])
+	assert.NotContains(t, preview.Headers, "Bcc")
+	assert.ElementsMatch(t, []string{"bob@corp.io", "audit@acme.io"}, preview.Recipients)
+	assert.Contains(t, preview.Raw, "Hi Bob")
+	assert.Equal(t, len(preview.Raw), preview.SizeBytes)
+	assert.NotEmpty(t, preview.BodyStructure)
+
+	assert.Equal(t, "abc@acme.io", email.MessageID)
+	assert.Nil(t, email.RawHeaders)
+	assert.Nil(t, email.Envelope)
+	assert.Nil(t, email.BodyStructure)
+}
@@ -0,0 +1,54 @@
+package smtp
+
+import (
+	"strings"
+
+	"github.com/customeros/mailsherpa/mailvalidate"
+
+	mailstack_errors "github.com/customeros/mailstack/internal/errors"
+)
+
+// Policies for recipients with invalid addresses
+const (
+	// InvalidRecipientsSkip sends to the valid recipients and records the skipped ones on the email
+	InvalidRecipientsSkip = "skip"
+	// InvalidRecipientsReject fails the send when any recipient is invalid
+	InvalidRecipientsReject = "reject"
+)
+
+// splitRecipients validates every recipient and returns the clean valid addresses, deduped
+// case insensitively, and the invalid ones as given
+func splitRecipients(recipients []string) (valid, invalid []string) {
+	seen := make(map[string]struct{}, len(recipients))
+	for _, recipient := range recipients {
+		validation := mailvalidate.ValidateEmailSyntax(recipient)
+		if !validation.IsValid {
+			invalid = append(invalid, recipient)
+			continue
+		}
+
+		key := strings.ToLower(validation.CleanEmail)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		valid = append(valid, validation.CleanEmail)
+	}
+	return valid, invalid
+}
+
+// skippedRecipientsError reports every invalid and undeliverable recipient
+func skippedRecipientsError(invalid, undeliverable []string) error {
+	var problems mailstack_errors.ValidationErrors
+	for _, recipient := range invalid {
+		problems.Add(mailstack_errors.ValidationInvalidRecipient, "recipients", recipient+": email address is invalid", nil)
+	}
+	for _, recipient := range undeliverable {
+		problems.Add(mailstack_errors.ValidationUndeliverable, "recipients", recipient+": domain accepts no mail", nil)
+	}
+	return problems
+}
+
+func (s *SMTPClient) rejectsInvalidRecipients() bool {
+	return s.config != nil && strings.EqualFold(s.config.InvalidRecipientPolicy, InvalidRecipientsReject)
+}
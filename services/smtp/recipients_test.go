@@ -0,0 +1,65 @@
+package smtp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/internal/config"
+	mailstack_errors "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/models"
+)
+
+func TestSplitRecipients(t *testing.T) {
+	valid, invalid := splitRecipients([]string{
+		"jane@example.com",
+		"not-an-address",
+		"Jane@Example.com",
+		"bob@example.com",
+		"@example.com",
+	})
+	assert.Equal(t, []string{"jane@example.com", "bob@example.com"}, valid)
+	assert.Equal(t, []string{"not-an-address", "@example.com"}, invalid)
+
+	valid, invalid = splitRecipients([]string{"jane@example.com"})
+	assert.Equal(t, []string{"jane@example.com"}, valid)
+	assert.Empty(t, invalid)
+}
+
+func TestCheckRecipients(t *testing.T) {
+	mixed := []string{"jane@example.com", "broken@", "bob@example.com"}
+
+	t.Run("skip sends to the valid recipients", func(t *testing.T) {
+		client := &SMTPClient{config: &config.SMTPConfig{InvalidRecipientPolicy: InvalidRecipientsSkip}}
+		email := &models.Email{}
+
+		recipients, err := client.checkRecipients(context.Background(), email, mixed)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"jane@example.com", "bob@example.com"}, recipients)
+		assert.Equal(t, []string{"broken@"}, []string(email.SkippedRecipients))
+	})
+
+	t.Run("skip fails without any valid recipient", func(t *testing.T) {
+		client := &SMTPClient{config: &config.SMTPConfig{InvalidRecipientPolicy: InvalidRecipientsSkip}}
+
+		_, err := client.checkRecipients(context.Background(), &models.Email{}, []string{"broken@", "also broken"})
+		problems, ok := mailstack_errors.AsValidationErrors(err)
+		require.True(t, ok)
+		assert.Len(t, problems, 2)
+		assert.False(t, IsTransientError(err))
+	})
+
+	t.Run("reject fails on any invalid recipient", func(t *testing.T) {
+		client := &SMTPClient{config: &config.SMTPConfig{InvalidRecipientPolicy: InvalidRecipientsReject}}
+		email := &models.Email{}
+
+		_, err := client.checkRecipients(context.Background(), email, mixed)
+		problems, ok := mailstack_errors.AsValidationErrors(err)
+		require.True(t, ok)
+		require.Len(t, problems, 1)
+		assert.Equal(t, mailstack_errors.ValidationInvalidRecipient, problems[0].Code)
+		assert.Equal(t, []string{"broken@"}, []string(email.SkippedRecipients))
+	})
+}
@@ -0,0 +1,87 @@
+package smtp
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/textproto"
+	"time"
+
+	"github.com/customeros/mailstack/internal/enum"
+	"github.com/customeros/mailstack/internal/models"
+)
+
+const (
+	defaultMaxSendAttempts = 5
+	defaultRetryBaseDelay  = time.Minute
+	defaultRetryMaxDelay   = time.Hour
+)
+
+// IsTransientError reports whether an SMTP send error is worth retrying.
+// 4xx replies and network level failures are transient, 5xx replies are permanent.
+func IsTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return protoErr.Code >= 400 && protoErr.Code < 500
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// recordFailedAttempt updates the email send state after a failed attempt. Transient
+// failures are rescheduled with exponential backoff until the max attempts are used up.
+func (s *SMTPClient) recordFailedAttempt(email *models.Email, sendErr error, now time.Time) {
+	email.LastAttemptAt = &now
+	email.StatusDetail = sendErr.Error()
+
+	if !IsTransientError(sendErr) || email.SendAttempts >= s.maxSendAttempts() {
+		email.Status = enum.EmailStatusFailed
+		return
+	}
+
+	nextAttemptAt := now.Add(s.retryDelay(email.SendAttempts))
+	email.SendAttempts++
+	email.Status = enum.EmailStatusScheduled
+	email.ScheduledFor = &nextAttemptAt
+}
+
+// retryDelay returns the wait before the next attempt, doubling with each failed attempt
+func (s *SMTPClient) retryDelay(failedAttempts int) time.Duration {
+	base, max := defaultRetryBaseDelay, defaultRetryMaxDelay
+	if s.config != nil {
+		if s.config.RetryBaseDelaySeconds > 0 {
+			base = time.Duration(s.config.RetryBaseDelaySeconds) * time.Second
+		}
+		if s.config.RetryMaxDelaySeconds > 0 {
+			max = time.Duration(s.config.RetryMaxDelaySeconds) * time.Second
+		}
+	}
+
+	delay := base
+	for i := 1; i < failedAttempts; i++ {
+		delay *= 2
+		if delay >= max {
+			return max
+		}
+	}
+	if delay > max {
+		return max
+	}
+	return delay
+}
+
+func (s *SMTPClient) maxSendAttempts() int {
+	if s.config != nil && s.config.MaxSendAttempts > 0 {
+		return s.config.MaxSendAttempts
+	}
+	return defaultMaxSendAttempts
+}
@@ -0,0 +1,99 @@
+package smtp
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/internal/config"
+	"github.com/customeros/mailstack/internal/enum"
+	"github.com/customeros/mailstack/internal/models"
+)
+
+func TestIsTransientError(t *testing.T) {
+	timeout := &net.OpError{Op: "dial", Net: "tcp", Err: os.ErrDeadlineExceeded}
+
+	tests := []struct {
+		name      string
+		err       error
+		transient bool
+	}{
+		{"no error", nil, false},
+		{"mailbox busy", &textproto.Error{Code: 450, Msg: "mailbox busy"}, true},
+		{"greylisted", errors.Wrap(&textproto.Error{Code: 421, Msg: "try again later"}, "failed to send"), true},
+		{"no such user", &textproto.Error{Code: 550, Msg: "no such user"}, false},
+		{"rejected as spam", fmt.Errorf("data: %w", &textproto.Error{Code: 554, Msg: "rejected"}), false},
+		{"timeout", timeout, true},
+		{"wrapped timeout", errors.Wrap(timeout, "failed to connect"), true},
+		{"connection closed", io.EOF, true},
+		{"truncated reply", io.ErrUnexpectedEOF, true},
+		{"other error", errors.New("invalid address"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.transient, IsTransientError(tt.err))
+		})
+	}
+}
+
+func TestRecordFailedAttempt(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	transient := &textproto.Error{Code: 451, Msg: "try again later"}
+	client := &SMTPClient{config: &config.SMTPConfig{MaxSendAttempts: 3, RetryBaseDelaySeconds: 60, RetryMaxDelaySeconds: 150}}
+
+	t.Run("transient failures back off until max attempts", func(t *testing.T) {
+		email := &models.Email{Status: enum.EmailStatusQueued}
+
+		for _, delay := range []time.Duration{time.Minute, time.Minute, 2 * time.Minute} {
+			attempts := email.SendAttempts
+			client.recordFailedAttempt(email, transient, now)
+
+			assert.Equal(t, enum.EmailStatusScheduled, email.Status)
+			assert.Equal(t, attempts+1, email.SendAttempts)
+			require.NotNil(t, email.ScheduledFor)
+			assert.Equal(t, now.Add(delay), *email.ScheduledFor)
+			assert.Equal(t, now, *email.LastAttemptAt)
+			assert.Equal(t, transient.Error(), email.StatusDetail)
+		}
+
+		client.recordFailedAttempt(email, transient, now)
+		assert.Equal(t, enum.EmailStatusFailed, email.Status)
+		assert.Equal(t, 3, email.SendAttempts)
+	})
+
+	t.Run("permanent failure is not retried", func(t *testing.T) {
+		email := &models.Email{Status: enum.EmailStatusQueued}
+		permanent := &textproto.Error{Code: 550, Msg: "no such user"}
+
+		client.recordFailedAttempt(email, permanent, now)
+
+		assert.Equal(t, enum.EmailStatusFailed, email.Status)
+		assert.Equal(t, 0, email.SendAttempts)
+		assert.Nil(t, email.ScheduledFor)
+		assert.Equal(t, permanent.Error(), email.StatusDetail)
+		assert.Equal(t, now, *email.LastAttemptAt)
+	})
+}
+
+func TestRetryDelay(t *testing.T) {
+	client := &SMTPClient{config: &config.SMTPConfig{RetryBaseDelaySeconds: 60, RetryMaxDelaySeconds: 300}}
+	assert.Equal(t, time.Minute, client.retryDelay(1))
+	assert.Equal(t, 2*time.Minute, client.retryDelay(2))
+	assert.Equal(t, 4*time.Minute, client.retryDelay(3))
+	assert.Equal(t, 5*time.Minute, client.retryDelay(4))
+	assert.Equal(t, 5*time.Minute, client.retryDelay(30))
+
+	// defaults without config
+	assert.Equal(t, defaultRetryBaseDelay, (&SMTPClient{}).retryDelay(1))
+	assert.Equal(t, defaultRetryMaxDelay, (&SMTPClient{}).retryDelay(20))
+	assert.Equal(t, defaultMaxSendAttempts, (&SMTPClient{}).maxSendAttempts())
+}
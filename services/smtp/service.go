@@ -4,35 +4,70 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"encoding/base64"
 	"fmt"
+	"log"
 	"mime/multipart"
 	"net"
 	"net/smtp"
 	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/customeros/mailsherpa/mailvalidate"
 	"github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
 
+	"github.com/customeros/mailstack/internal/config"
 	"github.com/customeros/mailstack/internal/enum"
+	mailstack_errors "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/metrics"
 	"github.com/customeros/mailstack/internal/models"
 	"github.com/customeros/mailstack/internal/repository"
 	"github.com/customeros/mailstack/internal/tracing"
 	"github.com/customeros/mailstack/internal/utils"
 )
 
+// sentFolderAppendTimeout bounds storing the copy of a sent message, including the wait for an
+// IMAP connection slot
+const sentFolderAppendTimeout = 2 * time.Minute
+
 type SMTPClient struct {
 	repositories *repository.Repositories
 	mailbox      *models.Mailbox
+	config       *config.SMTPConfig
+	pool         *Pool
+	sentAppender SentFolderAppender
+	mxChecker    *MXChecker
+}
+
+// SentFolderAppender stores sent messages in the sent folder of the mailbox
+type SentFolderAppender interface {
+	AppendToSent(ctx context.Context, mailbox *models.Mailbox, message []byte, date time.Time) error
 }
 
-func NewSMTPClient(repos *repository.Repositories, mailbox *models.Mailbox) *SMTPClient {
+// NewSMTPClient creates a sender for the mailbox. Sessions are reused from pool, a nil pool opens
+// a new session for every send.
+func NewSMTPClient(repos *repository.Repositories, mailbox *models.Mailbox, cfg *config.SMTPConfig, pool *Pool) *SMTPClient {
 	return &SMTPClient{
 		repositories: repos,
 		mailbox:      mailbox,
+		config:       cfg,
+		pool:         pool,
 	}
 }
 
+// SetSentFolderAppender makes Send store a copy of every sent message in the sent folder
+func (s *SMTPClient) SetSentFolderAppender(appender SentFolderAppender) {
+	s.sentAppender = appender
+}
+
+// SetMXChecker makes Send skip recipients whose domain accepts no mail, nil disables the check
+func (s *SMTPClient) SetMXChecker(checker *MXChecker) {
+	s.mxChecker = checker
+}
+
 func (s *SMTPClient) Send(ctx context.Context, email *models.Email, attachments []*models.EmailAttachment) error {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "SMTPClient.Send")
 	defer span.Finish()
@@ -42,23 +77,39 @@ func (s *SMTPClient) Send(ctx context.Context, email *models.Email, attachments
 	err := s.validateEmail(ctx, email)
 	if err != nil {
 		tracing.TraceErr(span, err)
+		metrics.EmailsFailed.WithLabelValues(metrics.OperationSend).Inc()
 		return err
 	}
 
 	// Prepare the email message
 	allRecipients, messageBuffer, err := s.prepareMessage(ctx, email, attachments)
+	if err == nil {
+		allRecipients, err = s.checkRecipients(ctx, email, allRecipients)
+	}
 	if err != nil {
 		tracing.TraceErr(span, err)
+		metrics.EmailsFailed.WithLabelValues(metrics.OperationSend).Inc()
+		if _, invalidRecipients := mailstack_errors.AsValidationErrors(err); invalidRecipients || errors.Is(err, mailstack_errors.ErrMessageTooLarge) {
+			s.recordFailedAttempt(email, err, utils.Now())
+			if updateErr := s.repositories.EmailRepository.Update(ctx, email); updateErr != nil {
+				tracing.TraceErr(span, updateErr)
+			}
+		}
 		return err
 	}
 
 	// Send the email
-	err = s.sendToServer(ctx, email.FromAddress, allRecipients, messageBuffer)
+	email.MessageSizeBytes = messageBuffer.Len()
+	email.RecipientCount = len(allRecipients)
+	start := time.Now()
+	email.SmtpResponse, err = s.sendToServer(ctx, email.FromAddress, allRecipients, messageBuffer)
+	duration := time.Since(start)
+	email.SendDurationMs = duration.Milliseconds()
+	tagSendMetrics(span, email)
+	recordSendMetrics(duration, err)
 	if err != nil {
 		tracing.TraceErr(span, err)
-		email.LastAttemptAt = utils.NowPtr()
-		email.Status = enum.EmailStatusFailed
-		email.StatusDetail = err.Error()
+		s.recordFailedAttempt(email, err, utils.Now())
 		err = s.repositories.EmailRepository.Update(ctx, email)
 		if err != nil {
 			tracing.TraceErr(span, err)
@@ -76,68 +127,124 @@ func (s *SMTPClient) Send(ctx context.Context, email *models.Email, attachments
 		return err
 	}
 
+	if s.sentAppender != nil {
+		go s.appendToSentFolder(context.WithoutCancel(ctx), messageBuffer.Bytes(), *email.SentAt)
+	}
+
 	return nil
 }
 
-// validateEmail performs basic validation on the email
+// appendToSentFolder copies the sent message to the sent folder. It runs after Send returned, the
+// message is delivered already so failures are only logged. The synced copy is matched to the
+// sent email by its Message-ID instead of being ingested again.
+func (s *SMTPClient) appendToSentFolder(ctx context.Context, message []byte, sentAt time.Time) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "SMTPClient.appendToSentFolder")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+
+	ctx, cancel := context.WithTimeout(ctx, sentFolderAppendTimeout)
+	defer cancel()
+
+	err := s.sentAppender.AppendToSent(ctx, s.mailbox, message, sentAt)
+	if errors.Is(err, mailstack_errors.ErrSentFolderNotFound) {
+		span.LogKV("sent_folder", "not found")
+		log.Printf("[%s] No sent folder found, sent message not stored", s.mailbox.ID)
+		return
+	}
+	if err != nil {
+		tracing.TraceErr(span, err)
+		log.Printf("[%s] Failed to append sent message to sent folder: %v", s.mailbox.ID, err)
+	}
+}
+
+// checkRecipients drops the recipients with invalid addresses and, with the MX precheck, those of
+// domains that accept no mail, so one bad address does not fail the send at RCPT time. The dropped
+// ones are recorded on the email, or fail the send when the policy rejects invalid recipients or
+// no valid one is left.
+func (s *SMTPClient) checkRecipients(ctx context.Context, email *models.Email, recipients []string) ([]string, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "SMTPClient.checkRecipients")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+
+	valid, invalid := splitRecipients(recipients)
+	var undeliverable []string
+	if s.mxChecker != nil {
+		valid, undeliverable = s.mxChecker.splitDeliverable(ctx, valid)
+	}
+
+	email.SkippedRecipients = append(invalid, undeliverable...)
+	if len(email.SkippedRecipients) == 0 {
+		return valid, nil
+	}
+	span.LogKV("skipped_recipients", strings.Join(email.SkippedRecipients, ", "))
+
+	if len(valid) == 0 || s.rejectsInvalidRecipients() {
+		err := skippedRecipientsError(invalid, undeliverable)
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+	return valid, nil
+}
+
+// validateEmail checks the email can be sent from the mailbox and reports all problems at once
 func (s *SMTPClient) validateEmail(ctx context.Context, email *models.Email) error {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "SMTPClient.validateEmail")
 	defer span.Finish()
 	tracing.SetDefaultServiceSpanTags(ctx, span)
 
-	email.Direction = enum.EmailDirectionOutbound
-
 	if email == nil {
 		err := fmt.Errorf("email cannot be nil")
 		tracing.TraceErr(span, err)
 		return err
 	}
+	email.Direction = enum.EmailDirectionOutbound
 
-	if email.FromAddress == "" {
-		err := fmt.Errorf("from address is required")
+	problems := validateOutgoing(email, s.mailbox.MailboxDomain)
+	if err := problems.Err(); err != nil {
 		tracing.TraceErr(span, err)
 		return err
 	}
 
-	if email.FromDomain == "" {
+	if email.MessageID == "" {
+		email.MessageID = utils.GenerateMessageID(s.mailbox.MessageIDHost(s.mailbox.MailboxDomain), "")
+	}
+
+	return nil
+}
+
+// validateOutgoing collects the problems that keep an email from being sent from mailboxDomain.
+// Sender user and domain are set on the email when the from address is valid.
+func validateOutgoing(email *models.Email, mailboxDomain string) mailstack_errors.ValidationErrors {
+	var problems mailstack_errors.ValidationErrors
+
+	switch {
+	case email.FromAddress == "":
+		problems.Add(mailstack_errors.ValidationMissingFrom, "fromAddress", "from address is required", nil)
+	case email.FromDomain == "":
 		validation := mailvalidate.ValidateEmailSyntax(email.FromAddress)
 		if !validation.IsValid {
-			err := fmt.Errorf("from address is not valid")
-			tracing.TraceErr(span, err)
-			return err
-		}
-		if validation.Domain != s.mailbox.MailboxDomain {
-			err := errors.New("from domain does not match mailbox domain")
-			tracing.TraceErr(span, err)
-			return err
+			problems.Add(mailstack_errors.ValidationInvalidFrom, "fromAddress", "from address is not valid", nil)
+		} else if validation.Domain != mailboxDomain {
+			problems.Add(mailstack_errors.ValidationDomainMismatch, "fromAddress", "from domain does not match mailbox domain", nil)
+		} else {
+			email.FromDomain = validation.Domain
+			email.FromUser = validation.User
 		}
-		email.FromDomain = validation.Domain
-		email.FromUser = validation.User
 	}
 
 	if len(email.ToAddresses) == 0 {
-		err := fmt.Errorf("at least one recipient is required")
-		tracing.TraceErr(span, err)
-		return err
+		problems.Add(mailstack_errors.ValidationNoRecipients, "toAddresses", "at least one recipient is required", nil)
 	}
 
 	if email.BodyText == "" && email.BodyHTML == "" {
-		err := fmt.Errorf("email must have either text or HTML content")
-		tracing.TraceErr(span, err)
-		return err
+		problems.Add(mailstack_errors.ValidationEmptyBody, "body", "email must have either text or HTML content", nil)
 	}
 
 	if email.Subject == "" {
-		err := fmt.Errorf("email must have a subject")
-		tracing.TraceErr(span, err)
-		return err
+		problems.Add(mailstack_errors.ValidationNoSubject, "subject", "email must have a subject", nil)
 	}
 
-	if email.MessageID == "" {
-		email.MessageID = utils.GenerateMessageID(s.mailbox.MailboxDomain, "")
-	}
-
-	return nil
+	return problems
 }
 
 // prepareMessage builds the email message in proper MIME format and stores raw metadata
@@ -146,12 +253,30 @@ func (s *SMTPClient) prepareMessage(ctx context.Context, email *models.Email, at
 	defer span.Finish()
 	tracing.SetDefaultServiceSpanTags(ctx, span)
 
+	buffer, err := s.renderMessage(ctx, email, attachments)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, nil, err
+	}
+	tracing.LogObjectAsJson(span, "headers", email.RawHeaders)
+
+	// Store the raw data in the database
+	err = s.repositories.EmailRepository.SetEmailRawData(ctx, email.ID, email.RawHeaders, email.Envelope, email.BodyStructure)
+	if err != nil {
+		tracing.TraceErr(span, err)
+	}
+
+	return email.AllRecipients(), buffer, nil
+}
+
+// renderMessage assembles the MIME message and sets the raw headers, envelope and body
+// structure on email, without storing anything
+func (s *SMTPClient) renderMessage(ctx context.Context, email *models.Email, attachments []*models.EmailAttachment) (*bytes.Buffer, error) {
 	// Create message buffer
 	buffer := bytes.NewBuffer(nil)
 
 	// Generate and store headers
 	headers := s.prepareHeaders(ctx, email)
-	tracing.LogObjectAsJson(span, "headers", headers)
 
 	// Prepare and store envelope information
 	s.prepareEnvelope(ctx, email, headers)
@@ -163,19 +288,15 @@ func (s *SMTPClient) prepareMessage(ctx context.Context, email *models.Email, at
 	} else {
 		err = s.buildPlainTextMessageWithStructure(ctx, email, headers, buffer)
 	}
-
 	if err != nil {
-		tracing.TraceErr(span, err)
-		return nil, nil, err
+		return nil, err
 	}
 
-	// Store the raw data in the database
-	err = s.repositories.EmailRepository.SetEmailRawData(ctx, email.ID, email.RawHeaders, email.Envelope, email.BodyStructure)
-	if err != nil {
-		tracing.TraceErr(span, err)
+	if s.config != nil && s.config.MaxMessageSizeBytes > 0 && buffer.Len() > s.config.MaxMessageSizeBytes {
+		return nil, errors.Wrapf(mailstack_errors.ErrMessageTooLarge, "%d bytes, limit %d", buffer.Len(), s.config.MaxMessageSizeBytes)
 	}
 
-	return email.AllRecipients(), buffer, nil
+	return buffer, nil
 }
 
 // prepareHeaders generates email headers and stores them in the Email model
@@ -211,70 +332,113 @@ func (s *SMTPClient) prepareEnvelope(ctx context.Context, email *models.Email, h
 }
 
 // buildMultipartMessageWithStructure creates a multipart MIME message with text, HTML, and attachments
-// while also capturing body structure metadata
+// while also capturing body structure metadata. Text and HTML are alternatives of each other, the
+// HTML is related to its inline images and a multipart/mixed is only used for attachments.
 func (s *SMTPClient) buildMultipartMessageWithStructure(ctx context.Context, email *models.Email,
 	headers map[string]string, attachments []*models.EmailAttachment, buffer *bytes.Buffer,
 ) error {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "SMTPClient.buildMultipartMessageWithStructure")
 	defer span.Finish()
 
-	writer := multipart.NewWriter(buffer)
-	boundary := writer.Boundary()
-
-	// Set content-type in headers
-	headers["Content-Type"] = "multipart/mixed; boundary=" + boundary
-
 	// Initialize body structure
 	bodyStructure := s.initializeBodyStructure(email)
-	bodyStructure["type"] = "multipart/mixed"
-	bodyStructure["boundary"] = boundary
 
-	// Initialize parts array for body structure
-	parts := []models.JSONMap{}
-	hasTextPart := false
-	hasHtmlPart := false
+	// Images referenced from the HTML go next to it in a multipart/related part, the rest is
+	// attached to the message
+	inline, regular := splitInlineAttachments(email.BodyHTML, attachments)
+	assignContentIDs(inline, email.FromAddress)
 
-	// Write headers to buffer
-	writeHeaders(headers, buffer)
+	// The message headers are written together with the header of its top level part
+	message := messagePart(headers, buffer)
 
-	// Add text part if available
-	if email.BodyText != "" {
-		if err := s.addTextPart(ctx, writer, email.BodyText); err != nil {
+	if len(regular) == 0 {
+		body, err := s.addBody(ctx, message, email, inline)
+		if err != nil {
 			return err
 		}
-		parts = append(parts, s.createPartMetadata("text/plain", len(email.BodyText), ""))
-		hasTextPart = true
-	}
-
-	// Add HTML part if available
-	if email.BodyHTML != "" {
-		if err := s.addHtmlPart(ctx, writer, email.BodyHTML); err != nil {
+		for k, v := range body {
+			bodyStructure[k] = v
+		}
+	} else {
+		mixed, boundary, err := createNestedMultipart(message, "mixed")
+		if err != nil {
+			tracing.TraceErr(span, err)
 			return err
 		}
-		parts = append(parts, s.createPartMetadata("text/html", len(email.BodyHTML), ""))
-		hasHtmlPart = true
-	}
+		bodyStructure["type"] = "multipart/mixed"
+		bodyStructure["boundary"] = boundary
+
+		parts := []models.JSONMap{}
+		if email.BodyText != "" || email.BodyHTML != "" {
+			body, err := s.addBody(ctx, mixed.CreatePart, email, inline)
+			if err != nil {
+				return err
+			}
+			parts = append(parts, body)
+		}
 
-	// Add attachments if any
-	if attachments != nil && len(attachments) > 0 {
-		for _, attachment := range attachments {
-			if err := s.addAttachment(ctx, writer, attachment); err != nil {
+		for _, attachment := range regular {
+			if err = s.addAttachment(ctx, mixed, attachment); err != nil {
 				return err
 			}
 			parts = append(parts, s.createAttachmentMetadata(attachment))
 		}
+		bodyStructure["parts"] = parts
+
+		if err = mixed.Close(); err != nil {
+			tracing.TraceErr(span, err)
+			return err
+		}
 	}
 
-	// Update body structure with parts information
-	bodyStructure["parts"] = parts
-	bodyStructure["hasTextPart"] = hasTextPart
-	bodyStructure["hasHtmlPart"] = hasHtmlPart
+	bodyStructure["hasTextPart"] = email.BodyText != ""
+	bodyStructure["hasHtmlPart"] = email.BodyHTML != ""
 
 	// Store body structure in Email model
 	email.BodyStructure = bodyStructure
 
-	// Close the multipart writer
-	return writer.Close()
+	return nil
+}
+
+// addBody adds the readable content of the email: a multipart/alternative when there is both
+// text and HTML, otherwise the one that is there. Returns the body structure metadata of it.
+func (s *SMTPClient) addBody(ctx context.Context, createPart createPartFunc, email *models.Email,
+	inline []*models.EmailAttachment,
+) (models.JSONMap, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "SMTPClient.addBody")
+	defer span.Finish()
+
+	switch {
+	case email.BodyText != "" && email.BodyHTML != "":
+		alternative, boundary, err := createNestedMultipart(createPart, "alternative")
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return nil, err
+		}
+		if err = s.addTextPart(ctx, alternative.CreatePart, email.BodyText); err != nil {
+			return nil, err
+		}
+		htmlPart, err := s.addHtmlBody(ctx, alternative.CreatePart, email.BodyHTML, inline)
+		if err != nil {
+			return nil, err
+		}
+		if err = alternative.Close(); err != nil {
+			tracing.TraceErr(span, err)
+			return nil, err
+		}
+		return models.JSONMap{
+			"type":     "multipart/alternative",
+			"boundary": boundary,
+			"parts":    []models.JSONMap{s.createPartMetadata("text/plain", len(email.BodyText), ""), htmlPart},
+		}, nil
+	case email.BodyHTML != "":
+		return s.addHtmlBody(ctx, createPart, email.BodyHTML, inline)
+	default:
+		if err := s.addTextPart(ctx, createPart, email.BodyText); err != nil {
+			return nil, err
+		}
+		return s.createPartMetadata("text/plain", len(email.BodyText), ""), nil
+	}
 }
 
 // buildPlainTextMessageWithStructure creates a simple text-only email and captures body structure
@@ -345,13 +509,13 @@ func writeHeaders(headers map[string]string, buffer *bytes.Buffer) {
 	buffer.WriteString("\r\n")
 }
 
-// addTextPart adds a plain text part to a multipart message
-func (s *SMTPClient) addTextPart(ctx context.Context, writer *multipart.Writer, content string) error {
+// addTextPart adds a quoted-printable plain text part
+func (s *SMTPClient) addTextPart(ctx context.Context, createPart createPartFunc, content string) error {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "SMTPClient.addTextPart")
 	defer span.Finish()
 	tracing.SetDefaultServiceSpanTags(ctx, span)
 
-	textPart, err := writer.CreatePart(textproto.MIMEHeader{
+	textPart, err := createPart(textproto.MIMEHeader{
 		"Content-Type":              {"text/plain; charset=UTF-8"},
 		"Content-Transfer-Encoding": {"quoted-printable"},
 	})
@@ -361,7 +525,7 @@ func (s *SMTPClient) addTextPart(ctx context.Context, writer *multipart.Writer,
 		return err
 	}
 
-	_, err = textPart.Write([]byte(content))
+	err = writeQuotedPrintable(textPart, content)
 	if err != nil {
 		err = fmt.Errorf("failed to write text content: %w", err)
 		tracing.TraceErr(span, err)
@@ -371,13 +535,13 @@ func (s *SMTPClient) addTextPart(ctx context.Context, writer *multipart.Writer,
 	return nil
 }
 
-// addHtmlPart adds an HTML part to a multipart message
-func (s *SMTPClient) addHtmlPart(ctx context.Context, writer *multipart.Writer, content string) error {
+// addHtmlPart adds a quoted-printable HTML part
+func (s *SMTPClient) addHtmlPart(ctx context.Context, createPart createPartFunc, content string) error {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "SMTPClient.addHtmlPart")
 	defer span.Finish()
 	tracing.SetDefaultServiceSpanTags(ctx, span)
 
-	htmlPart, err := writer.CreatePart(textproto.MIMEHeader{
+	htmlPart, err := createPart(textproto.MIMEHeader{
 		"Content-Type":              {"text/html; charset=UTF-8"},
 		"Content-Transfer-Encoding": {"quoted-printable"},
 	})
@@ -387,7 +551,7 @@ func (s *SMTPClient) addHtmlPart(ctx context.Context, writer *multipart.Writer,
 		return err
 	}
 
-	_, err = htmlPart.Write([]byte(content))
+	err = writeQuotedPrintable(htmlPart, content)
 	if err != nil {
 		err = fmt.Errorf("failed to write HTML content: %w", err)
 		tracing.TraceErr(span, err)
@@ -414,11 +578,21 @@ func (s *SMTPClient) addAttachment(ctx context.Context, writer *multipart.Writer
 		return err
 	}
 
-	attachmentPart, err := writer.CreatePart(textproto.MIMEHeader{
+	return s.writeAttachmentPart(ctx, writer, attachment, textproto.MIMEHeader{
 		"Content-Type":              {fmt.Sprintf("%s; name=%q", attachment.ContentType, attachment.Filename)},
 		"Content-Disposition":       {fmt.Sprintf("attachment; filename=%q", attachment.Filename)},
 		"Content-Transfer-Encoding": {"base64"},
 	})
+}
+
+// writeAttachmentPart creates a part with the given header and writes the stored content of the
+// attachment into it
+func (s *SMTPClient) writeAttachmentPart(ctx context.Context, writer *multipart.Writer, attachment *models.EmailAttachment, header textproto.MIMEHeader) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "SMTPClient.writeAttachmentPart")
+	defer span.Finish()
+	tracing.SetDefaultServiceSpanTags(ctx, span)
+
+	attachmentPart, err := writer.CreatePart(header)
 	if err != nil {
 		err = fmt.Errorf("failed to create attachment part: %w", err)
 		tracing.TraceErr(span, err)
@@ -432,7 +606,15 @@ func (s *SMTPClient) addAttachment(ctx context.Context, writer *multipart.Writer
 		return err
 	}
 
-	_, err = attachmentPart.Write(content)
+	// the part is declared base64, wrapped at 76 characters per line as RFC 2045 requires
+	encoded := base64.StdEncoding.EncodeToString(content)
+	for len(encoded) > 0 {
+		line := encoded[:min(76, len(encoded))]
+		encoded = encoded[len(line):]
+		if _, err = attachmentPart.Write([]byte(line + "\r\n")); err != nil {
+			break
+		}
+	}
 	if err != nil {
 		err = fmt.Errorf("failed to write attachment content: %w", err)
 		tracing.TraceErr(span, err)
@@ -442,189 +624,172 @@ func (s *SMTPClient) addAttachment(ctx context.Context, writer *multipart.Writer
 	return nil
 }
 
-// sendToServer sends the prepared email to the SMTP server
-func (s *SMTPClient) sendToServer(ctx context.Context, from string, recipients []string, buffer *bytes.Buffer) error {
+// sendToServer sends the prepared email to the SMTP server and returns its reply to the message
+// data. On failure the reply is the error reply of the server, if it sent one.
+func (s *SMTPClient) sendToServer(ctx context.Context, from string, recipients []string, buffer *bytes.Buffer) (string, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "SMTPClient.sendToServer")
 	defer span.Finish()
 	tracing.SetDefaultServiceSpanTags(ctx, span)
+	span.LogKV("smtp_server", s.mailbox.SmtpServer)
+	span.LogKV("smtp_port", s.mailbox.SmtpPort)
+	span.LogKV("smtp_username", s.mailbox.SmtpUsername)
+	span.LogKV("from_address", from)
 
-	addr := fmt.Sprintf("%s:%d", s.mailbox.SmtpServer, s.mailbox.SmtpPort)
-	auth := smtp.PlainAuth("", s.mailbox.SmtpUsername, s.mailbox.SmtpPassword, s.mailbox.SmtpServer)
+	if s.pool == nil {
+		conn, err := s.connect(ctx)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return serverReply(err), err
+		}
+		defer conn.close()
+
+		response, err := s.transmit(conn.Client, from, recipients, buffer)
+		if err != nil {
+			err = fmt.Errorf("failed to send email: %w", err)
+			tracing.TraceErr(span, err)
+			return serverReply(err), err
+		}
 
-	if s.mailbox.SmtpSecurity == enum.EmailSecurityStartTLS {
-		return s.sendWithSTARTTLS(ctx, addr, auth, from, recipients, buffer)
+		// the message is accepted, a failed QUIT does not change that
+		if err = conn.Client.Quit(); err != nil {
+			tracing.TraceErr(span, err)
+		}
+		return response, nil
 	}
 
-	// Standard SMTP (may use STARTTLS if server supports it)
-	err := smtp.SendMail(addr, auth, from, recipients, buffer.Bytes())
+	conn, err := s.pool.Get(ctx, s.mailbox, s.connect)
 	if err != nil {
+		tracing.TraceErr(span, err)
+		return serverReply(err), err
+	}
+
+	response, err := s.transmit(conn.Client, from, recipients, buffer)
+	if err != nil {
+		// the session state is unknown after a failed transaction, it is not reused
+		s.pool.Discard(conn)
 		err = fmt.Errorf("failed to send email: %w", err)
 		tracing.TraceErr(span, err)
-		return err
+		return serverReply(err), err
 	}
+	s.pool.Put(conn)
 
-	return nil
+	return response, nil
 }
 
-func (s *SMTPClient) sendWithSTARTTLS(ctx context.Context, addr string, auth smtp.Auth, from string, recipients []string, buffer *bytes.Buffer) error {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "SMTPClient.sendWithSTARTTLS")
-	defer span.Finish()
-	tracing.SetDefaultServiceSpanTags(ctx, span)
-	span.LogKV("smtp_server", s.mailbox.SmtpServer)
-	span.LogKV("smtp_port", s.mailbox.SmtpPort)
-	span.LogKV("smtp_username", s.mailbox.SmtpUsername)
-	span.LogKV("from_address", from)
+// connect opens an authenticated session. STARTTLS is required when the mailbox asks for it,
+// otherwise it is used when the server offers it, like smtp.SendMail does.
+func (s *SMTPClient) connect(ctx context.Context) (*Conn, error) {
+	addr := fmt.Sprintf("%s:%d", s.mailbox.SmtpServer, s.mailbox.SmtpPort)
 
-	// Connect to the server without TLS first
-	conn, err := net.Dial("tcp", addr)
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
 	if err != nil {
-		err = fmt.Errorf("failed to connect to SMTP server: %w", err)
-		tracing.TraceErr(span, err)
-		return err
+		return nil, fmt.Errorf("failed to connect to SMTP server: %w", err)
 	}
-	defer conn.Close()
 
-	// Create SMTP client
 	client, err := smtp.NewClient(conn, s.mailbox.SmtpServer)
 	if err != nil {
-		err = fmt.Errorf("failed to create SMTP client: %w", err)
-		tracing.TraceErr(span, err)
-		return err
+		conn.Close()
+		return nil, fmt.Errorf("failed to create SMTP client: %w", err)
 	}
-	defer client.Close()
 
-	// Start TLS
-	tlsConfig := &tls.Config{
-		ServerName: s.mailbox.SmtpServer,
-	}
-	if err = client.StartTLS(tlsConfig); err != nil {
-		err = fmt.Errorf("failed to start TLS: %w", err)
-		tracing.TraceErr(span, err)
-		return err
+	if ok, _ := client.Extension("STARTTLS"); ok || s.mailbox.SmtpSecurity == enum.EmailSecurityStartTLS {
+		if err = client.StartTLS(&tls.Config{ServerName: s.mailbox.SmtpServer}); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to start TLS: %w", err)
+		}
 	}
 
-	// Authenticate after TLS is established
-	if err = client.Auth(auth); err != nil {
-		err = fmt.Errorf("SMTP authentication failed: %w", err)
-		tracing.TraceErr(span, err)
-		return err
+	if ok, _ := client.Extension("AUTH"); ok {
+		auth := smtp.PlainAuth("", s.mailbox.SmtpUsername, s.mailbox.SmtpPassword, s.mailbox.SmtpServer)
+		if err = client.Auth(auth); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("SMTP authentication failed: %w", err)
+		}
 	}
 
-	// Set sender
-	if err = client.Mail(from); err != nil {
-		err = fmt.Errorf("SMTP MAIL command failed: %w", err)
-		tracing.TraceErr(span, err)
-		return err
-	}
+	return &Conn{Client: client, conn: conn}, nil
+}
 
-	// Set recipients
-	for _, recipient := range recipients {
-		if err = client.Rcpt(recipient); err != nil {
-			err = fmt.Errorf("SMTP RCPT command failed for %s: %w", recipient, err)
-			tracing.TraceErr(span, err)
-			return err
+// transmit runs one mail transaction on an open session and returns the reply to the data
+func (s *SMTPClient) transmit(client *smtp.Client, from string, recipients []string, buffer *bytes.Buffer) (string, error) {
+	// Respect the server's advertised SIZE limit (RFC 1870)
+	if ok, param := client.Extension("SIZE"); ok {
+		maxSize, convErr := strconv.Atoi(strings.TrimSpace(param))
+		if convErr == nil && maxSize > 0 && buffer.Len() > maxSize {
+			return "", errors.Wrapf(mailstack_errors.ErrMessageTooLarge, "%d bytes, server limit %d", buffer.Len(), maxSize)
 		}
 	}
 
-	// Send data
-	dataWriter, err := client.Data()
-	if err != nil {
-		err = fmt.Errorf("SMTP DATA command failed: %w", err)
-		tracing.TraceErr(span, err)
-		return err
-	}
-
-	_, err = dataWriter.Write(buffer.Bytes())
-	if err != nil {
-		err = fmt.Errorf("failed to write email data: %w", err)
-		tracing.TraceErr(span, err)
-		return err
+	if err := client.Mail(from); err != nil {
+		return "", fmt.Errorf("SMTP MAIL command failed: %w", err)
 	}
 
-	err = dataWriter.Close()
-	if err != nil {
-		err = fmt.Errorf("failed to close data writer: %w", err)
-		tracing.TraceErr(span, err)
-		return err
+	for _, recipient := range recipients {
+		if err := client.Rcpt(recipient); err != nil {
+			return "", fmt.Errorf("SMTP RCPT command failed for %s: %w", recipient, err)
+		}
 	}
 
-	return client.Quit()
+	return writeData(client, buffer.Bytes())
 }
 
-// sendWithExplicitTLS sends an email using explicit TLS connection
-func (s *SMTPClient) sendWithExplicitTLS(ctx context.Context, addr string, auth smtp.Auth, from string, recipients []string, buffer *bytes.Buffer) error {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "SMTPClient.sendWithExplicitTLS")
-	defer span.Finish()
-	tracing.SetDefaultServiceSpanTags(ctx, span)
-	span.LogKV("address", addr)
-
-	// Create TLS config
-	tlsConfig := &tls.Config{
-		ServerName: s.mailbox.SmtpServer,
-	}
-
-	// Connect to the server
-	conn, err := tls.Dial("tcp", addr, tlsConfig)
+// writeData sends the DATA command and the message. Unlike smtp.Client.Data it keeps the final
+// reply, which usually carries the queue ID of the message on the server.
+func writeData(client *smtp.Client, data []byte) (string, error) {
+	id, err := client.Text.Cmd("DATA")
 	if err != nil {
-		err = fmt.Errorf("failed to connect to SMTP server: %w", err)
-		tracing.TraceErr(span, err)
-		return err
+		return "", fmt.Errorf("SMTP DATA command failed: %w", err)
 	}
-	defer conn.Close()
-
-	// Create SMTP client
-	client, err := smtp.NewClient(conn, s.mailbox.SmtpServer)
+	client.Text.StartResponse(id)
+	_, _, err = client.Text.ReadResponse(354)
+	client.Text.EndResponse(id)
 	if err != nil {
-		err = fmt.Errorf("failed to create SMTP client: %w", err)
-		tracing.TraceErr(span, err)
-		return err
+		return "", fmt.Errorf("SMTP DATA command failed: %w", err)
 	}
-	defer client.Close()
 
-	// Authenticate
-	if err = client.Auth(auth); err != nil {
-		err = fmt.Errorf("SMTP authentication failed: %w", err)
-		tracing.TraceErr(span, err)
-		return err
+	writer := client.Text.DotWriter()
+	if _, err = writer.Write(data); err != nil {
+		writer.Close()
+		return "", fmt.Errorf("failed to write email data: %w", err)
 	}
-
-	// Set sender
-	if err = client.Mail(from); err != nil {
-		err = fmt.Errorf("SMTP MAIL command failed: %w", err)
-		tracing.TraceErr(span, err)
-		return err
+	if err = writer.Close(); err != nil {
+		return "", fmt.Errorf("failed to close data writer: %w", err)
 	}
 
-	// Set recipients
-	for _, recipient := range recipients {
-		if err = client.Rcpt(recipient); err != nil {
-			err = fmt.Errorf("SMTP RCPT command failed for %s: %w", recipient, err)
-			tracing.TraceErr(span, err)
-			return err
-		}
+	code, message, err := client.Text.ReadResponse(250)
+	if err != nil {
+		return "", fmt.Errorf("SMTP server rejected the message: %w", err)
 	}
+	return fmt.Sprintf("%d %s", code, message), nil
+}
 
-	// Send data
-	dataWriter, err := client.Data()
-	if err != nil {
-		err = fmt.Errorf("SMTP DATA command failed: %w", err)
-		tracing.TraceErr(span, err)
-		return err
+// serverReply returns the SMTP reply carried by a send error, empty for errors without one
+func serverReply(err error) string {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return fmt.Sprintf("%d %s", protoErr.Code, protoErr.Msg)
 	}
+	return ""
+}
 
-	_, err = dataWriter.Write(buffer.Bytes())
-	if err != nil {
-		err = fmt.Errorf("failed to write email data: %w", err)
-		tracing.TraceErr(span, err)
-		return err
+func tagSendMetrics(span opentracing.Span, email *models.Email) {
+	span.SetTag("smtp.message_size_bytes", email.MessageSizeBytes)
+	span.SetTag("smtp.recipient_count", email.RecipientCount)
+	span.SetTag("smtp.send_duration_ms", email.SendDurationMs)
+	if email.SmtpResponse != "" {
+		span.SetTag("smtp.response", email.SmtpResponse)
 	}
+}
 
-	err = dataWriter.Close()
+// recordSendMetrics counts a send attempt to the server and observes its duration
+func recordSendMetrics(duration time.Duration, err error) {
+	result := "success"
 	if err != nil {
-		err = fmt.Errorf("failed to close data writer: %w", err)
-		tracing.TraceErr(span, err)
-		return err
+		result = "failure"
+		metrics.EmailsFailed.WithLabelValues(metrics.OperationSend).Inc()
+	} else {
+		metrics.EmailsSent.Inc()
 	}
-
-	return client.Quit()
+	metrics.SendDuration.WithLabelValues(result).Observe(duration.Seconds())
 }
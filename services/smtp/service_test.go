@@ -0,0 +1,128 @@
+package smtp
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	mailstack_errors "github.com/customeros/mailstack/internal/errors"
+	"github.com/customeros/mailstack/internal/models"
+)
+
+// fakeServer answers a single mail transaction, rejecting recipients listed in reject
+func fakeServer(t *testing.T, conn net.Conn, reject string, received *bytes.Buffer) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	write := func(line string) {
+		_, err := conn.Write([]byte(line + "\r\n"))
+		require.NoError(t, err)
+	}
+
+	write("220 fake ESMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(command, "EHLO"):
+			write("250-fake")
+			write("250 SIZE 1000")
+		case strings.HasPrefix(command, "MAIL"):
+			write("250 2.1.0 Ok")
+		case strings.HasPrefix(command, "RCPT"):
+			if reject != "" && strings.Contains(command, reject) {
+				write("550 5.1.1 User unknown")
+			} else {
+				write("250 2.1.5 Ok")
+			}
+		case command == "DATA":
+			write("354 End data with <CR><LF>.<CR><LF>")
+			data, err := textproto.NewReader(reader).ReadDotBytes()
+			require.NoError(t, err)
+			received.Write(data)
+			write("250 2.0.0 Ok: queued as ABC123")
+		case command == "RSET":
+			write("250 2.0.0 Ok")
+		case command == "QUIT":
+			write("221 Bye")
+			return
+		}
+	}
+}
+
+func TestTransmit(t *testing.T) {
+	t.Run("accepted", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+		var received bytes.Buffer
+		go fakeServer(t, serverConn, "", &received)
+
+		client, err := smtp.NewClient(clientConn, "fake")
+		require.NoError(t, err)
+
+		response, err := (&SMTPClient{}).transmit(client, "me@example.com", []string{"you@example.com"}, bytes.NewBufferString("Subject: hi\r\n\r\nhello\r\n"))
+		require.NoError(t, err)
+		assert.Equal(t, "250 2.0.0 Ok: queued as ABC123", response)
+		assert.Equal(t, "Subject: hi\n\nhello\n", received.String())
+		require.NoError(t, client.Quit())
+	})
+
+	t.Run("rejected recipient", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+		go fakeServer(t, serverConn, "nobody@", &bytes.Buffer{})
+
+		client, err := smtp.NewClient(clientConn, "fake")
+		require.NoError(t, err)
+		defer client.Close()
+
+		_, err = (&SMTPClient{}).transmit(client, "me@example.com", []string{"nobody@example.com"}, bytes.NewBufferString("hello"))
+		require.Error(t, err)
+		assert.Equal(t, "550 5.1.1 User unknown", serverReply(err))
+		assert.False(t, IsTransientError(err))
+	})
+
+	t.Run("server size limit", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+		go fakeServer(t, serverConn, "", &bytes.Buffer{})
+
+		client, err := smtp.NewClient(clientConn, "fake")
+		require.NoError(t, err)
+		defer client.Close()
+
+		_, err = (&SMTPClient{}).transmit(client, "me@example.com", []string{"you@example.com"}, bytes.NewBuffer(make([]byte, 2000)))
+		require.Error(t, err)
+		assert.Empty(t, serverReply(err))
+	})
+}
+
+func TestValidateOutgoing(t *testing.T) {
+	t.Run("valid sets sender", func(t *testing.T) {
+		email := &models.Email{FromAddress: "me@mailstack.io", ToAddresses: []string{"you@example.com"}, Subject: "Hi", BodyText: "Hello"}
+		assert.Empty(t, validateOutgoing(email, "mailstack.io"))
+		assert.Equal(t, "mailstack.io", email.FromDomain)
+		assert.Equal(t, "me", email.FromUser)
+	})
+
+	t.Run("reports every problem", func(t *testing.T) {
+		problems := validateOutgoing(&models.Email{FromAddress: "me@other.io"}, "mailstack.io")
+		assert.True(t, problems.HasCode(mailstack_errors.ValidationDomainMismatch))
+		assert.True(t, problems.HasCode(mailstack_errors.ValidationNoRecipients))
+		assert.True(t, problems.HasCode(mailstack_errors.ValidationEmptyBody))
+		assert.True(t, problems.HasCode(mailstack_errors.ValidationNoSubject))
+		assert.Len(t, problems, 4)
+	})
+
+	t.Run("missing from", func(t *testing.T) {
+		problems := validateOutgoing(&models.Email{ToAddresses: []string{"you@example.com"}, Subject: "Hi", BodyHTML: "<p>Hello</p>"}, "mailstack.io")
+		require.Len(t, problems, 1)
+		assert.Equal(t, mailstack_errors.ValidationMissingFrom, problems[0].Code)
+	})
+}
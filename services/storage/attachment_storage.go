@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/customeros/mailstack/interfaces"
+	"github.com/customeros/mailstack/internal/config"
+	"github.com/customeros/mailstack/services/storage/aws_client"
+)
+
+// Backend names, stored on each attachment as its storage service
+const (
+	BackendR2    = "R2"
+	BackendS3    = "S3"
+	BackendMinIO = "MINIO"
+)
+
+// attachmentStorage uploads to the configured backend and bucket, while objects stored before a
+// backend or bucket change are still read from where they were stored. R2 stays available as long
+// as its credentials are set, so attachments stored before other backends existed remain retrievable.
+type attachmentStorage struct {
+	backend string
+	bucket  string
+	clients map[string]aws_client.S3Client
+
+	mu       sync.Mutex
+	services map[string]interfaces.StorageService
+}
+
+func NewAttachmentStorage(cfg *config.AttachmentStorageConfig, r2Config *config.R2StorageConfig) (interfaces.AttachmentStorage, error) {
+	s := &attachmentStorage{
+		backend:  strings.ToUpper(cfg.Backend),
+		bucket:   cfg.Bucket,
+		clients:  make(map[string]aws_client.S3Client),
+		services: make(map[string]interfaces.StorageService),
+	}
+
+	if r2Config != nil && r2Config.AccountID != "" {
+		s.clients[BackendR2] = newR2Client(r2Config.AccountID, r2Config.AccessKeyID, r2Config.AccessKeySecret)
+	}
+
+	switch s.backend {
+	case BackendR2:
+		if s.clients[BackendR2] == nil {
+			return nil, fmt.Errorf("attachment storage backend %s requires the R2 account id and keys", BackendR2)
+		}
+	case BackendS3:
+		s.clients[BackendS3] = newS3Client(cfg.Region, cfg.AccessKeyID, cfg.AccessKeySecret)
+	case BackendMinIO:
+		if cfg.Endpoint == "" {
+			return nil, fmt.Errorf("attachment storage backend %s requires an endpoint", BackendMinIO)
+		}
+		s.clients[BackendMinIO] = newMinIOClient(cfg.Endpoint, cfg.Region, cfg.AccessKeyID, cfg.AccessKeySecret)
+	default:
+		return nil, fmt.Errorf("unknown attachment storage backend %q", cfg.Backend)
+	}
+
+	return s, nil
+}
+
+func (s *attachmentStorage) Default() (string, string) {
+	return s.backend, s.bucket
+}
+
+// Get returns the storage of a backend and bucket, empty values mean the default ones
+func (s *attachmentStorage) Get(backend, bucket string) (interfaces.StorageService, error) {
+	backend = strings.ToUpper(backend)
+	if backend == "" {
+		backend = s.backend
+	}
+	if bucket == "" {
+		bucket = s.bucket
+	}
+
+	client, ok := s.clients[backend]
+	if !ok {
+		return nil, fmt.Errorf("attachment storage backend %q is not configured", backend)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := backend + "/" + bucket
+	service, ok := s.services[key]
+	if !ok {
+		service = NewStorageService(client, StorageConfig{BucketName: bucket})
+		s.services[key] = service
+	}
+	return service, nil
+}
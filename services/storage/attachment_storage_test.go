@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/customeros/mailstack/internal/config"
+)
+
+func TestNewAttachmentStorage(t *testing.T) {
+	r2Config := &config.R2StorageConfig{AccountID: "account", AccessKeyID: "key", AccessKeySecret: "secret"}
+
+	t.Run("r2 requires credentials", func(t *testing.T) {
+		_, err := NewAttachmentStorage(&config.AttachmentStorageConfig{Backend: "r2", Bucket: "attachments"}, &config.R2StorageConfig{})
+		require.Error(t, err)
+	})
+
+	t.Run("minio requires an endpoint", func(t *testing.T) {
+		_, err := NewAttachmentStorage(&config.AttachmentStorageConfig{Backend: "minio", Bucket: "attachments"}, nil)
+		require.Error(t, err)
+	})
+
+	t.Run("unknown backend", func(t *testing.T) {
+		_, err := NewAttachmentStorage(&config.AttachmentStorageConfig{Backend: "azure", Bucket: "attachments"}, r2Config)
+		require.Error(t, err)
+	})
+
+	t.Run("objects stored in r2 stay retrievable after moving to minio", func(t *testing.T) {
+		s, err := NewAttachmentStorage(&config.AttachmentStorageConfig{
+			Backend:  "minio",
+			Bucket:   "mail-files",
+			Endpoint: "http://localhost:9000",
+			Region:   "us-east-1",
+		}, r2Config)
+		require.NoError(t, err)
+
+		backend, bucket := s.Default()
+		assert.Equal(t, BackendMinIO, backend)
+		assert.Equal(t, "mail-files", bucket)
+
+		legacy, err := s.Get("R2", "attachments")
+		require.NoError(t, err)
+		assert.Equal(t, "attachments", legacy.(*ObjectStorageService).bucketName)
+
+		current, err := s.Get("", "")
+		require.NoError(t, err)
+		assert.Equal(t, "mail-files", current.(*ObjectStorageService).bucketName)
+
+		again, err := s.Get("minio", "mail-files")
+		require.NoError(t, err)
+		assert.Same(t, current, again)
+
+		_, err = s.Get(BackendS3, "attachments")
+		require.Error(t, err)
+	})
+}
@@ -10,12 +10,7 @@ import (
 
 // NewS3StorageService creates a StorageService configured for AWS S3
 func NewS3StorageService(awsRegion, accessKeyID, accessKeySecret, bucketName string, isPublic bool) interfaces.StorageService {
-	s3Client := aws_client.NewS3Client(&aws.Config{
-		Region:      aws.String(awsRegion),
-		Credentials: credentials.NewStaticCredentials(accessKeyID, accessKeySecret, ""),
-	})
-
-	return NewStorageService(s3Client, StorageConfig{
+	return NewStorageService(newS3Client(awsRegion, accessKeyID, accessKeySecret), StorageConfig{
 		BucketName: bucketName,
 		IsPublic:   isPublic,
 	})
@@ -23,15 +18,46 @@ func NewS3StorageService(awsRegion, accessKeyID, accessKeySecret, bucketName str
 
 // NewR2StorageService creates a StorageService configured for Cloudflare R2
 func NewR2StorageService(accountID, accessKeyID, accessKeySecret, bucketName string, isPublic bool) interfaces.StorageService {
-	r2Client := aws_client.NewS3Client(&aws.Config{
+	return NewStorageService(newR2Client(accountID, accessKeyID, accessKeySecret), StorageConfig{
+		BucketName: bucketName,
+		IsPublic:   isPublic,
+	})
+}
+
+// NewMinIOStorageService creates a StorageService configured for MinIO or another S3 compatible endpoint
+func NewMinIOStorageService(endpoint, region, accessKeyID, accessKeySecret, bucketName string, isPublic bool) interfaces.StorageService {
+	return NewStorageService(newMinIOClient(endpoint, region, accessKeyID, accessKeySecret), StorageConfig{
+		BucketName: bucketName,
+		IsPublic:   isPublic,
+	})
+}
+
+// newS3Client falls back to the default AWS credential chain when no keys are given
+func newS3Client(awsRegion, accessKeyID, accessKeySecret string) aws_client.S3Client {
+	awsConfig := &aws.Config{
+		Region: aws.String(awsRegion),
+	}
+	if accessKeyID != "" {
+		awsConfig.Credentials = credentials.NewStaticCredentials(accessKeyID, accessKeySecret, "")
+	}
+	return aws_client.NewS3Client(awsConfig)
+}
+
+func newR2Client(accountID, accessKeyID, accessKeySecret string) aws_client.S3Client {
+	return aws_client.NewS3Client(&aws.Config{
 		Endpoint:         aws.String("https://" + accountID + ".r2.cloudflarestorage.com"),
 		Region:           aws.String("auto"),
 		Credentials:      credentials.NewStaticCredentials(accessKeyID, accessKeySecret, ""),
 		S3ForcePathStyle: aws.Bool(true),
 	})
+}
 
-	return NewStorageService(r2Client, StorageConfig{
-		BucketName: bucketName,
-		IsPublic:   isPublic,
+// newMinIOClient uses path style addressing, MinIO does not serve buckets as subdomains by default
+func newMinIOClient(endpoint, region, accessKeyID, accessKeySecret string) aws_client.S3Client {
+	return aws_client.NewS3Client(&aws.Config{
+		Endpoint:         aws.String(endpoint),
+		Region:           aws.String(region),
+		Credentials:      credentials.NewStaticCredentials(accessKeyID, accessKeySecret, ""),
+		S3ForcePathStyle: aws.Bool(true),
 	})
 }